@@ -0,0 +1,135 @@
+package main
+
+/*****************************************************************************
+ * Go 1.21
+ *
+ * main.go - Load test runner for the tracking service.
+ *
+ * Replays a load test scenario (loadtest.Scenario) against a target
+ * environment with simulated walkers and writes the run's report, with
+ * latency percentiles and error rates per operation, as a JSON artifact:
+ *
+ *   loadtest -scenario peak -target https://tracking.staging.example.com -out peak.json
+ *
+ * -scenario names a built-in scenario or a JSON file of scenarios, which are
+ * run one after another; with several, -out is suffixed with each scenario's
+ * name. The exit status is 1 when any run breaches its thresholds, so release
+ * pipelines can fail on performance regressions.
+ *****************************************************************************/
+
+import (
+	// context for stopping runs on interrupt (go1.21)
+	"context"
+	// flag for command-line options (go1.21)
+	"flag"
+	// fmt for run summaries (go1.21)
+	"fmt"
+	// os for exit codes and the environment (go1.21)
+	"os"
+	// signal for interrupting runs (go1.21)
+	"os/signal"
+	// filepath for per-scenario report names (go1.21)
+	"path/filepath"
+	// sort for ordering the summary (go1.21)
+	"sort"
+	// strings for listing scenarios (go1.21)
+	"strings"
+	// syscall for SIGTERM (go1.21)
+	"syscall"
+	// time for the request timeout flag (go1.21)
+	"time"
+
+	// loadtest package for scenarios, the simulator and reports
+	"src/backend/tracking-service/internal/loadtest"
+)
+
+func main() {
+	scenarioFlag := flag.String("scenario", "smoke", "built-in scenario ("+strings.Join(loadtest.BuiltinNames(), ", ")+") or path to a JSON scenario file")
+	targetFlag := flag.String("target", os.Getenv("LOADTEST_TARGET"), "base URL of the tracking service (default $LOADTEST_TARGET)")
+	tokenFlag := flag.String("token", os.Getenv("LOADTEST_TOKEN"), "Authorization header sent with every request (default $LOADTEST_TOKEN)")
+	timeoutFlag := flag.Duration("timeout", 10*time.Second, "timeout of each request")
+	outFlag := flag.String("out", "", "path to write the JSON report to (default stdout)")
+	flag.Parse()
+
+	scenarios, err := resolveScenarios(*scenarioFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	target := loadtest.Target{BaseURL: *targetFlag, Token: *tokenFlag, Timeout: *timeoutFlag}
+	passed := true
+	for _, scenario := range scenarios {
+		fmt.Fprintf(os.Stderr, "running %s: %d sessions at %.2f/s for %s\n",
+			scenario.Name, scenario.Sessions, scenario.Rate, time.Duration(scenario.Duration))
+		report, runErr := loadtest.Run(ctx, scenario, target)
+		if report == nil {
+			fmt.Fprintln(os.Stderr, runErr)
+			os.Exit(2)
+		}
+		if err := writeReport(report, reportPath(*outFlag, scenario.Name, len(scenarios))); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		printSummary(report)
+		if runErr != nil {
+			fmt.Fprintf(os.Stderr, "%s stopped early: %v\n", scenario.Name, runErr)
+			os.Exit(2)
+		}
+		passed = passed && report.Passed
+	}
+	if !passed {
+		os.Exit(1)
+	}
+}
+
+// resolveScenarios returns the built-in scenario named name, or the scenarios
+// in the file at name.
+func resolveScenarios(name string) ([]loadtest.Scenario, error) {
+	if scenario, ok := loadtest.Builtin[name]; ok {
+		return []loadtest.Scenario{scenario}, nil
+	}
+	return loadtest.LoadScenarios(name)
+}
+
+// reportPath returns where the report of scenario goes: out itself for a
+// single scenario, out suffixed with the scenario's name for several, or ""
+// for stdout.
+func reportPath(out, scenario string, scenarios int) string {
+	if out == "" || scenarios == 1 {
+		return out
+	}
+	ext := filepath.Ext(out)
+	return strings.TrimSuffix(out, ext) + "-" + scenario + ext
+}
+
+// writeReport writes report to path, or to stdout when path is empty.
+func writeReport(report *loadtest.Report, path string) error {
+	if path == "" {
+		return report.Write(os.Stdout)
+	}
+	return report.WriteFile(path)
+}
+
+// printSummary prints one line per operation and the threshold failures to
+// stderr.
+func printSummary(report *loadtest.Report) {
+	ops := make([]string, 0, len(report.Operations))
+	for op := range report.Operations {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	for _, op := range ops {
+		stats := report.Operations[op]
+		fmt.Fprintf(os.Stderr, "  %-14s %7d requests  %6.2f%% errors  p50 %.1fms  p95 %.1fms  p99 %.1fms\n",
+			op, stats.Requests, stats.ErrorRate*100, stats.LatencyMs.P50, stats.LatencyMs.P95, stats.LatencyMs.P99)
+	}
+	fmt.Fprintf(os.Stderr, "  points: %d generated, %d dropped, %d sent, %d accepted\n",
+		report.Points.Generated, report.Points.Dropped, report.Points.Sent, report.Points.Accepted)
+	for _, failure := range report.Failures {
+		fmt.Fprintf(os.Stderr, "  FAIL %s\n", failure)
+	}
+}
@@ -9,7 +9,13 @@ package main
  *
  * This file is responsible for:
  *   1. Initializing structured logging (zap).
- *   2. Loading and validating all service configuration (LoadConfig).
+ *   2. Loading and validating all service configuration (LoadConfigFile), from
+ *      environment variables over an optional YAML/JSON config file given by
+ *      -config or CONFIG_FILE; -validate-config only checks it and exits, and
+ *      -alert-rules prints the Prometheus alerting rules recommended for it.
+ *      -escrow-keygen creates a key escrow key pair split among custodians,
+ *      writing each custodian's share to its own file under -escrow-share-dir.
+ *      Reloadable settings are applied again on SIGHUP or when the file changes.
  *   3. Setting up Prometheus metrics collection.
  *   4. Creating and configuring MQTT and TimescaleDB clients with circuit breakers.
  *   5. Spawning the TrackingService and its dependencies.
@@ -21,12 +27,20 @@ package main
 import (
 	// Standard library imports
 	"context"               // go1.21 - For graceful shutdown contexts
+	"database/sql"          // go1.21 - For the repository's database handle
+	"errors"                // go1.21 - For classifying circuit breaker errors
+	"flag"                  // go1.21 - For the config file and validation flags
 	"fmt"                   // go1.21 - For formatted I/O
+	"net"                   // go1.21 - For reporting database endpoint dials
 	"net/http"             // go1.21 - For HTTP server and client
+	"net/url"              // go1.21 - For the MQTT broker address
 	"os"                    // go1.21 - For environment variables, signal handling
 	"os/signal"            // go1.21 - For capturing interrupt/termination signals
-	"strconv"              // go1.21 - For numeric conversions
+	"path/filepath"        // go1.21 - For the escrow share files
+	"strconv"              // go1.21 - For the HTTP status metric label
+	"strings"              // go1.21 - For stripping the MQTT topic prefix
 	"sync"                 // go1.21 - For concurrency controls as needed
+	"sync/atomic"          // go1.21 - For counting in-flight MQTT message handlers
 	"syscall"              // go1.21 - For various system call constants
 	"time"                 // go1.21 - For time-based operations and durations
 
@@ -40,12 +54,22 @@ import (
 	// LocationHandler for handling HTTP/WebSocket requests related to location updates
 	"src/backend/tracking-service/internal/handlers"
 
+	// TimescaleRepository for walk history, statistics and territory persistence
+	"src/backend/tracking-service/internal/repository"
+
+	// ID generators for new location and session IDs
+	"src/backend/tracking-service/internal/models"
+
+	// PoolMonitor for database pool metrics, wait alerts and auto-tuning
+	"src/backend/tracking-service/internal/utils"
+
 	// External imports with version annotations:
 	// gin v1.9.1 - HTTP web framework
 	"github.com/gin-gonic/gin"
 
-	// paho.mqtt.golang v1.4.3 - MQTT client library
-	pahomqtt "github.com/eclipse/paho.mqtt.golang"
+	// paho.golang v0.21.0 - MQTT 5 client library
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
 
 	// pgx/v4 v4.18.1 - PostgreSQL/TimescaleDB driver
 	"github.com/jackc/pgx/v4"
@@ -79,81 +103,399 @@ const (
 	// defaultMaxConnections represents the default maximum number of DB connections if not overridden.
 	defaultMaxConnections = 100
 
-	// defaultMQTTQoS represents the default QoS level for MQTT publish/subscribe operations.
-	defaultMQTTQoS = 1
+	// mqttReasonFailure is the lowest MQTT 5 reason code reporting a failure.
+	mqttReasonFailure = 0x80
 
-	// defaultRateLimit is the default rate limit expressed as "requests per minute".
-	// For example, "100/minute" means 100 requests allowed per minute.
-	defaultRateLimit = "100/minute"
+	// dbBreakerTimeout is how long the TimescaleDB circuit breaker stays open before probing.
+	dbBreakerTimeout = 30 * time.Second
 )
 
 /*****************************************************************************
  * pahoMqttClient - Implementation of the MQTTClient interface from services.
  *****************************************************************************/
 
-// pahoMqttClient wraps the paho.mqtt.golang client to implement services.MQTTClient.
+// pahoMqttClient wraps the paho.golang MQTT 5 connection manager to implement
+// services.MQTTClient. The connection manager reconnects on its own; the
+// subscriptions are made again whenever the connection comes up. Publishes
+// pass through a circuit breaker whose fallback queue holds the messages the
+// broker could not take, so publishers never wait out an outage. Incoming
+// messages are handled on the session dispatcher's workers, in order per
+// session, rather than on paho's router goroutine.
 type pahoMqttClient struct {
-	client        pahomqtt.Client
-	retryAttempts int
-	backoff       time.Duration
-	logger        *zap.Logger
+	client         *autopaho.ConnectionManager
+	breaker        *utils.PublishBreaker
+	cancel         context.CancelFunc
+	publishTimeout time.Duration
+	logger         *zap.Logger
+
+	// handlers keeps each subscription's handler, by the topic it was made
+	// for, so subscriptions can be restored and a paused topic resubscribed;
+	// paused lists the topics currently unsubscribed. subMu serializes
+	// subscription changes, which wait on the broker; routeMu guards the maps
+	// read by every incoming message.
+	subMu    sync.Mutex
+	routeMu  sync.RWMutex
+	handlers map[string]func(*paho.Publish)
+	paused   map[string]bool
+
+	// dispatcher runs message handlers, hashed by dispatchKey onto its
+	// workers; inFlight counts handlers queued or still running.
+	dispatcher *utils.SessionDispatcher
+	inFlight   atomic.Int64
+
+	// topicPrefix is prepended to every topic; subscriptions matching one of
+	// sharedTopics are made in sharedGroup when it is set.
+	topicPrefix  string
+	sharedGroup  string
+	sharedTopics []string
+
+	// qos is the QoS level of each message class. Subscriptions may carry
+	// several classes, so they are made with the highest level.
+	qos config.MQTTQoSConfig
+
+	// metrics.mqttMessages is labelled by topicLabels, which reports topic
+	// templates unless high-cardinality metrics are enabled.
+	metrics     *serviceMetrics
+	topicLabels *utils.TopicLabeler
+}
+
+// Publish sends a message payload to the specified MQTT topic with the QoS
+// configured for its class, through the publish circuit breaker. Messages the
+// broker cannot take are queued for delivery once it recovers; an error is
+// returned only for messages dropped.
+func (pmc *pahoMqttClient) Publish(class utils.MessageClass, topic string, payload []byte) error {
+	return pmc.PublishWithProperties(class, topic, payload, nil)
+}
+
+// PublishWithProperties publishes like Publish, with the given MQTT 5 message
+// properties; props may be nil.
+func (pmc *pahoMqttClient) PublishWithProperties(class utils.MessageClass, topic string, payload []byte, props *utils.MessageProperties) error {
+	label := pmc.topicLabels.Label(topic)
+	topic = utils.PrefixTopic(pmc.topicPrefix, topic)
+	err := pmc.breaker.Publish(func() error {
+		err := pmc.publish(class, topic, payload, props)
+		if err == nil {
+			pmc.metrics.mqttMessages.WithLabelValues("published", label).Inc()
+		}
+		return err
+	})
+	if err != nil {
+		pmc.metrics.mqttMessages.WithLabelValues("dropped", label).Inc()
+		pmc.logger.Error("MQTT publish dropped", zap.String("topic", topic), zap.String("class", string(class)), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// publish makes one attempt at publishing payload to the prefixed topic.
+func (pmc *pahoMqttClient) publish(class utils.MessageClass, topic string, payload []byte, props *utils.MessageProperties) error {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), pmc.publishTimeout)
+	defer cancel()
+	_, err := pmc.client.Publish(ctx, &paho.Publish{
+		Topic:      topic,
+		QoS:        class.QoS(pmc.qos),
+		Payload:    payload,
+		Properties: props.PahoProperties(),
+	})
+	pmc.metrics.mqttPublishDuration.WithLabelValues(metricOutcome(err)).Observe(time.Since(start).Seconds())
+	if err != nil {
+		pmc.logger.Warn("MQTT publish failed", zap.String("topic", topic), zap.String("class", string(class)), zap.Error(err))
+	}
+	return err
+}
+
+// Subscribe registers a handler for the given MQTT topic, allowing pahoMqttClient to
+// double as the services.MessageBus used for multi-region replication.
+func (pmc *pahoMqttClient) Subscribe(topic string, handler func(payload []byte)) error {
+	pmc.subMu.Lock()
+	defer pmc.subMu.Unlock()
+	// The handler is in place before the broker starts delivering.
+	pmc.routeMu.Lock()
+	pmc.handlers[topic] = func(msg *paho.Publish) { handler(msg.Payload) }
+	pmc.routeMu.Unlock()
+	if err := pmc.subscribe(topic); err != nil {
+		pmc.routeMu.Lock()
+		delete(pmc.handlers, topic)
+		pmc.routeMu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// subscribe subscribes to topic, waiting for the broker's ack.
+func (pmc *pahoMqttClient) subscribe(topic string) error {
+	filter := pmc.subscriptionFilter(topic)
+	ctx, cancel := context.WithTimeout(context.Background(), pmc.publishTimeout)
+	defer cancel()
+	suback, err := pmc.client.Subscribe(ctx, &paho.Subscribe{
+		Subscriptions: []paho.SubscribeOptions{{Topic: filter, QoS: byte(pmc.qos.Max())}},
+	})
+	if err == nil && len(suback.Reasons) > 0 && suback.Reasons[0] >= mqttReasonFailure {
+		err = fmt.Errorf("broker refused subscription with reason code 0x%02x", suback.Reasons[0])
+	}
+	if err != nil {
+		pmc.logger.Error("MQTT subscribe failed", zap.String("topic", filter), zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// resubscribe makes the subscriptions of every handler not paused again, as
+// the broker holds no session for a client connecting with a clean start.
+func (pmc *pahoMqttClient) resubscribe() {
+	pmc.subMu.Lock()
+	defer pmc.subMu.Unlock()
+	pmc.routeMu.RLock()
+	topics := make([]string, 0, len(pmc.handlers))
+	for topic := range pmc.handlers {
+		if !pmc.paused[topic] {
+			topics = append(topics, topic)
+		}
+	}
+	pmc.routeMu.RUnlock()
+	for _, topic := range topics {
+		_ = pmc.subscribe(topic)
+	}
+}
+
+// route hands the handler of each subscription matching the message's topic
+// to the session dispatcher, so the messages of a session are handled in
+// arrival order and a burst cannot start unbounded goroutines. Messages whose
+// worker is saturated are dropped and logged.
+func (pmc *pahoMqttClient) route(msg *paho.Publish) {
+	topic := msg.Topic
+	if pmc.topicPrefix != "" {
+		topic = strings.TrimPrefix(topic, pmc.topicPrefix+"/")
+	}
+	key := dispatchKey(topic)
+	label := pmc.topicLabels.Label(topic)
+	pmc.metrics.mqttMessages.WithLabelValues("received", label).Inc()
+	pmc.routeMu.RLock()
+	defer pmc.routeMu.RUnlock()
+	for filter, handler := range pmc.handlers {
+		if pmc.paused[filter] || !utils.TopicFilterMatches(filter, topic) {
+			continue
+		}
+		handler := handler
+		pmc.inFlight.Add(1)
+		err := pmc.dispatcher.Dispatch(key, func() {
+			defer pmc.inFlight.Add(-1)
+			handler(msg)
+		})
+		if err != nil {
+			pmc.inFlight.Add(-1)
+			pmc.metrics.mqttMessages.WithLabelValues("dropped", label).Inc()
+			pmc.logger.Warn("Dropping MQTT message", zap.String("topic", topic), zap.Error(err))
+		}
+	}
+}
+
+// liveTopicTemplates lists the templates of the topics the service publishes
+// and subscribes to, which label MQTT metrics in place of the concrete topics
+// embedding session, walker, owner or tenant IDs.
+func liveTopicTemplates() []string {
+	formats := []string{
+		services.UploadTopicFormat,
+		services.UploadProtobufTopicFormat,
+		services.UploadAckTopicFormat,
+		services.ControlTopicFormat,
+		services.WalkerPresenceTopicFormat,
+		services.WalkerOfflineAlertTopicFormat,
+		services.TenantEscrowTopicFormat,
+		services.ReplicationSubjectFormat,
+		"tracking/updates/%s",
+		"tracking/geofence/%s",
+		"tracking/owners/%s/alerts",
+	}
+	templates := make([]string, 0, len(formats))
+	for _, format := range formats {
+		templates = append(templates, fmt.Sprintf(format, "+"))
+	}
+	return templates
+}
+
+// dispatchKey returns the key a message's handling is ordered by: the session
+// ID for session topics, so a session's JSON and protobuf uploads share a
+// worker, and the topic itself otherwise.
+func dispatchKey(topic string) string {
+	if levels := strings.SplitN(topic, "/", 3); len(levels) >= 2 && levels[0] == "sessions" {
+		return levels[1]
+	}
+	return topic
+}
+
+// subscriptionFilter returns the filter subscribed to for topic: prefixed, and
+// shared in the share group when topic matches one of the shared topics.
+func (pmc *pahoMqttClient) subscriptionFilter(topic string) string {
+	filter := utils.PrefixTopic(pmc.topicPrefix, topic)
+	if pmc.sharedGroup == "" {
+		return filter
+	}
+	for _, shared := range pmc.sharedTopics {
+		if utils.TopicFilterMatches(shared, topic) {
+			return utils.SharedSubscription(pmc.sharedGroup, filter)
+		}
+	}
+	return filter
 }
 
-// Publish sends a message payload to the specified MQTT topic with the configured QoS.
-func (pmc *pahoMqttClient) Publish(topic string, payload []byte) error {
-	if token := pmc.client.Publish(topic, byte(defaultMQTTQoS), false, payload); token.Wait() && token.Error() != nil {
-		pmc.logger.Error("MQTT publish failed", zap.String("topic", topic), zap.Error(token.Error()))
-		return token.Error()
+// InFlight returns the number of message handlers queued on the session
+// dispatcher or still running, so it grows while processing falls behind.
+func (pmc *pahoMqttClient) InFlight() int {
+	return int(pmc.inFlight.Load())
+}
+
+// PauseSubscription unsubscribes from topic, keeping its handler for
+// ResumeSubscription. Topics not subscribed or already paused are ignored.
+func (pmc *pahoMqttClient) PauseSubscription(topic string) error {
+	pmc.subMu.Lock()
+	defer pmc.subMu.Unlock()
+	pmc.routeMu.RLock()
+	_, subscribed := pmc.handlers[topic]
+	paused := pmc.paused[topic]
+	pmc.routeMu.RUnlock()
+	if !subscribed || paused {
+		return nil
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), pmc.publishTimeout)
+	defer cancel()
+	if _, err := pmc.client.Unsubscribe(ctx, &paho.Unsubscribe{Topics: []string{pmc.subscriptionFilter(topic)}}); err != nil {
+		return err
+	}
+	pmc.routeMu.Lock()
+	pmc.paused[topic] = true
+	pmc.routeMu.Unlock()
 	return nil
 }
 
-// SetRetryPolicy configures how many times we attempt to resend or recover from failures and the backoff duration.
-func (pmc *pahoMqttClient) SetRetryPolicy(retries int, backoff time.Duration) {
-	pmc.retryAttempts = retries
-	pmc.backoff = backoff
+// ResumeSubscription resubscribes a paused topic with its original handler.
+func (pmc *pahoMqttClient) ResumeSubscription(topic string) error {
+	pmc.subMu.Lock()
+	defer pmc.subMu.Unlock()
+	pmc.routeMu.RLock()
+	paused := pmc.paused[topic]
+	pmc.routeMu.RUnlock()
+	if !paused {
+		return nil
+	}
+	pmc.routeMu.Lock()
+	delete(pmc.paused, topic)
+	pmc.routeMu.Unlock()
+	if err := pmc.subscribe(topic); err != nil {
+		pmc.routeMu.Lock()
+		pmc.paused[topic] = true
+		pmc.routeMu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// Disconnect disconnects from the broker and stops reconnecting, then lets
+// the session dispatcher finish the messages already received. Messages
+// still waiting in the fallback queue are dropped.
+func (pmc *pahoMqttClient) Disconnect(ctx context.Context) error {
+	defer pmc.cancel()
+	if pending := pmc.breaker.Pending(); pending > 0 {
+		pmc.logger.Warn("Dropping MQTT messages still queued for the broker", zap.Int("messages", pending))
+	}
+	pmc.breaker.Stop()
+	err := pmc.client.Disconnect(ctx)
+	pmc.dispatcher.Stop()
+	return err
 }
 
 /*****************************************************************************
  * newMQTTClient - Builds and configures a pahoMqttClient with QoS and connection settings.
  *****************************************************************************/
 
-func newMQTTClient(cfg *config.Config, logger *zap.Logger) (services.MQTTClient, error) {
+func newMQTTClient(cfg *config.Config, metrics *serviceMetrics, registry *prometheus.Registry, logger *zap.Logger) (services.MQTTClient, error) {
 	if cfg == nil {
 		return nil, fmt.Errorf("cannot create MQTT client: provided config is nil")
 	}
 
-	opts := pahomqtt.NewClientOptions()
 	brokerURL := fmt.Sprintf("tcp://%s:%d", cfg.MQTT.Host, cfg.MQTT.Port)
-	opts.AddBroker(brokerURL)
-	opts.SetClientID("tracking-service-client")
+	serverURL, err := url.Parse(brokerURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MQTT broker address %q: %w", brokerURL, err)
+	}
 	if cfg.MQTT.TLSEnabled {
 		// In production, configure TLS settings/certs here.
 	}
-	opts.SetUsername(cfg.MQTT.Username)
-	opts.SetPassword(cfg.MQTT.Password)
-	opts.SetConnectTimeout(cfg.MQTT.ConnectionTimeout)
-	opts.SetKeepAlive(cfg.MQTT.KeepAlive)
-	opts.SetAutoReconnect(true)
-	opts.SetOrderMatters(false)
 
-	client := pahomqtt.NewClient(opts)
-	token := client.Connect()
-	if ok := token.WaitTimeout(10 * time.Second); !ok {
-		return nil, fmt.Errorf("MQTT connection timed out: %s", brokerURL)
+	pmc := &pahoMqttClient{
+		breaker:        utils.NewPublishBreaker(cfg.MQTT, logger, registry),
+		dispatcher:     utils.NewSessionDispatcher(cfg.MQTT.DispatchWorkers, cfg.MQTT.DispatchQueueSize),
+		publishTimeout: cfg.MQTT.ConnectionTimeout,
+		logger:         logger,
+		handlers:       make(map[string]func(*paho.Publish)),
+		paused:         make(map[string]bool),
+		topicPrefix:    cfg.MQTT.TopicPrefix,
+		sharedGroup:    cfg.MQTT.SharedGroup,
+		sharedTopics:   cfg.MQTT.SharedTopics,
+		qos:            cfg.MQTT.QoS,
+		metrics:        metrics,
+		topicLabels:    utils.NewTopicLabeler(cfg.Metrics.HighCardinality, cfg.Metrics.MaxLabelValues, liveTopicTemplates()...),
 	}
-	if err := token.Error(); err != nil {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client, err := autopaho.NewConnection(ctx, autopaho.ClientConfig{
+		ServerUrls:                    []*url.URL{serverURL},
+		KeepAlive:                     uint16(cfg.MQTT.KeepAlive.Seconds()),
+		CleanStartOnInitialConnection: true,
+		ConnectTimeout:                cfg.MQTT.ConnectionTimeout,
+		ConnectUsername:               cfg.MQTT.Username,
+		ConnectPassword:               []byte(cfg.MQTT.Password),
+		OnConnectionUp: func(*autopaho.ConnectionManager, *paho.Connack) {
+			logger.Info("MQTT connection up", zap.String("brokerURL", brokerURL))
+			// The first connection has nothing to restore; pmc.client is set
+			// before any subscription is made.
+			go pmc.resubscribe()
+		},
+		OnConnectError: func(err error) {
+			logger.Warn("MQTT connection attempt failed", zap.String("brokerURL", brokerURL), zap.Error(err))
+		},
+		ClientConfig: paho.ClientConfig{
+			ClientID: cfg.MQTT.ClientID,
+			OnPublishReceived: []func(paho.PublishReceived) (bool, error){
+				func(received paho.PublishReceived) (bool, error) {
+					pmc.route(received.Packet)
+					return true, nil
+				},
+			},
+		},
+	})
+	if err != nil {
+		cancel()
+		pmc.breaker.Stop()
+		pmc.dispatcher.Stop()
 		return nil, fmt.Errorf("MQTT connection failed: %w", err)
 	}
+	pmc.client = client
+	pmc.cancel = cancel
+
+	awaitCtx, awaitCancel := context.WithTimeout(ctx, 10*time.Second)
+	defer awaitCancel()
+	if err := client.AwaitConnection(awaitCtx); err != nil {
+		cancel()
+		pmc.breaker.Stop()
+		pmc.dispatcher.Stop()
+		return nil, fmt.Errorf("MQTT connection timed out: %s: %w", brokerURL, err)
+	}
 
-	logger.Info("MQTT client connected successfully", zap.String("brokerURL", brokerURL))
+	logger.Info("MQTT client connected successfully",
+		zap.String("brokerURL", brokerURL),
+		zap.String("clientID", cfg.MQTT.ClientID),
+		zap.String("topicPrefix", cfg.MQTT.TopicPrefix),
+	)
+	if cfg.MQTT.SharedGroup != "" {
+		logger.Info("MQTT shared subscriptions enabled",
+			zap.String("group", cfg.MQTT.SharedGroup),
+			zap.Strings("topics", cfg.MQTT.SharedTopics),
+		)
+	}
 
-	return &pahoMqttClient{
-		client:        client,
-		retryAttempts: 3,
-		backoff:       2 * time.Second,
-		logger:        logger,
-	}, nil
+	return pmc, nil
 }
 
 /*****************************************************************************
@@ -162,10 +504,68 @@ func newMQTTClient(cfg *config.Config, logger *zap.Logger) (services.MQTTClient,
 
 type timescaleDBConn struct {
 	pool     *pgxpool.Pool
+	poolCfg  *pgxpool.Config
 	breaker  *gobreaker.CircuitBreaker
 	mu       sync.Mutex
 	logger   *zap.Logger
 	cfg      *config.DBConfig
+	metrics  *serviceMetrics
+}
+
+// currentPool returns the active pool, which Resize may replace at runtime.
+func (tsdb *timescaleDBConn) currentPool() *pgxpool.Pool {
+	tsdb.mu.Lock()
+	defer tsdb.mu.Unlock()
+	return tsdb.pool
+}
+
+// PoolSnapshot implements utils.PoolController using pgxpool statistics.
+func (tsdb *timescaleDBConn) PoolSnapshot() utils.PoolSnapshot {
+	tsdb.mu.Lock()
+	pool, minConns := tsdb.pool, tsdb.poolCfg.MinConns
+	tsdb.mu.Unlock()
+
+	stat := pool.Stat()
+	return utils.PoolSnapshot{
+		AcquiredConns:        stat.AcquiredConns(),
+		IdleConns:            stat.IdleConns(),
+		TotalConns:           stat.TotalConns(),
+		MinConns:             minConns,
+		MaxConns:             stat.MaxConns(),
+		AcquireCount:         stat.AcquireCount(),
+		EmptyAcquireCount:    stat.EmptyAcquireCount(),
+		CanceledAcquireCount: stat.CanceledAcquireCount(),
+		AcquireDuration:      stat.AcquireDuration(),
+	}
+}
+
+// Resize implements utils.PoolController. pgxpool limits are fixed at creation,
+// so a new pool is connected with the new limits and swapped in; the old pool
+// is closed once its in-flight connections are released.
+func (tsdb *timescaleDBConn) Resize(minConns, maxConns int32) error {
+	tsdb.mu.Lock()
+	newCfg := tsdb.poolCfg.Copy()
+	tsdb.mu.Unlock()
+	newCfg.MinConns = minConns
+	newCfg.MaxConns = maxConns
+
+	newPool, err := pgxpool.ConnectConfig(context.Background(), newCfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect resized pool: %w", err)
+	}
+
+	tsdb.mu.Lock()
+	oldPool := tsdb.pool
+	tsdb.pool = newPool
+	tsdb.poolCfg = newCfg
+	tsdb.mu.Unlock()
+
+	go oldPool.Close()
+	tsdb.logger.Info("TimescaleDB pool resized",
+		zap.Int32("minConns", minConns),
+		zap.Int32("maxConns", maxConns),
+	)
+	return nil
 }
 
 // StoreLocationBatch persists a collection of location records. This method
@@ -174,7 +574,7 @@ func (tsdb *timescaleDBConn) StoreLocationBatch(sessionID string, locBatch []*se
 	_, err := tsdb.breaker.Execute(func() (interface{}, error) {
 		// Example insert or upsert logic. The real schema is not shown here
 		// as we only have a placeholder in the specification.
-		conn, err := tsdb.pool.Acquire(context.Background())
+		conn, err := tsdb.currentPool().Acquire(context.Background())
 		if err != nil {
 			return nil, err
 		}
@@ -195,9 +595,13 @@ func (tsdb *timescaleDBConn) StoreLocationBatch(sessionID string, locBatch []*se
 			)
 		}
 
+		start := time.Now()
 		br := conn.SendBatch(context.Background(), batch)
-		defer br.Close()
-		if _, batchErr := br.Exec(); batchErr != nil {
+		_, batchErr := br.Exec()
+		br.Close()
+		tsdb.metrics.dbBatchDuration.WithLabelValues(metricOutcome(batchErr)).Observe(time.Since(start).Seconds())
+		tsdb.metrics.dbBatchSize.Observe(float64(len(locBatch)))
+		if batchErr != nil {
 			return nil, batchErr
 		}
 		return nil, nil
@@ -208,6 +612,11 @@ func (tsdb *timescaleDBConn) StoreLocationBatch(sessionID string, locBatch []*se
 			zap.String("sessionID", sessionID),
 			zap.Error(err),
 		)
+		// An open breaker refuses writes without trying them; report it as the
+		// store being unavailable so the batch can be spooled.
+		if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+			return fmt.Errorf("%w: %w", services.ErrStoreUnavailable, err)
+		}
 		return err
 	}
 	return nil
@@ -216,7 +625,7 @@ func (tsdb *timescaleDBConn) StoreLocationBatch(sessionID string, locBatch []*se
 // RecordSessionMetrics updates aggregated session metrics in TimescaleDB.
 func (tsdb *timescaleDBConn) RecordSessionMetrics(sessionID string, stats interface{}) error {
 	_, err := tsdb.breaker.Execute(func() (interface{}, error) {
-		conn, err := tsdb.pool.Acquire(context.Background())
+		conn, err := tsdb.currentPool().Acquire(context.Background())
 		if err != nil {
 			return nil, err
 		}
@@ -236,7 +645,7 @@ func (tsdb *timescaleDBConn) RecordSessionMetrics(sessionID string, stats interf
 
 // Close releases database resources.
 func (tsdb *timescaleDBConn) Close() error {
-	tsdb.pool.Close()
+	tsdb.currentPool().Close()
 	return nil
 }
 
@@ -244,7 +653,7 @@ func (tsdb *timescaleDBConn) Close() error {
  * newTimescaleDB - Creates a new TimescaleDB connection with circuit breaker.
  *****************************************************************************/
 
-func newTimescaleDB(cfg *config.Config, logger *zap.Logger) (services.TimescaleDB, error) {
+func newTimescaleDB(cfg *config.Config, router *repository.EndpointRouter, metrics *serviceMetrics, logger *zap.Logger) (services.TimescaleDB, error) {
 	if cfg == nil {
 		return nil, fmt.Errorf("cannot create TimescaleDB: provided config is nil")
 	}
@@ -267,6 +676,9 @@ func newTimescaleDB(cfg *config.Config, logger *zap.Logger) (services.TimescaleD
 	poolCfg.MaxConnIdleTime = dbCfg.MaxConnectionLifetime
 	poolCfg.MaxConns = int32(dbCfg.MaxConnections)
 	poolCfg.MinConns = 1
+	if router != nil {
+		routePool(poolCfg, router)
+	}
 
 	pool, err := pgxpool.ConnectConfig(context.Background(), poolCfg)
 	if err != nil {
@@ -290,8 +702,9 @@ func newTimescaleDB(cfg *config.Config, logger *zap.Logger) (services.TimescaleD
 		Name:        "TimescaleDBBreaker",
 		MaxRequests: 3,
 		Interval:    60 * time.Second,
-		Timeout:     30 * time.Second,
+		Timeout:     dbBreakerTimeout,
 		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
+			metrics.dbBreakerState.Set(float64(to))
 			logger.Warn("Circuit breaker state changed",
 				zap.String("name", name),
 				zap.String("from", from.String()),
@@ -303,61 +716,313 @@ func newTimescaleDB(cfg *config.Config, logger *zap.Logger) (services.TimescaleD
 
 	tsdb := &timescaleDBConn{
 		pool:    pool,
+		poolCfg: poolCfg,
 		breaker: breaker,
 		logger:  logger,
 		cfg:     &dbCfg,
+		metrics: metrics,
 	}
 	return tsdb, nil
 }
 
+// routePool makes the pool connect to the router's preferred endpoint, report
+// each dial's outcome to the router, and discard idle connections to an
+// endpoint that is no longer preferred instead of handing them out.
+func routePool(poolCfg *pgxpool.Config, router *repository.EndpointRouter) {
+	poolCfg.BeforeConnect = func(ctx context.Context, connCfg *pgx.ConnConfig) error {
+		ep := router.Preferred()
+		connCfg.Host = ep.Host
+		connCfg.Port = uint16(ep.Port)
+		connCfg.Fallbacks = nil
+
+		dial := connCfg.DialFunc
+		connCfg.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dial(ctx, network, addr)
+			router.ReportConnect(ep, err)
+			return conn, err
+		}
+		return nil
+	}
+	poolCfg.BeforeAcquire = func(ctx context.Context, conn *pgx.Conn) bool {
+		ep := repository.DBEndpoint{Host: conn.Config().Host, Port: int(conn.Config().Port)}
+		if !router.IsPreferred(ep) {
+			return false
+		}
+		router.RecordRequest(ep)
+		return true
+	}
+}
+
+/*****************************************************************************
+ * newEndpointRouter - Routes database connections across multi-AZ endpoints.
+ *****************************************************************************/
+
+// newEndpointRouter creates and starts the router over the configured database
+// endpoints, preferring those in this instance's zone. It returns nil when no
+// endpoints are configured and Host and Port are used directly.
+func newEndpointRouter(dbCfg config.DBConfig, logger *zap.Logger, registry *prometheus.Registry) (*repository.EndpointRouter, error) {
+	if len(dbCfg.Endpoints) == 0 {
+		return nil, nil
+	}
+	endpoints := make([]repository.DBEndpoint, 0, len(dbCfg.Endpoints))
+	for _, ep := range dbCfg.Endpoints {
+		endpoints = append(endpoints, repository.DBEndpoint{Zone: ep.Zone, Host: ep.Host, Port: ep.Port})
+	}
+	router, err := repository.NewEndpointRouter(dbCfg.Zone, endpoints, func(ep repository.DBEndpoint) string {
+		return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s connect_timeout=%d",
+			ep.Host,
+			ep.Port,
+			dbCfg.Username,
+			dbCfg.Password,
+			dbCfg.Database,
+			int(dbCfg.ConnectionTimeout.Seconds()),
+		)
+	}, logger, registry)
+	if err != nil {
+		return nil, err
+	}
+	router.Start(dbCfg.EndpointHealthInterval, dbCfg.ConnectionTimeout)
+	return router, nil
+}
+
+/*****************************************************************************
+ * newTimescaleRepository - Opens the database/sql handle backing the repository.
+ *****************************************************************************/
+
+func newTimescaleRepository(dbCfg config.DBConfig, router *repository.EndpointRouter, logger *zap.Logger) (*repository.TimescaleRepository, error) {
+	var db *sql.DB
+	if router != nil {
+		db = sql.OpenDB(router.Connector())
+	} else {
+		connStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s connect_timeout=%d",
+			dbCfg.Host,
+			dbCfg.Port,
+			dbCfg.Username,
+			dbCfg.Password,
+			dbCfg.Database,
+			int(dbCfg.ConnectionTimeout.Seconds()),
+		)
+
+		var err error
+		db, err = sql.Open("postgres", connStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open repository database handle: %w", err)
+		}
+	}
+	db.SetMaxOpenConns(dbCfg.MaxConnections)
+	db.SetMaxIdleConns(dbCfg.MaxIdleConnections)
+	db.SetConnMaxLifetime(dbCfg.MaxConnectionLifetime)
+
+	repo, err := repository.NewTimescaleRepository(db, dbCfg.Schema, repository.RepositoryConfig{
+		EventsChunkInterval:   dbCfg.EventsChunkInterval,
+		SessionsChunkInterval: dbCfg.SessionsChunkInterval,
+		EventRetention:        dbCfg.EventRetention,
+		SessionRetention:      dbCfg.SessionRetention,
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to initialize repository schema: %w", err)
+	}
+
+	logger.Info("TimescaleDB repository initialized", zap.String("schema", dbCfg.Schema))
+	return repo, nil
+}
+
 /*****************************************************************************
  * setupMetrics - Configures and registers Prometheus metrics for the service.
  *****************************************************************************/
 
-func setupMetrics() *prometheus.Registry {
+// serviceMetrics holds the latency histograms of the HTTP API, MQTT publishes
+// and TimescaleDB batch inserts, the MQTT message counts by topic template, and
+// the state of the TimescaleDB circuit breaker.
+type serviceMetrics struct {
+	httpRequestDuration *prometheus.HistogramVec
+	mqttPublishDuration *prometheus.HistogramVec
+	mqttMessages        *prometheus.CounterVec
+	dbBatchDuration     *prometheus.HistogramVec
+	dbBatchSize         prometheus.Histogram
+	dbBreakerState      prometheus.Gauge
+}
+
+func setupMetrics() (*prometheus.Registry, *serviceMetrics) {
 	registry := prometheus.NewRegistry()
 
 	// Register default Go metrics.
 	registry.MustRegister(prometheus.NewGoCollector())
 
-	// Additional custom metrics can be added here if needed.
-	return registry
+	metrics := &serviceMetrics{
+		httpRequestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "tracking_http_request_duration_seconds",
+			Help:    "Time taken to serve HTTP requests, by route, method and status",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		mqttPublishDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "tracking_mqtt_publish_duration_seconds",
+			Help:    "Time taken for the broker to acknowledge MQTT publishes, by outcome",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"outcome"}),
+		mqttMessages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tracking_mqtt_messages_total",
+			Help: "MQTT messages published, received and dropped, by topic template (raw topics in high-cardinality mode)",
+		}, []string{"direction", "topic"}),
+		dbBatchDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "tracking_db_batch_insert_duration_seconds",
+			Help:    "Time taken to insert location batches into TimescaleDB, by outcome",
+			Buckets: prometheus.ExponentialBuckets(0.002, 2, 12),
+		}, []string{"outcome"}),
+		dbBatchSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "tracking_db_batch_insert_size",
+			Help:    "Locations per batch inserted into TimescaleDB",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+		}),
+		dbBreakerState: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "tracking_db_breaker_state",
+			Help: "State of the TimescaleDB circuit breaker: 0 closed, 1 half-open, 2 open",
+		}),
+	}
+	registry.MustRegister(
+		metrics.httpRequestDuration,
+		metrics.mqttPublishDuration,
+		metrics.mqttMessages,
+		metrics.dbBatchDuration,
+		metrics.dbBatchSize,
+		metrics.dbBreakerState,
+	)
+	return registry, metrics
+}
+
+// alertThresholds collects the limits of cfg the recommended alerting rules are
+// derived from, leaving out the features it disables.
+func alertThresholds(cfg *config.Config) services.AlertThresholds {
+	th := services.AlertThresholds{BreakerTimeout: dbBreakerTimeout, MQTTBreakerTimeout: cfg.MQTT.BreakerTimeout}
+	if cfg.SLO.Enabled {
+		th.SLO = &services.SLOObjective{
+			Name:      services.LocationDeliverySLO,
+			Threshold: cfg.SLO.LatencyThreshold,
+			Target:    cfg.SLO.LatencyTarget,
+			Period:    cfg.SLO.BudgetPeriod,
+		}
+	}
+	if cfg.Runtime.Enabled {
+		th.QueueThresholds = cfg.Runtime.QueueThresholds
+		th.QueueSustainFor = cfg.Runtime.SustainFor
+	}
+	if cfg.Degradation.Enabled {
+		th.SpoolMaxBatches = cfg.Degradation.SpoolMaxBatches
+	}
+	th.Backpressure = cfg.Backpressure.Enabled
+	if cfg.Integrity.Enabled {
+		th.IntegrityInterval = cfg.Integrity.Interval
+	}
+	return th
+}
+
+// metricOutcome labels an operation's duration by whether it failed.
+func metricOutcome(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
 }
 
 /*****************************************************************************
  * setupRouter - Configures the Gin router with security, rate limiting, and routes.
  *****************************************************************************/
 
-func setupRouter(locationHandler *handlers.LocationHandler, registry *prometheus.Registry, logger *zap.Logger) *gin.Engine {
+func setupRouter(cfg *config.Config, locationHandler *handlers.LocationHandler, inFlight *handlers.InFlightGuard, limiter *rate.Limiter, registry *prometheus.Registry, metrics *serviceMetrics, logger *zap.Logger) *gin.Engine {
 	// 1. Create a Gin engine in release mode for production readiness.
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
 
-	// 2. Configure panic recovery and logging. A custom zap-based logger can also be used.
-	router.Use(gin.Recovery())
+	// 1a. Give every request an X-Request-ID, the client's or a generated one, returned in the
+	//     response and attached to the request's log lines and service calls.
+	router.Use(handlers.RequestIDMiddleware())
+
+	// 1b. Time every request, outside recovery so recovered panics are observed as 500s.
+	router.Use(buildMetricsMiddleware(metrics))
+
+	// 1c. Answer errors left without a response, including unknown routes, in the shared error format.
+	router.Use(handlers.ErrorMiddleware())
+
+	// 2. Configure panic recovery, capturing a diagnostics bundle for each recovered panic.
+	router.Use(gin.CustomRecovery(locationHandler.Recovery))
 
 	// 3. Optionally configure advanced security headers or TLS in a real deployment.
 
-	// 4. Set up rate limiting with "golang.org/x/time/rate". We'll parse defaultRateLimit as "100/minute".
-	rateLimitMiddleware, err := buildRateLimitMiddleware(defaultRateLimit, logger)
-	if err != nil {
-		// fallback: no rate limit if parse fails (for demonstration)
-		logger.Warn("Failed to parse defaultRateLimit, skipping rate limit middleware", zap.Error(err))
-	} else {
-		router.Use(rateLimitMiddleware)
-	}
+	// 4. Set up rate limiting with "golang.org/x/time/rate", at the configured rate limit, which reloads may change.
+	router.Use(buildRateLimitMiddleware(limiter, logger))
 
 	// 5. Possibly add CORS or other middlewares if necessary. For demonstration, we skip advanced CORS config.
 
-	// 6. Health check endpoint with DB validation (minimal example).
+	// 5a. Decode gzip and deflate request bodies, such as compressed batch uploads, ahead of validation.
+	router.Use(handlers.DecompressionMiddleware())
+
+	// 5b. Enforce the OpenAPI contract on documented routes when a spec is configured.
+	if cfg.API.SpecPath != "" {
+		validator, err := handlers.NewOpenAPIValidator(cfg.API.SpecPath, cfg.API.ValidateResponses, logger)
+		if err != nil {
+			logger.Fatal("Failed to initialize OpenAPI validation", zap.Error(err))
+		}
+		router.Use(validator.Middleware())
+		logger.Info("OpenAPI validation enabled",
+			zap.String("spec", cfg.API.SpecPath),
+			zap.Bool("validateResponses", cfg.API.ValidateResponses),
+		)
+	}
+
+	// 6. Health check endpoint with DB validation (minimal example). A draining
+	//    instance reports 503 so the load balancer stops sending it new sessions,
+	//    as does one whose runtime signals have stayed above their thresholds.
+	affinity := locationHandler.SessionAffinity()
+	monitor := locationHandler.RuntimeMonitor()
 	router.GET("/health", func(c *gin.Context) {
+		if affinity != nil {
+			if draining, _ := affinity.Draining(); draining {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining"})
+				return
+			}
+		}
+		if monitor != nil {
+			health := monitor.Health()
+			if health.Degraded {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"status": "degraded", "runtime": health})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"status": "healthy", "runtime": health})
+			return
+		}
 		c.JSON(http.StatusOK, gin.H{
 			"status": "healthy",
 		})
 	})
 
 	// 7. Configure WebSocket endpoint with compression if desired in the handler itself.
-	router.GET("/ws", locationHandler.HandleLocationStream)
+	//    Streams are guarded against connection floods and auth brute-forcing when enabled.
+	streamMiddleware := []gin.HandlerFunc{}
+	if cfg.Abuse.Enabled {
+		bans, banErr := utils.NewBanStore(&cfg.Stream)
+		if banErr != nil {
+			logger.Fatal("Failed to initialize stream ban store", zap.Error(banErr))
+		}
+		guard, guardErr := handlers.NewStreamGuard(cfg.Abuse, bans, logger, registry)
+		if guardErr != nil {
+			logger.Fatal("Failed to initialize stream abuse protection", zap.Error(guardErr))
+		}
+		streamMiddleware = append(streamMiddleware, guard.Middleware())
+		logger.Info("Stream abuse protection enabled",
+			zap.Int("maxConnsPerIP", cfg.Abuse.MaxConnsPerIP),
+			zap.Int("connRatePerMinute", cfg.Abuse.ConnRatePerMinute),
+			zap.Int("authFailureLimit", cfg.Abuse.AuthFailureLimit),
+			zap.Bool("sharedBans", cfg.Stream.RedisAddr != ""),
+		)
+	}
+	//    Replays of completed walks read from the database and need no affinity.
+	router.GET("/location/replay", append(streamMiddleware, locationHandler.HandleLocationReplay)...)
+	//    Connects are pinned to the instance holding their session when affinity is enabled.
+	if affinity != nil {
+		streamMiddleware = append(streamMiddleware, affinity.Middleware())
+	}
+	router.GET("/ws", append(streamMiddleware, locationHandler.HandleLocationStream)...)
 
 	// 8. Add metrics endpoint with Prometheus.
 	router.GET("/metrics", gin.WrapH(promhttp.HandlerFor(registry, promhttp.HandlerOpts{})))
@@ -372,75 +1037,151 @@ func setupRouter(locationHandler *handlers.LocationHandler, registry *prometheus
 	// 10. Configure error handling middleware or advanced logic (omitted for brevity).
 
 	// 11. Location-related endpoints from the location handler.
-	router.POST("/location", locationHandler.HandleLocationUpdate)
+	router.POST("/sessions", locationHandler.HandleStartSession)
+	//     Location updates are counted for shutdown draining and capped at the in-flight ceiling.
+	router.POST("/location", inFlight.Middleware(), locationHandler.HandleLocationUpdate)
+	router.POST("/location/batch", inFlight.Middleware(), locationHandler.HandleLocationBatch)
 	router.GET("/location/history", locationHandler.HandleGetLocationHistory)
+	router.GET("/location/history/export", locationHandler.HandleExportLocationHistory)
+	router.GET("/walks/:walkID/territory", locationHandler.HandleGetWalkTerritory)
+	router.POST("/walks/:walkID/geofences", locationHandler.HandleCreateWalkGeofence)
+	router.GET("/walks/:walkID/geofences", locationHandler.HandleGetWalkGeofences)
+	router.GET("/walks/:walkID/access-log", locationHandler.HandleGetWalkAccessLog)
+	router.GET("/sessions/:sessionID/events/raw", locationHandler.HandleGetRawSessionEvents)
+	router.GET("/sessions/:sessionID/locations", locationHandler.HandleGetSessionLocations)
+	router.GET("/walkers/presence", locationHandler.HandleGetWalkerPresence)
+	router.GET("/sessions/:sessionID/export.fit", locationHandler.HandleExportSessionFIT)
+	router.GET("/sessions/:sessionID/statistics", locationHandler.HandleGetSessionStatistics)
+	router.GET("/sessions/:sessionID/sparkline", locationHandler.HandleGetSessionSparkline)
+	router.GET("/sessions/:sessionID/breaches", locationHandler.HandleGetSessionBreaches)
+	router.PUT("/owners/:ownerID/fitness/:provider", locationHandler.HandlePutFitnessToken)
+	router.GET("/owners/:ownerID/dogs/:dogID/alert-preferences", locationHandler.HandleGetOwnerAlertPreference)
+	router.PUT("/owners/:ownerID/dogs/:dogID/alert-preferences", locationHandler.HandlePutOwnerAlertPreference)
+	router.DELETE("/owners/:ownerID/dogs/:dogID/alert-preferences", locationHandler.HandleDeleteOwnerAlertPreference)
+	router.POST("/walkers/:walkerID/devices", locationHandler.HandlePairDevice)
+	router.GET("/walkers/:walkerID/devices", locationHandler.HandleGetWalkerDevices)
+	router.DELETE("/walkers/:walkerID/devices/:deviceID", locationHandler.HandleRevokeDevice)
+	router.GET("/analytics/popular-routes", locationHandler.HandleGetPopularRoutes)
+	router.GET("/analytics/demand", locationHandler.HandleGetDemand)
+	router.GET("/analytics/leaderboard", locationHandler.HandleGetLeaderboard)
+	router.GET("/current-walks", locationHandler.HandleGetCurrentWalks)
+	router.GET("/current-walks/changes", locationHandler.HandleGetCurrentWalkChanges)
+	router.POST("/query/locations", locationHandler.HandleQueryLocations)
+	router.POST("/sessions/:sessionID/uploads", locationHandler.HandlePostSequencedUpload)
+	router.POST("/sessions/:sessionID/beacons", locationHandler.HandlePostBeaconEvents)
+	router.GET("/sessions/:sessionID/timeline", locationHandler.HandleGetSessionTimeline)
+	router.GET("/admin/slo", locationHandler.HandleGetSLOStatus)
+	router.GET("/admin/integrity", locationHandler.HandleGetIntegrityReport)
+	router.GET("/admin/config", locationHandler.HandleGetConfigReloadStatus)
+	router.GET("/admin/regions", locationHandler.HandleGetRegionProfiles)
+	router.GET("/admin/regions/:region", locationHandler.HandleGetRegionProfile)
+	router.PUT("/admin/regions/:region", locationHandler.HandlePutRegionProfile)
+	router.DELETE("/admin/regions/:region", locationHandler.HandleDeleteRegionProfile)
+	router.GET("/admin/geofence-groups", locationHandler.HandleGetGeofenceGroups)
+	router.PUT("/admin/geofence-groups/:name", locationHandler.HandlePutGeofenceGroup)
+	router.DELETE("/admin/geofence-groups/:name", locationHandler.HandleDeleteGeofenceGroup)
+	router.PUT("/admin/sessions/:sessionID/batch-policy", locationHandler.HandlePutSessionBatchPolicy)
+	router.POST("/admin/sessions/:sessionID/rebuild", locationHandler.HandleRebuildSession)
+	router.GET("/admin/retention/preview", locationHandler.HandleGetRetentionPreview)
+	router.POST("/sessions/:sessionID/share-links", locationHandler.HandleCreateShareLink)
+	router.PUT("/sessions/:sessionID/subscribers/:subscriberID", locationHandler.HandleShareSession)
+	router.DELETE("/sessions/:sessionID/subscribers/:subscriberID", locationHandler.HandleUnshareSession)
+	router.GET("/sessions/:sessionID/subscribers/:subscriberID/stream-key", locationHandler.HandleGetStreamKey)
+	router.PUT("/admin/sessions/:sessionID/stream-tier", locationHandler.HandlePutStreamTier)
+	router.GET("/admin/quarantine", locationHandler.HandleGetQuarantinedPoints)
+	router.POST("/admin/quarantine/reingest", locationHandler.HandleReingestQuarantined)
+	router.POST("/admin/escrow/sessions/:sessionID/keys", locationHandler.HandleAccessEscrowedKeys)
+	router.GET("/admin/escrow/audit", locationHandler.HandleGetEscrowAccessLog)
 
 	return router
 }
 
 /*****************************************************************************
- * buildRateLimitMiddleware - Constructs a Gin middleware for rate-limiting using time/rate.
+ * newRateLimiter - Creates a limiter allowing the requests of a "<count>/<unit>" rate limit.
  *****************************************************************************/
 
-func buildRateLimitMiddleware(limitSpec string, logger *zap.Logger) (gin.HandlerFunc, error) {
-	// Example input: "100/minute"
-	// Simplistic parse: split by '/'
-	parts := []rune(limitSpec)
-	var numericPart, unitPart string
-	reached := false
-	for _, r := range parts {
-		if r == '/' {
-			reached = true
-			continue
-		}
-		if !reached {
-			numericPart += string(r)
-		} else {
-			unitPart += string(r)
-		}
-	}
-	num, err := strconv.Atoi(numericPart)
-	if err != nil {
-		return nil, fmt.Errorf("invalid numeric part in rate limit: %w", err)
+func newRateLimiter(limitSpec string) (*rate.Limiter, error) {
+	limiter := rate.NewLimiter(0, 0)
+	if err := setRateLimit(limiter, limitSpec); err != nil {
+		return nil, err
 	}
+	return limiter, nil
+}
+
+/*****************************************************************************
+ * setRateLimit - Applies a "<count>/<unit>" rate limit, such as "100/minute", to limiter.
+ *****************************************************************************/
 
-	var duration time.Duration
-	switch unitPart {
-	case "s", "sec", "second":
-		duration = time.Second
-	case "m", "min", "minute":
-		duration = time.Minute
-	case "h", "hour":
-		duration = time.Hour
-	default:
-		return nil, fmt.Errorf("unsupported rate limit unit: %s", unitPart)
+func setRateLimit(limiter *rate.Limiter, limitSpec string) error {
+	num, per, err := config.ParseRateLimit(limitSpec)
+	if err != nil {
+		return err
 	}
+	limiter.SetLimit(rate.Every(per / time.Duration(num)))
+	limiter.SetBurst(num)
+	return nil
+}
 
-	every := duration / time.Duration(num)
-	limiter := rate.NewLimiter(rate.Every(every), num)
+/*****************************************************************************
+ * buildRateLimitMiddleware - Constructs a Gin middleware for rate-limiting using time/rate.
+ *****************************************************************************/
 
+func buildRateLimitMiddleware(limiter *rate.Limiter, logger *zap.Logger) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if !limiter.Allow() {
-			logger.Warn("Rate limit exceeded",
+			handlers.RequestLogger(c, logger).Warn("Rate limit exceeded",
 				zap.String("path", c.Request.URL.Path),
 				zap.String("ip", c.ClientIP()),
 			)
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error": "rate limit exceeded",
-			})
-			c.Abort()
+			handlers.AbortWithError(c, handlers.NewAPIError(http.StatusTooManyRequests, "", "rate limit exceeded"))
 			return
 		}
 		c.Next()
-	}, nil
+	}
+}
+
+/*****************************************************************************
+ * buildMetricsMiddleware - Constructs a Gin middleware observing request durations.
+ * Requests are labeled with their route pattern rather than their path, so IDs
+ * in paths do not multiply the series; unmatched requests share one label.
+ *****************************************************************************/
+
+func buildMetricsMiddleware(metrics *serviceMetrics) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.httpRequestDuration.
+			WithLabelValues(route, c.Request.Method, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	}
 }
 
 /*****************************************************************************
  * gracefulShutdown - Manages a graceful server shutdown with a specified timeout.
+ * In-flight location updates are given time to complete and live location
+ * streams are drained first, as http.Server.Shutdown does not track hijacked
+ * WebSocket connections, and buffered location writes are flushed before the
+ * database is closed.
  *****************************************************************************/
 
-func gracefulShutdown(server *http.Server, trackingService *services.TrackingService, logger *zap.Logger) {
+func gracefulShutdown(server *http.Server, trackingService *services.TrackingService, locationHandler *handlers.LocationHandler, inFlight *handlers.InFlightGuard, cfg *config.Config, logger *zap.Logger) {
 	logger.Info("Initiating graceful shutdown...")
+
+	// Refuse new location updates and let those in flight complete.
+	if pending := inFlight.Drain(cfg.InFlight.DrainTimeout); pending > 0 {
+		logger.Warn("Location updates still in flight after drain timeout", zap.Int("requests", pending))
+	}
+
+	// Ask live streams to reconnect elsewhere, handling their in-flight messages.
+	if closed := locationHandler.DrainStreams(cfg.Stream.DrainTimeout); closed > 0 {
+		logger.Warn("Closed location streams that did not finish draining", zap.Int("streams", closed))
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), defaultGracefulTimeout)
 	defer cancel()
 
@@ -449,15 +1190,23 @@ func gracefulShutdown(server *http.Server, trackingService *services.TrackingSer
 		logger.Error("HTTP server shutdown encountered an error", zap.Error(err))
 	}
 
+	// Write the locations still buffered by write coalescing.
+	if err := trackingService.FlushLocationWrites(); err != nil {
+		logger.Error("Failed to flush buffered locations", zap.Error(err))
+	}
+
 	// Perform tracking service cleanup, close DB and MQTT connections if needed.
 	if db, ok := trackingService.DBConn.(services.TimescaleDB); ok {
 		if err := db.Close(); err != nil {
 			logger.Warn("Failed to close TimescaleDB connection", zap.Error(err))
 		}
 	}
-	if mq, ok := trackingService.MQTTConn.(services.MQTTClient); ok {
-		// No direct close for paho but we can forcibly disconnect if we want:
-		_ = mq // placeholder if needed
+	if mq, ok := trackingService.MQTTConn.(*pahoMqttClient); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := mq.Disconnect(ctx); err != nil {
+			logger.Warn("Failed to disconnect from the MQTT broker", zap.Error(err))
+		}
+		cancel()
 	}
 
 	// Flush log buffers if necessary
@@ -466,13 +1215,55 @@ func gracefulShutdown(server *http.Server, trackingService *services.TrackingSer
 	logger.Info("Graceful shutdown completed")
 }
 
+/*****************************************************************************
+ * writeEscrowShares - Writes each custodian's escrow share to its own file,
+ * dir/<custodian>.share, readable by the owner only, so each can be handed to
+ * its custodian separately. Existing files are never overwritten; if any share
+ * cannot be written, the ones already written are removed.
+ *****************************************************************************/
+
+func writeEscrowShares(dir string, shares []models.EscrowShare) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create share directory: %w", err)
+	}
+	paths := make([]string, 0, len(shares))
+	removeWritten := func() {
+		for _, path := range paths {
+			_ = os.Remove(path)
+		}
+	}
+	for _, share := range shares {
+		if share.Custodian != filepath.Base(share.Custodian) || strings.HasPrefix(share.Custodian, ".") {
+			removeWritten()
+			return nil, fmt.Errorf("custodian %q cannot be used as a file name", share.Custodian)
+		}
+		path := filepath.Join(dir, share.Custodian+".share")
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			removeWritten()
+			return nil, fmt.Errorf("failed to create share file: %w", err)
+		}
+		paths = append(paths, path)
+		_, err = f.WriteString(share.Share + "\n")
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			removeWritten()
+			return nil, fmt.Errorf("failed to write share file %s: %w", path, err)
+		}
+	}
+	return paths, nil
+}
+
 /*****************************************************************************
  * main - Entry point function that initializes and runs the tracking service.
  *****************************************************************************/
 
 func main() {
-	// 1. Initialize structured logging with zap.
-	logger, err := zap.NewProduction()
+	// 1. Initialize structured logging with zap, at a level adjustable at runtime.
+	logConfig := zap.NewProductionConfig()
+	logger, err := logConfig.Build()
 	if err != nil {
 		panic(fmt.Sprintf("Failed to initialize logger: %v", err))
 	}
@@ -480,40 +1271,776 @@ func main() {
 
 	logger.Info("Starting Tracking Service...")
 
-	// 2. Load and validate service configuration.
-	cfg, err := config.LoadConfig()
+	// 2. Load and validate service configuration. Environment variables take
+	//    precedence over the config file, which takes precedence over defaults.
+	configPath := flag.String("config", "", "path to a YAML or JSON config file (default $CONFIG_FILE)")
+	validateOnly := flag.Bool("validate-config", false, "validate the configuration and exit")
+	alertRules := flag.Bool("alert-rules", false, "print the recommended Prometheus alerting rules for the configuration and exit")
+	escrowKeygen := flag.String("escrow-keygen", "", "comma-separated custodians to split a new key escrow key among; writes the shares to -escrow-share-dir, prints the public key and exits")
+	escrowShareDir := flag.String("escrow-share-dir", "", "directory to write each custodian's escrow share to, as <custodian>.share")
+	flag.Parse()
+	if *escrowKeygen != "" {
+		if *escrowShareDir == "" {
+			logger.Fatal("-escrow-keygen requires -escrow-share-dir, so no single output holds every share")
+		}
+		publicKey, shares, keygenErr := services.GenerateEscrowKey(strings.Split(*escrowKeygen, ","))
+		if keygenErr != nil {
+			logger.Fatal("Failed to generate key escrow key", zap.Error(keygenErr))
+		}
+		paths, writeErr := writeEscrowShares(*escrowShareDir, shares)
+		if writeErr != nil {
+			logger.Fatal("Failed to write key escrow shares", zap.Error(writeErr))
+		}
+		fmt.Printf("KEY_ESCROW_PUBLIC_KEY=%s\n", publicKey)
+		for i, share := range shares {
+			fmt.Printf("share for %s written to %s\n", share.Custodian, paths[i])
+		}
+		return
+	}
+	if *configPath == "" {
+		*configPath = os.Getenv("CONFIG_FILE")
+	}
+	cfg, err := config.LoadConfigFile(*configPath)
 	if err != nil {
-		logger.Fatal("Failed to load configuration", zap.Error(err))
+		logger.Fatal("Failed to load configuration", zap.String("configFile", *configPath), zap.Error(err))
+	}
+	if *validateOnly {
+		logger.Info("Configuration is valid", zap.String("configFile", *configPath))
+		return
+	}
+	if *alertRules {
+		if _, err := os.Stdout.Write(services.FormatAlertRules(services.RecommendedAlertRules(alertThresholds(cfg)))); err != nil {
+			logger.Fatal("Failed to write alerting rules", zap.Error(err))
+		}
+		return
 	}
 
+	// 2a. Set up feature flags and let the log-level flag drive the logger.
+	flagRollouts := make(map[string]services.FlagRollout, len(cfg.Flags.Rollouts))
+	for name, rollout := range cfg.Flags.Rollouts {
+		flagRollouts[name] = services.FlagRollout{Percentage: rollout.Percentage, By: rollout.By}
+	}
+	featureFlags := services.NewStaticFeatureFlags(flagRollouts, map[string]string{
+		services.FlagLogLevel: cfg.Flags.LogLevel,
+	})
+	logLevelWatcher := services.NewLogLevelWatcher(featureFlags, logConfig.Level, cfg.Flags.RefreshInterval, logger)
+	logLevelWatcher.Start()
+	defer logLevelWatcher.Stop()
+
+	// 2b. Select the ID format for new locations and sessions, and the formats accepted on ingestion.
+	idGenerator, err := models.NewIDGenerator(cfg.IDs.Format, cfg.IDs.SnowflakeNodeID)
+	if err != nil {
+		logger.Fatal("Failed to initialize ID generator", zap.Error(err))
+	}
+	models.SetIDGenerator(idGenerator)
+	if err := models.SetAcceptedIDFormats(cfg.IDs.AcceptedFormats); err != nil {
+		logger.Fatal("Failed to configure accepted ID formats", zap.Error(err))
+	}
+	logger.Info("ID generation configured",
+		zap.String("format", idGenerator.Format()),
+		zap.Strings("acceptedFormats", cfg.IDs.AcceptedFormats),
+	)
+
 	// 3. Set up Prometheus metrics collectors.
-	registry := setupMetrics()
+	registry, metrics := setupMetrics()
 
-	// 4. Initialize MQTT client with QoS and retry policies.
-	mqttClient, err := newMQTTClient(cfg, logger)
+	// 4. Initialize MQTT client with QoS, and the publish circuit breaker with its fallback queue.
+	mqttClient, err := newMQTTClient(cfg, metrics, registry, logger)
 	if err != nil {
 		logger.Fatal("Failed to initialize MQTT client", zap.Error(err))
 	}
 
-	// 5. Configure TimescaleDB connection pool with circuit breaker.
-	dbConn, err := newTimescaleDB(cfg, logger)
+	// 4a. Capture diagnostics bundles for panics recovered while handling requests, streams and MQTT messages.
+	diagnostics, err := utils.NewDiagnosticsRecorder(cfg.Diagnostics, registry)
+	if err != nil {
+		logger.Fatal("Failed to initialize diagnostics recorder", zap.Error(err))
+	}
+	if pmc, ok := mqttClient.(*pahoMqttClient); ok {
+		pmc.dispatcher.SetDiagnostics(diagnostics)
+	}
+
+	// 5. Configure TimescaleDB connection pool with circuit breaker, routed to
+	//    the same-zone database endpoint in multi-AZ deployments.
+	dbRouter, err := newEndpointRouter(cfg.Database, logger, registry)
+	if err != nil {
+		logger.Fatal("Failed to initialize database endpoint routing", zap.Error(err))
+	}
+	if dbRouter != nil {
+		defer dbRouter.Stop()
+		logger.Info("Database endpoint routing enabled",
+			zap.String("zone", cfg.Database.Zone),
+			zap.String("preferred", dbRouter.Preferred().Address()),
+			zap.Duration("healthInterval", cfg.Database.EndpointHealthInterval),
+		)
+	}
+	dbConn, err := newTimescaleDB(cfg, dbRouter, metrics, logger)
 	if err != nil {
 		logger.Fatal("Failed to initialize TimescaleDB connection", zap.Error(err))
 	}
 
+	// 5a. Export pool statistics, alert on slow acquisitions and optionally auto-tune the pool.
+	if poolCtl, isPool := dbConn.(utils.PoolController); isPool {
+		poolMonitor := utils.NewPoolMonitor(poolCtl, cfg.Database, registry)
+		poolMonitor.Start()
+		defer poolMonitor.Stop()
+		logger.Info("TimescaleDB pool monitoring enabled",
+			zap.Duration("interval", cfg.Database.PoolStatsInterval),
+			zap.Duration("acquireWaitThreshold", cfg.Database.AcquireWaitThreshold),
+			zap.Bool("autoTune", cfg.Database.PoolAutoTune),
+		)
+	}
+
+	// The uncoalesced connection, into which spooled batches are drained.
+	directDB := dbConn
+
+	// 5b. Coalesce per-session location writes into adaptive batches if enabled.
+	if cfg.Batching.Enabled {
+		coalescer, batchErr := services.NewCoalescingWriter(dbConn, services.BatchPolicy{
+			MaxPoints: cfg.Batching.MaxPoints,
+			MinWindow: cfg.Batching.MinWindow,
+			MaxWindow: cfg.Batching.MaxWindow,
+		}, logger, registry)
+		if batchErr != nil {
+			logger.Fatal("Failed to initialize location write coalescing", zap.Error(batchErr))
+		}
+		dbConn = coalescer
+		logger.Info("Location write coalescing enabled",
+			zap.Int("maxPoints", cfg.Batching.MaxPoints),
+			zap.Duration("minWindow", cfg.Batching.MinWindow),
+			zap.Duration("maxWindow", cfg.Batching.MaxWindow),
+		)
+	}
+
 	// 6. Create tracking service instance with dependencies.
-	trackingService := services.NewTrackingService(mqttClient, dbConn, nil) // config param can be extended if needed
+	trackingService := services.NewTrackingService(mqttClient, dbConn, &services.Config{
+		LocationHistoryMode: cfg.Service.LocationHistoryMode,
+		LocationHistorySize: cfg.Service.MaxLocationHistory,
+		LocationExpiry:      cfg.MQTT.LocationExpiry,
+	})
+
+	// 6a. Enable multi-region replication of session events if configured.
+	if cfg.Replication.Enabled {
+		bus, isBus := mqttClient.(services.MessageBus)
+		if !isBus {
+			logger.Fatal("MQTT client does not support subscriptions required for replication")
+		}
+		replicator, repErr := services.NewEventReplicator(cfg.Replication.Region, cfg.Replication.PeerRegions, bus, logger, registry)
+		if repErr != nil {
+			logger.Fatal("Failed to initialize session event replicator", zap.Error(repErr))
+		}
+		if repErr = replicator.Start(); repErr != nil {
+			logger.Fatal("Failed to subscribe to replication subject", zap.Error(repErr))
+		}
+		trackingService.SetEventReplicator(replicator)
+		logger.Info("Session event replication enabled",
+			zap.String("region", cfg.Replication.Region),
+			zap.Strings("peerRegions", cfg.Replication.PeerRegions),
+		)
+	}
+
+	// 6b. Persist walk territory coverage and serve point-in-time statistics through the TimescaleDB repository.
+	var repo repository.Store
+	repo, err = newTimescaleRepository(cfg.Database, dbRouter, logger)
+	if err != nil {
+		logger.Fatal("Failed to initialize TimescaleDB repository", zap.Error(err))
+	}
+
+	//     During a storage migration, mirror repository writes to the shadow
+	//     database and compare a sample of reads against it.
+	if cfg.Migration.DualWriteEnabled {
+		shadowRepo, shadowErr := newTimescaleRepository(cfg.Migration.Shadow, nil, logger)
+		if shadowErr != nil {
+			logger.Fatal("Failed to initialize shadow repository for dual-write", zap.Error(shadowErr))
+		}
+		repo, err = repository.NewDualWriteRepository(repo, shadowRepo, cfg.Migration.ReadSampleRate, logger, registry)
+		if err != nil {
+			logger.Fatal("Failed to initialize dual-write repository", zap.Error(err))
+		}
+		logger.Info("Repository dual-write enabled",
+			zap.String("shadowHost", cfg.Migration.Shadow.Host),
+			zap.String("shadowSchema", cfg.Migration.Shadow.Schema),
+			zap.Float64("readSampleRate", cfg.Migration.ReadSampleRate),
+		)
+	}
+	defer repo.Close()
+	trackingService.SetTerritoryStore(repo)
+	trackingService.SetStatisticsHistoryStore(repo)
+	trackingService.SetSparklineStore(repo)
+	trackingService.SetLocationQueryStore(repo)
+	trackingService.SetGeofenceStore(repo)
+	trackingService.SetGeofenceGroupStore(repo)
+	if groups, groupErr := trackingService.LoadGeofenceGroups(); groupErr != nil {
+		logger.Warn("Failed to load geofence groups", zap.Int("groups", groups), zap.Error(groupErr))
+	} else {
+		logger.Info("Geofence groups loaded", zap.Int("groups", groups))
+	}
+	trackingService.SetReplayStore(repo)
+	trackingService.SetHistoryExportStore(repo)
+	trackingService.SetHistoryPageStore(repo)
+
+	// 6c. Record session state transitions as an append-only event stream if enabled.
+	if cfg.Service.EventSourcingEnabled {
+		trackingService.SetSessionEventStore(repo)
+		logger.Info("Session event sourcing enabled")
+	}
+
+	// 6d. Upload completed walks to owners' connected fitness platforms if enabled.
+	if cfg.Fitness.AutoUploadEnabled {
+		trackingService.SetFitnessIntegration(repo, cfg.Fitness.UploadTimeout,
+			services.NewStravaUploader(cfg.Fitness.StravaClientID, cfg.Fitness.StravaClientSecret, cfg.Fitness.UploadTimeout),
+		)
+		logger.Info("Fitness platform auto-upload enabled")
+	}
+
+	// 6e. Track walker presence heartbeats independently of sessions if enabled.
+	if cfg.Presence.Enabled {
+		bus, isBus := mqttClient.(services.MessageBus)
+		if !isBus {
+			logger.Fatal("MQTT client does not support subscriptions required for presence tracking")
+		}
+		presence, presErr := services.NewPresenceRegistry(bus, cfg.Presence.Timeout, logger, registry)
+		if presErr != nil {
+			logger.Fatal("Failed to initialize walker presence registry", zap.Error(presErr))
+		}
+		if presErr = presence.Start(cfg.Presence.SweepInterval); presErr != nil {
+			logger.Fatal("Failed to start walker presence tracking", zap.Error(presErr))
+		}
+		defer presence.Stop()
+		trackingService.SetPresenceRegistry(presence)
+		logger.Info("Walker presence tracking enabled", zap.Duration("timeout", cfg.Presence.Timeout))
+	}
+
+	// 6f. Aggregate completed walks into the route popularity layer and, by the cell and hour
+	//     they started in, into walk start demand, each if enabled.
+	if cfg.Analytics.RoutePopularityEnabled {
+		trackingService.SetRouteStore(repo, cfg.Analytics.RouteMinWalks)
+		logger.Info("Route popularity aggregation enabled", zap.Int("minWalks", cfg.Analytics.RouteMinWalks))
+	}
+	if cfg.Analytics.DemandEnabled {
+		trackingService.SetDemandStore(repo, cfg.Analytics.DemandMinStarts)
+		logger.Info("Walk start demand aggregation enabled", zap.Int("minStarts", cfg.Analytics.DemandMinStarts))
+	}
+
+	// 6g. Accept sequenced device uploads over MQTT, acking on each session's ack topic.
+	if bus, isBus := mqttClient.(services.MessageBus); isBus {
+		if err := trackingService.StartUploadIngress(bus); err != nil {
+			logger.Fatal("Failed to subscribe to device uploads", zap.Error(err))
+		}
+		logger.Info("MQTT device upload ingress enabled",
+			zap.Strings("topics", []string{services.UploadSubscription, services.UploadProtobufSubscription}),
+		)
+	} else {
+		logger.Warn("MQTT client does not support subscriptions; device uploads accepted over HTTP and WebSocket only")
+	}
+
+	// 6h. Measure location delivery latency against its SLO if enabled.
+	if cfg.SLO.Enabled {
+		sloTracker, sloErr := services.NewSLOTracker(services.SLOObjective{
+			Name:      services.LocationDeliverySLO,
+			Threshold: cfg.SLO.LatencyThreshold,
+			Target:    cfg.SLO.LatencyTarget,
+			Period:    cfg.SLO.BudgetPeriod,
+		}, registry)
+		if sloErr != nil {
+			logger.Fatal("Failed to initialize SLO tracking", zap.Error(sloErr))
+		}
+		trackingService.SetSLOTracker(sloTracker)
+		logger.Info("Location delivery SLO tracking enabled",
+			zap.Duration("threshold", cfg.SLO.LatencyThreshold),
+			zap.Float64("target", cfg.SLO.LatencyTarget),
+		)
+	}
+
+	// 6i. Reject late location messages for ended sessions at ingress.
+	completedFilter, err := services.NewCompletedSessionFilter(cfg.Service.CompletedSessionRetention, registry)
+	if err != nil {
+		logger.Fatal("Failed to initialize completed session filter", zap.Error(err))
+	}
+	trackingService.SetCompletedSessionFilter(completedFilter)
+
+	// 6j. Resolve start and end addresses of completed walks into their replicated summaries if enabled.
+	if cfg.Geocoding.Provider != "" {
+		geocoder, geoErr := services.NewReverseGeocoder(services.GeocoderOptions{
+			Provider:  cfg.Geocoding.Provider,
+			APIKey:    cfg.Geocoding.APIKey,
+			BaseURL:   cfg.Geocoding.BaseURL,
+			UserAgent: cfg.Geocoding.UserAgent,
+			Timeout:   cfg.Geocoding.Timeout,
+		})
+		if geoErr != nil {
+			logger.Fatal("Failed to initialize reverse geocoding", zap.Error(geoErr))
+		}
+		cached, geoErr := services.NewCachingGeocoder(geocoder, cfg.Geocoding.CacheSize, cfg.Geocoding.CacheTTL, cfg.Geocoding.RateLimit, registry)
+		if geoErr != nil {
+			logger.Fatal("Failed to initialize reverse geocoding cache", zap.Error(geoErr))
+		}
+		trackingService.SetReverseGeocoder(cached, cfg.Geocoding.Timeout)
+		logger.Info("Walk summary geocoding enabled", zap.String("provider", cfg.Geocoding.Provider))
+		if !cfg.Replication.Enabled {
+			logger.Warn("Walk summaries are only recorded with replication enabled; geocoded addresses will not be stored")
+		}
+	}
+
+	// 6k. Gate pipeline stages under gradual rollout on feature flags.
+	trackingService.SetFeatureFlags(featureFlags)
+	if len(cfg.Flags.Rollouts) > 0 {
+		logger.Info("Feature flag rollouts configured", zap.Int("flags", len(cfg.Flags.Rollouts)))
+	}
+
+	// 6l. Export data quality of ingested points by location provider.
+	trackingService.SetLocationQualityMetrics(services.NewLocationQualityMetrics(registry))
+
+	// 6m. Encrypt the live streams of sessions shared with sensitive subscribers if enabled.
+	if cfg.Stream.EncryptionEnabled {
+		trackingService.SetStreamKeyring(services.NewStreamKeyring(registry))
+		logger.Info("Per-session stream encryption enabled")
+	}
 
 	// For demonstration, set references so we can perform cleanup in gracefulShutdown.
 	// We do this by embedding references into the trackingService struct if desired:
 	trackingService.DBConn = dbConn
 	trackingService.MQTTConn = mqttClient
 
+	// 6n. Degrade gracefully while the database breaker is open: spool refused batches to disk.
+	var spool *services.LocationSpool
+	if cfg.Degradation.Enabled {
+		var spoolErr error
+		spool, spoolErr = services.NewLocationSpool(cfg.Degradation.SpoolDir, cfg.Degradation.SpoolMaxBatches, logger, registry)
+		if spoolErr != nil {
+			logger.Fatal("Failed to open location spool", zap.Error(spoolErr))
+		}
+		spool.Start(directDB, cfg.Degradation.DrainInterval)
+		defer spool.Stop()
+		trackingService.SetLocationSpool(spool)
+		logger.Info("Graceful degradation enabled",
+			zap.String("spoolDir", cfg.Degradation.SpoolDir),
+			zap.Int("spooledBatches", spool.Len()),
+			zap.Duration("drainInterval", cfg.Degradation.DrainInterval),
+		)
+	}
+
+	// 6o. Record completed walks for the tenant walker leaderboard if enabled.
+	if cfg.Analytics.LeaderboardEnabled {
+		trackingService.SetLeaderboardStore(repo)
+		logger.Info("Walker leaderboard enabled")
+	}
+
+	// 6p. Ingest beacon proximity events for indoor presence if enabled.
+	if cfg.Beacons.Enabled {
+		trackingService.SetBeaconStore(repo, cfg.Beacons.MinRSSI)
+		logger.Info("Beacon ingestion enabled", zap.Int("minRSSI", cfg.Beacons.MinRSSI))
+	}
+
+	// 6q. Keep the current walks view and its change feed in step with session transitions if enabled.
+	if cfg.Service.CurrentWalksEnabled {
+		trackingService.SetCurrentWalksStore(repo)
+		logger.Info("Current walks view enabled", zap.String("notifyChannel", repository.CurrentWalksNotifyChannel))
+	}
+
+	// 6r. Smooth GPS fixes with a per-session Kalman filter for sessions the kalman-filter flag is rolled out to.
+	if cfg.Smoothing.Enabled {
+		trackingService.SetLocationSmoother(utils.NewLocationSmoother(cfg.Smoothing, registry))
+		logger.Info("GPS smoothing enabled",
+			zap.Float64("processNoise", cfg.Smoothing.ProcessNoise),
+			zap.Duration("resetAfter", cfg.Smoothing.ResetAfter),
+		)
+	}
+
+	// 6s. Resolve sessions' settings from regional configuration profiles and enforce regional retention if enabled.
+	if cfg.Regions.Enabled {
+		trackingService.SetRegionProfiles(repo, models.SessionSettings{
+			MaxSpeedKmh:      cfg.Regions.MaxSpeedKmh,
+			GeofenceRadiusKm: cfg.Regions.GeofenceRadiusKm,
+		})
+		retention := services.NewRegionRetention(trackingService, cfg.Regions.RetentionDryRun)
+		retention.Start(cfg.Regions.RetentionInterval)
+		defer retention.Stop()
+		logger.Info("Region profiles enabled",
+			zap.Float64("baseMaxSpeedKmh", cfg.Regions.MaxSpeedKmh),
+			zap.Float64("baseGeofenceRadiusKm", cfg.Regions.GeofenceRadiusKm),
+			zap.Duration("retentionInterval", cfg.Regions.RetentionInterval),
+			zap.Bool("retentionDryRun", cfg.Regions.RetentionDryRun),
+		)
+	}
+
+	//     Preview what location retention would compress, delete and drop.
+	trackingService.SetRetentionStore(repo)
+
+	// 6t. Measure recomputed statistics on the WGS84 ellipsoid if configured.
+	if cfg.Service.SummaryDistance == "geodesic" {
+		trackingService.SetSummaryDistance(utils.WGS84)
+		logger.Info("Geodesic summary distances enabled")
+	}
+
+	// 6u. Export per-tenant time to first point and stream first frame latencies.
+	trackingService.SetSessionLifecycleMetrics(services.NewSessionLifecycleMetrics(registry, cfg.Metrics.MaxLabelValues))
+
+	// 6v. Notify owners when walks of their dogs cross the alert radius they set, if enabled.
+	if cfg.OwnerAlerts.Enabled {
+		trackingService.SetOwnerAlertStore(repo)
+		logger.Info("Owner boundary alerts enabled")
+	}
+
+	// 6w. Pause low-priority MQTT topics while internal queues are saturated, if enabled.
+	if cfg.Backpressure.Enabled {
+		pmc, isPaho := mqttClient.(*pahoMqttClient)
+		if !isPaho {
+			logger.Fatal("MQTT client does not support pausing subscriptions required for backpressure")
+		}
+		backpressure, bpErr := services.NewBackpressureController(pmc, cfg.Backpressure.LowPriorityTopics,
+			cfg.Backpressure.HighWatermark, cfg.Backpressure.LowWatermark, logger, registry)
+		if bpErr != nil {
+			logger.Fatal("Failed to initialize backpressure control", zap.Error(bpErr))
+		}
+		backpressure.AddQueue("mqtt_inflight", pmc.InFlight, cfg.Backpressure.MaxInFlight)
+		if coalescer, isCoalescing := dbConn.(*services.CoalescingWriter); isCoalescing {
+			backpressure.AddQueue("coalesced_points", coalescer.BufferedPoints, cfg.Backpressure.MaxBufferedPoints)
+		}
+		if spool != nil {
+			backpressure.AddQueue("spool", spool.Len, cfg.Degradation.SpoolMaxBatches)
+		}
+		backpressure.Start(cfg.Backpressure.CheckInterval)
+		defer backpressure.Stop()
+		logger.Info("MQTT backpressure enabled",
+			zap.Strings("lowPriorityTopics", cfg.Backpressure.LowPriorityTopics),
+			zap.Float64("highWatermark", cfg.Backpressure.HighWatermark),
+			zap.Float64("lowWatermark", cfg.Backpressure.LowWatermark),
+		)
+	}
+
+	// 6x. Cross-check recently completed walks against their stored data on a schedule, if enabled.
+	if cfg.Integrity.Enabled {
+		integrity, intErr := services.NewIntegrityChecker(trackingService, repo, cfg.Integrity.Lookback,
+			cfg.Integrity.SampleSize, cfg.Integrity.DistanceTolerance, logger, registry)
+		if intErr != nil {
+			logger.Fatal("Failed to initialize data integrity checks", zap.Error(intErr))
+		}
+		trackingService.SetIntegrityChecker(integrity)
+		integrity.Start(cfg.Integrity.Interval)
+		defer integrity.Stop()
+		logger.Info("Data integrity checks enabled",
+			zap.Duration("interval", cfg.Integrity.Interval),
+			zap.Duration("lookback", cfg.Integrity.Lookback),
+			zap.Int("sampleSize", cfg.Integrity.SampleSize),
+		)
+	}
+
+	// 6y. Register walkers' paired devices and check the devices sessions start from, if enabled.
+	if cfg.Devices.Enabled {
+		trackingService.SetDeviceRegistry(repo, []byte(cfg.Devices.AttestationSecret), services.DevicePolicy{
+			Default: cfg.Devices.DefaultPolicy,
+			Tenants: cfg.Devices.TenantPolicies,
+		})
+		logger.Info("Device pairing enabled",
+			zap.String("defaultPolicy", cfg.Devices.DefaultPolicy),
+			zap.Int("tenantPolicies", len(cfg.Devices.TenantPolicies)),
+		)
+	}
+
+	// 6z. Complete abandoned and timed-out sessions in the background, if enabled.
+	if cfg.Reaper.Enabled {
+		reaper, reapErr := services.NewSessionReaper(trackingService, services.MaxInactiveTime,
+			cfg.Service.SessionTimeout, logger, registry)
+		if reapErr != nil {
+			logger.Fatal("Failed to initialize the session reaper", zap.Error(reapErr))
+		}
+		reaper.Start(cfg.Reaper.Interval)
+		defer reaper.Stop()
+		logger.Info("Session reaper enabled",
+			zap.Duration("interval", cfg.Reaper.Interval),
+			zap.Duration("maxInactive", services.MaxInactiveTime),
+			zap.Duration("sessionTimeout", cfg.Service.SessionTimeout),
+		)
+	}
+
+	// 6aa. Skip points of retried uploads whose IDs were recently stored, if enabled.
+	if cfg.Dedup.Enabled {
+		recentLocations, dedupErr := services.NewRecentLocationFilter(cfg.Dedup.Capacity, cfg.Dedup.FalsePositiveRate, registry)
+		if dedupErr != nil {
+			logger.Fatal("Failed to initialize location dedup", zap.Error(dedupErr))
+		}
+		trackingService.SetRecentLocationFilter(recentLocations)
+		logger.Info("Location dedup enabled",
+			zap.Int("capacity", cfg.Dedup.Capacity),
+			zap.Float64("falsePositiveRate", cfg.Dedup.FalsePositiveRate),
+		)
+	}
+
+	// 6ab. Quarantine points rejected at ingestion for review and reingestion, if enabled.
+	if cfg.Quarantine.Enabled {
+		trackingService.SetLocationQuarantine(services.NewLocationQuarantine(repo, registry))
+		logger.Info("Location quarantine enabled")
+	}
+
+	// 6ac. Escrow session stream keys for audited access under legal requests, if enabled.
+	if cfg.Escrow.Enabled {
+		keyEscrow, escrowErr := services.NewStreamKeyEscrow(cfg.Escrow.PublicKey, cfg.Escrow.Custodians, repo, registry)
+		if escrowErr != nil {
+			logger.Fatal("Failed to initialize key escrow", zap.Error(escrowErr))
+		}
+		trackingService.SetKeyEscrow(keyEscrow)
+		logger.Info("Stream key escrow enabled", zap.Strings("custodians", cfg.Escrow.Custodians))
+	}
+
+	// 6ad. Enrich session-start stream frames and walk summaries with dog profiles, if enabled.
+	if cfg.DogProfiles.PetsServiceURL != "" {
+		pets, petsErr := services.NewHTTPPetsClient(cfg.DogProfiles.PetsServiceURL, cfg.DogProfiles.Token, cfg.DogProfiles.Timeout)
+		if petsErr != nil {
+			logger.Fatal("Failed to initialize pets service client", zap.Error(petsErr))
+		}
+		cached, petsErr := services.NewDogProfileCache(pets, cfg.DogProfiles.CacheSize, cfg.DogProfiles.CacheTTL, registry)
+		if petsErr != nil {
+			logger.Fatal("Failed to initialize dog profile cache", zap.Error(petsErr))
+		}
+		trackingService.SetDogProfiles(cached, cfg.DogProfiles.Timeout)
+		logger.Info("Dog profile enrichment enabled", zap.String("petsService", cfg.DogProfiles.PetsServiceURL))
+	}
+
+	// 6ae. Snap fixes to the road and path network before they count towards distance, if enabled.
+	if cfg.MapMatching.Provider != "" {
+		matcher, matchErr := services.NewMapMatcher(services.MapMatcherOptions{
+			Provider: cfg.MapMatching.Provider,
+			BaseURL:  cfg.MapMatching.URL,
+			Profile:  cfg.MapMatching.Profile,
+			Timeout:  cfg.MapMatching.Timeout,
+		})
+		if matchErr != nil {
+			logger.Fatal("Failed to initialize map matching", zap.Error(matchErr))
+		}
+		stage := services.NewMapMatchingStage(matcher, cfg.MapMatching.Timeout, cfg.MapMatching.ContextPoints, cfg.MapMatching.MaxSnapMeters, registry)
+		if matchErr = trackingService.SetMapMatching(stage); matchErr != nil {
+			logger.Fatal("Failed to register map matching stage", zap.Error(matchErr))
+		}
+		logger.Info("Map matching enabled",
+			zap.String("provider", cfg.MapMatching.Provider),
+			zap.Float64("maxSnapMeters", cfg.MapMatching.MaxSnapMeters),
+		)
+	}
+
+	// 6af. Predict each walk's duration and distance at session start, if enabled.
+	if cfg.Prediction.Mode != "" {
+		var predictor services.WalkPredictor = services.HeuristicWalkPredictor{}
+		if cfg.Prediction.Mode == "endpoint" {
+			endpoint, predErr := services.NewHTTPWalkPredictor(cfg.Prediction.URL, cfg.Prediction.Timeout)
+			if predErr != nil {
+				logger.Fatal("Failed to initialize walk prediction", zap.Error(predErr))
+			}
+			predictor = endpoint
+		}
+		hook, predErr := services.NewWalkPredictionHook(predictor, repo, cfg.Prediction.HistoryWalks, cfg.Prediction.HistoryWindow, cfg.Prediction.Timeout, registry)
+		if predErr != nil {
+			logger.Fatal("Failed to initialize walk prediction", zap.Error(predErr))
+		}
+		trackingService.SetWalkPrediction(hook)
+		logger.Info("Walk prediction enabled", zap.String("mode", cfg.Prediction.Mode))
+	}
+
+	// 6ag. Detect points missing from devices' sequence numbers and request them again, if enabled.
+	if cfg.SequenceGaps.Enabled {
+		trackingService.SetSequenceGapDetection(services.NewSequenceGapDetector(cfg.SequenceGaps.ConfirmAfter, cfg.SequenceGaps.GPSGapThreshold, registry))
+		logger.Info("Sequence gap detection enabled",
+			zap.Duration("confirmAfter", cfg.SequenceGaps.ConfirmAfter),
+			zap.Duration("gpsGapThreshold", cfg.SequenceGaps.GPSGapThreshold),
+		)
+	}
+
+	// 6ah. Record every read of a walk's location data for its owner, if enabled.
+	if cfg.AccessLog.Enabled {
+		trackingService.SetWalkAccessStore(repo)
+		logger.Info("Walk access log enabled", zap.String("actorHeader", cfg.AccessLog.ActorHeader))
+	}
+
+	// 6ai. Check every ingested point against its walk's geofences as it arrives, if enabled.
+	if cfg.InlineGeofences.Enabled {
+		trackingService.SetInlineGeofencing(cfg.InlineGeofences.RefreshInterval)
+		groupPoller := services.NewGeofenceGroupPoller(trackingService)
+		groupPoller.Start(cfg.InlineGeofences.RefreshInterval)
+		defer groupPoller.Stop()
+		logger.Info("Inline geofence evaluation enabled", zap.Duration("refreshInterval", cfg.InlineGeofences.RefreshInterval))
+	}
+
+	// 6aj. Register enterprise tenants' point hooks on the location pipeline, if configured.
+	//     Each runs after publishing, for its tenant's sessions only.
+	for _, tenantHook := range cfg.TenantHooks.Hooks {
+		hook, hookErr := services.NewPointHook("tenant."+tenantHook.TenantID+".points", tenantHook.URL, tenantHook.EveryNPoints, cfg.TenantHooks.Timeout, logger)
+		if hookErr == nil {
+			hookErr = trackingService.Pipeline().Register(services.PhasePublish, services.TenantStage(hook, tenantHook.TenantID))
+		}
+		if hookErr != nil {
+			logger.Fatal("Failed to register tenant point hook", zap.String("tenantID", tenantHook.TenantID), zap.Error(hookErr))
+		}
+		logger.Info("Tenant point hook registered",
+			zap.String("tenantID", tenantHook.TenantID),
+			zap.Int("everyNPoints", tenantHook.EveryNPoints),
+		)
+	}
+
 	// 7. Initialize the location handler with the tracking service and logger, referencing the registry if needed.
-	locationHandler := handlers.NewLocationHandler(trackingService, logger, registry)
+	//    Every service call from the handlers passes through one middleware chain: panic recovery, scope
+	//    checks, the diagnostics trail, latency metrics and logging.
+	trackingAPI := services.WithMiddleware(trackingService,
+		services.RecoveryMiddleware(diagnostics),
+		services.LoggingMiddleware(logger),
+		services.MetricsMiddleware(registry),
+		services.TracingMiddleware(diagnostics),
+		services.RequireScopeMiddleware(),
+	)
+	locationHandler := handlers.NewLocationHandler(trackingAPI, logger, registry)
+	locationHandler.SetDiagnostics(diagnostics)
+	if cfg.Stream.CaptureDir != "" && len(cfg.Stream.CaptureSessions) > 0 {
+		capture, captureErr := handlers.NewStreamCapture(cfg.Stream.CaptureDir, cfg.Stream.CaptureSessions)
+		if captureErr != nil {
+			logger.Fatal("Failed to initialize WebSocket capture", zap.Error(captureErr))
+		}
+		locationHandler.SetStreamCapture(capture)
+		logger.Warn("WebSocket capture enabled; inbound messages of selected sessions are written to disk",
+			zap.String("dir", cfg.Stream.CaptureDir),
+			zap.Strings("sessions", cfg.Stream.CaptureSessions),
+		)
+	}
+
+	// 7a. Fan location updates out to every live stream subscribed to their session.
+	streamHub := handlers.NewStreamHub(cfg.Stream, logger, registry)
+	//     Frames are sequenced for resuming subscribers when a resume secret is set,
+	//     in Redis when configured so subscribers can resume on another instance.
+	if cfg.Stream.ResumeSecret != "" {
+		streamBuffer, bufErr := utils.NewStreamBuffer(&cfg.Stream, registry)
+		if bufErr != nil {
+			logger.Fatal("Failed to initialize stream replay buffer", zap.Error(bufErr))
+		}
+		streamHub.EnableResumableStreams(streamBuffer, []byte(cfg.Stream.ResumeSecret))
+		logger.Info("Resumable streams enabled",
+			zap.Bool("sharedBuffer", cfg.Stream.RedisAddr != ""),
+			zap.Int("bufferSize", cfg.Stream.BufferSize),
+			zap.Duration("maxFrameAge", cfg.Stream.MaxFrameAge),
+		)
+	} else {
+		logger.Warn("No stream resume secret configured; reconnecting subscribers cannot replay missed frames")
+	}
+	//     Shared sessions' frames are sealed with their stream key.
+	if cfg.Stream.EncryptionEnabled {
+		streamHub.SetFrameSealer(trackingService.SealStreamFrame)
+	}
+	trackingService.OnLocationBatch(streamHub.PublishLocations)
+	locationHandler.SetStreamHub(streamHub)
+	logger.Info("Live stream fan-out enabled",
+		zap.Int("subscriberQueue", cfg.Stream.SubscriberQueueSize),
+		zap.Duration("subscriberWriteTimeout", cfg.Stream.SubscriberWriteTimeout),
+	)
+
+	locationHandler.SetResponseCompression(cfg.API.CompressionMinBytes)
+	locationHandler.SetAccessActorHeader(cfg.AccessLog.ActorHeader)
+
+	if cfg.Degradation.Enabled {
+		locationHandler.SetLastKnownCache(handlers.NewLastKnownCache(cfg.Degradation.CacheEntries, cfg.Degradation.CacheMaxAge))
+	}
+
+	if cfg.Pagination.CursorSecret != "" {
+		locationHandler.SetPageCursorSigner(handlers.NewPageCursorSigner([]byte(cfg.Pagination.CursorSecret)))
+	} else {
+		logger.Warn("No pagination cursor secret configured; page cursors are valid only on this instance")
+	}
+
+	locationHandler.SetShareLinkSigner(handlers.NewShareLinkSigner([]byte(cfg.ShareLinks.Secret), cfg.ShareLinks.DefaultTTL, cfg.ShareLinks.MaxTTL))
+	if cfg.ShareLinks.Secret == "" {
+		logger.Warn("No share link secret configured; live-share links are valid only on this instance")
+	}
+
+	if cfg.Auth.JWTPublicKey != "" {
+		verifier, verifierErr := handlers.NewIdentityVerifier([]byte(cfg.Auth.JWTPublicKey), cfg.Auth.JWTIssuer, cfg.Auth.JWTAudience)
+		if verifierErr != nil {
+			logger.Fatal("Failed to set up access token verification", zap.Error(verifierErr))
+		}
+		locationHandler.SetIdentityVerifier(verifier)
+	} else {
+		logger.Warn("No JWT public key configured; session sharing, stream keys and escrow access are refused")
+	}
+
+	if cfg.Affinity.Secret != "" {
+		affinity, affinityErr := handlers.NewSessionAffinity(cfg.Affinity, registry)
+		if affinityErr != nil {
+			logger.Fatal("Failed to initialize session affinity", zap.Error(affinityErr))
+		}
+		locationHandler.SetSessionAffinity(affinity)
+		logger.Info("Session affinity enabled",
+			zap.String("node", cfg.Affinity.NodeID),
+			zap.String("fallbackURL", cfg.Affinity.FallbackURL),
+		)
+	}
+
+	// 7b. Sample goroutines, heap and internal queue depths, reporting sustained overload
+	//     on the readiness probe, if enabled.
+	if cfg.Runtime.Enabled {
+		monitor, monErr := services.NewRuntimeMonitor(cfg.Runtime.MaxGoroutines, uint64(cfg.Runtime.MaxHeapMB)<<20,
+			cfg.Runtime.SustainFor, logger, registry)
+		if monErr != nil {
+			logger.Fatal("Failed to initialize runtime monitoring", zap.Error(monErr))
+		}
+		if pmc, isPaho := mqttClient.(*pahoMqttClient); isPaho {
+			monitor.AddQueue("mqtt_inflight", pmc.InFlight, cfg.Runtime.QueueThresholds["mqtt_inflight"])
+		}
+		if coalescer, isCoalescing := dbConn.(*services.CoalescingWriter); isCoalescing {
+			monitor.AddQueue("coalesced_points", coalescer.BufferedPoints, cfg.Runtime.QueueThresholds["coalesced_points"])
+		}
+		if spool != nil {
+			monitor.AddQueue("spool", spool.Len, cfg.Runtime.QueueThresholds["spool"])
+		}
+		monitor.AddQueue("stream_hub", streamHub.QueuedFrames, cfg.Runtime.QueueThresholds["stream_hub"])
+		monitor.Start(cfg.Runtime.Interval)
+		defer monitor.Stop()
+		locationHandler.SetRuntimeMonitor(monitor)
+		logger.Info("Runtime monitoring enabled",
+			zap.Duration("interval", cfg.Runtime.Interval),
+			zap.Duration("sustainFor", cfg.Runtime.SustainFor),
+			zap.Int("maxGoroutines", cfg.Runtime.MaxGoroutines),
+			zap.Int("maxHeapMB", cfg.Runtime.MaxHeapMB),
+		)
+	}
+
+	// 7c. Reload the log level, API rate limit and base session settings from the
+	//     config file on SIGHUP or when it changes, if one is used.
+	apiLimiter, err := newRateLimiter(cfg.Service.RateLimit)
+	if err != nil {
+		logger.Fatal("Failed to initialize API rate limit", zap.Error(err))
+	}
+	if *configPath != "" {
+		configWatcher := config.NewWatcher(*configPath, cfg, cfg.Service.ConfigPollInterval,
+			func(next *config.Config, status config.ReloadStatus, reloadErr error) {
+				if reloadErr != nil {
+					logger.Error("Configuration reload failed; keeping the current configuration", zap.Error(reloadErr))
+					return
+				}
+				featureFlags.SetValue(services.FlagLogLevel, next.Flags.LogLevel)
+				logLevelWatcher.Refresh()
+				if limitErr := setRateLimit(apiLimiter, next.Service.RateLimit); limitErr != nil {
+					logger.Error("Failed to apply reloaded API rate limit", zap.Error(limitErr))
+				}
+				if cfg.Regions.Enabled {
+					trackingService.SetBaseSessionSettings(models.SessionSettings{
+						MaxSpeedKmh:      next.Regions.MaxSpeedKmh,
+						GeofenceRadiusKm: next.Regions.GeofenceRadiusKm,
+					})
+				}
+				logger.Info("Configuration reloaded", zap.Strings("applied", status.Applied))
+				if len(status.RestartRequired) > 0 {
+					logger.Warn("Changed settings take effect only after a restart",
+						zap.Strings("settings", status.RestartRequired),
+					)
+				}
+			})
+		configWatcher.Start()
+		defer configWatcher.Stop()
+		locationHandler.SetConfigWatcher(configWatcher)
+		logger.Info("Configuration reloading enabled",
+			zap.String("configFile", *configPath),
+			zap.Duration("pollInterval", cfg.Service.ConfigPollInterval),
+			zap.Strings("reloadable", config.ReloadableSettings()),
+		)
+	}
 
 	// 8. Configure the HTTP router with security middleware, rate limiting, and monitoring.
-	router := setupRouter(locationHandler, registry, logger)
+	inFlight := handlers.NewInFlightGuard(cfg.InFlight, logger, registry)
+	router := setupRouter(cfg, locationHandler, inFlight, apiLimiter, registry, metrics, logger)
 
 	// 9. Start the HTTP server with graceful shutdown handling.
 	port := defaultPort
@@ -545,6 +2072,14 @@ func main() {
 	// 11. Block until we receive a termination signal, then gracefully shut down.
 	sig := <-quit
 	logger.Info("Caught signal, shutting down", zap.String("signal", sig.String()))
-	gracefulShutdown(server, trackingService, logger)
+	if affinity := locationHandler.SessionAffinity(); affinity != nil && cfg.Affinity.DrainPeriod > 0 {
+		// Redirect stream connects to other nodes before closing the listener.
+		affinity.Drain(cfg.Affinity.HandoffNode)
+		logger.Info("Draining stream connects",
+			zap.Duration("period", cfg.Affinity.DrainPeriod),
+			zap.String("handoffNode", cfg.Affinity.HandoffNode),
+		)
+		time.Sleep(cfg.Affinity.DrainPeriod)
+	}
+	gracefulShutdown(server, trackingService, locationHandler, inFlight, cfg, logger)
 }
-```
\ No newline at end of file
@@ -15,8 +15,9 @@ go 1.21
 // metrics, logging, and configuration management.
 //
 require (
-	// MQTT client for real-time location updates with QoS support
-	github.com/eclipse/paho.mqtt.golang v1.4.3
+	// MQTT 5 client for real-time location updates with QoS support and
+	// message properties (expiry, content type, user properties)
+	github.com/eclipse/paho.golang v0.21.0
 
 	// WebSocket support for client communication
 	github.com/gorilla/websocket v1.5.0
@@ -32,4 +33,22 @@ require (
 
 	// Configuration management library for environment variables and file support
 	github.com/spf13/viper v1.16.0
+
+	// OpenAPI 3 document loading and request/response validation
+	github.com/getkin/kin-openapi v0.120.0
+
+	// Redis client for the shared resumable stream buffer
+	github.com/redis/go-redis/v9 v9.2.1
+
+	// Request coalescing for identical concurrent repository reads
+	golang.org/x/sync v0.4.0
+
+	// EPSG definitions and reprojection of national grid coordinates to WGS84
+	github.com/wroge/wgs84 v1.1.7
+
+	// API Gateway event types for mounting the HTTP handlers in AWS Lambda
+	github.com/aws/aws-lambda-go v1.41.0
+
+	// Circuit breakers guarding TimescaleDB writes and MQTT publishes
+	github.com/sony/gobreaker v0.5.0
 )
\ No newline at end of file
@@ -22,6 +22,9 @@ import (
 	"strconv"  // go1.21 - For string-to-numeric parsing with error handling
 	"fmt"      // go1.21 - For formatted error output
 	"strings"  // go1.21 - For constructing detailed validation error messages
+	"net"      // go1.21 - For validating allowlisted IPs and CIDRs
+	"net/url"  // go1.21 - For validating the affinity fallback, pets service and map matching URLs
+	"encoding/base64" // go1.21 - For validating the key escrow public key
 )
 
 // ------------------------
@@ -38,6 +41,7 @@ const (
 	DefaultMaxConnections          = 100
 	DefaultLocationUpdateInterval  = 5 * time.Second
 	DefaultSessionTimeout          = 30 * time.Minute
+	DefaultRateLimit               = "100/minute"
 )
 
 // ------------------------
@@ -47,6 +51,31 @@ const (
 // MQTTConfig defines core MQTT connection parameters,
 // including security settings (TLS) and reconnect handling.
 //
+// ClientID must be unique per replica, as the broker disconnects a client
+// when another connects with its ID. TopicPrefix, when set, is prepended as
+// leading topic levels to every topic the service publishes and subscribes,
+// so deployments sharing a broker keep separate topic trees. With
+// SharedGroup set, subscriptions matching a SharedTopics filter are made as
+// shared subscriptions ($share/<group>/<filter>), so the broker delivers each
+// of their messages to one replica of the group instead of to every replica.
+// Only topics whose messages any replica can handle should be shared; those
+// feeding state held in each replica's memory, such as uploads for live
+// sessions, walker presence and replicated session state, must keep reaching
+// every replica. Shared subscriptions are an MQTT 5 feature, so the broker
+// must support MQTT 5, as the service's client speaks it.
+//
+// Publishes pass through a circuit breaker, opened by BreakerFailures
+// consecutive failures and probing the broker again after BreakerTimeout.
+// Messages whose publish fails or is refused by the open breaker wait in a
+// fallback queue of FallbackQueueSize messages, delivered in order once the
+// broker recovers, instead of blocking their publisher; those still queued
+// after FallbackMaxAge are dropped as stale.
+//
+// Location updates are published with an MQTT 5 message expiry of
+// LocationExpiry, so the broker discards those a subscriber has not received
+// in time rather than delivering stale positions; zero keeps them until they
+// are delivered.
+//
 type MQTTConfig struct {
 	Host             string
 	Port             int
@@ -55,8 +84,48 @@ type MQTTConfig struct {
 	ConnectionTimeout time.Duration
 	KeepAlive         time.Duration
 	TLSEnabled        bool
-	QoS               int
+	QoS               MQTTQoSConfig
 	RetryInterval     time.Duration
+	DispatchWorkers   int
+	DispatchQueueSize int
+	ClientID          string
+	TopicPrefix       string
+	SharedGroup       string
+	SharedTopics      []string
+	BreakerFailures   int
+	BreakerTimeout    time.Duration
+	FallbackQueueSize int
+	FallbackMaxAge    time.Duration
+	LocationExpiry    time.Duration
+}
+
+// ------------------------
+// MQTTQoSConfig Struct
+// ------------------------
+//
+// MQTTQoSConfig sets the QoS level, 0, 1 or 2, each class of MQTT message is
+// published with: Location for location updates, Control for session control
+// acks, upload acks and replicated session lifecycle events, Alert for
+// geofence, owner, walker offline and escrow alerts, and Summary for
+// replicated walk summaries. Subscriptions carrying several classes are made
+// with the highest level, so none of them is downgraded by the broker.
+//
+type MQTTQoSConfig struct {
+	Location int
+	Control  int
+	Alert    int
+	Summary  int
+}
+
+// Max returns the highest of the configured QoS levels.
+func (q MQTTQoSConfig) Max() int {
+	highest := q.Location
+	for _, level := range []int{q.Control, q.Alert, q.Summary} {
+		if level > highest {
+			highest = level
+		}
+	}
+	return highest
 }
 
 // ------------------------
@@ -65,7 +134,24 @@ type MQTTConfig struct {
 //
 // DBConfig defines TimescaleDB connection parameters,
 // including credentials, connection pooling, timeouts,
-// and other essential database settings.
+// and other essential database settings. The pool is sampled
+// every PoolStatsInterval; acquisitions waiting longer than
+// AcquireWaitThreshold on average raise an alert. With
+// PoolAutoTune enabled, MinConns/MaxConns are re-sized once a
+// day at PoolAutoTuneHour (local time) from the observed load,
+// never exceeding MaxConnections.
+//
+// The session event stream and session summaries are partitioned
+// by time into chunks of EventsChunkInterval and
+// SessionsChunkInterval; partitions older than EventRetention and
+// SessionRetention are dropped, zero keeping them indefinitely.
+//
+// In multi-AZ deployments Endpoints lists the addresses the database is
+// reachable at, replacing Host and Port. Connections go to the first healthy
+// endpoint in Zone, this instance's availability zone, then to the others in
+// the order listed. Every endpoint is probed each EndpointHealthInterval;
+// connections fail over as soon as their endpoint stops answering and move
+// back once a preferred endpoint recovers.
 //
 type DBConfig struct {
 	Host                 string
@@ -77,6 +163,26 @@ type DBConfig struct {
 	ConnectionTimeout    time.Duration
 	MaxIdleConnections   int
 	MaxConnectionLifetime time.Duration
+	Schema               string
+	PoolStatsInterval    time.Duration
+	AcquireWaitThreshold time.Duration
+	PoolAutoTune         bool
+	PoolAutoTuneHour     int
+	EventsChunkInterval  time.Duration
+	SessionsChunkInterval time.Duration
+	EventRetention       time.Duration
+	SessionRetention     time.Duration
+
+	Zone                   string
+	Endpoints              []DBEndpoint
+	EndpointHealthInterval time.Duration
+}
+
+// DBEndpoint is one address of the database, in an availability zone.
+type DBEndpoint struct {
+	Zone string
+	Host string
+	Port int
 }
 
 // ------------------------
@@ -94,287 +200,2905 @@ type ServiceConfig struct {
 	MinAccuracy            float64
 	MaxLocationHistory     int
 	StaleLocationThreshold time.Duration
+	LocationHistoryMode    string
+	EventSourcingEnabled   bool
+
+	// CompletedSessionRetention is how long ended session IDs are remembered so
+	// late location messages for them are rejected at ingress with a terminal ack.
+	CompletedSessionRetention time.Duration
+
+	// CurrentWalksEnabled maintains the current walks table on every session
+	// transition and publishes its changes through an outbox and LISTEN/NOTIFY.
+	CurrentWalksEnabled bool
+
+	// SummaryDistance selects how recomputed statistics measure distance:
+	// "haversine", as live updates do, or "geodesic" on the WGS84 ellipsoid,
+	// which is slower but avoids the sphere's error on long segments.
+	SummaryDistance string
+
+	// RateLimit caps the HTTP API's requests as "<count>/<unit>", the unit
+	// being second, minute or hour. It is reloadable.
+	RateLimit string
+
+	// ConfigPollInterval is how often the config file is checked for changes
+	// to reload; zero reloads on SIGHUP only.
+	ConfigPollInterval time.Duration
 }
 
 // ------------------------
-// Config Struct
+// ReplicationConfig Struct
 // ------------------------
 //
-// Config is the main configuration structure for the tracking service,
-// consolidating MQTT, DB, and Service-level configs. It offers a Validate method
-// to ensure all fields are thoroughly checked and safe for production use.
+// ReplicationConfig defines the multi-region disaster recovery settings. When
+// enabled, session lifecycle and summary events are mirrored to every peer region
+// over the message bus, tagged with the local Region identifier.
 //
-type Config struct {
-	MQTT    MQTTConfig
-	Database DBConfig
-	Service ServiceConfig
+type ReplicationConfig struct {
+	Enabled     bool
+	Region      string
+	PeerRegions []string
 }
 
 // ------------------------
-// Validate Method
+// APIConfig Struct
 // ------------------------
 //
-// Validate performs comprehensive validation on all configuration fields.
-// It aggregates any errors found and returns them as a single error. If no
-// issues are found, it returns nil.
+// APIConfig defines HTTP API contract settings. SpecPath points at the OpenAPI
+// document enforced by the validation middleware; an empty path disables it.
+// ValidateResponses additionally checks outbound responses and is meant for
+// debug deployments only.
 //
-// Returns:
-//   error: A descriptive error if any validation checks fail, or nil otherwise.
+// CompressionMinBytes is the smallest history response compressed with gzip
+// or deflate for clients that accept it; zero disables response compression.
+// Compressed request bodies are always accepted.
 //
-func (c *Config) Validate() error {
-	var validationErrs []string
+type APIConfig struct {
+	SpecPath            string
+	ValidateResponses   bool
+	CompressionMinBytes int
+}
 
-	// ------------------------
-	// MQTT Validation
-	// ------------------------
-	if strings.TrimSpace(c.MQTT.Host) == "" {
-		validationErrs = append(validationErrs, "MQTT host is empty")
-	}
-	if c.MQTT.Port <= 0 || c.MQTT.Port > 65535 {
-		validationErrs = append(validationErrs, fmt.Sprintf("MQTT port %d is out of valid range", c.MQTT.Port))
-	}
-	if c.MQTT.ConnectionTimeout <= 0 {
-		validationErrs = append(validationErrs, "MQTT connection timeout must be greater than zero")
-	}
-	if c.MQTT.KeepAlive < 0 {
-		validationErrs = append(validationErrs, "MQTT keep-alive cannot be negative")
-	}
-	if c.MQTT.QoS < 0 || c.MQTT.QoS > 2 {
-		validationErrs = append(validationErrs, fmt.Sprintf("MQTT QoS %d is invalid; must be 0, 1, or 2", c.MQTT.QoS))
-	}
-	if c.MQTT.RetryInterval < 0 {
-		validationErrs = append(validationErrs, "MQTT retry interval cannot be negative")
-	}
+// ------------------------
+// StreamConfig Struct
+// ------------------------
+//
+// StreamConfig defines resumable WebSocket stream settings. Outbound frames are
+// kept in a last-N buffer per session, shared across instances through Redis when
+// RedisAddr is set, so a subscriber that reconnects to another node can replay
+// the frames it missed. ResumeSecret signs the resume tokens handed to clients;
+// an empty secret disables resumption.
+//
+// BufferSize and MaxFrameAge limit the buffer of sessions on the default tier:
+// frames beyond the last BufferSize, or older than MaxFrameAge, are pruned and
+// never replayed. Tiers overrides both limits for named tiers assigned to
+// individual sessions with PUT /admin/sessions/{sessionID}/stream-tier.
+// BufferTTL expires a session's whole buffer once idle.
+//
+// EncryptionEnabled allows sessions to be shared with subscribers under a
+// per-session stream key, so their outbound frames are sent encrypted.
+//
+// CaptureDir and CaptureSessions record the full inbound message stream of the
+// listed sessions ("*" for all) to files under CaptureDir, for replay as
+// regression fixtures. Capture is off unless both are set.
+//
+// ThrottleMaxInterval caps the per-subscriber delivery interval a client may
+// negotiate, receiving at most one frame per interval with the latest winning.
+// Subscribers whose smoothed ping RTT exceeds ThrottleAutoRTT are throttled to
+// ThrottleAutoInterval until it falls below half that; zero disables automatic
+// throttling.
+//
+// SubscriberQueueSize bounds the frames queued for each live stream subscriber
+// of a session. A subscriber whose queue is full, or whose socket accepts no
+// frame within SubscriberWriteTimeout, is evicted so it cannot hold back the
+// others.
+//
+// DrainTimeout bounds how long shutdown waits for live streams to answer the
+// "server restarting" close frame before closing them.
+//
+type StreamConfig struct {
+	RedisAddr     string
+	RedisPassword string
+	RedisDB       int
+	BufferSize    int
+	BufferTTL     time.Duration
+	MaxFrameAge   time.Duration
+	Tiers         map[string]StreamTierLimits
+	ResumeSecret  string
 
-	// ------------------------
-	// Database Validation
-	// ------------------------
-	if strings.TrimSpace(c.Database.Host) == "" {
-		validationErrs = append(validationErrs, "DB host is empty")
-	}
-	if c.Database.Port <= 0 || c.Database.Port > 65535 {
-		validationErrs = append(validationErrs, fmt.Sprintf("DB port %d is out of valid range", c.Database.Port))
-	}
-	if strings.TrimSpace(c.Database.Database) == "" {
-		validationErrs = append(validationErrs, "DB database name is empty")
-	}
-	if c.Database.MaxConnections < 1 {
-		validationErrs = append(validationErrs, fmt.Sprintf("DB max connections %d is invalid; must be at least 1", c.Database.MaxConnections))
-	}
-	if c.Database.ConnectionTimeout < 0 {
-		validationErrs = append(validationErrs, "DB connection timeout cannot be negative")
-	}
-	if c.Database.MaxIdleConnections < 0 {
-		validationErrs = append(validationErrs, fmt.Sprintf("DB max idle connections %d cannot be negative", c.Database.MaxIdleConnections))
+	EncryptionEnabled bool
+
+	CaptureDir      string
+	CaptureSessions []string
+
+	ThrottleMaxInterval  time.Duration
+	ThrottleAutoRTT      time.Duration
+	ThrottleAutoInterval time.Duration
+
+	SubscriberQueueSize    int
+	SubscriberWriteTimeout time.Duration
+	DrainTimeout time.Duration
+}
+
+// DefaultStreamTier names the tier of sessions not assigned to any other.
+const DefaultStreamTier = "default"
+
+// StreamTierLimits bounds the replay buffer of sessions on one tier by frame
+// count and frame age.
+type StreamTierLimits struct {
+	BufferSize  int
+	MaxFrameAge time.Duration
+}
+
+// TierLimits returns the limits of tier, falling back to the default tier's for
+// unknown names. ok reports whether tier is configured.
+func (c StreamConfig) TierLimits(tier string) (limits StreamTierLimits, ok bool) {
+	if limits, ok = c.Tiers[tier]; ok {
+		return limits, true
 	}
-	if c.Database.MaxConnectionLifetime < 0 {
-		validationErrs = append(validationErrs, "DB max connection lifetime cannot be negative")
+	return StreamTierLimits{BufferSize: c.BufferSize, MaxFrameAge: c.MaxFrameAge}, tier == DefaultStreamTier
+}
+
+// ------------------------
+// MetricsConfig Struct
+// ------------------------
+//
+// MetricsConfig controls Prometheus label cardinality. By default, per-session
+// values such as MQTT topics are collapsed into bounded templates/classes.
+// HighCardinality switches to raw values for debugging, and MaxLabelValues caps
+// the distinct values any guarded label may take in either mode.
+//
+type MetricsConfig struct {
+	HighCardinality bool
+	MaxLabelValues  int
+}
+
+// ------------------------
+// PresenceConfig Struct
+// ------------------------
+//
+// PresenceConfig controls walker presence tracking. Walkers publish heartbeats
+// independently of any session; a walker whose last heartbeat is older than
+// Timeout is marked offline by a sweep running every SweepInterval.
+//
+type PresenceConfig struct {
+	Enabled       bool
+	Timeout       time.Duration
+	SweepInterval time.Duration
+}
+
+// ------------------------
+// FitnessConfig Struct
+// ------------------------
+//
+// FitnessConfig controls fitness platform integrations. FIT export is always
+// available; with AutoUploadEnabled, completed walks are uploaded to every
+// platform an owner has connected, using the Strava OAuth application
+// credentials to refresh expired tokens.
+//
+type FitnessConfig struct {
+	AutoUploadEnabled  bool
+	StravaClientID     string
+	StravaClientSecret string
+	UploadTimeout      time.Duration
+}
+
+// ------------------------
+// GeocodingConfig Struct
+// ------------------------
+//
+// GeocodingConfig controls reverse geocoding of completed walks' start and end
+// addresses into their summaries. Provider is nominatim, google or mapbox, or
+// empty to disable it; APIKey is the Google key or Mapbox token. Lookups are
+// cached for CacheTTL in a CacheSize-entry cache and limited to RateLimit
+// provider requests per second.
+//
+type GeocodingConfig struct {
+	Provider  string
+	APIKey    string
+	BaseURL   string
+	UserAgent string
+	Timeout   time.Duration
+	RateLimit float64
+	CacheSize int
+	CacheTTL  time.Duration
+}
+
+// ------------------------
+// DogProfileConfig Struct
+// ------------------------
+//
+// DogProfileConfig enables enriching session-start stream frames and walk
+// summaries with the walked dog's name, breed and photo from the pets service
+// at PetsServiceURL, or disables it when empty. Token is sent as a bearer
+// token. Profiles are cached for CacheTTL in a CacheSize-entry cache and
+// each lookup is bounded by Timeout.
+//
+type DogProfileConfig struct {
+	PetsServiceURL string
+	Token          string
+	Timeout        time.Duration
+	CacheSize      int
+	CacheTTL       time.Duration
+}
+
+// ------------------------
+// BatchingConfig Struct
+// ------------------------
+//
+// BatchingConfig controls coalescing of location writes. When enabled, each
+// session's points are buffered and written once MaxPoints accumulate or the
+// flush window elapses; the window is MaxWindow for busy sessions and shrinks
+// towards MinWindow as a session's update rate drops, bounding write latency.
+//
+type BatchingConfig struct {
+	Enabled   bool
+	MaxPoints int
+	MinWindow time.Duration
+	MaxWindow time.Duration
+}
+
+// ------------------------
+// AnalyticsConfig Struct
+// ------------------------
+//
+// AnalyticsConfig controls aggregate analytics built from completed walks. With
+// RoutePopularityEnabled, walk tracks are counted into a route popularity layer;
+// segments walked fewer than RouteMinWalks times are withheld from queries so an
+// individual's regular route cannot be picked out. With LeaderboardEnabled,
+// completed walks of sessions started with a tenant are recorded for the
+// tenant's walker leaderboard. With DemandEnabled, completed walks are counted
+// by the grid cell and hour they started in, for the walker app's demand
+// suggestions; cell and hour buckets with fewer than DemandMinStarts starts
+// are withheld.
+//
+type AnalyticsConfig struct {
+	RoutePopularityEnabled bool
+	RouteMinWalks          int
+	LeaderboardEnabled     bool
+	DemandEnabled          bool
+	DemandMinStarts        int
+}
+
+// ------------------------
+// AbuseConfig Struct
+// ------------------------
+//
+// AbuseConfig protects streaming endpoints from brute-force and connection
+// floods. Each client IP may hold at most MaxConnsPerIP concurrent streams and
+// open new ones at ConnRatePerMinute with bursts of ConnBurst. An IP failing
+// authentication AuthFailureLimit times within AuthFailureWindow is banned for
+// BanDuration; bans are shared through the stream Redis when configured.
+// Allowlist entries (IPs or CIDRs) bypass every limit.
+//
+type AbuseConfig struct {
+	Enabled           bool
+	MaxConnsPerIP     int
+	ConnRatePerMinute int
+	ConnBurst         int
+	AuthFailureLimit  int
+	AuthFailureWindow time.Duration
+	BanDuration       time.Duration
+	Allowlist         []string
+}
+
+// ------------------------
+// SLOConfig Struct
+// ------------------------
+//
+// SLOConfig defines the location delivery objective: LatencyTarget of location
+// updates must be published to subscribers within LatencyThreshold of being
+// ingested, measured over a rolling BudgetPeriod. The remaining error budget and
+// burn rates over shorter windows are exported as metrics and on /admin/slo.
+//
+type SLOConfig struct {
+	Enabled          bool
+	LatencyThreshold time.Duration
+	LatencyTarget    float64
+	BudgetPeriod     time.Duration
+}
+
+// ------------------------
+// MigrationConfig Struct
+// ------------------------
+//
+// MigrationConfig controls the dual-write phase of a storage migration or
+// re-shard. With DualWriteEnabled, every repository write is mirrored to the
+// Shadow database after it succeeds on the primary, and ReadSampleRate of reads
+// are repeated against the shadow and compared. Shadow inherits the primary's
+// pool settings; only its location and credentials are configured separately.
+// Live location batches written through the ingestion pool are not mirrored.
+//
+type MigrationConfig struct {
+	DualWriteEnabled bool
+	Shadow           DBConfig
+	ReadSampleRate   float64
+}
+
+// ------------------------
+// FlagsConfig Struct
+// ------------------------
+//
+// FlagsConfig configures the static feature-flag client used for gradual
+// rollouts of new pipeline stages. Rollouts gives each flag the percentage of
+// sessions, or of tenants, it is enabled for; flags not listed are off.
+// LogLevel is the initial value of the log-level flag, which the logger
+// re-reads every RefreshInterval so a remote flags client can change it live.
+//
+type FlagsConfig struct {
+	Rollouts        map[string]FlagRollout
+	LogLevel        string
+	RefreshInterval time.Duration
+}
+
+// Rollout bucketing keys: each session, or each tenant as a whole, falls
+// consistently inside or outside a flag's percentage.
+const (
+	FlagBySession = "session"
+	FlagByTenant  = "tenant"
+)
+
+// FlagRollout enables a flag for Percentage (0-100) of subjects, bucketed by
+// session or tenant.
+type FlagRollout struct {
+	Percentage float64
+	By         string
+}
+
+// ------------------------
+// AffinityConfig Struct
+// ------------------------
+//
+// AffinityConfig pins each session's WebSocket connects to the instance holding
+// it in memory. Sessions created on this instance get an affinity token naming
+// NodeID, signed with Secret, which the load balancer routes on and clients
+// present when connecting; an empty secret disables affinity.
+//
+// FallbackURL is the load-balanced stream URL that misrouted connects, and every
+// connect while this instance drains, are redirected to with a 307. While
+// draining, redirects name HandoffNode, when set, as the node to reconnect to.
+// DrainPeriod is how long the instance keeps redirecting before it shuts down.
+//
+type AffinityConfig struct {
+	NodeID      string
+	Secret      string
+	FallbackURL string
+	HandoffNode string
+	DrainPeriod time.Duration
+}
+
+// ------------------------
+// DegradationConfig Struct
+// ------------------------
+//
+// DegradationConfig controls how the service degrades while the database is
+// unavailable. When enabled, location batches refused while the database
+// circuit breaker is open are written to a disk spool in SpoolDir, holding up
+// to SpoolMaxBatches, and accepted with 202 "queued"; the spool is drained
+// into the database every DrainInterval. History endpoints answer with their
+// last-known response, up to CacheMaxAge old, instead of an error, remembering
+// the latest CacheEntries responses.
+//
+type DegradationConfig struct {
+	Enabled         bool
+	SpoolDir        string
+	SpoolMaxBatches int
+	DrainInterval   time.Duration
+	CacheEntries    int
+	CacheMaxAge     time.Duration
+}
+
+// ------------------------
+// DiagnosticsConfig Struct
+// ------------------------
+//
+// DiagnosticsConfig controls the bundles captured when a panic is recovered:
+// the stack, the redacted triggering payload, the session context and the
+// last TrailSize pipeline events. Bundles are written to Dir, keeping the
+// newest MaxBundles (zero keeps all); an empty Dir only logs and counts them.
+//
+type DiagnosticsConfig struct {
+	Dir        string
+	MaxBundles int
+	TrailSize  int
+}
+
+// ------------------------
+// SmoothingConfig Struct
+// ------------------------
+//
+// SmoothingConfig controls the Kalman filter applied to batches of fixes
+// before storage, so GPS jitter does not inflate distance and speed. With
+// Enabled it runs for the sessions the kalman-filter feature flag is rolled
+// out to. ProcessNoise is how far, in meters per second, the walker may
+// plausibly move between fixes; lower values smooth harder. A silence
+// longer than ResetAfter restarts a session's filter.
+//
+type SmoothingConfig struct {
+	Enabled      bool
+	ProcessNoise float64
+	ResetAfter   time.Duration
+}
+
+// ------------------------
+// MapMatchingConfig Struct
+// ------------------------
+//
+// MapMatchingConfig controls snapping fixes to the road and path network
+// before they count towards distance. Provider is osrm or valhalla, or empty
+// to disable it, served at URL with the given Profile, such as "foot" for
+// OSRM or "pedestrian" for Valhalla. ContextPoints previous points of each
+// session are matched along with every batch, points are never moved further
+// than MaxSnapMeters, and each request is bounded by Timeout.
+//
+type MapMatchingConfig struct {
+	Provider      string
+	URL           string
+	Profile       string
+	Timeout       time.Duration
+	ContextPoints int
+	MaxSnapMeters float64
+}
+
+// ------------------------
+// PredictionConfig Struct
+// ------------------------
+//
+// PredictionConfig controls predicting each walk's duration and distance when
+// its session starts. Mode is endpoint, to POST the walk and the dog's history
+// to URL, falling back to the heuristic when it fails, heuristic, for the
+// median of the dog's past walks, or empty to disable it. The history is the
+// dog's last HistoryWalks leaderboard walks ended within HistoryWindow, and
+// each prediction is bounded by Timeout.
+//
+type PredictionConfig struct {
+	Mode          string
+	URL           string
+	Timeout       time.Duration
+	HistoryWalks  int
+	HistoryWindow time.Duration
+}
+
+// ------------------------
+// TenantHookConfig Struct
+// ------------------------
+//
+// TenantHookConfig registers enterprise tenants' hooks on the location
+// pipeline. Each hook POSTs every EveryNPoints-th point accepted for the
+// tenant's sessions to the tenant's URL, e.g. their analytics endpoint; each
+// post is bounded by Timeout.
+//
+type TenantHookConfig struct {
+	Hooks   []TenantPointHook
+	Timeout time.Duration
+}
+
+// TenantPointHook is one tenant's point hook.
+type TenantPointHook struct {
+	TenantID     string
+	EveryNPoints int
+	URL          string
+}
+
+// ------------------------
+// BeaconConfig Struct
+// ------------------------
+//
+// BeaconConfig controls the ingestion of Bluetooth beacon proximity events,
+// used for indoor presence at daycare facilities. Sightings are stored in
+// their own hypertable and merged into session timelines, so indoor periods
+// are not reported as GPS gaps. Sightings weaker than MinRSSI dBm are ignored.
+//
+type BeaconConfig struct {
+	Enabled bool
+	MinRSSI int
+}
+
+// ------------------------
+// RegionConfig Struct
+// ------------------------
+//
+// RegionConfig enables named configuration profiles per city or region,
+// stored in the database and edited through the admin API. A session started
+// with a region code gets the base settings below with the region's profile
+// layered over them. MaxSpeedKmh rejects fixes implying faster movement, zero
+// accepting any speed; GeofenceRadiusKm is the radius of circle geofences
+// created without one. Every RetentionInterval, location points older than a
+// region's retention are purged from the walks started there; with
+// RetentionDryRun the sweep only logs what it would purge.
+//
+type RegionConfig struct {
+	Enabled           bool
+	MaxSpeedKmh       float64
+	GeofenceRadiusKm  float64
+	RetentionInterval time.Duration
+	RetentionDryRun   bool
+}
+
+// ------------------------
+// OwnerAlertConfig Struct
+// ------------------------
+//
+// OwnerAlertConfig enables owner boundary alerts: owners set their own alert
+// radius per dog through the owner preferences API, usually tighter than the
+// operational geofence, and are notified on their own MQTT topic when a walk
+// of the dog leaves or returns within it.
+//
+type OwnerAlertConfig struct {
+	Enabled bool
+}
+
+// ------------------------
+// BackpressureConfig Struct
+// ------------------------
+//
+// BackpressureConfig pauses consumption of low-priority MQTT topics while
+// internal processing queues are saturated, so a database slowdown cannot
+// pile up unbounded work in memory. Every CheckInterval the fill of each
+// queue is sampled: running MQTT handlers against MaxInFlight, points held by
+// write coalescing against MaxBufferedPoints, and the spool against its
+// maximum. Once any queue reaches HighWatermark of its capacity the
+// LowPriorityTopics are unsubscribed, and they are resubscribed when every
+// queue is back under LowWatermark. Messages published meanwhile are lost.
+//
+type BackpressureConfig struct {
+	Enabled           bool
+	LowPriorityTopics []string
+	HighWatermark     float64
+	LowWatermark      float64
+	CheckInterval     time.Duration
+	MaxInFlight       int
+	MaxBufferedPoints int
+}
+
+// ------------------------
+// PaginationConfig Struct
+// ------------------------
+//
+// PaginationConfig configures the cursors of paginated listings, such as
+// location history, session events and current walks. CursorSecret signs
+// them so clients cannot forge positions; every instance behind the load
+// balancer must share it. When empty each instance draws a random secret, and
+// cursors are honoured only by the instance that issued them until it
+// restarts.
+//
+type PaginationConfig struct {
+	CursorSecret string
+}
+
+// ------------------------
+// ShareLinkConfig Struct
+// ------------------------
+//
+// ShareLinkConfig configures the live-share links owners create to let others
+// follow a walk read-only. Secret signs their tokens; every instance behind
+// the load balancer must share it, and when empty each instance draws a
+// random secret. Links are valid for DefaultTTL unless requested otherwise,
+// and for at most MaxTTL.
+//
+type ShareLinkConfig struct {
+	Secret     string
+	DefaultTTL time.Duration
+	MaxTTL     time.Duration
+}
+
+// ------------------------
+// AuthConfig Struct
+// ------------------------
+//
+// AuthConfig configures verification of the auth service's access tokens,
+// which identify callers of endpoints that act on someone's behalf: sharing a
+// session's encrypted stream, fetching its stream key and releasing escrowed
+// keys. JWTPublicKey is the PEM-encoded RSA key the auth service's tokens are
+// verified with; when empty, those endpoints reject every request. Tokens
+// must have been issued by JWTIssuer for JWTAudience.
+//
+type AuthConfig struct {
+	JWTPublicKey string
+	JWTIssuer    string
+	JWTAudience  string
+}
+
+// ------------------------
+// IntegrityConfig Struct
+// ------------------------
+//
+// IntegrityConfig schedules the data integrity checker. Every Interval it
+// samples up to SampleSize walks completed within Lookback, rebuilds each from
+// its event stream, and cross-checks the accumulated distance against the
+// distance recomputed from the stored points, allowing DistanceTolerance as a
+// fraction of the latter, the accepted point counter against the stored
+// points, and that a leaderboard summary was recorded. Discrepancies are
+// exported as metrics and served at /admin/integrity. It requires event
+// sourcing.
+//
+type IntegrityConfig struct {
+	Enabled           bool
+	Interval          time.Duration
+	Lookback          time.Duration
+	SampleSize        int
+	DistanceTolerance float64
+}
+
+// ------------------------
+// RuntimeMonitorConfig Struct
+// ------------------------
+//
+// RuntimeMonitorConfig enables self-monitoring. Every Interval the goroutine
+// count, heap and the depth of internal queues are sampled and queue depths
+// exported as metrics; the readiness probe at /health reports the instance
+// degraded, with 503, while any of them stays above its limit for SustainFor.
+// QueueThresholds maps queue names to their limit: mqtt_inflight (running
+// message handlers), coalesced_points (points held by the batch writer), spool
+// (batches spooled to disk) and stream_hub (frames queued for live streams).
+// Zero MaxGoroutines or MaxHeapMB is not checked.
+//
+type RuntimeMonitorConfig struct {
+	Enabled         bool
+	Interval        time.Duration
+	SustainFor      time.Duration
+	MaxGoroutines   int
+	MaxHeapMB       int
+	QueueThresholds map[string]int
+}
+
+// monitoredQueues are the queue names accepted in QueueThresholds.
+var monitoredQueues = map[string]bool{"mqtt_inflight": true, "coalesced_points": true, "spool": true, "stream_hub": true}
+
+// ------------------------
+// ReaperConfig Struct
+// ------------------------
+//
+// ReaperConfig schedules the session reaper. Every Interval it completes
+// active sessions that received no update for 15 minutes, or that have run
+// for longer than the service's SessionTimeout, and persists their final
+// statistics. It is off by default, as the default SessionTimeout would end
+// walks longer than 30 minutes.
+//
+type ReaperConfig struct {
+	Enabled  bool
+	Interval time.Duration
+}
+
+// ------------------------
+// DedupConfig Struct
+// ------------------------
+//
+// DedupConfig enables the in-memory filter of recently stored location IDs,
+// which skips the points of uploads retried by mobile clients before they are
+// processed. It holds two generations of Capacity IDs, and wrongly skips a
+// new point at about FalsePositiveRate. The database stores each location ID
+// once whether or not it is enabled.
+//
+type DedupConfig struct {
+	Enabled           bool
+	Capacity          int
+	FalsePositiveRate float64
+}
+
+// ------------------------
+// QuarantineConfig Struct
+// ------------------------
+//
+// QuarantineConfig enables the location quarantine. Points failing validation
+// or the session's checks are then stored with the reason they were rejected,
+// counted by reason, and can be inspected and ingested again through the admin
+// quarantine API, instead of being dropped.
+//
+type QuarantineConfig struct {
+	Enabled bool
+}
+
+// ------------------------
+// EscrowConfig Struct
+// ------------------------
+//
+// EscrowConfig enables key escrow of session stream keys, which requires
+// stream encryption. Every stream key issued is also stored wrapped under
+// PublicKey, a base64 X25519 key whose private key is split among Custodians
+// and held by none of them, so a walk's encrypted frames can be decrypted under
+// a legal request only once every custodian presents their share. Every access
+// is audited and notified to the walk's tenant. Keys and shares are generated
+// with the -escrow-keygen flag, which writes each share to its own file under
+// -escrow-share-dir.
+//
+type EscrowConfig struct {
+	Enabled    bool
+	PublicKey  string
+	Custodians []string
+}
+
+// ------------------------
+// InFlightConfig Struct
+// ------------------------
+//
+// InFlightConfig guards POST /location against concurrency spikes. Once
+// MaxLocationRequests location updates are being handled, further ones are
+// refused with 429 and a Retry-After of RetryAfter, so bursts back off on the
+// clients instead of queueing for database connections; zero sets no ceiling.
+// On shutdown new updates are refused and those in flight are given up to
+// DrainTimeout to complete before the server stops.
+//
+type InFlightConfig struct {
+	MaxLocationRequests int
+	RetryAfter          time.Duration
+	DrainTimeout        time.Duration
+}
+
+// ------------------------
+// SequenceGapConfig Struct
+// ------------------------
+//
+// SequenceGapConfig enables gap detection on the per-point sequence numbers
+// devices attach to their fixes. Points missing from a session's sequence are
+// requested again from the device, and counted lost when still missing after
+// ConfirmAfter or when the device confirms it no longer holds them. Pauses
+// longer than GPSGapThreshold between consecutively numbered points are
+// counted as GPS gaps instead, periods the device took no fix.
+//
+type SequenceGapConfig struct {
+	Enabled         bool
+	ConfirmAfter    time.Duration
+	GPSGapThreshold time.Duration
+}
+
+// ------------------------
+// AccessLogConfig Struct
+// ------------------------
+//
+// AccessLogConfig enables the walk access log. Every read of a walk's location
+// data, through the history, export, statistics and replay endpoints or a
+// subscribed WebSocket stream, is recorded with who read it, when and through
+// which endpoint, and listed to the walk's owner by GET /walks/:walkID/access-log.
+// The reader is taken from the ActorHeader set by the gateway once it has
+// authenticated the caller, else identified by a fingerprint of their token.
+//
+type AccessLogConfig struct {
+	Enabled     bool
+	ActorHeader string
+}
+
+// ------------------------
+// InlineGeofenceConfig Struct
+// ------------------------
+//
+// InlineGeofenceConfig moves geofence evaluation into location ingestion.
+// Every accepted point, each point of a batch in order, is checked against
+// its walk's geofences and the geofence groups as it arrives, and a
+// violation event is published the moment a zone is breached, rather than
+// when the health monitor next polls the session. Every RefreshInterval the
+// geofence groups are reloaded and polled against each session's newest
+// point, so a scheduled group that becomes active after the walker stops
+// sending points is breached. A walk's geofences are reloaded every
+// RefreshInterval, and as soon as one is created.
+//
+type InlineGeofenceConfig struct {
+	Enabled         bool
+	RefreshInterval time.Duration
+}
+
+// ------------------------
+// DeviceConfig Struct
+// ------------------------
+//
+// DeviceConfig enables the trusted-device registry. Walkers pair the devices
+// they report walks from through the walker devices API, with a request the
+// app attests by signing it with AttestationSecret, and revoke lost ones.
+// Sessions record the trust of the device they were started from; those from
+// devices the walker has not paired, or revoked, are allowed, flagged or
+// rejected by their tenant's policy in TenantPolicies, else DefaultPolicy.
+//
+type DeviceConfig struct {
+	Enabled           bool
+	AttestationSecret string
+	DefaultPolicy     string
+	TenantPolicies    map[string]string
+}
+
+// devicePolicies are the policies accepted for DefaultPolicy and TenantPolicies.
+var devicePolicies = map[string]bool{"allow": true, "flag": true, "reject": true}
+
+// ------------------------
+// IDConfig Struct
+// ------------------------
+//
+// IDConfig selects how new location and session IDs are generated. Format is
+// "uuid" (random UUIDv4, the default), "ulid" or "snowflake"; the latter two
+// sort by creation time. Snowflake IDs embed SnowflakeNodeID, which must be
+// unique per instance within a deployment.
+//
+// AcceptedFormats lists the ID formats accepted on ingestion, empty meaning
+// all. While migrating from one format to another keep both listed, so points
+// and sessions created before the switch remain valid.
+//
+type IDConfig struct {
+	Format          string
+	SnowflakeNodeID int64
+	AcceptedFormats []string
+}
+
+// idFormats are the ID formats accepted for Format and AcceptedFormats.
+var idFormats = map[string]bool{"uuid": true, "ulid": true, "snowflake": true}
+
+// logLevels are the zap level names accepted for LogLevel.
+var logLevels = map[string]bool{"debug": true, "info": true, "warn": true, "error": true, "dpanic": true, "panic": true, "fatal": true}
+
+// ------------------------
+// Config Struct
+// ------------------------
+//
+// Config is the main configuration structure for the tracking service,
+// consolidating MQTT, DB, and Service-level configs. It offers a Validate method
+// to ensure all fields are thoroughly checked and safe for production use.
+//
+type Config struct {
+	MQTT    MQTTConfig
+	Database DBConfig
+	Service ServiceConfig
+	Replication ReplicationConfig
+	API         APIConfig
+	Stream      StreamConfig
+	Metrics     MetricsConfig
+	Presence    PresenceConfig
+	Fitness     FitnessConfig
+	Geocoding   GeocodingConfig
+	DogProfiles DogProfileConfig
+	Batching    BatchingConfig
+	Analytics   AnalyticsConfig
+	Abuse       AbuseConfig
+	SLO         SLOConfig
+	Migration   MigrationConfig
+	Flags       FlagsConfig
+	Affinity    AffinityConfig
+	IDs         IDConfig
+	Degradation DegradationConfig
+	Diagnostics DiagnosticsConfig
+	Beacons     BeaconConfig
+	Smoothing   SmoothingConfig
+	MapMatching MapMatchingConfig
+	Prediction  PredictionConfig
+	TenantHooks TenantHookConfig
+	Regions     RegionConfig
+	OwnerAlerts OwnerAlertConfig
+	Backpressure BackpressureConfig
+	Pagination   PaginationConfig
+	ShareLinks   ShareLinkConfig
+	Auth         AuthConfig
+	Integrity    IntegrityConfig
+	Devices      DeviceConfig
+	Runtime      RuntimeMonitorConfig
+	Reaper       ReaperConfig
+	Dedup        DedupConfig
+	Quarantine   QuarantineConfig
+	Escrow       EscrowConfig
+	InFlight     InFlightConfig
+	SequenceGaps SequenceGapConfig
+	AccessLog    AccessLogConfig
+	InlineGeofences InlineGeofenceConfig
+
+	// settings holds the raw value of every setting the configuration was
+	// loaded from, keyed by environment variable, for comparing reloads.
+	settings map[string]string
+}
+
+// ------------------------
+// Validate Method
+// ------------------------
+//
+// Validate performs comprehensive validation on all configuration fields.
+// It aggregates any errors found and returns them as a single error. If no
+// issues are found, it returns nil.
+//
+// Returns:
+//   error: A descriptive error if any validation checks fail, or nil otherwise.
+//
+func (c *Config) Validate() error {
+	var validationErrs []string
+
+	// ------------------------
+	// MQTT Validation
+	// ------------------------
+	if strings.TrimSpace(c.MQTT.Host) == "" {
+		validationErrs = append(validationErrs, "MQTT host is empty")
+	}
+	if c.MQTT.Port <= 0 || c.MQTT.Port > 65535 {
+		validationErrs = append(validationErrs, fmt.Sprintf("MQTT port %d is out of valid range", c.MQTT.Port))
+	}
+	if c.MQTT.ConnectionTimeout <= 0 {
+		validationErrs = append(validationErrs, "MQTT connection timeout must be greater than zero")
+	}
+	if c.MQTT.KeepAlive < 0 {
+		validationErrs = append(validationErrs, "MQTT keep-alive cannot be negative")
+	}
+	for _, class := range []struct {
+		name  string
+		level int
+	}{
+		{"location", c.MQTT.QoS.Location},
+		{"control", c.MQTT.QoS.Control},
+		{"alert", c.MQTT.QoS.Alert},
+		{"summary", c.MQTT.QoS.Summary},
+	} {
+		if class.level < 0 || class.level > 2 {
+			validationErrs = append(validationErrs, fmt.Sprintf("MQTT %s QoS %d is invalid; must be 0, 1, or 2", class.name, class.level))
+		}
+	}
+	if c.MQTT.RetryInterval < 0 {
+		validationErrs = append(validationErrs, "MQTT retry interval cannot be negative")
+	}
+	if c.MQTT.DispatchWorkers < 1 {
+		validationErrs = append(validationErrs, fmt.Sprintf("MQTT dispatch workers %d is invalid; must be at least 1", c.MQTT.DispatchWorkers))
+	}
+	if c.MQTT.DispatchQueueSize < 1 {
+		validationErrs = append(validationErrs, fmt.Sprintf("MQTT dispatch queue size %d is invalid; must be at least 1", c.MQTT.DispatchQueueSize))
+	}
+	if c.MQTT.BreakerFailures < 1 {
+		validationErrs = append(validationErrs, fmt.Sprintf("MQTT breaker failures %d is invalid; must be at least 1", c.MQTT.BreakerFailures))
+	}
+	if c.MQTT.BreakerTimeout <= 0 {
+		validationErrs = append(validationErrs, fmt.Sprintf("MQTT breaker timeout %s is invalid; must be positive", c.MQTT.BreakerTimeout))
+	}
+	if c.MQTT.FallbackQueueSize < 0 {
+		validationErrs = append(validationErrs, fmt.Sprintf("MQTT fallback queue size %d is invalid; must be 0 (no queue) or positive", c.MQTT.FallbackQueueSize))
+	}
+	if c.MQTT.FallbackMaxAge <= 0 {
+		validationErrs = append(validationErrs, fmt.Sprintf("MQTT fallback max age %s is invalid; must be positive", c.MQTT.FallbackMaxAge))
+	}
+	if c.MQTT.LocationExpiry < 0 {
+		validationErrs = append(validationErrs, fmt.Sprintf("MQTT location expiry %s is invalid; must be 0 (no expiry) or positive", c.MQTT.LocationExpiry))
+	}
+	if strings.TrimSpace(c.MQTT.ClientID) == "" {
+		validationErrs = append(validationErrs, "MQTT client ID is empty")
+	}
+	if strings.ContainsAny(c.MQTT.TopicPrefix, "+#") || strings.HasPrefix(c.MQTT.TopicPrefix, "$") {
+		validationErrs = append(validationErrs, fmt.Sprintf("MQTT topic prefix %q is invalid; must not contain wildcards or start with $", c.MQTT.TopicPrefix))
+	}
+	if c.MQTT.SharedGroup != "" {
+		if strings.ContainsAny(c.MQTT.SharedGroup, "/+#") {
+			validationErrs = append(validationErrs, fmt.Sprintf("MQTT shared group %q is invalid; must not contain /, + or #", c.MQTT.SharedGroup))
+		}
+		if len(c.MQTT.SharedTopics) == 0 {
+			validationErrs = append(validationErrs, "MQTT shared topics must not be empty when a shared group is set")
+		}
+	}
+
+	// ------------------------
+	// Database Validation
+	// ------------------------
+	if strings.TrimSpace(c.Database.Host) == "" && len(c.Database.Endpoints) == 0 {
+		validationErrs = append(validationErrs, "DB host is empty")
+	}
+	for _, ep := range c.Database.Endpoints {
+		if ep.Zone == "" || ep.Host == "" || ep.Port <= 0 || ep.Port > 65535 {
+			validationErrs = append(validationErrs, fmt.Sprintf("DB endpoint %s=%s:%d is invalid; must be zone=host:port", ep.Zone, ep.Host, ep.Port))
+		}
+	}
+	if len(c.Database.Endpoints) > 0 && c.Database.EndpointHealthInterval <= 0 {
+		validationErrs = append(validationErrs, "DB endpoint health interval must be greater than zero")
+	}
+	if c.Database.Zone != "" && len(c.Database.Endpoints) > 0 {
+		zoneFound := false
+		for _, ep := range c.Database.Endpoints {
+			zoneFound = zoneFound || ep.Zone == c.Database.Zone
+		}
+		if !zoneFound {
+			validationErrs = append(validationErrs, fmt.Sprintf("DB zone %q has no endpoint", c.Database.Zone))
+		}
+	}
+	if c.Database.Port <= 0 || c.Database.Port > 65535 {
+		validationErrs = append(validationErrs, fmt.Sprintf("DB port %d is out of valid range", c.Database.Port))
+	}
+	if strings.TrimSpace(c.Database.Database) == "" {
+		validationErrs = append(validationErrs, "DB database name is empty")
+	}
+	if c.Database.MaxConnections < 1 {
+		validationErrs = append(validationErrs, fmt.Sprintf("DB max connections %d is invalid; must be at least 1", c.Database.MaxConnections))
+	}
+	if c.Database.ConnectionTimeout < 0 {
+		validationErrs = append(validationErrs, "DB connection timeout cannot be negative")
+	}
+	if c.Database.MaxIdleConnections < 0 {
+		validationErrs = append(validationErrs, fmt.Sprintf("DB max idle connections %d cannot be negative", c.Database.MaxIdleConnections))
+	}
+	if c.Database.MaxConnectionLifetime < 0 {
+		validationErrs = append(validationErrs, "DB max connection lifetime cannot be negative")
+	}
+	if strings.TrimSpace(c.Database.Schema) == "" {
+		validationErrs = append(validationErrs, "DB schema cannot be empty")
+	}
+	if c.Database.PoolStatsInterval <= 0 {
+		validationErrs = append(validationErrs, "DB pool stats interval must be greater than zero")
+	}
+	if c.Database.AcquireWaitThreshold <= 0 {
+		validationErrs = append(validationErrs, "DB acquire wait threshold must be greater than zero")
+	}
+	if c.Database.PoolAutoTuneHour < 0 || c.Database.PoolAutoTuneHour > 23 {
+		validationErrs = append(validationErrs, fmt.Sprintf("DB pool auto-tune hour %d is out of range [0, 23]", c.Database.PoolAutoTuneHour))
+	}
+	if c.Database.EventsChunkInterval <= 0 || c.Database.SessionsChunkInterval <= 0 {
+		validationErrs = append(validationErrs, "DB events and sessions chunk intervals must be positive")
+	}
+	if c.Database.EventRetention < 0 || c.Database.SessionRetention < 0 {
+		validationErrs = append(validationErrs, "DB events and sessions retention cannot be negative")
+	}
+
+	// ------------------------
+	// Service Validation
+	// ------------------------
+	if c.Service.GeofenceRadius <= 0 {
+		validationErrs = append(validationErrs, fmt.Sprintf("service geofence radius %f must be positive", c.Service.GeofenceRadius))
+	}
+	if c.Service.LocationUpdateInterval <= 0 {
+		validationErrs = append(validationErrs, "service location update interval must be greater than zero")
+	}
+	if c.Service.SessionTimeout <= 0 {
+		validationErrs = append(validationErrs, "service session timeout must be greater than zero")
+	}
+	if c.Service.MaxConcurrentSessions < 0 {
+		validationErrs = append(validationErrs, fmt.Sprintf("service max concurrent sessions %d cannot be negative", c.Service.MaxConcurrentSessions))
+	}
+	if c.Service.MinAccuracy < 0 {
+		validationErrs = append(validationErrs, fmt.Sprintf("service minimum accuracy %f cannot be negative", c.Service.MinAccuracy))
+	}
+	if c.Service.MaxLocationHistory < 0 {
+		validationErrs = append(validationErrs, fmt.Sprintf("service max location history %d cannot be negative", c.Service.MaxLocationHistory))
+	}
+	if c.Service.StaleLocationThreshold < 0 {
+		validationErrs = append(validationErrs, "service stale location threshold cannot be negative")
+	}
+	if c.Service.LocationHistoryMode != "bounded" && c.Service.LocationHistoryMode != "windowed" {
+		validationErrs = append(validationErrs, fmt.Sprintf("service location history mode %q is invalid; must be bounded or windowed", c.Service.LocationHistoryMode))
+	}
+	if c.Service.SummaryDistance != "haversine" && c.Service.SummaryDistance != "geodesic" {
+		validationErrs = append(validationErrs, fmt.Sprintf("service summary distance %q is invalid; must be haversine or geodesic", c.Service.SummaryDistance))
+	}
+	if c.Service.CompletedSessionRetention <= 0 {
+		validationErrs = append(validationErrs, "service completed session retention must be positive")
+	}
+	if _, _, err := ParseRateLimit(c.Service.RateLimit); err != nil {
+		validationErrs = append(validationErrs, fmt.Sprintf("service rate limit %q is invalid: %v", c.Service.RateLimit, err))
+	}
+	if c.Service.ConfigPollInterval < 0 {
+		validationErrs = append(validationErrs, "service config poll interval cannot be negative")
+	}
+
+	// ------------------------
+	// Replication Validation
+	// ------------------------
+	if c.Replication.Enabled {
+		if strings.TrimSpace(c.Replication.Region) == "" {
+			validationErrs = append(validationErrs, "replication region is empty while replication is enabled")
+		}
+		if len(c.Replication.PeerRegions) == 0 {
+			validationErrs = append(validationErrs, "replication requires at least one peer region")
+		}
+		for _, peer := range c.Replication.PeerRegions {
+			if peer == c.Replication.Region {
+				validationErrs = append(validationErrs, fmt.Sprintf("replication peer region %s matches the local region", peer))
+			}
+		}
+	}
+
+	// ------------------------
+	// API Validation
+	// ------------------------
+	if c.API.ValidateResponses && strings.TrimSpace(c.API.SpecPath) == "" {
+		validationErrs = append(validationErrs, "API response validation requires an OpenAPI spec path")
+	}
+	if c.API.CompressionMinBytes < 0 {
+		validationErrs = append(validationErrs, "API compression minimum size must not be negative")
+	}
+
+	// ------------------------
+	// Stream Validation
+	// ------------------------
+	if c.Stream.BufferSize < 1 {
+		validationErrs = append(validationErrs, fmt.Sprintf("stream buffer size %d is invalid; must be at least 1", c.Stream.BufferSize))
+	}
+	if c.Stream.BufferTTL <= 0 {
+		validationErrs = append(validationErrs, "stream buffer TTL must be greater than zero")
+	}
+	if c.Stream.RedisDB < 0 {
+		validationErrs = append(validationErrs, fmt.Sprintf("stream Redis DB %d cannot be negative", c.Stream.RedisDB))
+	}
+	if c.Stream.MaxFrameAge <= 0 {
+		validationErrs = append(validationErrs, "stream max frame age must be greater than zero")
+	}
+	if _, ok := c.Stream.Tiers[DefaultStreamTier]; ok {
+		validationErrs = append(validationErrs, fmt.Sprintf("stream tier %q is reserved; configure it with STREAM_BUFFER_SIZE and STREAM_BUFFER_MAX_AGE", DefaultStreamTier))
+	}
+	for name, limits := range c.Stream.Tiers {
+		if limits.BufferSize < 1 {
+			validationErrs = append(validationErrs, fmt.Sprintf("stream tier %q buffer size %d is invalid; must be at least 1", name, limits.BufferSize))
+		}
+		if limits.MaxFrameAge <= 0 {
+			validationErrs = append(validationErrs, fmt.Sprintf("stream tier %q max frame age must be greater than zero", name))
+		}
+	}
+	if len(c.Stream.CaptureSessions) > 0 && c.Stream.CaptureDir == "" {
+		validationErrs = append(validationErrs, "stream capture sessions require STREAM_CAPTURE_DIR")
+	}
+	if c.Stream.ThrottleMaxInterval <= 0 {
+		validationErrs = append(validationErrs, "stream throttle max interval must be greater than zero")
+	}
+	if c.Stream.ThrottleAutoRTT < 0 {
+		validationErrs = append(validationErrs, "stream throttle auto RTT cannot be negative")
+	}
+	if c.Stream.ThrottleAutoRTT > 0 && (c.Stream.ThrottleAutoInterval <= 0 || c.Stream.ThrottleAutoInterval > c.Stream.ThrottleMaxInterval) {
+		validationErrs = append(validationErrs, fmt.Sprintf("stream throttle auto interval %s is invalid; must be greater than zero and at most the max interval %s",
+			c.Stream.ThrottleAutoInterval, c.Stream.ThrottleMaxInterval))
+	}
+	if c.Stream.SubscriberQueueSize < 1 {
+		validationErrs = append(validationErrs, "stream subscriber queue size must be at least 1")
+	}
+	if c.Stream.SubscriberWriteTimeout <= 0 {
+		validationErrs = append(validationErrs, "stream subscriber write timeout must be greater than zero")
+	}
+	if c.Stream.DrainTimeout <= 0 {
+		validationErrs = append(validationErrs, "stream drain timeout must be greater than zero")
+	}
+
+	// ------------------------
+	// Metrics Validation
+	// ------------------------
+	if c.Metrics.MaxLabelValues < 1 {
+		validationErrs = append(validationErrs, fmt.Sprintf("metrics max label values %d is invalid; must be at least 1", c.Metrics.MaxLabelValues))
+	}
+
+	// ------------------------
+	// Presence Validation
+	// ------------------------
+	if c.Presence.Enabled {
+		if c.Presence.Timeout <= 0 {
+			validationErrs = append(validationErrs, "presence timeout must be greater than zero")
+		}
+		if c.Presence.SweepInterval <= 0 || c.Presence.SweepInterval > c.Presence.Timeout {
+			validationErrs = append(validationErrs, fmt.Sprintf("presence sweep interval %s is invalid; must be positive and no longer than the timeout", c.Presence.SweepInterval))
+		}
+	}
+
+	// ------------------------
+	// Fitness Validation
+	// ------------------------
+	if c.Fitness.AutoUploadEnabled {
+		if strings.TrimSpace(c.Fitness.StravaClientID) == "" || strings.TrimSpace(c.Fitness.StravaClientSecret) == "" {
+			validationErrs = append(validationErrs, "fitness auto-upload requires the Strava client ID and secret")
+		}
+		if c.Fitness.UploadTimeout <= 0 {
+			validationErrs = append(validationErrs, "fitness upload timeout must be greater than zero")
+		}
+	}
+
+	// ------------------------
+	// Geocoding Validation
+	// ------------------------
+	switch c.Geocoding.Provider {
+	case "":
+	case "nominatim":
+		if strings.TrimSpace(c.Geocoding.UserAgent) == "" {
+			validationErrs = append(validationErrs, "nominatim geocoding requires a user agent identifying the application")
+		}
+	case "google", "mapbox":
+		if strings.TrimSpace(c.Geocoding.APIKey) == "" {
+			validationErrs = append(validationErrs, fmt.Sprintf("%s geocoding requires an API key", c.Geocoding.Provider))
+		}
+	default:
+		validationErrs = append(validationErrs, fmt.Sprintf("geocoding provider %q is invalid; must be nominatim, google or mapbox", c.Geocoding.Provider))
+	}
+	if c.Geocoding.Provider != "" {
+		if c.Geocoding.Timeout <= 0 {
+			validationErrs = append(validationErrs, "geocoding timeout must be greater than zero")
+		}
+		if c.Geocoding.RateLimit <= 0 {
+			validationErrs = append(validationErrs, "geocoding rate limit must be greater than zero")
+		}
+		if c.Geocoding.CacheSize < 1 {
+			validationErrs = append(validationErrs, fmt.Sprintf("geocoding cache size %d is invalid; must be at least 1", c.Geocoding.CacheSize))
+		}
+		if c.Geocoding.CacheTTL <= 0 {
+			validationErrs = append(validationErrs, "geocoding cache TTL must be greater than zero")
+		}
+	}
+
+	// ------------------------
+	// Dog Profile Validation
+	// ------------------------
+	if c.DogProfiles.PetsServiceURL != "" {
+		if u, err := url.Parse(c.DogProfiles.PetsServiceURL); err != nil || u.Scheme == "" || u.Host == "" {
+			validationErrs = append(validationErrs, fmt.Sprintf("pets service URL %q is invalid; must be an absolute URL", c.DogProfiles.PetsServiceURL))
+		}
+		if c.DogProfiles.Timeout <= 0 {
+			validationErrs = append(validationErrs, "dog profile timeout must be greater than zero")
+		}
+		if c.DogProfiles.CacheSize < 1 {
+			validationErrs = append(validationErrs, fmt.Sprintf("dog profile cache size %d is invalid; must be at least 1", c.DogProfiles.CacheSize))
+		}
+		if c.DogProfiles.CacheTTL <= 0 {
+			validationErrs = append(validationErrs, "dog profile cache TTL must be greater than zero")
+		}
+	}
+
+	// ------------------------
+	// Batching Validation
+	// ------------------------
+	if c.Batching.Enabled {
+		if c.Batching.MaxPoints < 1 {
+			validationErrs = append(validationErrs, fmt.Sprintf("batching max points %d is invalid; must be at least 1", c.Batching.MaxPoints))
+		}
+		if c.Batching.MinWindow <= 0 || c.Batching.MaxWindow < c.Batching.MinWindow {
+			validationErrs = append(validationErrs, fmt.Sprintf("batching window [%s, %s] is invalid; min must be positive and no greater than max", c.Batching.MinWindow, c.Batching.MaxWindow))
+		}
+	}
+
+	// ------------------------
+	// Analytics Validation
+	// ------------------------
+	if c.Analytics.RoutePopularityEnabled && c.Analytics.RouteMinWalks < 1 {
+		validationErrs = append(validationErrs, fmt.Sprintf("analytics route min walks %d is invalid; must be at least 1", c.Analytics.RouteMinWalks))
+	}
+	if c.Analytics.DemandEnabled && c.Analytics.DemandMinStarts < 1 {
+		validationErrs = append(validationErrs, fmt.Sprintf("analytics demand min starts %d is invalid; must be at least 1", c.Analytics.DemandMinStarts))
+	}
+
+	// ------------------------
+	// Abuse Protection Validation
+	// ------------------------
+	if c.Abuse.Enabled {
+		if c.Abuse.MaxConnsPerIP < 1 {
+			validationErrs = append(validationErrs, fmt.Sprintf("abuse max connections per IP %d is invalid; must be at least 1", c.Abuse.MaxConnsPerIP))
+		}
+		if c.Abuse.ConnRatePerMinute < 1 || c.Abuse.ConnBurst < 1 {
+			validationErrs = append(validationErrs, "abuse connection rate and burst must be at least 1")
+		}
+		if c.Abuse.AuthFailureLimit < 1 || c.Abuse.AuthFailureWindow <= 0 || c.Abuse.BanDuration <= 0 {
+			validationErrs = append(validationErrs, "abuse auth failure limit, window and ban duration must be positive")
+		}
+		for _, entry := range c.Abuse.Allowlist {
+			if _, _, err := net.ParseCIDR(entry); err != nil && net.ParseIP(entry) == nil {
+				validationErrs = append(validationErrs, fmt.Sprintf("abuse allowlist entry %q is not an IP or CIDR", entry))
+			}
+		}
+	}
+
+	// ------------------------
+	// SLO Validation
+	// ------------------------
+	if c.SLO.Enabled {
+		if c.SLO.LatencyThreshold <= 0 {
+			validationErrs = append(validationErrs, fmt.Sprintf("SLO latency threshold %s is invalid; must be positive", c.SLO.LatencyThreshold))
+		}
+		if c.SLO.LatencyTarget <= 0 || c.SLO.LatencyTarget >= 1 {
+			validationErrs = append(validationErrs, fmt.Sprintf("SLO latency target %g is invalid; must be between 0 and 1 exclusive", c.SLO.LatencyTarget))
+		}
+		if c.SLO.BudgetPeriod < time.Hour {
+			validationErrs = append(validationErrs, fmt.Sprintf("SLO budget period %s is invalid; must be at least 1h", c.SLO.BudgetPeriod))
+		}
+	}
+
+	// ------------------------
+	// Migration Validation
+	// ------------------------
+	if c.Migration.DualWriteEnabled {
+		if strings.TrimSpace(c.Migration.Shadow.Host) == "" {
+			validationErrs = append(validationErrs, "dual-write requires a shadow database host")
+		}
+		if c.Migration.Shadow.Host == c.Database.Host && c.Migration.Shadow.Port == c.Database.Port &&
+			c.Migration.Shadow.Database == c.Database.Database && c.Migration.Shadow.Schema == c.Database.Schema {
+			validationErrs = append(validationErrs, "dual-write shadow database must differ from the primary")
+		}
+		if c.Migration.ReadSampleRate < 0 || c.Migration.ReadSampleRate > 1 {
+			validationErrs = append(validationErrs, fmt.Sprintf("dual-write read sample rate %g is invalid; must be between 0 and 1", c.Migration.ReadSampleRate))
+		}
+	}
+
+	// ------------------------
+	// Flags Validation
+	// ------------------------
+	for name, rollout := range c.Flags.Rollouts {
+		if name == "" {
+			validationErrs = append(validationErrs, "feature flag name is empty")
+		}
+		if rollout.Percentage < 0 || rollout.Percentage > 100 {
+			validationErrs = append(validationErrs, fmt.Sprintf("feature flag %q percentage %g is invalid; must be between 0 and 100", name, rollout.Percentage))
+		}
+		if rollout.By != FlagBySession && rollout.By != FlagByTenant {
+			validationErrs = append(validationErrs, fmt.Sprintf("feature flag %q bucketing %q is invalid; must be %q or %q", name, rollout.By, FlagBySession, FlagByTenant))
+		}
+	}
+	if !logLevels[c.Flags.LogLevel] {
+		validationErrs = append(validationErrs, fmt.Sprintf("log level %q is invalid", c.Flags.LogLevel))
+	}
+	if c.Flags.RefreshInterval <= 0 {
+		validationErrs = append(validationErrs, "feature flag refresh interval must be positive")
+	}
+
+	// ------------------------
+	// Affinity Validation
+	// ------------------------
+	if c.Affinity.Secret != "" {
+		if c.Affinity.NodeID == "" {
+			validationErrs = append(validationErrs, "affinity node ID must not be empty when affinity is enabled")
+		}
+		if u, err := url.Parse(c.Affinity.FallbackURL); err != nil || u.Scheme == "" || u.Host == "" {
+			validationErrs = append(validationErrs, fmt.Sprintf("affinity fallback URL %q must be an absolute URL", c.Affinity.FallbackURL))
+		}
+		if c.Affinity.HandoffNode != "" && c.Affinity.HandoffNode == c.Affinity.NodeID {
+			validationErrs = append(validationErrs, "affinity handoff node must differ from this node")
+		}
+	}
+	if c.Affinity.DrainPeriod < 0 {
+		validationErrs = append(validationErrs, "affinity drain period cannot be negative")
+	}
+
+	// ------------------------
+	// Degradation Validation
+	// ------------------------
+	if c.Degradation.Enabled {
+		if strings.TrimSpace(c.Degradation.SpoolDir) == "" {
+			validationErrs = append(validationErrs, "degradation spool directory must not be empty when degradation is enabled")
+		}
+		if c.Degradation.SpoolMaxBatches < 1 {
+			validationErrs = append(validationErrs, fmt.Sprintf("degradation spool max batches %d is invalid; must be at least 1", c.Degradation.SpoolMaxBatches))
+		}
+		if c.Degradation.DrainInterval <= 0 {
+			validationErrs = append(validationErrs, "degradation drain interval must be positive")
+		}
+		if c.Degradation.CacheEntries < 0 {
+			validationErrs = append(validationErrs, "degradation cache entries cannot be negative")
+		}
+		if c.Degradation.CacheMaxAge <= 0 {
+			validationErrs = append(validationErrs, "degradation cache max age must be positive")
+		}
+	}
+
+	// ------------------------
+	// Diagnostics Validation
+	// ------------------------
+	if c.Diagnostics.MaxBundles < 0 {
+		validationErrs = append(validationErrs, "diagnostics max bundles cannot be negative")
+	}
+	if c.Diagnostics.TrailSize < 1 {
+		validationErrs = append(validationErrs, fmt.Sprintf("diagnostics trail size %d is invalid; must be at least 1", c.Diagnostics.TrailSize))
+	}
+
+	// ------------------------
+	// Beacon Validation
+	// ------------------------
+	if c.Beacons.MinRSSI < -127 || c.Beacons.MinRSSI > 0 {
+		validationErrs = append(validationErrs, fmt.Sprintf("beacon min RSSI %d is invalid; must be between -127 and 0 dBm", c.Beacons.MinRSSI))
+	}
+
+	// ------------------------
+	// Backpressure Validation
+	// ------------------------
+	if c.Backpressure.Enabled {
+		if len(c.Backpressure.LowPriorityTopics) == 0 {
+			validationErrs = append(validationErrs, "backpressure requires at least one low-priority topic")
+		}
+		if c.Backpressure.LowWatermark <= 0 || c.Backpressure.LowWatermark >= c.Backpressure.HighWatermark || c.Backpressure.HighWatermark > 1 {
+			validationErrs = append(validationErrs, fmt.Sprintf("backpressure watermarks low %.2f and high %.2f are invalid; must satisfy 0 < low < high <= 1", c.Backpressure.LowWatermark, c.Backpressure.HighWatermark))
+		}
+		if c.Backpressure.CheckInterval <= 0 {
+			validationErrs = append(validationErrs, "backpressure check interval must be positive")
+		}
+		if c.Backpressure.MaxInFlight < 1 {
+			validationErrs = append(validationErrs, fmt.Sprintf("backpressure max in-flight %d is invalid; must be at least 1", c.Backpressure.MaxInFlight))
+		}
+		if c.Backpressure.MaxBufferedPoints < 1 {
+			validationErrs = append(validationErrs, fmt.Sprintf("backpressure max buffered points %d is invalid; must be at least 1", c.Backpressure.MaxBufferedPoints))
+		}
+	}
+
+	// ------------------------
+	// Integrity Validation
+	// ------------------------
+	if c.Integrity.Enabled {
+		if !c.Service.EventSourcingEnabled {
+			validationErrs = append(validationErrs, "integrity checks require event sourcing to be enabled")
+		}
+		if c.Integrity.Interval <= 0 {
+			validationErrs = append(validationErrs, "integrity check interval must be positive")
+		}
+		if c.Integrity.Lookback <= 0 {
+			validationErrs = append(validationErrs, "integrity check lookback must be positive")
+		}
+		if c.Integrity.SampleSize < 1 {
+			validationErrs = append(validationErrs, fmt.Sprintf("integrity check sample size %d is invalid; must be at least 1", c.Integrity.SampleSize))
+		}
+		if c.Integrity.DistanceTolerance < 0 {
+			validationErrs = append(validationErrs, fmt.Sprintf("integrity check distance tolerance %.2f is invalid; cannot be negative", c.Integrity.DistanceTolerance))
+		}
+	}
+
+	// ------------------------
+	// Runtime Monitor Validation
+	// ------------------------
+	if c.Runtime.Enabled {
+		if c.Runtime.Interval <= 0 {
+			validationErrs = append(validationErrs, "runtime monitor interval must be positive")
+		}
+		if c.Runtime.SustainFor < 0 {
+			validationErrs = append(validationErrs, "runtime monitor sustain period cannot be negative")
+		}
+		if c.Runtime.MaxGoroutines < 0 {
+			validationErrs = append(validationErrs, fmt.Sprintf("runtime monitor max goroutines %d is invalid; cannot be negative", c.Runtime.MaxGoroutines))
+		}
+		if c.Runtime.MaxHeapMB < 0 {
+			validationErrs = append(validationErrs, fmt.Sprintf("runtime monitor max heap %d MB is invalid; cannot be negative", c.Runtime.MaxHeapMB))
+		}
+		for queue, threshold := range c.Runtime.QueueThresholds {
+			if !monitoredQueues[queue] {
+				validationErrs = append(validationErrs, fmt.Sprintf("runtime monitor queue %q is unknown; must be mqtt_inflight, coalesced_points, spool or stream_hub", queue))
+			} else if threshold < 1 {
+				validationErrs = append(validationErrs, fmt.Sprintf("runtime monitor threshold for queue %s is invalid; must be a positive integer", queue))
+			}
+		}
+	}
+
+	// ------------------------
+	// Reaper Validation
+	// ------------------------
+	if c.Reaper.Enabled && c.Reaper.Interval <= 0 {
+		validationErrs = append(validationErrs, "session reaper interval must be positive")
+	}
+
+	// ------------------------
+	// Dedup Validation
+	// ------------------------
+	if c.Dedup.Enabled {
+		if c.Dedup.Capacity < 1 {
+			validationErrs = append(validationErrs, fmt.Sprintf("location dedup capacity %d is invalid; must be at least 1", c.Dedup.Capacity))
+		}
+		if c.Dedup.FalsePositiveRate <= 0 || c.Dedup.FalsePositiveRate >= 1 {
+			validationErrs = append(validationErrs, fmt.Sprintf("location dedup false positive rate %g is invalid; must be within (0, 1)", c.Dedup.FalsePositiveRate))
+		}
+	}
+
+	// ------------------------
+	// Escrow Validation
+	// ------------------------
+	if c.Escrow.Enabled {
+		if !c.Stream.EncryptionEnabled {
+			validationErrs = append(validationErrs, "key escrow requires stream encryption to be enabled")
+		}
+		if key, err := base64.StdEncoding.DecodeString(c.Escrow.PublicKey); err != nil || len(key) != 32 {
+			validationErrs = append(validationErrs, "key escrow public key must be a base64-encoded 32-byte X25519 key")
+		}
+		custodians := make(map[string]bool, len(c.Escrow.Custodians))
+		for _, custodian := range c.Escrow.Custodians {
+			custodians[custodian] = true
+		}
+		if len(custodians) < 2 || len(custodians) != len(c.Escrow.Custodians) {
+			validationErrs = append(validationErrs, "key escrow requires at least two distinct custodians")
+		}
+	}
+
+	// ------------------------
+	// Share Link Validation
+	// ------------------------
+	if c.ShareLinks.DefaultTTL <= 0 {
+		validationErrs = append(validationErrs, "share link default TTL must be greater than zero")
+	}
+	if c.ShareLinks.MaxTTL < c.ShareLinks.DefaultTTL {
+		validationErrs = append(validationErrs, fmt.Sprintf("share link max TTL %s is invalid; must be at least the default TTL %s", c.ShareLinks.MaxTTL, c.ShareLinks.DefaultTTL))
+	}
+
+	// ------------------------
+	// In-Flight Guard Validation
+	// ------------------------
+	if c.InFlight.MaxLocationRequests < 0 {
+		validationErrs = append(validationErrs, fmt.Sprintf("max in-flight location requests %d is invalid; must be 0 (no ceiling) or positive", c.InFlight.MaxLocationRequests))
+	}
+	if c.InFlight.RetryAfter <= 0 {
+		validationErrs = append(validationErrs, fmt.Sprintf("location retry-after %s is invalid; must be positive", c.InFlight.RetryAfter))
+	}
+	if c.InFlight.DrainTimeout <= 0 {
+		validationErrs = append(validationErrs, fmt.Sprintf("location drain timeout %s is invalid; must be positive", c.InFlight.DrainTimeout))
+	}
+
+	// ------------------------
+	// Sequence Gap Validation
+	// ------------------------
+	if c.SequenceGaps.Enabled {
+		if c.SequenceGaps.ConfirmAfter <= 0 {
+			validationErrs = append(validationErrs, fmt.Sprintf("sequence gap confirm-after %s is invalid; must be positive", c.SequenceGaps.ConfirmAfter))
+		}
+		if c.SequenceGaps.GPSGapThreshold <= 0 {
+			validationErrs = append(validationErrs, fmt.Sprintf("sequence GPS gap threshold %s is invalid; must be positive", c.SequenceGaps.GPSGapThreshold))
+		}
+	}
+
+	// ------------------------
+	// Access Log Validation
+	// ------------------------
+	if c.AccessLog.Enabled && strings.TrimSpace(c.AccessLog.ActorHeader) == "" {
+		validationErrs = append(validationErrs, "walk access log requires an actor header")
+	}
+
+	// ------------------------
+	// Inline Geofence Validation
+	// ------------------------
+	if c.InlineGeofences.Enabled && c.InlineGeofences.RefreshInterval <= 0 {
+		validationErrs = append(validationErrs, fmt.Sprintf("inline geofence refresh interval %s is invalid; must be positive", c.InlineGeofences.RefreshInterval))
+	}
+
+	// ------------------------
+	// Device Validation
+	// ------------------------
+	if c.Devices.Enabled {
+		if strings.TrimSpace(c.Devices.AttestationSecret) == "" {
+			validationErrs = append(validationErrs, "device pairing requires an attestation secret")
+		}
+		if !devicePolicies[c.Devices.DefaultPolicy] {
+			validationErrs = append(validationErrs, fmt.Sprintf("device default policy %q is invalid; must be allow, flag or reject", c.Devices.DefaultPolicy))
+		}
+		for tenant, policy := range c.Devices.TenantPolicies {
+			if tenant == "" || !devicePolicies[policy] {
+				validationErrs = append(validationErrs, fmt.Sprintf("device policy %q for tenant %q is invalid; must be allow, flag or reject", policy, tenant))
+			}
+		}
+	}
+
+	// ------------------------
+	// Region Validation
+	// ------------------------
+	if c.Regions.Enabled {
+		if c.Regions.MaxSpeedKmh < 0 {
+			validationErrs = append(validationErrs, "region base max speed cannot be negative")
+		}
+		if c.Regions.GeofenceRadiusKm < 0.1 || c.Regions.GeofenceRadiusKm > 5 {
+			validationErrs = append(validationErrs, fmt.Sprintf("region base geofence radius %.2f km is invalid; must be between 0.1 and 5", c.Regions.GeofenceRadiusKm))
+		}
+		if c.Regions.RetentionInterval <= 0 {
+			validationErrs = append(validationErrs, "region retention interval must be positive")
+		}
+	}
+
+	// ------------------------
+	// Smoothing Validation
+	// ------------------------
+	if c.Smoothing.Enabled {
+		if c.Smoothing.ProcessNoise <= 0 {
+			validationErrs = append(validationErrs, "smoothing process noise must be positive")
+		}
+		if c.Smoothing.ResetAfter < 0 {
+			validationErrs = append(validationErrs, "smoothing reset interval cannot be negative")
+		}
+	}
+
+	// ------------------------
+	// Map Matching Validation
+	// ------------------------
+	switch c.MapMatching.Provider {
+	case "":
+	case "osrm", "valhalla":
+		if u, err := url.Parse(c.MapMatching.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			validationErrs = append(validationErrs, fmt.Sprintf("map matching URL %q is invalid; must be an absolute URL", c.MapMatching.URL))
+		}
+		if c.MapMatching.Timeout <= 0 {
+			validationErrs = append(validationErrs, "map matching timeout must be greater than zero")
+		}
+		if c.MapMatching.ContextPoints < 1 {
+			validationErrs = append(validationErrs, fmt.Sprintf("map matching context points %d is invalid; must be at least 1", c.MapMatching.ContextPoints))
+		}
+		if c.MapMatching.MaxSnapMeters <= 0 {
+			validationErrs = append(validationErrs, "map matching max snap distance must be positive")
+		}
+	default:
+		validationErrs = append(validationErrs, fmt.Sprintf("map matching provider %q is invalid; must be osrm or valhalla", c.MapMatching.Provider))
+	}
+
+	// ------------------------
+	// Walk Prediction Validation
+	// ------------------------
+	switch c.Prediction.Mode {
+	case "":
+	case "endpoint", "heuristic":
+		if c.Prediction.Mode == "endpoint" {
+			if u, err := url.Parse(c.Prediction.URL); err != nil || u.Scheme == "" || u.Host == "" {
+				validationErrs = append(validationErrs, fmt.Sprintf("prediction URL %q is invalid; must be an absolute URL", c.Prediction.URL))
+			}
+		}
+		if c.Prediction.Timeout <= 0 {
+			validationErrs = append(validationErrs, "prediction timeout must be greater than zero")
+		}
+		if c.Prediction.HistoryWalks < 1 {
+			validationErrs = append(validationErrs, fmt.Sprintf("prediction history walks %d is invalid; must be at least 1", c.Prediction.HistoryWalks))
+		}
+		if c.Prediction.HistoryWindow <= 0 {
+			validationErrs = append(validationErrs, "prediction history window must be greater than zero")
+		}
+	default:
+		validationErrs = append(validationErrs, fmt.Sprintf("prediction mode %q is invalid; must be endpoint or heuristic", c.Prediction.Mode))
+	}
+
+	// ------------------------
+	// Tenant Hook Validation
+	// ------------------------
+	for _, hook := range c.TenantHooks.Hooks {
+		if hook.TenantID == "" {
+			validationErrs = append(validationErrs, "tenant point hooks must name a tenant")
+		}
+		if hook.EveryNPoints < 1 {
+			validationErrs = append(validationErrs, fmt.Sprintf("tenant %q point hook interval %d is invalid; must be at least 1", hook.TenantID, hook.EveryNPoints))
+		}
+		if u, err := url.Parse(hook.URL); err != nil || u.Scheme == "" || u.Host == "" {
+			validationErrs = append(validationErrs, fmt.Sprintf("tenant %q point hook URL %q is invalid; must be an absolute URL", hook.TenantID, hook.URL))
+		}
+	}
+	if len(c.TenantHooks.Hooks) > 0 && c.TenantHooks.Timeout <= 0 {
+		validationErrs = append(validationErrs, "tenant point hook timeout must be greater than zero")
+	}
+
+	// ------------------------
+	// ID Validation
+	// ------------------------
+	if !idFormats[c.IDs.Format] {
+		validationErrs = append(validationErrs, fmt.Sprintf("ID format %q is invalid; must be uuid, ulid or snowflake", c.IDs.Format))
+	}
+	if c.IDs.SnowflakeNodeID < 0 || c.IDs.SnowflakeNodeID > 1023 {
+		validationErrs = append(validationErrs, fmt.Sprintf("snowflake node ID %d is out of range [0, 1023]", c.IDs.SnowflakeNodeID))
+	}
+	if len(c.IDs.AcceptedFormats) > 0 {
+		generatedAccepted := false
+		for _, format := range c.IDs.AcceptedFormats {
+			if !idFormats[format] {
+				validationErrs = append(validationErrs, fmt.Sprintf("accepted ID format %q is invalid; must be uuid, ulid or snowflake", format))
+			}
+			generatedAccepted = generatedAccepted || format == c.IDs.Format
+		}
+		if !generatedAccepted {
+			validationErrs = append(validationErrs, fmt.Sprintf("accepted ID formats must include the generated format %q", c.IDs.Format))
+		}
+	}
+
+	// ------------------------
+	// Return Validation Errors
+	// ------------------------
+	if len(validationErrs) > 0 {
+		return fmt.Errorf("configuration validation failed:\n - %s", strings.Join(validationErrs, "\n - "))
+	}
+	return nil
+}
+
+// ------------------------
+// LoadConfig Function
+// ------------------------
+//
+// LoadConfig reads the necessary environment variables, applies defaults,
+// and returns a populated Config pointer. It ensures that all settings are
+// validated before returning. When CONFIG_FILE names a YAML or JSON file,
+// settings missing from the environment are taken from it, as described by
+// LoadConfigFile.
+//
+// Returns:
+//   *Config: Populated configuration struct if successful
+//   error:   Any error if configuration loading or validation fails
+//
+func LoadConfig() (*Config, error) {
+	return LoadConfigFile(getEnvWithDefault("CONFIG_FILE", ""))
+}
+
+// loadConfig populates and validates the configuration from the environment
+// and the active config file, if any.
+func loadConfig() (*Config, error) {
+	resolvedSettings = make(map[string]string)
+	defer func() { resolvedSettings = nil }()
+
+	cfg := &Config{
+		// --------------------------------
+		// MQTT Configuration
+		// --------------------------------
+		MQTT: MQTTConfig{
+			Host: getEnvWithDefault("MQTT_HOST", "localhost"),
+		},
+		Database: DBConfig{
+			Host: getEnvWithDefault("DB_HOST", "localhost"),
+		},
+		Service: ServiceConfig{},
+	}
+
+	// -------------------------------
+	// Parse numeric/bool/duration envs
+	// for MQTT
+	// -------------------------------
+	mqttPortStr := getEnvWithDefault("MQTT_PORT", strconv.Itoa(DefaultMQTTPort))
+	mqttPort, err := strconv.Atoi(mqttPortStr)
+	if err != nil {
+		mqttPort = DefaultMQTTPort
+	}
+	cfg.MQTT.Port = mqttPort
+
+	cfg.MQTT.Username = getEnvWithDefault("MQTT_USER", "")
+	cfg.MQTT.Password = getEnvWithDefault("MQTT_PASS", "")
+
+	mqttTLSStr := getEnvWithDefault("MQTT_TLS_ENABLED", "false")
+	mqttTLSVal, err := strconv.ParseBool(mqttTLSStr)
+	if err != nil {
+		mqttTLSVal = false
+	}
+	cfg.MQTT.TLSEnabled = mqttTLSVal
+
+	mqttConnectionTimeoutStr := getEnvWithDefault("MQTT_CONNECTION_TIMEOUT", "10s")
+	mqttConnTimeout, err := time.ParseDuration(mqttConnectionTimeoutStr)
+	if err != nil {
+		mqttConnTimeout = 10 * time.Second
+	}
+	cfg.MQTT.ConnectionTimeout = mqttConnTimeout
+
+	mqttKeepAliveStr := getEnvWithDefault("MQTT_KEEP_ALIVE", "60s")
+	mqttKeepAlive, err := time.ParseDuration(mqttKeepAliveStr)
+	if err != nil {
+		mqttKeepAlive = 60 * time.Second
+	}
+	cfg.MQTT.KeepAlive = mqttKeepAlive
+
+	// MQTT_QOS is the level of every class not configured on its own, except
+	// alerts, which default to exactly-once delivery.
+	mqttQoSStr := getEnvWithDefault("MQTT_QOS", "1")
+	mqttQoSVal, err := strconv.Atoi(mqttQoSStr)
+	if err != nil {
+		mqttQoSVal = 1
+	}
+	mqttQoSLocation, err := strconv.Atoi(getEnvWithDefault("MQTT_QOS_LOCATION", strconv.Itoa(mqttQoSVal)))
+	if err != nil {
+		mqttQoSLocation = mqttQoSVal
+	}
+	cfg.MQTT.QoS.Location = mqttQoSLocation
+	mqttQoSControl, err := strconv.Atoi(getEnvWithDefault("MQTT_QOS_CONTROL", strconv.Itoa(mqttQoSVal)))
+	if err != nil {
+		mqttQoSControl = mqttQoSVal
+	}
+	cfg.MQTT.QoS.Control = mqttQoSControl
+	mqttQoSAlert, err := strconv.Atoi(getEnvWithDefault("MQTT_QOS_ALERT", "2"))
+	if err != nil {
+		mqttQoSAlert = 2
+	}
+	cfg.MQTT.QoS.Alert = mqttQoSAlert
+	mqttQoSSummary, err := strconv.Atoi(getEnvWithDefault("MQTT_QOS_SUMMARY", strconv.Itoa(mqttQoSVal)))
+	if err != nil {
+		mqttQoSSummary = mqttQoSVal
+	}
+	cfg.MQTT.QoS.Summary = mqttQoSSummary
+
+	mqttRetryIntervalStr := getEnvWithDefault("MQTT_RETRY_INTERVAL", "5s")
+	mqttRetryInterval, err := time.ParseDuration(mqttRetryIntervalStr)
+	if err != nil {
+		mqttRetryInterval = 5 * time.Second
+	}
+	cfg.MQTT.RetryInterval = mqttRetryInterval
+
+	mqttDispatchWorkersStr := getEnvWithDefault("MQTT_DISPATCH_WORKERS", "8")
+	mqttDispatchWorkers, err := strconv.Atoi(mqttDispatchWorkersStr)
+	if err != nil {
+		mqttDispatchWorkers = 8
+	}
+	cfg.MQTT.DispatchWorkers = mqttDispatchWorkers
+
+	mqttDispatchQueueStr := getEnvWithDefault("MQTT_DISPATCH_QUEUE_SIZE", "256")
+	mqttDispatchQueue, err := strconv.Atoi(mqttDispatchQueueStr)
+	if err != nil {
+		mqttDispatchQueue = 256
+	}
+	cfg.MQTT.DispatchQueueSize = mqttDispatchQueue
+
+	mqttBreakerFailures, err := strconv.Atoi(getEnvWithDefault("MQTT_BREAKER_FAILURES", "5"))
+	if err != nil {
+		mqttBreakerFailures = 5
+	}
+	cfg.MQTT.BreakerFailures = mqttBreakerFailures
+
+	mqttBreakerTimeout, err := time.ParseDuration(getEnvWithDefault("MQTT_BREAKER_TIMEOUT", "30s"))
+	if err != nil {
+		mqttBreakerTimeout = 30 * time.Second
+	}
+	cfg.MQTT.BreakerTimeout = mqttBreakerTimeout
+
+	mqttFallbackQueue, err := strconv.Atoi(getEnvWithDefault("MQTT_FALLBACK_QUEUE_SIZE", "1000"))
+	if err != nil {
+		mqttFallbackQueue = 1000
+	}
+	cfg.MQTT.FallbackQueueSize = mqttFallbackQueue
+
+	mqttFallbackMaxAge, err := time.ParseDuration(getEnvWithDefault("MQTT_FALLBACK_MAX_AGE", "2m"))
+	if err != nil {
+		mqttFallbackMaxAge = 2 * time.Minute
+	}
+	cfg.MQTT.FallbackMaxAge = mqttFallbackMaxAge
+
+	mqttLocationExpiry, err := time.ParseDuration(getEnvWithDefault("MQTT_LOCATION_EXPIRY", "0s"))
+	if err != nil {
+		mqttLocationExpiry = 0
+	}
+	cfg.MQTT.LocationExpiry = mqttLocationExpiry
+
+	mqttHostname, _ := os.Hostname()
+	cfg.MQTT.ClientID = getEnvWithDefault("MQTT_CLIENT_ID", "tracking-service-"+mqttHostname)
+	cfg.MQTT.TopicPrefix = strings.Trim(getEnvWithDefault("MQTT_TOPIC_PREFIX", ""), "/")
+	cfg.MQTT.SharedGroup = getEnvWithDefault("MQTT_SHARED_GROUP", "")
+	cfg.MQTT.SharedTopics = splitAndTrim(getEnvWithDefault("MQTT_SHARED_TOPICS", ""))
+
+	// -------------------------------
+	// Parse numeric/bool/duration envs
+	// for Database
+	// -------------------------------
+	dbPortStr := getEnvWithDefault("DB_PORT", strconv.Itoa(DefaultDBPort))
+	dbPort, err := strconv.Atoi(dbPortStr)
+	if err != nil {
+		dbPort = DefaultDBPort
+	}
+	cfg.Database.Port = dbPort
+
+	cfg.Database.Database = getEnvWithDefault("DB_DATABASE", "tracking_db")
+	cfg.Database.Username = getEnvWithDefault("DB_USER", "")
+	cfg.Database.Password = getEnvWithDefault("DB_PASS", "")
+
+	dbMaxConnStr := getEnvWithDefault("DB_MAX_CONNECTIONS", strconv.Itoa(DefaultMaxConnections))
+	dbMaxConn, err := strconv.Atoi(dbMaxConnStr)
+	if err != nil {
+		dbMaxConn = DefaultMaxConnections
+	}
+	cfg.Database.MaxConnections = dbMaxConn
+
+	dbConnTimeoutStr := getEnvWithDefault("DB_CONNECTION_TIMEOUT", "5s")
+	dbConnTimeout, err := time.ParseDuration(dbConnTimeoutStr)
+	if err != nil {
+		dbConnTimeout = 5 * time.Second
+	}
+	cfg.Database.ConnectionTimeout = dbConnTimeout
+
+	dbMaxIdleConnStr := getEnvWithDefault("DB_MAX_IDLE_CONNECTIONS", "10")
+	dbMaxIdleConn, err := strconv.Atoi(dbMaxIdleConnStr)
+	if err != nil {
+		dbMaxIdleConn = 10
+	}
+	cfg.Database.MaxIdleConnections = dbMaxIdleConn
+
+	dbMaxLifetimeStr := getEnvWithDefault("DB_MAX_CONNECTION_LIFETIME", "60m")
+	dbMaxLifetime, err := time.ParseDuration(dbMaxLifetimeStr)
+	if err != nil {
+		dbMaxLifetime = 60 * time.Minute
+	}
+	cfg.Database.MaxConnectionLifetime = dbMaxLifetime
+	cfg.Database.Schema = getEnvWithDefault("DB_SCHEMA", "tracking")
+
+	dbPoolStatsIntervalStr := getEnvWithDefault("DB_POOL_STATS_INTERVAL", "15s")
+	dbPoolStatsInterval, err := time.ParseDuration(dbPoolStatsIntervalStr)
+	if err != nil {
+		dbPoolStatsInterval = 15 * time.Second
+	}
+	cfg.Database.PoolStatsInterval = dbPoolStatsInterval
+
+	dbAcquireWaitStr := getEnvWithDefault("DB_ACQUIRE_WAIT_THRESHOLD", "100ms")
+	dbAcquireWait, err := time.ParseDuration(dbAcquireWaitStr)
+	if err != nil {
+		dbAcquireWait = 100 * time.Millisecond
+	}
+	cfg.Database.AcquireWaitThreshold = dbAcquireWait
+
+	dbAutoTuneStr := getEnvWithDefault("DB_POOL_AUTO_TUNE", "false")
+	dbAutoTune, err := strconv.ParseBool(dbAutoTuneStr)
+	if err != nil {
+		dbAutoTune = false
+	}
+	cfg.Database.PoolAutoTune = dbAutoTune
+
+	dbAutoTuneHourStr := getEnvWithDefault("DB_POOL_AUTO_TUNE_HOUR", "3")
+	dbAutoTuneHour, err := strconv.Atoi(dbAutoTuneHourStr)
+	if err != nil {
+		dbAutoTuneHour = 3
+	}
+	cfg.Database.PoolAutoTuneHour = dbAutoTuneHour
+
+	dbEventsChunkStr := getEnvWithDefault("DB_EVENTS_CHUNK_INTERVAL", "168h")
+	dbEventsChunk, err := time.ParseDuration(dbEventsChunkStr)
+	if err != nil {
+		dbEventsChunk = 7 * 24 * time.Hour
+	}
+	cfg.Database.EventsChunkInterval = dbEventsChunk
+
+	dbSessionsChunkStr := getEnvWithDefault("DB_SESSIONS_CHUNK_INTERVAL", "720h")
+	dbSessionsChunk, err := time.ParseDuration(dbSessionsChunkStr)
+	if err != nil {
+		dbSessionsChunk = 30 * 24 * time.Hour
+	}
+	cfg.Database.SessionsChunkInterval = dbSessionsChunk
+
+	dbEventRetentionStr := getEnvWithDefault("DB_EVENTS_RETENTION", "0")
+	dbEventRetention, err := time.ParseDuration(dbEventRetentionStr)
+	if err != nil {
+		dbEventRetention = 0
+	}
+	cfg.Database.EventRetention = dbEventRetention
+
+	dbSessionRetentionStr := getEnvWithDefault("DB_SESSIONS_RETENTION", "0")
+	dbSessionRetention, err := time.ParseDuration(dbSessionRetentionStr)
+	if err != nil {
+		dbSessionRetention = 0
+	}
+	cfg.Database.SessionRetention = dbSessionRetention
+
+	cfg.Database.Zone = getEnvWithDefault("DB_ZONE", "")
+	cfg.Database.Endpoints = parseDBEndpoints(getEnvWithDefault("DB_ENDPOINTS", ""))
+	dbEndpointHealthStr := getEnvWithDefault("DB_ENDPOINT_HEALTH_INTERVAL", "5s")
+	dbEndpointHealth, err := time.ParseDuration(dbEndpointHealthStr)
+	if err != nil {
+		dbEndpointHealth = 5 * time.Second
+	}
+	cfg.Database.EndpointHealthInterval = dbEndpointHealth
+
+	// -------------------------------
+	// Parse numeric/bool/duration envs
+	// for Service-level configuration
+	// -------------------------------
+	geoRadiusStr := getEnvWithDefault("SERVICE_GEOFENCE_RADIUS", fmt.Sprintf("%f", DefaultGeofenceRadius))
+	geoRadiusVal, err := strconv.ParseFloat(geoRadiusStr, 64)
+	if err != nil {
+		geoRadiusVal = DefaultGeofenceRadius
+	}
+	cfg.Service.GeofenceRadius = geoRadiusVal
+
+	locUpdateIntStr := getEnvWithDefault("SERVICE_LOCATION_UPDATE_INTERVAL", "5s")
+	locUpdateIntVal, err := time.ParseDuration(locUpdateIntStr)
+	if err != nil {
+		locUpdateIntVal = DefaultLocationUpdateInterval
+	}
+	cfg.Service.LocationUpdateInterval = locUpdateIntVal
+
+	sessTimeoutStr := getEnvWithDefault("SERVICE_SESSION_TIMEOUT", "30m")
+	sessTimeoutVal, err := time.ParseDuration(sessTimeoutStr)
+	if err != nil {
+		sessTimeoutVal = DefaultSessionTimeout
+	}
+	cfg.Service.SessionTimeout = sessTimeoutVal
+
+	maxSessionsStr := getEnvWithDefault("SERVICE_MAX_CONCURRENT_SESSIONS", "10")
+	maxSessionsVal, err := strconv.Atoi(maxSessionsStr)
+	if err != nil {
+		maxSessionsVal = 10
+	}
+	cfg.Service.MaxConcurrentSessions = maxSessionsVal
+
+	minAccStr := getEnvWithDefault("SERVICE_MIN_ACCURACY", "10")
+	minAccVal, err := strconv.ParseFloat(minAccStr, 64)
+	if err != nil {
+		minAccVal = 10.0
+	}
+	cfg.Service.MinAccuracy = minAccVal
+
+	maxLocHistStr := getEnvWithDefault("SERVICE_MAX_LOCATION_HISTORY", "1000")
+	maxLocHistVal, err := strconv.Atoi(maxLocHistStr)
+	if err != nil {
+		maxLocHistVal = 1000
+	}
+	cfg.Service.MaxLocationHistory = maxLocHistVal
+
+	staleLocThresholdStr := getEnvWithDefault("SERVICE_STALE_LOCATION_THRESHOLD", "30s")
+	staleLocThresholdVal, err := time.ParseDuration(staleLocThresholdStr)
+	if err != nil {
+		staleLocThresholdVal = 30 * time.Second
+	}
+	cfg.Service.StaleLocationThreshold = staleLocThresholdVal
+
+	// "windowed" keeps the last SERVICE_MAX_LOCATION_HISTORY points in memory;
+	// "bounded" rejects updates once that many points are held.
+	cfg.Service.LocationHistoryMode = getEnvWithDefault("SERVICE_LOCATION_HISTORY_MODE", "windowed")
+
+	eventSourcingStr := getEnvWithDefault("SERVICE_EVENT_SOURCING_ENABLED", "false")
+	eventSourcingVal, err := strconv.ParseBool(eventSourcingStr)
+	if err != nil {
+		eventSourcingVal = false
+	}
+	cfg.Service.EventSourcingEnabled = eventSourcingVal
+
+	completedRetentionStr := getEnvWithDefault("SERVICE_COMPLETED_SESSION_RETENTION", "24h")
+	completedRetentionVal, err := time.ParseDuration(completedRetentionStr)
+	if err != nil {
+		completedRetentionVal = 24 * time.Hour
+	}
+	cfg.Service.CompletedSessionRetention = completedRetentionVal
+
+	currentWalksStr := getEnvWithDefault("SERVICE_CURRENT_WALKS_ENABLED", "true")
+	currentWalksVal, err := strconv.ParseBool(currentWalksStr)
+	if err != nil {
+		currentWalksVal = true
+	}
+	cfg.Service.CurrentWalksEnabled = currentWalksVal
+	cfg.Service.SummaryDistance = getEnvWithDefault("SERVICE_SUMMARY_DISTANCE", "haversine")
+	cfg.Service.RateLimit = getEnvWithDefault("SERVICE_RATE_LIMIT", DefaultRateLimit)
+
+	configPollStr := getEnvWithDefault("SERVICE_CONFIG_POLL_INTERVAL", "10s")
+	configPollVal, err := time.ParseDuration(configPollStr)
+	if err != nil {
+		configPollVal = 10 * time.Second
+	}
+	cfg.Service.ConfigPollInterval = configPollVal
+
+	// -------------------------------
+	// Parse multi-region replication envs
+	// -------------------------------
+	replEnabledStr := getEnvWithDefault("REPLICATION_ENABLED", "false")
+	replEnabledVal, err := strconv.ParseBool(replEnabledStr)
+	if err != nil {
+		replEnabledVal = false
+	}
+	cfg.Replication.Enabled = replEnabledVal
+	cfg.Replication.Region = getEnvWithDefault("SERVICE_REGION", "")
+	cfg.Replication.PeerRegions = splitAndTrim(getEnvWithDefault("REPLICATION_PEER_REGIONS", ""))
+
+	// -------------------------------
+	// Parse HTTP API contract envs
+	// -------------------------------
+	cfg.API.SpecPath = getEnvWithDefault("API_OPENAPI_SPEC_PATH", "")
+	apiValidateRespStr := getEnvWithDefault("API_VALIDATE_RESPONSES", "false")
+	apiValidateResp, err := strconv.ParseBool(apiValidateRespStr)
+	if err != nil {
+		apiValidateResp = false
+	}
+	cfg.API.ValidateResponses = apiValidateResp
+	apiCompressionMin, err := strconv.Atoi(getEnvWithDefault("API_COMPRESSION_MIN_BYTES", "1024"))
+	if err != nil {
+		apiCompressionMin = 1024
+	}
+	cfg.API.CompressionMinBytes = apiCompressionMin
+
+	// -------------------------------
+	// Parse resumable stream envs
+	// -------------------------------
+	cfg.Stream.RedisAddr = getEnvWithDefault("STREAM_REDIS_ADDR", "")
+	cfg.Stream.RedisPassword = getEnvWithDefault("STREAM_REDIS_PASSWORD", "")
+	streamRedisDBStr := getEnvWithDefault("STREAM_REDIS_DB", "0")
+	streamRedisDB, err := strconv.Atoi(streamRedisDBStr)
+	if err != nil {
+		streamRedisDB = 0
+	}
+	cfg.Stream.RedisDB = streamRedisDB
+
+	streamBufSizeStr := getEnvWithDefault("STREAM_BUFFER_SIZE", "500")
+	streamBufSize, err := strconv.Atoi(streamBufSizeStr)
+	if err != nil {
+		streamBufSize = 500
+	}
+	cfg.Stream.BufferSize = streamBufSize
+
+	streamBufTTLStr := getEnvWithDefault("STREAM_BUFFER_TTL", "30m")
+	streamBufTTL, err := time.ParseDuration(streamBufTTLStr)
+	if err != nil {
+		streamBufTTL = 30 * time.Minute
+	}
+	cfg.Stream.BufferTTL = streamBufTTL
+
+	streamMaxAgeStr := getEnvWithDefault("STREAM_BUFFER_MAX_AGE", "10m")
+	streamMaxAge, err := time.ParseDuration(streamMaxAgeStr)
+	if err != nil {
+		streamMaxAge = 10 * time.Minute
+	}
+	cfg.Stream.MaxFrameAge = streamMaxAge
+	cfg.Stream.Tiers = parseStreamTiers(getEnvWithDefault("STREAM_BUFFER_TIERS", ""))
+	cfg.Stream.ResumeSecret = getEnvWithDefault("STREAM_RESUME_SECRET", "")
+	streamEncryptionStr := getEnvWithDefault("STREAM_ENCRYPTION_ENABLED", "false")
+	streamEncryptionVal, err := strconv.ParseBool(streamEncryptionStr)
+	if err != nil {
+		streamEncryptionVal = false
+	}
+	cfg.Stream.EncryptionEnabled = streamEncryptionVal
+	cfg.Stream.CaptureDir = getEnvWithDefault("STREAM_CAPTURE_DIR", "")
+	cfg.Stream.CaptureSessions = splitAndTrim(getEnvWithDefault("STREAM_CAPTURE_SESSIONS", ""))
+
+	streamThrottleMaxStr := getEnvWithDefault("STREAM_THROTTLE_MAX_INTERVAL", "30s")
+	streamThrottleMax, err := time.ParseDuration(streamThrottleMaxStr)
+	if err != nil {
+		streamThrottleMax = 30 * time.Second
+	}
+	cfg.Stream.ThrottleMaxInterval = streamThrottleMax
+
+	streamThrottleRTTStr := getEnvWithDefault("STREAM_THROTTLE_AUTO_RTT", "0")
+	streamThrottleRTT, err := time.ParseDuration(streamThrottleRTTStr)
+	if err != nil {
+		streamThrottleRTT = 0
+	}
+	cfg.Stream.ThrottleAutoRTT = streamThrottleRTT
+
+	streamThrottleAutoStr := getEnvWithDefault("STREAM_THROTTLE_AUTO_INTERVAL", "3s")
+	streamThrottleAuto, err := time.ParseDuration(streamThrottleAutoStr)
+	if err != nil {
+		streamThrottleAuto = 3 * time.Second
+	}
+	cfg.Stream.ThrottleAutoInterval = streamThrottleAuto
+
+	streamQueueStr := getEnvWithDefault("STREAM_SUBSCRIBER_QUEUE", "64")
+	streamQueue, err := strconv.Atoi(streamQueueStr)
+	if err != nil {
+		streamQueue = 64
+	}
+	cfg.Stream.SubscriberQueueSize = streamQueue
+
+	streamWriteTimeoutStr := getEnvWithDefault("STREAM_SUBSCRIBER_WRITE_TIMEOUT", "5s")
+	streamWriteTimeout, err := time.ParseDuration(streamWriteTimeoutStr)
+	if err != nil {
+		streamWriteTimeout = 5 * time.Second
+	}
+	cfg.Stream.SubscriberWriteTimeout = streamWriteTimeout
+
+	streamDrainTimeoutStr := getEnvWithDefault("STREAM_DRAIN_TIMEOUT", "10s")
+	streamDrainTimeout, err := time.ParseDuration(streamDrainTimeoutStr)
+	if err != nil {
+		streamDrainTimeout = 10 * time.Second
+	}
+	cfg.Stream.DrainTimeout = streamDrainTimeout
+
+	// -------------------------------
+	// Parse metrics cardinality envs
+	// -------------------------------
+	metricsHighCardStr := getEnvWithDefault("METRICS_HIGH_CARDINALITY", "false")
+	metricsHighCard, err := strconv.ParseBool(metricsHighCardStr)
+	if err != nil {
+		metricsHighCard = false
+	}
+	cfg.Metrics.HighCardinality = metricsHighCard
+
+	metricsMaxLabelsStr := getEnvWithDefault("METRICS_MAX_LABEL_VALUES", "100")
+	metricsMaxLabels, err := strconv.Atoi(metricsMaxLabelsStr)
+	if err != nil {
+		metricsMaxLabels = 100
+	}
+	cfg.Metrics.MaxLabelValues = metricsMaxLabels
+
+	// -------------------------------
+	// Parse walker presence envs
+	// -------------------------------
+	presenceEnabledStr := getEnvWithDefault("PRESENCE_ENABLED", "false")
+	presenceEnabled, err := strconv.ParseBool(presenceEnabledStr)
+	if err != nil {
+		presenceEnabled = false
+	}
+	cfg.Presence.Enabled = presenceEnabled
+
+	presenceTimeoutStr := getEnvWithDefault("PRESENCE_TIMEOUT", "90s")
+	presenceTimeout, err := time.ParseDuration(presenceTimeoutStr)
+	if err != nil {
+		presenceTimeout = 90 * time.Second
+	}
+	cfg.Presence.Timeout = presenceTimeout
+
+	presenceSweepStr := getEnvWithDefault("PRESENCE_SWEEP_INTERVAL", "15s")
+	presenceSweep, err := time.ParseDuration(presenceSweepStr)
+	if err != nil {
+		presenceSweep = 15 * time.Second
+	}
+	cfg.Presence.SweepInterval = presenceSweep
+
+	// -------------------------------
+	// Parse fitness platform envs
+	// -------------------------------
+	fitnessAutoUploadStr := getEnvWithDefault("FITNESS_AUTO_UPLOAD_ENABLED", "false")
+	fitnessAutoUpload, err := strconv.ParseBool(fitnessAutoUploadStr)
+	if err != nil {
+		fitnessAutoUpload = false
+	}
+	cfg.Fitness.AutoUploadEnabled = fitnessAutoUpload
+	cfg.Fitness.StravaClientID = getEnvWithDefault("FITNESS_STRAVA_CLIENT_ID", "")
+	cfg.Fitness.StravaClientSecret = getEnvWithDefault("FITNESS_STRAVA_CLIENT_SECRET", "")
+
+	fitnessTimeoutStr := getEnvWithDefault("FITNESS_UPLOAD_TIMEOUT", "30s")
+	fitnessTimeout, err := time.ParseDuration(fitnessTimeoutStr)
+	if err != nil {
+		fitnessTimeout = 30 * time.Second
+	}
+	cfg.Fitness.UploadTimeout = fitnessTimeout
+
+	// -------------------------------
+	// Parse reverse geocoding envs
+	// -------------------------------
+	cfg.Geocoding.Provider = strings.ToLower(getEnvWithDefault("GEOCODING_PROVIDER", ""))
+	cfg.Geocoding.APIKey = getEnvWithDefault("GEOCODING_API_KEY", "")
+	cfg.Geocoding.BaseURL = getEnvWithDefault("GEOCODING_BASE_URL", "")
+	cfg.Geocoding.UserAgent = getEnvWithDefault("GEOCODING_USER_AGENT", "")
+
+	geocodeTimeoutStr := getEnvWithDefault("GEOCODING_TIMEOUT", "5s")
+	geocodeTimeout, err := time.ParseDuration(geocodeTimeoutStr)
+	if err != nil {
+		geocodeTimeout = 5 * time.Second
+	}
+	cfg.Geocoding.Timeout = geocodeTimeout
+
+	geocodeRateStr := getEnvWithDefault("GEOCODING_RATE_LIMIT", "1")
+	geocodeRate, err := strconv.ParseFloat(geocodeRateStr, 64)
+	if err != nil {
+		geocodeRate = 1
+	}
+	cfg.Geocoding.RateLimit = geocodeRate
+
+	geocodeCacheSizeStr := getEnvWithDefault("GEOCODING_CACHE_SIZE", "10000")
+	geocodeCacheSize, err := strconv.Atoi(geocodeCacheSizeStr)
+	if err != nil {
+		geocodeCacheSize = 10000
+	}
+	cfg.Geocoding.CacheSize = geocodeCacheSize
+
+	geocodeCacheTTLStr := getEnvWithDefault("GEOCODING_CACHE_TTL", "168h")
+	geocodeCacheTTL, err := time.ParseDuration(geocodeCacheTTLStr)
+	if err != nil {
+		geocodeCacheTTL = 168 * time.Hour
+	}
+	cfg.Geocoding.CacheTTL = geocodeCacheTTL
+
+	// -------------------------------
+	// Parse dog profile envs
+	// -------------------------------
+	cfg.DogProfiles.PetsServiceURL = getEnvWithDefault("PETS_SERVICE_URL", "")
+	cfg.DogProfiles.Token = getEnvWithDefault("PETS_SERVICE_TOKEN", "")
+
+	dogProfileTimeout, err := time.ParseDuration(getEnvWithDefault("DOG_PROFILE_TIMEOUT", "2s"))
+	if err != nil {
+		dogProfileTimeout = 2 * time.Second
+	}
+	cfg.DogProfiles.Timeout = dogProfileTimeout
+
+	dogProfileCacheSize, err := strconv.Atoi(getEnvWithDefault("DOG_PROFILE_CACHE_SIZE", "10000"))
+	if err != nil {
+		dogProfileCacheSize = 10000
+	}
+	cfg.DogProfiles.CacheSize = dogProfileCacheSize
+
+	dogProfileCacheTTL, err := time.ParseDuration(getEnvWithDefault("DOG_PROFILE_CACHE_TTL", "1h"))
+	if err != nil {
+		dogProfileCacheTTL = time.Hour
+	}
+	cfg.DogProfiles.CacheTTL = dogProfileCacheTTL
+
+	// -------------------------------
+	// Parse write batching envs
+	// -------------------------------
+	batchingEnabledStr := getEnvWithDefault("BATCHING_ENABLED", "false")
+	batchingEnabled, err := strconv.ParseBool(batchingEnabledStr)
+	if err != nil {
+		batchingEnabled = false
+	}
+	cfg.Batching.Enabled = batchingEnabled
+
+	batchingMaxPointsStr := getEnvWithDefault("BATCHING_MAX_POINTS", "50")
+	batchingMaxPoints, err := strconv.Atoi(batchingMaxPointsStr)
+	if err != nil {
+		batchingMaxPoints = 50
+	}
+	cfg.Batching.MaxPoints = batchingMaxPoints
+
+	batchingMinWindowStr := getEnvWithDefault("BATCHING_MIN_WINDOW", "50ms")
+	batchingMinWindow, err := time.ParseDuration(batchingMinWindowStr)
+	if err != nil {
+		batchingMinWindow = 50 * time.Millisecond
+	}
+	cfg.Batching.MinWindow = batchingMinWindow
+
+	batchingMaxWindowStr := getEnvWithDefault("BATCHING_MAX_WINDOW", "1s")
+	batchingMaxWindow, err := time.ParseDuration(batchingMaxWindowStr)
+	if err != nil {
+		batchingMaxWindow = time.Second
+	}
+	cfg.Batching.MaxWindow = batchingMaxWindow
+
+	// -------------------------------
+	// Parse analytics envs
+	// -------------------------------
+	routePopularityStr := getEnvWithDefault("ANALYTICS_ROUTE_POPULARITY_ENABLED", "true")
+	routePopularity, err := strconv.ParseBool(routePopularityStr)
+	if err != nil {
+		routePopularity = true
+	}
+	cfg.Analytics.RoutePopularityEnabled = routePopularity
+
+	routeMinWalksStr := getEnvWithDefault("ANALYTICS_ROUTE_MIN_WALKS", "3")
+	routeMinWalks, err := strconv.Atoi(routeMinWalksStr)
+	if err != nil {
+		routeMinWalks = 3
+	}
+	cfg.Analytics.RouteMinWalks = routeMinWalks
+
+	leaderboardStr := getEnvWithDefault("ANALYTICS_LEADERBOARD_ENABLED", "true")
+	leaderboard, err := strconv.ParseBool(leaderboardStr)
+	if err != nil {
+		leaderboard = true
+	}
+	cfg.Analytics.LeaderboardEnabled = leaderboard
+
+	demandStr := getEnvWithDefault("ANALYTICS_DEMAND_ENABLED", "false")
+	demand, err := strconv.ParseBool(demandStr)
+	if err != nil {
+		demand = false
+	}
+	cfg.Analytics.DemandEnabled = demand
+
+	demandMinStartsStr := getEnvWithDefault("ANALYTICS_DEMAND_MIN_STARTS", "5")
+	demandMinStarts, err := strconv.Atoi(demandMinStartsStr)
+	if err != nil {
+		demandMinStarts = 5
+	}
+	cfg.Analytics.DemandMinStarts = demandMinStarts
+
+	// -------------------------------
+	// Parse abuse protection envs
+	// -------------------------------
+	abuseEnabledStr := getEnvWithDefault("ABUSE_PROTECTION_ENABLED", "true")
+	abuseEnabled, err := strconv.ParseBool(abuseEnabledStr)
+	if err != nil {
+		abuseEnabled = true
+	}
+	cfg.Abuse.Enabled = abuseEnabled
+
+	abuseMaxConnsStr := getEnvWithDefault("ABUSE_MAX_CONNS_PER_IP", "20")
+	abuseMaxConns, err := strconv.Atoi(abuseMaxConnsStr)
+	if err != nil {
+		abuseMaxConns = 20
+	}
+	cfg.Abuse.MaxConnsPerIP = abuseMaxConns
+
+	abuseRateStr := getEnvWithDefault("ABUSE_CONN_RATE_PER_MINUTE", "60")
+	abuseRate, err := strconv.Atoi(abuseRateStr)
+	if err != nil {
+		abuseRate = 60
+	}
+	cfg.Abuse.ConnRatePerMinute = abuseRate
+
+	abuseBurstStr := getEnvWithDefault("ABUSE_CONN_BURST", "20")
+	abuseBurst, err := strconv.Atoi(abuseBurstStr)
+	if err != nil {
+		abuseBurst = 20
+	}
+	cfg.Abuse.ConnBurst = abuseBurst
+
+	abuseFailLimitStr := getEnvWithDefault("ABUSE_AUTH_FAILURE_LIMIT", "10")
+	abuseFailLimit, err := strconv.Atoi(abuseFailLimitStr)
+	if err != nil {
+		abuseFailLimit = 10
+	}
+	cfg.Abuse.AuthFailureLimit = abuseFailLimit
+
+	abuseFailWindowStr := getEnvWithDefault("ABUSE_AUTH_FAILURE_WINDOW", "5m")
+	abuseFailWindow, err := time.ParseDuration(abuseFailWindowStr)
+	if err != nil {
+		abuseFailWindow = 5 * time.Minute
+	}
+	cfg.Abuse.AuthFailureWindow = abuseFailWindow
+
+	abuseBanStr := getEnvWithDefault("ABUSE_BAN_DURATION", "15m")
+	abuseBan, err := time.ParseDuration(abuseBanStr)
+	if err != nil {
+		abuseBan = 15 * time.Minute
+	}
+	cfg.Abuse.BanDuration = abuseBan
+
+	cfg.Abuse.Allowlist = splitAndTrim(getEnvWithDefault("ABUSE_ALLOWLIST", ""))
+
+	// -------------------------------
+	// Parse SLO envs
+	// -------------------------------
+	sloEnabledStr := getEnvWithDefault("SLO_TRACKING_ENABLED", "true")
+	sloEnabled, err := strconv.ParseBool(sloEnabledStr)
+	if err != nil {
+		sloEnabled = true
+	}
+	cfg.SLO.Enabled = sloEnabled
+
+	sloThresholdStr := getEnvWithDefault("SLO_LATENCY_THRESHOLD", "2s")
+	sloThreshold, err := time.ParseDuration(sloThresholdStr)
+	if err != nil {
+		sloThreshold = 2 * time.Second
+	}
+	cfg.SLO.LatencyThreshold = sloThreshold
+
+	sloTargetStr := getEnvWithDefault("SLO_LATENCY_TARGET", "0.99")
+	sloTarget, err := strconv.ParseFloat(sloTargetStr, 64)
+	if err != nil {
+		sloTarget = 0.99
+	}
+	cfg.SLO.LatencyTarget = sloTarget
+
+	sloPeriodStr := getEnvWithDefault("SLO_BUDGET_PERIOD", "720h")
+	sloPeriod, err := time.ParseDuration(sloPeriodStr)
+	if err != nil {
+		sloPeriod = 30 * 24 * time.Hour
+	}
+	cfg.SLO.BudgetPeriod = sloPeriod
+
+	// -------------------------------
+	// Parse storage migration envs
+	// -------------------------------
+	dualWriteStr := getEnvWithDefault("MIGRATION_DUAL_WRITE_ENABLED", "false")
+	dualWrite, err := strconv.ParseBool(dualWriteStr)
+	if err != nil {
+		dualWrite = false
+	}
+	cfg.Migration.DualWriteEnabled = dualWrite
+
+	cfg.Migration.Shadow = cfg.Database
+	cfg.Migration.Shadow.Host = getEnvWithDefault("MIGRATION_SHADOW_DB_HOST", "")
+	shadowPortStr := getEnvWithDefault("MIGRATION_SHADOW_DB_PORT", strconv.Itoa(cfg.Database.Port))
+	shadowPort, err := strconv.Atoi(shadowPortStr)
+	if err != nil {
+		shadowPort = cfg.Database.Port
+	}
+	cfg.Migration.Shadow.Port = shadowPort
+	cfg.Migration.Shadow.Database = getEnvWithDefault("MIGRATION_SHADOW_DB_DATABASE", cfg.Database.Database)
+	cfg.Migration.Shadow.Username = getEnvWithDefault("MIGRATION_SHADOW_DB_USER", cfg.Database.Username)
+	cfg.Migration.Shadow.Password = getEnvWithDefault("MIGRATION_SHADOW_DB_PASS", cfg.Database.Password)
+	cfg.Migration.Shadow.Schema = getEnvWithDefault("MIGRATION_SHADOW_DB_SCHEMA", cfg.Database.Schema)
+
+	sampleRateStr := getEnvWithDefault("MIGRATION_READ_SAMPLE_RATE", "0.01")
+	sampleRate, err := strconv.ParseFloat(sampleRateStr, 64)
+	if err != nil {
+		sampleRate = 0.01
+	}
+	cfg.Migration.ReadSampleRate = sampleRate
+
+	// -------------------------------
+	// Parse feature flag envs
+	// -------------------------------
+	// FEATURE_FLAGS is "flag=percent[/session|/tenant],...", bucketing by session
+	// when omitted, e.g. "kalman-filter=10,delta-encoding=50/tenant".
+	cfg.Flags.Rollouts = parseFlagRollouts(getEnvWithDefault("FEATURE_FLAGS", ""))
+	cfg.Flags.LogLevel = strings.ToLower(getEnvWithDefault("LOG_LEVEL", "info"))
+	flagRefreshStr := getEnvWithDefault("FEATURE_FLAGS_REFRESH_INTERVAL", "30s")
+	flagRefresh, err := time.ParseDuration(flagRefreshStr)
+	if err != nil {
+		flagRefresh = 30 * time.Second
+	}
+	cfg.Flags.RefreshInterval = flagRefresh
+
+	// -------------------------------
+	// Parse session affinity envs
+	// -------------------------------
+	hostname, _ := os.Hostname()
+	cfg.Affinity.NodeID = getEnvWithDefault("AFFINITY_NODE_ID", hostname)
+	cfg.Affinity.Secret = getEnvWithDefault("AFFINITY_SECRET", "")
+	cfg.Affinity.FallbackURL = getEnvWithDefault("AFFINITY_FALLBACK_URL", "")
+	cfg.Affinity.HandoffNode = getEnvWithDefault("AFFINITY_HANDOFF_NODE", "")
+	drainPeriodStr := getEnvWithDefault("AFFINITY_DRAIN_PERIOD", "15s")
+	drainPeriod, err := time.ParseDuration(drainPeriodStr)
+	if err != nil {
+		drainPeriod = 15 * time.Second
+	}
+	cfg.Affinity.DrainPeriod = drainPeriod
+
+	// -------------------------------
+	// Parse degradation envs
+	// -------------------------------
+	degradationEnabled, err := strconv.ParseBool(getEnvWithDefault("DEGRADATION_ENABLED", "false"))
+	if err != nil {
+		degradationEnabled = false
+	}
+	cfg.Degradation.Enabled = degradationEnabled
+	cfg.Degradation.SpoolDir = getEnvWithDefault("DEGRADATION_SPOOL_DIR", "/var/spool/tracking-service")
+	spoolMaxBatches, err := strconv.Atoi(getEnvWithDefault("DEGRADATION_SPOOL_MAX_BATCHES", "10000"))
+	if err != nil {
+		spoolMaxBatches = 10000
+	}
+	cfg.Degradation.SpoolMaxBatches = spoolMaxBatches
+	drainInterval, err := time.ParseDuration(getEnvWithDefault("DEGRADATION_DRAIN_INTERVAL", "5s"))
+	if err != nil {
+		drainInterval = 5 * time.Second
+	}
+	cfg.Degradation.DrainInterval = drainInterval
+	cacheEntries, err := strconv.Atoi(getEnvWithDefault("DEGRADATION_CACHE_ENTRIES", "10000"))
+	if err != nil {
+		cacheEntries = 10000
+	}
+	cfg.Degradation.CacheEntries = cacheEntries
+	cacheMaxAge, err := time.ParseDuration(getEnvWithDefault("DEGRADATION_CACHE_MAX_AGE", "1h"))
+	if err != nil {
+		cacheMaxAge = time.Hour
+	}
+	cfg.Degradation.CacheMaxAge = cacheMaxAge
+
+	// -------------------------------
+	// Parse diagnostics envs
+	// -------------------------------
+	cfg.Diagnostics.Dir = getEnvWithDefault("DIAGNOSTICS_DIR", "/var/lib/tracking-service/diagnostics")
+	diagMaxBundles, err := strconv.Atoi(getEnvWithDefault("DIAGNOSTICS_MAX_BUNDLES", "200"))
+	if err != nil {
+		diagMaxBundles = 200
+	}
+	cfg.Diagnostics.MaxBundles = diagMaxBundles
+	diagTrailSize, err := strconv.Atoi(getEnvWithDefault("DIAGNOSTICS_TRAIL_SIZE", "64"))
+	if err != nil {
+		diagTrailSize = 64
+	}
+	cfg.Diagnostics.TrailSize = diagTrailSize
+
+	// -------------------------------
+	// Parse beacon envs
+	// -------------------------------
+	beaconsEnabled, err := strconv.ParseBool(getEnvWithDefault("BEACONS_ENABLED", "false"))
+	if err != nil {
+		beaconsEnabled = false
+	}
+	cfg.Beacons.Enabled = beaconsEnabled
+	beaconMinRSSI, err := strconv.Atoi(getEnvWithDefault("BEACONS_MIN_RSSI", "-90"))
+	if err != nil {
+		beaconMinRSSI = -90
+	}
+	cfg.Beacons.MinRSSI = beaconMinRSSI
+
+	// -------------------------------
+	// Parse smoothing envs
+	// -------------------------------
+	smoothingEnabled, err := strconv.ParseBool(getEnvWithDefault("SMOOTHING_ENABLED", "true"))
+	if err != nil {
+		smoothingEnabled = true
+	}
+	cfg.Smoothing.Enabled = smoothingEnabled
+	processNoise, err := strconv.ParseFloat(getEnvWithDefault("SMOOTHING_PROCESS_NOISE", "3"), 64)
+	if err != nil {
+		processNoise = 3
+	}
+	cfg.Smoothing.ProcessNoise = processNoise
+	resetAfter, err := time.ParseDuration(getEnvWithDefault("SMOOTHING_RESET_AFTER", "2m"))
+	if err != nil {
+		resetAfter = 2 * time.Minute
+	}
+	cfg.Smoothing.ResetAfter = resetAfter
+
+	// -------------------------------
+	// Parse map matching envs
+	// -------------------------------
+	cfg.MapMatching.Provider = strings.ToLower(getEnvWithDefault("MAP_MATCHING_PROVIDER", ""))
+	cfg.MapMatching.URL = getEnvWithDefault("MAP_MATCHING_URL", "")
+	cfg.MapMatching.Profile = getEnvWithDefault("MAP_MATCHING_PROFILE", "")
+	mapMatchTimeout, err := time.ParseDuration(getEnvWithDefault("MAP_MATCHING_TIMEOUT", "2s"))
+	if err != nil {
+		mapMatchTimeout = 2 * time.Second
+	}
+	cfg.MapMatching.Timeout = mapMatchTimeout
+	mapMatchContext, err := strconv.Atoi(getEnvWithDefault("MAP_MATCHING_CONTEXT_POINTS", "5"))
+	if err != nil {
+		mapMatchContext = 5
+	}
+	cfg.MapMatching.ContextPoints = mapMatchContext
+	mapMatchMaxSnap, err := strconv.ParseFloat(getEnvWithDefault("MAP_MATCHING_MAX_SNAP_METERS", "25"), 64)
+	if err != nil {
+		mapMatchMaxSnap = 25
+	}
+	cfg.MapMatching.MaxSnapMeters = mapMatchMaxSnap
+
+	// -------------------------------
+	// Parse walk prediction envs
+	// -------------------------------
+	cfg.Prediction.Mode = strings.ToLower(getEnvWithDefault("PREDICTION_MODE", ""))
+	cfg.Prediction.URL = getEnvWithDefault("PREDICTION_URL", "")
+	predictionTimeout, err := time.ParseDuration(getEnvWithDefault("PREDICTION_TIMEOUT", "1s"))
+	if err != nil {
+		predictionTimeout = time.Second
+	}
+	cfg.Prediction.Timeout = predictionTimeout
+	predictionWalks, err := strconv.Atoi(getEnvWithDefault("PREDICTION_HISTORY_WALKS", "20"))
+	if err != nil {
+		predictionWalks = 20
+	}
+	cfg.Prediction.HistoryWalks = predictionWalks
+	predictionWindow, err := time.ParseDuration(getEnvWithDefault("PREDICTION_HISTORY_WINDOW", "2160h"))
+	if err != nil {
+		predictionWindow = 90 * 24 * time.Hour
+	}
+	cfg.Prediction.HistoryWindow = predictionWindow
+
+	// -------------------------------
+	// Parse tenant hook envs
+	// -------------------------------
+	cfg.TenantHooks.Hooks = parseTenantPointHooks(getEnvWithDefault("TENANT_POINT_HOOKS", ""))
+	tenantHookTimeout, err := time.ParseDuration(getEnvWithDefault("TENANT_POINT_HOOK_TIMEOUT", "5s"))
+	if err != nil {
+		tenantHookTimeout = 5 * time.Second
+	}
+	cfg.TenantHooks.Timeout = tenantHookTimeout
+
+	// -------------------------------
+	// Parse region profile envs
+	// -------------------------------
+	regionsEnabled, err := strconv.ParseBool(getEnvWithDefault("REGION_PROFILES_ENABLED", "false"))
+	if err != nil {
+		regionsEnabled = false
+	}
+	cfg.Regions.Enabled = regionsEnabled
+	regionMaxSpeed, err := strconv.ParseFloat(getEnvWithDefault("REGION_BASE_MAX_SPEED_KMH", "0"), 64)
+	if err != nil {
+		regionMaxSpeed = 0
+	}
+	cfg.Regions.MaxSpeedKmh = regionMaxSpeed
+	regionRadius, err := strconv.ParseFloat(getEnvWithDefault("REGION_BASE_GEOFENCE_RADIUS_KM", "0.5"), 64)
+	if err != nil {
+		regionRadius = 0.5
+	}
+	cfg.Regions.GeofenceRadiusKm = regionRadius
+	regionRetentionInterval, err := time.ParseDuration(getEnvWithDefault("REGION_RETENTION_INTERVAL", "1h"))
+	if err != nil {
+		regionRetentionInterval = time.Hour
 	}
-
-	// ------------------------
-	// Service Validation
-	// ------------------------
-	if c.Service.GeofenceRadius <= 0 {
-		validationErrs = append(validationErrs, fmt.Sprintf("service geofence radius %f must be positive", c.Service.GeofenceRadius))
+	cfg.Regions.RetentionInterval = regionRetentionInterval
+	regionRetentionDryRun, err := strconv.ParseBool(getEnvWithDefault("REGION_RETENTION_DRY_RUN", "false"))
+	if err != nil {
+		regionRetentionDryRun = false
 	}
-	if c.Service.LocationUpdateInterval <= 0 {
-		validationErrs = append(validationErrs, "service location update interval must be greater than zero")
+	cfg.Regions.RetentionDryRun = regionRetentionDryRun
+
+	// -------------------------------
+	// Parse owner alert envs
+	// -------------------------------
+	ownerAlertsEnabled, err := strconv.ParseBool(getEnvWithDefault("OWNER_ALERTS_ENABLED", "false"))
+	if err != nil {
+		ownerAlertsEnabled = false
 	}
-	if c.Service.SessionTimeout <= 0 {
-		validationErrs = append(validationErrs, "service session timeout must be greater than zero")
+	cfg.OwnerAlerts.Enabled = ownerAlertsEnabled
+
+	// -------------------------------
+	// Parse backpressure envs
+	// -------------------------------
+	backpressureEnabled, err := strconv.ParseBool(getEnvWithDefault("BACKPRESSURE_ENABLED", "false"))
+	if err != nil {
+		backpressureEnabled = false
 	}
-	if c.Service.MaxConcurrentSessions < 0 {
-		validationErrs = append(validationErrs, fmt.Sprintf("service max concurrent sessions %d cannot be negative", c.Service.MaxConcurrentSessions))
+	cfg.Backpressure.Enabled = backpressureEnabled
+	cfg.Backpressure.LowPriorityTopics = splitAndTrim(getEnvWithDefault("BACKPRESSURE_LOW_PRIORITY_TOPICS", "walkers/presence/+"))
+	highWatermark, err := strconv.ParseFloat(getEnvWithDefault("BACKPRESSURE_HIGH_WATERMARK", "0.8"), 64)
+	if err != nil {
+		highWatermark = 0.8
 	}
-	if c.Service.MinAccuracy < 0 {
-		validationErrs = append(validationErrs, fmt.Sprintf("service minimum accuracy %f cannot be negative", c.Service.MinAccuracy))
+	cfg.Backpressure.HighWatermark = highWatermark
+	lowWatermark, err := strconv.ParseFloat(getEnvWithDefault("BACKPRESSURE_LOW_WATERMARK", "0.5"), 64)
+	if err != nil {
+		lowWatermark = 0.5
 	}
-	if c.Service.MaxLocationHistory < 0 {
-		validationErrs = append(validationErrs, fmt.Sprintf("service max location history %d cannot be negative", c.Service.MaxLocationHistory))
+	cfg.Backpressure.LowWatermark = lowWatermark
+	backpressureInterval, err := time.ParseDuration(getEnvWithDefault("BACKPRESSURE_CHECK_INTERVAL", "1s"))
+	if err != nil {
+		backpressureInterval = time.Second
 	}
-	if c.Service.StaleLocationThreshold < 0 {
-		validationErrs = append(validationErrs, "service stale location threshold cannot be negative")
+	cfg.Backpressure.CheckInterval = backpressureInterval
+	maxInFlight, err := strconv.Atoi(getEnvWithDefault("BACKPRESSURE_MAX_INFLIGHT", "1000"))
+	if err != nil {
+		maxInFlight = 1000
 	}
-
-	// ------------------------
-	// Return Validation Errors
-	// ------------------------
-	if len(validationErrs) > 0 {
-		return fmt.Errorf("configuration validation failed:\n - %s", strings.Join(validationErrs, "\n - "))
+	cfg.Backpressure.MaxInFlight = maxInFlight
+	maxBufferedPoints, err := strconv.Atoi(getEnvWithDefault("BACKPRESSURE_MAX_BUFFERED_POINTS", "50000"))
+	if err != nil {
+		maxBufferedPoints = 50000
 	}
-	return nil
-}
+	cfg.Backpressure.MaxBufferedPoints = maxBufferedPoints
 
-// ------------------------
-// LoadConfig Function
-// ------------------------
-//
-// LoadConfig reads the necessary environment variables, applies defaults,
-// and returns a populated Config pointer. It ensures that all settings are
-// validated before returning.
-//
-// Returns:
-//   *Config: Populated configuration struct if successful
-//   error:   Any error if configuration loading or validation fails
-//
-func LoadConfig() (*Config, error) {
-	cfg := &Config{
-		// --------------------------------
-		// MQTT Configuration
-		// --------------------------------
-		MQTT: MQTTConfig{
-			Host: getEnvWithDefault("MQTT_HOST", "localhost"),
-		},
-		Database: DBConfig{
-			Host: getEnvWithDefault("DB_HOST", "localhost"),
-		},
-		Service: ServiceConfig{},
-	}
+	// -------------------------------
+	// Parse pagination envs
+	// -------------------------------
+	cfg.Pagination.CursorSecret = getEnvWithDefault("PAGINATION_CURSOR_SECRET", "")
 
 	// -------------------------------
-	// Parse numeric/bool/duration envs
-	// for MQTT
+	// Parse share link envs
 	// -------------------------------
-	mqttPortStr := getEnvWithDefault("MQTT_PORT", strconv.Itoa(DefaultMQTTPort))
-	mqttPort, err := strconv.Atoi(mqttPortStr)
+	cfg.ShareLinks.Secret = getEnvWithDefault("SHARE_LINK_SECRET", "")
+	shareLinkTTL, err := time.ParseDuration(getEnvWithDefault("SHARE_LINK_TTL", "1h"))
 	if err != nil {
-		mqttPort = DefaultMQTTPort
+		shareLinkTTL = time.Hour
 	}
-	cfg.MQTT.Port = mqttPort
+	cfg.ShareLinks.DefaultTTL = shareLinkTTL
+	shareLinkMaxTTL, err := time.ParseDuration(getEnvWithDefault("SHARE_LINK_MAX_TTL", "24h"))
+	if err != nil {
+		shareLinkMaxTTL = 24 * time.Hour
+	}
+	cfg.ShareLinks.MaxTTL = shareLinkMaxTTL
 
-	cfg.MQTT.Username = getEnvWithDefault("MQTT_USER", "")
-	cfg.MQTT.Password = getEnvWithDefault("MQTT_PASS", "")
+	// -------------------------------
+	// Parse access token envs
+	// -------------------------------
+	// Keys passed on a single line carry their line breaks escaped.
+	cfg.Auth.JWTPublicKey = strings.ReplaceAll(getEnvWithDefault("JWT_PUBLIC_KEY", ""), `\n`, "\n")
+	cfg.Auth.JWTIssuer = getEnvWithDefault("JWT_ISSUER", "dog-walking-auth-service")
+	cfg.Auth.JWTAudience = getEnvWithDefault("JWT_AUDIENCE", "dog-walking-api")
 
-	mqttTLSStr := getEnvWithDefault("MQTT_TLS_ENABLED", "false")
-	mqttTLSVal, err := strconv.ParseBool(mqttTLSStr)
+	// -------------------------------
+	// Parse integrity check envs
+	// -------------------------------
+	integrityEnabled, err := strconv.ParseBool(getEnvWithDefault("INTEGRITY_CHECK_ENABLED", "false"))
 	if err != nil {
-		mqttTLSVal = false
+		integrityEnabled = false
 	}
-	cfg.MQTT.TLSEnabled = mqttTLSVal
-
-	mqttConnectionTimeoutStr := getEnvWithDefault("MQTT_CONNECTION_TIMEOUT", "10s")
-	mqttConnTimeout, err := time.ParseDuration(mqttConnectionTimeoutStr)
+	cfg.Integrity.Enabled = integrityEnabled
+	integrityInterval, err := time.ParseDuration(getEnvWithDefault("INTEGRITY_CHECK_INTERVAL", "24h"))
 	if err != nil {
-		mqttConnTimeout = 10 * time.Second
+		integrityInterval = 24 * time.Hour
 	}
-	cfg.MQTT.ConnectionTimeout = mqttConnTimeout
-
-	mqttKeepAliveStr := getEnvWithDefault("MQTT_KEEP_ALIVE", "60s")
-	mqttKeepAlive, err := time.ParseDuration(mqttKeepAliveStr)
+	cfg.Integrity.Interval = integrityInterval
+	integrityLookback, err := time.ParseDuration(getEnvWithDefault("INTEGRITY_CHECK_LOOKBACK", "24h"))
 	if err != nil {
-		mqttKeepAlive = 60 * time.Second
+		integrityLookback = 24 * time.Hour
 	}
-	cfg.MQTT.KeepAlive = mqttKeepAlive
-
-	mqttQoSStr := getEnvWithDefault("MQTT_QOS", "0")
-	mqttQoSVal, err := strconv.Atoi(mqttQoSStr)
+	cfg.Integrity.Lookback = integrityLookback
+	integritySample, err := strconv.Atoi(getEnvWithDefault("INTEGRITY_CHECK_SAMPLE_SIZE", "100"))
 	if err != nil {
-		mqttQoSVal = 0
+		integritySample = 100
 	}
-	cfg.MQTT.QoS = mqttQoSVal
-
-	mqttRetryIntervalStr := getEnvWithDefault("MQTT_RETRY_INTERVAL", "5s")
-	mqttRetryInterval, err := time.ParseDuration(mqttRetryIntervalStr)
+	cfg.Integrity.SampleSize = integritySample
+	integrityTolerance, err := strconv.ParseFloat(getEnvWithDefault("INTEGRITY_CHECK_DISTANCE_TOLERANCE", "0.05"), 64)
 	if err != nil {
-		mqttRetryInterval = 5 * time.Second
+		integrityTolerance = 0.05
 	}
-	cfg.MQTT.RetryInterval = mqttRetryInterval
+	cfg.Integrity.DistanceTolerance = integrityTolerance
 
 	// -------------------------------
-	// Parse numeric/bool/duration envs
-	// for Database
+	// Parse runtime monitor envs
 	// -------------------------------
-	dbPortStr := getEnvWithDefault("DB_PORT", strconv.Itoa(DefaultDBPort))
-	dbPort, err := strconv.Atoi(dbPortStr)
+	runtimeEnabled, err := strconv.ParseBool(getEnvWithDefault("RUNTIME_MONITOR_ENABLED", "false"))
 	if err != nil {
-		dbPort = DefaultDBPort
+		runtimeEnabled = false
 	}
-	cfg.Database.Port = dbPort
-
-	cfg.Database.Database = getEnvWithDefault("DB_DATABASE", "tracking_db")
-	cfg.Database.Username = getEnvWithDefault("DB_USER", "")
-	cfg.Database.Password = getEnvWithDefault("DB_PASS", "")
-
-	dbMaxConnStr := getEnvWithDefault("DB_MAX_CONNECTIONS", strconv.Itoa(DefaultMaxConnections))
-	dbMaxConn, err := strconv.Atoi(dbMaxConnStr)
+	cfg.Runtime.Enabled = runtimeEnabled
+	runtimeInterval, err := time.ParseDuration(getEnvWithDefault("RUNTIME_MONITOR_INTERVAL", "5s"))
 	if err != nil {
-		dbMaxConn = DefaultMaxConnections
+		runtimeInterval = 5 * time.Second
 	}
-	cfg.Database.MaxConnections = dbMaxConn
+	cfg.Runtime.Interval = runtimeInterval
+	runtimeSustain, err := time.ParseDuration(getEnvWithDefault("RUNTIME_MONITOR_SUSTAIN", "30s"))
+	if err != nil {
+		runtimeSustain = 30 * time.Second
+	}
+	cfg.Runtime.SustainFor = runtimeSustain
+	maxGoroutines, err := strconv.Atoi(getEnvWithDefault("RUNTIME_MONITOR_MAX_GOROUTINES", "10000"))
+	if err != nil {
+		maxGoroutines = 10000
+	}
+	cfg.Runtime.MaxGoroutines = maxGoroutines
+	maxHeapMB, err := strconv.Atoi(getEnvWithDefault("RUNTIME_MONITOR_MAX_HEAP_MB", "0"))
+	if err != nil {
+		maxHeapMB = 0
+	}
+	cfg.Runtime.MaxHeapMB = maxHeapMB
+	cfg.Runtime.QueueThresholds = parseQueueThresholds(getEnvWithDefault("RUNTIME_MONITOR_QUEUE_THRESHOLDS",
+		"mqtt_inflight=1000,coalesced_points=50000,spool=1000,stream_hub=5000"))
 
-	dbConnTimeoutStr := getEnvWithDefault("DB_CONNECTION_TIMEOUT", "5s")
-	dbConnTimeout, err := time.ParseDuration(dbConnTimeoutStr)
+	// -------------------------------
+	// Parse session reaper envs
+	// -------------------------------
+	reaperEnabled, err := strconv.ParseBool(getEnvWithDefault("SESSION_REAPER_ENABLED", "false"))
 	if err != nil {
-		dbConnTimeout = 5 * time.Second
+		reaperEnabled = false
 	}
-	cfg.Database.ConnectionTimeout = dbConnTimeout
+	cfg.Reaper.Enabled = reaperEnabled
+	reaperInterval, err := time.ParseDuration(getEnvWithDefault("SESSION_REAPER_INTERVAL", "1m"))
+	if err != nil {
+		reaperInterval = time.Minute
+	}
+	cfg.Reaper.Interval = reaperInterval
 
-	dbMaxIdleConnStr := getEnvWithDefault("DB_MAX_IDLE_CONNECTIONS", "10")
-	dbMaxIdleConn, err := strconv.Atoi(dbMaxIdleConnStr)
+	// -------------------------------
+	// Parse location dedup envs
+	// -------------------------------
+	dedupEnabled, err := strconv.ParseBool(getEnvWithDefault("LOCATION_DEDUP_ENABLED", "false"))
 	if err != nil {
-		dbMaxIdleConn = 10
+		dedupEnabled = false
 	}
-	cfg.Database.MaxIdleConnections = dbMaxIdleConn
+	cfg.Dedup.Enabled = dedupEnabled
+	dedupCapacity, err := strconv.Atoi(getEnvWithDefault("LOCATION_DEDUP_CAPACITY", "100000"))
+	if err != nil {
+		dedupCapacity = 100000
+	}
+	cfg.Dedup.Capacity = dedupCapacity
+	dedupRate, err := strconv.ParseFloat(getEnvWithDefault("LOCATION_DEDUP_FALSE_POSITIVE_RATE", "0.000001"), 64)
+	if err != nil {
+		dedupRate = 0.000001
+	}
+	cfg.Dedup.FalsePositiveRate = dedupRate
 
-	dbMaxLifetimeStr := getEnvWithDefault("DB_MAX_CONNECTION_LIFETIME", "60m")
-	dbMaxLifetime, err := time.ParseDuration(dbMaxLifetimeStr)
+	// -------------------------------
+	// Parse location quarantine envs
+	// -------------------------------
+	quarantineEnabled, err := strconv.ParseBool(getEnvWithDefault("QUARANTINE_ENABLED", "false"))
 	if err != nil {
-		dbMaxLifetime = 60 * time.Minute
+		quarantineEnabled = false
 	}
-	cfg.Database.MaxConnectionLifetime = dbMaxLifetime
+	cfg.Quarantine.Enabled = quarantineEnabled
 
 	// -------------------------------
-	// Parse numeric/bool/duration envs
-	// for Service-level configuration
+	// Parse key escrow envs
 	// -------------------------------
-	geoRadiusStr := getEnvWithDefault("SERVICE_GEOFENCE_RADIUS", fmt.Sprintf("%f", DefaultGeofenceRadius))
-	geoRadiusVal, err := strconv.ParseFloat(geoRadiusStr, 64)
+	escrowEnabled, err := strconv.ParseBool(getEnvWithDefault("KEY_ESCROW_ENABLED", "false"))
 	if err != nil {
-		geoRadiusVal = DefaultGeofenceRadius
+		escrowEnabled = false
 	}
-	cfg.Service.GeofenceRadius = geoRadiusVal
+	cfg.Escrow.Enabled = escrowEnabled
+	cfg.Escrow.PublicKey = getEnvWithDefault("KEY_ESCROW_PUBLIC_KEY", "")
+	cfg.Escrow.Custodians = splitAndTrim(getEnvWithDefault("KEY_ESCROW_CUSTODIANS", ""))
 
-	locUpdateIntStr := getEnvWithDefault("SERVICE_LOCATION_UPDATE_INTERVAL", "5s")
-	locUpdateIntVal, err := time.ParseDuration(locUpdateIntStr)
+	// -------------------------------
+	// Parse in-flight guard envs
+	// -------------------------------
+	locationMaxInFlight, err := strconv.Atoi(getEnvWithDefault("LOCATION_MAX_IN_FLIGHT", "0"))
 	if err != nil {
-		locUpdateIntVal = DefaultLocationUpdateInterval
+		locationMaxInFlight = 0
 	}
-	cfg.Service.LocationUpdateInterval = locUpdateIntVal
+	cfg.InFlight.MaxLocationRequests = locationMaxInFlight
+	inFlightRetryAfter, err := time.ParseDuration(getEnvWithDefault("LOCATION_RETRY_AFTER", "1s"))
+	if err != nil {
+		inFlightRetryAfter = time.Second
+	}
+	cfg.InFlight.RetryAfter = inFlightRetryAfter
+	inFlightDrainTimeout, err := time.ParseDuration(getEnvWithDefault("LOCATION_DRAIN_TIMEOUT", "10s"))
+	if err != nil {
+		inFlightDrainTimeout = 10 * time.Second
+	}
+	cfg.InFlight.DrainTimeout = inFlightDrainTimeout
 
-	sessTimeoutStr := getEnvWithDefault("SERVICE_SESSION_TIMEOUT", "30m")
-	sessTimeoutVal, err := time.ParseDuration(sessTimeoutStr)
+	// -------------------------------
+	// Parse sequence gap envs
+	// -------------------------------
+	sequenceGapsEnabled, err := strconv.ParseBool(getEnvWithDefault("SEQUENCE_GAPS_ENABLED", "false"))
 	if err != nil {
-		sessTimeoutVal = DefaultSessionTimeout
+		sequenceGapsEnabled = false
 	}
-	cfg.Service.SessionTimeout = sessTimeoutVal
+	cfg.SequenceGaps.Enabled = sequenceGapsEnabled
+	gapConfirmAfter, err := time.ParseDuration(getEnvWithDefault("SEQUENCE_GAP_CONFIRM_AFTER", "2m"))
+	if err != nil {
+		gapConfirmAfter = 2 * time.Minute
+	}
+	cfg.SequenceGaps.ConfirmAfter = gapConfirmAfter
+	gpsGapThreshold, err := time.ParseDuration(getEnvWithDefault("SEQUENCE_GPS_GAP_THRESHOLD", "30s"))
+	if err != nil {
+		gpsGapThreshold = 30 * time.Second
+	}
+	cfg.SequenceGaps.GPSGapThreshold = gpsGapThreshold
 
-	maxSessionsStr := getEnvWithDefault("SERVICE_MAX_CONCURRENT_SESSIONS", "10")
-	maxSessionsVal, err := strconv.Atoi(maxSessionsStr)
+	// -------------------------------
+	// Parse walk access log envs
+	// -------------------------------
+	accessLogEnabled, err := strconv.ParseBool(getEnvWithDefault("ACCESS_LOG_ENABLED", "false"))
 	if err != nil {
-		maxSessionsVal = 10
+		accessLogEnabled = false
 	}
-	cfg.Service.MaxConcurrentSessions = maxSessionsVal
+	cfg.AccessLog.Enabled = accessLogEnabled
+	cfg.AccessLog.ActorHeader = getEnvWithDefault("ACCESS_LOG_ACTOR_HEADER", "X-Actor-ID")
 
-	minAccStr := getEnvWithDefault("SERVICE_MIN_ACCURACY", "10")
-	minAccVal, err := strconv.ParseFloat(minAccStr, 64)
+	// -------------------------------
+	// Parse inline geofence envs
+	// -------------------------------
+	inlineGeofencesEnabled, err := strconv.ParseBool(getEnvWithDefault("INLINE_GEOFENCES_ENABLED", "false"))
 	if err != nil {
-		minAccVal = 10.0
+		inlineGeofencesEnabled = false
 	}
-	cfg.Service.MinAccuracy = minAccVal
+	cfg.InlineGeofences.Enabled = inlineGeofencesEnabled
+	geofenceRefresh, err := time.ParseDuration(getEnvWithDefault("INLINE_GEOFENCE_REFRESH_INTERVAL", "30s"))
+	if err != nil {
+		geofenceRefresh = 30 * time.Second
+	}
+	cfg.InlineGeofences.RefreshInterval = geofenceRefresh
 
-	maxLocHistStr := getEnvWithDefault("SERVICE_MAX_LOCATION_HISTORY", "1000")
-	maxLocHistVal, err := strconv.Atoi(maxLocHistStr)
+	// -------------------------------
+	// Parse device registry envs
+	// -------------------------------
+	devicesEnabled, err := strconv.ParseBool(getEnvWithDefault("DEVICE_PAIRING_ENABLED", "false"))
 	if err != nil {
-		maxLocHistVal = 1000
+		devicesEnabled = false
 	}
-	cfg.Service.MaxLocationHistory = maxLocHistVal
+	cfg.Devices.Enabled = devicesEnabled
+	cfg.Devices.AttestationSecret = getEnvWithDefault("DEVICE_ATTESTATION_SECRET", "")
+	cfg.Devices.DefaultPolicy = strings.ToLower(getEnvWithDefault("DEVICE_DEFAULT_POLICY", "flag"))
+	cfg.Devices.TenantPolicies = parseDevicePolicies(getEnvWithDefault("DEVICE_TENANT_POLICIES", ""))
 
-	staleLocThresholdStr := getEnvWithDefault("SERVICE_STALE_LOCATION_THRESHOLD", "30s")
-	staleLocThresholdVal, err := time.ParseDuration(staleLocThresholdStr)
+	// -------------------------------
+	// Parse ID generation envs
+	// -------------------------------
+	cfg.IDs.Format = strings.ToLower(getEnvWithDefault("ID_FORMAT", "uuid"))
+	snowflakeNodeStr := getEnvWithDefault("ID_SNOWFLAKE_NODE_ID", "0")
+	snowflakeNode, err := strconv.ParseInt(snowflakeNodeStr, 10, 64)
 	if err != nil {
-		staleLocThresholdVal = 30 * time.Second
+		snowflakeNode = 0
+	}
+	cfg.IDs.SnowflakeNodeID = snowflakeNode
+	cfg.IDs.AcceptedFormats = splitAndTrim(strings.ToLower(getEnvWithDefault("ID_ACCEPTED_FORMATS", "")))
+
+	// -------------------------------
+	// Reject config file settings that
+	// match no environment variable
+	// -------------------------------
+	if activeFile != nil {
+		if unknown := activeFile.unknownKeys(); len(unknown) > 0 {
+			return nil, fmt.Errorf("config file has unknown settings: %s", strings.Join(unknown, ", "))
+		}
 	}
-	cfg.Service.StaleLocationThreshold = staleLocThresholdVal
 
 	// -------------------------------
 	// Validate the final configuration
@@ -382,6 +3106,7 @@ func LoadConfig() (*Config, error) {
 	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
+	cfg.settings = resolvedSettings
 	return cfg, nil
 }
 
@@ -390,8 +3115,10 @@ func LoadConfig() (*Config, error) {
 // ------------------------
 //
 // getEnvWithDefault is a secure helper function that checks the environment for a given key.
-// If the key is empty or invalid, it returns the specified defaultValue. Otherwise, it returns
-// the sanitized environment variable.
+// If the key is empty or invalid, it falls back to the active config file's setting for the
+// key, then to the specified defaultValue. Otherwise, it returns the sanitized environment
+// variable. While a configuration loads, the value returned is recorded so reloads can
+// tell which settings changed.
 //
 // Parameters:
 //   key:          The environment variable name to look up.
@@ -401,9 +3128,183 @@ func LoadConfig() (*Config, error) {
 //   string:       The environment variable's value or the defaultValue.
 //
 func getEnvWithDefault(key string, defaultValue string) string {
-	val, exists := os.LookupEnv(key)
-	if !exists || strings.TrimSpace(val) == "" {
-		return defaultValue
+	if activeFile != nil {
+		// A config file setting replaces the default; the environment still wins.
+		if fileVal, ok := activeFile.lookup(key); ok && strings.TrimSpace(fileVal) != "" {
+			defaultValue = strings.TrimSpace(fileVal)
+		}
+	}
+	resolved := defaultValue
+	if val, exists := os.LookupEnv(key); exists && strings.TrimSpace(val) != "" {
+		resolved = strings.TrimSpace(val)
+	}
+	if resolvedSettings != nil {
+		resolvedSettings[key] = resolved
+	}
+	return resolved
+}
+
+// ------------------------
+// splitAndTrim Function
+// ------------------------
+//
+// splitAndTrim splits a comma-separated environment value into its trimmed,
+// non-empty elements. An empty input yields a nil slice.
+//
+func splitAndTrim(raw string) []string {
+	var out []string
+	for _, part := range strings.Split(raw, ",") {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			out = append(out, trimmed)
+		}
+	}
+	return out
+}
+
+// ------------------------
+// ParseRateLimit Function
+// ------------------------
+//
+// ParseRateLimit parses a rate limit of the form "<count>/<unit>", such as
+// "100/minute", into the number of requests allowed per period. The unit is
+// s, sec or second; m, min or minute; or h or hour.
+//
+func ParseRateLimit(spec string) (int, time.Duration, error) {
+	countStr, unit, ok := strings.Cut(strings.TrimSpace(spec), "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("rate limit %q must have the form <count>/<unit>", spec)
+	}
+	count, err := strconv.Atoi(countStr)
+	if err != nil || count < 1 {
+		return 0, 0, fmt.Errorf("rate limit count %q must be a positive integer", countStr)
+	}
+
+	var per time.Duration
+	switch unit {
+	case "s", "sec", "second":
+		per = time.Second
+	case "m", "min", "minute":
+		per = time.Minute
+	case "h", "hour":
+		per = time.Hour
+	default:
+		return 0, 0, fmt.Errorf("unsupported rate limit unit: %s", unit)
+	}
+	return count, per, nil
+}
+
+// ------------------------
+// parseStreamTiers Function
+// ------------------------
+//
+// parseStreamTiers parses per-tier stream buffer limits written as
+// "name=size/maxAge" pairs separated by commas, e.g. "premium=2000/2h,free=100/2m".
+// Unparseable limits are left zero so Validate reports the offending tier.
+//
+func parseStreamTiers(raw string) map[string]StreamTierLimits {
+	tiers := make(map[string]StreamTierLimits)
+	for _, entry := range splitAndTrim(raw) {
+		name, spec, _ := strings.Cut(entry, "=")
+		sizeStr, ageStr, _ := strings.Cut(spec, "/")
+		var limits StreamTierLimits
+		limits.BufferSize, _ = strconv.Atoi(strings.TrimSpace(sizeStr))
+		limits.MaxFrameAge, _ = time.ParseDuration(strings.TrimSpace(ageStr))
+		tiers[strings.TrimSpace(name)] = limits
+	}
+	return tiers
+}
+
+// ------------------------
+// parseQueueThresholds Function
+// ------------------------
+//
+// parseQueueThresholds parses runtime monitor queue limits written as
+// "queue=depth" pairs separated by commas, e.g. "spool=1000,stream_hub=5000".
+// Unparseable depths are left zero so Validate reports the offending queue.
+//
+func parseQueueThresholds(raw string) map[string]int {
+	thresholds := make(map[string]int)
+	for _, entry := range splitAndTrim(raw) {
+		queue, depthStr, _ := strings.Cut(entry, "=")
+		depth, _ := strconv.Atoi(strings.TrimSpace(depthStr))
+		thresholds[strings.TrimSpace(queue)] = depth
+	}
+	return thresholds
+}
+
+// ------------------------
+// parseDevicePolicies Function
+// ------------------------
+//
+// parseDevicePolicies parses per-tenant device policies written as
+// "tenant=policy" pairs separated by commas, e.g. "acme=reject,globex=allow".
+// Policies are lower-cased and otherwise kept as given so Validate reports
+// unknown ones.
+//
+func parseDevicePolicies(raw string) map[string]string {
+	policies := make(map[string]string)
+	for _, entry := range splitAndTrim(raw) {
+		tenant, policy, _ := strings.Cut(entry, "=")
+		policies[strings.TrimSpace(tenant)] = strings.ToLower(strings.TrimSpace(policy))
+	}
+	return policies
+}
+
+// ------------------------
+// parseTenantPointHooks Function
+// ------------------------
+//
+// parseTenantPointHooks parses tenant point hooks written as
+// "tenant=every@url" entries separated by commas, e.g.
+// "acme=100@https://analytics.acme.example/points". Unparseable intervals are
+// left zero so Validate reports the offending tenant.
+//
+func parseTenantPointHooks(raw string) []TenantPointHook {
+	var hooks []TenantPointHook
+	for _, entry := range splitAndTrim(raw) {
+		tenant, spec, _ := strings.Cut(entry, "=")
+		everyStr, hookURL, _ := strings.Cut(spec, "@")
+		hook := TenantPointHook{TenantID: strings.TrimSpace(tenant), URL: strings.TrimSpace(hookURL)}
+		hook.EveryNPoints, _ = strconv.Atoi(strings.TrimSpace(everyStr))
+		hooks = append(hooks, hook)
+	}
+	return hooks
+}
+
+// parseDBEndpoints parses "zone=host:port,..." into endpoints, in order.
+// Malformed entries keep their empty or zero fields, so Validate reports them.
+func parseDBEndpoints(raw string) []DBEndpoint {
+	var endpoints []DBEndpoint
+	for _, entry := range splitAndTrim(raw) {
+		zone, addr, _ := strings.Cut(entry, "=")
+		ep := DBEndpoint{Zone: strings.TrimSpace(zone)}
+		if host, portStr, err := net.SplitHostPort(strings.TrimSpace(addr)); err == nil {
+			ep.Host = host
+			ep.Port, _ = strconv.Atoi(portStr)
+		}
+		endpoints = append(endpoints, ep)
 	}
-	return strings.TrimSpace(val)
-}
\ No newline at end of file
+	return endpoints
+}
+
+// parseFlagRollouts parses "flag=percent[/by],..." into rollouts. Malformed
+// percentages parse as -1 and unknown bucketing keys are kept, so Validate
+// reports them.
+func parseFlagRollouts(raw string) map[string]FlagRollout {
+	rollouts := make(map[string]FlagRollout)
+	for _, entry := range splitAndTrim(raw) {
+		name, spec, _ := strings.Cut(entry, "=")
+		pctStr, by, hasBy := strings.Cut(spec, "/")
+		rollout := FlagRollout{By: FlagBySession}
+		if hasBy {
+			rollout.By = strings.TrimSpace(by)
+		}
+		pct, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(pctStr, "%")), 64)
+		if err != nil {
+			pct = -1
+		}
+		rollout.Percentage = pct
+		rollouts[strings.TrimSpace(name)] = rollout
+	}
+	return rollouts
+}
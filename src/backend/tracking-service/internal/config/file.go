@@ -0,0 +1,135 @@
+package config
+
+// ------------------------
+// External Imports
+// ------------------------
+import (
+	"fmt"           // go1.21 - For wrapping config file errors
+	"path/filepath" // go1.21 - For detecting the config file format from its extension
+	"sort"          // go1.21 - For reporting unknown settings in a stable order
+	"strings"       // go1.21 - For mapping file keys to environment variable names
+
+	"github.com/spf13/viper" // v1.16.0 - For reading YAML and JSON config files
+)
+
+// ------------------------
+// fileSettings Struct
+// ------------------------
+//
+// fileSettings holds the settings read from a config file, keyed by the
+// environment variable each one stands in for, and records which of them
+// LoadConfigFile looked up so unknown settings can be reported.
+//
+type fileSettings struct {
+	values map[string]string
+	used   map[string]bool
+}
+
+// activeFile is the config file being applied by LoadConfigFile, consulted by
+// getEnvWithDefault; nil outside of it.
+var activeFile *fileSettings
+
+// ------------------------
+// LoadConfigFile Function
+// ------------------------
+//
+// LoadConfigFile loads the configuration like LoadConfig, taking settings
+// missing from the environment from the YAML or JSON file at path. An empty
+// path reads the environment only.
+//
+// Precedence, highest first:
+//   1. Environment variables
+//   2. The config file
+//   3. Built-in defaults
+//
+// Each file setting stands in for the environment variable named by its key
+// path joined with underscores and upper-cased, so
+//
+//   stream:
+//     drain_timeout: 15s
+//
+// sets STREAM_DRAIN_TIMEOUT, as does a top-level stream_drain_timeout key.
+// Lists are joined with commas. Settings that match no variable fail
+// validation, so typos are not silently ignored.
+//
+func LoadConfigFile(path string) (*Config, error) {
+	if path != "" {
+		settings, err := readConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		activeFile = settings
+		defer func() { activeFile = nil }()
+	}
+	return loadConfig()
+}
+
+// ------------------------
+// readConfigFile Function
+// ------------------------
+//
+// readConfigFile parses the YAML or JSON file at path into its settings.
+//
+func readConfigFile(path string) (*fileSettings, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml", ".json":
+	default:
+		return nil, fmt.Errorf("unsupported config file format %q: use .yaml, .yml or .json", ext)
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	keyReplacer := strings.NewReplacer(".", "_", "-", "_")
+	settings := &fileSettings{values: make(map[string]string), used: make(map[string]bool)}
+	for _, key := range v.AllKeys() {
+		var value string
+		switch raw := v.Get(key).(type) {
+		case []interface{}:
+			parts := make([]string, len(raw))
+			for i, part := range raw {
+				parts[i] = fmt.Sprint(part)
+			}
+			value = strings.Join(parts, ",")
+		case nil:
+			continue
+		default:
+			value = fmt.Sprint(raw)
+		}
+		settings.values[strings.ToUpper(keyReplacer.Replace(key))] = value
+	}
+	return settings, nil
+}
+
+// ------------------------
+// lookup Method
+// ------------------------
+//
+// lookup returns the file's value for the environment variable key, marking
+// it as used.
+//
+func (f *fileSettings) lookup(key string) (string, bool) {
+	f.used[key] = true
+	val, ok := f.values[key]
+	return val, ok
+}
+
+// ------------------------
+// unknownKeys Method
+// ------------------------
+//
+// unknownKeys returns the file settings that were never looked up, sorted.
+//
+func (f *fileSettings) unknownKeys() []string {
+	var unknown []string
+	for key := range f.values {
+		if !f.used[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
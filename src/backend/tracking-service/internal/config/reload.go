@@ -0,0 +1,274 @@
+package config
+
+// ------------------------
+// External Imports
+// ------------------------
+import (
+	"os"        // go1.21 - For checking the config file's modification time
+	"os/signal" // go1.21 - For reloading on SIGHUP
+	"sort"      // go1.21 - For reporting changed settings in a stable order
+	"sync"      // go1.21 - For serializing reloads and stopping the watcher once
+	"syscall"   // go1.21 - For the SIGHUP signal
+	"time"      // go1.21 - For the poll interval and reload timestamps
+)
+
+// reloadableSettings are the settings, named by environment variable, that
+// a running service applies when its configuration is reloaded. Every other
+// setting takes effect only after a restart.
+var reloadableSettings = map[string]bool{
+	"LOG_LEVEL":                      true,
+	"SERVICE_RATE_LIMIT":             true,
+	"REGION_BASE_MAX_SPEED_KMH":      true,
+	"REGION_BASE_GEOFENCE_RADIUS_KM": true,
+}
+
+// resolvedSettings collects the value of every setting looked up by
+// getEnvWithDefault while loadConfig runs; nil outside of it.
+var resolvedSettings map[string]string
+
+// ------------------------
+// ReloadableSettings Function
+// ------------------------
+//
+// ReloadableSettings returns the settings applied without a restart when the
+// configuration is reloaded, sorted.
+//
+func ReloadableSettings() []string {
+	keys := make([]string, 0, len(reloadableSettings))
+	for key := range reloadableSettings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ------------------------
+// Changes Method
+// ------------------------
+//
+// Changes returns the settings whose values differ between c and next, split
+// into those a reload applies and those requiring a restart, each sorted.
+// Settings are named by environment variable; values are not reported, as
+// some are secrets.
+//
+func (c *Config) Changes(next *Config) (reloadable []string, restart []string) {
+	keys := make(map[string]bool, len(c.settings))
+	for key := range c.settings {
+		keys[key] = true
+	}
+	for key := range next.settings {
+		keys[key] = true
+	}
+	for key := range keys {
+		oldVal, oldOK := c.settings[key]
+		newVal, newOK := next.settings[key]
+		if oldOK == newOK && oldVal == newVal {
+			continue
+		}
+		if reloadableSettings[key] {
+			reloadable = append(reloadable, key)
+		} else {
+			restart = append(restart, key)
+		}
+	}
+	sort.Strings(reloadable)
+	sort.Strings(restart)
+	return reloadable, restart
+}
+
+// ------------------------
+// ReloadStatus Struct
+// ------------------------
+//
+// ReloadStatus describes the configuration reloads of a running service.
+// Applied lists the reloadable settings the last successful reload changed,
+// and RestartRequired every setting changed since startup that only takes
+// effect after a restart. Error is the failure of the last reload, if it
+// failed; the previous configuration then stays in effect.
+//
+type ReloadStatus struct {
+	Reloadable      []string   `json:"reloadable"`
+	ReloadedAt      *time.Time `json:"reloadedAt,omitempty"`
+	Applied         []string   `json:"applied"`
+	RestartRequired []string   `json:"restartRequired"`
+	Error           string     `json:"error,omitempty"`
+}
+
+// ------------------------
+// ReloadFunc Type
+// ------------------------
+//
+// ReloadFunc is called after every reload attempt with the reloaded
+// configuration, to apply its reloadable settings, and the resulting status.
+// On failure cfg is nil and err is set.
+//
+type ReloadFunc func(cfg *Config, status ReloadStatus, err error)
+
+// ------------------------
+// Watcher Struct
+// ------------------------
+//
+// Watcher reloads the configuration of a running service from its config
+// file, and the environment over it, on SIGHUP and whenever the file's
+// modification time changes. Invalid configurations are rejected and leave
+// the previous one in effect.
+//
+type Watcher struct {
+	path         string
+	pollInterval time.Duration
+	onReload     ReloadFunc
+
+	mu      sync.Mutex
+	running *Config
+	current *Config
+	modTime time.Time
+	status  ReloadStatus
+
+	signals  chan os.Signal
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+// ------------------------
+// NewWatcher Function
+// ------------------------
+//
+// NewWatcher creates a watcher for the config file at path, of the service
+// that started with running. The file is checked for changes every
+// pollInterval; zero reloads on SIGHUP only. onReload must not call back into
+// the watcher.
+//
+func NewWatcher(path string, running *Config, pollInterval time.Duration, onReload ReloadFunc) *Watcher {
+	w := &Watcher{
+		path:         path,
+		pollInterval: pollInterval,
+		onReload:     onReload,
+		running:      running,
+		current:      running,
+		status: ReloadStatus{
+			Reloadable:      ReloadableSettings(),
+			Applied:         []string{},
+			RestartRequired: []string{},
+		},
+		signals: make(chan os.Signal, 1),
+		stop:    make(chan struct{}),
+	}
+	if info, err := os.Stat(path); err == nil {
+		w.modTime = info.ModTime()
+	}
+	return w
+}
+
+// ------------------------
+// Start Method
+// ------------------------
+//
+// Start listens for SIGHUP and polls the config file in the background.
+//
+func (w *Watcher) Start() {
+	signal.Notify(w.signals, syscall.SIGHUP)
+	go func() {
+		var poll <-chan time.Time
+		if w.pollInterval > 0 {
+			ticker := time.NewTicker(w.pollInterval)
+			defer ticker.Stop()
+			poll = ticker.C
+		}
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-w.signals:
+				_, _ = w.Reload()
+			case <-poll:
+				if w.fileChanged() {
+					_, _ = w.Reload()
+				}
+			}
+		}
+	}()
+}
+
+// ------------------------
+// Stop Method
+// ------------------------
+//
+// Stop ends watching and restores the default handling of SIGHUP.
+//
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() {
+		signal.Stop(w.signals)
+		close(w.stop)
+	})
+}
+
+// ------------------------
+// Reload Method
+// ------------------------
+//
+// Reload loads the configuration again, reports it to the ReloadFunc and
+// returns the resulting status.
+//
+func (w *Watcher) Reload() (ReloadStatus, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if info, err := os.Stat(w.path); err == nil {
+		w.modTime = info.ModTime()
+	}
+	next, err := LoadConfigFile(w.path)
+	if err != nil {
+		w.status.Error = err.Error()
+		w.onReload(nil, w.status, err)
+		return w.status, err
+	}
+
+	applied, _ := w.current.Changes(next)
+	_, restart := w.running.Changes(next)
+	if applied == nil {
+		applied = []string{}
+	}
+	if restart == nil {
+		restart = []string{}
+	}
+	w.current = next
+	reloadedAt := time.Now().UTC()
+	w.status = ReloadStatus{
+		Reloadable:      ReloadableSettings(),
+		ReloadedAt:      &reloadedAt,
+		Applied:         applied,
+		RestartRequired: restart,
+	}
+	w.onReload(next, w.status, nil)
+	return w.status, nil
+}
+
+// ------------------------
+// Status Method
+// ------------------------
+//
+// Status returns the outcome of the last reload.
+//
+func (w *Watcher) Status() ReloadStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+// ------------------------
+// fileChanged Method
+// ------------------------
+//
+// fileChanged reports whether the config file's modification time differs
+// from when it was last loaded. A missing file, as while an editor replaces
+// it, is not a change.
+//
+func (w *Watcher) fileChanged() bool {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return !info.ModTime().Equal(w.modTime)
+}
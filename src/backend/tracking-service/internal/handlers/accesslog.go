@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	// sha256 for identifying token holders without logging their tokens (go1.21)
+	"crypto/sha256"
+	// hex for encoding token fingerprints (go1.21)
+	"encoding/hex"
+	// http for request headers (go1.21)
+	"net/http"
+
+	// services package for recording walk accesses
+	"src/backend/tracking-service/internal/services"
+)
+
+// DefaultAccessActorHeader is the header naming who reads a walk's location
+// data, set by the gateway in front of the service once it has
+// authenticated the caller.
+const DefaultAccessActorHeader = "X-Actor-ID"
+
+// Actors recorded for reads whose caller has no actor header.
+const (
+	actorShareLink = "share-link"
+	actorAnonymous = "anonymous"
+)
+
+// SetAccessActorHeader sets the header naming who reads a walk's location data
+// in its access log. Empty restores DefaultAccessActorHeader.
+func (lh *LocationHandler) SetAccessActorHeader(name string) {
+	lh.accessActorHeader = name
+}
+
+// audited wraps core, an endpoint reading a walk's location data, so every
+// successful read is recorded in the access log of the walk or session named
+// by the idParam path parameter, or query parameter when the route has no
+// such path parameter. Failed reads returned no data and are not recorded.
+func (lh *LocationHandler) audited(endpoint, idParam string, core CoreHandler) CoreHandler {
+	return func(req Request) Response {
+		resp := core(req)
+		if resp.Status < http.StatusOK || resp.Status >= http.StatusMultipleChoices {
+			return resp
+		}
+		id := req.PathParam(idParam)
+		if id == "" {
+			id = req.QueryParam(idParam)
+		}
+		lh.recordAccess(lh.api(req), id, endpoint, req.Header, false, req.RequestID())
+		return resp
+	}
+}
+
+// recordAccess records a read of the location data of the walk or session id
+// through endpoint by the caller of a request with header. Share-link
+// viewers are recorded as such whatever headers they send.
+func (lh *LocationHandler) recordAccess(api services.TrackingAPI, id, endpoint string, header http.Header, shareLink bool, requestID string) {
+	if id == "" {
+		return
+	}
+	api.RecordWalkAccess(id, lh.accessActor(header, shareLink), endpoint, requestID)
+}
+
+// accessActor identifies the caller of a request with header: "share-link"
+// for share-link viewers, the actor header when it holds a usable value, a
+// fingerprint of the Authorization token, never the token itself, or
+// "anonymous".
+func (lh *LocationHandler) accessActor(header http.Header, shareLink bool) string {
+	if shareLink {
+		return actorShareLink
+	}
+	name := lh.accessActorHeader
+	if name == "" {
+		name = DefaultAccessActorHeader
+	}
+	if actor := header.Get(name); validRequestID(actor) {
+		return actor
+	}
+	if token := header.Get("Authorization"); token != "" {
+		sum := sha256.Sum256([]byte(token))
+		return "token:" + hex.EncodeToString(sum[:8])
+	}
+	return actorAnonymous
+}
@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	// crypto/hmac and crypto/sha256 for signing affinity tokens (go1.21)
+	"crypto/hmac"
+	"crypto/sha256"
+	// base64 for URL-safe token encoding (go1.21)
+	"encoding/base64"
+	// errors for the invalid token sentinel (go1.21)
+	"errors"
+	// http for redirects and cookies (go1.21)
+	"net/http"
+	// url for building redirect targets (go1.21)
+	"net/url"
+	// strings for splitting tokens (go1.21)
+	"strings"
+	// sync for guarding the drain state (go1.21)
+	"sync"
+
+	// gin for the HTTP middleware (github.com/gin-gonic/gin v1.9.1)
+	"github.com/gin-gonic/gin"
+
+	// prometheus for redirect metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+
+	// config for the affinity settings
+	"src/backend/tracking-service/internal/config"
+)
+
+// AffinityCookieName is the cookie carrying a session's affinity token, for
+// load balancers that route on cookies.
+const AffinityCookieName = "tracking_affinity"
+
+// NodeHeader names the instance that served a response, so load balancers and
+// clients can learn a session's node from its creation response.
+const NodeHeader = "X-Tracking-Node"
+
+// NodeHintHeader names the node a redirected client should reconnect to.
+const NodeHintHeader = "X-Tracking-Node-Hint"
+
+// Query parameters of stream connects: the affinity token, and the marker set
+// on redirects so a connect is redirected at most once.
+const (
+	affinityQueryParam      = "affinity"
+	affinityRedirectedParam = "affinityRedirected"
+)
+
+// ErrInvalidAffinityToken is returned when an affinity token is malformed, was
+// signed with a different secret, or belongs to another session.
+var ErrInvalidAffinityToken = errors.New("invalid affinity token")
+
+// SessionAffinity issues and enforces instance-affinity tokens. Live sessions
+// are held in the memory of the instance that created them, so their WebSocket
+// connects must reach that instance. The token names the node and is signed so
+// a client cannot pin itself to an arbitrary node; every instance shares the
+// secret, so any of them can verify it and redirect a misrouted connect.
+//
+// While draining, the instance redirects every connect to the fallback URL,
+// naming the handoff node when configured, instead of accepting it. Resumable
+// streams let subscribers pick up missed frames on the new node.
+type SessionAffinity struct {
+	nodeID   string
+	secret   []byte
+	fallback *url.URL
+
+	mu       sync.RWMutex
+	draining bool
+	handoff  string
+
+	redirects *prometheus.CounterVec
+}
+
+// NewSessionAffinity creates the affinity enforcer for this instance,
+// registering its metrics on registry when non-nil.
+func NewSessionAffinity(cfg config.AffinityConfig, registry *prometheus.Registry) (*SessionAffinity, error) {
+	fallback, err := url.Parse(cfg.FallbackURL)
+	if err != nil {
+		return nil, err
+	}
+	a := &SessionAffinity{
+		nodeID:   cfg.NodeID,
+		secret:   []byte(cfg.Secret),
+		fallback: fallback,
+		redirects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tracking_affinity_redirects_total",
+			Help: "Stream connects redirected to another node, by reason",
+		}, []string{"reason"}),
+	}
+	if registry != nil {
+		registry.MustRegister(a.redirects)
+	}
+	return a, nil
+}
+
+// NodeID returns the ID of this instance.
+func (a *SessionAffinity) NodeID() string {
+	return a.nodeID
+}
+
+// Issue returns the affinity token binding sessionID to this instance.
+func (a *SessionAffinity) Issue(sessionID string) string {
+	return a.issueFor(sessionID, a.nodeID)
+}
+
+// issueFor returns the token binding sessionID to nodeID, formatted as
+// base64url(sessionID).base64url(nodeID).base64url(hmac).
+func (a *SessionAffinity) issueFor(sessionID, nodeID string) string {
+	encodedID := base64.RawURLEncoding.EncodeToString([]byte(sessionID))
+	encodedNode := base64.RawURLEncoding.EncodeToString([]byte(nodeID))
+	return encodedID + "." + encodedNode + "." + a.sign(encodedID, encodedNode)
+}
+
+// Parse verifies token for sessionID and returns the node it binds to.
+func (a *SessionAffinity) Parse(sessionID, token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", ErrInvalidAffinityToken
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(a.sign(parts[0], parts[1]))) {
+		return "", ErrInvalidAffinityToken
+	}
+	decodedID, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || string(decodedID) != sessionID {
+		return "", ErrInvalidAffinityToken
+	}
+	node, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || len(node) == 0 {
+		return "", ErrInvalidAffinityToken
+	}
+	return string(node), nil
+}
+
+// sign computes the URL-safe HMAC-SHA256 over the encoded session and node.
+func (a *SessionAffinity) sign(encodedID, encodedNode string) string {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(encodedID + "." + encodedNode))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Cookie returns the affinity cookie carrying token.
+func (a *SessionAffinity) Cookie(token string) *http.Cookie {
+	return &http.Cookie{
+		Name:     AffinityCookieName,
+		Value:    token,
+		Path:     "/",
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// Drain makes the instance redirect every stream connect, naming handoffNode
+// as the node to reconnect to when non-empty.
+func (a *SessionAffinity) Drain(handoffNode string) {
+	a.mu.Lock()
+	a.draining = true
+	a.handoff = handoffNode
+	a.mu.Unlock()
+}
+
+// Draining reports whether Drain has been called, and the handoff node.
+func (a *SessionAffinity) Draining() (bool, string) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.draining, a.handoff
+}
+
+// Middleware enforces affinity on stream connects. The token is read from the
+// affinity query parameter, falling back to the affinity cookie; cookies left
+// over from another session are ignored, while an invalid query token is
+// rejected with 400.
+//
+// Steps:
+//  1. Accept connects already redirected once, so a stale load balancer can
+//     never cause a redirect loop
+//  2. Redirect every connect while draining, naming the handoff node
+//  3. Redirect connects whose token names another node to that node
+//  4. Accept everything else
+func (a *SessionAffinity) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header(NodeHeader, a.nodeID)
+		if c.Query(affinityRedirectedParam) != "" {
+			c.Next()
+			return
+		}
+
+		sessionID := c.Query("sessionID")
+		var node string
+		if token := c.Query(affinityQueryParam); token != "" {
+			var err error
+			if node, err = a.Parse(sessionID, token); err != nil {
+				AbortWithError(c, NewAPIError(http.StatusBadRequest, "", err.Error()))
+				return
+			}
+		} else if cookie, err := c.Cookie(AffinityCookieName); err == nil {
+			node, _ = a.Parse(sessionID, cookie)
+		}
+
+		if draining, handoff := a.Draining(); draining {
+			a.redirect(c, sessionID, handoff, "draining")
+			return
+		}
+		if node != "" && node != a.nodeID {
+			a.redirect(c, sessionID, node, "misrouted")
+			return
+		}
+		c.Next()
+	}
+}
+
+// redirect answers a connect with a 307 to the fallback URL, keeping its query
+// parameters. When node is known the redirect carries it as a hint and a fresh
+// token bound to it, so the load balancer routes the retried connect there.
+func (a *SessionAffinity) redirect(c *gin.Context, sessionID, node, reason string) {
+	query := c.Request.URL.Query()
+	query.Del(affinityQueryParam)
+	query.Set(affinityRedirectedParam, "1")
+	if node != "" && sessionID != "" {
+		token := a.issueFor(sessionID, node)
+		query.Set(affinityQueryParam, token)
+		http.SetCookie(c.Writer, a.Cookie(token))
+		c.Header(NodeHintHeader, node)
+	}
+
+	target := *a.fallback
+	target.RawQuery = query.Encode()
+	a.redirects.WithLabelValues(reason).Inc()
+	c.Redirect(http.StatusTemporaryRedirect, target.String())
+	c.Abort()
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	// json for encoding error bodies (go1.21)
+	"encoding/json"
+	// errors for translating error chains (go1.21)
+	"errors"
+	// http for status codes and status texts (go1.21)
+	"net/http"
+
+	// gin for the error-translation middleware (github.com/gin-gonic/gin v1.9.1)
+	"github.com/gin-gonic/gin"
+
+	// models package for model validation errors
+	"src/backend/tracking-service/internal/models"
+	// repository package for storage errors
+	"src/backend/tracking-service/internal/repository"
+)
+
+// RequestIDHeader carries the identifier of a request, set by
+// RequestIDMiddleware, returned in the response and in the requestId of error
+// bodies, and logged with the request so they can be matched with server logs.
+const RequestIDHeader = "X-Request-ID"
+
+// Error codes of APIError. Clients branch on the code; the message is for
+// people and may change.
+const (
+	CodeInvalidRequest   = "invalid_request"
+	CodeValidationFailed = "validation_failed"
+	CodeLocationRejected = "location_rejected"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeGone             = "gone"
+	CodePayloadTooLarge  = "payload_too_large"
+	CodeRateLimited      = "rate_limited"
+	CodeUnavailable      = "unavailable"
+	CodeInternal         = "internal_error"
+)
+
+// APIError is the error body of every endpoint:
+//
+//	{"code": "not_found", "message": "...", "details": ..., "requestId": "...", "error": "..."}
+//
+// error repeats the message for clients of the earlier {"error": message}
+// format.
+type APIError struct {
+	// Status is the HTTP status the error is returned with.
+	Status int `json:"-"`
+
+	Code    string `json:"code"`
+	Message string `json:"message"`
+
+	// Details holds structured information about the error, such as the
+	// schema violations of a request; omitted when nil.
+	Details interface{} `json:"details,omitempty"`
+
+	// RequestID is the request's X-Request-ID, when it has one.
+	RequestID string `json:"requestId,omitempty"`
+}
+
+// NewAPIError creates an error returned with status, coded with code, or with
+// the default code of status when code is empty.
+func NewAPIError(status int, code, message string) *APIError {
+	if code == "" {
+		code = codeForStatus(status)
+	}
+	return &APIError{Status: status, Code: code, Message: message}
+}
+
+// Error implements error.
+func (e *APIError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// WithDetails returns a copy of e carrying details.
+func (e *APIError) WithDetails(details interface{}) *APIError {
+	out := *e
+	out.Details = details
+	return &out
+}
+
+// withRequestID returns a copy of e carrying requestID.
+func (e *APIError) withRequestID(requestID string) *APIError {
+	out := *e
+	out.RequestID = requestID
+	return &out
+}
+
+// MarshalJSON adds the legacy error field to the encoded error.
+func (e *APIError) MarshalJSON() ([]byte, error) {
+	type alias APIError
+	return json.Marshal(struct {
+		*alias
+		Error string `json:"error"`
+	}{alias: (*alias)(e), Error: e.Message})
+}
+
+// codeForStatus returns the error code of responses with status that carry
+// no more specific one.
+func codeForStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeInvalidRequest
+	case http.StatusUnprocessableEntity:
+		return CodeValidationFailed
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound, http.StatusMethodNotAllowed:
+		return CodeNotFound
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusGone:
+		return CodeGone
+	case http.StatusRequestEntityTooLarge:
+		return CodePayloadTooLarge
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return CodeUnavailable
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeInvalidRequest
+}
+
+// TranslateError maps err to the API error it is returned as. Malformed model
+// fields are 400 validation_failed, points the session refuses are 422
+// location_rejected, invalid session transitions are 409, and storage errors
+// follow repositoryErrorStatus. Anything else is a 500 whose message does not
+// leak the internal error.
+func TranslateError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	var (
+		invalidWalkID    models.ErrInvalidWalkID
+		outOfRange       models.ErrOutOfRange
+		invalidProvider  models.ErrInvalidProvider
+		invalidTimestamp models.ErrInvalidTimestamp
+	)
+	switch {
+	case errors.As(err, &invalidWalkID), errors.As(err, &outOfRange),
+		errors.As(err, &invalidProvider), errors.As(err, &invalidTimestamp),
+		errors.Is(err, models.ErrInvalidID):
+		return NewAPIError(http.StatusBadRequest, CodeValidationFailed, err.Error())
+	case errors.Is(err, models.ErrLocationAccuracyTooLow), errors.Is(err, models.ErrImplausibleSpeed):
+		return NewAPIError(http.StatusUnprocessableEntity, CodeLocationRejected, err.Error())
+	case errors.Is(err, models.ErrInvalidStatusTransition):
+		return NewAPIError(http.StatusConflict, CodeConflict, err.Error())
+	case errors.Is(err, repository.ErrInvalidInput):
+		return NewAPIError(http.StatusBadRequest, CodeInvalidRequest, err.Error())
+	}
+	switch status := repositoryErrorStatus(err); status {
+	case http.StatusNotFound:
+		return NewAPIError(status, CodeNotFound, "not found")
+	case http.StatusServiceUnavailable:
+		return NewAPIError(status, CodeUnavailable, "storage temporarily unavailable")
+	}
+	return NewAPIError(http.StatusInternalServerError, CodeInternal, "internal server error")
+}
+
+// apiErrorResponse builds the response of e.
+func apiErrorResponse(e *APIError) Response {
+	body, _ := json.Marshal(e)
+	return Response{Status: e.Status, Header: http.Header{"Content-Type": {jsonContentType}}, Body: body, apiError: e}
+}
+
+// translatedErrorResponse builds the response of TranslateError(err), with
+// message in place of the generic message of internal errors.
+func translatedErrorResponse(err error, message string) Response {
+	apiErr := TranslateError(err)
+	if apiErr.Code == CodeInternal {
+		apiErr = NewAPIError(apiErr.Status, CodeInternal, message)
+	}
+	return apiErrorResponse(apiErr)
+}
+
+// requestID returns the identifier of the request in c.
+func requestID(c *gin.Context) string {
+	return c.GetHeader(RequestIDHeader)
+}
+
+// withRequestID returns resp with its error body, if it has one, stamped with
+// requestID.
+func (resp Response) withRequestID(requestID string) Response {
+	if resp.apiError == nil || requestID == "" {
+		return resp
+	}
+	stamped := apiErrorResponse(resp.apiError.withRequestID(requestID))
+	stamped.Header = resp.Header
+	return stamped
+}
+
+// AbortWithError aborts c with e, stamped with the request's identifier, and
+// attaches e to the context's errors.
+func AbortWithError(c *gin.Context, e *APIError) {
+	_ = c.Error(e)
+	c.AbortWithStatusJSON(e.Status, e.withRequestID(requestID(c)))
+}
+
+// ErrorMiddleware writes the errors of requests that end without a response.
+// Errors handlers attach with c.Error are translated by TranslateError, and
+// error statuses set without a body, such as gin's 404 for unknown routes,
+// get the APIError of their status. It must be registered before the
+// middleware and handlers whose errors it translates.
+func ErrorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if c.Writer.Written() {
+			return
+		}
+		if last := c.Errors.Last(); last != nil {
+			apiErr := TranslateError(last.Err)
+			c.AbortWithStatusJSON(apiErr.Status, apiErr.withRequestID(requestID(c)))
+			return
+		}
+		if status := c.Writer.Status(); status >= http.StatusBadRequest {
+			apiErr := NewAPIError(status, "", http.StatusText(status))
+			c.AbortWithStatusJSON(status, apiErr.withRequestID(requestID(c)))
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	// bytes for reading and building compressed bodies (go1.21)
+	"bytes"
+	// flate for raw deflate request bodies (go1.21)
+	"compress/flate"
+	// gzip for gzip request and response bodies (go1.21)
+	"compress/gzip"
+	// zlib for deflate request bodies (go1.21)
+	"compress/zlib"
+	// errors for the decoding sentinels (go1.21)
+	"errors"
+	// io for bounded reads of request bodies (go1.21)
+	"io"
+	// http for status codes (go1.21)
+	"net/http"
+	// strconv for the quality values of Accept-Encoding (go1.21)
+	"strconv"
+	// strings for parsing encoding headers (go1.21)
+	"strings"
+
+	// gin for the decompression middleware (github.com/gin-gonic/gin v1.9.1)
+	"github.com/gin-gonic/gin"
+	// websocket for per-message compression (github.com/gorilla/websocket v1.5.0)
+	"github.com/gorilla/websocket"
+)
+
+// Content codings accepted on request bodies and applied to responses.
+const (
+	encodingGzip    = "gzip"
+	encodingDeflate = "deflate"
+)
+
+// wsCompressionThreshold is the smallest outbound WebSocket message sent
+// compressed when the client negotiated permessage-deflate. Smaller messages,
+// such as acks and single location frames, barely shrink and are sent as
+// they are.
+var wsCompressionThreshold = 512
+
+var (
+	// errUnsupportedEncoding is returned for request bodies in a content
+	// coding other than gzip and deflate.
+	errUnsupportedEncoding = errors.New("unsupported content encoding")
+
+	// errBodyTooLarge is returned for request bodies that decompress to more
+	// than maxRequestBodySize bytes.
+	errBodyTooLarge = errors.New("decompressed request body is too large")
+)
+
+// decodeContentEncoding returns body decoded from the content coding named by
+// a Content-Encoding header: gzip, or deflate as zlib or raw deflate data.
+// Decoded bodies are bounded by maxRequestBodySize, so small compressed
+// bodies cannot expand without limit.
+func decodeContentEncoding(encoding string, body []byte) ([]byte, error) {
+	var r io.Reader
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return body, nil
+	case encodingGzip, "x-gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	case encodingDeflate:
+		// Some clients send raw deflate data rather than the zlib stream
+		// RFC 9110 specifies.
+		if zr, err := zlib.NewReader(bytes.NewReader(body)); err == nil {
+			defer zr.Close()
+			r = zr
+		} else {
+			fr := flate.NewReader(bytes.NewReader(body))
+			defer fr.Close()
+			r = fr
+		}
+	default:
+		return nil, errUnsupportedEncoding
+	}
+
+	decoded, err := io.ReadAll(io.LimitReader(r, maxRequestBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) > maxRequestBodySize {
+		return nil, errBodyTooLarge
+	}
+	return decoded, nil
+}
+
+// decodeErrorStatus maps a decodeContentEncoding error to its HTTP status.
+func decodeErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, errUnsupportedEncoding):
+		return http.StatusUnsupportedMediaType, "Content-Encoding must be gzip or deflate"
+	case errors.Is(err, errBodyTooLarge):
+		return http.StatusRequestEntityTooLarge, err.Error()
+	default:
+		return http.StatusBadRequest, "request body is not valid compressed data"
+	}
+}
+
+// DecompressionMiddleware decodes gzip and deflate request bodies, such as
+// compressed batch uploads from mobile clients, so the handlers and the
+// OpenAPI validator after it read them as sent uncompressed. Other codings
+// are rejected with 415. It must be registered before the validator.
+func DecompressionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		encoding := c.GetHeader("Content-Encoding")
+		if encoding == "" || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxRequestBodySize+1))
+		switch {
+		case err != nil:
+		case len(body) > maxRequestBodySize:
+			err = errBodyTooLarge
+		default:
+			body, err = decodeContentEncoding(encoding, body)
+		}
+		if err != nil {
+			status, message := decodeErrorStatus(err)
+			AbortWithError(c, NewAPIError(status, "", message))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Request.ContentLength = int64(len(body))
+		c.Request.Header.Del("Content-Encoding")
+		c.Request.Header.Del("Content-Length")
+		c.Next()
+	}
+}
+
+// SetResponseCompression compresses the responses of the history endpoints
+// of at least minBytes bytes for clients that accept gzip or deflate. Zero
+// disables it.
+func (lh *LocationHandler) SetResponseCompression(minBytes int) {
+	lh.compressMinBytes = minBytes
+}
+
+// compressed wraps core so its responses are compressed as configured by
+// SetResponseCompression.
+func (lh *LocationHandler) compressed(core CoreHandler) CoreHandler {
+	return func(req Request) Response {
+		resp := core(req)
+		if lh.compressMinBytes <= 0 {
+			return resp
+		}
+		return compressResponse(req, resp, lh.compressMinBytes)
+	}
+}
+
+// compressResponse encodes resp's body in the coding req accepts, preferring
+// gzip, when it is at least minBytes long and not already encoded. Every
+// response of a compressible endpoint varies by Accept-Encoding.
+func compressResponse(req Request, resp Response, minBytes int) Response {
+	if resp.Header == nil {
+		resp.Header = http.Header{}
+	}
+	resp.Header.Add("Vary", "Accept-Encoding")
+	if len(resp.Body) < minBytes || resp.Header.Get("Content-Encoding") != "" {
+		return resp
+	}
+	encoding := negotiateEncoding(req.HeaderValue("Accept-Encoding"))
+	if encoding == "" {
+		return resp
+	}
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	if encoding == encodingGzip {
+		w, _ = gzip.NewWriterLevel(&buf, gzip.BestSpeed)
+	} else {
+		w, _ = zlib.NewWriterLevel(&buf, zlib.BestSpeed)
+	}
+	if _, err := w.Write(resp.Body); err != nil {
+		return resp
+	}
+	if err := w.Close(); err != nil {
+		return resp
+	}
+	resp.Body = buf.Bytes()
+	resp.Header.Set("Content-Encoding", encoding)
+	return resp
+}
+
+// negotiateEncoding returns the response coding an Accept-Encoding header
+// allows, gzip over deflate, or "" for neither. Codings with q=0 are refused,
+// and "*" accepts either.
+func negotiateEncoding(accept string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		ok := true
+		for _, param := range fields[1:] {
+			name, value, found := strings.Cut(strings.TrimSpace(param), "=")
+			if found && strings.EqualFold(name, "q") {
+				if q, err := strconv.ParseFloat(value, 64); err == nil && q == 0 {
+					ok = false
+				}
+			}
+		}
+		if coding != "" {
+			accepted[coding] = ok
+		}
+	}
+	for _, coding := range []string{encodingGzip, encodingDeflate} {
+		if ok, listed := accepted[coding]; listed {
+			if ok {
+				return coding
+			}
+			continue
+		}
+		if accepted["*"] {
+			return coding
+		}
+	}
+	return ""
+}
+
+// writeWSMessage writes a data message, compressed only when it is large
+// enough to benefit. The caller must be the connection's only writer.
+func writeWSMessage(conn *websocket.Conn, messageType int, data []byte) error {
+	conn.EnableWriteCompression(len(data) >= wsCompressionThreshold)
+	return conn.WriteMessage(messageType, data)
+}
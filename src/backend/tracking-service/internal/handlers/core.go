@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	// json for encoding response bodies and decoding request bodies (go1.21)
+	"encoding/json"
+	// io for reading request bodies (go1.21)
+	"io"
+	// http for status codes and headers (go1.21)
+	"net/http"
+	// url for query parameters (go1.21)
+	"net/url"
+
+	// gin for the HTTP adapter (github.com/gin-gonic/gin v1.9.1)
+	"github.com/gin-gonic/gin"
+)
+
+// jsonContentType is the content type of every JSON response, matching gin's.
+const jsonContentType = "application/json; charset=utf-8"
+
+// maxRequestBodySize bounds the request bodies read by the adapters.
+const maxRequestBodySize = 4 << 20
+
+// Request is a transport-agnostic HTTP request, built by an adapter from a gin
+// context, an API Gateway event or any other framework's request.
+type Request struct {
+	// PathParams holds the route's path parameters by name, e.g. "sessionID".
+	PathParams map[string]string
+	Query      url.Values
+	Header     http.Header
+	Body       []byte
+}
+
+// PathParam returns the named path parameter, or "" when absent.
+func (r Request) PathParam(name string) string {
+	return r.PathParams[name]
+}
+
+// QueryParam returns the first value of the named query parameter.
+func (r Request) QueryParam(name string) string {
+	return r.Query.Get(name)
+}
+
+// HeaderValue returns the first value of the named header.
+func (r Request) HeaderValue(name string) string {
+	return r.Header.Get(name)
+}
+
+// decodeJSON unmarshals the request body into v.
+func (r Request) decodeJSON(v interface{}) error {
+	return json.Unmarshal(r.Body, v)
+}
+
+// Response is a transport-agnostic HTTP response for an adapter to write out.
+type Response struct {
+	Status int
+	Header http.Header
+	Body   []byte
+
+	// apiError is the error Body encodes, so adapters can stamp it with the
+	// request's identifier; nil for other responses.
+	apiError *APIError
+}
+
+// CoreHandler is the business logic of one endpoint: parse and validate the
+// request, process it through the tracking service and build the response,
+// with no dependency on the HTTP framework it is mounted in.
+type CoreHandler func(Request) Response
+
+// jsonResponse encodes v as a JSON response with status.
+func jsonResponse(status int, v interface{}) Response {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return errorResponse(http.StatusInternalServerError, "failed to encode response")
+	}
+	return Response{Status: status, Header: http.Header{"Content-Type": {jsonContentType}}, Body: body}
+}
+
+// errorResponse builds the APIError body used by every endpoint, coded by
+// status.
+func errorResponse(status int, message string) Response {
+	return apiErrorResponse(NewAPIError(status, "", message))
+}
+
+// ---------------------------------------------------------------------------
+// Core Routes
+// ---------------------------------------------------------------------------
+
+// CoreRoute binds a CoreHandler to a method and a path pattern in gin syntax,
+// with ":name" segments for path parameters.
+type CoreRoute struct {
+	Method  string
+	Path    string
+	Handler CoreHandler
+}
+
+// CoreRoutes lists the location endpoints whose logic is framework-agnostic, for
+// mounting outside gin. The WebSocket stream is not included: it needs a
+// long-lived connection that request/response runtimes such as AWS Lambda
+// cannot hold.
+func (lh *LocationHandler) CoreRoutes() []CoreRoute {
+	return []CoreRoute{
+		{http.MethodPost, "/sessions", lh.StartSession},
+		{http.MethodPost, "/location", lh.LocationUpdate},
+		{http.MethodPost, "/location/batch", lh.LocationBatch},
+		{http.MethodGet, "/location/history", lh.compressed(lh.audited("GET /location/history", "sessionID", lh.GetLocationHistory))},
+		{http.MethodGet, "/location/history/export", lh.compressed(lh.audited("GET /location/history/export", "sessionID", lh.ExportLocationHistory))},
+		{http.MethodGet, "/walks/:walkID/territory", lh.audited("GET /walks/:walkID/territory", "walkID", lh.GetWalkTerritory)},
+		{http.MethodPost, "/walks/:walkID/geofences", lh.CreateWalkGeofence},
+		{http.MethodGet, "/walks/:walkID/geofences", lh.GetWalkGeofences},
+		{http.MethodGet, "/walks/:walkID/access-log", lh.GetWalkAccessLog},
+		{http.MethodGet, "/sessions/:sessionID/events/raw", lh.compressed(lh.audited("GET /sessions/:sessionID/events/raw", "sessionID", lh.GetRawSessionEvents))},
+		{http.MethodGet, "/sessions/:sessionID/locations", lh.compressed(lh.audited("GET /sessions/:sessionID/locations", "sessionID", lh.GetSessionLocations))},
+		{http.MethodGet, "/walkers/presence", lh.GetWalkerPresence},
+		{http.MethodGet, "/sessions/:sessionID/export.fit", lh.audited("GET /sessions/:sessionID/export.fit", "sessionID", lh.ExportSessionFIT)},
+		{http.MethodGet, "/sessions/:sessionID/statistics", lh.audited("GET /sessions/:sessionID/statistics", "sessionID", lh.GetSessionStatistics)},
+		{http.MethodGet, "/sessions/:sessionID/sparkline", lh.audited("GET /sessions/:sessionID/sparkline", "sessionID", lh.GetSessionSparkline)},
+		{http.MethodGet, "/sessions/:sessionID/breaches", lh.GetSessionBreaches},
+		{http.MethodPut, "/owners/:ownerID/fitness/:provider", lh.PutFitnessToken},
+		{http.MethodGet, "/owners/:ownerID/dogs/:dogID/alert-preferences", lh.GetOwnerAlertPreference},
+		{http.MethodPut, "/owners/:ownerID/dogs/:dogID/alert-preferences", lh.PutOwnerAlertPreference},
+		{http.MethodDelete, "/owners/:ownerID/dogs/:dogID/alert-preferences", lh.DeleteOwnerAlertPreference},
+		{http.MethodPost, "/walkers/:walkerID/devices", lh.PairDevice},
+		{http.MethodGet, "/walkers/:walkerID/devices", lh.GetWalkerDevices},
+		{http.MethodDelete, "/walkers/:walkerID/devices/:deviceID", lh.RevokeDevice},
+		{http.MethodGet, "/analytics/popular-routes", lh.GetPopularRoutes},
+		{http.MethodGet, "/analytics/demand", lh.GetDemand},
+		{http.MethodGet, "/analytics/leaderboard", lh.GetLeaderboard},
+		{http.MethodGet, "/current-walks", lh.GetCurrentWalks},
+		{http.MethodGet, "/current-walks/changes", lh.GetCurrentWalkChanges},
+		{http.MethodPost, "/query/locations", lh.QueryLocations},
+		{http.MethodPost, "/sessions/:sessionID/uploads", lh.PostSequencedUpload},
+		{http.MethodPost, "/sessions/:sessionID/beacons", lh.PostBeaconEvents},
+		{http.MethodGet, "/sessions/:sessionID/timeline", lh.audited("GET /sessions/:sessionID/timeline", "sessionID", lh.GetSessionTimeline)},
+		{http.MethodGet, "/admin/slo", lh.GetSLOStatus},
+		{http.MethodGet, "/admin/integrity", lh.GetIntegrityReport},
+		{http.MethodGet, "/admin/config", lh.GetConfigReloadStatus},
+		{http.MethodGet, "/admin/regions", lh.GetRegionProfiles},
+		{http.MethodGet, "/admin/regions/:region", lh.GetRegionProfile},
+		{http.MethodPut, "/admin/regions/:region", lh.PutRegionProfile},
+		{http.MethodDelete, "/admin/regions/:region", lh.DeleteRegionProfile},
+		{http.MethodGet, "/admin/geofence-groups", lh.GetGeofenceGroups},
+		{http.MethodPut, "/admin/geofence-groups/:name", lh.PutGeofenceGroup},
+		{http.MethodDelete, "/admin/geofence-groups/:name", lh.DeleteGeofenceGroup},
+		{http.MethodPut, "/admin/sessions/:sessionID/batch-policy", lh.PutSessionBatchPolicy},
+		{http.MethodPost, "/admin/sessions/:sessionID/rebuild", lh.RebuildSession},
+		{http.MethodGet, "/admin/retention/preview", lh.GetRetentionPreview},
+		{http.MethodPost, "/sessions/:sessionID/share-links", lh.CreateShareLink},
+		{http.MethodPut, "/sessions/:sessionID/subscribers/:subscriberID", lh.ShareSession},
+		{http.MethodDelete, "/sessions/:sessionID/subscribers/:subscriberID", lh.UnshareSession},
+		{http.MethodGet, "/sessions/:sessionID/subscribers/:subscriberID/stream-key", lh.GetStreamKey},
+		{http.MethodGet, "/admin/quarantine", lh.GetQuarantinedPoints},
+		{http.MethodPost, "/admin/quarantine/reingest", lh.ReingestQuarantined},
+		{http.MethodPost, "/admin/escrow/sessions/:sessionID/keys", lh.AccessEscrowedKeys},
+		{http.MethodGet, "/admin/escrow/audit", lh.GetEscrowAccessLog},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Gin Adapter
+// ---------------------------------------------------------------------------
+
+// serveGin runs core for a gin request and writes its response.
+func serveGin(c *gin.Context, core CoreHandler) {
+	req := Request{
+		PathParams: make(map[string]string, len(c.Params)),
+		Query:      c.Request.URL.Query(),
+		Header:     c.Request.Header,
+	}
+	for _, p := range c.Params {
+		req.PathParams[p.Key] = p.Value
+	}
+	if c.Request.Body != nil {
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxRequestBodySize))
+		if err != nil {
+			AbortWithError(c, NewAPIError(http.StatusBadRequest, "", "failed to read request body"))
+			return
+		}
+		req.Body = body
+	}
+
+	resp := core(req).withRequestID(requestID(c))
+	for key, values := range resp.Header {
+		for _, v := range values {
+			c.Writer.Header().Add(key, v)
+		}
+	}
+	if len(resp.Body) == 0 {
+		c.Status(resp.Status)
+		return
+	}
+	c.Data(resp.Status, resp.Header.Get("Content-Type"), resp.Body)
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	// crypto/hmac and crypto/sha256 for signing page cursors (go1.21)
+	"crypto/hmac"
+	"crypto/sha256"
+	// crypto/rand for the per-instance secret when none is configured (go1.21)
+	"crypto/rand"
+	// base64 for URL-safe cursor encoding (go1.21)
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	// models package for the PageCursor struct
+	"src/backend/tracking-service/internal/models"
+)
+
+// ErrInvalidPageCursor is returned when a page cursor is malformed, was signed
+// with a different secret, or belongs to another listing.
+var ErrInvalidPageCursor = errors.New("invalid page cursor")
+
+// PageCursorSigner issues and verifies the opaque cursors of paginated
+// listings. A cursor records the timestamp and ID of the last item of a page
+// and is signed together with the listing it was issued for, so clients can
+// neither forge positions nor replay a cursor against another session's or
+// tenant's listing.
+type PageCursorSigner struct {
+	secret []byte
+}
+
+// NewPageCursorSigner creates a signer using the shared secret. Without one it
+// draws a random secret, so cursors are honoured only by this instance until
+// it restarts.
+func NewPageCursorSigner(secret []byte) *PageCursorSigner {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic("page cursor secret: " + err.Error())
+		}
+	}
+	return &PageCursorSigner{secret: append([]byte(nil), secret...)}
+}
+
+// Encode returns the cursor of position c in the listing named by scope,
+// formatted as base64url(unixNanos.id).base64url(hmac).
+func (s *PageCursorSigner) Encode(scope string, c models.PageCursor) string {
+	body := base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(c.Timestamp.UnixNano(), 10) + "." + c.ID))
+	return body + "." + s.sign(scope, body)
+}
+
+// Decode verifies cursor for the listing named by scope and returns its
+// position.
+func (s *PageCursorSigner) Decode(scope, cursor string) (models.PageCursor, error) {
+	body, sig, ok := strings.Cut(cursor, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(s.sign(scope, body))) {
+		return models.PageCursor{}, ErrInvalidPageCursor
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return models.PageCursor{}, ErrInvalidPageCursor
+	}
+	nanosStr, id, ok := strings.Cut(string(decoded), ".")
+	if !ok {
+		return models.PageCursor{}, ErrInvalidPageCursor
+	}
+	nanos, err := strconv.ParseInt(nanosStr, 10, 64)
+	if err != nil {
+		return models.PageCursor{}, ErrInvalidPageCursor
+	}
+	return models.PageCursor{Timestamp: time.Unix(0, nanos).UTC(), ID: id}, nil
+}
+
+// sign computes the URL-safe HMAC-SHA256 over the listing scope and the
+// encoded position.
+func (s *PageCursorSigner) sign(scope, body string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(scope + "\n" + body))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SetPageCursorSigner signs the cursors of paginated listings with signer,
+// which must share its secret with every instance behind the load balancer.
+func (lh *LocationHandler) SetPageCursorSigner(signer *PageCursorSigner) {
+	lh.cursors = signer
+}
+
+// pageQuery parses the limit and cursor query parameters of a paginated
+// listing named by scope. A missing cursor starts the listing and a missing
+// limit leaves the page size to the service.
+func (lh *LocationHandler) pageQuery(req Request, scope string) (models.PageCursor, int, error) {
+	limit := 0
+	if limitStr := req.QueryParam("limit"); limitStr != "" {
+		var err error
+		if limit, err = strconv.Atoi(limitStr); err != nil || limit < 1 {
+			return models.PageCursor{}, 0, errors.New("limit must be a positive integer")
+		}
+	}
+	var after models.PageCursor
+	if cursor := req.QueryParam("cursor"); cursor != "" {
+		var err error
+		if after, err = lh.cursors.Decode(scope, cursor); err != nil {
+			return models.PageCursor{}, 0, err
+		}
+	}
+	return after, limit, nil
+}
+
+// nextPageCursor encodes the cursor of the next page of the listing named by
+// scope, or returns "" on the last page.
+func (lh *LocationHandler) nextPageCursor(scope string, next *models.PageCursor) string {
+	if next == nil {
+		return ""
+	}
+	return lh.cursors.Encode(scope, *next)
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	// list for least-recently-used eviction (go1.21)
+	"container/list"
+	// http for response headers (go1.21)
+	"net/http"
+	// strconv for the staleness header (go1.21)
+	"strconv"
+	// sync for guarding the cache (go1.21)
+	"sync"
+	// time for entry ages (go1.21)
+	"time"
+)
+
+// StalenessHeader carries the age in seconds of a last-known response served
+// while the database is unavailable.
+const StalenessHeader = "X-Data-Staleness"
+
+// staleWarning is the RFC 7234 warning marking a stale response.
+const staleWarning = `110 - "Response is Stale"`
+
+// LastKnownCache remembers the latest successful response of history
+// endpoints, so that while the database is unavailable they answer with the
+// last-known data, marked stale, instead of an error. Entries older than
+// maxAge are not served, and the least recently used are evicted beyond
+// maxEntries.
+type LastKnownCache struct {
+	maxEntries int
+	maxAge     time.Duration
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// lastKnownEntry is one remembered response value.
+type lastKnownEntry struct {
+	key      string
+	value    interface{}
+	storedAt time.Time
+}
+
+// NewLastKnownCache creates a cache of up to maxEntries responses served for
+// up to maxAge after they were remembered.
+func NewLastKnownCache(maxEntries int, maxAge time.Duration) *LastKnownCache {
+	return &LastKnownCache{
+		maxEntries: maxEntries,
+		maxAge:     maxAge,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// remember records value as the latest response for key. A nil cache ignores it.
+func (c *LastKnownCache) remember(key string, value interface{}) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = &lastKnownEntry{key: key, value: value, storedAt: time.Now()}
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lastKnownEntry{key: key, value: value, storedAt: time.Now()})
+	for c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lastKnownEntry).key)
+	}
+}
+
+// recall returns the latest response for key and its age, when one younger
+// than maxAge is remembered.
+func (c *LastKnownCache) recall(key string) (interface{}, time.Duration, bool) {
+	if c == nil {
+		return nil, 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, 0, false
+	}
+	entry := elem.Value.(*lastKnownEntry)
+	age := time.Since(entry.storedAt)
+	if c.maxAge > 0 && age > c.maxAge {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return nil, 0, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.value, age, true
+}
+
+// serveLastKnown answers a request that failed with err from the last-known
+// cache when the failure is on the server side, such as the database being
+// down, shaped by fields and marked with its staleness. It reports false when
+// the response must be an error.
+func (lh *LocationHandler) serveLastKnown(key string, fields fieldset, err error) (Response, bool) {
+	if err == nil || repositoryErrorStatus(err) < http.StatusInternalServerError {
+		return Response{}, false
+	}
+	value, age, ok := lh.lastKnown.recall(key)
+	if !ok {
+		return Response{}, false
+	}
+	resp := fields.response(http.StatusOK, value)
+	if resp.Status == http.StatusOK {
+		resp.Header.Set(StalenessHeader, strconv.Itoa(int(age.Seconds())))
+		resp.Header.Set("Warning", staleWarning)
+	}
+	return resp, true
+}
+
+// SetLastKnownCache enables serving last-known history data while the
+// database is unavailable. Passing nil disables it.
+func (lh *LocationHandler) SetLastKnownCache(cache *LastKnownCache) {
+	lh.lastKnown = cache
+}
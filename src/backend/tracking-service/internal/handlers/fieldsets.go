@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	// json for re-encoding shaped responses (go1.21)
+	"encoding/json"
+	// fmt for field errors (go1.21)
+	"fmt"
+	// http for status codes (go1.21)
+	"net/http"
+	// reflect for checking requested fields against the response type (go1.21)
+	"reflect"
+	// strings for parsing field paths and JSON tags (go1.21)
+	"strings"
+)
+
+// fieldsQueryParam is the query parameter selecting a sparse fieldset.
+const fieldsQueryParam = "fields"
+
+// fieldset is a parsed sparse fieldset, JSON:API style: the selected member
+// names at one level of a response, each mapped to the fieldset of its own
+// members, or to nil when selected whole. A nil fieldset selects everything.
+type fieldset map[string]fieldset
+
+// requestFieldset parses the fields query parameter of req, a comma-separated
+// list of JSON member names with dots selecting nested members, e.g.
+// "TotalDistance,Coverage.areaSqMeters", and checks every name against the
+// JSON encoding of shape. An absent parameter yields a nil fieldset.
+func requestFieldset(req Request, shape interface{}) (fieldset, error) {
+	raw, ok := req.Query[fieldsQueryParam]
+	if !ok {
+		return nil, nil
+	}
+	fields := fieldset{}
+	for _, path := range splitFieldList(strings.Join(raw, ",")) {
+		node := fields
+		names := strings.Split(path, ".")
+		for i, name := range names {
+			if name == "" {
+				return nil, fmt.Errorf("invalid field %q", path)
+			}
+			child, seen := node[name]
+			if i == len(names)-1 {
+				node[name] = nil
+				break
+			}
+			if seen && child == nil {
+				// The member is already selected whole.
+				break
+			}
+			if child == nil {
+				child = fieldset{}
+				node[name] = child
+			}
+			node = child
+		}
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%s must name at least one field", fieldsQueryParam)
+	}
+	if err := fields.check(reflect.TypeOf(shape), ""); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// splitFieldList splits a comma-separated field list, dropping blanks.
+func splitFieldList(raw string) []string {
+	var out []string
+	for _, part := range strings.Split(raw, ",") {
+		if trimmed := strings.TrimSpace(part); trimmed != "" {
+			out = append(out, trimmed)
+		}
+	}
+	return out
+}
+
+// check reports the first requested member that t's JSON encoding does not
+// have. Maps accept any member name and interfaces any path.
+func (fs fieldset) check(t reflect.Type, prefix string) error {
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() == reflect.Interface {
+		return nil
+	}
+	for name, sub := range fs {
+		var memberType reflect.Type
+		switch t.Kind() {
+		case reflect.Map:
+			memberType = t.Elem()
+		case reflect.Struct:
+			var ok bool
+			if memberType, ok = jsonMembers(t)[name]; !ok {
+				return fmt.Errorf("unknown field %q", prefix+name)
+			}
+		default:
+			return fmt.Errorf("field %q has no members", strings.TrimSuffix(prefix, "."))
+		}
+		if sub != nil {
+			if err := sub.check(memberType, prefix+name+"."); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// jsonMembers maps the JSON member names of struct type t to their types,
+// following encoding/json's rules for tags, unexported fields and embedded
+// structs.
+func jsonMembers(t reflect.Type) map[string]reflect.Type {
+	members := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			embedded := f.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for n, mt := range jsonMembers(embedded) {
+					if _, ok := members[n]; !ok {
+						members[n] = mt
+					}
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		members[name] = f.Type
+	}
+	return members
+}
+
+// apply strips every member not in fs from the JSON value raw. Arrays are
+// shaped element by element, so a fieldset on a list response selects the
+// members of each item.
+func (fs fieldset) apply(raw json.RawMessage) (json.RawMessage, error) {
+	if fs == nil {
+		return raw, nil
+	}
+	switch firstJSONByte(raw) {
+	case '{':
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &object); err != nil {
+			return nil, err
+		}
+		shaped := make(map[string]json.RawMessage, len(fs))
+		for name, sub := range fs {
+			value, ok := object[name]
+			if !ok {
+				continue
+			}
+			value, err := sub.apply(value)
+			if err != nil {
+				return nil, err
+			}
+			shaped[name] = value
+		}
+		return json.Marshal(shaped)
+	case '[':
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return nil, err
+		}
+		for i, item := range items {
+			shapedItem, err := fs.apply(item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = shapedItem
+		}
+		return json.Marshal(items)
+	default:
+		return raw, nil
+	}
+}
+
+// firstJSONByte returns the first non-whitespace byte of raw, or 0.
+func firstJSONByte(raw []byte) byte {
+	for _, b := range raw {
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		default:
+			return b
+		}
+	}
+	return 0
+}
+
+// response encodes v as a JSON response with status, keeping only the
+// members selected by fs.
+func (fs fieldset) response(status int, v interface{}) Response {
+	resp := jsonResponse(status, v)
+	if fs == nil || resp.Status != status {
+		return resp
+	}
+	body, err := fs.apply(resp.Body)
+	if err != nil {
+		return errorResponse(http.StatusInternalServerError, "failed to encode response")
+	}
+	resp.Body = body
+	return resp
+}
@@ -0,0 +1,560 @@
+package handlers
+
+import (
+	// context for bounding stream buffer operations (go1.21)
+	"context"
+	// json for encoding location frames (go1.21)
+	"encoding/json"
+	// errors for the disabled resumption error (go1.21)
+	"errors"
+	// fmt for wrapping replay errors (go1.21)
+	"fmt"
+	// sync for guarding the subscriber sets (go1.21)
+	"sync"
+	// atomic for muting subscribers (go1.21)
+	"sync/atomic"
+	// time for write deadlines and keepalive pings (go1.21)
+	"time"
+
+	// WebSocket protocol implementation (github.com/gorilla/websocket v1.5.0)
+	"github.com/gorilla/websocket"
+
+	// prometheus for subscriber and eviction metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// config for the subscriber queue settings
+	"src/backend/tracking-service/internal/config"
+	// models for the streamed locations
+	"src/backend/tracking-service/internal/models"
+	// services for sealing frames with stream keys
+	"src/backend/tracking-service/internal/services"
+	// utils for the resumable stream buffer
+	um "src/backend/tracking-service/internal/utils"
+)
+
+// ErrResumableStreamsDisabled is returned when assigning a stream tier while
+// resumable streams are not enabled.
+var ErrResumableStreamsDisabled = errors.New("resumable streams are not enabled")
+
+// Reasons a subscriber is evicted from the hub.
+const (
+	evictQueueFull   = "queue_full"
+	evictWriteFailed = "write_failed"
+)
+
+// locationsFrame carries the points a processed batch added to a session.
+// While resumable streams are enabled it also carries the frame's sequence in
+// the session's stream buffer and the token a client presents, as the
+// resumeToken query parameter, to replay the frames after it on reconnecting.
+// Frames of sessions with a stream key carry the locations encrypted in
+// Sealed instead.
+type locationsFrame struct {
+	Type        string                `json:"type"`
+	SessionID   string                `json:"sessionID"`
+	Seq         uint64                `json:"seq,omitempty"`
+	ResumeToken string                `json:"resumeToken,omitempty"`
+	Locations   json.RawMessage       `json:"locations,omitempty"`
+	Sealed      *services.SealedFrame `json:"sealed,omitempty"`
+}
+
+// resyncFrame tells a resuming client that frames after AfterSeq were pruned
+// from the buffer and it must reload the walk's full history.
+type resyncFrame struct {
+	Type     string `json:"type"`
+	AfterSeq uint64 `json:"afterSeq"`
+}
+
+// sessionFrame opens a subscriber's stream with the session it follows and
+// the compact profile of the walked dog.
+type sessionFrame struct {
+	Type      string             `json:"type"`
+	SessionID string             `json:"sessionID"`
+	Dog       *models.DogProfile `json:"dog"`
+}
+
+// StreamHub fans the location updates of each session out to every live stream
+// subscribed to it, so the owner's app and an admin dashboard can follow the
+// same walk. Each subscriber has its own bounded send queue drained by its own
+// writer, so a slow client only delays itself; one whose queue fills up or
+// whose socket stalls is evicted and has to reconnect. Location frames reach
+// each subscriber through its own delivery throttle, so clients on slow links
+// can receive at most one frame per interval, the latest winning.
+//
+// With resumable streams enabled, every location frame is also sequenced in a
+// per-session buffer, so a subscriber that reconnects, possibly to another
+// instance, is first replayed the frames it missed.
+type StreamHub struct {
+	queueSize    int
+	writeTimeout time.Duration
+	throttling   config.StreamConfig
+	logger       *zap.Logger
+
+	// buffer retains each session's recent location frames for resuming
+	// subscribers, and resumeTokens signs their positions. Nil disables
+	// resumption.
+	buffer       um.StreamBuffer
+	resumeTokens *ResumeTokenSigner
+
+	// seal encrypts location frames with their session's stream key, returning
+	// nil for sessions streamed in the clear. Nil disables encryption.
+	seal func(sessionID string, payload []byte) (*services.SealedFrame, error)
+
+	mu       sync.RWMutex
+	sessions map[string]map[*hubSubscriber]struct{}
+
+	subscribers prometheus.Gauge
+	delivered   prometheus.Counter
+	evictions   *prometheus.CounterVec
+}
+
+// hubSubscriber is one connection subscribed to a session. Frames are queued on
+// send and written by the subscriber's writer until done is closed. A muted
+// subscriber is skipped by Publish; its queue only carries the replies to its
+// own messages.
+type hubSubscriber struct {
+	sessionID string
+	conn      *websocket.Conn
+	send      chan hubFrame
+	done      chan struct{}
+	closeOnce sync.Once
+	muted     atomic.Bool
+	throttle  *subscriberThrottle
+
+	// subscribedAt and onFirstFrame time the wait for the first frame.
+	subscribedAt time.Time
+	onFirstFrame func(wait time.Duration)
+
+	// replayMu guards replaying and held. While the subscriber is replayed
+	// its buffered frames, published frames are held rather than queued.
+	replayMu  sync.Mutex
+	replaying bool
+	held      []hubFrame
+}
+
+// hubFrame is a frame queued for a subscriber; reply is set for replies to the
+// subscriber's own messages, as opposed to published frames, and seq is the
+// buffer sequence of published location frames.
+type hubFrame struct {
+	data  []byte
+	reply bool
+	seq   uint64
+}
+
+// subscribeOptions are the settings of a new subscription. A muted
+// subscriber receives no published frames until it is unmuted. A resuming
+// subscriber is first replayed the buffered frames after afterSeq.
+// frameInterval is the delivery interval the subscriber negotiated, zero for
+// every frame. onFirstFrame, when non-nil, is called with the wait once the
+// first published frame is written.
+type subscribeOptions struct {
+	muted         bool
+	resuming      bool
+	afterSeq      uint64
+	frameInterval time.Duration
+	onFirstFrame  func(wait time.Duration)
+}
+
+// NewStreamHub creates a hub with the subscriber queue and throttling settings
+// of cfg, registering its metrics on registry when non-nil.
+func NewStreamHub(cfg config.StreamConfig, logger *zap.Logger, registry *prometheus.Registry) *StreamHub {
+	h := &StreamHub{
+		queueSize:    cfg.SubscriberQueueSize,
+		writeTimeout: cfg.SubscriberWriteTimeout,
+		throttling:   cfg,
+		logger:       logger,
+		sessions:     make(map[string]map[*hubSubscriber]struct{}),
+		subscribers: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "tracking_stream_hub_subscribers",
+			Help: "Live stream connections subscribed to session location updates",
+		}),
+		delivered: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tracking_stream_hub_frames_delivered_total",
+			Help: "Location frames written to live stream subscribers",
+		}),
+		evictions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tracking_stream_hub_evictions_total",
+			Help: "Live stream subscribers evicted for falling behind, by reason",
+		}, []string{"reason"}),
+	}
+	if registry != nil {
+		registry.MustRegister(h.subscribers, h.delivered, h.evictions)
+	}
+	return h
+}
+
+// EnableResumableStreams sequences every location frame in buffer and signs
+// its position with secret, so subscribers can resume after reconnecting.
+func (h *StreamHub) EnableResumableStreams(buffer um.StreamBuffer, secret []byte) {
+	h.buffer = buffer
+	h.resumeTokens = NewResumeTokenSigner(secret)
+}
+
+// SetFrameSealer encrypts the locations of every frame with seal, typically
+// TrackingService.SealStreamFrame, for sessions that have a stream key. The
+// buffer keeps frames in the clear, so replayed frames are sealed as they are
+// sent, with the key current by then. Passing nil disables encryption.
+func (h *StreamHub) SetFrameSealer(seal func(sessionID string, payload []byte) (*services.SealedFrame, error)) {
+	h.seal = seal
+}
+
+// SetStreamTier assigns sessionID's replay buffer to tier, whose frame count and
+// age limits then decide how far back a reconnecting subscriber can resume.
+// Unknown tiers return an error wrapping utils.ErrUnknownStreamTier.
+func (h *StreamHub) SetStreamTier(sessionID, tier string) error {
+	if h.buffer == nil {
+		return ErrResumableStreamsDisabled
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), h.writeTimeout)
+	defer cancel()
+	return h.buffer.SetTier(ctx, sessionID, tier)
+}
+
+// resumePosition verifies the resume token a client presented for sessionID
+// and returns the last sequence it received. ok is false when there is no
+// token or resumption is disabled, in which case the stream starts live.
+func (h *StreamHub) resumePosition(sessionID, token string) (afterSeq uint64, ok bool, err error) {
+	if token == "" || h.resumeTokens == nil {
+		return 0, false, nil
+	}
+	afterSeq, err = h.resumeTokens.Parse(sessionID, token)
+	if err != nil {
+		return 0, false, err
+	}
+	return afterSeq, true, nil
+}
+
+// subscribe adds conn to the subscribers of sessionID and starts its writer,
+// which also pings the connection every heartbeatInterval. A resuming
+// subscriber is replayed its missed frames first, written directly since its
+// writer is not running yet; frames published meanwhile are held and queued
+// once the replay ends, skipping those it already covered. The subscription
+// must be passed to unsubscribe once the connection closes, also when the
+// replay fails.
+func (h *StreamHub) subscribe(sessionID string, conn *websocket.Conn, opts subscribeOptions) (*hubSubscriber, error) {
+	sub := &hubSubscriber{
+		sessionID:    sessionID,
+		conn:         conn,
+		send:         make(chan hubFrame, h.queueSize),
+		done:         make(chan struct{}),
+		subscribedAt: time.Now(),
+		onFirstFrame: opts.onFirstFrame,
+		replaying:    opts.resuming && h.buffer != nil,
+	}
+	sub.muted.Store(opts.muted)
+	sub.throttle = newSubscriberThrottle(h.throttling, opts.frameInterval, func(frame []byte) {
+		h.enqueue(sub, hubFrame{data: frame})
+	})
+	h.mu.Lock()
+	subs, ok := h.sessions[sessionID]
+	if !ok {
+		subs = make(map[*hubSubscriber]struct{})
+		h.sessions[sessionID] = subs
+	}
+	subs[sub] = struct{}{}
+	h.mu.Unlock()
+	h.subscribers.Inc()
+
+	if !sub.replaying {
+		go h.writeLoop(sub)
+		return sub, nil
+	}
+	lastSeq, err := h.replay(sub, opts.afterSeq)
+	if err != nil {
+		return sub, err
+	}
+	go h.writeLoop(sub)
+	for {
+		sub.replayMu.Lock()
+		held := sub.held
+		sub.held = nil
+		if len(held) == 0 {
+			sub.replaying = false
+		}
+		sub.replayMu.Unlock()
+		if len(held) == 0 {
+			return sub, nil
+		}
+		for _, frame := range held {
+			if frame.seq == 0 || frame.seq > lastSeq {
+				sub.throttle.offer(frame.data)
+			}
+		}
+	}
+}
+
+// replay writes the buffered frames of sub's session after afterSeq to its
+// connection, preceded by a resync frame when the buffer no longer covers the
+// gap, and returns the sequence of the last frame written.
+func (h *StreamHub) replay(sub *hubSubscriber, afterSeq uint64) (uint64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), h.writeTimeout)
+	frames, complete, err := h.buffer.Since(ctx, sub.sessionID, afterSeq)
+	cancel()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read stream buffer: %w", err)
+	}
+
+	if !complete {
+		resync, _ := json.Marshal(resyncFrame{Type: "resync", AfterSeq: afterSeq})
+		if err := h.write(sub, resync); err != nil {
+			return 0, err
+		}
+	}
+	lastSeq := afterSeq
+	for _, f := range frames {
+		frame, err := h.encodeLocations(locationsFrame{
+			Type:        "locations",
+			SessionID:   sub.sessionID,
+			Seq:         f.Sequence,
+			ResumeToken: h.resumeTokens.Issue(sub.sessionID, f.Sequence),
+			Locations:   f.Payload,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to encode replayed frame: %w", err)
+		}
+		if err := h.write(sub, frame); err != nil {
+			return 0, err
+		}
+		lastSeq = f.Sequence
+	}
+	return lastSeq, nil
+}
+
+// unsubscribe removes sub from the hub and stops its writer. It is a no-op for
+// subscribers already evicted.
+func (h *StreamHub) unsubscribe(sub *hubSubscriber) {
+	h.remove(sub)
+}
+
+// detachAll removes every subscriber and stops its writer without closing
+// its connection, so streams being drained receive no frames after their
+// close frame. It returns how many subscribers were removed.
+func (h *StreamHub) detachAll() int {
+	h.mu.RLock()
+	subs := make([]*hubSubscriber, 0)
+	for _, sessionSubs := range h.sessions {
+		for sub := range sessionSubs {
+			subs = append(subs, sub)
+		}
+	}
+	h.mu.RUnlock()
+
+	removed := 0
+	for _, sub := range subs {
+		if h.remove(sub) {
+			removed++
+		}
+	}
+	return removed
+}
+
+// Subscribers returns how many connections are subscribed to sessionID.
+func (h *StreamHub) Subscribers(sessionID string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.sessions[sessionID])
+}
+
+// QueuedFrames returns the number of frames queued for all subscribers and
+// not yet written.
+func (h *StreamHub) QueuedFrames() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	queued := 0
+	for _, subs := range h.sessions {
+		for sub := range subs {
+			queued += len(sub.send)
+		}
+	}
+	return queued
+}
+
+// PublishLocations sends the points a batch added to sessionID to the
+// session's subscribers, first sequencing them in the stream buffer when
+// resumable streams are enabled, even while nobody is subscribed. It only
+// waits on the buffer, for at most the subscriber write timeout, so it can be
+// registered with TrackingService.OnLocationBatch.
+func (h *StreamHub) PublishLocations(sessionID string, locations []models.Location) {
+	if h.buffer == nil && h.Subscribers(sessionID) == 0 {
+		return
+	}
+	payload, err := json.Marshal(locations)
+	if err != nil {
+		h.logger.Error("Failed to encode location frame", zap.String("sessionID", sessionID), zap.Error(err))
+		return
+	}
+	frame := locationsFrame{Type: "locations", SessionID: sessionID, Locations: payload}
+	if h.buffer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), h.writeTimeout)
+		seq, err := h.buffer.Append(ctx, sessionID, payload)
+		cancel()
+		if err != nil {
+			h.logger.Warn("Failed to buffer location frame for resumption", zap.String("sessionID", sessionID), zap.Error(err))
+		} else {
+			frame.Seq = seq
+			frame.ResumeToken = h.resumeTokens.Issue(sessionID, seq)
+		}
+	}
+	if h.Subscribers(sessionID) == 0 {
+		return
+	}
+	data, err := h.encodeLocations(frame)
+	if err != nil {
+		h.logger.Error("Failed to encode location frame", zap.String("sessionID", sessionID), zap.Error(err))
+		return
+	}
+	h.publish(sessionID, hubFrame{data: data, seq: frame.Seq})
+}
+
+// encodeLocations marshals frame, first sealing its locations when its
+// session has a stream key. A frame that fails to seal is never sent in the
+// clear.
+func (h *StreamHub) encodeLocations(frame locationsFrame) ([]byte, error) {
+	if h.seal != nil {
+		sealed, err := h.seal(frame.SessionID, frame.Locations)
+		if err != nil {
+			return nil, fmt.Errorf("failed to seal location frame: %w", err)
+		}
+		if sealed != nil {
+			frame.Locations, frame.Sealed = nil, sealed
+		}
+	}
+	return json.Marshal(frame)
+}
+
+// Publish sends frame to every unmuted subscriber of sessionID, through its
+// delivery throttle, and returns how many it was sent to. Subscribers whose
+// queue is full are evicted instead.
+func (h *StreamHub) Publish(sessionID string, frame []byte) int {
+	return h.publish(sessionID, hubFrame{data: frame})
+}
+
+// publish offers frame to the delivery throttle of every unmuted subscriber of
+// sessionID, holding it for those still being replayed, and returns how many
+// subscribers it was offered to.
+func (h *StreamHub) publish(sessionID string, frame hubFrame) int {
+	h.mu.RLock()
+	subs := make([]*hubSubscriber, 0, len(h.sessions[sessionID]))
+	for sub := range h.sessions[sessionID] {
+		if !sub.muted.Load() {
+			subs = append(subs, sub)
+		}
+	}
+	h.mu.RUnlock()
+
+	queued := 0
+	for _, sub := range subs {
+		sub.replayMu.Lock()
+		if sub.replaying {
+			sub.held = append(sub.held, frame)
+			sub.replayMu.Unlock()
+			queued++
+			continue
+		}
+		sub.replayMu.Unlock()
+		sub.throttle.offer(frame.data)
+		queued++
+	}
+	return queued
+}
+
+// send queues a reply to sub's own message, reporting whether it was queued.
+// Like published frames, a reply that finds the queue full evicts sub.
+func (h *StreamHub) send(sub *hubSubscriber, frame []byte) bool {
+	return h.enqueue(sub, hubFrame{data: frame, reply: true})
+}
+
+// enqueue queues frame for sub without blocking, evicting sub when its queue
+// is full.
+func (h *StreamHub) enqueue(sub *hubSubscriber, frame hubFrame) bool {
+	select {
+	case sub.send <- frame:
+		return true
+	default:
+		h.evict(sub, evictQueueFull)
+		return false
+	}
+}
+
+// writeLoop writes sub's queued frames and keepalive pings until sub is
+// removed, evicting it on the first write that fails or exceeds the write
+// timeout. Pings carry their send time, so the pongs measure the RTT that
+// drives sub's automatic throttling.
+func (h *StreamHub) writeLoop(sub *hubSubscriber) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-sub.done:
+			return
+		case frame := <-sub.send:
+			if err := h.write(sub, frame.data); err != nil {
+				h.logger.Debug("Location frame write failed", zap.String("sessionID", sub.sessionID), zap.Error(err))
+				h.evict(sub, evictWriteFailed)
+				return
+			}
+			if frame.reply {
+				continue
+			}
+			h.delivered.Inc()
+			if sub.onFirstFrame != nil {
+				sub.onFirstFrame(time.Since(sub.subscribedAt))
+				sub.onFirstFrame = nil
+			}
+		case <-ticker.C:
+			now := time.Now()
+			if err := sub.conn.WriteControl(websocket.PingMessage, pingPayload(now), now.Add(h.writeTimeout)); err != nil {
+				h.evict(sub, evictWriteFailed)
+				return
+			}
+		}
+	}
+}
+
+// write writes frame to sub's connection within the write timeout, in the
+// encoding of its subprotocol. Only sub's writer, or subscribe before starting
+// it, may call it.
+func (h *StreamHub) write(sub *hubSubscriber, frame []byte) error {
+	sub.conn.SetWriteDeadline(time.Now().Add(h.writeTimeout))
+	return writeJSONFrame(sub.conn, frame)
+}
+
+// evict removes sub and closes its connection, which ends the connection's
+// read loop. Later evictions of the same subscriber are ignored.
+func (h *StreamHub) evict(sub *hubSubscriber, reason string) {
+	if !h.remove(sub) {
+		return
+	}
+	h.evictions.WithLabelValues(reason).Inc()
+	h.logger.Warn("Evicting slow stream subscriber",
+		zap.String("sessionID", sub.sessionID),
+		zap.String("reason", reason),
+	)
+	closeMsg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "subscriber too slow")
+	_ = sub.conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(h.writeTimeout))
+	_ = sub.conn.Close()
+}
+
+// remove deletes sub from its session's subscribers and stops its writer,
+// reporting whether it was still subscribed.
+func (h *StreamHub) remove(sub *hubSubscriber) bool {
+	h.mu.Lock()
+	subs := h.sessions[sub.sessionID]
+	_, ok := subs[sub]
+	if ok {
+		delete(subs, sub)
+		if len(subs) == 0 {
+			delete(h.sessions, sub.sessionID)
+		}
+	}
+	h.mu.Unlock()
+
+	sub.closeOnce.Do(func() { close(sub.done) })
+	sub.throttle.stop()
+	if ok {
+		h.subscribers.Dec()
+	}
+	return ok
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	// crypto for the RS256 signature hash (go1.21)
+	"crypto"
+	// crypto/rsa for verifying access token signatures (go1.21)
+	"crypto/rsa"
+	// crypto/sha256 for hashing the signed token header and claims (go1.21)
+	"crypto/sha256"
+	// crypto/x509 and encoding/pem for parsing the auth service's public key (go1.21)
+	"crypto/x509"
+	"encoding/pem"
+	// base64 for decoding token segments (go1.21)
+	"encoding/base64"
+	// json for decoding the token header and claims (go1.21)
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// User types of the auth service's access tokens.
+const (
+	RoleOwner  = "OWNER"
+	RoleWalker = "WALKER"
+	RoleAdmin  = "ADMIN"
+)
+
+// identityClockSkew is how far past its expiry an access token is still
+// accepted, absorbing clock drift between the auth service and this one.
+const identityClockSkew = 30 * time.Second
+
+// Identity verification failures.
+var (
+	ErrIdentityDisabled = errors.New("identity verification is not configured")
+	ErrMissingIdentity  = errors.New("missing bearer access token")
+	ErrInvalidIdentity  = errors.New("invalid access token")
+	ErrIdentityExpired  = errors.New("access token has expired")
+	ErrNotAdmin         = errors.New("access token does not identify an admin")
+)
+
+// Principal is the verified identity of a caller: the user ID of the owner,
+// walker or operator its access token was issued to, and their user type.
+type Principal struct {
+	Subject string
+	Role    string
+}
+
+// IdentityVerifier verifies the RS256 access tokens issued by the auth
+// service, identifying callers of endpoints that act on someone's behalf, such
+// as sharing a session or releasing escrowed keys. The issuer and audience are
+// checked when configured.
+type IdentityVerifier struct {
+	key      *rsa.PublicKey
+	issuer   string
+	audience string
+}
+
+// identityHeader is the header of an access token.
+type identityHeader struct {
+	Alg string `json:"alg"`
+}
+
+// identityClaims are the claims of an access token read by the verifier. The
+// audience may be a string or a list of strings.
+type identityClaims struct {
+	Subject   string          `json:"sub"`
+	UserType  string          `json:"userType"`
+	Issuer    string          `json:"iss"`
+	Audience  json.RawMessage `json:"aud"`
+	ExpiresAt int64           `json:"exp"`
+}
+
+// NewIdentityVerifier creates a verifier for tokens signed with the private
+// key matching publicKeyPEM, a PEM-encoded PKIX or PKCS#1 RSA public key.
+// Empty issuer or audience are not checked.
+func NewIdentityVerifier(publicKeyPEM []byte, issuer, audience string) (*IdentityVerifier, error) {
+	block, _ := pem.Decode(publicKeyPEM)
+	if block == nil {
+		return nil, errors.New("identity public key is not PEM-encoded")
+	}
+	var key *rsa.PublicKey
+	if parsed, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		rsaKey, ok := parsed.(*rsa.PublicKey)
+		if !ok {
+			return nil, errors.New("identity public key is not an RSA key")
+		}
+		key = rsaKey
+	} else if key, err = x509.ParsePKCS1PublicKey(block.Bytes); err != nil {
+		return nil, fmt.Errorf("failed to parse identity public key: %w", err)
+	}
+	return &IdentityVerifier{key: key, issuer: issuer, audience: audience}, nil
+}
+
+// Parse verifies token and returns the principal it identifies. It returns
+// ErrInvalidIdentity for tokens that are malformed, forged, not RS256 or
+// issued by or for someone else, and ErrIdentityExpired once the token has
+// expired.
+func (v *IdentityVerifier) Parse(token string) (Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Principal{}, ErrInvalidIdentity
+	}
+	var header identityHeader
+	if err := decodeTokenSegment(parts[0], &header); err != nil || header.Alg != "RS256" {
+		return Principal{}, ErrInvalidIdentity
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return Principal{}, ErrInvalidIdentity
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if rsa.VerifyPKCS1v15(v.key, crypto.SHA256, digest[:], signature) != nil {
+		return Principal{}, ErrInvalidIdentity
+	}
+
+	var claims identityClaims
+	if err := decodeTokenSegment(parts[1], &claims); err != nil || claims.Subject == "" {
+		return Principal{}, ErrInvalidIdentity
+	}
+	if v.issuer != "" && claims.Issuer != v.issuer {
+		return Principal{}, ErrInvalidIdentity
+	}
+	if v.audience != "" && !claims.hasAudience(v.audience) {
+		return Principal{}, ErrInvalidIdentity
+	}
+	if claims.ExpiresAt == 0 || time.Now().After(time.Unix(claims.ExpiresAt, 0).Add(identityClockSkew)) {
+		return Principal{}, ErrIdentityExpired
+	}
+	return Principal{Subject: claims.Subject, Role: claims.UserType}, nil
+}
+
+// hasAudience reports whether the token was issued for audience.
+func (c identityClaims) hasAudience(audience string) bool {
+	var single string
+	if json.Unmarshal(c.Audience, &single) == nil {
+		return single == audience
+	}
+	var list []string
+	if json.Unmarshal(c.Audience, &list) != nil {
+		return false
+	}
+	for _, aud := range list {
+		if aud == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeTokenSegment decodes a base64url JSON segment of a token into v.
+func decodeTokenSegment(segment string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// SetIdentityVerifier verifies the access tokens of callers with verifier.
+// Without one, endpoints requiring a verified identity reject every request.
+func (lh *LocationHandler) SetIdentityVerifier(verifier *IdentityVerifier) {
+	lh.identities = verifier
+}
+
+// principal returns the verified identity of the caller of req, from its
+// "Authorization: Bearer <token>" header.
+func (lh *LocationHandler) principal(req Request) (Principal, error) {
+	if lh.identities == nil {
+		return Principal{}, ErrIdentityDisabled
+	}
+	token, ok := strings.CutPrefix(req.HeaderValue("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return Principal{}, ErrMissingIdentity
+	}
+	return lh.identities.Parse(token)
+}
+
+// adminPrincipal returns the verified identity of the caller of req, failing
+// with ErrNotAdmin unless it is an admin.
+func (lh *LocationHandler) adminPrincipal(req Request) (Principal, error) {
+	caller, err := lh.principal(req)
+	if err != nil {
+		return Principal{}, err
+	}
+	if caller.Role != RoleAdmin {
+		return Principal{}, ErrNotAdmin
+	}
+	return caller, nil
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	// errors for admission rejection sentinels (go1.21)
+	"errors"
+	// fmt for Retry-After formatting (go1.21)
+	"fmt"
+	// math for rounding Retry-After up to whole seconds (go1.21)
+	"math"
+	// http for status codes (go1.21)
+	"net/http"
+	// sync for guarding the in-flight count (go1.21)
+	"sync"
+	// time for the retry hint and drain deadline (go1.21)
+	"time"
+
+	// gin for the HTTP middleware (github.com/gin-gonic/gin v1.9.1)
+	"github.com/gin-gonic/gin"
+
+	// prometheus for in-flight and rejection metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// config for the in-flight guard settings
+	"src/backend/tracking-service/internal/config"
+)
+
+// Admission rejections of InFlightGuard.
+var (
+	ErrTooManyInFlight = errors.New("too many location updates in flight; retry later")
+	ErrDraining        = errors.New("server is shutting down; retry later")
+)
+
+// InFlightGuard tracks the requests in flight on a route so they can be
+// drained on shutdown, and caps how many run at once so that load spikes are
+// turned away with 429 instead of piling up as database connections.
+type InFlightGuard struct {
+	max        int
+	retryAfter time.Duration
+	logger     *zap.Logger
+
+	mu       sync.Mutex
+	inFlight int
+	draining bool
+	done     sync.WaitGroup
+
+	gauge      prometheus.Gauge
+	rejections *prometheus.CounterVec
+}
+
+// NewInFlightGuard creates a guard enforcing cfg. Metrics are registered on
+// registry when it is non-nil.
+func NewInFlightGuard(cfg config.InFlightConfig, logger *zap.Logger, registry *prometheus.Registry) *InFlightGuard {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	g := &InFlightGuard{
+		max:        cfg.MaxLocationRequests,
+		retryAfter: cfg.RetryAfter,
+		logger:     logger,
+		gauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "tracking_location_requests_in_flight",
+			Help: "Location update requests currently being handled.",
+		}),
+		rejections: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tracking_location_requests_rejected_total",
+				Help: "Location update requests refused by the in-flight guard, by reason (overload or draining).",
+			},
+			[]string{"reason"},
+		),
+	}
+	if registry != nil {
+		registry.MustRegister(g.gauge, g.rejections)
+	}
+	return g
+}
+
+// Admit takes an in-flight slot, returning a release function that must be
+// called when the request completes. It returns ErrDraining once Drain has
+// begun and ErrTooManyInFlight when the ceiling is reached.
+func (g *InFlightGuard) Admit() (func(), error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.draining {
+		g.rejections.WithLabelValues("draining").Inc()
+		return nil, ErrDraining
+	}
+	if g.max > 0 && g.inFlight >= g.max {
+		g.rejections.WithLabelValues("overload").Inc()
+		return nil, ErrTooManyInFlight
+	}
+	g.inFlight++
+	g.gauge.Inc()
+	g.done.Add(1)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			g.mu.Lock()
+			g.inFlight--
+			g.mu.Unlock()
+			g.gauge.Dec()
+			g.done.Done()
+		})
+	}, nil
+}
+
+// InFlight returns the number of requests currently admitted.
+func (g *InFlightGuard) InFlight() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.inFlight
+}
+
+// Middleware admits each request through the guard, responding 429 at the
+// ceiling and 503 while draining, both with a Retry-After header.
+func (g *InFlightGuard) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		release, err := g.Admit()
+		if err != nil {
+			status := http.StatusTooManyRequests
+			if errors.Is(err, ErrDraining) {
+				status = http.StatusServiceUnavailable
+			}
+			c.Header("Retry-After", fmt.Sprintf("%d", int64(math.Ceil(g.retryAfter.Seconds()))))
+			AbortWithError(c, NewAPIError(status, "", err.Error()))
+			return
+		}
+		defer release()
+		c.Next()
+	}
+}
+
+// Drain refuses new requests and waits up to timeout for those in flight to
+// complete. It returns how many were still running when it gave up.
+func (g *InFlightGuard) Drain(timeout time.Duration) int {
+	g.mu.Lock()
+	g.draining = true
+	pending := g.inFlight
+	g.mu.Unlock()
+	if pending == 0 {
+		return 0
+	}
+
+	g.logger.Info("Draining in-flight location updates",
+		zap.Int("requests", pending),
+		zap.Duration("timeout", timeout),
+	)
+	done := make(chan struct{})
+	go func() {
+		g.done.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		g.logger.Info("In-flight location updates drained")
+		return 0
+	case <-timer.C:
+		return g.InFlight()
+	}
+}
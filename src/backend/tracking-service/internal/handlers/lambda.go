@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	// context for the Lambda handler signature (go1.21)
+	"context"
+	// base64 for binary bodies in API Gateway events (go1.21)
+	"encoding/base64"
+	// http for status codes and headers (go1.21)
+	"net/http"
+	// url for query parameters (go1.21)
+	"net/url"
+	// strings for translating route patterns and content types (go1.21)
+	"strings"
+
+	// events for API Gateway proxy event types (github.com/aws/aws-lambda-go v1.41.0)
+	"github.com/aws/aws-lambda-go/events"
+
+	// models package for generating request IDs
+	"src/backend/tracking-service/internal/models"
+)
+
+// LambdaRouter mounts CoreRoutes behind an API Gateway REST API using Lambda
+// proxy integration, with one API resource per route, e.g.
+// "/sessions/{sessionID}/statistics". Pass Handle to lambda.Start.
+//
+// The gin middleware chain (OpenAPI validation, rate limiting, stream guard)
+// does not run here; configure the equivalent on API Gateway.
+type LambdaRouter struct {
+	routes map[string]CoreHandler
+}
+
+// NewLambdaRouter indexes routes by method and API Gateway resource path.
+func NewLambdaRouter(routes []CoreRoute) *LambdaRouter {
+	r := &LambdaRouter{routes: make(map[string]CoreHandler, len(routes))}
+	for _, route := range routes {
+		r.routes[route.Method+" "+apiGatewayResource(route.Path)] = route.Handler
+	}
+	return r
+}
+
+// apiGatewayResource converts a gin path pattern to API Gateway syntax, turning
+// ":name" segments into "{name}".
+func apiGatewayResource(path string) string {
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") {
+			segments[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// Handle implements the Lambda handler for API Gateway proxy events.
+// Unmatched resources get 404 and malformed base64 bodies 400. Requests
+// without a usable X-Request-ID are identified by API Gateway's request ID,
+// and the identifier is returned in the response like RequestIDMiddleware's.
+func (r *LambdaRouter) Handle(_ context.Context, event events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	core, ok := r.routes[event.HTTPMethod+" "+event.Resource]
+	if !ok {
+		return toAPIGatewayResponse(errorResponse(http.StatusNotFound, "route not found")), nil
+	}
+
+	req := Request{
+		PathParams: event.PathParameters,
+		Query:      url.Values{},
+		Header:     http.Header{},
+		Body:       []byte(event.Body),
+	}
+	for key, values := range event.MultiValueQueryStringParameters {
+		req.Query[key] = values
+	}
+	for key, value := range event.QueryStringParameters {
+		if _, ok := req.Query[key]; !ok {
+			req.Query.Set(key, value)
+		}
+	}
+	for key, values := range event.MultiValueHeaders {
+		for _, v := range values {
+			req.Header.Add(key, v)
+		}
+	}
+	for key, value := range event.Headers {
+		if req.Header.Get(key) == "" {
+			req.Header.Set(key, value)
+		}
+	}
+	if event.IsBase64Encoded {
+		body, err := base64.StdEncoding.DecodeString(event.Body)
+		if err != nil {
+			return toAPIGatewayResponse(errorResponse(http.StatusBadRequest, "request body is not valid base64")), nil
+		}
+		req.Body = body
+	}
+	if encoding := req.HeaderValue("Content-Encoding"); encoding != "" {
+		body, err := decodeContentEncoding(encoding, req.Body)
+		if err != nil {
+			status, message := decodeErrorStatus(err)
+			return toAPIGatewayResponse(errorResponse(status, message)), nil
+		}
+		req.Body = body
+		req.Header.Del("Content-Encoding")
+	}
+
+	id := req.HeaderValue(RequestIDHeader)
+	if !validRequestID(id) {
+		id = event.RequestContext.RequestID
+	}
+	if !validRequestID(id) {
+		id = models.NewID()
+	}
+	req.Header.Set(RequestIDHeader, id)
+	resp := core(req).withRequestID(id)
+	if resp.Header == nil {
+		resp.Header = http.Header{}
+	}
+	resp.Header.Set(RequestIDHeader, id)
+	return toAPIGatewayResponse(resp), nil
+}
+
+// toAPIGatewayResponse converts resp to a proxy response, base64-encoding
+// bodies that are not JSON or text, such as FIT exports, and compressed
+// bodies.
+func toAPIGatewayResponse(resp Response) events.APIGatewayProxyResponse {
+	out := events.APIGatewayProxyResponse{
+		StatusCode:        resp.Status,
+		MultiValueHeaders: map[string][]string(resp.Header),
+	}
+	contentType := resp.Header.Get("Content-Type")
+	textual := strings.HasPrefix(contentType, "application/json") || strings.HasPrefix(contentType, "text/")
+	if len(resp.Body) == 0 || (textual && resp.Header.Get("Content-Encoding") == "") {
+		out.Body = string(resp.Body)
+	} else {
+		out.Body = base64.StdEncoding.EncodeToString(resp.Body)
+		out.IsBase64Encoded = true
+	}
+	return out
+}
@@ -12,6 +12,8 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -21,11 +23,20 @@ import (
 	// prometheus for metrics collection and monitoring (github.com/prometheus/client_golang/prometheus v1.16.0)
 	"github.com/prometheus/client_golang/prometheus"
 
+	// config package for the configuration reload status
+	"src/backend/tracking-service/internal/config"
+
 	// models package for the Location struct
 	"src/backend/tracking-service/internal/models"
 
+	// repository package for the storage error taxonomy
+	"src/backend/tracking-service/internal/repository"
+
 	// services package for the TrackingService struct
 	"src/backend/tracking-service/internal/services"
+
+	// utils package for the FIT content type and diagnostics bundles
+	"src/backend/tracking-service/internal/utils"
 )
 
 // Global configuration variables as described in the specification.
@@ -50,9 +61,12 @@ func checkOrigin(r *http.Request) bool {
 // LocationHandler is an enhanced handler for managing location-related endpoints,
 // featuring real-time tracking, robust monitoring, and enhanced security checks.
 // It exposes HTTP and WebSocket methods to integrate with the rest of the system.
+// The logic of each HTTP endpoint is a framework-agnostic CoreHandler, mounted in
+// gin by a thin HandleX adapter and elsewhere, e.g. AWS Lambda, via CoreRoutes.
 type LocationHandler struct {
-	// trackingService references the core tracking service for location processing, session management, etc.
-	trackingService *services.TrackingService
+	// trackingService references the core tracking service for location processing, session management, etc.,
+	// usually wrapped with the service middleware chain.
+	trackingService services.TrackingAPI
 
 	// wsUpgrader configures WebSocket upgrade parameters like read/write buffer sizes and origin checks.
 	wsUpgrader websocket.Upgrader
@@ -65,6 +79,55 @@ type LocationHandler struct {
 
 	// connectionPool is used for pooling resources related to WebSocket connections (if desired).
 	connectionPool *sync.Pool
+
+	// capture records the inbound messages of selected sessions for replay. Nil disables it.
+	capture *StreamCapture
+
+	// affinity issues the tokens pinning sessions' stream connects to this instance. Nil disables it.
+	affinity *SessionAffinity
+
+	// lastKnown serves the latest history responses while the database is unavailable. Nil disables it.
+	lastKnown *LastKnownCache
+
+	// diagnostics captures a bundle for panics recovered while handling requests and streams. Nil only logs them.
+	diagnostics *utils.DiagnosticsRecorder
+
+	// hub fans each session's location updates out to its live streams. Nil disables streaming them.
+	hub *StreamHub
+
+	// cursors signs and verifies the cursors of paginated listings.
+	cursors *PageCursorSigner
+
+	// shareLinks signs and verifies the tokens of read-only live-share links.
+	shareLinks *ShareLinkSigner
+
+	// identities verifies the bearer tokens identifying callers (nil rejects
+	// every request that needs a verified identity).
+	identities *IdentityVerifier
+
+	// configWatcher reloads the service configuration (nil when reloading is disabled).
+	configWatcher *config.Watcher
+
+	// runtimeMonitor reports the instance degraded to the readiness probe. Nil disables it.
+	runtimeMonitor *services.RuntimeMonitor
+
+	// compressMinBytes is the smallest history response compressed for clients accepting it. Zero disables it.
+	compressMinBytes int
+
+	// accessActorHeader names the header identifying who reads a walk's location data in its access log.
+	accessActorHeader string
+
+	// streamsMu guards streams and draining.
+	streamsMu sync.Mutex
+
+	// streams holds the live location streams, so they can be drained on shutdown.
+	streams map[*websocket.Conn]struct{}
+
+	// streamsDone counts the live location streams until their handler returns.
+	streamsDone sync.WaitGroup
+
+	// draining refuses new location streams once DrainStreams has started.
+	draining bool
 }
 
 // NewLocationHandler creates a new location handler instance with enhanced monitoring and security features.
@@ -78,7 +141,7 @@ type LocationHandler struct {
 //  6. Set up connection pool
 //  7. Return initialized handler
 func NewLocationHandler(
-	ts *services.TrackingService,
+	ts services.TrackingAPI,
 	logger *zap.Logger,
 	metricsCollector prometheus.Collector,
 ) *LocationHandler {
@@ -88,6 +151,7 @@ func NewLocationHandler(
 		WriteBufferSize:   1024,
 		CheckOrigin:       checkOrigin,
 		EnableCompression: true,
+		Subprotocols:      []string{SubprotocolCBOR, SubprotocolProtobuf, SubprotocolJSON},
 	}
 
 	// Create a sync.Pool for potential WebSocket connection reuse or other object pooling.
@@ -104,7 +168,81 @@ func NewLocationHandler(
 		logger:           logger,
 		metricsCollector: metricsCollector,
 		connectionPool:   connPool,
+		streams:          make(map[*websocket.Conn]struct{}),
+		cursors:          NewPageCursorSigner(nil),
+		shareLinks:       NewShareLinkSigner(nil, defaultShareLinkTTL, defaultShareLinkMax),
+	}
+}
+
+// SetStreamCapture records the inbound WebSocket messages of the sessions
+// selected by capture. Passing nil disables recording.
+func (lh *LocationHandler) SetStreamCapture(capture *StreamCapture) {
+	lh.capture = capture
+}
+
+// SetStreamHub subscribes each live stream to its session's location updates
+// through hub. Passing nil disables pushing updates to streams.
+func (lh *LocationHandler) SetStreamHub(hub *StreamHub) {
+	lh.hub = hub
+}
+
+// SetSessionAffinity issues affinity tokens for sessions created on this
+// instance. Passing nil disables them.
+func (lh *LocationHandler) SetSessionAffinity(affinity *SessionAffinity) {
+	lh.affinity = affinity
+}
+
+// SetDiagnostics captures a diagnostics bundle for each panic recovered while
+// handling requests and location streams. Passing nil only logs them.
+func (lh *LocationHandler) SetDiagnostics(diagnostics *utils.DiagnosticsRecorder) {
+	lh.diagnostics = diagnostics
+}
+
+// SetConfigWatcher serves the status of the configuration reloads of watcher.
+// Passing nil disables the status endpoint.
+func (lh *LocationHandler) SetConfigWatcher(watcher *config.Watcher) {
+	lh.configWatcher = watcher
+}
+
+// SetRuntimeMonitor reports the runtime health sampled by monitor on the
+// readiness probe. Passing nil disables it.
+func (lh *LocationHandler) SetRuntimeMonitor(monitor *services.RuntimeMonitor) {
+	lh.runtimeMonitor = monitor
+}
+
+// Recovery is the gin recovery function for the router. It captures a
+// diagnostics bundle for the panic with the request's route and session, taken
+// from the path, the query or the X-Session-ID header, and answers 500 with the
+// usual error body.
+func (lh *LocationHandler) Recovery(c *gin.Context, p interface{}) {
+	sessionID := c.Param("sessionID")
+	if sessionID == "" {
+		sessionID = c.Query("sessionID")
+	}
+	if sessionID == "" {
+		sessionID = c.GetHeader("X-Session-ID")
 	}
+	lh.diagnostics.Capture("http", p, &utils.DiagnosticContext{
+		SessionID: sessionID,
+		Fields: map[string]string{
+			"method":    c.Request.Method,
+			"route":     c.FullPath(),
+			"requestID": requestID(c),
+		},
+	})
+	resp := errorResponse(http.StatusInternalServerError, "internal server error").withRequestID(requestID(c))
+	c.Data(resp.Status, jsonContentType, resp.Body)
+	c.Abort()
+}
+
+// SessionAffinity returns the affinity set by SetSessionAffinity, or nil.
+func (lh *LocationHandler) SessionAffinity() *SessionAffinity {
+	return lh.affinity
+}
+
+// RuntimeMonitor returns the monitor set by SetRuntimeMonitor, or nil.
+func (lh *LocationHandler) RuntimeMonitor() *services.RuntimeMonitor {
+	return lh.runtimeMonitor
 }
 
 // validateSession performs enhanced session validation with rate limiting and security checks.
@@ -116,28 +254,28 @@ func NewLocationHandler(
 //  4. Check permissions (placeholder role-based or scope-based checks)
 //  5. Record validation metrics
 //  6. Return validation result (error if invalid)
-func (lh *LocationHandler) validateSession(sessionID, token string) error {
+func (lh *LocationHandler) validateSession(logger *zap.Logger, sessionID, token string) error {
 	// 1. Check rate limits - In a real implementation, call an external rate limiter or track usage counters
 	if sessionID == "" {
-		lh.logger.Error("Session validation failed: empty session ID")
+		logger.Error("Session validation failed: empty session ID")
 		return errors.New("session validation failed: sessionID cannot be empty")
 	}
 
 	// 2. Validate session existence - For demonstration, ensure sessionID is not trivially empty
 	_, ok := lh.trackingService.GetSessionStatistics(sessionID) // Hypothetical check or usage
 	if !ok {
-		lh.logger.Warn("Session not found during validation", zap.String("sessionID", sessionID))
+		logger.Warn("Session not found during validation", zap.String("sessionID", sessionID))
 		// In real usage, we'd verify in a service or DB that the session is valid
 	}
 
 	// 3. Verify token authenticity - Placeholder logic
 	if token == "" {
-		lh.logger.Warn("No token provided; additional checks recommended for security")
+		logger.Warn("No token provided; additional checks recommended for security")
 	}
 
 	// 4. Check permissions - This is where roles or scopes would be validated
 	// 5. Record validation metrics - e.g., increment a counter or observe a histogram
-	lh.logger.Debug("Session validated successfully",
+	logger.Debug("Session validated successfully",
 		zap.String("sessionID", sessionID),
 		zap.String("tokenSnippet", token),
 	)
@@ -147,32 +285,90 @@ func (lh *LocationHandler) validateSession(sessionID, token string) error {
 // handleWSConnection manages a WebSocket connection lifecycle with monitoring and recovery.
 //
 // Steps:
-//  1. Initialize connection metrics (stubbed or integrated with Prometheus)
+//  1. Initialize connection metrics (stubbed or integrated with Prometheus),
+//     start recording if the session is selected for capture and subscribe
+//     the connection to the session's location updates, opening with a
+//     session-start frame carrying the dog's profile when one is available
 //  2. Set up heartbeat interval checks
-//  3. Configure compression and read limits
-//  4. Start a message read loop
+//  3. Configure compression and read limits, extending the read deadline on
+//     each pong so listen-only subscribers stay connected
+//  4. Start a message read loop, recording each message of captured sessions
+//     and answering each with an ack (see streamMessage for the protocol)
 //  5. Handle reconnection attempts if needed (simplified here)
 //  6. Manage connection lifecycle and cleanup
-func (lh *LocationHandler) handleWSConnection(conn *websocket.Conn, sessionID string) error {
+//
+// shareExpiry is zero for authenticated streams. Otherwise the stream is a
+// read-only share-link subscription: its location frames are rejected, and it
+// is closed once its link expires at shareExpiry. opts subscribe the stream to
+// the hub: a muted stream is not sent the session's location frames until it
+// sends a subscribe frame, and a resuming one is first replayed those it missed.
+func (lh *LocationHandler) handleWSConnection(logger *zap.Logger, api services.TrackingAPI, conn *websocket.Conn, sessionID string, shareExpiry time.Time, opts subscribeOptions) error {
 	if conn == nil {
-		lh.logger.Error("handleWSConnection invoked with nil *websocket.Conn")
+		logger.Error("handleWSConnection invoked with nil *websocket.Conn")
 		return errors.New("nil websocket connection")
 	}
 	defer conn.Close()
+	// diag follows the message being handled for the diagnostics bundle of a panic.
+	diag := &utils.DiagnosticContext{SessionID: sessionID}
+	defer lh.diagnostics.Recover("location.stream", diag)
 
 	// 1. Initialize connection metrics: a placeholder for integration with lh.metricsCollector
-	lh.logger.Info("WebSocket connection established",
+	logger.Info("WebSocket connection established",
 		zap.String("sessionID", sessionID),
 	)
+	recorder, err := lh.capture.Start(sessionID)
+	if err != nil {
+		logger.Warn("Failed to start WebSocket capture", zap.String("sessionID", sessionID), zap.Error(err))
+	}
+	if recorder != nil {
+		defer recorder.Close()
+		logger.Info("Capturing WebSocket session", zap.String("sessionID", sessionID))
+	}
+	readOnly := !shareExpiry.IsZero()
+	if readOnly {
+		expiry := time.AfterFunc(time.Until(shareExpiry), func() {
+			closeMsg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, shareExpiredReason)
+			_ = conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(5*time.Second))
+			_ = conn.Close()
+		})
+		defer expiry.Stop()
+	}
+	stream := &locationStream{logger: logger, api: api, conn: conn, sessionID: sessionID, readOnly: readOnly}
+	if lh.hub != nil {
+		lh.writeSessionFrame(logger, conn, sessionID)
+		opts.onFirstFrame = func(wait time.Duration) {
+			api.ObserveStreamFirstFrame(sessionID, wait)
+		}
+		sub, err := lh.hub.subscribe(sessionID, conn, opts)
+		defer lh.hub.unsubscribe(sub)
+		if err != nil {
+			logger.Warn("Failed to replay missed stream frames", zap.String("sessionID", sessionID), zap.Error(err))
+			return err
+		}
+		stream.hub, stream.sub = lh.hub, sub
+	}
 
 	// 2. Prepare a ticker for heartbeat pings or checks if desired
 	heartbeatTicker := time.NewTicker(heartbeatInterval)
 	defer heartbeatTicker.Stop()
 
 	// 3. Configure read limits and compression
-	conn.SetReadLimit(maxMessageSize)
+	if readOnly {
+		conn.SetReadLimit(maxMessageSize)
+	} else {
+		conn.SetReadLimit(maxStreamFrameSize)
+	}
+	conn.SetPongHandler(func(appData string) error {
+		now := time.Now()
+		if stream.sub != nil {
+			if rtt, ok := pongRTT(appData, now); ok {
+				stream.sub.throttle.observeRTT(rtt)
+			}
+		}
+		return conn.SetReadDeadline(now.Add(heartbeatInterval * 2))
+	})
 	if err := conn.SetCompressionLevel(websocket.CompressionBestSpeed); err != nil {
-		lh.logger.Warn("Failed to set WebSocket compression level", zap.Error(err))
+		logger.Warn("Failed to set WebSocket compression level", zap.Error(err))
 	}
 
 	// 4. Message pump: read messages in a loop and handle them
@@ -183,10 +379,10 @@ func (lh *LocationHandler) handleWSConnection(conn *websocket.Conn, sessionID st
 			// Example heartbeat or ping
 			err := conn.WriteControl(websocket.PingMessage, []byte("ping"), time.Now().Add(5*time.Second))
 			if err != nil {
-				lh.logger.Warn("Heartbeat ping failed", zap.Error(err))
+				logger.Warn("Heartbeat ping failed", zap.Error(err))
 				reconnectAttempts++
 				if reconnectAttempts > maxReconnectAttempts {
-					lh.logger.Error("Max reconnect attempts reached, closing connection",
+					logger.Error("Max reconnect attempts reached, closing connection",
 						zap.String("sessionID", sessionID),
 					)
 					return err
@@ -201,171 +397,2185 @@ func (lh *LocationHandler) handleWSConnection(conn *websocket.Conn, sessionID st
 			mt, msg, err := conn.ReadMessage()
 			if err != nil {
 				// Check if it's a normal closure
-				lh.logger.Info("WebSocket read error / closure",
+				logger.Info("WebSocket read error / closure",
 					zap.String("sessionID", sessionID),
 					zap.Error(err),
 				)
 				return err
 			}
-
-			// For demonstration: parse possible location data or commands
-			lh.logger.Debug("Received WebSocket message",
-				zap.String("sessionID", sessionID),
-				zap.Int("messageType", mt),
-				zap.ByteString("payload", msg),
-			)
+			// Frames are handled as JSON, so CBOR and protobuf frames are
+			// transcoded first and captured as the JSON they carry.
+			msg, err = stream.decode(mt, msg)
+			if err != nil {
+				stream.reply(stream.rejected(streamMessage{}, NewAPIError(http.StatusBadRequest, "", "invalid stream frame")))
+				continue
+			}
+			diag.Payload = msg
+			if recorder != nil && !readOnly {
+				if recErr := recorder.Record(websocket.TextMessage, msg); recErr != nil {
+					logger.Warn("Failed to record WebSocket message", zap.String("sessionID", sessionID), zap.Error(recErr))
+					recorder = nil
+				}
+			}
+			stream.handleMessage(msg)
 		}
 	}
 }
 
-// HandleLocationUpdate is an HTTP handler for receiving location updates
-// with recommended decorators (RateLimit, ValidateSession, etc.).
+// writeSessionFrame writes the session-start frame with the profile of the
+// session's dog, when dog profiles are enabled and the pets service has one.
+// It runs before the connection subscribes to the hub, whose writer then owns
+// the connection's writes.
+func (lh *LocationHandler) writeSessionFrame(logger *zap.Logger, conn *websocket.Conn, sessionID string) {
+	dog, err := lh.trackingService.GetSessionDogProfile(sessionID)
+	if err != nil || dog == nil {
+		return
+	}
+	frame, err := json.Marshal(sessionFrame{Type: "session", SessionID: sessionID, Dog: dog})
+	if err != nil {
+		logger.Error("Failed to encode session frame", zap.String("sessionID", sessionID), zap.Error(err))
+		return
+	}
+	conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if err := writeJSONFrame(conn, frame); err != nil {
+		logger.Debug("Session frame write failed", zap.String("sessionID", sessionID), zap.Error(err))
+	}
+	conn.SetWriteDeadline(time.Time{})
+}
+
+// LocationUpdate receives a location update with recommended decorators
+// (RateLimit, ValidateSession, etc.).
 //
 // Steps:
 //  1. Start request metrics tracking
-//  2. Parse and validate location update from request body
-//  3. Extract and validate session info (sessionID, token) from headers or query
+//  2. Parse, reproject to WGS84 and validate location update from request body
+//  3. Extract and validate session info (sessionID, token) from headers or query,
+//     answering updates for ended sessions with a terminal ack
 //  4. Process location update via TrackingService.ProcessLocationUpdate
 //  5. Record relevant metrics
 //  6. Return a response with appropriate status code and message
-func (lh *LocationHandler) HandleLocationUpdate(c *gin.Context) {
+func (lh *LocationHandler) LocationUpdate(req Request) Response {
+	logger, api := lh.log(req), lh.api(req)
+
 	// 1. Start request metrics (placeholder for actual instrumentation)
-	lh.logger.Debug("HandleLocationUpdate started")
+	logger.Debug("LocationUpdate started")
 
 	// 2. Parse input location
 	var loc models.Location
-	if err := c.ShouldBindJSON(&loc); err != nil {
-		lh.logger.Error("Failed to bind JSON for location update", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid location format",
-		})
-		return
+	if err := req.decodeJSON(&loc); err != nil {
+		logger.Error("Failed to decode JSON for location update", zap.Error(err))
+		return errorResponse(http.StatusBadRequest, "invalid location format")
+	}
+	if err := api.NormalizeLocation(&loc); err != nil {
+		logger.Warn("Location reprojection failed", zap.String("locationID", loc.ID), zap.Error(err))
+		return apiErrorResponse(NewAPIError(http.StatusBadRequest, CodeValidationFailed, fmt.Sprintf("validation error: %v", err)))
 	}
 	if err := loc.Validate(); err != nil {
-		lh.logger.Warn("Location validation failed", zap.String("locationID", loc.ID), zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": fmt.Sprintf("validation error: %v", err),
-		})
-		return
+		logger.Warn("Location validation failed", zap.String("locationID", loc.ID), zap.Error(err))
+		return apiErrorResponse(NewAPIError(http.StatusBadRequest, CodeValidationFailed, fmt.Sprintf("validation error: %v", err)))
 	}
 
 	// 3. Extract sessionID and token from headers or query parameters for demonstration
-	sessionID := c.GetHeader("X-Session-ID")
-	token := c.GetHeader("Authorization") // or "Bearer <token>" in real usage
+	sessionID := req.HeaderValue("X-Session-ID")
+	token := req.HeaderValue("Authorization") // or "Bearer <token>" in real usage
 
-	if err := lh.validateSession(sessionID, token); err != nil {
-		lh.logger.Error("Session validation failed", zap.Error(err))
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"error": "session validation failed",
-		})
-		return
+	if err := lh.validateSession(logger, sessionID, token); err != nil {
+		logger.Error("Session validation failed", zap.Error(err))
+		return errorResponse(http.StatusUnauthorized, "session validation failed")
+	}
+	if err := api.CheckIngress(sessionID, services.IngressHTTP); err != nil {
+		return jsonResponse(http.StatusGone, services.TerminalAck(sessionID))
 	}
 
-	// 4. Process location update with a hypothetical service method (not shown in actual tracking.go,
-	//    but required by the specification).
-	//    We demonstrate usage by calling a placeholder 'ProcessLocationUpdate' method
-	//    or an equivalent approach if it existed.
-	err := lh.trackingService.ProcessLocationUpdate(loc)
+	// 4. Process location update as a one-point batch of the session.
+	result, err := api.ProcessLocationUpdate(sessionID, loc)
+	if errors.Is(err, services.ErrStoreUnavailable) {
+		logger.Warn("Location store unavailable", zap.String("sessionID", sessionID), zap.Error(err))
+		return errorResponse(http.StatusServiceUnavailable, "location store unavailable")
+	}
 	if err != nil {
-		lh.logger.Error("Failed to process location update", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to process location update",
+		logger.Error("Failed to process location update", zap.Error(err))
+		return translatedErrorResponse(err, "failed to process location update")
+	}
+
+	// 4a. With the database unavailable the point was spooled; accept it for later storage.
+	if result.QueuedCount > 0 {
+		return jsonResponse(http.StatusAccepted, gin.H{
+			"status":  "queued",
+			"message": "location update queued for storage",
 		})
-		return
 	}
 
 	// 5. Record relevant metrics (placeholder for actual instrumentation)
-	lh.logger.Debug("Location update processed successfully",
+	logger.Debug("Location update processed successfully",
 		zap.String("locationID", loc.ID),
 		zap.String("walkID", loc.WalkID),
 		zap.String("sessionID", sessionID),
 	)
 
 	// 6. Return status
-	c.JSON(http.StatusOK, gin.H{
+	return jsonResponse(http.StatusOK, gin.H{
 		"status":  "ok",
 		"message": "location update successful",
 	})
 }
 
+// HandleLocationUpdate is the gin adapter for LocationUpdate.
+func (lh *LocationHandler) HandleLocationUpdate(c *gin.Context) {
+	serveGin(c, lh.LocationUpdate)
+}
+
+// locationBatchRequest is the body of LocationBatch.
+type locationBatchRequest struct {
+	Locations []*models.Location `json:"locations"`
+}
+
+// locationBatchResult reports what became of the points of a batch upload.
+type locationBatchResult struct {
+	Processed  int               `json:"processed"`
+	Stored     int               `json:"stored"`
+	Queued     int               `json:"queued"`
+	Invalid    int               `json:"invalid"`
+	Duplicates int               `json:"duplicates"`
+	Retransmit []models.SeqRange `json:"retransmit,omitempty"`
+}
+
+// LocationBatch receives up to MaxBatchSize points of the session named by the
+// X-Session-ID header, as a device sends the points it buffered while offline.
+// Bodies may be gzip or deflate encoded (see DecompressionMiddleware), which
+// keeps the mobile data of large batches down. Unlike sequenced uploads, a
+// resent batch is processed again, its points counting as duplicates.
+//
+// Steps:
+//  1. Decode the batch and validate the session, answering batches for ended
+//     sessions with a terminal ack
+//  2. Process it through the tracking service's batch pipeline
+//  3. Return 200, 202 when points were spooled for later storage, or 422 when
+//     none was accepted
+func (lh *LocationHandler) LocationBatch(req Request) Response {
+	logger, api := lh.log(req), lh.api(req)
+
+	// 1. Decode the batch and validate the session.
+	var body locationBatchRequest
+	if err := req.decodeJSON(&body); err != nil {
+		return errorResponse(http.StatusBadRequest, "invalid location batch format")
+	}
+	if len(body.Locations) == 0 {
+		return apiErrorResponse(NewAPIError(http.StatusBadRequest, CodeValidationFailed, "batch requires locations"))
+	}
+	if len(body.Locations) > services.MaxBatchSize {
+		return apiErrorResponse(NewAPIError(http.StatusBadRequest, CodeValidationFailed,
+			fmt.Sprintf("batch exceeds maximum batch size of %d", services.MaxBatchSize)))
+	}
+	sessionID := req.HeaderValue("X-Session-ID")
+	if err := lh.validateSession(logger, sessionID, req.HeaderValue("Authorization")); err != nil {
+		logger.Error("Session validation failed", zap.Error(err))
+		return errorResponse(http.StatusUnauthorized, "session validation failed")
+	}
+	if err := api.CheckIngress(sessionID, services.IngressHTTP); err != nil {
+		return jsonResponse(http.StatusGone, services.TerminalAck(sessionID))
+	}
+
+	// 2. Process the batch.
+	result, err := api.ProcessBatchLocations(sessionID, body.Locations)
+	if errors.Is(err, services.ErrStoreUnavailable) {
+		logger.Warn("Location store unavailable", zap.String("sessionID", sessionID), zap.Error(err))
+		return errorResponse(http.StatusServiceUnavailable, "location store unavailable")
+	}
+	if err != nil {
+		logger.Error("Failed to process location batch",
+			zap.String("sessionID", sessionID),
+			zap.Int("locations", len(body.Locations)),
+			zap.Error(err),
+		)
+		return translatedErrorResponse(err, "failed to process location batch")
+	}
+
+	// 3. Report the outcome.
+	if !result.Success {
+		return apiErrorResponse(NewAPIError(http.StatusUnprocessableEntity, CodeLocationRejected, "no location in the batch was accepted"))
+	}
+	out := locationBatchResult{
+		Processed:  result.ProcessedCount,
+		Stored:     result.StoredCount,
+		Queued:     result.QueuedCount,
+		Invalid:    result.InvalidCount,
+		Duplicates: result.DuplicateCount,
+		Retransmit: result.Retransmit,
+	}
+	if result.QueuedCount > 0 {
+		return jsonResponse(http.StatusAccepted, out)
+	}
+	return jsonResponse(http.StatusOK, out)
+}
+
+// HandleLocationBatch is the gin adapter for LocationBatch.
+func (lh *LocationHandler) HandleLocationBatch(c *gin.Context) {
+	serveGin(c, lh.LocationBatch)
+}
+
 // HandleLocationStream upgrades an HTTP connection to a WebSocket connection,
 // enabling real-time streaming of location data. This method uses handleWSConnection
 // to manage the lifecycle of the WebSocket.
 //
 // Steps:
 //  1. Extract session details (sessionID, token) for validation
-//  2. Validate session
+//  2. Validate session, or the share token of a read-only share-link
+//     subscription, which needs no further authentication
 //  3. Upgrade HTTP to WebSocket
-//  4. Delegate to handleWSConnection
+//  4. Delegate to handleWSConnection, tracking the stream for draining
 //  5. Handle errors and close connection gracefully
+//
+// Streams are refused with 503 once shutdown has begun draining them. Devices
+// that only send locations can connect with subscribe=false, so the points
+// they send are not echoed back to them. Subscribers reconnecting with the
+// resumeToken of the last location frame they received are replayed the
+// frames they missed; a token that does not verify is refused with 401.
+// Subscribers on slow links can ask for at most one location frame per
+// maxFrameIntervalMs milliseconds, or later send a throttle frame.
+// Low-power trackers can negotiate the tracking.v1.cbor subprotocol to send
+// and receive the same frames encoded as CBOR in binary frames, or
+// tracking.v1.protobuf to send their points as LocationBatch binary frames.
 func (lh *LocationHandler) HandleLocationStream(c *gin.Context) {
+	logger := RequestLogger(c, lh.logger)
 	sessionID := c.Query("sessionID")
 	token := c.GetHeader("Authorization")
 
-	err := lh.validateSession(sessionID, token)
+	var shareExpiry time.Time
+	if share := c.Query("share"); share != "" {
+		expiresAt, err := lh.shareLinks.Parse(sessionID, share)
+		if err != nil {
+			logger.Warn("Share token rejected for WebSocket connection", zap.String("sessionID", sessionID), zap.Error(err))
+			AbortWithError(c, NewAPIError(http.StatusUnauthorized, "", err.Error()))
+			return
+		}
+		shareExpiry = expiresAt
+	} else if err := lh.validateSession(logger, sessionID, token); err != nil {
+		logger.Error("Session validation failed for WebSocket connection", zap.Error(err))
+		AbortWithError(c, NewAPIError(http.StatusUnauthorized, "", "invalid or missing session credentials"))
+		return
+	}
+	if lh.Draining() {
+		AbortWithError(c, NewAPIError(http.StatusServiceUnavailable, "", drainCloseReason))
+		return
+	}
+
+	api := services.WithRequestID(lh.trackingService, requestID(c))
+	opts := subscribeOptions{muted: c.Query("subscribe") == "false"}
+	if lh.hub != nil {
+		afterSeq, resuming, err := lh.hub.resumePosition(sessionID, c.Query("resumeToken"))
+		if err != nil {
+			logger.Warn("Resume token rejected for WebSocket connection", zap.String("sessionID", sessionID), zap.Error(err))
+			AbortWithError(c, NewAPIError(http.StatusUnauthorized, "", err.Error()))
+			return
+		}
+		opts.resuming, opts.afterSeq = resuming, afterSeq
+	}
+	frameInterval, err := parseMaxFrameInterval(c.Query(MaxFrameIntervalParam))
 	if err != nil {
-		lh.logger.Error("Session validation failed for WebSocket connection", zap.Error(err))
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or missing session credentials"})
+		AbortWithError(c, NewAPIError(http.StatusBadRequest, CodeValidationFailed, err.Error()))
 		return
 	}
+	opts.frameInterval = frameInterval
 
 	websocketConn, err := lh.wsUpgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
-		lh.logger.Error("WebSocket upgrade failed", zap.Error(err))
+		logger.Error("WebSocket upgrade failed", zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "failed to upgrade connection to WebSocket",
 		})
 		return
 	}
 
+	// Hold the stream guard's connection slot until the WebSocket closes.
+	releaseLease := takeStreamLease(c)
+
 	// Optionally acquire a *websocket.Conn from the pool (demonstration only)
 	pooledConn := lh.connectionPool.Get().(*websocket.Conn)
 	*pooledConn = *websocketConn
 
+	if !lh.trackStream(pooledConn) {
+		// Shutdown began during the upgrade.
+		closeMsg := websocket.FormatCloseMessage(websocket.CloseServiceRestart, drainCloseReason)
+		_ = pooledConn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(5*time.Second))
+		_ = pooledConn.Close()
+		releaseLease()
+		return
+	}
+
+	if !opts.muted {
+		lh.recordAccess(api, sessionID, "GET /ws", c.Request.Header, c.Query("share") != "", requestID(c))
+	}
+
 	go func() {
+		defer releaseLease()
 		defer lh.connectionPool.Put(pooledConn)
-		if wsErr := lh.handleWSConnection(pooledConn, sessionID); wsErr != nil {
-			lh.logger.Warn("handleWSConnection returned error", zap.Error(wsErr))
+		defer lh.untrackStream(pooledConn)
+		if wsErr := lh.handleWSConnection(logger, api, pooledConn, sessionID, shareExpiry, opts); wsErr != nil {
+			logger.Warn("handleWSConnection returned error", zap.Error(wsErr))
 		}
 	}()
 }
 
-// HandleGetLocationHistory retrieves a historical record of a walk session's location data
+// GetLocationHistory retrieves a historical record of a walk session's location data
 // or aggregated statistics from the tracking service. This example calls a hypothetical
 // GetSessionStatistics method per the specification, but usage may vary based on real data flows.
 //
 // Steps:
-//  1. Extract sessionID from query
+//  1. Extract sessionID and the optional fields sparse fieldset from query
 //  2. Validate session if needed
 //  3. Retrieve session statistics or history from the tracking service
-//  4. Return data in a JSON response
-func (lh *LocationHandler) HandleGetLocationHistory(c *gin.Context) {
-	sessionID := c.Query("sessionID")
+//  4. Return data in a JSON response, keeping only the requested fields
+func (lh *LocationHandler) GetLocationHistory(req Request) Response {
+	sessionID := req.QueryParam("sessionID")
 	if sessionID == "" {
-		lh.logger.Error("No sessionID provided to HandleGetLocationHistory")
-		c.JSON(http.StatusBadRequest, gin.H{"error": "sessionID query parameter is required"})
-		return
+		lh.log(req).Error("No sessionID provided to GetLocationHistory")
+		return errorResponse(http.StatusBadRequest, "sessionID query parameter is required")
+	}
+	fields, err := requestFieldset(req, models.TrackingStatistics{})
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, err.Error())
 	}
 
 	// For demonstration, we skip a token check here or reuse validateSession if desired
-	stats, ok := lh.trackingService.GetSessionStatistics(sessionID)
+	stats, ok := lh.api(req).GetSessionStatistics(sessionID)
 	if !ok {
-		lh.logger.Warn("Session statistics not found",
+		lh.log(req).Warn("Session statistics not found",
 			zap.String("sessionID", sessionID),
 		)
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": fmt.Sprintf("no statistics found for sessionID: %s", sessionID),
-		})
-		return
+		return errorResponse(http.StatusNotFound, fmt.Sprintf("no statistics found for sessionID: %s", sessionID))
 	}
 
 	// Convert statistics to JSON; this is a hypothetical approach if stats is a struct
 	payload, err := json.Marshal(stats)
+	if err == nil {
+		payload, err = fields.apply(payload)
+	}
 	if err != nil {
-		lh.logger.Error("Failed to marshal session statistics", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retrieve session history"})
-		return
+		lh.log(req).Error("Failed to marshal session statistics", zap.Error(err))
+		return errorResponse(http.StatusInternalServerError, "failed to retrieve session history")
+	}
+
+	return Response{Status: http.StatusOK, Header: http.Header{"Content-Type": {"application/json"}}, Body: payload}
+}
+
+// HandleGetLocationHistory is the gin adapter for GetLocationHistory.
+func (lh *LocationHandler) HandleGetLocationHistory(c *gin.Context) {
+	serveGin(c, lh.compressed(lh.audited("GET /location/history", "sessionID", lh.GetLocationHistory)))
+}
+
+// ExportLocationHistory serves the persisted track of a session's walk as a
+// file for import into fitness apps. The format query parameter selects the
+// file type; gpx, a GPX 1.1 track with elevation, timestamps and accuracy
+// extensions, is the only format and the default.
+func (lh *LocationHandler) ExportLocationHistory(req Request) Response {
+	sessionID := req.QueryParam("sessionID")
+	if sessionID == "" {
+		return errorResponse(http.StatusBadRequest, "sessionID query parameter is required")
+	}
+	if format := req.QueryParam("format"); format != "" && format != "gpx" {
+		return errorResponse(http.StatusBadRequest, fmt.Sprintf("unsupported export format: %s", format))
+	}
+
+	gpx, err := lh.api(req).ExportWalkGPX(sessionID)
+	if errors.Is(err, services.ErrHistoryExportDisabled) {
+		return errorResponse(http.StatusNotFound, "location history export is not enabled")
+	}
+	if errors.Is(err, services.ErrSessionTrackNotFound) {
+		return errorResponse(http.StatusNotFound, fmt.Sprintf("no track found for sessionID: %s", sessionID))
+	}
+	if err != nil {
+		lh.log(req).Error("Failed to export location history as GPX",
+			zap.String("sessionID", sessionID),
+			zap.Error(err),
+		)
+		return errorResponse(repositoryErrorStatus(err), "failed to export location history")
+	}
+
+	return Response{
+		Status: http.StatusOK,
+		Header: http.Header{
+			"Content-Type":        {utils.GPXContentType},
+			"Content-Disposition": {fmt.Sprintf("attachment; filename=%q", "walk-"+sessionID+".gpx")},
+		},
+		Body: gpx,
+	}
+}
+
+// HandleExportLocationHistory is the gin adapter for ExportLocationHistory.
+func (lh *LocationHandler) HandleExportLocationHistory(c *gin.Context) {
+	serveGin(c, lh.compressed(lh.audited("GET /location/history/export", "sessionID", lh.ExportLocationHistory)))
+}
+
+// GetSessionStatistics returns a session's statistics. With the asOf query
+// parameter (RFC 3339) they are computed only from points recorded up to that
+// instant, answering what the owner saw at the time. The fields query parameter
+// limits the response to the listed members.
+func (lh *LocationHandler) GetSessionStatistics(req Request) Response {
+	sessionID := req.PathParam("sessionID")
+	fields, err := requestFieldset(req, models.TrackingStatistics{})
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, err.Error())
+	}
+
+	var asOf time.Time
+	if asOfStr := req.QueryParam("asOf"); asOfStr != "" {
+		if asOf, err = time.Parse(time.RFC3339, asOfStr); err != nil {
+			return errorResponse(http.StatusBadRequest, "asOf must be an RFC 3339 timestamp")
+		}
+	}
+
+	cacheKey := "statistics:" + sessionID + ":" + req.QueryParam("asOf")
+	stats, err := lh.api(req).GetSessionStatisticsAsOf(sessionID, asOf)
+	if errors.Is(err, services.ErrSessionTrackNotFound) {
+		return errorResponse(http.StatusNotFound, "session not found")
+	}
+	if stale, ok := lh.serveLastKnown(cacheKey, fields, err); ok {
+		return stale
+	}
+	if err != nil {
+		lh.log(req).Error("Failed to compute session statistics",
+			zap.String("sessionID", sessionID),
+			zap.Time("asOf", asOf),
+			zap.Error(err),
+		)
+		return errorResponse(repositoryErrorStatus(err), "failed to compute session statistics")
+	}
+
+	lh.lastKnown.remember(cacheKey, stats)
+	return fields.response(http.StatusOK, stats)
+}
+
+// HandleGetSessionStatistics is the gin adapter for GetSessionStatistics.
+func (lh *LocationHandler) HandleGetSessionStatistics(c *gin.Context) {
+	serveGin(c, lh.audited("GET /sessions/:sessionID/statistics", "sessionID", lh.GetSessionStatistics))
+}
+
+// GetSessionSparkline returns a session's distance-per-minute activity series,
+// computed from the per-minute continuous aggregate, so the owner app can chart
+// a walk's activity without loading its full track. The fields query parameter
+// limits the response to the listed members, e.g. "minutes.distanceMeters".
+func (lh *LocationHandler) GetSessionSparkline(req Request) Response {
+	sessionID := req.PathParam("sessionID")
+	fields, err := requestFieldset(req, models.ActivitySparkline{})
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, err.Error())
+	}
+
+	cacheKey := "sparkline:" + sessionID
+	sparkline, err := lh.api(req).GetSessionSparkline(sessionID)
+	switch {
+	case errors.Is(err, services.ErrSparklineDisabled):
+		return errorResponse(http.StatusNotFound, "activity sparklines are not enabled")
+	case errors.Is(err, services.ErrSessionTrackNotFound):
+		return errorResponse(http.StatusNotFound, "session not found")
+	case err != nil:
+		if stale, ok := lh.serveLastKnown(cacheKey, fields, err); ok {
+			return stale
+		}
+		lh.log(req).Error("Failed to load session sparkline",
+			zap.String("sessionID", sessionID),
+			zap.Error(err),
+		)
+		return errorResponse(repositoryErrorStatus(err), "failed to load session sparkline")
+	}
+
+	lh.lastKnown.remember(cacheKey, sparkline)
+	return fields.response(http.StatusOK, sparkline)
+}
+
+// HandleGetSessionSparkline is the gin adapter for GetSessionSparkline.
+func (lh *LocationHandler) HandleGetSessionSparkline(c *gin.Context) {
+	serveGin(c, lh.audited("GET /sessions/:sessionID/sparkline", "sessionID", lh.GetSessionSparkline))
+}
+
+// GetSessionBreaches returns every breach of the geofences of a session's walk,
+// computed from its stored track and the walk's geofences, for incident
+// reviews: the points around each breach, how far past the boundary the walk
+// went over time, and the total time spent in breach.
+func (lh *LocationHandler) GetSessionBreaches(req Request) Response {
+	sessionID := req.PathParam("sessionID")
+	if sessionID == "" {
+		return errorResponse(http.StatusBadRequest, "sessionID path parameter is required")
+	}
+
+	review, err := lh.api(req).ReviewSessionBreaches(sessionID)
+	switch {
+	case errors.Is(err, services.ErrGeofencesDisabled):
+		return errorResponse(http.StatusNotFound, "geofence persistence is not enabled")
+	case errors.Is(err, services.ErrSessionTrackNotFound):
+		return errorResponse(http.StatusNotFound, "session not found")
+	case err != nil:
+		lh.log(req).Error("Failed to review session breaches",
+			zap.String("sessionID", sessionID),
+			zap.Error(err),
+		)
+		return errorResponse(repositoryErrorStatus(err), "failed to review session breaches")
+	}
+
+	return jsonResponse(http.StatusOK, review)
+}
+
+// HandleGetSessionBreaches is the gin adapter for GetSessionBreaches.
+func (lh *LocationHandler) HandleGetSessionBreaches(c *gin.Context) {
+	serveGin(c, lh.GetSessionBreaches)
+}
+
+// GetWalkTerritory returns the territory coverage computed for a completed
+// walk: the area explored and the share of it that was new to the dog. The owner
+// app uses it for gamification features.
+//
+// Steps:
+//  1. Extract walkID from the path
+//  2. Retrieve the stored coverage from the tracking service
+//  3. Return it as JSON, 404 if it has not been computed, or the status
+//     matching the repository error otherwise
+func (lh *LocationHandler) GetWalkTerritory(req Request) Response {
+	walkID := req.PathParam("walkID")
+	if walkID == "" {
+		return errorResponse(http.StatusBadRequest, "walkID path parameter is required")
+	}
+
+	coverage, err := lh.api(req).GetTerritoryCoverage(walkID)
+	if errors.Is(err, services.ErrTerritoryDisabled) || errors.Is(err, repository.ErrNotFound) {
+		return errorResponse(http.StatusNotFound, fmt.Sprintf("no territory coverage found for walkID: %s", walkID))
+	}
+	if err != nil {
+		lh.log(req).Error("Failed to load territory coverage",
+			zap.String("walkID", walkID),
+			zap.Error(err),
+		)
+		return errorResponse(repositoryErrorStatus(err), "failed to retrieve territory coverage")
+	}
+
+	return jsonResponse(http.StatusOK, coverage)
+}
+
+// HandleGetWalkTerritory is the gin adapter for GetWalkTerritory.
+func (lh *LocationHandler) HandleGetWalkTerritory(c *gin.Context) {
+	serveGin(c, lh.audited("GET /walks/:walkID/territory", "walkID", lh.GetWalkTerritory))
+}
+
+// GetRawSessionEvents returns the append-only event stream recorded for a
+// session in event sourcing mode, for audits and recovery debugging.
+//
+// Steps:
+//  1. Extract sessionID from the path and the limit and cursor of the page
+//  2. Load the page of raw events from the tracking service
+//  3. Return 404 when event sourcing is disabled or the stream is empty
+//  4. Return the page as JSON in occurrence order, with the next page's cursor
+func (lh *LocationHandler) GetRawSessionEvents(req Request) Response {
+	sessionID := req.PathParam("sessionID")
+	if sessionID == "" {
+		return errorResponse(http.StatusBadRequest, "sessionID path parameter is required")
+	}
+
+	scope := "events:" + sessionID
+	after, limit, err := lh.pageQuery(req, scope)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, err.Error())
+	}
+
+	events, next, err := lh.api(req).GetRawSessionEventsPage(sessionID, after, limit)
+	if errors.Is(err, services.ErrEventSourcingDisabled) {
+		return errorResponse(http.StatusNotFound, "event sourcing is not enabled")
 	}
+	if err != nil {
+		lh.log(req).Error("Failed to load session events",
+			zap.String("sessionID", sessionID),
+			zap.Error(err),
+		)
+		return errorResponse(repositoryErrorStatus(err), "failed to retrieve session events")
+	}
+	if len(events) == 0 && after.IsZero() {
+		return errorResponse(http.StatusNotFound, fmt.Sprintf("no events found for sessionID: %s", sessionID))
+	}
+
+	return jsonResponse(http.StatusOK, models.SessionEventPage{Items: events, NextCursor: lh.nextPageCursor(scope, next)})
+}
+
+// HandleGetRawSessionEvents is the gin adapter for GetRawSessionEvents.
+func (lh *LocationHandler) HandleGetRawSessionEvents(c *gin.Context) {
+	serveGin(c, lh.compressed(lh.audited("GET /sessions/:sessionID/events/raw", "sessionID", lh.GetRawSessionEvents)))
+}
+
+// GetSessionLocations lists the persisted points of a session's walk, oldest
+// first, a page of up to limit points at a time. The returned nextCursor is
+// passed as cursor to fetch the following page; pages stay stable while the
+// walk keeps recording. The from and to query parameters (RFC 3339) bound the
+// points' timestamps, and every (keep every Nth point) or bucket (keep the
+// first point per duration, e.g. 30s) down-sample them.
+func (lh *LocationHandler) GetSessionLocations(req Request) Response {
+	sessionID := req.PathParam("sessionID")
+	scope := "history:" + sessionID
+	after, limit, err := lh.pageQuery(req, scope)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, err.Error())
+	}
+	filter, err := historyFilter(req)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, err.Error())
+	}
+
+	locations, next, err := lh.api(req).GetLocationHistoryPage(sessionID, after, limit, filter)
+	switch {
+	case errors.Is(err, services.ErrHistoryPagesDisabled):
+		return errorResponse(http.StatusNotFound, "paginated location history is not enabled")
+	case errors.Is(err, services.ErrSessionTrackNotFound):
+		return errorResponse(http.StatusNotFound, "session not found")
+	case err != nil:
+		lh.log(req).Error("Failed to load location history page",
+			zap.String("sessionID", sessionID),
+			zap.Error(err),
+		)
+		return errorResponse(repositoryErrorStatus(err), "failed to retrieve location history")
+	}
+
+	return jsonResponse(http.StatusOK, models.LocationPage{Items: locations, NextCursor: lh.nextPageCursor(scope, next)})
+}
+
+// HandleGetSessionLocations is the gin adapter for GetSessionLocations.
+func (lh *LocationHandler) HandleGetSessionLocations(c *gin.Context) {
+	serveGin(c, lh.compressed(lh.audited("GET /sessions/:sessionID/locations", "sessionID", lh.GetSessionLocations)))
+}
+
+// historyFilter parses the from, to, every and bucket query parameters of a
+// history listing.
+func historyFilter(req Request) (models.HistoryFilter, error) {
+	var filter models.HistoryFilter
+	var err error
+	if from := req.QueryParam("from"); from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return filter, errors.New("from must be an RFC 3339 timestamp")
+		}
+	}
+	if to := req.QueryParam("to"); to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return filter, errors.New("to must be an RFC 3339 timestamp")
+		}
+	}
+	if every := req.QueryParam("every"); every != "" {
+		if filter.EveryNth, err = strconv.Atoi(every); err != nil || filter.EveryNth < 1 {
+			return filter, errors.New("every must be a positive integer")
+		}
+	}
+	if bucket := req.QueryParam("bucket"); bucket != "" {
+		if filter.Bucket, err = time.ParseDuration(bucket); err != nil || filter.Bucket <= 0 {
+			return filter, errors.New("bucket must be a positive duration such as 30s")
+		}
+	}
+	return filter, filter.Validate()
+}
 
-	c.Data(http.StatusOK, "application/json", payload)
-}
\ No newline at end of file
+// GetWalkerPresence returns the online/offline presence of every walker that
+// has sent a presence heartbeat, independently of whether they have a session.
+func (lh *LocationHandler) GetWalkerPresence(req Request) Response {
+	presence, err := lh.api(req).GetWalkerPresence()
+	if errors.Is(err, services.ErrPresenceDisabled) {
+		return errorResponse(http.StatusNotFound, "walker presence tracking is not enabled")
+	}
+	if err != nil {
+		lh.log(req).Error("Failed to load walker presence", zap.Error(err))
+		return errorResponse(http.StatusInternalServerError, "failed to retrieve walker presence")
+	}
+
+	return jsonResponse(http.StatusOK, presence)
+}
+
+// HandleGetWalkerPresence is the gin adapter for GetWalkerPresence.
+func (lh *LocationHandler) HandleGetWalkerPresence(c *gin.Context) {
+	serveGin(c, lh.GetWalkerPresence)
+}
+
+// ExportSessionFIT serves a session's track as a FIT activity file for import
+// into fitness platforms such as Strava and Garmin Connect.
+func (lh *LocationHandler) ExportSessionFIT(req Request) Response {
+	sessionID := req.PathParam("sessionID")
+	if sessionID == "" {
+		return errorResponse(http.StatusBadRequest, "sessionID path parameter is required")
+	}
+
+	fit, err := lh.api(req).ExportSessionFIT(sessionID)
+	if errors.Is(err, services.ErrSessionTrackNotFound) {
+		return errorResponse(http.StatusNotFound, fmt.Sprintf("no track found for sessionID: %s", sessionID))
+	}
+	if err != nil {
+		lh.log(req).Error("Failed to export session as FIT",
+			zap.String("sessionID", sessionID),
+			zap.Error(err),
+		)
+		return errorResponse(repositoryErrorStatus(err), "failed to export session")
+	}
+
+	return Response{
+		Status: http.StatusOK,
+		Header: http.Header{
+			"Content-Type":        {utils.FITContentType},
+			"Content-Disposition": {fmt.Sprintf("attachment; filename=%q", "walk-"+sessionID+".fit")},
+		},
+		Body: fit,
+	}
+}
+
+// HandleExportSessionFIT is the gin adapter for ExportSessionFIT.
+func (lh *LocationHandler) HandleExportSessionFIT(c *gin.Context) {
+	serveGin(c, lh.audited("GET /sessions/:sessionID/export.fit", "sessionID", lh.ExportSessionFIT))
+}
+
+// PutFitnessToken stores an owner's OAuth tokens for a fitness platform and
+// the dogs whose completed walks should be uploaded there automatically.
+func (lh *LocationHandler) PutFitnessToken(req Request) Response {
+	var token models.FitnessToken
+	if err := req.decodeJSON(&token); err != nil {
+		return errorResponse(http.StatusBadRequest, "invalid fitness token format")
+	}
+	token.OwnerID = req.PathParam("ownerID")
+	token.Provider = req.PathParam("provider")
+	if token.Provider != models.FitnessProviderStrava && token.Provider != models.FitnessProviderGarmin {
+		return errorResponse(http.StatusBadRequest, fmt.Sprintf("unsupported fitness provider: %s", token.Provider))
+	}
+	if token.OwnerID == "" || token.AccessToken == "" {
+		return errorResponse(http.StatusBadRequest, "ownerID and accessToken are required")
+	}
+
+	err := lh.api(req).SaveFitnessToken(&token)
+	if errors.Is(err, services.ErrFitnessIntegrationDisabled) {
+		return errorResponse(http.StatusNotFound, "fitness integration is not enabled")
+	}
+	if err != nil {
+		lh.log(req).Error("Failed to save fitness token",
+			zap.String("ownerID", token.OwnerID),
+			zap.String("provider", token.Provider),
+			zap.Error(err),
+		)
+		return errorResponse(repositoryErrorStatus(err), "failed to save fitness token")
+	}
+	return Response{Status: http.StatusNoContent}
+}
+
+// HandlePutFitnessToken is the gin adapter for PutFitnessToken.
+func (lh *LocationHandler) HandlePutFitnessToken(c *gin.Context) {
+	serveGin(c, lh.PutFitnessToken)
+}
+
+// GetOwnerAlertPreference returns the owner's alert radius for the dog in the path.
+func (lh *LocationHandler) GetOwnerAlertPreference(req Request) Response {
+	ownerID := req.PathParam("ownerID")
+	dogID := req.PathParam("dogID")
+
+	pref, err := lh.api(req).GetOwnerAlertPreference(ownerID, dogID)
+	if errors.Is(err, services.ErrOwnerAlertsDisabled) {
+		return errorResponse(http.StatusNotFound, "owner alerts are not enabled")
+	}
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			lh.log(req).Error("Failed to load owner alert preference",
+				zap.String("ownerID", ownerID),
+				zap.String("dogID", dogID),
+				zap.Error(err),
+			)
+		}
+		return errorResponse(repositoryErrorStatus(err), "failed to retrieve owner alert preference")
+	}
+
+	return jsonResponse(http.StatusOK, pref)
+}
+
+// HandleGetOwnerAlertPreference is the gin adapter for GetOwnerAlertPreference.
+func (lh *LocationHandler) HandleGetOwnerAlertPreference(c *gin.Context) {
+	serveGin(c, lh.GetOwnerAlertPreference)
+}
+
+// PutOwnerAlertPreference sets the owner's alert radius for the dog in the
+// path. The owner is notified when a walk of the dog leaves or returns within
+// it, from the dog's next walk.
+func (lh *LocationHandler) PutOwnerAlertPreference(req Request) Response {
+	var pref models.OwnerAlertPreference
+	if err := req.decodeJSON(&pref); err != nil {
+		return errorResponse(http.StatusBadRequest, "invalid owner alert preference format")
+	}
+	pref.OwnerID = req.PathParam("ownerID")
+	pref.DogID = req.PathParam("dogID")
+
+	err := lh.api(req).PutOwnerAlertPreference(&pref)
+	switch {
+	case errors.Is(err, services.ErrOwnerAlertsDisabled):
+		return errorResponse(http.StatusNotFound, "owner alerts are not enabled")
+	case errors.Is(err, services.ErrInvalidOwnerAlert):
+		return errorResponse(http.StatusBadRequest, err.Error())
+	case err != nil:
+		lh.log(req).Error("Failed to save owner alert preference",
+			zap.String("ownerID", pref.OwnerID),
+			zap.String("dogID", pref.DogID),
+			zap.Error(err),
+		)
+		return errorResponse(repositoryErrorStatus(err), "failed to save owner alert preference")
+	}
+
+	return jsonResponse(http.StatusOK, pref)
+}
+
+// HandlePutOwnerAlertPreference is the gin adapter for PutOwnerAlertPreference.
+func (lh *LocationHandler) HandlePutOwnerAlertPreference(c *gin.Context) {
+	serveGin(c, lh.PutOwnerAlertPreference)
+}
+
+// DeleteOwnerAlertPreference removes the owner's alert radius for the dog in
+// the path.
+func (lh *LocationHandler) DeleteOwnerAlertPreference(req Request) Response {
+	ownerID := req.PathParam("ownerID")
+	dogID := req.PathParam("dogID")
+
+	err := lh.api(req).DeleteOwnerAlertPreference(ownerID, dogID)
+	switch {
+	case errors.Is(err, services.ErrOwnerAlertsDisabled):
+		return errorResponse(http.StatusNotFound, "owner alerts are not enabled")
+	case errors.Is(err, repository.ErrNotFound):
+		return errorResponse(http.StatusNotFound, "owner alert preference not found")
+	case err != nil:
+		lh.log(req).Error("Failed to delete owner alert preference",
+			zap.String("ownerID", ownerID),
+			zap.String("dogID", dogID),
+			zap.Error(err),
+		)
+		return errorResponse(repositoryErrorStatus(err), "failed to delete owner alert preference")
+	}
+
+	return Response{Status: http.StatusNoContent}
+}
+
+// HandleDeleteOwnerAlertPreference is the gin adapter for DeleteOwnerAlertPreference.
+func (lh *LocationHandler) HandleDeleteOwnerAlertPreference(c *gin.Context) {
+	serveGin(c, lh.DeleteOwnerAlertPreference)
+}
+
+// PairDevice pairs the device in the body to the walker in the path, once its
+// attestation proves the request comes from the walker app. Sessions started
+// from it are then trusted.
+func (lh *LocationHandler) PairDevice(req Request) Response {
+	var pairing models.DevicePairing
+	if err := req.decodeJSON(&pairing); err != nil {
+		return errorResponse(http.StatusBadRequest, "invalid device pairing format")
+	}
+	walkerID := req.PathParam("walkerID")
+
+	device, err := lh.api(req).PairDevice(walkerID, &pairing)
+	switch {
+	case errors.Is(err, services.ErrDevicePairingDisabled):
+		return errorResponse(http.StatusNotFound, "device pairing is not enabled")
+	case errors.Is(err, services.ErrInvalidDevicePairing):
+		return errorResponse(http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrInvalidDeviceAttestation):
+		return errorResponse(http.StatusUnauthorized, "device attestation verification failed")
+	case errors.Is(err, services.ErrDeviceRevoked):
+		return errorResponse(http.StatusConflict, err.Error())
+	case err != nil:
+		lh.log(req).Error("Failed to pair device",
+			zap.String("walkerID", walkerID),
+			zap.String("deviceID", pairing.DeviceID),
+			zap.Error(err),
+		)
+		return errorResponse(repositoryErrorStatus(err), "failed to pair device")
+	}
+
+	return jsonResponse(http.StatusCreated, device)
+}
+
+// HandlePairDevice is the gin adapter for PairDevice.
+func (lh *LocationHandler) HandlePairDevice(c *gin.Context) {
+	serveGin(c, lh.PairDevice)
+}
+
+// GetWalkerDevices returns every device the walker in the path paired,
+// including revoked ones.
+func (lh *LocationHandler) GetWalkerDevices(req Request) Response {
+	walkerID := req.PathParam("walkerID")
+
+	devices, err := lh.api(req).GetWalkerDevices(walkerID)
+	if errors.Is(err, services.ErrDevicePairingDisabled) {
+		return errorResponse(http.StatusNotFound, "device pairing is not enabled")
+	}
+	if err != nil {
+		lh.log(req).Error("Failed to load walker devices", zap.String("walkerID", walkerID), zap.Error(err))
+		return errorResponse(repositoryErrorStatus(err), "failed to retrieve walker devices")
+	}
+
+	return jsonResponse(http.StatusOK, devices)
+}
+
+// HandleGetWalkerDevices is the gin adapter for GetWalkerDevices.
+func (lh *LocationHandler) HandleGetWalkerDevices(c *gin.Context) {
+	serveGin(c, lh.GetWalkerDevices)
+}
+
+// RevokeDevice revokes the walker's device in the path, so sessions started
+// from it afterwards are untrusted. The device stays listed, with the time it
+// was revoked, and cannot be paired again.
+func (lh *LocationHandler) RevokeDevice(req Request) Response {
+	walkerID := req.PathParam("walkerID")
+	deviceID := req.PathParam("deviceID")
+
+	err := lh.api(req).RevokeDevice(walkerID, deviceID)
+	switch {
+	case errors.Is(err, services.ErrDevicePairingDisabled):
+		return errorResponse(http.StatusNotFound, "device pairing is not enabled")
+	case errors.Is(err, repository.ErrNotFound):
+		return errorResponse(http.StatusNotFound, "device not found")
+	case err != nil:
+		lh.log(req).Error("Failed to revoke device",
+			zap.String("walkerID", walkerID),
+			zap.String("deviceID", deviceID),
+			zap.Error(err),
+		)
+		return errorResponse(repositoryErrorStatus(err), "failed to revoke device")
+	}
+
+	return Response{Status: http.StatusNoContent}
+}
+
+// HandleRevokeDevice is the gin adapter for RevokeDevice.
+func (lh *LocationHandler) HandleRevokeDevice(c *gin.Context) {
+	serveGin(c, lh.RevokeDevice)
+}
+
+// GetPopularRoutes returns the most walked route segments inside a bounding
+// box, for the walker app's route suggestions. The bbox query parameter is
+// "minLon,minLat,maxLon,maxLat"; limit optionally caps the number of segments.
+//
+// Steps:
+//  1. Parse and validate the bounding box and limit
+//  2. Load the segments from the tracking service
+//  3. Return them as JSON, busiest first
+func (lh *LocationHandler) GetPopularRoutes(req Request) Response {
+	bbox, err := parseBoundingBox("bbox", req.QueryParam("bbox"))
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, err.Error())
+	}
+	limit := 0
+	if limitStr := req.QueryParam("limit"); limitStr != "" {
+		if limit, err = strconv.Atoi(limitStr); err != nil || limit < 1 {
+			return errorResponse(http.StatusBadRequest, "limit must be a positive integer")
+		}
+	}
+
+	segments, err := lh.api(req).GetPopularRoutes(bbox, limit)
+	if errors.Is(err, services.ErrRoutePopularityDisabled) {
+		return errorResponse(http.StatusNotFound, "route popularity is not enabled")
+	}
+	if err != nil {
+		lh.log(req).Error("Failed to load popular routes", zap.Error(err))
+		return errorResponse(repositoryErrorStatus(err), "failed to retrieve popular routes")
+	}
+	if segments == nil {
+		segments = []models.PopularRouteSegment{}
+	}
+
+	return jsonResponse(http.StatusOK, segments)
+}
+
+// HandleGetPopularRoutes is the gin adapter for GetPopularRoutes.
+func (lh *LocationHandler) HandleGetPopularRoutes(c *gin.Context) {
+	serveGin(c, lh.GetPopularRoutes)
+}
+
+// GetDemand returns where and when walks started inside an area recently, by
+// demand grid cell and hour of the day, so the walker app can suggest where and
+// when demand is highest. The area query parameter is
+// "minLon,minLat,maxLon,maxLat"; tz optionally names the IANA time zone hours
+// are given in (default UTC), and days the window (default 28). Buckets with
+// too few starts to be anonymous are withheld.
+//
+// Steps:
+//  1. Parse and validate the area and window
+//  2. Load the heatmap from the tracking service
+//  3. Return it as JSON, busiest buckets first
+func (lh *LocationHandler) GetDemand(req Request) Response {
+	bbox, err := parseBoundingBox("area", req.QueryParam("area"))
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, err.Error())
+	}
+	days := 0
+	if daysStr := req.QueryParam("days"); daysStr != "" {
+		if days, err = strconv.Atoi(daysStr); err != nil || days < 1 {
+			return errorResponse(http.StatusBadRequest, "days must be a positive integer")
+		}
+	}
+
+	heatmap, err := lh.api(req).GetDemandHeatmap(bbox, req.QueryParam("tz"), days)
+	if errors.Is(err, services.ErrDemandDisabled) {
+		return errorResponse(http.StatusNotFound, "demand analytics are not enabled")
+	}
+	if errors.Is(err, services.ErrInvalidDemandQuery) {
+		return errorResponse(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		lh.log(req).Error("Failed to load walk demand", zap.Error(err))
+		return errorResponse(repositoryErrorStatus(err), "failed to retrieve walk demand")
+	}
+
+	return jsonResponse(http.StatusOK, heatmap)
+}
+
+// HandleGetDemand is the gin adapter for GetDemand.
+func (lh *LocationHandler) HandleGetDemand(c *gin.Context) {
+	serveGin(c, lh.GetDemand)
+}
+
+// GetLeaderboard ranks a tenant's walkers over the current period for the
+// walker gamification program. The tenantId query parameter is required;
+// period is day, week (the default) or month, rankBy is distance (the
+// default), walks or quality, and limit optionally caps the number of walkers.
+//
+// Steps:
+//  1. Parse the query parameters
+//  2. Rank the walkers through the tracking service
+//  3. Return the leaderboard as JSON, best first
+func (lh *LocationHandler) GetLeaderboard(req Request) Response {
+	tenantID := req.QueryParam("tenantId")
+	period := req.QueryParam("period")
+	if period == "" {
+		period = models.LeaderboardPeriodWeek
+	}
+	rankBy := req.QueryParam("rankBy")
+	if rankBy == "" {
+		rankBy = models.LeaderboardRankDistance
+	}
+	limit := 0
+	if limitStr := req.QueryParam("limit"); limitStr != "" {
+		var err error
+		if limit, err = strconv.Atoi(limitStr); err != nil || limit < 1 {
+			return errorResponse(http.StatusBadRequest, "limit must be a positive integer")
+		}
+	}
+
+	board, err := lh.api(req).GetLeaderboard(tenantID, period, rankBy, limit)
+	if errors.Is(err, services.ErrLeaderboardDisabled) {
+		return errorResponse(http.StatusNotFound, "walker leaderboard is not enabled")
+	}
+	if errors.Is(err, services.ErrInvalidLeaderboardQuery) {
+		return errorResponse(http.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		lh.log(req).Error("Failed to load walker leaderboard", zap.String("tenantID", tenantID), zap.Error(err))
+		return errorResponse(repositoryErrorStatus(err), "failed to retrieve walker leaderboard")
+	}
+
+	return jsonResponse(http.StatusOK, board)
+}
+
+// HandleGetLeaderboard is the gin adapter for GetLeaderboard.
+func (lh *LocationHandler) HandleGetLeaderboard(c *gin.Context) {
+	serveGin(c, lh.GetLeaderboard)
+}
+
+// GetCurrentWalks lists the running walks from the current walks view, of the
+// tenant given by the tenantId query parameter or of every tenant without it,
+// a page of up to limit walks at a time. The returned nextCursor is passed as
+// cursor to fetch the following page.
+func (lh *LocationHandler) GetCurrentWalks(req Request) Response {
+	tenantID := req.QueryParam("tenantId")
+	scope := "current-walks:" + tenantID
+	after, limit, err := lh.pageQuery(req, scope)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, err.Error())
+	}
+
+	walks, next, err := lh.api(req).GetCurrentWalksPage(tenantID, after, limit)
+	if errors.Is(err, services.ErrCurrentWalksDisabled) {
+		return errorResponse(http.StatusNotFound, "current walks view is not enabled")
+	}
+	if err != nil {
+		lh.log(req).Error("Failed to load current walks", zap.String("tenantID", tenantID), zap.Error(err))
+		return errorResponse(repositoryErrorStatus(err), "failed to retrieve current walks")
+	}
+
+	return jsonResponse(http.StatusOK, models.CurrentWalkPage{Items: walks, NextCursor: lh.nextPageCursor(scope, next)})
+}
+
+// HandleGetCurrentWalks is the gin adapter for GetCurrentWalks.
+func (lh *LocationHandler) HandleGetCurrentWalks(c *gin.Context) {
+	serveGin(c, lh.GetCurrentWalks)
+}
+
+// GetCurrentWalkChanges returns the current walks change feed after the after
+// query parameter (0 for the oldest retained change), up to limit changes.
+// Dashboards pass the returned nextSeq as after on their next poll.
+func (lh *LocationHandler) GetCurrentWalkChanges(req Request) Response {
+	var afterSeq int64
+	if afterStr := req.QueryParam("after"); afterStr != "" {
+		var err error
+		if afterSeq, err = strconv.ParseInt(afterStr, 10, 64); err != nil || afterSeq < 0 {
+			return errorResponse(http.StatusBadRequest, "after must be a non-negative integer")
+		}
+	}
+	limit := 0
+	if limitStr := req.QueryParam("limit"); limitStr != "" {
+		var err error
+		if limit, err = strconv.Atoi(limitStr); err != nil || limit < 1 {
+			return errorResponse(http.StatusBadRequest, "limit must be a positive integer")
+		}
+	}
+
+	feed, err := lh.api(req).GetCurrentWalkChanges(afterSeq, limit)
+	if errors.Is(err, services.ErrCurrentWalksDisabled) {
+		return errorResponse(http.StatusNotFound, "current walks view is not enabled")
+	}
+	if err != nil {
+		lh.log(req).Error("Failed to load current walk changes", zap.Int64("after", afterSeq), zap.Error(err))
+		return errorResponse(repositoryErrorStatus(err), "failed to retrieve current walk changes")
+	}
+
+	return jsonResponse(http.StatusOK, feed)
+}
+
+// HandleGetCurrentWalkChanges is the gin adapter for GetCurrentWalkChanges.
+func (lh *LocationHandler) HandleGetCurrentWalkChanges(c *gin.Context) {
+	serveGin(c, lh.GetCurrentWalkChanges)
+}
+
+// QueryLocations searches recorded points by polygon and time window, for
+// lost-dog search operations. The body is a models.LocationQuery: a GeoJSON
+// polygon, the from/to window, optional walkId and dogId filters, and a mode
+// returning either the matching points or per-walk counts.
+//
+// Steps:
+//  1. Decode the query
+//  2. Run it through the tracking service, which validates it
+//  3. Return the matching points or counts as JSON
+func (lh *LocationHandler) QueryLocations(req Request) Response {
+	var q models.LocationQuery
+	if err := req.decodeJSON(&q); err != nil {
+		return errorResponse(http.StatusBadRequest, "invalid location query format")
+	}
+
+	result, err := lh.api(req).QueryLocations(q)
+	switch {
+	case errors.Is(err, services.ErrLocationQueryDisabled):
+		return errorResponse(http.StatusNotFound, "location queries are not enabled")
+	case errors.Is(err, services.ErrInvalidLocationQuery):
+		return errorResponse(http.StatusBadRequest, err.Error())
+	case err != nil:
+		lh.log(req).Error("Failed to run location query",
+			zap.Time("from", q.From),
+			zap.Time("to", q.To),
+			zap.String("walkID", q.WalkID),
+			zap.String("dogID", q.DogID),
+			zap.Error(err),
+		)
+		return errorResponse(repositoryErrorStatus(err), "failed to run location query")
+	}
+
+	return jsonResponse(http.StatusOK, result)
+}
+
+// HandleQueryLocations is the gin adapter for QueryLocations.
+func (lh *LocationHandler) HandleQueryLocations(c *gin.Context) {
+	serveGin(c, lh.QueryLocations)
+}
+
+// CreateWalkGeofence defines a circular or polygon geofence for a walk. The
+// body is a geofence with its shape and either its center and radiusKm or its
+// vertices; polygons may be given in either winding order and are returned
+// normalized to counter-clockwise.
+func (lh *LocationHandler) CreateWalkGeofence(req Request) Response {
+	walkID := req.PathParam("walkID")
+	if walkID == "" {
+		return errorResponse(http.StatusBadRequest, "walkID path parameter is required")
+	}
+	var spec models.GeofenceRecord
+	if err := req.decodeJSON(&spec); err != nil {
+		return errorResponse(http.StatusBadRequest, "invalid geofence format")
+	}
+
+	geofence, err := lh.api(req).CreateGeofence(walkID, spec)
+	switch {
+	case errors.Is(err, services.ErrGeofencesDisabled):
+		return errorResponse(http.StatusNotFound, "geofence persistence is not enabled")
+	case errors.Is(err, services.ErrInvalidGeofence):
+		return errorResponse(http.StatusBadRequest, err.Error())
+	case err != nil:
+		lh.log(req).Error("Failed to create geofence",
+			zap.String("walkID", walkID),
+			zap.String("shape", spec.Shape),
+			zap.Error(err),
+		)
+		return errorResponse(repositoryErrorStatus(err), "failed to create geofence")
+	}
+
+	return jsonResponse(http.StatusCreated, geofence.Record())
+}
+
+// HandleCreateWalkGeofence is the gin adapter for CreateWalkGeofence.
+func (lh *LocationHandler) HandleCreateWalkGeofence(c *gin.Context) {
+	serveGin(c, lh.CreateWalkGeofence)
+}
+
+// GetWalkGeofences returns the geofences defined for a walk, oldest first.
+func (lh *LocationHandler) GetWalkGeofences(req Request) Response {
+	walkID := req.PathParam("walkID")
+	if walkID == "" {
+		return errorResponse(http.StatusBadRequest, "walkID path parameter is required")
+	}
+
+	geofences, err := lh.api(req).LoadGeofences(walkID)
+	if errors.Is(err, services.ErrGeofencesDisabled) {
+		return errorResponse(http.StatusNotFound, "geofence persistence is not enabled")
+	}
+	if err != nil {
+		lh.log(req).Error("Failed to load geofences",
+			zap.String("walkID", walkID),
+			zap.Error(err),
+		)
+		return errorResponse(repositoryErrorStatus(err), "failed to retrieve geofences")
+	}
+
+	records := make([]models.GeofenceRecord, 0, len(geofences))
+	for _, g := range geofences {
+		records = append(records, g.Record())
+	}
+	return jsonResponse(http.StatusOK, records)
+}
+
+// HandleGetWalkGeofences is the gin adapter for GetWalkGeofences.
+func (lh *LocationHandler) HandleGetWalkGeofences(c *gin.Context) {
+	serveGin(c, lh.GetWalkGeofences)
+}
+
+// sessionStartRequest is the body of a session creation request.
+type sessionStartRequest struct {
+	WalkID   string `json:"walkId"`
+	WalkerID string `json:"walkerId"`
+	DogID    string `json:"dogId"`
+	TenantID string `json:"tenantId"`
+	Region   string `json:"region"`
+	DeviceID string `json:"deviceId"`
+}
+
+// sessionStartResponse is a created session and, with session affinity
+// enabled, the node holding it and the token routing its stream connects there.
+type sessionStartResponse struct {
+	Session       *models.TrackingSession `json:"session"`
+	Node          string                  `json:"node,omitempty"`
+	AffinityToken string                  `json:"affinityToken,omitempty"`
+
+	// Continued is set when the walk's existing session was continued, and
+	// AckedUploadSeq is then its cumulative upload ack.
+	Continued      bool   `json:"continued,omitempty"`
+	AckedUploadSeq uint64 `json:"ackedUploadSeq,omitempty"`
+}
+
+// StartSession starts a tracking session on this instance. With session
+// affinity enabled the response carries an affinity token, also set as a
+// cookie, that the client presents when connecting to the stream so the load
+// balancer routes it to this instance, which holds the session in memory.
+// Sessions from a device that is not trusted get 403 when the tenant's device
+// policy rejects them. Starting a walk that already has an active session,
+// e.g. after the walker app restarted, continues that session with 200, or
+// gets 409 when it belongs to another walker or dog.
+func (lh *LocationHandler) StartSession(req Request) Response {
+	var body sessionStartRequest
+	if err := req.decodeJSON(&body); err != nil {
+		return errorResponse(http.StatusBadRequest, "invalid session format")
+	}
+	if body.WalkID == "" || body.WalkerID == "" || body.DogID == "" {
+		return errorResponse(http.StatusBadRequest, "walkId, walkerId and dogId are required")
+	}
+	if body.Region != "" && !models.ValidRegionCode(body.Region) {
+		return errorResponse(http.StatusBadRequest, "region must be 1-32 lowercase letters, digits or dashes")
+	}
+
+	session, continued, err := lh.api(req).StartOrContinueSession(body.TenantID, body.Region, body.DeviceID, body.WalkID, body.WalkerID, body.DogID)
+	if errors.Is(err, services.ErrUntrustedDevice) {
+		return errorResponse(http.StatusForbidden, err.Error())
+	}
+	if errors.Is(err, services.ErrWalkSessionConflict) {
+		return errorResponse(http.StatusConflict, err.Error())
+	}
+	if err != nil {
+		lh.log(req).Warn("Failed to start session", zap.String("walkID", body.WalkID), zap.Error(err))
+		return errorResponse(http.StatusBadRequest, err.Error())
+	}
+
+	out := sessionStartResponse{Session: session}
+	status := http.StatusCreated
+	if continued {
+		out.Continued = true
+		out.AckedUploadSeq = lh.api(req).AckedUploadSeq(session.ID)
+		status = http.StatusOK
+	}
+	if lh.affinity == nil {
+		return jsonResponse(status, out)
+	}
+	out.Node = lh.affinity.NodeID()
+	out.AffinityToken = lh.affinity.Issue(session.ID)
+	resp := jsonResponse(status, out)
+	resp.Header.Set(NodeHeader, out.Node)
+	resp.Header.Add("Set-Cookie", lh.affinity.Cookie(out.AffinityToken).String())
+	return resp
+}
+
+// HandleStartSession is the gin adapter for StartSession.
+func (lh *LocationHandler) HandleStartSession(c *gin.Context) {
+	serveGin(c, lh.StartSession)
+}
+
+// PostSequencedUpload accepts a device's sequenced location batch. The
+// response carries the session's cumulative acked sequence; a device resends any
+// batch above it until a later ack, from this endpoint, the WebSocket stream or
+// the session's MQTT ack topic, covers it. Resent batches are not reprocessed.
+// Uploads for an ended session get 410 with a terminal ack.
+//
+// Steps:
+//  1. Validate the session token, reject ended sessions, and decode the upload
+//  2. Process it through the tracking service's sequenced upload path
+//  3. Return 202 with the current ack
+func (lh *LocationHandler) PostSequencedUpload(req Request) Response {
+	sessionID := req.PathParam("sessionID")
+	if err := lh.validateSession(lh.log(req), sessionID, req.HeaderValue("Authorization")); err != nil {
+		return errorResponse(http.StatusUnauthorized, "session validation failed")
+	}
+	if err := lh.api(req).CheckIngress(sessionID, services.IngressHTTP); err != nil {
+		return jsonResponse(http.StatusGone, services.TerminalAck(sessionID))
+	}
+
+	var upload services.SequencedUpload
+	if err := req.decodeJSON(&upload); err != nil {
+		return errorResponse(http.StatusBadRequest, "invalid upload format")
+	}
+	if len(upload.Locations) > services.MaxBatchSize {
+		return errorResponse(http.StatusBadRequest, fmt.Sprintf("upload exceeds maximum batch size of %d", services.MaxBatchSize))
+	}
+	upload.SessionID = sessionID
+
+	ack, _, err := lh.api(req).ProcessSequencedUpload(upload)
+	switch {
+	case errors.Is(err, services.ErrInvalidUploadSeq):
+		return errorResponse(http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrUploadSessionClosed):
+		return errorResponse(http.StatusNotFound, "session is not active")
+	case err != nil:
+		lh.log(req).Error("Failed to process sequenced upload",
+			zap.String("sessionID", sessionID),
+			zap.Uint64("uploadSeq", upload.UploadSeq),
+			zap.Error(err),
+		)
+		return translatedErrorResponse(err, "failed to process upload")
+	}
+
+	return jsonResponse(http.StatusAccepted, ack)
+}
+
+// HandlePostSequencedUpload is the gin adapter for PostSequencedUpload.
+func (lh *LocationHandler) HandlePostSequencedUpload(c *gin.Context) {
+	serveGin(c, lh.PostSequencedUpload)
+}
+
+// beaconEventsRequest is the body of a beacon event batch.
+type beaconEventsRequest struct {
+	Events []models.BeaconEvent `json:"events"`
+}
+
+// PostBeaconEvents accepts a batch of Bluetooth beacon sightings (beacon ID,
+// RSSI and timestamp) for an active session, for indoor presence at
+// facilities without GPS coverage. Sightings for an ended session get 410 with
+// a terminal ack.
+//
+// Steps:
+//  1. Validate the session token, reject ended sessions, and decode the batch
+//  2. Ingest it through the tracking service
+//  3. Return 202 with the number of sightings stored and ignored
+func (lh *LocationHandler) PostBeaconEvents(req Request) Response {
+	sessionID := req.PathParam("sessionID")
+	if err := lh.validateSession(lh.log(req), sessionID, req.HeaderValue("Authorization")); err != nil {
+		return errorResponse(http.StatusUnauthorized, "session validation failed")
+	}
+	if err := lh.api(req).CheckIngress(sessionID, services.IngressHTTP); err != nil {
+		return jsonResponse(http.StatusGone, services.TerminalAck(sessionID))
+	}
+
+	var body beaconEventsRequest
+	if err := req.decodeJSON(&body); err != nil {
+		return errorResponse(http.StatusBadRequest, "invalid beacon events format")
+	}
+
+	result, err := lh.api(req).IngestBeaconEvents(sessionID, body.Events)
+	switch {
+	case errors.Is(err, services.ErrBeaconsDisabled):
+		return errorResponse(http.StatusNotFound, "beacon ingestion is not enabled")
+	case errors.Is(err, services.ErrInvalidBeaconEvent):
+		return errorResponse(http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrBeaconSessionClosed):
+		return errorResponse(http.StatusNotFound, "session is not active")
+	case err != nil:
+		lh.log(req).Error("Failed to ingest beacon events",
+			zap.String("sessionID", sessionID),
+			zap.Int("events", len(body.Events)),
+			zap.Error(err),
+		)
+		return errorResponse(repositoryErrorStatus(err), "failed to ingest beacon events")
+	}
+
+	return jsonResponse(http.StatusAccepted, result)
+}
+
+// HandlePostBeaconEvents is the gin adapter for PostBeaconEvents.
+func (lh *LocationHandler) HandlePostBeaconEvents(c *gin.Context) {
+	serveGin(c, lh.PostBeaconEvents)
+}
+
+// GetSessionTimeline returns a session's GPS fixes and indoor beacon periods
+// in time order, with gap entries only where the walker was seen by neither.
+func (lh *LocationHandler) GetSessionTimeline(req Request) Response {
+	sessionID := req.PathParam("sessionID")
+
+	timeline, err := lh.api(req).GetSessionTimeline(sessionID)
+	switch {
+	case errors.Is(err, services.ErrBeaconsDisabled):
+		return errorResponse(http.StatusNotFound, "beacon ingestion is not enabled")
+	case errors.Is(err, services.ErrSessionTrackNotFound):
+		return errorResponse(http.StatusNotFound, "session not found")
+	case err != nil:
+		lh.log(req).Error("Failed to build session timeline",
+			zap.String("sessionID", sessionID),
+			zap.Error(err),
+		)
+		return errorResponse(repositoryErrorStatus(err), "failed to build session timeline")
+	}
+
+	return jsonResponse(http.StatusOK, timeline)
+}
+
+// HandleGetSessionTimeline is the gin adapter for GetSessionTimeline.
+func (lh *LocationHandler) HandleGetSessionTimeline(c *gin.Context) {
+	serveGin(c, lh.audited("GET /sessions/:sessionID/timeline", "sessionID", lh.GetSessionTimeline))
+}
+
+// GetSLOStatus reports the location delivery objective: compliance and
+// remaining error budget over the budget period, and burn rates over shorter
+// windows.
+func (lh *LocationHandler) GetSLOStatus(req Request) Response {
+	status, err := lh.api(req).GetSLOStatus()
+	if errors.Is(err, services.ErrSLOTrackingDisabled) {
+		return errorResponse(http.StatusNotFound, "SLO tracking is not enabled")
+	}
+	if err != nil {
+		lh.log(req).Error("Failed to load SLO status", zap.Error(err))
+		return errorResponse(http.StatusInternalServerError, "failed to retrieve SLO status")
+	}
+
+	return jsonResponse(http.StatusOK, status)
+}
+
+// HandleGetSLOStatus is the gin adapter for GetSLOStatus.
+func (lh *LocationHandler) HandleGetSLOStatus(c *gin.Context) {
+	serveGin(c, lh.GetSLOStatus)
+}
+
+// GetIntegrityReport returns the report of the last scheduled data integrity
+// run: the sampled completed walks and the invariants any of them break.
+func (lh *LocationHandler) GetIntegrityReport(req Request) Response {
+	report, err := lh.api(req).GetIntegrityReport()
+	if errors.Is(err, services.ErrIntegrityChecksDisabled) {
+		return errorResponse(http.StatusNotFound, "data integrity checks are not enabled")
+	}
+	if errors.Is(err, services.ErrNoIntegrityReport) {
+		return errorResponse(http.StatusNotFound, "no data integrity report is available yet")
+	}
+	if err != nil {
+		lh.log(req).Error("Failed to load data integrity report", zap.Error(err))
+		return errorResponse(http.StatusInternalServerError, "failed to retrieve data integrity report")
+	}
+
+	return jsonResponse(http.StatusOK, report)
+}
+
+// HandleGetIntegrityReport is the gin adapter for GetIntegrityReport.
+func (lh *LocationHandler) HandleGetIntegrityReport(c *gin.Context) {
+	serveGin(c, lh.GetIntegrityReport)
+}
+
+// GetConfigReloadStatus reports which settings a configuration reload applies
+// without a restart, what the last reload changed, and the changed settings
+// still waiting for a restart.
+func (lh *LocationHandler) GetConfigReloadStatus(_ Request) Response {
+	if lh.configWatcher == nil {
+		return errorResponse(http.StatusNotFound, "configuration reloading is not enabled")
+	}
+	return jsonResponse(http.StatusOK, lh.configWatcher.Status())
+}
+
+// HandleGetConfigReloadStatus is the gin adapter for GetConfigReloadStatus.
+func (lh *LocationHandler) HandleGetConfigReloadStatus(c *gin.Context) {
+	serveGin(c, lh.GetConfigReloadStatus)
+}
+
+// GetRegionProfiles lists the regional configuration profiles.
+func (lh *LocationHandler) GetRegionProfiles(req Request) Response {
+	profiles, err := lh.api(req).GetRegionProfiles()
+	if errors.Is(err, services.ErrRegionProfilesDisabled) {
+		return errorResponse(http.StatusNotFound, "region profiles are not enabled")
+	}
+	if err != nil {
+		lh.log(req).Error("Failed to load region profiles", zap.Error(err))
+		return errorResponse(repositoryErrorStatus(err), "failed to retrieve region profiles")
+	}
+
+	return jsonResponse(http.StatusOK, profiles)
+}
+
+// HandleGetRegionProfiles is the gin adapter for GetRegionProfiles.
+func (lh *LocationHandler) HandleGetRegionProfiles(c *gin.Context) {
+	serveGin(c, lh.GetRegionProfiles)
+}
+
+// GetRegionProfile returns the profile of the region in the path.
+func (lh *LocationHandler) GetRegionProfile(req Request) Response {
+	region := req.PathParam("region")
+
+	profile, err := lh.api(req).GetRegionProfile(region)
+	if errors.Is(err, services.ErrRegionProfilesDisabled) {
+		return errorResponse(http.StatusNotFound, "region profiles are not enabled")
+	}
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			lh.log(req).Error("Failed to load region profile", zap.String("region", region), zap.Error(err))
+		}
+		return errorResponse(repositoryErrorStatus(err), "failed to retrieve region profile")
+	}
+
+	return jsonResponse(http.StatusOK, profile)
+}
+
+// HandleGetRegionProfile is the gin adapter for GetRegionProfile.
+func (lh *LocationHandler) HandleGetRegionProfile(c *gin.Context) {
+	serveGin(c, lh.GetRegionProfile)
+}
+
+// PutRegionProfile creates or replaces the profile of the region in the path.
+// It applies to sessions started there afterwards.
+func (lh *LocationHandler) PutRegionProfile(req Request) Response {
+	var profile models.RegionProfile
+	if err := req.decodeJSON(&profile); err != nil {
+		return errorResponse(http.StatusBadRequest, "invalid region profile format")
+	}
+	profile.Region = req.PathParam("region")
+
+	err := lh.api(req).PutRegionProfile(&profile)
+	switch {
+	case errors.Is(err, services.ErrRegionProfilesDisabled):
+		return errorResponse(http.StatusNotFound, "region profiles are not enabled")
+	case errors.Is(err, services.ErrInvalidRegionProfile):
+		return errorResponse(http.StatusBadRequest, err.Error())
+	case err != nil:
+		lh.log(req).Error("Failed to save region profile", zap.String("region", profile.Region), zap.Error(err))
+		return errorResponse(repositoryErrorStatus(err), "failed to save region profile")
+	}
+
+	lh.log(req).Info("Region profile updated",
+		zap.String("region", profile.Region),
+		zap.Float64("maxSpeedKmh", profile.MaxSpeedKmh),
+		zap.Float64("geofenceRadiusKm", profile.GeofenceRadiusKm),
+		zap.Int("retentionDays", profile.RetentionDays),
+	)
+	return jsonResponse(http.StatusOK, profile)
+}
+
+// HandlePutRegionProfile is the gin adapter for PutRegionProfile.
+func (lh *LocationHandler) HandlePutRegionProfile(c *gin.Context) {
+	serveGin(c, lh.PutRegionProfile)
+}
+
+// DeleteRegionProfile removes the profile of the region in the path, so
+// sessions started there afterwards get the base settings.
+func (lh *LocationHandler) DeleteRegionProfile(req Request) Response {
+	region := req.PathParam("region")
+
+	err := lh.api(req).DeleteRegionProfile(region)
+	switch {
+	case errors.Is(err, services.ErrRegionProfilesDisabled):
+		return errorResponse(http.StatusNotFound, "region profiles are not enabled")
+	case errors.Is(err, repository.ErrNotFound):
+		return errorResponse(http.StatusNotFound, "region profile not found")
+	case err != nil:
+		lh.log(req).Error("Failed to delete region profile", zap.String("region", region), zap.Error(err))
+		return errorResponse(repositoryErrorStatus(err), "failed to delete region profile")
+	}
+
+	lh.log(req).Info("Region profile deleted", zap.String("region", region))
+	return Response{Status: http.StatusNoContent}
+}
+
+// HandleDeleteRegionProfile is the gin adapter for DeleteRegionProfile.
+func (lh *LocationHandler) HandleDeleteRegionProfile(c *gin.Context) {
+	serveGin(c, lh.DeleteRegionProfile)
+}
+
+// GetGeofenceGroups lists the geofence groups enforced on every walk. Only
+// admins may list them.
+func (lh *LocationHandler) GetGeofenceGroups(req Request) Response {
+	if _, err := lh.adminPrincipal(req); errors.Is(err, ErrNotAdmin) {
+		return errorResponse(http.StatusForbidden, err.Error())
+	} else if err != nil {
+		return errorResponse(http.StatusUnauthorized, err.Error())
+	}
+
+	groups, err := lh.api(req).GetGeofenceGroups()
+	if errors.Is(err, services.ErrGeofenceGroupsDisabled) {
+		return errorResponse(http.StatusNotFound, "geofence groups are not enabled")
+	}
+	if err != nil {
+		lh.log(req).Error("Failed to load geofence groups", zap.Error(err))
+		return errorResponse(repositoryErrorStatus(err), "failed to retrieve geofence groups")
+	}
+
+	return jsonResponse(http.StatusOK, groups)
+}
+
+// HandleGetGeofenceGroups is the gin adapter for GetGeofenceGroups.
+func (lh *LocationHandler) HandleGetGeofenceGroups(c *gin.Context) {
+	serveGin(c, lh.GetGeofenceGroups)
+}
+
+// PutGeofenceGroup creates or replaces the geofence group named in the path,
+// such as school zones enforced during pickup hours. It is enforced from the
+// next point checked. Only admins may define groups.
+func (lh *LocationHandler) PutGeofenceGroup(req Request) Response {
+	if _, err := lh.adminPrincipal(req); errors.Is(err, ErrNotAdmin) {
+		return errorResponse(http.StatusForbidden, err.Error())
+	} else if err != nil {
+		return errorResponse(http.StatusUnauthorized, err.Error())
+	}
+
+	var spec models.GeofenceGroupRecord
+	if err := req.decodeJSON(&spec); err != nil {
+		return errorResponse(http.StatusBadRequest, "invalid geofence group format")
+	}
+	spec.Name = req.PathParam("name")
+
+	group, err := lh.api(req).PutGeofenceGroup(&spec)
+	switch {
+	case errors.Is(err, services.ErrGeofenceGroupsDisabled):
+		return errorResponse(http.StatusNotFound, "geofence groups are not enabled")
+	case errors.Is(err, services.ErrInvalidGeofenceGroup):
+		return errorResponse(http.StatusBadRequest, err.Error())
+	case err != nil:
+		lh.log(req).Error("Failed to save geofence group", zap.String("name", spec.Name), zap.Error(err))
+		return errorResponse(repositoryErrorStatus(err), "failed to save geofence group")
+	}
+
+	lh.log(req).Info("Geofence group updated",
+		zap.String("name", group.Name),
+		zap.String("groupID", group.ID),
+		zap.String("schedule", group.Schedule),
+		zap.Int("geofences", len(group.Geofences)),
+	)
+	return jsonResponse(http.StatusOK, group)
+}
+
+// HandlePutGeofenceGroup is the gin adapter for PutGeofenceGroup.
+func (lh *LocationHandler) HandlePutGeofenceGroup(c *gin.Context) {
+	serveGin(c, lh.PutGeofenceGroup)
+}
+
+// DeleteGeofenceGroup removes the geofence group named in the path and stops
+// enforcing it. Only admins may remove groups.
+func (lh *LocationHandler) DeleteGeofenceGroup(req Request) Response {
+	if _, err := lh.adminPrincipal(req); errors.Is(err, ErrNotAdmin) {
+		return errorResponse(http.StatusForbidden, err.Error())
+	} else if err != nil {
+		return errorResponse(http.StatusUnauthorized, err.Error())
+	}
+
+	name := req.PathParam("name")
+	err := lh.api(req).DeleteGeofenceGroup(name)
+	switch {
+	case errors.Is(err, services.ErrGeofenceGroupsDisabled):
+		return errorResponse(http.StatusNotFound, "geofence groups are not enabled")
+	case errors.Is(err, repository.ErrNotFound):
+		return errorResponse(http.StatusNotFound, "geofence group not found")
+	case err != nil:
+		lh.log(req).Error("Failed to delete geofence group", zap.String("name", name), zap.Error(err))
+		return errorResponse(repositoryErrorStatus(err), "failed to delete geofence group")
+	}
+
+	lh.log(req).Info("Geofence group deleted", zap.String("name", name))
+	return Response{Status: http.StatusNoContent}
+}
+
+// HandleDeleteGeofenceGroup is the gin adapter for DeleteGeofenceGroup.
+func (lh *LocationHandler) HandleDeleteGeofenceGroup(c *gin.Context) {
+	serveGin(c, lh.DeleteGeofenceGroup)
+}
+
+// sessionBatchPolicy is the body of PutSessionBatchPolicy: the write
+// coalescing policy of one session, with its flush window in milliseconds.
+type sessionBatchPolicy struct {
+	MaxPoints   int   `json:"maxPoints"`
+	MinWindowMs int64 `json:"minWindowMs"`
+	MaxWindowMs int64 `json:"maxWindowMs"`
+}
+
+// PutSessionBatchPolicy overrides the write coalescing policy of the active
+// session in the path, e.g. to flush a closely watched walk sooner than the
+// configured policy would. Only admins may tune sessions.
+func (lh *LocationHandler) PutSessionBatchPolicy(req Request) Response {
+	if _, err := lh.adminPrincipal(req); errors.Is(err, ErrNotAdmin) {
+		return errorResponse(http.StatusForbidden, err.Error())
+	} else if err != nil {
+		return errorResponse(http.StatusUnauthorized, err.Error())
+	}
+
+	var body sessionBatchPolicy
+	if err := req.decodeJSON(&body); err != nil {
+		return errorResponse(http.StatusBadRequest, "invalid batch policy format")
+	}
+	sessionID := req.PathParam("sessionID")
+	policy := services.BatchPolicy{
+		MaxPoints: body.MaxPoints,
+		MinWindow: time.Duration(body.MinWindowMs) * time.Millisecond,
+		MaxWindow: time.Duration(body.MaxWindowMs) * time.Millisecond,
+	}
+
+	err := lh.api(req).SetSessionBatchPolicy(sessionID, policy)
+	switch {
+	case errors.Is(err, services.ErrCoalescingDisabled):
+		return errorResponse(http.StatusNotFound, "location write coalescing is not enabled")
+	case errors.Is(err, services.ErrBatchSessionInactive):
+		return errorResponse(http.StatusNotFound, "session is not active")
+	case errors.Is(err, services.ErrInvalidBatchPolicy):
+		return errorResponse(http.StatusBadRequest, err.Error())
+	case err != nil:
+		lh.log(req).Error("Failed to set session batch policy", zap.String("sessionID", sessionID), zap.Error(err))
+		return errorResponse(http.StatusInternalServerError, "failed to set batch policy")
+	}
+
+	lh.log(req).Info("Session batch policy updated",
+		zap.String("sessionID", sessionID),
+		zap.Int("maxPoints", policy.MaxPoints),
+		zap.Duration("minWindow", policy.MinWindow),
+		zap.Duration("maxWindow", policy.MaxWindow),
+	)
+	return jsonResponse(http.StatusOK, body)
+}
+
+// HandlePutSessionBatchPolicy is the gin adapter for PutSessionBatchPolicy.
+func (lh *LocationHandler) HandlePutSessionBatchPolicy(c *gin.Context) {
+	serveGin(c, lh.PutSessionBatchPolicy)
+}
+
+// RebuildSession reconstructs the session in the path from its recorded event
+// stream and, unless it has completed, replaces the in-memory copy with it,
+// e.g. after a crash or a bug left that copy wrong. Only admins may rebuild
+// sessions.
+func (lh *LocationHandler) RebuildSession(req Request) Response {
+	if _, err := lh.adminPrincipal(req); errors.Is(err, ErrNotAdmin) {
+		return errorResponse(http.StatusForbidden, err.Error())
+	} else if err != nil {
+		return errorResponse(http.StatusUnauthorized, err.Error())
+	}
+
+	sessionID := req.PathParam("sessionID")
+	session, err := lh.api(req).RebuildSession(sessionID)
+	switch {
+	case errors.Is(err, services.ErrEventSourcingDisabled):
+		return errorResponse(http.StatusNotFound, "event sourcing is not enabled")
+	case errors.Is(err, services.ErrNoSessionEvents):
+		return errorResponse(http.StatusNotFound, fmt.Sprintf("no events found for sessionID: %s", sessionID))
+	case err != nil:
+		lh.log(req).Error("Failed to rebuild session", zap.String("sessionID", sessionID), zap.Error(err))
+		return errorResponse(repositoryErrorStatus(err), "failed to rebuild session")
+	}
+	return jsonResponse(http.StatusOK, session)
+}
+
+// HandleRebuildSession is the gin adapter for RebuildSession.
+func (lh *LocationHandler) HandleRebuildSession(c *gin.Context) {
+	serveGin(c, lh.RebuildSession)
+}
+
+// GetRetentionPreview reports what the retention policy and region retention
+// would compress, delete and drop if they ran now, without modifying data.
+func (lh *LocationHandler) GetRetentionPreview(req Request) Response {
+	report, err := lh.api(req).PreviewRetention()
+	if errors.Is(err, services.ErrRetentionDisabled) {
+		return errorResponse(http.StatusNotFound, "data retention is not configured")
+	}
+	if err != nil {
+		lh.log(req).Error("Failed to preview data retention", zap.Error(err))
+		return errorResponse(repositoryErrorStatus(err), "failed to preview data retention")
+	}
+
+	return jsonResponse(http.StatusOK, report)
+}
+
+// HandleGetRetentionPreview is the gin adapter for GetRetentionPreview.
+func (lh *LocationHandler) HandleGetRetentionPreview(c *gin.Context) {
+	serveGin(c, lh.GetRetentionPreview)
+}
+
+// ShareSession shares a session's live stream with a subscriber that must
+// receive it encrypted. Only the session's walker, identified by their bearer
+// token, may share it. The session's stream key is rotated and the new key is
+// returned for delivery to the subscriber; existing subscribers fetch it from
+// GetStreamKey when frames arrive under the new key ID.
+func (lh *LocationHandler) ShareSession(req Request) Response {
+	sessionID := req.PathParam("sessionID")
+	subscriberID := req.PathParam("subscriberID")
+	caller, err := lh.principal(req)
+	if err != nil {
+		return errorResponse(http.StatusUnauthorized, err.Error())
+	}
+	if subscriberID == "" {
+		return errorResponse(http.StatusBadRequest, "subscriberID path parameter is required")
+	}
+
+	key, err := lh.api(req).ShareSession(sessionID, caller.Subject, subscriberID)
+	switch {
+	case errors.Is(err, services.ErrStreamEncryptionDisabled):
+		return errorResponse(http.StatusNotFound, "stream encryption is not enabled")
+	case errors.Is(err, services.ErrStreamSessionInactive):
+		return errorResponse(http.StatusNotFound, "session is not active")
+	case errors.Is(err, services.ErrNotSessionOwner):
+		return errorResponse(http.StatusForbidden, "only the session's walker can share it")
+	case err != nil:
+		lh.log(req).Error("Failed to share session stream",
+			zap.String("sessionID", sessionID),
+			zap.String("subscriberID", subscriberID),
+			zap.Error(err),
+		)
+		return errorResponse(http.StatusInternalServerError, "failed to share session")
+	}
+
+	return jsonResponse(http.StatusOK, key)
+}
+
+// HandleShareSession is the gin adapter for ShareSession.
+func (lh *LocationHandler) HandleShareSession(c *gin.Context) {
+	serveGin(c, lh.ShareSession)
+}
+
+// UnshareSession revokes a subscriber's access to a session's encrypted stream,
+// rotating the key so frames sent afterwards are unreadable to them. The
+// session's walker can remove any subscriber, and a subscriber themselves.
+func (lh *LocationHandler) UnshareSession(req Request) Response {
+	sessionID := req.PathParam("sessionID")
+	subscriberID := req.PathParam("subscriberID")
+	caller, err := lh.principal(req)
+	if err != nil {
+		return errorResponse(http.StatusUnauthorized, err.Error())
+	}
+
+	err = lh.api(req).UnshareSession(sessionID, caller.Subject, subscriberID)
+	switch {
+	case errors.Is(err, services.ErrStreamEncryptionDisabled):
+		return errorResponse(http.StatusNotFound, "stream encryption is not enabled")
+	case errors.Is(err, services.ErrNotSessionOwner):
+		return errorResponse(http.StatusForbidden, "only the session's walker or the subscriber can unshare it")
+	case errors.Is(err, services.ErrNotStreamMember):
+		return errorResponse(http.StatusNotFound, "session is not shared with subscriber")
+	case err != nil:
+		lh.log(req).Error("Failed to unshare session stream",
+			zap.String("sessionID", sessionID),
+			zap.String("subscriberID", subscriberID),
+			zap.Error(err),
+		)
+		return errorResponse(http.StatusInternalServerError, "failed to unshare session")
+	}
+
+	return Response{Status: http.StatusNoContent}
+}
+
+// HandleUnshareSession is the gin adapter for UnshareSession.
+func (lh *LocationHandler) HandleUnshareSession(c *gin.Context) {
+	serveGin(c, lh.UnshareSession)
+}
+
+// GetStreamKey returns a session's current stream key to one of its
+// subscribers, e.g. after a rotation. The caller's bearer token must identify
+// the subscriber in the path.
+func (lh *LocationHandler) GetStreamKey(req Request) Response {
+	sessionID := req.PathParam("sessionID")
+	subscriberID := req.PathParam("subscriberID")
+	caller, err := lh.principal(req)
+	if err != nil {
+		return errorResponse(http.StatusUnauthorized, err.Error())
+	}
+	if caller.Subject != subscriberID {
+		return errorResponse(http.StatusForbidden, "stream keys are only issued to the subscriber themselves")
+	}
+
+	key, err := lh.api(req).GetStreamKey(sessionID, subscriberID)
+	switch {
+	case errors.Is(err, services.ErrStreamEncryptionDisabled):
+		return errorResponse(http.StatusNotFound, "stream encryption is not enabled")
+	case errors.Is(err, services.ErrNotStreamMember):
+		return errorResponse(http.StatusForbidden, "session is not shared with subscriber")
+	case err != nil:
+		lh.log(req).Error("Failed to load stream key",
+			zap.String("sessionID", sessionID),
+			zap.String("subscriberID", subscriberID),
+			zap.Error(err),
+		)
+		return errorResponse(http.StatusInternalServerError, "failed to retrieve stream key")
+	}
+
+	return jsonResponse(http.StatusOK, key)
+}
+
+// HandleGetStreamKey is the gin adapter for GetStreamKey.
+func (lh *LocationHandler) HandleGetStreamKey(c *gin.Context) {
+	serveGin(c, lh.GetStreamKey)
+}
+
+// streamTier assigns a session's replay buffer to a configured tier.
+type streamTier struct {
+	SessionID string `json:"sessionId"`
+	Tier      string `json:"tier"`
+}
+
+// PutStreamTier assigns the replay buffer of the session in the path to the
+// tier in the body, whose frame count and age limits bound how far back its
+// reconnecting subscribers can resume.
+func (lh *LocationHandler) PutStreamTier(req Request) Response {
+	var body streamTier
+	if err := req.decodeJSON(&body); err != nil || body.Tier == "" {
+		return errorResponse(http.StatusBadRequest, "body must name a tier")
+	}
+	body.SessionID = req.PathParam("sessionID")
+	if lh.hub == nil {
+		return errorResponse(http.StatusNotFound, "resumable streams are not enabled")
+	}
+
+	err := lh.hub.SetStreamTier(body.SessionID, body.Tier)
+	switch {
+	case errors.Is(err, ErrResumableStreamsDisabled):
+		return errorResponse(http.StatusNotFound, "resumable streams are not enabled")
+	case errors.Is(err, utils.ErrUnknownStreamTier):
+		return errorResponse(http.StatusBadRequest, err.Error())
+	case err != nil:
+		lh.log(req).Error("Failed to assign stream tier",
+			zap.String("sessionID", body.SessionID),
+			zap.String("tier", body.Tier),
+			zap.Error(err),
+		)
+		return errorResponse(http.StatusServiceUnavailable, "failed to assign stream tier")
+	}
+
+	lh.log(req).Info("Stream tier assigned", zap.String("sessionID", body.SessionID), zap.String("tier", body.Tier))
+	return jsonResponse(http.StatusOK, body)
+}
+
+// HandlePutStreamTier is the gin adapter for PutStreamTier.
+func (lh *LocationHandler) HandlePutStreamTier(c *gin.Context) {
+	serveGin(c, lh.PutStreamTier)
+}
+
+// GetQuarantinedPoints lists the location points quarantined at ingestion,
+// newest first, optionally filtered by session and rejection reason.
+func (lh *LocationHandler) GetQuarantinedPoints(req Request) Response {
+	query := models.QuarantineQuery{
+		SessionID: req.QueryParam("sessionId"),
+		Reason:    req.QueryParam("reason"),
+	}
+	if limitStr := req.QueryParam("limit"); limitStr != "" {
+		var err error
+		if query.Limit, err = strconv.Atoi(limitStr); err != nil || query.Limit < 1 {
+			return errorResponse(http.StatusBadRequest, "limit must be a positive integer")
+		}
+	}
+
+	points, err := lh.api(req).GetQuarantinedPoints(query)
+	if errors.Is(err, services.ErrQuarantineDisabled) {
+		return errorResponse(http.StatusNotFound, "location quarantine is not enabled")
+	}
+	if err != nil {
+		lh.log(req).Error("Failed to load quarantined locations", zap.Error(err))
+		return errorResponse(repositoryErrorStatus(err), "failed to retrieve quarantined locations")
+	}
+
+	return jsonResponse(http.StatusOK, points)
+}
+
+// HandleGetQuarantinedPoints is the gin adapter for GetQuarantinedPoints.
+func (lh *LocationHandler) HandleGetQuarantinedPoints(c *gin.Context) {
+	serveGin(c, lh.GetQuarantinedPoints)
+}
+
+// ReingestQuarantined ingests quarantined points again under the current
+// validation rules, typically after thresholds were adjusted.
+func (lh *LocationHandler) ReingestQuarantined(req Request) Response {
+	var body models.ReingestRequest
+	if err := req.decodeJSON(&body); err != nil {
+		return errorResponse(http.StatusBadRequest, "invalid reingest request format")
+	}
+
+	result, err := lh.api(req).ReingestQuarantined(body.IDs)
+	switch {
+	case errors.Is(err, services.ErrQuarantineDisabled):
+		return errorResponse(http.StatusNotFound, "location quarantine is not enabled")
+	case errors.Is(err, services.ErrInvalidReingest):
+		return errorResponse(http.StatusBadRequest, err.Error())
+	case err != nil:
+		lh.log(req).Error("Failed to reingest quarantined locations", zap.Error(err))
+		return errorResponse(repositoryErrorStatus(err), "failed to reingest quarantined locations")
+	}
+
+	return jsonResponse(http.StatusOK, result)
+}
+
+// HandleReingestQuarantined is the gin adapter for ReingestQuarantined.
+func (lh *LocationHandler) HandleReingestQuarantined(c *gin.Context) {
+	serveGin(c, lh.ReingestQuarantined)
+}
+
+// AccessEscrowedKeys releases the escrowed stream keys of a walk's session
+// under a legal request, once every escrow custodian presented their share.
+// Every attempt is recorded in the escrow audit log. Only admins may access
+// escrowed keys, and the audit log records the admin their access token
+// identifies.
+func (lh *LocationHandler) AccessEscrowedKeys(req Request) Response {
+	caller, err := lh.adminPrincipal(req)
+	if errors.Is(err, ErrNotAdmin) {
+		return errorResponse(http.StatusForbidden, err.Error())
+	}
+	if err != nil {
+		return errorResponse(http.StatusUnauthorized, err.Error())
+	}
+
+	sessionID := req.PathParam("sessionID")
+	var body models.EscrowAccessRequest
+	if err := req.decodeJSON(&body); err != nil {
+		return errorResponse(http.StatusBadRequest, "invalid escrow access request format")
+	}
+	body.RequestedBy = caller.Subject
+
+	result, err := lh.api(req).AccessEscrowedKeys(sessionID, body)
+	switch {
+	case errors.Is(err, services.ErrKeyEscrowDisabled):
+		return errorResponse(http.StatusNotFound, "key escrow is not enabled")
+	case errors.Is(err, services.ErrInvalidEscrowRequest):
+		return errorResponse(http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrEscrowSharesRejected):
+		return errorResponse(http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrNoEscrowedKeys):
+		return errorResponse(http.StatusNotFound, err.Error())
+	case err != nil:
+		lh.log(req).Error("Failed to access escrowed stream keys", zap.String("sessionID", sessionID), zap.Error(err))
+		return errorResponse(repositoryErrorStatus(err), "failed to access escrowed stream keys")
+	}
+
+	return jsonResponse(http.StatusOK, result)
+}
+
+// HandleAccessEscrowedKeys is the gin adapter for AccessEscrowedKeys.
+func (lh *LocationHandler) HandleAccessEscrowedKeys(c *gin.Context) {
+	serveGin(c, lh.AccessEscrowedKeys)
+}
+
+// GetEscrowAccessLog lists the escrow audit log, newest first, optionally
+// restricted to one tenant. Only admins may read it.
+func (lh *LocationHandler) GetEscrowAccessLog(req Request) Response {
+	if _, err := lh.adminPrincipal(req); errors.Is(err, ErrNotAdmin) {
+		return errorResponse(http.StatusForbidden, err.Error())
+	} else if err != nil {
+		return errorResponse(http.StatusUnauthorized, err.Error())
+	}
+
+	limit := 0
+	if limitStr := req.QueryParam("limit"); limitStr != "" {
+		var err error
+		if limit, err = strconv.Atoi(limitStr); err != nil || limit < 1 {
+			return errorResponse(http.StatusBadRequest, "limit must be a positive integer")
+		}
+	}
+
+	records, err := lh.api(req).GetEscrowAccessLog(req.QueryParam("tenantId"), limit)
+	if errors.Is(err, services.ErrKeyEscrowDisabled) {
+		return errorResponse(http.StatusNotFound, "key escrow is not enabled")
+	}
+	if err != nil {
+		lh.log(req).Error("Failed to load escrow access log", zap.Error(err))
+		return errorResponse(repositoryErrorStatus(err), "failed to retrieve escrow access log")
+	}
+
+	return jsonResponse(http.StatusOK, records)
+}
+
+// HandleGetEscrowAccessLog is the gin adapter for GetEscrowAccessLog.
+func (lh *LocationHandler) HandleGetEscrowAccessLog(c *gin.Context) {
+	serveGin(c, lh.GetEscrowAccessLog)
+}
+
+// GetWalkAccessLog lists who read the walk's location data, when and through
+// which endpoint, newest first, so its owner can see every access.
+func (lh *LocationHandler) GetWalkAccessLog(req Request) Response {
+	walkID := req.PathParam("walkID")
+	if walkID == "" {
+		return errorResponse(http.StatusBadRequest, "walkID is required")
+	}
+	limit := 0
+	if limitStr := req.QueryParam("limit"); limitStr != "" {
+		var err error
+		if limit, err = strconv.Atoi(limitStr); err != nil || limit < 1 {
+			return errorResponse(http.StatusBadRequest, "limit must be a positive integer")
+		}
+	}
+
+	records, err := lh.api(req).GetWalkAccessLog(walkID, limit)
+	if errors.Is(err, services.ErrAccessLogDisabled) {
+		return errorResponse(http.StatusNotFound, "walk access logging is not enabled")
+	}
+	if err != nil {
+		lh.log(req).Error("Failed to load walk access log", zap.String("walkID", walkID), zap.Error(err))
+		return errorResponse(repositoryErrorStatus(err), "failed to retrieve walk access log")
+	}
+
+	return jsonResponse(http.StatusOK, records)
+}
+
+// HandleGetWalkAccessLog is the gin adapter for GetWalkAccessLog.
+func (lh *LocationHandler) HandleGetWalkAccessLog(c *gin.Context) {
+	serveGin(c, lh.GetWalkAccessLog)
+}
+
+// parseBoundingBox parses a "minLon,minLat,maxLon,maxLat" bounding box given
+// in the query parameter named param.
+func parseBoundingBox(param, spec string) (models.BoundingBox, error) {
+	var bbox models.BoundingBox
+	parts := strings.Split(spec, ",")
+	if len(parts) != 4 {
+		return bbox, fmt.Errorf("%s must be minLon,minLat,maxLon,maxLat", param)
+	}
+	var coords [4]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return bbox, fmt.Errorf("%s coordinate %q is not a number", param, part)
+		}
+		coords[i] = v
+	}
+	bbox = models.BoundingBox{
+		MinLongitude: coords[0],
+		MinLatitude:  coords[1],
+		MaxLongitude: coords[2],
+		MaxLatitude:  coords[3],
+	}
+	return bbox, bbox.Validate()
+}
+
+// repositoryErrorStatus maps repository errors to HTTP statuses: invalid input is
+// the client's fault, missing records are 404, and exhausted retries indicate a
+// transient storage outage the client may retry. Anything else is a 500.
+func repositoryErrorStatus(err error) int {
+	var exhausted *repository.ErrRetryExhausted
+	switch {
+	case errors.Is(err, repository.ErrInvalidInput):
+		return http.StatusBadRequest
+	case errors.Is(err, repository.ErrNotFound):
+		return http.StatusNotFound
+	case errors.As(err, &exhausted), errors.Is(err, services.ErrStoreUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
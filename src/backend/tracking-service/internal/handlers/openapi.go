@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	// bytes for buffering response bodies in debug mode (go1.21)
+	"bytes"
+	// errors for unwrapping validation error chains (go1.21)
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	// gin for HTTP routing and middleware (github.com/gin-gonic/gin v1.9.1)
+	"github.com/gin-gonic/gin"
+
+	// kin-openapi for loading the spec and validating requests/responses (github.com/getkin/kin-openapi v0.118.0)
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+)
+
+// responseValidationHeader is set on responses that failed validation in debug mode,
+// making client/server schema drift visible without altering the response itself.
+const responseValidationHeader = "X-Response-Validation"
+
+// SchemaViolation describes a single spec violation, located by a JSON pointer into
+// the request body (e.g. "/latitude") or by the offending parameter name.
+type SchemaViolation struct {
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+// OpenAPIValidator validates inbound requests, and optionally outbound responses,
+// against the service's OpenAPI specification.
+type OpenAPIValidator struct {
+	// router resolves an incoming request to its documented operation.
+	router routers.Router
+
+	// validateResponses enables response validation; intended for debug deployments
+	// since it buffers every documented response body.
+	validateResponses bool
+
+	// logger provides structured logging for validation failures.
+	logger *zap.Logger
+}
+
+// NewOpenAPIValidator loads and validates the specification at specPath and builds
+// a validator for it.
+//
+// Steps:
+//  1. Load the OpenAPI document from disk
+//  2. Validate the document itself so a broken spec fails fast at startup
+//  3. Build a route matcher for the documented paths
+//  4. Return the configured validator
+func NewOpenAPIValidator(specPath string, validateResponses bool, logger *zap.Logger) (*OpenAPIValidator, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromFile(specPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load OpenAPI spec %s: %w", specPath, err)
+	}
+	if err := doc.Validate(loader.Context); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI spec %s: %w", specPath, err)
+	}
+
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build OpenAPI router: %w", err)
+	}
+
+	return &OpenAPIValidator{
+		router:            router,
+		validateResponses: validateResponses,
+		logger:            logger,
+	}, nil
+}
+
+// Middleware returns a Gin middleware enforcing the specification.
+//
+// Steps:
+//  1. Resolve the request to a documented operation; undocumented routes pass through
+//  2. Validate parameters and body, returning 400 with pointer paths on violations
+//  3. In debug mode, buffer the response and validate it after the handler runs
+//  4. Log and flag response violations without changing the response sent
+func (v *OpenAPIValidator) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route, pathParams, err := v.router.FindRoute(c.Request)
+		if err != nil {
+			// Routes such as /metrics, /ws and /location/replay are intentionally not
+			// documented.
+			c.Next()
+			return
+		}
+
+		requestInput := &openapi3filter.RequestValidationInput{
+			Request:    c.Request,
+			PathParams: pathParams,
+			Route:      route,
+			Options: &openapi3filter.Options{
+				MultiError:         true,
+				AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+			},
+		}
+		if err := openapi3filter.ValidateRequest(c.Request.Context(), requestInput); err != nil {
+			violations := collectViolations(err)
+			RequestLogger(c, v.logger).Warn("Request failed OpenAPI validation",
+				zap.String("path", c.Request.URL.Path),
+				zap.String("method", c.Request.Method),
+				zap.Int("violations", len(violations)),
+			)
+			AbortWithError(c, NewAPIError(http.StatusBadRequest, CodeValidationFailed, "request does not match API specification").WithDetails(violations))
+			return
+		}
+
+		if !v.validateResponses {
+			c.Next()
+			return
+		}
+
+		recorder := &bufferedResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		// Compressed history responses are validated as they were before
+		// compression.
+		body, err := decodeContentEncoding(recorder.Header().Get("Content-Encoding"), recorder.body.Bytes())
+		if err != nil {
+			body = recorder.body.Bytes()
+		}
+		responseInput := &openapi3filter.ResponseValidationInput{
+			RequestValidationInput: requestInput,
+			Status:                 recorder.Status(),
+			Header:                 recorder.Header(),
+			Body:                   io.NopCloser(bytes.NewReader(body)),
+			Options:                &openapi3filter.Options{MultiError: true},
+		}
+		if err := openapi3filter.ValidateResponse(c.Request.Context(), responseInput); err != nil {
+			violations := collectViolations(err)
+			RequestLogger(c, v.logger).Error("Response failed OpenAPI validation",
+				zap.String("path", c.Request.URL.Path),
+				zap.String("method", c.Request.Method),
+				zap.Int("status", recorder.Status()),
+				zap.Any("violations", violations),
+			)
+			recorder.Header().Set(responseValidationHeader, "failed")
+		}
+		recorder.flush()
+	}
+}
+
+// collectViolations flattens kin-openapi errors into pointer/message pairs.
+func collectViolations(err error) []SchemaViolation {
+	var multi openapi3.MultiError
+	if errors.As(err, &multi) {
+		var out []SchemaViolation
+		for _, e := range multi {
+			out = append(out, collectViolations(e)...)
+		}
+		return out
+	}
+
+	var schemaErr *openapi3.SchemaError
+	if errors.As(err, &schemaErr) {
+		return []SchemaViolation{{
+			Pointer: "/" + strings.Join(schemaErr.JSONPointer(), "/"),
+			Message: schemaErr.Reason,
+		}}
+	}
+
+	var reqErr *openapi3filter.RequestError
+	if errors.As(err, &reqErr) {
+		pointer := "/"
+		if reqErr.Parameter != nil {
+			pointer = fmt.Sprintf("%s:%s", reqErr.Parameter.In, reqErr.Parameter.Name)
+		}
+		if reqErr.Err != nil {
+			if nested := collectViolations(reqErr.Err); len(nested) > 0 && reqErr.Parameter == nil {
+				return nested
+			}
+		}
+		return []SchemaViolation{{Pointer: pointer, Message: reqErr.Error()}}
+	}
+
+	return []SchemaViolation{{Pointer: "/", Message: err.Error()}}
+}
+
+// bufferedResponseWriter holds the response body and status until validation
+// completes, then writes them to the underlying gin.ResponseWriter.
+type bufferedResponseWriter struct {
+	gin.ResponseWriter
+	body   bytes.Buffer
+	status int
+}
+
+// WriteHeader records the status code without sending it yet.
+func (w *bufferedResponseWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+// Write buffers the response body.
+func (w *bufferedResponseWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+// WriteString buffers the response body.
+func (w *bufferedResponseWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// Status returns the recorded status, defaulting to 200 like net/http.
+func (w *bufferedResponseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Written reports whether a status or body has been recorded.
+func (w *bufferedResponseWriter) Written() bool {
+	return w.status != 0 || w.body.Len() > 0
+}
+
+// flush sends the buffered status and body to the client.
+func (w *bufferedResponseWriter) flush() {
+	w.ResponseWriter.WriteHeader(w.Status())
+	_, _ = w.ResponseWriter.Write(w.body.Bytes())
+}
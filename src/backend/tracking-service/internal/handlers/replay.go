@@ -0,0 +1,241 @@
+package handlers
+
+import (
+	// json for replay frames and controls (go1.21)
+	"encoding/json"
+	// errors for matching replay sentinels (go1.21)
+	"errors"
+	// http for status codes (go1.21)
+	"net/http"
+	// strconv for the speed query parameter (go1.21)
+	"strconv"
+	// time for playback timers and the from query parameter (go1.21)
+	"time"
+
+	// gin for HTTP routing and handling (github.com/gin-gonic/gin v1.9.1)
+	"github.com/gin-gonic/gin"
+	// websocket for WebSocket connections (github.com/gorilla/websocket v1.5.0)
+	"github.com/gorilla/websocket"
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// models package for the Location struct
+	"src/backend/tracking-service/internal/models"
+
+	// services package for the WalkReplay struct
+	"src/backend/tracking-service/internal/services"
+)
+
+// replayFrame is one frame of a walk replay: a played point, or the end of
+// the walk. Position counts the points played so far out of Total.
+type replayFrame struct {
+	Type     string           `json:"type"`
+	Position int              `json:"position"`
+	Total    int              `json:"total"`
+	Speed    int              `json:"speed"`
+	Location *models.Location `json:"location,omitempty"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// replayControl is a message from the client steering playback: "seek" to
+// At, change "speed", "pause" or "resume".
+type replayControl struct {
+	Action string    `json:"action"`
+	At     time.Time `json:"at"`
+	Speed  int       `json:"speed"`
+}
+
+// HandleLocationReplay streams a completed walk's location history over a
+// WebSocket, pacing the points as they were recorded at the playback speed
+// given by the speed query parameter (1, 2 or 10, default 1), so owners can
+// replay a walk in the app. Playback starts at the from query parameter
+// (RFC 3339) when given, and the client scrubs through the walk by sending
+// controls: {"action":"seek","at":...}, {"action":"speed","speed":2},
+// {"action":"pause"} and {"action":"resume"}.
+//
+// Steps:
+//  1. Validate the session and the playback parameters
+//  2. Load the walk's track before upgrading, so failures get an HTTP status
+//  3. Upgrade HTTP to WebSocket
+//  4. Delegate to streamReplay
+func (lh *LocationHandler) HandleLocationReplay(c *gin.Context) {
+	logger := RequestLogger(c, lh.logger)
+	sessionID := c.Query("sessionID")
+	if err := lh.validateSession(logger, sessionID, c.GetHeader("Authorization")); err != nil {
+		logger.Error("Session validation failed for walk replay", zap.Error(err))
+		AbortWithError(c, NewAPIError(http.StatusUnauthorized, "", "invalid or missing session credentials"))
+		return
+	}
+
+	speed := 1
+	if raw := c.Query("speed"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			AbortWithError(c, NewAPIError(http.StatusBadRequest, "", "speed must be an integer"))
+			return
+		}
+		speed = parsed
+	}
+	var from time.Time
+	if raw := c.Query("from"); raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			AbortWithError(c, NewAPIError(http.StatusBadRequest, "", "from must be an RFC 3339 timestamp"))
+			return
+		}
+		from = parsed
+	}
+
+	api := services.WithRequestID(lh.trackingService, requestID(c))
+	replay, err := api.LoadWalkReplay(sessionID, speed)
+	if err != nil {
+		status := replayErrorStatus(err)
+		if status >= http.StatusInternalServerError {
+			logger.Error("Failed to load walk replay", zap.String("sessionID", sessionID), zap.Error(err))
+		}
+		AbortWithError(c, NewAPIError(status, "", err.Error()))
+		return
+	}
+	if !from.IsZero() {
+		replay.Seek(from)
+	}
+
+	conn, err := lh.wsUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		logger.Error("WebSocket upgrade failed for walk replay", zap.Error(err))
+		return
+	}
+
+	lh.recordAccess(api, sessionID, "GET /location/replay", c.Request.Header, false, requestID(c))
+
+	// Hold the stream guard's connection slot until the replay closes.
+	releaseLease := takeStreamLease(c)
+	go func() {
+		defer releaseLease()
+		lh.streamReplay(logger, conn, replay)
+	}()
+}
+
+// replayErrorStatus maps LoadWalkReplay failures to HTTP statuses.
+func replayErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidReplaySpeed):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrReplayDisabled), errors.Is(err, services.ErrSessionTrackNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrWalkNotCompleted):
+		return http.StatusConflict
+	default:
+		return repositoryErrorStatus(err)
+	}
+}
+
+// streamReplay plays replay over conn until the client disconnects. Reaching
+// the end of the walk sends an "end" frame and keeps the connection open, so
+// the client can still seek back.
+func (lh *LocationHandler) streamReplay(logger *zap.Logger, conn *websocket.Conn, replay *services.WalkReplay) {
+	defer conn.Close()
+	conn.SetReadLimit(maxMessageSize)
+
+	// The reader forwards controls until the connection closes; finished
+	// releases it if playback stops first.
+	controls := make(chan replayControl)
+	readerDone := make(chan struct{})
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		defer close(readerDone)
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var ctl replayControl
+			if err := json.Unmarshal(msg, &ctl); err != nil {
+				logger.Debug("Ignoring malformed replay control",
+					zap.String("sessionID", replay.SessionID()),
+					zap.Error(err),
+				)
+				continue
+			}
+			select {
+			case controls <- ctl:
+			case <-finished:
+				return
+			}
+		}
+	}()
+
+	write := func(frame replayFrame) bool {
+		frame.Position = replay.Position()
+		frame.Total = replay.Len()
+		frame.Speed = replay.Speed()
+		data, err := json.Marshal(frame)
+		if err == nil {
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			err = writeWSMessage(conn, websocket.TextMessage, data)
+		}
+		if err != nil {
+			logger.Info("Walk replay closed",
+				zap.String("sessionID", replay.SessionID()),
+				zap.Error(err),
+			)
+			return false
+		}
+		return true
+	}
+
+	paused, ended := false, false
+	for {
+		var (
+			timer *time.Timer
+			due   <-chan time.Time
+		)
+		if !paused && !ended {
+			if _, wait, ok := replay.Peek(); ok {
+				timer = time.NewTimer(wait)
+				due = timer.C
+			} else {
+				ended = true
+				if !write(replayFrame{Type: "end"}) {
+					return
+				}
+			}
+		}
+
+		select {
+		case <-due:
+			loc, _, _ := replay.Peek()
+			replay.Advance()
+			if !write(replayFrame{Type: "location", Location: &loc}) {
+				return
+			}
+		case ctl := <-controls:
+			if timer != nil {
+				timer.Stop()
+			}
+			switch ctl.Action {
+			case "seek":
+				replay.Seek(ctl.At)
+				ended = false
+			case "speed":
+				if err := replay.SetSpeed(ctl.Speed); err != nil && !write(replayFrame{Type: "error", Error: err.Error()}) {
+					return
+				}
+			case "pause":
+				paused = true
+			case "resume":
+				paused = false
+			default:
+				if !write(replayFrame{Type: "error", Error: "unknown replay action: " + ctl.Action}) {
+					return
+				}
+			}
+		case <-readerDone:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		}
+	}
+}
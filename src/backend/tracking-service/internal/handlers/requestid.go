@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	// gin for the request ID middleware (github.com/gin-gonic/gin v1.9.1)
+	"github.com/gin-gonic/gin"
+	// zap for request-scoped loggers (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// models package for generating request IDs
+	"src/backend/tracking-service/internal/models"
+	// services package for scoping service calls to a request
+	"src/backend/tracking-service/internal/services"
+)
+
+// maxRequestIDLength bounds the request IDs accepted from clients, so they
+// cannot bloat every log line of the request.
+const maxRequestIDLength = 128
+
+// RequestIDMiddleware gives every request an identifier: the client's
+// X-Request-ID when it sends a usable one, such as a load balancer's trace ID,
+// or a newly generated one otherwise. The identifier is set on the request for
+// the handlers and middleware after it, and on the response, so a client can
+// quote it to support and it can be found in every log line of the request. It
+// must be registered first.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = models.NewID()
+		}
+		c.Request.Header.Set(RequestIDHeader, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// validRequestID reports whether id, taken from a client, can be used as the
+// request's identifier: non-empty, bounded, and printable ASCII without
+// spaces, so it is safe to log and echo back.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID returns the identifier of the request, or "" when it has none.
+func (r Request) RequestID() string {
+	return r.HeaderValue(RequestIDHeader)
+}
+
+// RequestLogger returns logger with the requestID field of the request in c,
+// or logger itself when the request has no identifier.
+func RequestLogger(c *gin.Context, logger *zap.Logger) *zap.Logger {
+	return withRequestIDField(logger, requestID(c))
+}
+
+// withRequestIDField returns logger with a requestID field, or logger itself
+// when requestID is empty.
+func withRequestIDField(logger *zap.Logger, requestID string) *zap.Logger {
+	if requestID == "" {
+		return logger
+	}
+	return logger.With(zap.String("requestID", requestID))
+}
+
+// log returns the handler's logger with the requestID field of req.
+func (lh *LocationHandler) log(req Request) *zap.Logger {
+	return withRequestIDField(lh.logger, req.RequestID())
+}
+
+// api returns the tracking service with its calls scoped to req, so the
+// service middleware logs and traces them with the request's identifier.
+func (lh *LocationHandler) api(req Request) services.TrackingAPI {
+	return services.WithRequestID(lh.trackingService, req.RequestID())
+}
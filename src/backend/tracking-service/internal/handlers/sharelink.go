@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	// crypto/hmac and crypto/sha256 for signing share tokens (go1.21)
+	"crypto/hmac"
+	"crypto/sha256"
+	// crypto/rand for the per-instance secret when none is configured (go1.21)
+	"crypto/rand"
+	// base64 for URL-safe token encoding (go1.21)
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	// gin for the HTTP adapter (github.com/gin-gonic/gin v1.9.1)
+	"github.com/gin-gonic/gin"
+
+	// models package for the ShareLink struct
+	"src/backend/tracking-service/internal/models"
+)
+
+// Default lifetimes of share links, used until SetShareLinkSigner configures
+// them.
+const (
+	defaultShareLinkTTL = time.Hour
+	defaultShareLinkMax = 24 * time.Hour
+)
+
+// shareExpiredReason is the close reason sent to share-link subscribers when
+// their link expires.
+const shareExpiredReason = "share link expired"
+
+// Share token verification failures.
+var (
+	ErrInvalidShareToken = errors.New("invalid share token")
+	ErrShareTokenExpired = errors.New("share token has expired")
+)
+
+// ShareLinkSigner issues and verifies the tokens of live-share links. A token
+// grants a read-only subscription to one session's live stream until it
+// expires, and is signed so it can be neither forged nor moved to another
+// session. Tokens carry no node identity, so any instance sharing the secret
+// can honour them.
+type ShareLinkSigner struct {
+	secret     []byte
+	defaultTTL time.Duration
+	maxTTL     time.Duration
+}
+
+// NewShareLinkSigner creates a signer using the shared secret, issuing links
+// valid for defaultTTL unless requested otherwise, and never for longer than
+// maxTTL. Without a secret it draws a random one, so links are honoured only
+// by this instance until it restarts.
+func NewShareLinkSigner(secret []byte, defaultTTL, maxTTL time.Duration) *ShareLinkSigner {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic("share link secret: " + err.Error())
+		}
+	}
+	return &ShareLinkSigner{secret: append([]byte(nil), secret...), defaultTTL: defaultTTL, maxTTL: maxTTL}
+}
+
+// Issue returns the token sharing sessionID until expiresAt, formatted as
+// base64url(sessionID).unixExpiry.base64url(hmac).
+func (s *ShareLinkSigner) Issue(sessionID string, expiresAt time.Time) string {
+	encodedID := base64.RawURLEncoding.EncodeToString([]byte(sessionID))
+	expiry := strconv.FormatInt(expiresAt.Unix(), 10)
+	return encodedID + "." + expiry + "." + s.sign(encodedID, expiry)
+}
+
+// Parse verifies token for sessionID and returns when it expires. It returns
+// ErrInvalidShareToken for tokens that are malformed, forged or issued for
+// another session, and ErrShareTokenExpired once the token has expired.
+func (s *ShareLinkSigner) Parse(sessionID, token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, ErrInvalidShareToken
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(s.sign(parts[0], parts[1]))) {
+		return time.Time{}, ErrInvalidShareToken
+	}
+	decodedID, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || string(decodedID) != sessionID {
+		return time.Time{}, ErrInvalidShareToken
+	}
+	unix, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return time.Time{}, ErrInvalidShareToken
+	}
+	expiresAt := time.Unix(unix, 0).UTC()
+	if !time.Now().Before(expiresAt) {
+		return time.Time{}, ErrShareTokenExpired
+	}
+	return expiresAt, nil
+}
+
+// sign computes the URL-safe HMAC-SHA256 over the encoded session and expiry.
+// The "share" prefix keeps share tokens distinct from other tokens signed
+// with the same secret.
+func (s *ShareLinkSigner) sign(encodedID, expiry string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("share\n" + encodedID + "." + expiry))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SetShareLinkSigner signs the tokens of live-share links with signer, which
+// must share its secret with every instance behind the load balancer.
+func (lh *LocationHandler) SetShareLinkSigner(signer *ShareLinkSigner) {
+	lh.shareLinks = signer
+}
+
+// CreateShareLink issues a signed, expiring token with which an owner shares a
+// session's live tracking: a stream connecting with it as the share query
+// parameter subscribes read-only, without further authentication, until the
+// token expires. The optional ttl query parameter, a duration such as "2h",
+// sets the link's lifetime, up to the configured maximum.
+//
+// Steps:
+//  1. Validate the session credentials and the requested lifetime
+//  2. Check that the session is active
+//  3. Sign the token and return it with its expiry
+func (lh *LocationHandler) CreateShareLink(req Request) Response {
+	// 1. Validate the session credentials and the requested lifetime
+	sessionID := req.PathParam("sessionID")
+	if err := lh.validateSession(lh.log(req), sessionID, req.HeaderValue("Authorization")); err != nil {
+		return errorResponse(http.StatusUnauthorized, "session validation failed")
+	}
+	ttl := lh.shareLinks.defaultTTL
+	if ttlStr := req.QueryParam("ttl"); ttlStr != "" {
+		var err error
+		if ttl, err = time.ParseDuration(ttlStr); err != nil || ttl <= 0 {
+			return errorResponse(http.StatusBadRequest, "ttl must be a positive duration such as 30m or 2h")
+		}
+	}
+	if ttl > lh.shareLinks.maxTTL {
+		return errorResponse(http.StatusBadRequest, fmt.Sprintf("ttl must not exceed %s", lh.shareLinks.maxTTL))
+	}
+
+	// 2. Check that the session is active
+	if !lh.api(req).SessionActive(sessionID) {
+		return errorResponse(http.StatusNotFound, "session is not active")
+	}
+
+	// 3. Sign the token and return it with its expiry
+	expiresAt := time.Now().Add(ttl).UTC().Truncate(time.Second)
+	return jsonResponse(http.StatusCreated, models.ShareLink{
+		SessionID: sessionID,
+		Token:     lh.shareLinks.Issue(sessionID, expiresAt),
+		ExpiresAt: expiresAt,
+	})
+}
+
+// HandleCreateShareLink is the gin adapter for CreateShareLink.
+func (lh *LocationHandler) HandleCreateShareLink(c *gin.Context) {
+	serveGin(c, lh.CreateShareLink)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	// time for the drain deadline (go1.21)
+	"time"
+
+	// websocket for the restart close frame (github.com/gorilla/websocket v1.5.0)
+	"github.com/gorilla/websocket"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+)
+
+// drainCloseReason is the close reason sent to location streams drained for
+// shutdown, and the error of streams refused while draining.
+const drainCloseReason = "server restarting"
+
+// trackStream records conn as a live location stream, reporting false once
+// draining has begun, in which case the stream must not be served.
+func (lh *LocationHandler) trackStream(conn *websocket.Conn) bool {
+	lh.streamsMu.Lock()
+	defer lh.streamsMu.Unlock()
+	if lh.draining {
+		return false
+	}
+	lh.streams[conn] = struct{}{}
+	lh.streamsDone.Add(1)
+	return true
+}
+
+// untrackStream forgets a location stream whose handler returned.
+func (lh *LocationHandler) untrackStream(conn *websocket.Conn) {
+	lh.streamsMu.Lock()
+	delete(lh.streams, conn)
+	lh.streamsMu.Unlock()
+	lh.streamsDone.Done()
+}
+
+// Draining reports whether DrainStreams has begun, after which new location
+// streams are refused.
+func (lh *LocationHandler) Draining() bool {
+	lh.streamsMu.Lock()
+	defer lh.streamsMu.Unlock()
+	return lh.draining
+}
+
+// DrainStreams ends the live location streams for shutdown. It refuses new
+// streams, stops pushing location updates, and sends each stream a close frame
+// with the "server restarting" reason so clients reconnect elsewhere. Messages
+// clients send until they answer the close are still handled; streams still
+// open after timeout are closed. It returns how many streams were closed that
+// way.
+func (lh *LocationHandler) DrainStreams(timeout time.Duration) int {
+	lh.streamsMu.Lock()
+	lh.draining = true
+	conns := make([]*websocket.Conn, 0, len(lh.streams))
+	for conn := range lh.streams {
+		conns = append(conns, conn)
+	}
+	lh.streamsMu.Unlock()
+	if len(conns) == 0 {
+		return 0
+	}
+
+	lh.logger.Info("Draining location streams",
+		zap.Int("streams", len(conns)),
+		zap.Duration("timeout", timeout),
+	)
+	if lh.hub != nil {
+		lh.hub.detachAll()
+	}
+	deadline := time.Now().Add(timeout)
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseServiceRestart, drainCloseReason)
+	for _, conn := range conns {
+		if err := conn.WriteControl(websocket.CloseMessage, closeMsg, deadline); err != nil {
+			lh.logger.Debug("Failed to send restart close frame", zap.Error(err))
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		lh.streamsDone.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case <-done:
+		lh.logger.Info("Location streams drained")
+		return 0
+	case <-timer.C:
+	}
+
+	lh.streamsMu.Lock()
+	remaining := make([]*websocket.Conn, 0, len(lh.streams))
+	for conn := range lh.streams {
+		remaining = append(remaining, conn)
+	}
+	lh.streamsMu.Unlock()
+	lh.logger.Warn("Location stream drain timed out; closing remaining streams",
+		zap.Int("streams", len(remaining)),
+	)
+	for _, conn := range remaining {
+		_ = conn.Close()
+	}
+	return len(remaining)
+}
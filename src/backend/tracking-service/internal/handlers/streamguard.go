@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	// context for bounding ban store calls (go1.21)
+	"context"
+	// errors for admission rejection sentinels (go1.21)
+	"errors"
+	// fmt for Retry-After formatting (go1.21)
+	"fmt"
+	// math for rounding Retry-After up to whole seconds (go1.21)
+	"math"
+	// net for client IP and allowlist matching (go1.21)
+	"net"
+	// http for status codes and net/http integration (go1.21)
+	"net/http"
+	// sync for guarding per-IP counters (go1.21)
+	"sync"
+	// time for rate limiting and lookup timeouts (go1.21)
+	"time"
+
+	// gin for the HTTP middleware (github.com/gin-gonic/gin v1.9.1)
+	"github.com/gin-gonic/gin"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// prometheus for rejection and ban metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+
+	// rate for per-IP connection rate limiting (golang.org/x/time/rate v0.3.0)
+	"golang.org/x/time/rate"
+
+	// config for the abuse protection settings
+	"src/backend/tracking-service/internal/config"
+
+	// utils for the shared ban store
+	"src/backend/tracking-service/internal/utils"
+)
+
+// banLookupTimeout bounds ban store calls so an unreachable Redis cannot stall
+// connection attempts; lookups that time out admit the client.
+const banLookupTimeout = 250 * time.Millisecond
+
+// streamLeaseKey is the gin context key under which the middleware stores the
+// connection slot of an admitted stream request.
+const streamLeaseKey = "streamGuard.lease"
+
+// Admission rejections returned by StreamGuard.Admit.
+var (
+	ErrClientBanned      = errors.New("client is temporarily banned")
+	ErrTooManyStreams    = errors.New("too many concurrent streams from client")
+	ErrConnectionRateHit = errors.New("client is opening streams too quickly")
+)
+
+// StreamGuard protects streaming endpoints from brute-force and connection
+// floods: it caps concurrent streams and the connection rate per client IP, and
+// bans IPs that repeatedly fail authentication. Bans live in a BanStore so they
+// can be shared across replicas; concurrency and rate limits are per instance.
+type StreamGuard struct {
+	cfg       config.AbuseConfig
+	bans      utils.BanStore
+	allowlist []*net.IPNet
+	logger    *zap.Logger
+
+	mu       sync.Mutex
+	active   map[string]int
+	limiters map[string]*ipLimiter
+
+	rejections *prometheus.CounterVec
+	bansIssued prometheus.Counter
+}
+
+// ipLimiter is one client IP's connection rate limiter.
+type ipLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// streamLease is an admitted stream's connection slot. Release is idempotent.
+type streamLease struct {
+	once    sync.Once
+	release func()
+	owned   bool
+}
+
+// Release frees the connection slot.
+func (l *streamLease) Release() {
+	l.once.Do(l.release)
+}
+
+// NewStreamGuard creates a guard enforcing cfg with ban state kept in bans.
+// Metrics are registered on registry when it is non-nil.
+func NewStreamGuard(cfg config.AbuseConfig, bans utils.BanStore, logger *zap.Logger, registry *prometheus.Registry) (*StreamGuard, error) {
+	if bans == nil {
+		return nil, fmt.Errorf("stream guard requires a ban store")
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	allowlist := make([]*net.IPNet, 0, len(cfg.Allowlist))
+	for _, entry := range cfg.Allowlist {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			allowlist = append(allowlist, network)
+			continue
+		}
+		ip := net.ParseIP(entry)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid allowlist entry %q", entry)
+		}
+		bits := 8 * len(ip.To16())
+		if v4 := ip.To4(); v4 != nil {
+			ip, bits = v4, 32
+		}
+		allowlist = append(allowlist, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+
+	g := &StreamGuard{
+		cfg:       cfg,
+		bans:      bans,
+		allowlist: allowlist,
+		logger:    logger,
+		active:    make(map[string]int),
+		limiters:  make(map[string]*ipLimiter),
+		rejections: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tracking_stream_rejections_total",
+				Help: "Stream connection attempts rejected by abuse protection, by reason.",
+			},
+			[]string{"reason"},
+		),
+		bansIssued: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tracking_stream_bans_total",
+			Help: "Client IPs temporarily banned after repeated authentication failures.",
+		}),
+	}
+	if registry != nil {
+		registry.MustRegister(g.rejections, g.bansIssued)
+	}
+	return g, nil
+}
+
+// Admit decides whether ip may open a new stream. On success it returns a
+// release function that must be called when the stream ends; on rejection it
+// returns one of ErrClientBanned, ErrTooManyStreams or ErrConnectionRateHit and,
+// for bans, how long the ban remains.
+//
+// Steps:
+//  1. Admit allowlisted clients unconditionally
+//  2. Reject banned clients
+//  3. Reject clients exceeding the connection rate
+//  4. Reject clients at the concurrent stream cap, otherwise take a slot
+func (g *StreamGuard) Admit(ip string) (func(), time.Duration, error) {
+	if g.allowlisted(ip) {
+		return func() {}, 0, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), banLookupTimeout)
+	remaining, err := g.bans.BannedFor(ctx, ip)
+	cancel()
+	if err != nil {
+		g.logger.Warn("Ban lookup failed; admitting client", zap.String("ip", ip), zap.Error(err))
+	}
+	if remaining > 0 {
+		g.rejections.WithLabelValues("banned").Inc()
+		return nil, remaining, ErrClientBanned
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	g.pruneLimitersLocked(now)
+	lim, ok := g.limiters[ip]
+	if !ok {
+		lim = &ipLimiter{limiter: rate.NewLimiter(rate.Limit(float64(g.cfg.ConnRatePerMinute)/60.0), g.cfg.ConnBurst)}
+		g.limiters[ip] = lim
+	}
+	lim.lastSeen = now
+	if !lim.limiter.AllowN(now, 1) {
+		g.rejections.WithLabelValues("rate").Inc()
+		return nil, 0, ErrConnectionRateHit
+	}
+
+	if g.active[ip] >= g.cfg.MaxConnsPerIP {
+		g.rejections.WithLabelValues("concurrency").Inc()
+		return nil, 0, ErrTooManyStreams
+	}
+	g.active[ip]++
+
+	return func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if g.active[ip] <= 1 {
+			delete(g.active, ip)
+		} else {
+			g.active[ip]--
+		}
+	}, 0, nil
+}
+
+// RecordAuthFailure counts a failed authentication from ip and bans it once
+// AuthFailureLimit failures occur within AuthFailureWindow.
+func (g *StreamGuard) RecordAuthFailure(ip string) {
+	if g.allowlisted(ip) {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), banLookupTimeout)
+	defer cancel()
+	failures, err := g.bans.RecordFailure(ctx, ip, g.cfg.AuthFailureWindow)
+	if err != nil {
+		g.logger.Warn("Failed to record stream auth failure", zap.String("ip", ip), zap.Error(err))
+		return
+	}
+	if failures < int64(g.cfg.AuthFailureLimit) {
+		return
+	}
+	if err := g.bans.Ban(ctx, ip, g.cfg.BanDuration); err != nil {
+		g.logger.Warn("Failed to ban client", zap.String("ip", ip), zap.Error(err))
+		return
+	}
+	g.bansIssued.Inc()
+	g.logger.Warn("Client banned after repeated stream auth failures",
+		zap.String("ip", ip),
+		zap.Int64("failures", failures),
+		zap.Duration("duration", g.cfg.BanDuration),
+	)
+}
+
+// Middleware admits stream requests through the guard. The connection slot is
+// released when the handler returns unless the handler takes ownership of it
+// with takeStreamLease, as stream handlers do once the connection is upgraded.
+// Responses of 401 or 403 count as authentication failures.
+func (g *StreamGuard) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		release, retryAfter, err := g.Admit(ip)
+		if err != nil {
+			AbortWithError(c, NewAPIError(rejectStream(c.Writer, err, retryAfter), "", err.Error()))
+			return
+		}
+
+		lease := &streamLease{release: release}
+		c.Set(streamLeaseKey, lease)
+		c.Next()
+
+		if status := c.Writer.Status(); status == http.StatusUnauthorized || status == http.StatusForbidden {
+			g.RecordAuthFailure(ip)
+		}
+		if !lease.owned {
+			lease.Release()
+		}
+	}
+}
+
+// allowlisted reports whether ip matches an allowlist entry.
+func (g *StreamGuard) allowlisted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range g.allowlist {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneLimitersLocked drops rate limiters idle long enough to have refilled.
+// g.mu must be held.
+func (g *StreamGuard) pruneLimitersLocked(now time.Time) {
+	idle := time.Duration(float64(g.cfg.ConnBurst)/float64(g.cfg.ConnRatePerMinute)*float64(time.Minute)) + time.Minute
+	for ip, lim := range g.limiters {
+		if now.Sub(lim.lastSeen) > idle {
+			delete(g.limiters, ip)
+		}
+	}
+}
+
+// takeStreamLease transfers the connection slot admitted by the middleware to
+// the caller, which must call the returned function when the stream closes.
+// Without a guard it returns a no-op.
+func takeStreamLease(c *gin.Context) func() {
+	val, ok := c.Get(streamLeaseKey)
+	if !ok {
+		return func() {}
+	}
+	lease, ok := val.(*streamLease)
+	if !ok {
+		return func() {}
+	}
+	lease.owned = true
+	return lease.Release
+}
+
+// rejectStream sets the Retry-After header for an admission rejection and
+// returns the status to respond with: 403 for bans, 429 for limits.
+func rejectStream(w http.ResponseWriter, err error, retryAfter time.Duration) int {
+	if errors.Is(err, ErrClientBanned) {
+		w.Header().Set("Retry-After", fmt.Sprintf("%d", int64(math.Ceil(retryAfter.Seconds()))))
+		return http.StatusForbidden
+	}
+	return http.StatusTooManyRequests
+}
+
+// remoteIP extracts the client IP from a plain net/http request.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
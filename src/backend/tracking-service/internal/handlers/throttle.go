@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	// errors for negotiation errors (go1.21)
+	"errors"
+	// fmt for negotiation errors (go1.21)
+	"fmt"
+	// strconv for ping timestamps and the interval query parameter (go1.21)
+	"strconv"
+	// sync for guarding throttle state (go1.21)
+	"sync"
+	// time for delivery intervals and RTT measurement (go1.21)
+	"time"
+
+	// config package for the throttling settings
+	"src/backend/tracking-service/internal/config"
+)
+
+// MaxFrameIntervalParam is the connect query parameter, and the "throttle"
+// stream frame's field, by which a subscriber asks for at most one location
+// frame per the given number of milliseconds.
+const MaxFrameIntervalParam = "maxFrameIntervalMs"
+
+// rttSmoothing is the weight of each new sample in the smoothed RTT, as in
+// TCP's SRTT estimator.
+const rttSmoothing = 0.125
+
+// subscriberThrottle limits the frames written to one subscriber to one per
+// interval, for clients on slow links that cannot keep up with every frame.
+// Frames offered within an interval are coalesced, the most recent winning,
+// and the survivor is written when the interval elapses. The interval is the
+// larger of the one the subscriber negotiated and the one applied
+// automatically while its measured RTT is high; zero writes every frame.
+// write is called without the throttle's lock held, so it may stop the
+// throttle.
+//
+// Coalesced frames are still sequenced and buffered by resumable streams, so
+// a subscriber sees gaps in Seq but can resume from any frame it received.
+type subscriberThrottle struct {
+	policy config.StreamConfig
+	write  func([]byte)
+
+	mu        sync.Mutex
+	requested time.Duration
+	auto      time.Duration
+	srtt      time.Duration
+	lastSent  time.Time
+	pending   []byte
+	timer     *time.Timer
+	coalesced uint64
+	stopped   bool
+}
+
+// newSubscriberThrottle creates a throttle writing frames through write,
+// starting at the requested interval.
+func newSubscriberThrottle(policy config.StreamConfig, requested time.Duration, write func([]byte)) *subscriberThrottle {
+	t := &subscriberThrottle{policy: policy, write: write}
+	t.requested = t.clamp(requested)
+	return t
+}
+
+// clamp bounds a negotiated interval by the configured maximum.
+func (t *subscriberThrottle) clamp(d time.Duration) time.Duration {
+	if t.policy.ThrottleMaxInterval > 0 && d > t.policy.ThrottleMaxInterval {
+		return t.policy.ThrottleMaxInterval
+	}
+	return d
+}
+
+// interval returns the effective delivery interval. The caller must hold mu.
+func (t *subscriberThrottle) interval() time.Duration {
+	if t.auto > t.requested {
+		return t.auto
+	}
+	return t.requested
+}
+
+// offer delivers frame now if the subscriber's interval has elapsed since the
+// last write, and otherwise holds it, replacing any frame already held, until
+// the interval ends.
+func (t *subscriberThrottle) offer(frame []byte) {
+	t.mu.Lock()
+	if t.stopped {
+		t.mu.Unlock()
+		return
+	}
+
+	now := time.Now()
+	wait := t.interval() - now.Sub(t.lastSent)
+	if t.timer == nil && wait <= 0 {
+		t.lastSent = now
+		t.mu.Unlock()
+		t.write(frame)
+		return
+	}
+	if t.pending != nil {
+		t.coalesced++
+	}
+	t.pending = frame
+	if t.timer == nil {
+		t.timer = time.AfterFunc(wait, t.flush)
+	}
+	t.mu.Unlock()
+}
+
+// flush writes the frame held at the end of an interval.
+func (t *subscriberThrottle) flush() {
+	t.mu.Lock()
+	t.timer = nil
+	if t.stopped || t.pending == nil {
+		t.mu.Unlock()
+		return
+	}
+	frame := t.pending
+	t.pending = nil
+	t.lastSent = time.Now()
+	t.mu.Unlock()
+	t.write(frame)
+}
+
+// setRequested applies the interval negotiated by the subscriber, bounded by
+// the configured maximum. A held frame keeps its scheduled delivery.
+func (t *subscriberThrottle) setRequested(d time.Duration) {
+	t.mu.Lock()
+	t.requested = t.clamp(d)
+	t.mu.Unlock()
+}
+
+// observeRTT folds a round-trip sample into the smoothed RTT and engages the
+// automatic interval while it exceeds ThrottleAutoRTT, releasing it once the
+// RTT falls below half the threshold.
+func (t *subscriberThrottle) observeRTT(rtt time.Duration) {
+	if rtt <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.srtt == 0 {
+		t.srtt = rtt
+	} else {
+		t.srtt += time.Duration(rttSmoothing * float64(rtt-t.srtt))
+	}
+
+	threshold := t.policy.ThrottleAutoRTT
+	switch {
+	case threshold <= 0:
+		t.auto = 0
+	case t.srtt > threshold:
+		t.auto = t.policy.ThrottleAutoInterval
+	case t.srtt < threshold/2:
+		t.auto = 0
+	}
+}
+
+// stop discards any held frame and ends delivery.
+func (t *subscriberThrottle) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stopped = true
+	t.pending = nil
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+}
+
+// parseMaxFrameInterval parses a negotiated interval in milliseconds; empty
+// means no throttling.
+func parseMaxFrameInterval(raw string) (time.Duration, error) {
+	if raw == "" {
+		return 0, nil
+	}
+	ms, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be an integer number of milliseconds", MaxFrameIntervalParam)
+	}
+	if ms < 0 {
+		return 0, errors.New(MaxFrameIntervalParam + " cannot be negative")
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// pingPayload stamps a ping with its send time so the pong measures the RTT.
+func pingPayload(now time.Time) []byte {
+	return []byte(strconv.FormatInt(now.UnixNano(), 10))
+}
+
+// pongRTT returns the round trip of the ping whose payload the pong echoes.
+func pongRTT(appData string, now time.Time) (time.Duration, bool) {
+	sent, err := strconv.ParseInt(appData, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return now.Sub(time.Unix(0, sent)), true
+}
@@ -17,6 +17,7 @@ import (
 
 	// Internal dependencies.
 	// Adjust the import paths/names according to your project structure.
+	md "src/backend/tracking-service/internal/models"   // For Location
 	st "src/backend/tracking-service/internal/services" // For *TrackingService
 	um "src/backend/tracking-service/internal/utils"    // For *MQTTClient
 )
@@ -44,7 +45,7 @@ var (
 	maxConnections = 10000
 
 	// messageBufferSize specifies the buffer length when reading messages.
-	// This can be used for channels or pooled buffers.
+	// It also bounds the messages queued for each connection's write pump.
 	messageBufferSize = 256
 )
 
@@ -56,10 +57,9 @@ var (
 // dog walk tracking, featuring connection pooling, enhanced security, and
 // comprehensive monitoring.
 type WebSocketHandler struct {
-	// connections maintains all active connection references in a thread-safe
-	// manner. The map keys might be connection IDs or session IDs, depending
-	// on how we choose to store them. Each map value can hold a struct containing
-	// metadata about the connection, the actual *websocket.Conn, or both.
+	// connections maintains all active connections in a thread-safe manner,
+	// keyed by session ID. Each value is a *wsConn, whose send queue is the
+	// only way to write to the connection once its write pump is running.
 	connections *sync.Map
 
 	// trackingService provides access to session management and location
@@ -84,6 +84,32 @@ type WebSocketHandler struct {
 	// cancel is the cancellation function associated with ctx, used to trigger
 	// a graceful shutdown or termination across connections.
 	cancel context.CancelFunc
+
+	// guard enforces per-IP stream limits and auth-failure bans. Nil disables it.
+	guard *StreamGuard
+
+	// capture records the inbound messages of selected sessions for replay
+	// through Replay. Nil disables recording.
+	capture *StreamCapture
+
+	// lifecycle counts connections through the registry. Nil disables the
+	// metrics.
+	lifecycle *wsLifecycleMetrics
+
+	// diagnostics captures a bundle when a connection's handling panics and
+	// traces processed messages. Nil only logs panics.
+	diagnostics *um.DiagnosticsRecorder
+}
+
+// uploadAckFrame acknowledges sequenced uploads: every upload up to and
+// including AckedSeq has been committed to the database. Retransmit lists the
+// point sequence ranges the device should send again.
+type uploadAckFrame struct {
+	Type       string        `json:"type"`
+	AckedSeq   uint64        `json:"ackedSeq"`
+	Duplicate  bool          `json:"duplicate,omitempty"`
+	Terminal   bool          `json:"terminal,omitempty"`
+	Retransmit []md.SeqRange `json:"retransmit,omitempty"`
 }
 
 // ---------------------------------------------------------------------------
@@ -102,6 +128,7 @@ type WebSocketHandler struct {
 //   5. Initialize message pool for efficiency
 //   6. Configure connection limits (global variable usage or logs)
 //   7. Initialize shutdown context
+//   8. Register for upload acks from the tracking service
 func NewWebSocketHandler(
 	trackingService *st.TrackingService,
 	mqttClient *um.MQTTClient,
@@ -116,6 +143,12 @@ func NewWebSocketHandler(
 	upg := websocket.Upgrader{
 		ReadBufferSize:  int(messageBufferSize),
 		WriteBufferSize: int(messageBufferSize),
+		// Binary encodings are listed first so trackers offering JSON too get
+		// the compact one.
+		Subprotocols: []string{SubprotocolCBOR, SubprotocolProtobuf, SubprotocolJSON},
+		// Negotiate permessage-deflate; only messages of at least
+		// wsCompressionThreshold bytes are sent compressed.
+		EnableCompression: true,
 		// Example origin check. Adjust or remove according to security requirements.
 		CheckOrigin: func(r *http.Request) bool {
 			// Here we accept all origins for demonstration; refine in production.
@@ -145,7 +178,7 @@ func NewWebSocketHandler(
 	handlerCtx, cancelFn := context.WithCancel(ctx)
 
 	// Construct the WebSocketHandler
-	return &WebSocketHandler{
+	wh := &WebSocketHandler{
 		connections:     connMap,
 		trackingService: trackingService,
 		mqttClient:      mqttClient,
@@ -154,6 +187,44 @@ func NewWebSocketHandler(
 		ctx:             handlerCtx,
 		cancel:          cancelFn,
 	}
+
+	// 8. Push upload acks to connected devices as their batches commit
+	if trackingService != nil {
+		trackingService.OnUploadAck(func(ack st.UploadAck) {
+			wh.writeUploadAck(ack, sendDrop)
+		})
+	}
+	return wh
+}
+
+// ---------------------------------------------------------------------------
+// EnableStreamGuard
+// ---------------------------------------------------------------------------
+//
+// EnableStreamGuard applies guard's per-IP connection limits to new connections.
+func (wh *WebSocketHandler) EnableStreamGuard(guard *StreamGuard) {
+	wh.guard = guard
+}
+
+// ---------------------------------------------------------------------------
+// EnableCapture
+// ---------------------------------------------------------------------------
+//
+// EnableCapture records the inbound messages of the sessions selected by
+// capture, producing files that ReplayCapture can feed back through a location
+// stream.
+func (wh *WebSocketHandler) EnableCapture(capture *StreamCapture) {
+	wh.capture = capture
+}
+
+// ---------------------------------------------------------------------------
+// EnableDiagnostics
+// ---------------------------------------------------------------------------
+//
+// EnableDiagnostics captures a diagnostics bundle, with the redacted message
+// being processed, whenever handling a connection panics.
+func (wh *WebSocketHandler) EnableDiagnostics(diagnostics *um.DiagnosticsRecorder) {
+	wh.diagnostics = diagnostics
 }
 
 // ---------------------------------------------------------------------------
@@ -169,8 +240,8 @@ func NewWebSocketHandler(
 //   2. Check connection limits
 //   3. Upgrade HTTP connection to WebSocket with security checks
 //   4. Initialize connection metrics (placeholder or actual instrumentation)
-//   5. Register connection in pool
-//   6. Start read/write pumps with monitoring
+//   5. Prepare the connection's registration
+//   6. Start read/write pumps, then register the connection in the pool
 //   7. Set up connection cleanup handlers
 //   8. Configure automatic recovery
 //   9. Start metrics collection (placeholder or instrumentation)
@@ -180,9 +251,25 @@ func (wh *WebSocketHandler) HandleConnection(w http.ResponseWriter, r *http.Requ
 	//    Return an error or http.Error if invalid.
 	//    For demonstration, we simply pass.
 
+	// 1a. Admit the client through the stream guard: banned, flooding or
+	//     over-connected IPs are rejected before any other work.
+	clientIP := remoteIP(r)
+	releaseSlot := func() {}
+	if wh.guard != nil {
+		release, retryAfter, admitErr := wh.guard.Admit(clientIP)
+		if admitErr != nil {
+			http.Error(w, admitErr.Error(), rejectStream(w, admitErr, retryAfter))
+			return admitErr
+		}
+		releaseSlot = release
+	}
+
+	sessionID := r.URL.Query().Get("sessionID")
+
 	// 2. Check connection limits
 	currConnCount := wh.countConnections()
 	if currConnCount >= maxConnections {
+		releaseSlot()
 		http.Error(w, "Maximum connection limit reached", http.StatusServiceUnavailable)
 		return errors.New("max connection limit reached")
 	}
@@ -190,27 +277,30 @@ func (wh *WebSocketHandler) HandleConnection(w http.ResponseWriter, r *http.Requ
 	// 3. Upgrade HTTP to WebSocket
 	conn, err := wh.upgrader.Upgrade(w, r, nil)
 	if err != nil {
+		releaseSlot()
 		return fmt.Errorf("failed to upgrade to websocket: %w", err)
 	}
+	_ = conn.SetCompressionLevel(websocket.CompressionBestSpeed)
 
 	// 4. Initialize connection metrics (placeholder or actual instrumentation).
 	//    For demonstration, we might log or increment a counter.
 	//    You could use a Prometheus counter here.
 
-	// 5. Register connection in pool. We'll store based on a unique ID (e.g., short GUID).
+	// 5. Prepare the connection's registration, which owns its guard slot
+	//    from here on. We'll store based on a unique ID (e.g., short GUID).
 	//    If the client provides a sessionID in a query param, we might use that.
-	sessionID := r.URL.Query().Get("sessionID")
 	if sessionID == "" {
 		// For demonstration, if no sessionID is provided, we generate one.
 		sessionID = fmt.Sprintf("ws-%d", time.Now().UnixNano())
 	}
-	wh.connections.Store(sessionID, conn)
+	wc := newWSConn(conn)
+	reg := wh.newRegistration(sessionID, wc, releaseSlot)
 
 	// Optionally, we can attempt to start or subscribe to MQTT here if needed.
 	// For demonstration, we call the trackingService's StartSession (if it exists)
 	// and subscribe via MQTT client. Adjust arguments as required.
 	if wh.trackingService != nil {
-		_ = wh.trackingService.StartSession(sessionID, "walkerID_placeholder", "dogID_placeholder")
+		_, _ = wh.trackingService.StartSession(sessionID, "walkerID_placeholder", "dogID_placeholder")
 	}
 	if wh.mqttClient != nil {
 		_ = wh.mqttClient.SubscribeToSession(nil) // Example usage if required
@@ -218,9 +308,12 @@ func (wh *WebSocketHandler) HandleConnection(w http.ResponseWriter, r *http.Requ
 	}
 
 	// 6. Start read/write pumps
-	//    We'll run them as goroutines to handle asynchronous I/O.
-	go wh.writePump(conn, sessionID)
-	go wh.readPump(conn, sessionID)
+	//    We'll run them as goroutines to handle asynchronous I/O. The connection
+	//    is registered only once both are running, and the first of them to
+	//    exit deregisters it.
+	if !reg.start() {
+		return errors.New("failed to start websocket pumps")
+	}
 
 	// 7. Setup connection cleanup handlers
 	//    e.g., close the connection if the context is canceled or if an internal error occurs.
@@ -253,24 +346,19 @@ func (wh *WebSocketHandler) HandleConnection(w http.ResponseWriter, r *http.Requ
 //   7. Process messages with retries
 //   8. Handle connection closure gracefully
 //   9. Clean up resources
-func (wh *WebSocketHandler) readPump(conn *websocket.Conn, sessionID string) {
+func (wh *WebSocketHandler) readPump(reg *wsRegistration) {
+	wc, sessionID := reg.wc, reg.sessionID
+	conn := wc.conn
 	defer func() {
-		// 9. Clean up resources on routine exit
-		conn.Close()
-		wh.connections.Delete(sessionID)
-
-		// Attempt to end the session if needed
-		if wh.trackingService != nil {
-			_ = wh.trackingService.EndSession(sessionID)
-		}
+		// 9. Clean up resources on routine exit, stopping the write pump and
+		//    ending the session
+		reg.pumpExited()
+		reg.deregister(deregisterClosed)
 	}()
 
-	defer func() {
-		// 4. Error recovery
-		if r := recover(); r != nil {
-			// Log and handle
-		}
-	}()
+	// 4. Error recovery: diag follows the message being processed
+	diag := &um.DiagnosticContext{SessionID: sessionID}
+	defer wh.diagnostics.Recover("websocket", diag)
 
 	// 1. Set read deadline
 	conn.SetReadDeadline(time.Now().Add(pongWait))
@@ -284,6 +372,12 @@ func (wh *WebSocketHandler) readPump(conn *websocket.Conn, sessionID string) {
 	// 2. Configure message size limits to prevent DOS.
 	conn.SetReadLimit(maxMessageSize)
 
+	// Record the session's inbound stream when it is selected for capture.
+	recorder, _ := wh.capture.Start(sessionID)
+	if recorder != nil {
+		defer recorder.Close()
+	}
+
 	for {
 		// 5. Read messages in a loop
 		messageType, msg, err := conn.ReadMessage()
@@ -291,12 +385,22 @@ func (wh *WebSocketHandler) readPump(conn *websocket.Conn, sessionID string) {
 			// 8. Connection closure or error
 			break
 		}
-
 		if messageType != websocket.TextMessage && messageType != websocket.BinaryMessage {
 			// If we want to ignore non-text/binary, we can continue
 			continue
 		}
 
+		// Transcode CBOR and protobuf messages to JSON. Captures record the
+		// JSON, so they replay through a location stream whatever the client spoke.
+		msg, err = wc.decode(messageType, msg)
+		if err != nil {
+			wh.diagnostics.Trace("websocket", sessionID, "message rejected: "+err.Error())
+			continue
+		}
+		if recorder != nil && recorder.Record(messageType, msg) != nil {
+			recorder = nil
+		}
+
 		// 3. & 6. Validate message format in a minimal sense
 		if len(msg) == 0 {
 			// Skip or handle empty message
@@ -304,8 +408,10 @@ func (wh *WebSocketHandler) readPump(conn *websocket.Conn, sessionID string) {
 		}
 
 		// 7. Process messages (with potential retry)
+		diag.Payload = msg
 		procErr := wh.processMessage(sessionID, msg)
 		if procErr != nil {
+			wh.diagnostics.Trace("websocket", sessionID, "message rejected: "+procErr.Error())
 			// We can log errors or decide to break if they are critical
 			// For demonstration, we simply continue
 			continue
@@ -317,8 +423,9 @@ func (wh *WebSocketHandler) readPump(conn *websocket.Conn, sessionID string) {
 // writePump
 // ---------------------------------------------------------------------------
 //
-// writePump writes messages to the WebSocket connection with reliability
-// mechanisms like heartbeats, delivery guarantees, and graceful shutdown.
+// writePump is the connection's only writer: it writes the messages queued on
+// its send queue and keepalive pings, with reliability mechanisms like write
+// deadlines and graceful shutdown.
 //
 // Steps:
 //   1. Set up ticker for ping messages
@@ -330,22 +437,33 @@ func (wh *WebSocketHandler) readPump(conn *websocket.Conn, sessionID string) {
 //   7. Handle write timeouts
 //   8. Manage connection health
 //   9. Clean up on shutdown
-func (wh *WebSocketHandler) writePump(conn *websocket.Conn, sessionID string) {
+func (wh *WebSocketHandler) writePump(reg *wsRegistration) {
+	wc := reg.wc
 	ticker := time.NewTicker(pingPeriod)
 	defer func() {
 		ticker.Stop()
-		conn.Close()
+		reg.pumpExited()
+		reg.deregister(deregisterClosed)
 	}()
+	defer wh.diagnostics.Recover("websocket", &um.DiagnosticContext{SessionID: reg.sessionID})
 
 	for {
 		select {
 		case <-wh.ctx.Done():
 			// 9. Graceful shutdown triggered from external cancel function
 			return
+		case <-wc.done:
+			// 9. The read pump closed the connection
+			return
+		case msg := <-wc.send:
+			// 4. & 7. Outgoing messages, each with its own write deadline
+			if err := wc.write(msg); err != nil {
+				return
+			}
 		case <-ticker.C:
 			// 1. Ping messages
-			conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			wc.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := wc.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				// 8. Connection health check fails if we cannot write
 				return
 			}
@@ -374,8 +492,10 @@ func (wh *WebSocketHandler) processMessage(sessionID string, message []byte) err
 	// 1. Validate message schema
 	//    For demonstration, assume a JSON with a field "action"
 	var payload struct {
-		Action string `json:"action"`
-		Data   string `json:"data"`
+		Action    string         `json:"action"`
+		Data      string         `json:"data"`
+		UploadSeq uint64         `json:"uploadSeq"`
+		Locations []*md.Location `json:"locations"`
 	}
 	if err := json.Unmarshal(message, &payload); err != nil {
 		return fmt.Errorf("invalid message format: %w", err)
@@ -384,6 +504,15 @@ func (wh *WebSocketHandler) processMessage(sessionID string, message []byte) err
 	// 2. Parse message type (e.g., action)
 	action := payload.Action
 
+	// Location messages for an ended session get a terminal ack telling the
+	// device to stop sending, before any further processing.
+	if (action == "locationUpdate" || action == "upload") && wh.trackingService != nil {
+		if err := wh.trackingService.CheckIngress(sessionID, st.IngressWebSocket); err != nil {
+			wh.writeUploadAck(st.TerminalAck(sessionID), sendBackpressure)
+			return nil
+		}
+	}
+
 	// 3. Authenticate request (placeholder). Could parse tokens, etc.
 
 	// 4. Rate limit (placeholder). Could integrate with a token bucket or call out to an external service.
@@ -391,9 +520,13 @@ func (wh *WebSocketHandler) processMessage(sessionID string, message []byte) err
 	// 5. Route to appropriate handler based on action
 	switch action {
 	case "locationUpdate":
-		// We might parse location data from payload.Data and call trackingService.ProcessLocationUpdate
+		// payload.Data carries the location as a JSON document.
 		if wh.trackingService != nil {
-			if err := wh.trackingService.ProcessLocationUpdate(sessionID, payload.Data); err != nil {
+			var loc md.Location
+			if err := json.Unmarshal([]byte(payload.Data), &loc); err != nil {
+				return fmt.Errorf("invalid location data: %w", err)
+			}
+			if _, err := wh.trackingService.ProcessLocationUpdate(sessionID, loc); err != nil {
 				return fmt.Errorf("failed to process location update: %w", err)
 			}
 		}
@@ -401,9 +534,26 @@ func (wh *WebSocketHandler) processMessage(sessionID string, message []byte) err
 		// Optionally, use the MQTT client to publish location updates for real-time distribution
 		if wh.mqttClient != nil {
 			// Example usage - parse location if needed
-			// wh.mqttClient.PublishLocation(sessionID, &models.Location{})
+			// wh.mqttClient.PublishLocation(sessionID, &models.Location{}, nil)
 		}
 
+	case "upload":
+		// Sequenced batch upload: the ack frame carries the cumulative acked
+		// sequence, and later acks are pushed as coalesced writes commit.
+		if wh.trackingService == nil {
+			return errors.New("tracking service unavailable")
+		}
+		ack, _, err := wh.trackingService.ProcessSequencedUpload(st.SequencedUpload{
+			SessionID: sessionID,
+			UploadSeq: payload.UploadSeq,
+			Locations: payload.Locations,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to process upload: %w", err)
+		}
+		wh.writeUploadAck(ack, sendBackpressure)
+		return nil
+
 	case "someOtherAction":
 		// Placeholder for other types of messages
 	default:
@@ -419,8 +569,9 @@ func (wh *WebSocketHandler) processMessage(sessionID string, message []byte) err
 		"session": sessionID,
 	}
 	ackJSON, _ := json.Marshal(ackMsg)
-	// Best-effort attempt to write acknowledgment:
-	wh.writeAck(sessionID, ackJSON)
+	// Queue the acknowledgment, holding further reads while the client is not
+	// draining its replies:
+	wh.writeAck(sessionID, ackJSON, sendBackpressure)
 
 	// 8. Update metrics (placeholder). Could increment a Prometheus counter for processed messages.
 
@@ -429,19 +580,28 @@ func (wh *WebSocketHandler) processMessage(sessionID string, message []byte) err
 }
 
 // writeAck attempts to find the existing WebSocket connection from wh.connections
-// by sessionID and writes a text message with the provided payload. This is a
-// convenience function used by processMessage for sending acknowledgments.
-func (wh *WebSocketHandler) writeAck(sessionID string, payload []byte) {
+// by sessionID and queues a text message with the provided payload for its
+// write pump, applying policy when the connection's send queue is full. This
+// is a convenience function used by processMessage for sending acknowledgments.
+func (wh *WebSocketHandler) writeAck(sessionID string, payload []byte, policy sendPolicy) {
 	val, ok := wh.connections.Load(sessionID)
 	if !ok {
 		return
 	}
-	conn, castOK := val.(*websocket.Conn)
+	wc, castOK := val.(*wsConn)
 	if !castOK {
 		return
 	}
-	_ = conn.SetWriteDeadline(time.Now().Add(writeWait))
-	_ = conn.WriteMessage(websocket.TextMessage, payload)
+	wc.enqueue(payload, policy)
+}
+
+// writeUploadAck sends an upload ack frame to the session's live connection.
+func (wh *WebSocketHandler) writeUploadAck(ack st.UploadAck, policy sendPolicy) {
+	frameJSON, err := json.Marshal(uploadAckFrame{Type: "ack", AckedSeq: ack.AckedSeq, Duplicate: ack.Duplicate, Terminal: ack.Terminal, Retransmit: ack.Retransmit})
+	if err != nil {
+		return
+	}
+	wh.writeAck(ack.SessionID, frameJSON, policy)
 }
 
 // ---------------------------------------------------------------------------
@@ -456,8 +616,10 @@ func (wh *WebSocketHandler) Shutdown() error {
 
 	// Iterate over all active connections, close them, and remove from map.
 	wh.connections.Range(func(key, value interface{}) bool {
-		if c, ok := value.(*websocket.Conn); ok {
-			_ = c.Close()
+		if wc, ok := value.(*wsConn); ok && wc.registration != nil {
+			wc.registration.deregister(deregisterShutdown)
+		} else if ok {
+			wc.close()
 		}
 		wh.connections.Delete(key)
 		return true
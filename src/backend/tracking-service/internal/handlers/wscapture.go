@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	// bufio for reading captures line by line (go1.21)
+	"bufio"
+	// context for cancelling replays (go1.21)
+	"context"
+	// json for the capture file format (go1.21)
+	"encoding/json"
+	// errors for capture format errors (go1.21)
+	"errors"
+	// fmt for wrapping errors and naming capture files (go1.21)
+	"fmt"
+	// io for capture readers and writers (go1.21)
+	"io"
+	// os for creating capture files (go1.21)
+	"os"
+	// filepath for capture file paths (go1.21)
+	"path/filepath"
+	// strings for sanitizing session IDs in file names (go1.21)
+	"strings"
+	// sync for serializing writes to a capture (go1.21)
+	"sync"
+	// time for message offsets and replay pacing (go1.21)
+	"time"
+
+	// websocket for message type constants (github.com/gorilla/websocket v1.5.0)
+	"github.com/gorilla/websocket"
+	// zap for the replayed stream's logger (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// services package for the TrackingAPI a capture is replayed on
+	"src/backend/tracking-service/internal/services"
+)
+
+// CaptureFormatVersion is the version written in every capture header.
+const CaptureFormatVersion = 1
+
+// CaptureAllSessions selects every session for capture.
+const CaptureAllSessions = "*"
+
+// captureFileExt is the extension of capture files.
+const captureFileExt = ".wscap"
+
+// maxCaptureLineSize bounds a single line of a capture file. Messages are
+// capped at maxMessageSize before recording, so base64 and framing overhead
+// stay well below it.
+const maxCaptureLineSize = 1 << 20
+
+// ErrInvalidCapture is returned when a capture file is malformed or written in
+// an unsupported version.
+var ErrInvalidCapture = errors.New("invalid WebSocket capture")
+
+// CaptureHeader is the first line of a capture file.
+type CaptureHeader struct {
+	Version   int       `json:"version"`
+	SessionID string    `json:"sessionId"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// CaptureRecord is one inbound message of a capture. Offset is the time since
+// the connection was captured, so a replay can reproduce the original timing.
+type CaptureRecord struct {
+	Offset      time.Duration `json:"offsetNs"`
+	MessageType int           `json:"messageType"`
+	Payload     []byte        `json:"payload"`
+}
+
+// Capture is a recorded WebSocket session: its header and every inbound
+// message in the order received.
+type Capture struct {
+	Header  CaptureHeader
+	Records []CaptureRecord
+}
+
+// ReadCapture parses a capture file written by a SessionRecorder. A truncated
+// final line, as left by a crash mid-write, is ignored.
+func ReadCapture(r io.Reader) (*Capture, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxCaptureLineSize)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read capture header: %w", err)
+		}
+		return nil, fmt.Errorf("%w: missing header", ErrInvalidCapture)
+	}
+	capture := &Capture{}
+	if err := json.Unmarshal(scanner.Bytes(), &capture.Header); err != nil {
+		return nil, fmt.Errorf("%w: malformed header: %v", ErrInvalidCapture, err)
+	}
+	if capture.Header.Version != CaptureFormatVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidCapture, capture.Header.Version)
+	}
+
+	var pending error
+	for scanner.Scan() {
+		if pending != nil {
+			return nil, pending
+		}
+		var record CaptureRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			pending = fmt.Errorf("%w: malformed record %d: %v", ErrInvalidCapture, len(capture.Records)+1, err)
+			continue
+		}
+		capture.Records = append(capture.Records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read capture: %w", err)
+	}
+	return capture, nil
+}
+
+// ReadCaptureFile opens and parses the capture file at path.
+func ReadCaptureFile(path string) (*Capture, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open capture: %w", err)
+	}
+	defer f.Close()
+	return ReadCapture(f)
+}
+
+// ---------------------------------------------------------------------------
+// Recording
+// ---------------------------------------------------------------------------
+
+// SessionRecorder appends a session's inbound WebSocket messages to a capture,
+// one JSON line per message after a header line.
+type SessionRecorder struct {
+	mu      sync.Mutex
+	w       io.Writer
+	closer  io.Closer
+	started time.Time
+	err     error
+}
+
+// NewSessionRecorder writes the capture header for sessionID to w and returns
+// a recorder measuring offsets from now. When w is an io.Closer, Close closes it.
+func NewSessionRecorder(w io.Writer, sessionID string) (*SessionRecorder, error) {
+	rec := &SessionRecorder{w: w, started: time.Now()}
+	if c, ok := w.(io.Closer); ok {
+		rec.closer = c
+	}
+	header, err := json.Marshal(CaptureHeader{
+		Version:   CaptureFormatVersion,
+		SessionID: sessionID,
+		StartedAt: rec.started.UTC(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode capture header: %w", err)
+	}
+	if _, err := w.Write(append(header, '\n')); err != nil {
+		return nil, fmt.Errorf("failed to write capture header: %w", err)
+	}
+	return rec, nil
+}
+
+// Record appends a message. After the first write error the recorder stops
+// writing and keeps returning that error, so a full disk never stalls the
+// connection being captured.
+func (rec *SessionRecorder) Record(messageType int, payload []byte) error {
+	line, err := json.Marshal(CaptureRecord{
+		Offset:      time.Since(rec.started),
+		MessageType: messageType,
+		Payload:     payload,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode capture record: %w", err)
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.err != nil {
+		return rec.err
+	}
+	if _, err := rec.w.Write(append(line, '\n')); err != nil {
+		rec.err = fmt.Errorf("failed to write capture record: %w", err)
+	}
+	return rec.err
+}
+
+// Close closes the underlying writer when it is closable.
+func (rec *SessionRecorder) Close() error {
+	if rec.closer == nil {
+		return nil
+	}
+	return rec.closer.Close()
+}
+
+// StreamCapture decides which WebSocket sessions are recorded and creates a
+// capture file for each of their connections under a directory. It is meant
+// to be switched on for the sessions of a production incident, whose captures
+// are then replayed as regression fixtures.
+type StreamCapture struct {
+	dir      string
+	all      bool
+	sessions map[string]struct{}
+}
+
+// NewStreamCapture records the listed sessions, or every session when the
+// list contains CaptureAllSessions, into dir, creating it if needed.
+func NewStreamCapture(dir string, sessions []string) (*StreamCapture, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create capture directory: %w", err)
+	}
+	sc := &StreamCapture{dir: dir, sessions: make(map[string]struct{}, len(sessions))}
+	for _, id := range sessions {
+		if id == CaptureAllSessions {
+			sc.all = true
+		}
+		sc.sessions[id] = struct{}{}
+	}
+	return sc, nil
+}
+
+// Start opens a recorder for a new connection of sessionID. It returns nil
+// without error when the session is not selected for capture.
+func (sc *StreamCapture) Start(sessionID string) (*SessionRecorder, error) {
+	if sc == nil {
+		return nil, nil
+	}
+	if _, ok := sc.sessions[sessionID]; !ok && !sc.all {
+		return nil, nil
+	}
+	name := fmt.Sprintf("%s-%d%s", captureFileName(sessionID), time.Now().UnixNano(), captureFileExt)
+	f, err := os.OpenFile(filepath.Join(sc.dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create capture file: %w", err)
+	}
+	rec, err := NewSessionRecorder(f, sessionID)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return rec, nil
+}
+
+// captureFileName maps a client-supplied session ID to a safe file name
+// component, replacing anything but letters, digits, '-' and '_'.
+func captureFileName(sessionID string) string {
+	if sessionID == "" {
+		return "unknown"
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, sessionID)
+}
+
+// ---------------------------------------------------------------------------
+// Replay
+// ---------------------------------------------------------------------------
+
+// ReplayOptions controls how a capture is fed back through a location stream.
+type ReplayOptions struct {
+	// Speed scales the original timing: 1 replays in real time, 10 ten times
+	// faster. Zero or negative replays without delays.
+	Speed float64
+
+	// SessionID overrides the captured session ID, e.g. to replay into a
+	// session started by the test fixture.
+	SessionID string
+}
+
+// ReplayError is a message the stream rejected during a replay.
+type ReplayError struct {
+	Index int
+	Err   error
+}
+
+// ReplayResult summarizes a replay.
+type ReplayResult struct {
+	SessionID string
+	Processed int
+	Skipped   int
+	Errors    []ReplayError
+	Elapsed   time.Duration
+}
+
+// ReplayCapture feeds a capture's messages through a location stream on api in
+// order, as the read pump would have, pacing them by their recorded offsets
+// scaled by opts.Speed. The stream is not subscribed to a hub, so subscribe
+// and throttle frames are rejected as with live frames disabled. Messages the
+// read pump ignores (control frames, empty payloads) are skipped. Rejected
+// messages are collected with their ack's error rather than aborting, so a
+// regression test can assert on exactly which messages fail; only ctx
+// cancellation stops a replay early.
+func ReplayCapture(ctx context.Context, logger *zap.Logger, api services.TrackingAPI, capture *Capture, opts ReplayOptions) (*ReplayResult, error) {
+	result := &ReplayResult{SessionID: capture.Header.SessionID}
+	if opts.SessionID != "" {
+		result.SessionID = opts.SessionID
+	}
+
+	index := 0
+	stream := &locationStream{logger: logger, api: api, sessionID: result.SessionID}
+	stream.sink = func(v interface{}) {
+		if ack, ok := v.(streamAck); ok && ack.Status == streamAckRejected && ack.Error != nil {
+			result.Errors = append(result.Errors, ReplayError{Index: index, Err: ack.Error})
+		}
+	}
+
+	start := time.Now()
+	for i, record := range capture.Records {
+		if opts.Speed > 0 {
+			due := start.Add(time.Duration(float64(record.Offset) / opts.Speed))
+			if wait := time.Until(due); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					result.Elapsed = time.Since(start)
+					return result, ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			result.Elapsed = time.Since(start)
+			return result, err
+		}
+
+		if (record.MessageType != websocket.TextMessage && record.MessageType != websocket.BinaryMessage) || len(record.Payload) == 0 {
+			result.Skipped++
+			continue
+		}
+		index = i
+		stream.handleMessage(record.Payload)
+		result.Processed++
+	}
+	result.Elapsed = time.Since(start)
+	return result, nil
+}
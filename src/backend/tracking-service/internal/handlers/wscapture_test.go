@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"src/backend/tracking-service/internal/models"
+	"src/backend/tracking-service/internal/services"
+)
+
+// replayAPI records what a replayed stream asks of the tracking service. The
+// methods a stream does not call are left to the nil embedded interface.
+type replayAPI struct {
+	services.TrackingAPI
+	batches [][]*models.Location
+	gaps    []models.SeqRange
+}
+
+func (a *replayAPI) CheckIngress(sessionID, transport string) error {
+	return nil
+}
+
+func (a *replayAPI) ProcessBatchLocations(sessionID string, locations []*models.Location) (services.BatchResult, error) {
+	if sessionID != "replayed-session" {
+		return services.BatchResult{}, errors.New("unexpected session " + sessionID)
+	}
+	a.batches = append(a.batches, locations)
+	return services.BatchResult{ProcessedCount: len(locations), StoredCount: len(locations), Success: true}, nil
+}
+
+func (a *replayAPI) ConfirmSequenceGap(sessionID string, r models.SeqRange) (int, error) {
+	a.gaps = append(a.gaps, r)
+	return int(r.To - r.From + 1), nil
+}
+
+// TestReplayCaptureThroughLocationStream records a session, reads its capture
+// back and replays it into another session, checking each frame reaches the
+// tracking service as it would on a live stream and that exactly the frames a
+// stream rejects are reported.
+func TestReplayCaptureThroughLocationStream(t *testing.T) {
+	var buf bytes.Buffer
+	rec, err := NewSessionRecorder(&buf, "captured-session")
+	if err != nil {
+		t.Fatalf("NewSessionRecorder: %v", err)
+	}
+	for _, msg := range []struct {
+		messageType int
+		payload     string
+	}{
+		{websocket.TextMessage, `{"type":"locationUpdate","seq":1,"location":{"latitude":40.7128,"longitude":-74.006,"accuracy":5,"timestamp":"2024-05-01T12:00:00Z"}}`},
+		{websocket.PingMessage, `ping`},
+		{websocket.TextMessage, `not a frame`},
+		{websocket.TextMessage, `{"type":"batchUpdate","seq":2,"locations":[{"latitude":40.713,"longitude":-74.0061},{"latitude":40.7131,"longitude":-74.0062}]}`},
+		{websocket.TextMessage, `{"type":"subscribe","seq":3}`},
+		{websocket.TextMessage, `{"type":"gap","seq":4,"range":{"from":5,"to":7}}`},
+		{websocket.TextMessage, `{"type":"heartbeat","seq":5}`},
+	} {
+		if err := rec.Record(msg.messageType, []byte(msg.payload)); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+
+	capture, err := ReadCapture(&buf)
+	if err != nil {
+		t.Fatalf("ReadCapture: %v", err)
+	}
+	if capture.Header.SessionID != "captured-session" || len(capture.Records) != 7 {
+		t.Fatalf("capture of %q has %d records, want captured-session with 7", capture.Header.SessionID, len(capture.Records))
+	}
+
+	api := &replayAPI{}
+	result, err := ReplayCapture(context.Background(), zap.NewNop(), api, capture, ReplayOptions{SessionID: "replayed-session"})
+	if err != nil {
+		t.Fatalf("ReplayCapture: %v", err)
+	}
+	if result.SessionID != "replayed-session" || result.Processed != 6 || result.Skipped != 1 {
+		t.Fatalf("replay of %q processed %d and skipped %d, want replayed-session with 6 and 1",
+			result.SessionID, result.Processed, result.Skipped)
+	}
+
+	wantErrors := map[int]int{2: http.StatusBadRequest, 4: http.StatusServiceUnavailable}
+	if len(result.Errors) != len(wantErrors) {
+		t.Fatalf("replay reported %d errors, want %d: %v", len(result.Errors), len(wantErrors), result.Errors)
+	}
+	for _, replayErr := range result.Errors {
+		var apiErr *APIError
+		if !errors.As(replayErr.Err, &apiErr) || apiErr.Status != wantErrors[replayErr.Index] {
+			t.Errorf("message %d rejected with %v, want status %d", replayErr.Index, replayErr.Err, wantErrors[replayErr.Index])
+		}
+	}
+
+	if len(api.batches) != 2 || len(api.batches[0]) != 1 || len(api.batches[1]) != 2 {
+		t.Fatalf("tracking service received batches %v, want one of 1 point and one of 2", api.batches)
+	}
+	if len(api.gaps) != 1 || api.gaps[0] != (models.SeqRange{From: 5, To: 7}) {
+		t.Fatalf("tracking service confirmed gaps %v, want [5, 7]", api.gaps)
+	}
+}
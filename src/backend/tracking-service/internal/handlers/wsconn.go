@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	// json for building messages from protobuf batches (go1.21)
+	"encoding/json"
+	// errors for rejecting unsupported protobuf batches (go1.21)
+	"errors"
+	// sync for closing a connection once (go1.21)
+	"sync"
+	// time for the backpressure wait (go1.21)
+	"time"
+
+	// WebSocket protocol implementation (github.com/gorilla/websocket v1.5.0)
+	"github.com/gorilla/websocket"
+
+	// utils package for the CBOR and protobuf transcoding
+	um "src/backend/tracking-service/internal/utils"
+)
+
+// WebSocket subprotocols a client may request. Clients that request neither
+// speak JSON in text frames.
+const (
+	// SubprotocolJSON exchanges JSON documents in text frames.
+	SubprotocolJSON = "tracking.v1.json"
+
+	// SubprotocolCBOR exchanges the same documents encoded as CBOR in binary
+	// frames, for low-power trackers: it is smaller on the air and cheaper to
+	// parse on a microcontroller. Text frames from the client are still read
+	// as JSON.
+	SubprotocolCBOR = "tracking.v1.cbor"
+
+	// SubprotocolProtobuf carries location updates and sequenced uploads from
+	// the client as LocationBatch messages of api/tracking.proto in binary
+	// frames, the most compact encoding for 1 Hz location streams. Text frames
+	// from the client are still read as JSON, and every reply is a JSON text
+	// frame.
+	SubprotocolProtobuf = "tracking.v1.protobuf"
+)
+
+// sendPolicy decides what happens to an outbound message when its
+// connection's send queue is full.
+type sendPolicy int
+
+const (
+	// sendBackpressure waits up to writeWait for room in the queue and closes
+	// the connection if the client has not drained it by then. It is used for
+	// replies to the client's own messages, which are sent from the read
+	// pump, so a client that stops reading its replies stops being read.
+	sendBackpressure sendPolicy = iota
+
+	// sendDrop drops the message. It is used for messages pushed from other
+	// goroutines, which must not stall on a slow client: upload acks are
+	// cumulative, so the next one covers a dropped one.
+	sendDrop
+)
+
+// wsConn is a live WebSocket connection with its outbound queue. Once its
+// write pump is started, the pump is the connection's only writer and every
+// other goroutine queues messages through enqueue, as gorilla/websocket
+// connections support a single concurrent writer. Messages are handled as
+// JSON throughout and only transcoded at the socket for CBOR and protobuf
+// clients.
+type wsConn struct {
+	conn      *websocket.Conn
+	cbor      bool
+	protobuf  bool
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// registration owns the connection's cleanup once the handler has
+	// accepted it.
+	registration *wsRegistration
+}
+
+// newWSConn wraps conn with a send queue of messageBufferSize messages,
+// encoding for the subprotocol negotiated on it.
+func newWSConn(conn *websocket.Conn) *wsConn {
+	return &wsConn{
+		conn:     conn,
+		cbor:     conn.Subprotocol() == SubprotocolCBOR,
+		protobuf: conn.Subprotocol() == SubprotocolProtobuf,
+		send:     make(chan []byte, messageBufferSize),
+		done:     make(chan struct{}),
+	}
+}
+
+// enqueue queues msg for the write pump, applying policy when the queue is
+// full, and reports whether it was queued. Messages for a closed connection
+// are discarded.
+func (c *wsConn) enqueue(msg []byte, policy sendPolicy) bool {
+	select {
+	case <-c.done:
+		return false
+	case c.send <- msg:
+		return true
+	default:
+	}
+	if policy == sendDrop {
+		return false
+	}
+
+	timer := time.NewTimer(writeWait)
+	defer timer.Stop()
+	select {
+	case <-c.done:
+		return false
+	case c.send <- msg:
+		return true
+	case <-timer.C:
+		c.close()
+		return false
+	}
+}
+
+// close stops the write pump and closes the socket, which ends the read pump.
+// Later calls are no-ops.
+func (c *wsConn) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		_ = c.conn.Close()
+	})
+}
+
+// decode returns an inbound message as JSON, transcoding binary messages on a
+// CBOR or protobuf connection.
+func (c *wsConn) decode(messageType int, msg []byte) ([]byte, error) {
+	if messageType != websocket.BinaryMessage {
+		return msg, nil
+	}
+	switch {
+	case c.cbor:
+		return um.CBORToJSON(msg)
+	case c.protobuf:
+		return protobufMessage(msg)
+	}
+	return msg, nil
+}
+
+// protobufMessage turns a LocationBatch into the JSON message a client would
+// have sent instead: an upload when it carries an upload sequence, and
+// otherwise a location update of its single point.
+func protobufMessage(msg []byte) ([]byte, error) {
+	batch, err := um.UnmarshalLocationBatchProto(msg)
+	if err != nil {
+		return nil, err
+	}
+	if batch.UploadSeq > 0 {
+		return json.Marshal(map[string]interface{}{
+			"action":    "upload",
+			"uploadSeq": batch.UploadSeq,
+			"locations": batch.Locations,
+		})
+	}
+	if len(batch.Locations) != 1 {
+		return nil, errors.New("protobuf batch without an upload sequence must hold exactly one location")
+	}
+	data, err := json.Marshal(batch.Locations[0])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]string{"action": "locationUpdate", "data": string(data)})
+}
+
+// write writes a JSON message to the socket in the connection's encoding. Only
+// the write pump, or the handler before it starts the pump, may call it.
+func (c *wsConn) write(msg []byte) error {
+	_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return writeJSONFrame(c.conn, msg)
+}
+
+// writeJSONFrame writes a JSON frame to conn in the encoding of its
+// subprotocol: a CBOR binary frame on CBOR connections and a text frame
+// otherwise. The caller sets the write deadline.
+func writeJSONFrame(conn *websocket.Conn, frame []byte) error {
+	if conn.Subprotocol() != SubprotocolCBOR {
+		return writeWSMessage(conn, websocket.TextMessage, frame)
+	}
+	encoded, err := um.JSONToCBOR(frame)
+	if err != nil {
+		return err
+	}
+	return writeWSMessage(conn, websocket.BinaryMessage, encoded)
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	// sync for deregistering a connection once (go1.21)
+	"sync"
+	// atomic for counting a connection's live pumps (go1.21)
+	"sync/atomic"
+	// time for the sweep interval (go1.21)
+	"time"
+
+	// prometheus for connection registry metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Reasons a connection leaves the registry, as labelled on
+// tracking_websocket_deregistrations_total.
+const (
+	deregisterClosed   = "closed"
+	deregisterPanic    = "panic"
+	deregisterShutdown = "shutdown"
+	deregisterSwept    = "swept"
+)
+
+// wsRegistration owns the cleanup of one connection: its registry entry,
+// guard slot and tracking session. Whichever of its pumps,
+// a failed start, Shutdown or the orphan sweeper finishes with it first
+// deregisters it, and the rest find the work done.
+type wsRegistration struct {
+	wh        *WebSocketHandler
+	sessionID string
+	wc        *wsConn
+
+	// release frees the connection's guard slot.
+	release func()
+
+	// pumps is the number of the connection's pumps still running.
+	pumps int32
+
+	once sync.Once
+}
+
+// wsLifecycleMetrics counts connections through the registry, so entries that
+// outlive their connection show up as a growing gap between registrations and
+// deregistrations, and as orphans swept.
+type wsLifecycleMetrics struct {
+	registered      prometheus.Gauge
+	deregistrations *prometheus.CounterVec
+	orphans         prometheus.Counter
+	startFailures   prometheus.Counter
+}
+
+// ---------------------------------------------------------------------------
+// EnableLeakMetrics
+// ---------------------------------------------------------------------------
+//
+// EnableLeakMetrics registers metrics on registry for the connections held in
+// the handler's registry, their deregistrations by reason, pump start failures
+// and orphaned entries removed by the sweeper.
+func (wh *WebSocketHandler) EnableLeakMetrics(registry *prometheus.Registry) {
+	m := &wsLifecycleMetrics{
+		registered: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "tracking_websocket_registered_connections",
+			Help: "WebSocket connections held in the connection registry",
+		}),
+		deregistrations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tracking_websocket_deregistrations_total",
+			Help: "WebSocket connections removed from the connection registry, by reason",
+		}, []string{"reason"}),
+		orphans: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tracking_websocket_orphans_swept_total",
+			Help: "Registry entries whose connection had closed without deregistering, removed by the orphan sweeper",
+		}),
+		startFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tracking_websocket_pump_start_failures_total",
+			Help: "WebSocket connections whose read or write pump failed to start",
+		}),
+	}
+	if registry != nil {
+		registry.MustRegister(m.registered, m.deregistrations, m.orphans, m.startFailures)
+	}
+	wh.lifecycle = m
+}
+
+// ---------------------------------------------------------------------------
+// StartOrphanSweeper
+// ---------------------------------------------------------------------------
+//
+// StartOrphanSweeper compares the connection registry against the live
+// connections every interval until the handler shuts down, deregistering
+// entries whose connection has closed or whose pumps have all exited. It
+// backstops the pumps' own deregistration, which a panic outside their
+// recovery could skip.
+func (wh *WebSocketHandler) StartOrphanSweeper(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-wh.ctx.Done():
+				return
+			case <-ticker.C:
+				wh.sweepOrphans()
+			}
+		}
+	}()
+}
+
+// sweepOrphans deregisters the registry entries without a live connection and
+// returns how many it removed.
+func (wh *WebSocketHandler) sweepOrphans() int {
+	swept := 0
+	wh.connections.Range(func(key, value interface{}) bool {
+		wc, ok := value.(*wsConn)
+		if !ok {
+			if wh.connections.CompareAndDelete(key, value) {
+				swept++
+			}
+			return true
+		}
+		if wc.live() {
+			return true
+		}
+		if reg := wc.registration; reg != nil {
+			if reg.deregister(deregisterSwept) {
+				swept++
+			}
+		} else if wh.unregister(key.(string), wc, deregisterSwept) {
+			swept++
+		}
+		return true
+	})
+	if swept > 0 && wh.lifecycle != nil {
+		wh.lifecycle.orphans.Add(float64(swept))
+	}
+	return swept
+}
+
+// newRegistration prepares the cleanup of wc, which holds the guard slot
+// freed by release. The connection is not yet in the registry.
+func (wh *WebSocketHandler) newRegistration(sessionID string, wc *wsConn, release func()) *wsRegistration {
+	reg := &wsRegistration{wh: wh, sessionID: sessionID, wc: wc, release: release}
+	wc.registration = reg
+	return reg
+}
+
+// start runs the connection's pumps and only then registers it, so a
+// connection whose pumps never ran is never reachable through the registry.
+// A panic while starting them deregisters the connection and is returned as
+// false.
+func (reg *wsRegistration) start() (started bool) {
+	wh := reg.wh
+	defer func() {
+		if p := recover(); p != nil {
+			if wh.lifecycle != nil {
+				wh.lifecycle.startFailures.Inc()
+			}
+			reg.deregister(deregisterPanic)
+			started = false
+		}
+	}()
+
+	atomic.StoreInt32(&reg.pumps, 2)
+	go wh.writePump(reg)
+	go wh.readPump(reg)
+
+	wh.connections.Store(reg.sessionID, reg.wc)
+	if wh.lifecycle != nil {
+		wh.lifecycle.registered.Inc()
+	}
+	// A pump that already exited deregistered before the entry existed;
+	// remove it rather than leave it for the sweeper.
+	if !reg.wc.live() {
+		wh.unregister(reg.sessionID, reg.wc, deregisterClosed)
+	}
+	return true
+}
+
+// pumpExited records that one of the connection's pumps has returned.
+func (reg *wsRegistration) pumpExited() {
+	atomic.AddInt32(&reg.pumps, -1)
+}
+
+// deregister closes the connection and releases everything it holds. Only
+// the first call does anything, and reports true. The tracking session is
+// ended unless a newer connection for it has taken over its registry entry.
+func (reg *wsRegistration) deregister(reason string) bool {
+	ran := false
+	reg.once.Do(func() {
+		ran = true
+		wh := reg.wh
+		reg.wc.close()
+		wh.unregister(reg.sessionID, reg.wc, reason)
+		reg.release()
+		if _, replaced := wh.connections.Load(reg.sessionID); replaced || wh.trackingService == nil {
+			return
+		}
+		_ = wh.trackingService.EndSession(reg.sessionID)
+	})
+	return ran
+}
+
+// unregister removes wc's registry entry, if it still holds sessionID's, and
+// reports whether it did.
+func (wh *WebSocketHandler) unregister(sessionID string, wc *wsConn, reason string) bool {
+	if !wh.connections.CompareAndDelete(sessionID, wc) {
+		return false
+	}
+	if wh.lifecycle != nil {
+		wh.lifecycle.registered.Dec()
+		wh.lifecycle.deregistrations.WithLabelValues(reason).Inc()
+	}
+	return true
+}
+
+// live reports whether the connection is open with at least one pump running.
+func (c *wsConn) live() bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	return c.registration == nil || atomic.LoadInt32(&c.registration.pumps) > 0
+}
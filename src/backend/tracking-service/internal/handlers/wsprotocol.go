@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	// json for decoding inbound frames and encoding acks (go1.21)
+	"encoding/json"
+	// errors for classifying processing failures (go1.21)
+	"errors"
+	// fmt for formatting ack messages (go1.21)
+	"fmt"
+	// http for the statuses of ack errors (go1.21)
+	"net/http"
+	// time for heartbeat acks and write deadlines (go1.21)
+	"time"
+
+	// WebSocket protocol implementation (github.com/gorilla/websocket v1.5.0)
+	"github.com/gorilla/websocket"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// models package for the inbound locations
+	"src/backend/tracking-service/internal/models"
+	// services package for batch processing and ingress checks
+	"src/backend/tracking-service/internal/services"
+	// utils package for the CBOR and protobuf transcoding
+	um "src/backend/tracking-service/internal/utils"
+)
+
+// Types of the frames a client sends on a location stream.
+const (
+	streamMsgLocationUpdate = "locationUpdate"
+	streamMsgBatchUpdate    = "batchUpdate"
+	streamMsgSubscribe      = "subscribe"
+	streamMsgHeartbeat      = "heartbeat"
+	streamMsgGap            = "gap"
+	streamMsgThrottle       = "throttle"
+)
+
+// streamMsgRetransmit is the type of the control frame asking the client to
+// send the points of missing sequence ranges again.
+const streamMsgRetransmit = "retransmit"
+
+// Statuses of stream acks.
+const (
+	streamAckOK       = "ok"
+	streamAckRejected = "rejected"
+)
+
+// maxStreamFrameSize bounds the inbound frames of streams that may send
+// locations, large enough for a full batchUpdate of services.MaxBatchSize
+// points. Read-only share subscribers keep the smaller maxMessageSize.
+var maxStreamFrameSize int64 = 64 << 10
+
+// streamMessage is a frame sent by a client on a location stream:
+//
+//	{"type": "locationUpdate", "seq": 7, "location": {...}}
+//	{"type": "batchUpdate", "seq": 8, "locations": [{...}, ...]}
+//	{"type": "subscribe", "seq": 9}
+//	{"type": "heartbeat", "seq": 10}
+//	{"type": "gap", "seq": 11, "range": {"from": 40, "to": 42}}
+//	{"type": "throttle", "seq": 12, "maxFrameIntervalMs": 3000}
+//
+// Seq is chosen by the client and echoed in the frame's ack, so the client
+// can match acks to the frames it sent. It is unrelated to the sequence
+// numbers of the points themselves; a gap frame confirms that the points
+// numbered Range, which the service asked for again, are lost. A throttle
+// frame renegotiates the subscriber's delivery interval, e.g. after its link
+// changes; zero asks for every frame.
+type streamMessage struct {
+	Type               string             `json:"type"`
+	Seq                uint64             `json:"seq"`
+	Location           *models.Location   `json:"location,omitempty"`
+	Locations          []*models.Location `json:"locations,omitempty"`
+	Range              *models.SeqRange   `json:"range,omitempty"`
+	MaxFrameIntervalMs *int64             `json:"maxFrameIntervalMs,omitempty"`
+}
+
+// streamAck answers one streamMessage. Status is "ok" when the frame was
+// processed and "rejected" otherwise, with Error saying why. The counts
+// describe the points of location frames: accepted (stored or queued for
+// storage), invalid, and duplicates of points already stored; Confirmed counts
+// the awaited points a gap frame confirmed lost. Terminal is set when the
+// session has ended and the client must stop sending.
+type streamAck struct {
+	Type       string     `json:"type"`
+	Seq        uint64     `json:"seq"`
+	For        string     `json:"for"`
+	Status     string     `json:"status"`
+	Accepted   int        `json:"accepted,omitempty"`
+	Invalid    int        `json:"invalid,omitempty"`
+	Duplicates int        `json:"duplicates,omitempty"`
+	Confirmed  int        `json:"confirmed,omitempty"`
+	Terminal   bool       `json:"terminal,omitempty"`
+	ServerTime *time.Time `json:"serverTime,omitempty"`
+	Error      *APIError  `json:"error,omitempty"`
+}
+
+// locationStream is the state of one location stream connection the inbound
+// frames are handled with.
+type locationStream struct {
+	logger    *zap.Logger
+	api       services.TrackingAPI
+	conn      *websocket.Conn
+	sessionID string
+	readOnly  bool
+
+	// hub and sub are set when the stream is subscribed to the hub, whose
+	// writer then owns the connection's writes, acks included.
+	hub *StreamHub
+	sub *hubSubscriber
+
+	// sink, when set, receives the stream's acks and control frames instead
+	// of the connection, as when a capture is replayed without one.
+	sink func(v interface{})
+}
+
+// decode returns an inbound frame as JSON, transcoding binary frames on a CBOR
+// or protobuf connection. Text frames are JSON whatever the subprotocol.
+func (s *locationStream) decode(messageType int, data []byte) ([]byte, error) {
+	return decodeStreamFrame(s.conn.Subprotocol(), messageType, data)
+}
+
+// decodeStreamFrame returns an inbound frame of a connection negotiated with
+// subprotocol as JSON.
+func decodeStreamFrame(subprotocol string, messageType int, data []byte) ([]byte, error) {
+	if messageType != websocket.BinaryMessage {
+		return data, nil
+	}
+	switch subprotocol {
+	case SubprotocolCBOR:
+		return um.CBORToJSON(data)
+	case SubprotocolProtobuf:
+		return protobufStreamMessage(data)
+	}
+	return data, nil
+}
+
+// protobufStreamMessage turns a LocationBatch into the frame a JSON client
+// would have sent instead: a locationUpdate for a single point and a
+// batchUpdate otherwise, with the batch's upload sequence as the frame's seq.
+func protobufStreamMessage(data []byte) ([]byte, error) {
+	batch, err := um.UnmarshalLocationBatchProto(data)
+	if err != nil {
+		return nil, err
+	}
+	msg := streamMessage{Type: streamMsgBatchUpdate, Seq: batch.UploadSeq, Locations: batch.Locations}
+	if len(batch.Locations) == 1 {
+		msg = streamMessage{Type: streamMsgLocationUpdate, Seq: batch.UploadSeq, Location: batch.Locations[0]}
+	}
+	return json.Marshal(msg)
+}
+
+// handleMessage processes one inbound frame and replies with its ack.
+// Malformed frames are rejected with seq 0 when theirs cannot be read.
+func (s *locationStream) handleMessage(data []byte) {
+	var msg streamMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		s.reply(s.rejected(msg, NewAPIError(http.StatusBadRequest, "", "invalid stream frame")))
+		return
+	}
+
+	switch msg.Type {
+	case streamMsgLocationUpdate:
+		if msg.Location == nil {
+			s.reply(s.rejected(msg, NewAPIError(http.StatusBadRequest, CodeValidationFailed, "locationUpdate requires a location")))
+			return
+		}
+		s.replyLocations(msg, []*models.Location{msg.Location})
+	case streamMsgBatchUpdate:
+		if len(msg.Locations) == 0 {
+			s.reply(s.rejected(msg, NewAPIError(http.StatusBadRequest, CodeValidationFailed, "batchUpdate requires locations")))
+			return
+		}
+		if len(msg.Locations) > services.MaxBatchSize {
+			s.reply(s.rejected(msg, NewAPIError(http.StatusBadRequest, CodeValidationFailed,
+				fmt.Sprintf("batch exceeds maximum batch size of %d", services.MaxBatchSize))))
+			return
+		}
+		s.replyLocations(msg, msg.Locations)
+	case streamMsgSubscribe:
+		if s.sub == nil {
+			s.reply(s.rejected(msg, NewAPIError(http.StatusServiceUnavailable, "", "live location frames are not enabled")))
+			return
+		}
+		s.sub.muted.Store(false)
+		s.reply(s.ok(msg))
+	case streamMsgHeartbeat:
+		ack := s.ok(msg)
+		now := time.Now().UTC()
+		ack.ServerTime = &now
+		s.reply(ack)
+	case streamMsgGap:
+		s.reply(s.confirmGap(msg))
+	case streamMsgThrottle:
+		s.reply(s.setThrottle(msg))
+	default:
+		s.reply(s.rejected(msg, NewAPIError(http.StatusBadRequest, "", fmt.Sprintf("unknown stream frame type %q", msg.Type))))
+	}
+}
+
+// retransmitFrame asks the client to send the points numbered Ranges again,
+// or to confirm them lost with a gap frame when it no longer holds them:
+//
+//	{"type": "retransmit", "ranges": [{"from": 40, "to": 42}]}
+type retransmitFrame struct {
+	Type   string            `json:"type"`
+	Ranges []models.SeqRange `json:"ranges"`
+}
+
+// replyLocations processes a location frame and replies with its ack,
+// followed by a retransmit frame when its points showed sequence numbers
+// missing.
+func (s *locationStream) replyLocations(msg streamMessage, locations []*models.Location) {
+	ack, missing := s.processLocations(msg, locations)
+	s.reply(ack)
+	if len(missing) > 0 {
+		s.reply(retransmitFrame{Type: streamMsgRetransmit, Ranges: missing})
+	}
+}
+
+// processLocations runs the points of a location frame through the session's
+// batch pipeline, which stores them, publishes them to MQTT and hands them to
+// live subscribers, and builds the frame's ack. It also returns the point
+// sequence ranges the frame showed missing.
+func (s *locationStream) processLocations(msg streamMessage, locations []*models.Location) (streamAck, []models.SeqRange) {
+	if s.readOnly {
+		return s.rejected(msg, NewAPIError(http.StatusForbidden, "", "share-link subscriptions are read-only")), nil
+	}
+	if err := s.api.CheckIngress(s.sessionID, services.IngressWebSocket); err != nil {
+		ack := s.rejected(msg, NewAPIError(http.StatusGone, "", "session has ended"))
+		ack.Terminal = true
+		return ack, nil
+	}
+
+	result, err := s.api.ProcessBatchLocations(s.sessionID, locations)
+	if err != nil {
+		if !errors.Is(err, services.ErrStoreUnavailable) {
+			s.logger.Error("Failed to process stream locations",
+				zap.String("sessionID", s.sessionID),
+				zap.Uint64("seq", msg.Seq),
+				zap.Error(err),
+			)
+		}
+		return s.rejected(msg, TranslateError(err)), nil
+	}
+	ack := s.ok(msg)
+	ack.Accepted = result.StoredCount + result.QueuedCount
+	ack.Invalid = result.InvalidCount
+	ack.Duplicates = result.DuplicateCount
+	if !result.Success {
+		ack.Status = streamAckRejected
+		ack.Error = NewAPIError(http.StatusUnprocessableEntity, CodeLocationRejected, "no location in the frame was accepted")
+	}
+	return ack, result.Retransmit
+}
+
+// confirmGap records that the client no longer holds the points of a gap
+// frame's range, so they are counted lost instead of awaited.
+func (s *locationStream) confirmGap(msg streamMessage) streamAck {
+	if s.readOnly {
+		return s.rejected(msg, NewAPIError(http.StatusForbidden, "", "share-link subscriptions are read-only"))
+	}
+	if msg.Range == nil || msg.Range.From == 0 || msg.Range.To < msg.Range.From {
+		return s.rejected(msg, NewAPIError(http.StatusBadRequest, CodeValidationFailed, "gap requires a range with 1 <= from <= to"))
+	}
+	confirmed, err := s.api.ConfirmSequenceGap(s.sessionID, *msg.Range)
+	if err != nil {
+		return s.rejected(msg, TranslateError(err))
+	}
+	ack := s.ok(msg)
+	ack.Confirmed = confirmed
+	return ack
+}
+
+// setThrottle applies the delivery interval of a throttle frame, bounded by
+// the configured maximum.
+func (s *locationStream) setThrottle(msg streamMessage) streamAck {
+	if s.sub == nil {
+		return s.rejected(msg, NewAPIError(http.StatusServiceUnavailable, "", "live location frames are not enabled"))
+	}
+	if msg.MaxFrameIntervalMs == nil || *msg.MaxFrameIntervalMs < 0 {
+		return s.rejected(msg, NewAPIError(http.StatusBadRequest, CodeValidationFailed,
+			fmt.Sprintf("throttle requires a non-negative %s", MaxFrameIntervalParam)))
+	}
+	s.sub.throttle.setRequested(time.Duration(*msg.MaxFrameIntervalMs) * time.Millisecond)
+	return s.ok(msg)
+}
+
+// ok returns the successful ack of msg.
+func (s *locationStream) ok(msg streamMessage) streamAck {
+	return streamAck{Type: "ack", Seq: msg.Seq, For: msg.Type, Status: streamAckOK}
+}
+
+// rejected returns the ack of msg rejected with e.
+func (s *locationStream) rejected(msg streamMessage, e *APIError) streamAck {
+	return streamAck{Type: "ack", Seq: msg.Seq, For: msg.Type, Status: streamAckRejected, Error: e}
+}
+
+// reply sends an ack or control frame to the client, through the hub's
+// writer when the stream is subscribed and directly otherwise.
+func (s *locationStream) reply(v interface{}) {
+	if s.sink != nil {
+		s.sink(v)
+		return
+	}
+	frame, err := json.Marshal(v)
+	if err != nil {
+		s.logger.Error("Failed to encode stream frame", zap.String("sessionID", s.sessionID), zap.Error(err))
+		return
+	}
+	if s.sub != nil {
+		s.hub.send(s.sub, frame)
+		return
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	if err := writeJSONFrame(s.conn, frame); err != nil {
+		s.logger.Debug("Stream frame write failed", zap.String("sessionID", s.sessionID), zap.Error(err))
+	}
+}
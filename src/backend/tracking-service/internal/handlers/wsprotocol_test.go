@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gorilla/websocket"
+
+	um "src/backend/tracking-service/internal/utils"
+)
+
+// FuzzStreamFrameRoundTrip checks that a JSON stream frame sent CBOR-encoded
+// by a tracker decodes to the frame it would have sent as JSON, and that
+// arbitrary binary frames never panic the decoder whatever the subprotocol.
+func FuzzStreamFrameRoundTrip(f *testing.F) {
+	f.Add([]byte(`{"type":"heartbeat","seq":10}`))
+	f.Add([]byte(`{"type":"subscribe","seq":9}`))
+	f.Add([]byte(`{"type":"locationUpdate","seq":7,"location":{"id":"8f14e45f-ceea-4e7a-9f3b-6b1f2a1d7c3e","walkId":"walk-1","latitude":40.7128,"longitude":-74.006,"accuracy":4.5,"timestamp":"2024-05-01T12:00:00Z"}}`))
+	f.Add([]byte(`{"type":"batchUpdate","seq":8,"locations":[{"latitude":1.5,"longitude":2.25},{"latitude":-0.1,"longitude":179.9}]}`))
+	f.Add([]byte(`{"type":"gap","seq":11,"range":{"from":40,"to":42}}`))
+	f.Add([]byte(`{"type":"throttle","seq":12,"maxFrameIntervalMs":3000}`))
+
+	f.Fuzz(func(t *testing.T, frame []byte) {
+		for _, subprotocol := range []string{SubprotocolCBOR, SubprotocolProtobuf, SubprotocolJSON} {
+			_, _ = decodeStreamFrame(subprotocol, websocket.BinaryMessage, frame)
+		}
+
+		// Only frames a stream would accept are round-tripped; unknown fields
+		// must still hold numbers JSON decoding can represent.
+		var msg streamMessage
+		var want interface{}
+		if json.Unmarshal(frame, &msg) != nil || json.Unmarshal(frame, &want) != nil {
+			return
+		}
+		text, err := decodeStreamFrame(SubprotocolCBOR, websocket.TextMessage, frame)
+		if err != nil || string(text) != string(frame) {
+			t.Fatalf("text frame on a CBOR stream decoded to %q, %v; want it unchanged", text, err)
+		}
+
+		encoded, err := um.JSONToCBOR(frame)
+		if err != nil {
+			t.Fatalf("JSONToCBOR(%s): %v", frame, err)
+		}
+		decoded, err := decodeStreamFrame(SubprotocolCBOR, websocket.BinaryMessage, encoded)
+		if errors.Is(err, um.ErrCBORTooDeep) {
+			return // nesting beyond what CBOR streams accept
+		}
+		if err != nil {
+			t.Fatalf("decode of CBOR-encoded %s: %v", frame, err)
+		}
+		var got interface{}
+		if err := json.Unmarshal(decoded, &got); err != nil {
+			t.Fatalf("decoded frame %s is not JSON: %v", decoded, err)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Fatalf("CBOR round trip changed %s into %s", frame, decoded)
+		}
+	})
+}
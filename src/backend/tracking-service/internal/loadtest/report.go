@@ -0,0 +1,192 @@
+package loadtest
+
+import (
+	// json for the report artifact (go1.21)
+	"encoding/json"
+	// fmt for threshold failures (go1.21)
+	"fmt"
+	// io for writing reports (go1.21)
+	"io"
+	// os for writing report files (go1.21)
+	"os"
+	// sort for latency percentiles (go1.21)
+	"sort"
+	// strconv for status keys (go1.21)
+	"strconv"
+	// time for run timestamps (go1.21)
+	"time"
+)
+
+// Report is the artifact of one run, kept per release so runs of the same
+// scenario can be compared. Latencies are in milliseconds.
+type Report struct {
+	Scenario  Scenario  `json:"scenario"`
+	Target    string    `json:"target"`
+	StartedAt time.Time `json:"startedAt"`
+	EndedAt   time.Time `json:"endedAt"`
+
+	Points PointCounts `json:"points"`
+
+	// Operations holds the statistics of each kind of request, and Total
+	// those of all of them together.
+	Operations map[string]*OperationStats `json:"operations"`
+	Total      *OperationStats            `json:"total"`
+
+	// Passed is set when the run stayed within the scenario's thresholds;
+	// otherwise Failures lists each threshold breached.
+	Passed   bool     `json:"passed"`
+	Failures []string `json:"failures,omitempty"`
+}
+
+// PointCounts follows the simulated points from device to service: generated
+// by walkers, lost to the scenario's drop pattern, sent, and accepted by the
+// service with a 2xx response.
+type PointCounts struct {
+	Generated int `json:"generated"`
+	Dropped   int `json:"dropped"`
+	Sent      int `json:"sent"`
+	Accepted  int `json:"accepted"`
+}
+
+// OperationStats summarizes the requests of one operation. Statuses counts
+// responses by HTTP status, with requests that got no response under "error".
+type OperationStats struct {
+	Requests  int            `json:"requests"`
+	Errors    int            `json:"errors"`
+	ErrorRate float64        `json:"errorRate"`
+	Statuses  map[string]int `json:"statuses"`
+	LatencyMs Latency        `json:"latencyMs"`
+}
+
+// Latency holds latency percentiles in milliseconds.
+type Latency struct {
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P95  float64 `json:"p95"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+	Mean float64 `json:"mean"`
+}
+
+// report builds the report of the results recorded so far and checks them
+// against the scenario's thresholds.
+func (r *recorder) report(s Scenario, target string, startedAt, endedAt time.Time) *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := &Report{
+		Scenario:   s,
+		Target:     target,
+		StartedAt:  startedAt,
+		EndedAt:    endedAt,
+		Points:     r.points,
+		Operations: make(map[string]*OperationStats, len(r.results)),
+	}
+	var all []result
+	for op, results := range r.results {
+		report.Operations[op] = newOperationStats(results)
+		all = append(all, results...)
+	}
+	report.Total = newOperationStats(all)
+	report.Failures = checkThresholds(s.Thresholds, report)
+	report.Passed = len(report.Failures) == 0
+	return report
+}
+
+// newOperationStats summarizes results.
+func newOperationStats(results []result) *OperationStats {
+	stats := &OperationStats{Requests: len(results), Statuses: make(map[string]int)}
+	latencies := make([]time.Duration, len(results))
+	var sum time.Duration
+	for i, res := range results {
+		if res.failed() {
+			stats.Errors++
+		}
+		key := "error"
+		if res.status != 0 {
+			key = strconv.Itoa(res.status)
+		}
+		stats.Statuses[key]++
+		latencies[i] = res.latency
+		sum += res.latency
+	}
+	if len(results) == 0 {
+		return stats
+	}
+	stats.ErrorRate = float64(stats.Errors) / float64(len(results))
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	stats.LatencyMs = Latency{
+		P50:  milliseconds(percentile(latencies, 50)),
+		P90:  milliseconds(percentile(latencies, 90)),
+		P95:  milliseconds(percentile(latencies, 95)),
+		P99:  milliseconds(percentile(latencies, 99)),
+		Max:  milliseconds(latencies[len(latencies)-1]),
+		Mean: milliseconds(sum / time.Duration(len(latencies))),
+	}
+	return stats
+}
+
+// percentile returns the p-th percentile of sorted by the nearest-rank method.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(p/100*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+// milliseconds converts d to fractional milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// checkThresholds lists the thresholds report breaches: a latency threshold
+// exceeded by any operation, or the error rate over all requests.
+func checkThresholds(t Thresholds, report *Report) []string {
+	var failures []string
+	ops := make([]string, 0, len(report.Operations))
+	for op := range report.Operations {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+	for _, op := range ops {
+		stats := report.Operations[op]
+		if limit := milliseconds(time.Duration(t.P95)); t.P95 > 0 && stats.LatencyMs.P95 > limit {
+			failures = append(failures, fmt.Sprintf("%s p95 latency %.1fms exceeds %.1fms", op, stats.LatencyMs.P95, limit))
+		}
+		if limit := milliseconds(time.Duration(t.P99)); t.P99 > 0 && stats.LatencyMs.P99 > limit {
+			failures = append(failures, fmt.Sprintf("%s p99 latency %.1fms exceeds %.1fms", op, stats.LatencyMs.P99, limit))
+		}
+	}
+	if t.ErrorRate > 0 && report.Total.ErrorRate > t.ErrorRate {
+		failures = append(failures, fmt.Sprintf("error rate %.4f exceeds %.4f", report.Total.ErrorRate, t.ErrorRate))
+	}
+	if report.Total.Requests == 0 {
+		failures = append(failures, "no requests were made")
+	}
+	return failures
+}
+
+// Write encodes the report as indented JSON to w.
+func (r *Report) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteFile writes the report to the file at path.
+func (r *Report) WriteFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create report file: %w", err)
+	}
+	if err := r.Write(f); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return f.Close()
+}
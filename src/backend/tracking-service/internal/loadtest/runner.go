@@ -0,0 +1,181 @@
+package loadtest
+
+import (
+	// context for stopping a run (go1.21)
+	"context"
+	// fmt for formatting errors (go1.21)
+	"fmt"
+	// rand for repeatable walks and drops (go1.21)
+	"math/rand"
+	// sync for collecting results from walkers (go1.21)
+	"sync"
+	// time for pacing walkers (go1.21)
+	"time"
+
+	// models package for Location and IDs
+	"src/backend/tracking-service/internal/models"
+)
+
+// Run replays s against target and reports how the target held up. Walkers
+// are started evenly over the ramp-up and each reports for the scenario's
+// duration, so a run takes RampUp + Duration. Cancelling ctx stops the run
+// early; the report then covers the requests made so far and ctx's error is
+// returned with it.
+func Run(ctx context.Context, s Scenario, target Target) (*Report, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	if target.BaseURL == "" {
+		return nil, fmt.Errorf("load test requires a target URL")
+	}
+	s = s.withDefaults()
+
+	rec := newRecorder()
+	startedAt := time.Now().UTC()
+	var wg sync.WaitGroup
+	for i := 0; i < s.Sessions; i++ {
+		delay := time.Duration(int64(s.RampUp) * int64(i) / int64(s.Sessions))
+		w := &walker{
+			scenario: s,
+			rng:      rand.New(rand.NewSource(s.Seed + int64(i))),
+			device:   newDevice(target),
+			rec:      rec,
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			w.run(ctx)
+		}()
+	}
+	wg.Wait()
+
+	report := rec.report(s, target.BaseURL, startedAt, time.Now().UTC())
+	return report, ctx.Err()
+}
+
+// walker is one simulated device walking for the length of a scenario.
+type walker struct {
+	scenario Scenario
+	rng      *rand.Rand
+	device   *device
+	rec      *recorder
+
+	// pending holds the points generated but not yet sent.
+	pending []*models.Location
+}
+
+// run starts the walker's session and reports its points until the scenario
+// ends or ctx is cancelled, then sends whatever it still holds.
+func (w *walker) run(ctx context.Context) {
+	walkID := models.NewID()
+	res := w.device.startSession(ctx, w.scenario, walkID)
+	w.rec.record(res, 0)
+	if res.failed() {
+		return
+	}
+
+	s := w.scenario
+	interval := time.Duration(float64(time.Second) / s.Rate)
+	tr := newTrack(w.rng, s, walkID)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	start := time.Now()
+	end := start.Add(time.Duration(s.Duration))
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if now.After(end) {
+				w.flush(ctx)
+				return
+			}
+			loc := tr.next(interval, now)
+			w.rec.generated(1)
+			offline := w.inOutage(now.Sub(start))
+			switch {
+			case offline && !s.Drops.Buffer:
+				w.rec.dropped(1)
+				continue
+			case !offline && w.rng.Float64() < s.Drops.Probability:
+				w.rec.dropped(1)
+				continue
+			}
+			w.pending = append(w.pending, loc)
+			if !offline && len(w.pending) >= s.BatchSize {
+				w.flush(ctx)
+			}
+		}
+	}
+}
+
+// inOutage reports whether the walker is offline elapsed into its walk.
+// Outages fall at the end of each OutageEvery interval.
+func (w *walker) inOutage(elapsed time.Duration) bool {
+	every, length := time.Duration(w.scenario.Drops.OutageEvery), time.Duration(w.scenario.Drops.OutageLength)
+	if every <= 0 || length <= 0 {
+		return false
+	}
+	return elapsed%every >= every-length
+}
+
+// flush sends the pending points: one request each without batching, or as
+// sequenced uploads of at most BatchSize points.
+func (w *walker) flush(ctx context.Context) {
+	for len(w.pending) > 0 && ctx.Err() == nil {
+		if w.scenario.BatchSize <= 1 {
+			w.rec.record(w.device.sendLocation(ctx, w.pending[0]), 1)
+			w.pending = w.pending[1:]
+			continue
+		}
+		n := w.scenario.BatchSize
+		if n > len(w.pending) {
+			n = len(w.pending)
+		}
+		w.rec.record(w.device.sendUpload(ctx, w.pending[:n]), n)
+		w.pending = w.pending[n:]
+	}
+}
+
+// recorder collects the results of every walker in a run.
+type recorder struct {
+	mu      sync.Mutex
+	results map[string][]result
+	points  PointCounts
+}
+
+// newRecorder creates an empty recorder.
+func newRecorder() *recorder {
+	return &recorder{results: make(map[string][]result)}
+}
+
+// record adds a request's result, which carried points points.
+func (r *recorder) record(res result, points int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results[res.op] = append(r.results[res.op], res)
+	r.points.Sent += points
+	if !res.failed() {
+		r.points.Accepted += points
+	}
+}
+
+// generated counts n points generated by walkers.
+func (r *recorder) generated(n int) {
+	r.mu.Lock()
+	r.points.Generated += n
+	r.mu.Unlock()
+}
+
+// dropped counts n points lost before they were sent.
+func (r *recorder) dropped(n int) {
+	r.mu.Lock()
+	r.points.Dropped += n
+	r.mu.Unlock()
+}
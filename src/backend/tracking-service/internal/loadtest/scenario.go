@@ -0,0 +1,217 @@
+// Package loadtest replays load test scenarios against a running tracking
+// service: simulated walkers start sessions and report their positions over
+// the HTTP API at a set rate, losing points the way real networks do, while
+// the latency and outcome of every request are recorded into a report that
+// can be compared between releases.
+package loadtest
+
+import (
+	// json for scenario files and durations (go1.21)
+	"encoding/json"
+	// errors for scenario validation (go1.21)
+	"errors"
+	// fmt for formatting validation errors (go1.21)
+	"fmt"
+	// os for reading scenario files (go1.21)
+	"os"
+	// sort for listing built-in scenarios (go1.21)
+	"sort"
+	// time for scenario timing (go1.21)
+	"time"
+)
+
+// Duration is a time.Duration that reads and writes JSON as a string such as
+// "90s" or "5m".
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"30s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Scenario is one replayable load test: Sessions simulated walkers, started
+// evenly over RampUp, each reporting Rate points per second for Duration.
+// With BatchSize above 1 points are sent as sequenced uploads of that many
+// points instead of one POST /location each.
+type Scenario struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Sessions    int      `json:"sessions"`
+	Rate        float64  `json:"rate"`
+	Duration    Duration `json:"duration"`
+	RampUp      Duration `json:"rampUp,omitempty"`
+	BatchSize   int      `json:"batchSize,omitempty"`
+
+	// Origin is where the simulated walks start, spread over OriginRadius
+	// meters; defaults to central London with a 2 km spread.
+	OriginLatitude  float64 `json:"originLatitude,omitempty"`
+	OriginLongitude float64 `json:"originLongitude,omitempty"`
+	OriginRadius    float64 `json:"originRadius,omitempty"`
+
+	// TenantID is sent with every session start when set.
+	TenantID string `json:"tenantId,omitempty"`
+
+	Drops DropPattern `json:"drops,omitempty"`
+
+	// Seed makes the tracks and drops of a scenario repeatable; runs with the
+	// same seed walk the same routes and lose the same points.
+	Seed int64 `json:"seed,omitempty"`
+
+	Thresholds Thresholds `json:"thresholds,omitempty"`
+}
+
+// DropPattern describes the points lost between the device and the service.
+// Each point is lost with probability Probability, and every OutageEvery the
+// walker loses connectivity for OutageLength. Points generated during an
+// outage are lost too, unless Buffer is set, in which case they are sent
+// together when it ends, like a device flushing its offline buffer.
+type DropPattern struct {
+	Probability  float64  `json:"probability,omitempty"`
+	OutageEvery  Duration `json:"outageEvery,omitempty"`
+	OutageLength Duration `json:"outageLength,omitempty"`
+	Buffer       bool     `json:"buffer,omitempty"`
+}
+
+// Thresholds are the limits a run must stay within to pass; zero values are
+// not checked. Latency thresholds apply to every operation.
+type Thresholds struct {
+	P95       Duration `json:"p95,omitempty"`
+	P99       Duration `json:"p99,omitempty"`
+	ErrorRate float64  `json:"errorRate,omitempty"`
+}
+
+// defaultOrigin is where walks start when a scenario sets no origin.
+const (
+	defaultOriginLatitude  = 51.5074
+	defaultOriginLongitude = -0.1278
+	defaultOriginRadius    = 2000
+)
+
+// maxBatchSize is the most points the service accepts in one upload.
+const maxBatchSize = 100
+
+// withDefaults returns s with unset optional fields defaulted.
+func (s Scenario) withDefaults() Scenario {
+	if s.OriginLatitude == 0 && s.OriginLongitude == 0 {
+		s.OriginLatitude, s.OriginLongitude = defaultOriginLatitude, defaultOriginLongitude
+	}
+	if s.OriginRadius == 0 {
+		s.OriginRadius = defaultOriginRadius
+	}
+	if s.BatchSize == 0 {
+		s.BatchSize = 1
+	}
+	if s.Seed == 0 {
+		s.Seed = 1
+	}
+	return s
+}
+
+// Validate reports the first problem that keeps s from running.
+func (s Scenario) Validate() error {
+	switch {
+	case s.Name == "":
+		return errors.New("scenario name is required")
+	case s.Sessions < 1:
+		return fmt.Errorf("scenario %s: sessions must be at least 1", s.Name)
+	case s.Rate <= 0:
+		return fmt.Errorf("scenario %s: rate must be positive", s.Name)
+	case s.Duration <= 0:
+		return fmt.Errorf("scenario %s: duration must be positive", s.Name)
+	case s.RampUp < 0:
+		return fmt.Errorf("scenario %s: ramp-up must not be negative", s.Name)
+	case s.BatchSize < 0 || s.BatchSize > maxBatchSize:
+		return fmt.Errorf("scenario %s: batch size must be in [0, %d]", s.Name, maxBatchSize)
+	case s.Drops.Probability < 0 || s.Drops.Probability >= 1:
+		return fmt.Errorf("scenario %s: drop probability must be in [0, 1)", s.Name)
+	case s.Drops.OutageLength > 0 && s.Drops.OutageEvery <= s.Drops.OutageLength:
+		return fmt.Errorf("scenario %s: outages must be shorter than the interval between them", s.Name)
+	case s.Thresholds.ErrorRate < 0 || s.Thresholds.ErrorRate > 1:
+		return fmt.Errorf("scenario %s: error rate threshold must be in [0, 1]", s.Name)
+	}
+	return nil
+}
+
+// Builtin are the scenarios run before each release, by name.
+var Builtin = map[string]Scenario{
+	"smoke": {
+		Name:        "smoke",
+		Description: "A handful of walkers, to check a deployment end to end.",
+		Sessions:    5,
+		Rate:        1,
+		Duration:    Duration(time.Minute),
+		Thresholds:  Thresholds{P95: Duration(250 * time.Millisecond), ErrorRate: 0.01},
+	},
+	"peak": {
+		Name:        "peak",
+		Description: "Weekday lunchtime peak: many walkers reporting every two seconds.",
+		Sessions:    2000,
+		Rate:        0.5,
+		Duration:    Duration(10 * time.Minute),
+		RampUp:      Duration(2 * time.Minute),
+		Thresholds:  Thresholds{P95: Duration(300 * time.Millisecond), P99: Duration(time.Second), ErrorRate: 0.005},
+	},
+	"flaky-network": {
+		Name:        "flaky-network",
+		Description: "Walkers on poor mobile coverage, uploading buffered batches after outages.",
+		Sessions:    500,
+		Rate:        1,
+		Duration:    Duration(10 * time.Minute),
+		RampUp:      Duration(time.Minute),
+		BatchSize:   10,
+		Drops: DropPattern{
+			Probability:  0.05,
+			OutageEvery:  Duration(2 * time.Minute),
+			OutageLength: Duration(20 * time.Second),
+			Buffer:       true,
+		},
+		Thresholds: Thresholds{P95: Duration(500 * time.Millisecond), ErrorRate: 0.01},
+	},
+}
+
+// BuiltinNames returns the names of the built-in scenarios, sorted.
+func BuiltinNames() []string {
+	names := make([]string, 0, len(Builtin))
+	for name := range Builtin {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadScenarios reads the scenarios in the JSON file at path, which holds
+// either one scenario or a list of them, and validates them.
+func LoadScenarios(path string) ([]Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario file: %w", err)
+	}
+	var scenarios []Scenario
+	if err := json.Unmarshal(data, &scenarios); err != nil {
+		var single Scenario
+		if singleErr := json.Unmarshal(data, &single); singleErr != nil {
+			return nil, fmt.Errorf("invalid scenario file %s: %w", path, singleErr)
+		}
+		scenarios = []Scenario{single}
+	}
+	for _, s := range scenarios {
+		if err := s.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return scenarios, nil
+}
@@ -0,0 +1,207 @@
+package loadtest
+
+import (
+	// bytes for request bodies (go1.21)
+	"bytes"
+	// context for cancelling in-flight requests (go1.21)
+	"context"
+	// json for request and response bodies (go1.21)
+	"encoding/json"
+	// fmt for formatting errors (go1.21)
+	"fmt"
+	// io for draining response bodies (go1.21)
+	"io"
+	// math for the random walk geometry (go1.21)
+	"math"
+	// rand for repeatable walks and drops (go1.21)
+	"math/rand"
+	// http for calling the target (go1.21)
+	"net/http"
+	// cookiejar for per-walker affinity cookies (go1.21)
+	"net/http/cookiejar"
+	// strings for joining the target URL (go1.21)
+	"strings"
+	// time for timestamps and request latency (go1.21)
+	"time"
+
+	// models package for Location and IDs
+	"src/backend/tracking-service/internal/models"
+)
+
+// Operations recorded in reports.
+const (
+	OpStartSession = "start_session"
+	OpLocation     = "location"
+	OpUpload       = "upload"
+)
+
+// earthRadiusMeters is used to turn walked meters into degrees.
+const earthRadiusMeters = 6371000.0
+
+// walkSpeedMetersPerSecond is the average pace of a simulated walk.
+const walkSpeedMetersPerSecond = 1.4
+
+// track generates the points of one simulated walk: a random walk at walking
+// pace with gently changing heading and a realistic spread of GPS accuracy.
+type track struct {
+	rng     *rand.Rand
+	walkID  string
+	lat     float64
+	lng     float64
+	heading float64
+}
+
+// newTrack starts a walk at a random point within radius meters of the
+// scenario's origin.
+func newTrack(rng *rand.Rand, s Scenario, walkID string) *track {
+	t := &track{rng: rng, walkID: walkID, lat: s.OriginLatitude, lng: s.OriginLongitude}
+	t.move(rng.Float64()*s.OriginRadius, rng.Float64()*2*math.Pi)
+	t.heading = rng.Float64() * 2 * math.Pi
+	return t
+}
+
+// move displaces the track by meters along heading (radians from north).
+func (t *track) move(meters, heading float64) {
+	dLat := meters * math.Cos(heading) / earthRadiusMeters
+	dLng := meters * math.Sin(heading) / (earthRadiusMeters * math.Cos(t.lat*math.Pi/180))
+	t.lat += dLat * 180 / math.Pi
+	t.lng += dLng * 180 / math.Pi
+}
+
+// next advances the walk by elapsed and returns the point reported at at.
+func (t *track) next(elapsed time.Duration, at time.Time) *models.Location {
+	t.heading += t.rng.NormFloat64() * 0.3
+	speed := walkSpeedMetersPerSecond * (0.8 + 0.4*t.rng.Float64())
+	t.move(speed*elapsed.Seconds(), t.heading)
+	return &models.Location{
+		ID:        models.NewID(),
+		WalkID:    t.walkID,
+		Latitude:  t.lat,
+		Longitude: t.lng,
+		Accuracy:  3 + math.Abs(t.rng.NormFloat64())*5,
+		Altitude:  20 + t.rng.NormFloat64(),
+		Timestamp: at.UTC(),
+		Provider:  "gps",
+	}
+}
+
+// Target is the tracking service a scenario runs against.
+type Target struct {
+	// BaseURL is the service's root, such as https://tracking.staging.example.com.
+	BaseURL string
+	// Token is sent as the Authorization header of every request.
+	Token string
+	// Timeout bounds each request; 10s when zero.
+	Timeout time.Duration
+}
+
+// result is the outcome of one request.
+type result struct {
+	op      string
+	latency time.Duration
+	status  int
+	err     error
+}
+
+// failed reports whether the request counts as an error: a transport failure
+// or a status outside 2xx.
+func (r result) failed() bool {
+	return r.err != nil || r.status < 200 || r.status >= 300
+}
+
+// device is one simulated walker's connection to the target. Each has its own
+// cookie jar, so session affinity cookies set at session start route its later
+// requests like a real device's.
+type device struct {
+	target     Target
+	httpClient *http.Client
+	sessionID  string
+	uploadSeq  uint64
+}
+
+// newDevice creates a device talking to target.
+func newDevice(target Target) *device {
+	timeout := target.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	jar, _ := cookiejar.New(nil)
+	return &device{target: target, httpClient: &http.Client{Timeout: timeout, Jar: jar}}
+}
+
+// startSession starts the walk's session and remembers its ID.
+func (d *device) startSession(ctx context.Context, s Scenario, walkID string) result {
+	body := map[string]string{
+		"walkId":   walkID,
+		"walkerId": models.NewID(),
+		"dogId":    models.NewID(),
+		"tenantId": s.TenantID,
+		"deviceId": models.NewID(),
+	}
+	var out struct {
+		Session struct {
+			ID string `json:"id"`
+		} `json:"session"`
+	}
+	res := d.post(ctx, OpStartSession, "/sessions", "", body, &out)
+	if !res.failed() {
+		if out.Session.ID == "" {
+			res.err = fmt.Errorf("session start response carries no session ID")
+		}
+		d.sessionID = out.Session.ID
+	}
+	return res
+}
+
+// sendLocation posts a single point.
+func (d *device) sendLocation(ctx context.Context, loc *models.Location) result {
+	return d.post(ctx, OpLocation, "/location", d.sessionID, loc, nil)
+}
+
+// sendUpload posts points as the session's next sequenced upload.
+func (d *device) sendUpload(ctx context.Context, locs []*models.Location) result {
+	d.uploadSeq++
+	body := map[string]interface{}{
+		"sessionId": d.sessionID,
+		"uploadSeq": d.uploadSeq,
+		"locations": locs,
+	}
+	return d.post(ctx, OpUpload, "/sessions/"+d.sessionID+"/uploads", d.sessionID, body, nil)
+}
+
+// post sends body as JSON to path and times the round trip, decoding a
+// successful response into out when it is non-nil.
+func (d *device) post(ctx context.Context, op, path, sessionID string, body, out interface{}) result {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return result{op: op, err: err}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(d.target.BaseURL, "/")+path, bytes.NewReader(payload))
+	if err != nil {
+		return result{op: op, err: err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if d.target.Token != "" {
+		req.Header.Set("Authorization", d.target.Token)
+	}
+	if sessionID != "" {
+		req.Header.Set("X-Session-ID", sessionID)
+	}
+
+	start := time.Now()
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return result{op: op, latency: time.Since(start), err: err}
+	}
+	defer resp.Body.Close()
+	res := result{op: op, status: resp.StatusCode}
+	if out != nil && resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			res.err = fmt.Errorf("invalid %s response: %w", op, err)
+		}
+	} else {
+		_, _ = io.Copy(io.Discard, resp.Body)
+	}
+	res.latency = time.Since(start)
+	return res
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	// time for access timestamps (go1.21)
+	"time"
+)
+
+// Walk access log listing limits.
+const (
+	DefaultWalkAccessLogLimit = 100
+	MaxWalkAccessLogLimit     = 1000
+)
+
+// WalkAccessRecord is an entry of a walk's access log, recorded for every
+// read of the walk's location data so its owner can see who looked at it.
+type WalkAccessRecord struct {
+	ID string `json:"id"`
+
+	// WalkID is the walk read, and SessionID the tracking session named by
+	// the request, when it named one.
+	WalkID    string `json:"walkId"`
+	SessionID string `json:"sessionId,omitempty"`
+
+	// Actor identifies who read the data, and Endpoint the method and route
+	// of the read, e.g. "GET /location/history".
+	Actor    string `json:"actor"`
+	Endpoint string `json:"endpoint"`
+
+	RequestID  string    `json:"requestId,omitempty"`
+	AccessedAt time.Time `json:"accessedAt"`
+}
@@ -0,0 +1,173 @@
+package models
+
+import (
+	// errors for beacon validation failures (go1.21)
+	"errors"
+	// sort for ordering sightings and timeline entries (go1.21)
+	"sort"
+	// time for sighting timestamps and indoor periods (go1.21)
+	"time"
+)
+
+// Bounds of a beacon sighting's received signal strength, in dBm.
+const (
+	MinBeaconRSSI = -127
+	MaxBeaconRSSI = 0
+)
+
+// Timeline entry kinds: a GPS fix, a period of indoor presence near a beacon,
+// or a stretch with neither longer than the tracking gap threshold.
+const (
+	TimelineEntryLocation = "location"
+	TimelineEntryIndoor   = "indoor"
+	TimelineEntryGap      = "gap"
+)
+
+// BeaconEvent is a sighting of a Bluetooth beacon by the walker's device,
+// used for indoor presence at facilities where GPS is unavailable.
+type BeaconEvent struct {
+	SessionID string `json:"sessionId"`
+	BeaconID  string `json:"beaconId"`
+
+	// RSSI is the received signal strength in dBm, between MinBeaconRSSI and
+	// MaxBeaconRSSI; stronger (closer to zero) means nearer the beacon.
+	RSSI int `json:"rssi"`
+
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Validate checks the beacon ID, signal strength and timestamp of a sighting.
+func (e *BeaconEvent) Validate() error {
+	if e.BeaconID == "" {
+		return errors.New("beaconId cannot be empty")
+	}
+	if e.RSSI < MinBeaconRSSI || e.RSSI > MaxBeaconRSSI {
+		return ErrOutOfRange("RSSI is out of valid range")
+	}
+	if e.Timestamp.IsZero() {
+		return ErrInvalidTimestamp("Timestamp cannot be zero")
+	}
+	if e.Timestamp.After(time.Now().UTC().Add(1 * time.Minute)) {
+		return ErrInvalidTimestamp("Timestamp is too far in the future")
+	}
+	return nil
+}
+
+// IndoorPeriod is a run of sightings of one beacon, none further apart than
+// the tracking gap threshold.
+type IndoorPeriod struct {
+	BeaconID  string    `json:"beaconId"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Sightings int       `json:"sightings"`
+
+	// MaxRSSI is the strongest signal seen during the period, in dBm.
+	MaxRSSI int `json:"maxRssi"`
+}
+
+// TimelineEntry is one step of a session timeline. Location entries carry
+// the fix and start and end at its timestamp; indoor entries carry the
+// period; gap entries carry neither.
+type TimelineEntry struct {
+	Kind     string        `json:"kind"`
+	Start    time.Time     `json:"start"`
+	End      time.Time     `json:"end"`
+	Location *Location     `json:"location,omitempty"`
+	Indoor   *IndoorPeriod `json:"indoor,omitempty"`
+}
+
+// SessionTimeline is a session's GPS track merged with its indoor periods in
+// time order, with the gaps in which the walker was seen by neither.
+type SessionTimeline struct {
+	SessionID string          `json:"sessionId"`
+	WalkID    string          `json:"walkId"`
+	Entries   []TimelineEntry `json:"entries"`
+
+	// HasGaps reports whether Entries contains any gap.
+	HasGaps bool `json:"hasGaps"`
+}
+
+// IndoorPeriods groups beacon sightings into indoor periods, ordered by
+// start. A sighting extends its beacon's current period when it follows the
+// previous sighting within the tracking gap threshold, and starts a new one
+// otherwise; periods of different beacons may overlap.
+func IndoorPeriods(events []BeaconEvent) []IndoorPeriod {
+	sorted := append([]BeaconEvent(nil), events...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	var periods []IndoorPeriod
+	open := make(map[string]int)
+	for _, ev := range sorted {
+		if idx, ok := open[ev.BeaconID]; ok && ev.Timestamp.Sub(periods[idx].End) <= locationGapThreshold {
+			p := &periods[idx]
+			p.End = ev.Timestamp
+			p.Sightings++
+			if ev.RSSI > p.MaxRSSI {
+				p.MaxRSSI = ev.RSSI
+			}
+			continue
+		}
+		open[ev.BeaconID] = len(periods)
+		periods = append(periods, IndoorPeriod{
+			BeaconID:  ev.BeaconID,
+			Start:     ev.Timestamp,
+			End:       ev.Timestamp,
+			Sightings: 1,
+			MaxRSSI:   ev.RSSI,
+		})
+	}
+	return periods
+}
+
+// BuildSessionTimeline merges a session's track and beacon sightings into
+// its timeline. A stretch longer than the tracking gap threshold covered by
+// neither a fix nor an indoor period becomes a gap entry, so time spent
+// indoors near a beacon is not reported as lost GPS.
+func BuildSessionTimeline(sessionID, walkID string, track []Location, events []BeaconEvent) *SessionTimeline {
+	periods := IndoorPeriods(events)
+	entries := make([]TimelineEntry, 0, len(track)+len(periods))
+	for i := range track {
+		loc := track[i]
+		entries = append(entries, TimelineEntry{
+			Kind:     TimelineEntryLocation,
+			Start:    loc.Timestamp,
+			End:      loc.Timestamp,
+			Location: &loc,
+		})
+	}
+	for i := range periods {
+		entries = append(entries, TimelineEntry{
+			Kind:   TimelineEntryIndoor,
+			Start:  periods[i].Start,
+			End:    periods[i].End,
+			Indoor: &periods[i],
+		})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Start.Before(entries[j].Start)
+	})
+
+	timeline := &SessionTimeline{
+		SessionID: sessionID,
+		WalkID:    walkID,
+		Entries:   make([]TimelineEntry, 0, len(entries)),
+	}
+	var covered time.Time
+	for _, entry := range entries {
+		if !covered.IsZero() && entry.Start.Sub(covered) > locationGapThreshold {
+			timeline.Entries = append(timeline.Entries, TimelineEntry{
+				Kind:  TimelineEntryGap,
+				Start: covered,
+				End:   entry.Start,
+			})
+			timeline.HasGaps = true
+		}
+		timeline.Entries = append(timeline.Entries, entry)
+		if entry.End.After(covered) {
+			covered = entry.End
+		}
+	}
+	return timeline
+}
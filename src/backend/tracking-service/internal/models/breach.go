@@ -0,0 +1,57 @@
+package models
+
+import (
+	// time for breach timestamps (go1.21)
+	"time"
+)
+
+// BreachSample is one point of a geofence breach.
+type BreachSample struct {
+	Timestamp time.Time `json:"timestamp"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+
+	// DistanceMeters is how far past the boundary the point lies: outside an
+	// inclusion zone, or inside an exclusion zone.
+	DistanceMeters float64 `json:"distanceMeters"`
+}
+
+// GeofenceBreach is one continuous violation of a geofence during a walk, from
+// the first point in violation to the first point back in compliance.
+type GeofenceBreach struct {
+	GeofenceID string `json:"geofenceId"`
+	EventType  string `json:"eventType"`
+	Mode       string `json:"mode"`
+	Severity   string `json:"severity"`
+
+	StartedAt time.Time `json:"startedAt"`
+
+	// EndedAt is the time of the point after the breach, or of the breach's
+	// last point when the track ends in violation.
+	EndedAt         time.Time `json:"endedAt"`
+	DurationSeconds float64   `json:"durationSeconds"`
+
+	// MaxDistanceMeters is the farthest any sample lies past the boundary.
+	MaxDistanceMeters float64 `json:"maxDistanceMeters"`
+
+	// Before is the point immediately before the breach, nil when the track
+	// starts in violation; After is the point immediately after it, nil when
+	// the track ends in violation.
+	Before *Location `json:"before,omitempty"`
+	After  *Location `json:"after,omitempty"`
+
+	// Samples are the points in violation, in time order.
+	Samples []BreachSample `json:"samples"`
+}
+
+// BreachReview lists every geofence breach of a session's walk, for incident
+// reviews.
+type BreachReview struct {
+	SessionID string           `json:"sessionId"`
+	WalkID    string           `json:"walkId"`
+	Breaches  []GeofenceBreach `json:"breaches"`
+
+	// TotalOutsideSeconds is the time spent in breach of any geofence, with
+	// overlapping breaches of different geofences counted once.
+	TotalOutsideSeconds float64 `json:"totalOutsideSeconds"`
+}
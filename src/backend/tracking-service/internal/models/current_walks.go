@@ -0,0 +1,63 @@
+package models
+
+import (
+	// time for walk start and change timestamps (go1.21)
+	"time"
+)
+
+// Current walk changes: a session started, its status changed while it kept
+// running, or it ended and left the current walks.
+const (
+	CurrentWalkStarted = "started"
+	CurrentWalkStatus  = "status"
+	CurrentWalkEnded   = "ended"
+)
+
+// CurrentWalk is a running session as kept in the current walks table, so
+// dashboards can list live walks without scanning tracking sessions.
+type CurrentWalk struct {
+	SessionID string `json:"sessionId"`
+	WalkID    string `json:"walkId"`
+	WalkerID  string `json:"walkerId"`
+	DogID     string `json:"dogId"`
+	TenantID  string `json:"tenantId,omitempty"`
+	Status    string `json:"status"`
+
+	StartedAt time.Time `json:"startedAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// NewCurrentWalk returns the current walk entry of a session as it is now.
+func NewCurrentWalk(s *TrackingSession) CurrentWalk {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return CurrentWalk{
+		SessionID: s.ID,
+		WalkID:    s.walkID,
+		WalkerID:  s.walkerID,
+		DogID:     s.dogID,
+		TenantID:  s.tenantID,
+		Status:    s.status,
+		StartedAt: s.startTime,
+		UpdatedAt: time.Now().UTC(),
+	}
+}
+
+// CurrentWalkChange is an entry of the current walks change feed. Seq
+// increases with every change, so a subscriber resumes after the last Seq it
+// processed.
+type CurrentWalkChange struct {
+	Seq       int64       `json:"seq"`
+	Change    string      `json:"change"`
+	Walk      CurrentWalk `json:"walk"`
+	ChangedAt time.Time   `json:"changedAt"`
+}
+
+// CurrentWalkFeed is a page of the change feed. NextSeq is the cursor to pass
+// for the following page: the last change's Seq, or the requested one when
+// there were no new changes.
+type CurrentWalkFeed struct {
+	Changes []CurrentWalkChange `json:"changes"`
+	NextSeq int64               `json:"nextSeq"`
+}
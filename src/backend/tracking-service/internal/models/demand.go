@@ -0,0 +1,51 @@
+package models
+
+import (
+	// time for demand windows (go1.21)
+	"time"
+)
+
+// DemandBucket is the number of walks started in one demand grid cell at one
+// hour of the day, local to the query's time zone, over the query's window.
+type DemandBucket struct {
+	// Cell is the demand grid cell key, and Latitude/Longitude its center.
+	Cell      string  `json:"cell"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+
+	// Hour is the hour of the day, from 0 to 23.
+	Hour   int `json:"hour"`
+	Starts int `json:"starts"`
+
+	// Intensity is Starts relative to the busiest bucket in the same heatmap,
+	// from 0 to 1.
+	Intensity float64 `json:"intensity"`
+}
+
+// DemandHour is the number of walks started at one hour of the day across the
+// buckets of a heatmap.
+type DemandHour struct {
+	Hour      int     `json:"hour"`
+	Starts    int     `json:"starts"`
+	Intensity float64 `json:"intensity"`
+}
+
+// DemandHeatmap is where and when walks started in an area over [From, To),
+// for suggesting to walkers where and when demand is highest. Buckets with
+// fewer than MinStarts walk starts are withheld, and left out of Hours, so no
+// individual walk can be picked out.
+type DemandHeatmap struct {
+	Area      BoundingBox `json:"area"`
+	From      time.Time   `json:"from"`
+	To        time.Time   `json:"to"`
+	Timezone  string      `json:"timezone"`
+	MinStarts int         `json:"minStarts"`
+
+	// Buckets are busiest first, and Hours covers every hour of the day.
+	Buckets []DemandBucket `json:"buckets"`
+	Hours   []DemandHour   `json:"hours"`
+
+	// PeakHour is the hour of the day with the most starts, or -1 when every
+	// bucket was withheld.
+	PeakHour int `json:"peakHour"`
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	// errors for device pairing validation failures (go1.21)
+	"errors"
+	// time for pairing and revocation timestamps (go1.21)
+	"time"
+)
+
+// Device policies, set per tenant, deciding what happens to sessions started
+// from a device that is not trusted.
+const (
+	// DevicePolicyAllow starts them normally, recording the device's trust.
+	DevicePolicyAllow = "allow"
+	// DevicePolicyFlag starts them, recording the device's trust and logging
+	// a warning.
+	DevicePolicyFlag = "flag"
+	// DevicePolicyReject refuses to start them.
+	DevicePolicyReject = "reject"
+)
+
+// Device trust levels recorded on sessions.
+const (
+	// DeviceTrusted is a device paired by the session's walker and not revoked.
+	DeviceTrusted = "trusted"
+	// DeviceUntrusted is a device the walker paired and later revoked.
+	DeviceUntrusted = "untrusted"
+	// DeviceUnknown is a device the walker never paired, or a session started
+	// without naming its device.
+	DeviceUnknown = "unknown"
+)
+
+// maxDeviceFieldLength bounds the device ID, name and platform of a pairing.
+const maxDeviceFieldLength = 128
+
+// Device is a device a walker paired to report their walks from.
+type Device struct {
+	DeviceID string    `json:"deviceId"`
+	WalkerID string    `json:"walkerId"`
+	Name     string    `json:"name,omitempty"`
+	Platform string    `json:"platform"`
+	PairedAt time.Time `json:"pairedAt"`
+
+	// RevokedAt is when the walker revoked the device; nil while it is trusted.
+	RevokedAt *time.Time `json:"revokedAt,omitempty"`
+}
+
+// Trust returns DeviceTrusted, or DeviceUntrusted once the device is revoked.
+func (d *Device) Trust() string {
+	if d.RevokedAt != nil {
+		return DeviceUntrusted
+	}
+	return DeviceTrusted
+}
+
+// DevicePairing is a walker app's request to pair the device it runs on.
+type DevicePairing struct {
+	DeviceID string `json:"deviceId"`
+	Name     string `json:"name,omitempty"`
+	Platform string `json:"platform"`
+
+	// Attestation is the app's signature over the walker, device ID and
+	// platform, proving the request comes from a genuine build of the app.
+	Attestation string `json:"attestation"`
+}
+
+// Validate checks that the pairing names its device and platform and carries
+// an attestation.
+func (p *DevicePairing) Validate() error {
+	if p.DeviceID == "" || p.Platform == "" {
+		return errors.New("deviceId and platform are required")
+	}
+	if len(p.DeviceID) > maxDeviceFieldLength || len(p.Name) > maxDeviceFieldLength || len(p.Platform) > maxDeviceFieldLength {
+		return errors.New("deviceId, name and platform must be at most 128 characters")
+	}
+	if p.Attestation == "" {
+		return errors.New("attestation is required")
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package models
+
+// DistanceCalculator measures the distance in meters between two points given
+// as latitude and longitude in degrees.
+type DistanceCalculator interface {
+	Distance(lat1, lon1, lat2, lon2 float64) float64
+}
+
+// HaversineDistance is the spherical DistanceCalculator used for live session
+// updates. It is fast but errs by up to about 0.5% on the ellipsoid, so
+// summary recomputation may use a geodesic calculator instead.
+type HaversineDistance struct{}
+
+// Distance implements DistanceCalculator.
+func (HaversineDistance) Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	return distanceBetweenPoints(lat1, lon1, lat2, lon2)
+}
@@ -0,0 +1,11 @@
+package models
+
+// DogProfile is the compact profile of a dog, from the pets service, that
+// streams and walk summaries carry so subscribers can show who is being walked
+// without calling the pets service themselves.
+type DogProfile struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Breed    string `json:"breed,omitempty"`
+	PhotoURL string `json:"photoUrl,omitempty"`
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	// errors for escrow request validation failures (go1.21)
+	"errors"
+	// time for escrow and access timestamps (go1.21)
+	"time"
+)
+
+// Escrow access outcomes recorded in the escrow audit log.
+const (
+	// EscrowAccessGranted is an access that released the walk's keys.
+	EscrowAccessGranted = "granted"
+	// EscrowAccessDenied is an access whose custodian shares were incomplete
+	// or did not reconstruct the escrow key.
+	EscrowAccessDenied = "denied"
+	// EscrowAccessNotFound is an access to a walk with no escrowed keys.
+	EscrowAccessNotFound = "not_found"
+	// EscrowAccessFailed is an access whose keys could not be unwrapped.
+	EscrowAccessFailed = "failed"
+)
+
+// Escrow audit log listing limits.
+const (
+	DefaultEscrowAuditLimit = 100
+	MaxEscrowAuditLimit     = 1000
+)
+
+// EscrowedStreamKey is a session stream key wrapped under the escrow public
+// key: an X25519 exchange with the ephemeral key derives the key sealing
+// WrappedKey, with the session and key IDs as additional data.
+type EscrowedStreamKey struct {
+	SessionID    string    `json:"sessionId"`
+	TenantID     string    `json:"tenantId,omitempty"`
+	KeyID        string    `json:"keyId"`
+	EphemeralKey []byte    `json:"ephemeralKey"`
+	Nonce        []byte    `json:"nonce"`
+	WrappedKey   []byte    `json:"wrappedKey"`
+	IssuedAt     time.Time `json:"issuedAt"`
+}
+
+// EscrowShare is one custodian's share of the escrow private key,
+// base64-encoded.
+type EscrowShare struct {
+	Custodian string `json:"custodian"`
+	Share     string `json:"share"`
+}
+
+// EscrowAccessRequest asks for the stream keys of a walk under a legal
+// request. Every custodian must contribute their share.
+type EscrowAccessRequest struct {
+	// LegalReference identifies the legal request, e.g. a warrant or court
+	// order number, and RequestedBy the person acting on it. RequestedBy is
+	// the caller's verified identity, never taken from the request body.
+	LegalReference string        `json:"legalReference"`
+	RequestedBy    string        `json:"-"`
+	Shares         []EscrowShare `json:"shares"`
+}
+
+// Validate checks that the request names its legal basis and requester and
+// carries shares.
+func (r *EscrowAccessRequest) Validate() error {
+	if r.LegalReference == "" || r.RequestedBy == "" {
+		return errors.New("legalReference and requestedBy are required")
+	}
+	if len(r.Shares) == 0 {
+		return errors.New("custodian shares are required")
+	}
+	return nil
+}
+
+// EscrowAccessRecord is an entry of the escrow audit log, recorded for every
+// access attempt whatever its outcome.
+type EscrowAccessRecord struct {
+	ID             string `json:"id"`
+	SessionID      string `json:"sessionId"`
+	TenantID       string `json:"tenantId,omitempty"`
+	LegalReference string `json:"legalReference"`
+	RequestedBy    string `json:"requestedBy"`
+
+	// Custodians are the custodians whose shares were presented.
+	Custodians []string `json:"custodians"`
+
+	// Outcome is one of the EscrowAccess outcomes, and KeyCount the number
+	// of stream keys released.
+	Outcome  string `json:"outcome"`
+	KeyCount int    `json:"keyCount"`
+
+	AccessedAt time.Time `json:"accessedAt"`
+}
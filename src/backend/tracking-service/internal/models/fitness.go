@@ -0,0 +1,28 @@
+package models
+
+import (
+	// time for token expiry (go1.21)
+	"time"
+)
+
+// Fitness platforms walks can be uploaded to.
+const (
+	FitnessProviderStrava = "strava"
+	FitnessProviderGarmin = "garmin"
+)
+
+// FitnessToken holds an owner's OAuth tokens for a fitness platform, plus the
+// dogs whose completed walks are uploaded there automatically.
+type FitnessToken struct {
+	OwnerID      string    `json:"ownerId"`
+	Provider     string    `json:"provider"`
+	AccessToken  string    `json:"accessToken"`
+	RefreshToken string    `json:"refreshToken"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	DogIDs       []string  `json:"dogIds"`
+}
+
+// Expired reports whether the access token has expired, or will within margin.
+func (t *FitnessToken) Expired(now time.Time, margin time.Duration) bool {
+	return !t.ExpiresAt.IsZero() && !now.Add(margin).Before(t.ExpiresAt)
+}
@@ -0,0 +1,321 @@
+package models
+
+import (
+	// fmt is used for validation error messages (go1.21)
+	"fmt"
+	// math for finiteness checks and planar geometry (go1.21)
+	"math"
+	// time for record timestamps (go1.21)
+	"time"
+)
+
+// Geofence boundary shapes.
+const (
+	GeofenceShapeCircle  = "circle"
+	GeofenceShapePolygon = "polygon"
+)
+
+// Geofence modes: an inclusion zone is violated by leaving it, such as the
+// park a walker must stay in; an exclusion zone, or no-go zone, is violated by
+// entering it, such as a busy road or an area where dogs must be leashed.
+const (
+	GeofenceModeInclusion = "inclusion"
+	GeofenceModeExclusion = "exclusion"
+)
+
+// Geofence violation severities, set per zone by the tenant defining it.
+const (
+	GeofenceSeverityInfo     = "info"
+	GeofenceSeverityWarning  = "warning"
+	GeofenceSeverityCritical = "critical"
+)
+
+// Geofence violation event types, one per mode, so consumers can tell a
+// walker straying from their area apart from one entering a no-go zone.
+const (
+	GeofenceEventExit           = "geofence_exit"
+	GeofenceEventExclusionEntry = "no_go_zone_entry"
+)
+
+// MaxGeofenceVertices bounds the vertex count of a polygon geofence, keeping
+// self-intersection validation and containment checks cheap.
+const MaxGeofenceVertices = 500
+
+// GeofenceVertex is one corner of a polygon geofence, in WGS84 degrees.
+type GeofenceVertex struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// GeofenceRecord is the persisted form of a geofence. Circles use the center
+// and RadiusKm; polygons use Vertices, counter-clockwise and without repeating
+// the first vertex, with the center set to their centroid.
+type GeofenceRecord struct {
+	ID                 string           `json:"id"`
+	WalkID             string           `json:"walkId"`
+	Shape              string           `json:"shape"`
+	CenterLatitude     float64          `json:"centerLatitude"`
+	CenterLongitude    float64          `json:"centerLongitude"`
+	RadiusKm           float64          `json:"radiusKm,omitempty"`
+	Vertices           []GeofenceVertex `json:"vertices,omitempty"`
+	Active             bool             `json:"active"`
+	BoundaryViolations int              `json:"boundaryViolations"`
+	Mode               string           `json:"mode,omitempty"`
+	Severity           string           `json:"severity,omitempty"`
+	GroupID            string           `json:"groupId,omitempty"`
+	CreatedAt          time.Time        `json:"createdAt"`
+	UpdatedAt          time.Time        `json:"updatedAt"`
+}
+
+// GeofenceGroupRecord is the persisted form of a geofence group: named
+// geofences enforced together on a recurring schedule, such as the school
+// zones near a walker's routes during pickup hours. Schedule is a spec
+// accepted by the geofence schedule parser, evaluated in the IANA TimeZone;
+// an empty schedule enforces the group at all times. Members without a WalkID
+// apply to every walk.
+type GeofenceGroupRecord struct {
+	ID        string           `json:"id"`
+	Name      string           `json:"name"`
+	Schedule  string           `json:"schedule,omitempty"`
+	TimeZone  string           `json:"timeZone,omitempty"`
+	Geofences []GeofenceRecord `json:"geofences"`
+	UpdatedAt time.Time        `json:"updatedAt"`
+}
+
+// GeofenceViolation is the event raised when a point breaches a geofence:
+// EventType is GeofenceEventExit for inclusion zones and
+// GeofenceEventExclusionEntry for exclusion zones.
+type GeofenceViolation struct {
+	EventType  string    `json:"eventType"`
+	GeofenceID string    `json:"geofenceId"`
+	WalkID     string    `json:"walkId"`
+	GroupID    string    `json:"groupId,omitempty"`
+	Mode       string    `json:"mode"`
+	Severity   string    `json:"severity"`
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// NormalizeGeofencePolicy validates a geofence's mode and severity, defaulting
+// an empty mode to inclusion and an empty severity to warning for inclusion
+// zones and critical for exclusion zones.
+func NormalizeGeofencePolicy(mode, severity string) (string, string, error) {
+	switch mode {
+	case "":
+		mode = GeofenceModeInclusion
+	case GeofenceModeInclusion, GeofenceModeExclusion:
+	default:
+		return "", "", fmt.Errorf("geofence mode %q is invalid; must be %q or %q", mode, GeofenceModeInclusion, GeofenceModeExclusion)
+	}
+	switch severity {
+	case "":
+		severity = GeofenceSeverityWarning
+		if mode == GeofenceModeExclusion {
+			severity = GeofenceSeverityCritical
+		}
+	case GeofenceSeverityInfo, GeofenceSeverityWarning, GeofenceSeverityCritical:
+	default:
+		return "", "", fmt.Errorf("geofence severity %q is invalid; must be %q, %q or %q",
+			severity, GeofenceSeverityInfo, GeofenceSeverityWarning, GeofenceSeverityCritical)
+	}
+	return mode, severity, nil
+}
+
+// NormalizePolygon validates a polygon boundary and returns it in canonical
+// form: counter-clockwise, with a closing vertex equal to the first removed.
+// The polygon is treated as planar in latitude/longitude, which is accurate
+// for park- and neighbourhood-sized areas.
+//
+// Steps:
+//  1. Drop the closing vertex, if present, and check the vertex count
+//  2. Check every vertex is finite and in range, and not equal to its successor
+//  3. Reject polygons crossing the antimeridian
+//  4. Reject self-intersecting boundaries and zero area
+//  5. Reverse clockwise boundaries
+func NormalizePolygon(vertices []GeofenceVertex) ([]GeofenceVertex, error) {
+	// 1. Closing vertex and count
+	if n := len(vertices); n > 1 && vertices[0] == vertices[n-1] {
+		vertices = vertices[:n-1]
+	}
+	if len(vertices) < 3 {
+		return nil, fmt.Errorf("polygon needs at least 3 distinct vertices, got %d", len(vertices))
+	}
+	if len(vertices) > MaxGeofenceVertices {
+		return nil, fmt.Errorf("polygon has %d vertices; at most %d are allowed", len(vertices), MaxGeofenceVertices)
+	}
+
+	// 2. Vertex values
+	minLon, maxLon := math.Inf(1), math.Inf(-1)
+	for i, v := range vertices {
+		if math.IsNaN(v.Latitude) || math.IsNaN(v.Longitude) || math.IsInf(v.Latitude, 0) || math.IsInf(v.Longitude, 0) {
+			return nil, fmt.Errorf("polygon vertex %d is not finite", i)
+		}
+		if v.Latitude < MinLatitude || v.Latitude > MaxLatitude || v.Longitude < MinLongitude || v.Longitude > MaxLongitude {
+			return nil, fmt.Errorf("polygon vertex %d (%.6f, %.6f) is out of range", i, v.Latitude, v.Longitude)
+		}
+		if v == vertices[(i+1)%len(vertices)] {
+			return nil, fmt.Errorf("polygon vertex %d repeats the next vertex", i)
+		}
+		minLon = math.Min(minLon, v.Longitude)
+		maxLon = math.Max(maxLon, v.Longitude)
+	}
+
+	// 3. Antimeridian
+	if maxLon-minLon > 180 {
+		return nil, fmt.Errorf("polygon may not cross the antimeridian")
+	}
+
+	// 4. Simplicity and area
+	if i, j, crossed := firstSelfIntersection(vertices); crossed {
+		return nil, fmt.Errorf("polygon edges %d and %d intersect", i, j)
+	}
+	area := signedArea(vertices)
+	if area == 0 {
+		return nil, fmt.Errorf("polygon has zero area")
+	}
+
+	// 5. Winding
+	out := append([]GeofenceVertex(nil), vertices...)
+	if area < 0 {
+		for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+			out[i], out[j] = out[j], out[i]
+		}
+	}
+	return out, nil
+}
+
+// PolygonCentroid returns the area-weighted centroid of a simple polygon.
+func PolygonCentroid(vertices []GeofenceVertex) GeofenceVertex {
+	var cx, cy, a float64
+	for i, v := range vertices {
+		w := vertices[(i+1)%len(vertices)]
+		cross := v.Longitude*w.Latitude - w.Longitude*v.Latitude
+		a += cross
+		cx += (v.Longitude + w.Longitude) * cross
+		cy += (v.Latitude + w.Latitude) * cross
+	}
+	a /= 2
+	return GeofenceVertex{Latitude: cy / (6 * a), Longitude: cx / (6 * a)}
+}
+
+// PolygonContains reports whether the point lies inside the polygon or on its
+// boundary, using even-odd ray casting.
+func PolygonContains(vertices []GeofenceVertex, lat, lon float64) bool {
+	inside := false
+	for i, j := 0, len(vertices)-1; i < len(vertices); j, i = i, i+1 {
+		a, b := vertices[i], vertices[j]
+		if onSegment(a, b, lat, lon) {
+			return true
+		}
+		if (a.Latitude > lat) != (b.Latitude > lat) {
+			crossLon := a.Longitude + (lat-a.Latitude)*(b.Longitude-a.Longitude)/(b.Latitude-a.Latitude)
+			if lon < crossLon {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
+
+// PolygonBoundaryDistance returns the distance in meters from the point to the
+// nearest edge of the polygon, whether the point is inside or outside it. Each
+// edge is measured on a plane tangent at the point, which is accurate for
+// park- and neighbourhood-sized areas.
+func PolygonBoundaryDistance(vertices []GeofenceVertex, lat, lon float64) float64 {
+	const metersPerDegree = 6371000.0 * math.Pi / 180
+	lonScale := math.Cos(lat * math.Pi / 180)
+	project := func(v GeofenceVertex) (float64, float64) {
+		return (v.Longitude - lon) * lonScale * metersPerDegree, (v.Latitude - lat) * metersPerDegree
+	}
+
+	nearest := math.Inf(1)
+	for i, v := range vertices {
+		ax, ay := project(v)
+		bx, by := project(vertices[(i+1)%len(vertices)])
+		// Closest point of edge ab to the origin, which is the point itself.
+		dx, dy := bx-ax, by-ay
+		t := 0.0
+		if lengthSq := dx*dx + dy*dy; lengthSq > 0 {
+			t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/lengthSq))
+		}
+		nearest = math.Min(nearest, math.Hypot(ax+t*dx, ay+t*dy))
+	}
+	return nearest
+}
+
+// signedArea returns twice the signed planar area of the polygon, positive
+// when counter-clockwise with longitude as x and latitude as y.
+func signedArea(vertices []GeofenceVertex) float64 {
+	var sum float64
+	for i, v := range vertices {
+		w := vertices[(i+1)%len(vertices)]
+		sum += v.Longitude*w.Latitude - w.Longitude*v.Latitude
+	}
+	return sum
+}
+
+// firstSelfIntersection returns the first pair of non-adjacent edges that
+// touch or cross, with edge i running from vertex i to vertex i+1.
+func firstSelfIntersection(vertices []GeofenceVertex) (int, int, bool) {
+	n := len(vertices)
+	for i := 0; i < n; i++ {
+		a1, a2 := vertices[i], vertices[(i+1)%n]
+		for j := i + 1; j < n; j++ {
+			if j == i+1 || (i == 0 && j == n-1) {
+				continue // adjacent edges share a vertex
+			}
+			b1, b2 := vertices[j], vertices[(j+1)%n]
+			if segmentsIntersect(a1, a2, b1, b2) {
+				return i, j, true
+			}
+		}
+	}
+	// Adjacent edges folding back onto each other overlap without a proper crossing.
+	for i := 0; i < n; i++ {
+		prev, cur, next := vertices[(i+n-1)%n], vertices[i], vertices[(i+1)%n]
+		if orientation(prev, cur, next) == 0 && (onSegment(prev, cur, next.Latitude, next.Longitude) || onSegment(cur, next, prev.Latitude, prev.Longitude)) {
+			if i == 0 {
+				return 0, n - 1, true
+			}
+			return i - 1, i, true
+		}
+	}
+	return 0, 0, false
+}
+
+// segmentsIntersect reports whether segments p1p2 and q1q2 share any point.
+func segmentsIntersect(p1, p2, q1, q2 GeofenceVertex) bool {
+	o1, o2 := orientation(p1, p2, q1), orientation(p1, p2, q2)
+	o3, o4 := orientation(q1, q2, p1), orientation(q1, q2, p2)
+	if o1 != o2 && o3 != o4 && o1 != 0 && o2 != 0 && o3 != 0 && o4 != 0 {
+		return true
+	}
+	return (o1 == 0 && onSegment(p1, p2, q1.Latitude, q1.Longitude)) ||
+		(o2 == 0 && onSegment(p1, p2, q2.Latitude, q2.Longitude)) ||
+		(o3 == 0 && onSegment(q1, q2, p1.Latitude, p1.Longitude)) ||
+		(o4 == 0 && onSegment(q1, q2, p2.Latitude, p2.Longitude))
+}
+
+// orientation returns 1 when a, b, c turn counter-clockwise, -1 when
+// clockwise and 0 when collinear.
+func orientation(a, b, c GeofenceVertex) int {
+	cross := (b.Longitude-a.Longitude)*(c.Latitude-a.Latitude) - (b.Latitude-a.Latitude)*(c.Longitude-a.Longitude)
+	switch {
+	case cross > 0:
+		return 1
+	case cross < 0:
+		return -1
+	default:
+		return 0
+	}
+}
+
+// onSegment reports whether the point lies on segment ab.
+func onSegment(a, b GeofenceVertex, lat, lon float64) bool {
+	if orientation(a, b, GeofenceVertex{Latitude: lat, Longitude: lon}) != 0 {
+		return false
+	}
+	return lat >= math.Min(a.Latitude, b.Latitude) && lat <= math.Max(a.Latitude, b.Latitude) &&
+		lon >= math.Min(a.Longitude, b.Longitude) && lon <= math.Max(a.Longitude, b.Longitude)
+}
@@ -0,0 +1,376 @@
+package models
+
+import (
+	// rand for ULID entropy (go1.21)
+	"crypto/rand"
+	// binary for reading ULID timestamps (go1.21)
+	"encoding/binary"
+	// errors for the invalid ID sentinel (go1.21)
+	"errors"
+	// fmt for generator configuration errors (go1.21)
+	"fmt"
+	// strconv for Snowflake encoding (go1.21)
+	"strconv"
+	// strings for normalizing format names (go1.21)
+	"strings"
+	// sync for generator state (go1.21)
+	"sync"
+	// atomic for swapping the process-wide generator (go1.21)
+	"sync/atomic"
+	// time for the timestamp component of sortable IDs (go1.21)
+	"time"
+
+	// uuid package for generating and parsing UUID v4 (v1.3.0)
+	"github.com/google/uuid"
+)
+
+// ID formats for location and session IDs. UUIDv4 IDs are random; ULIDs and
+// Snowflake IDs start with their creation time, so they sort by it and keep
+// B-tree inserts at the right edge of the index.
+const (
+	IDFormatUUID      = "uuid"
+	IDFormatULID      = "ulid"
+	IDFormatSnowflake = "snowflake"
+)
+
+// ErrInvalidID is returned for IDs that match no accepted format.
+var ErrInvalidID = errors.New("invalid ID")
+
+// IDGenerator creates unique IDs for new locations and tracking sessions.
+// Implementations are safe for concurrent use.
+type IDGenerator interface {
+	// NewID returns a new unique ID.
+	NewID() string
+
+	// Format returns the IDFormat constant of the IDs generated.
+	Format() string
+}
+
+// idGenerator holds the process-wide IDGenerator, UUIDv4 by default.
+var idGenerator atomic.Value
+
+// acceptedIDFormats holds the set of formats Validate accepts, all by default.
+var acceptedIDFormats atomic.Value
+
+func init() {
+	idGenerator.Store(IDGenerator(UUIDGenerator{}))
+	acceptedIDFormats.Store(map[string]bool{IDFormatUUID: true, IDFormatULID: true, IDFormatSnowflake: true})
+}
+
+// SetIDGenerator replaces the generator used by NewLocation and
+// NewTrackingSession. It is meant to be called once at startup.
+func SetIDGenerator(g IDGenerator) {
+	idGenerator.Store(g)
+}
+
+// NewID returns a new ID from the process-wide generator.
+func NewID() string {
+	return idGenerator.Load().(IDGenerator).NewID()
+}
+
+// SetAcceptedIDFormats restricts the ID formats that ValidateID accepts. While
+// migrating from one format to another both stay accepted, so records created
+// before the switch remain valid; once they have aged out the old format can
+// be dropped. An empty list accepts every format.
+func SetAcceptedIDFormats(formats []string) error {
+	accepted := make(map[string]bool, len(formats))
+	for _, format := range formats {
+		switch format {
+		case IDFormatUUID, IDFormatULID, IDFormatSnowflake:
+			accepted[format] = true
+		default:
+			return fmt.Errorf("unknown ID format %q", format)
+		}
+	}
+	if len(accepted) == 0 {
+		accepted = map[string]bool{IDFormatUUID: true, IDFormatULID: true, IDFormatSnowflake: true}
+	}
+	acceptedIDFormats.Store(accepted)
+	return nil
+}
+
+// NewIDGenerator returns the generator for format. nodeID identifies this
+// instance within a Snowflake deployment and is ignored by other formats.
+func NewIDGenerator(format string, nodeID int64) (IDGenerator, error) {
+	switch strings.ToLower(format) {
+	case "", IDFormatUUID:
+		return UUIDGenerator{}, nil
+	case IDFormatULID:
+		return NewULIDGenerator(), nil
+	case IDFormatSnowflake:
+		return NewSnowflakeGenerator(nodeID)
+	default:
+		return nil, fmt.Errorf("unknown ID format %q; must be %q, %q or %q", format, IDFormatUUID, IDFormatULID, IDFormatSnowflake)
+	}
+}
+
+// IDFormatOf reports the format of id, or ErrInvalidID when it has none.
+func IDFormatOf(id string) (string, error) {
+	switch {
+	case isSnowflakeID(id):
+		return IDFormatSnowflake, nil
+	case isULID(id):
+		return IDFormatULID, nil
+	}
+	if _, err := uuid.Parse(id); err == nil {
+		return IDFormatUUID, nil
+	}
+	return "", fmt.Errorf("%w: %q matches no ID format", ErrInvalidID, id)
+}
+
+// ValidateID checks that id is in one of the accepted formats.
+func ValidateID(id string) error {
+	format, err := IDFormatOf(id)
+	if err != nil {
+		return err
+	}
+	if !acceptedIDFormats.Load().(map[string]bool)[format] {
+		return fmt.Errorf("%w: %s IDs are not accepted", ErrInvalidID, format)
+	}
+	return nil
+}
+
+// IDTime returns the creation time embedded in a ULID or Snowflake ID, to the
+// millisecond. It reports false for UUIDs and invalid IDs.
+func IDTime(id string) (time.Time, bool) {
+	switch {
+	case isSnowflakeID(id):
+		n, _ := strconv.ParseInt(id, 10, 64)
+		return time.UnixMilli(snowflakeEpoch + n>>(snowflakeNodeBits+snowflakeSequenceBits)).UTC(), true
+	case isULID(id):
+		var raw [16]byte
+		decodeULID(id, &raw)
+		var ms [8]byte
+		copy(ms[2:], raw[:6])
+		return time.UnixMilli(int64(binary.BigEndian.Uint64(ms[:]))).UTC(), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// ------------------------
+// UUID
+// ------------------------
+
+// UUIDGenerator generates random UUIDv4 IDs, the original format.
+type UUIDGenerator struct{}
+
+// NewID implements IDGenerator.
+func (UUIDGenerator) NewID() string {
+	return uuid.NewString()
+}
+
+// Format implements IDGenerator.
+func (UUIDGenerator) Format() string {
+	return IDFormatUUID
+}
+
+// ------------------------
+// ULID
+// ------------------------
+
+// crockfordAlphabet is the Crockford base32 alphabet ULIDs are encoded in.
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ulidLength is the length of an encoded ULID.
+const ulidLength = 26
+
+// ULIDGenerator generates ULIDs: a 48-bit millisecond timestamp followed by 80
+// random bits, as 26 Crockford base32 characters. IDs generated within the
+// same millisecond increment the random part, so they stay strictly ordered.
+type ULIDGenerator struct {
+	mu     sync.Mutex
+	lastMs uint64
+	last   [16]byte
+}
+
+// NewULIDGenerator creates a ULID generator.
+func NewULIDGenerator() *ULIDGenerator {
+	return &ULIDGenerator{}
+}
+
+// NewID implements IDGenerator.
+func (g *ULIDGenerator) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := uint64(time.Now().UnixMilli())
+	if ms <= g.lastMs {
+		// Same millisecond, or the clock stepped back: increment the previous
+		// ID, carrying an exhausted random part into the timestamp.
+		if !incrementBytes(g.last[6:]) {
+			g.lastMs++
+		}
+		ms = g.lastMs
+	} else {
+		if _, err := rand.Read(g.last[6:]); err != nil {
+			panic(fmt.Sprintf("ulid: reading entropy: %v", err))
+		}
+		g.lastMs = ms
+	}
+	var stamp [8]byte
+	binary.BigEndian.PutUint64(stamp[:], ms)
+	copy(g.last[:6], stamp[2:])
+	return encodeULID(g.last)
+}
+
+// Format implements IDGenerator.
+func (g *ULIDGenerator) Format() string {
+	return IDFormatULID
+}
+
+// incrementBytes adds one to the big-endian number b, reporting false when it
+// wrapped around to zero.
+func incrementBytes(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeULID encodes 128 bits as 26 base32 characters, most significant first.
+// The first character carries only the top 3 bits.
+func encodeULID(raw [16]byte) string {
+	out := make([]byte, ulidLength)
+	for i := ulidLength - 1; i >= 0; i-- {
+		// Each step takes the lowest 5 bits and shifts the 128-bit value right.
+		out[i] = crockfordAlphabet[raw[15]&0x1f]
+		var carry byte
+		for j := 0; j < 16; j++ {
+			next := raw[j] & 0x1f
+			raw[j] = raw[j]>>5 | carry<<3
+			carry = next
+		}
+	}
+	return string(out)
+}
+
+// decodeULID decodes an ID already checked by isULID into raw.
+func decodeULID(id string, raw *[16]byte) {
+	for i := 0; i < ulidLength; i++ {
+		v := byte(strings.IndexByte(crockfordAlphabet, upperASCII(id[i])))
+		// Shift the 128-bit value left by 5 bits and add v.
+		var carry byte
+		for j := 15; j >= 0; j-- {
+			next := raw[j] >> 3
+			raw[j] = raw[j]<<5 | carry
+			carry = next
+		}
+		raw[15] |= v
+	}
+}
+
+// isULID reports whether id is a 26-character Crockford base32 ULID whose
+// value fits in 128 bits.
+func isULID(id string) bool {
+	if len(id) != ulidLength || upperASCII(id[0]) > '7' {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if strings.IndexByte(crockfordAlphabet, upperASCII(id[i])) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// upperASCII upper-cases an ASCII letter, since ULIDs are case-insensitive.
+func upperASCII(c byte) byte {
+	if c >= 'a' && c <= 'z' {
+		return c - 'a' + 'A'
+	}
+	return c
+}
+
+// ------------------------
+// Snowflake
+// ------------------------
+
+// Snowflake layout: a 41-bit millisecond timestamp since snowflakeEpoch, a
+// 10-bit node ID and a 12-bit per-millisecond sequence.
+const (
+	snowflakeNodeBits     = 10
+	snowflakeSequenceBits = 12
+	snowflakeMaxNode      = 1<<snowflakeNodeBits - 1
+	snowflakeMaxSequence  = 1<<snowflakeSequenceBits - 1
+
+	// snowflakeEpoch is 2024-01-01T00:00:00Z in Unix milliseconds, leaving
+	// the 41-bit timestamp room until 2093.
+	snowflakeEpoch = 1704067200000
+
+	// snowflakeDigits is the fixed width Snowflake IDs are zero-padded to, so
+	// their text sorts like their value in TEXT columns.
+	snowflakeDigits = 19
+)
+
+// SnowflakeGenerator generates Snowflake IDs: 63-bit integers of a timestamp,
+// the node ID and a sequence, written as zero-padded decimal. Every instance
+// of a deployment needs its own node ID; up to 4096 IDs per millisecond are
+// generated per node.
+type SnowflakeGenerator struct {
+	node int64
+
+	mu       sync.Mutex
+	lastMs   int64
+	sequence int64
+}
+
+// NewSnowflakeGenerator creates a Snowflake generator for nodeID, which must
+// be within [0, 1023].
+func NewSnowflakeGenerator(nodeID int64) (*SnowflakeGenerator, error) {
+	if nodeID < 0 || nodeID > snowflakeMaxNode {
+		return nil, fmt.Errorf("snowflake node ID %d out of range [0, %d]", nodeID, snowflakeMaxNode)
+	}
+	return &SnowflakeGenerator{node: nodeID}, nil
+}
+
+// NewID implements IDGenerator.
+func (g *SnowflakeGenerator) NewID() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	ms := time.Now().UnixMilli() - snowflakeEpoch
+	if ms < g.lastMs {
+		// The clock stepped back; keep issuing from the last millisecond.
+		ms = g.lastMs
+	}
+	if ms == g.lastMs {
+		g.sequence = (g.sequence + 1) & snowflakeMaxSequence
+		if g.sequence == 0 {
+			// Sequence exhausted for this millisecond; wait for the next.
+			for ms <= g.lastMs {
+				time.Sleep(100 * time.Microsecond)
+				ms = time.Now().UnixMilli() - snowflakeEpoch
+			}
+		}
+	} else {
+		g.sequence = 0
+	}
+	g.lastMs = ms
+
+	id := ms<<(snowflakeNodeBits+snowflakeSequenceBits) | g.node<<snowflakeSequenceBits | g.sequence
+	s := strconv.FormatInt(id, 10)
+	return strings.Repeat("0", snowflakeDigits-len(s)) + s
+}
+
+// Format implements IDGenerator.
+func (g *SnowflakeGenerator) Format() string {
+	return IDFormatSnowflake
+}
+
+// isSnowflakeID reports whether id is a zero-padded decimal Snowflake ID.
+func isSnowflakeID(id string) bool {
+	if len(id) != snowflakeDigits {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < '0' || id[i] > '9' {
+			return false
+		}
+	}
+	_, err := strconv.ParseInt(id, 10, 64)
+	return err == nil
+}
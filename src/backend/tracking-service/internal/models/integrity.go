@@ -0,0 +1,42 @@
+package models
+
+import (
+	// time for report timestamps (go1.21)
+	"time"
+)
+
+// Data integrity checks run over completed walks.
+const (
+	// IntegrityCheckDistance flags a session whose accumulated distance
+	// differs from the distance recomputed from its persisted points.
+	IntegrityCheckDistance = "distance_mismatch"
+	// IntegrityCheckPointCount flags a session whose accepted point counter
+	// differs from the number of points persisted for its walk.
+	IntegrityCheckPointCount = "point_count_mismatch"
+	// IntegrityCheckMissingSummary flags a completed session whose walk has no
+	// leaderboard summary.
+	IntegrityCheckMissingSummary = "missing_summary"
+)
+
+// IntegrityDiscrepancy is one invariant a sampled session breaks. Expected is
+// the value derived from the persisted data, Actual the one the session
+// reports.
+type IntegrityDiscrepancy struct {
+	Check     string  `json:"check"`
+	SessionID string  `json:"sessionId"`
+	WalkID    string  `json:"walkId"`
+	Expected  float64 `json:"expected"`
+	Actual    float64 `json:"actual"`
+}
+
+// IntegrityReport is the outcome of one data integrity run over the sessions
+// completed since Since. Sessions that could not be loaded are counted in
+// SessionsSkipped rather than reported as discrepancies.
+type IntegrityReport struct {
+	StartedAt       time.Time              `json:"startedAt"`
+	CompletedAt     time.Time              `json:"completedAt"`
+	Since           time.Time              `json:"since"`
+	SessionsChecked int                    `json:"sessionsChecked"`
+	SessionsSkipped int                    `json:"sessionsSkipped"`
+	Discrepancies   []IntegrityDiscrepancy `json:"discrepancies"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	// time for walk end times and leaderboard windows (go1.21)
+	"time"
+)
+
+// Leaderboard periods: the current UTC day, ISO week (from Monday) or
+// calendar month.
+const (
+	LeaderboardPeriodDay   = "day"
+	LeaderboardPeriodWeek  = "week"
+	LeaderboardPeriodMonth = "month"
+)
+
+// Leaderboard rankings: by total distance, number of completed walks, or
+// average walk quality score.
+const (
+	LeaderboardRankDistance = "distance"
+	LeaderboardRankWalks    = "walks"
+	LeaderboardRankQuality  = "quality"
+)
+
+// WalkSummary is the record of a completed walk that feeds the walker
+// leaderboard.
+type WalkSummary struct {
+	WalkID    string `json:"walkId"`
+	SessionID string `json:"sessionId"`
+
+	// TenantID is the account whose leaderboard the walk counts towards.
+	TenantID string `json:"tenantId"`
+	WalkerID string `json:"walkerId"`
+	DogID    string `json:"dogId"`
+
+	DistanceMeters  float64 `json:"distanceMeters"`
+	DurationSeconds float64 `json:"durationSeconds"`
+
+	// QualityScore is the walk's TrackingStatistics.QualityScore, from 0 to 100.
+	QualityScore float64 `json:"qualityScore"`
+
+	EndedAt time.Time `json:"endedAt"`
+}
+
+// LeaderboardEntry is one walker's standing on a leaderboard.
+type LeaderboardEntry struct {
+	// Rank starts at 1; walkers tied on the ranked metric share a rank.
+	Rank     int    `json:"rank"`
+	WalkerID string `json:"walkerId"`
+
+	DistanceMeters float64 `json:"distanceMeters"`
+	WalkCount      int     `json:"walkCount"`
+
+	// QualityScore is the average quality score of the walker's walks.
+	QualityScore float64 `json:"qualityScore"`
+}
+
+// Leaderboard ranks a tenant's walkers over the walks completed in [From, To).
+type Leaderboard struct {
+	TenantID string             `json:"tenantId"`
+	Period   string             `json:"period"`
+	RankBy   string             `json:"rankBy"`
+	From     time.Time          `json:"from"`
+	To       time.Time          `json:"to"`
+	Entries  []LeaderboardEntry `json:"entries"`
+}
@@ -5,8 +5,6 @@ import (
 	"time"
 	// json package for efficient JSON serialization and deserialization of location data with error handling (go1.21)
 	"encoding/json"
-	// uuid package for generating UUID v4 (v1.3.0)
-	"github.com/google/uuid"
 )
 
 // MinLatitude represents the minimum valid latitude coordinate.
@@ -21,17 +19,40 @@ const MinLongitude float64 = -180.0
 // MaxLongitude represents the maximum valid longitude coordinate.
 const MaxLongitude float64 = 180.0
 
+// WGS84CRS is the coordinate reference system every stored location uses.
+const WGS84CRS = "EPSG:4326"
+
 // DefaultAccuracy defines the default GPS accuracy in meters.
 const DefaultAccuracy float64 = 10.0
 
 // MaxAccuracy defines the maximum acceptable GPS accuracy in meters.
 const MaxAccuracy float64 = 100.0
 
+// Location providers a device may report for a fix. Devices that do not report
+// one leave Provider empty, counted as LocationProviderUnknown.
+const (
+	LocationProviderGPS     = "gps"
+	LocationProviderNetwork = "network"
+	LocationProviderFused   = "fused"
+	LocationProviderUnknown = "unknown"
+)
+
+// providerAccuracyWeights scale the reported accuracy of each provider's fixes
+// into an effective accuracy. Network fixes come from cell and Wi-Fi
+// positioning and are routinely worse than their reported radius; fused fixes
+// mix in network positions when satellites are weak.
+var providerAccuracyWeights = map[string]float64{
+	LocationProviderGPS:     1.0,
+	LocationProviderFused:   1.25,
+	LocationProviderNetwork: 2.0,
+}
+
 // Location represents a single GPS location point with coordinates, timestamp,
 // and accuracy metrics. It includes comprehensive validation, serialization,
 // and data integrity checks for real-time tracking scenarios.
 type Location struct {
-	// ID is a unique identifier for the location point (UUID v4).
+	// ID is a unique identifier for the location point, in any accepted ID format
+	// (UUID v4, ULID or Snowflake).
 	ID string `json:"id"`
 
 	// WalkID links this location to a specific dog walk session.
@@ -54,6 +75,21 @@ type Location struct {
 
 	// IsValid indicates whether the current location data has passed validation.
 	IsValid bool `json:"isValid"`
+
+	// CRS optionally declares the coordinate reference system of an ingested
+	// point as an EPSG identifier such as "EPSG:27700"; empty means WGS84. For
+	// projected systems Longitude carries the easting and Latitude the northing
+	// until the point is reprojected to WGS84 at ingestion.
+	CRS string `json:"crs,omitempty"`
+
+	// Provider is the device-reported source of the fix: "gps", "network" or
+	// "fused". Empty when the device does not report it.
+	Provider string `json:"provider,omitempty"`
+
+	// Seq is the device's sequence number of the fix, counting every fix it
+	// takes from 1, so the service can tell points lost in transit from
+	// periods without a fix. Zero when the device does not number its points.
+	Seq uint64 `json:"seq,omitempty"`
 }
 
 // NewLocation creates a new Location instance with comprehensive validation
@@ -68,8 +104,8 @@ func NewLocation(
 
 	var loc Location
 
-	// Generate unique ID using UUID v4
-	newID := uuid.NewString()
+	// Generate unique ID using the configured ID generator
+	newID := NewID()
 	loc.ID = newID
 
 	// Assign WalkID, ensuring it is not empty
@@ -102,15 +138,16 @@ func NewLocation(
 }
 
 // Validate performs comprehensive checks on the Location fields:
-//  1. ID must be a valid UUID.
+//  1. ID must be a valid ID in an accepted format.
 //  2. WalkID cannot be empty.
 //  3. Latitude must be within [-90.0, 90.0].
 //  4. Longitude must be within [-180.0, 180.0].
 //  5. Accuracy must be within [0.0, MaxAccuracy].
 //  6. Timestamp must be non-zero and not significantly in the future.
+//  7. Provider, if set, must be a known location provider.
 func (l *Location) Validate() error {
-	// Verify ID is valid UUID
-	if _, parseErr := uuid.Parse(l.ID); parseErr != nil {
+	// Verify ID is valid in an accepted format
+	if parseErr := ValidateID(l.ID); parseErr != nil {
 		l.IsValid = false
 		return parseErr
 	}
@@ -152,11 +189,37 @@ func (l *Location) Validate() error {
 		return ErrInvalidTimestamp("Timestamp is set too far in the future")
 	}
 
+	// Reject unknown providers so they cannot skew quality weighting
+	if _, known := providerAccuracyWeights[l.Provider]; l.Provider != "" && !known {
+		l.IsValid = false
+		return ErrInvalidProvider("Provider must be gps, network or fused")
+	}
+
 	// If all checks pass
 	l.IsValid = true
 	return nil
 }
 
+// EffectiveAccuracy returns the accuracy in meters weighted by the fix's
+// provider, used wherever a point's trustworthiness is judged: the session
+// accuracy filter and position smoothing. Fixes without a provider are
+// weighted as GPS.
+func (l *Location) EffectiveAccuracy() float64 {
+	if weight, ok := providerAccuracyWeights[l.Provider]; ok {
+		return l.Accuracy * weight
+	}
+	return l.Accuracy
+}
+
+// ProviderLabel returns Provider, or LocationProviderUnknown when unset, for
+// counting fixes by provider.
+func (l *Location) ProviderLabel() string {
+	if l.Provider == "" {
+		return LocationProviderUnknown
+	}
+	return l.Provider
+}
+
 // ToJSON serializes the Location data into its JSON representation. If any
 // validation step fails, an error will be returned instead of JSON data.
 func (l *Location) ToJSON() ([]byte, error) {
@@ -206,6 +269,14 @@ func (e ErrOutOfRange) Error() string {
 	return string(e)
 }
 
+// ErrInvalidProvider is returned when the provider field names an unknown
+// location provider.
+type ErrInvalidProvider string
+
+func (e ErrInvalidProvider) Error() string {
+	return string(e)
+}
+
 // ErrInvalidTimestamp is returned when the timestamp field is invalid.
 type ErrInvalidTimestamp string
 
@@ -0,0 +1,156 @@
+package models
+
+import (
+	// json for re-encoding the polygon as GeoJSON (go1.21)
+	"encoding/json"
+	// fmt is used for validation error messages (go1.21)
+	"fmt"
+	// time for the query window (go1.21)
+	"time"
+)
+
+// Limits of spatio-temporal location queries, keeping each one to a search
+// area and period that the spatial index and chunk exclusion can answer
+// quickly.
+const (
+	// MaxLocationQueryWindow is the longest time range a query may cover.
+	MaxLocationQueryWindow = 7 * 24 * time.Hour
+
+	// MaxLocationQueryVertices bounds the vertex count of the query polygon.
+	MaxLocationQueryVertices = 1000
+
+	// DefaultLocationQueryLimit and MaxLocationQueryLimit bound the points
+	// returned by a points query.
+	DefaultLocationQueryLimit = 1000
+	MaxLocationQueryLimit     = 10000
+)
+
+// Result modes of a location query.
+const (
+	LocationQueryPoints = "points"
+	LocationQueryCounts = "counts"
+)
+
+// GeoJSONPolygon is a GeoJSON Polygon geometry: an exterior ring followed by
+// optional holes, each a closed ring of [longitude, latitude] positions.
+type GeoJSONPolygon struct {
+	Type        string         `json:"type"`
+	Coordinates [][][2]float64 `json:"coordinates"`
+}
+
+// Validate checks that p is a Polygon whose rings are closed, have at least
+// four positions and use valid WGS84 coordinates.
+func (p GeoJSONPolygon) Validate() error {
+	if p.Type != "Polygon" {
+		return fmt.Errorf("geometry type %q is not supported; must be Polygon", p.Type)
+	}
+	if len(p.Coordinates) == 0 {
+		return fmt.Errorf("polygon has no rings")
+	}
+	vertices := 0
+	for i, ring := range p.Coordinates {
+		if len(ring) < 4 {
+			return fmt.Errorf("polygon ring %d has %d positions; at least 4 are required", i, len(ring))
+		}
+		if ring[0] != ring[len(ring)-1] {
+			return fmt.Errorf("polygon ring %d is not closed", i)
+		}
+		for _, pos := range ring {
+			if pos[0] < MinLongitude || pos[0] > MaxLongitude || pos[1] < MinLatitude || pos[1] > MaxLatitude {
+				return fmt.Errorf("polygon position [%g, %g] is out of range", pos[0], pos[1])
+			}
+		}
+		vertices += len(ring)
+	}
+	if vertices > MaxLocationQueryVertices {
+		return fmt.Errorf("polygon has %d positions; at most %d are allowed", vertices, MaxLocationQueryVertices)
+	}
+	return nil
+}
+
+// GeoJSON returns the polygon encoded as GeoJSON, for ST_GeomFromGeoJSON.
+func (p GeoJSONPolygon) GeoJSON() (string, error) {
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+// LocationQuery selects the recorded points that lie within a polygon during a
+// time window, optionally restricted to one walk or one dog, e.g. to find
+// which walks passed through a park around the time a dog went missing.
+type LocationQuery struct {
+	Polygon GeoJSONPolygon `json:"polygon"`
+	From    time.Time      `json:"from"`
+	To      time.Time      `json:"to"`
+
+	// WalkID and DogID optionally restrict matches to one walk or one dog.
+	WalkID string `json:"walkId,omitempty"`
+	DogID  string `json:"dogId,omitempty"`
+
+	// Mode is LocationQueryPoints (default) for the matching points, or
+	// LocationQueryCounts for per-walk counts.
+	Mode string `json:"mode,omitempty"`
+
+	// Limit caps the points returned in points mode.
+	Limit int `json:"limit,omitempty"`
+
+	// DogWalkIDs are walks of DogID still in progress, resolved by the service
+	// from its live sessions. Walks of DogID that have completed are resolved by
+	// the store.
+	DogWalkIDs []string `json:"-"`
+}
+
+// Validate checks the polygon, window, mode and limit, filling the defaults.
+func (q *LocationQuery) Validate() error {
+	if err := q.Polygon.Validate(); err != nil {
+		return err
+	}
+	if q.From.IsZero() || q.To.IsZero() {
+		return fmt.Errorf("from and to are required")
+	}
+	if !q.To.After(q.From) {
+		return fmt.Errorf("to must be after from")
+	}
+	if q.To.Sub(q.From) > MaxLocationQueryWindow {
+		return fmt.Errorf("time window may span at most %s", MaxLocationQueryWindow)
+	}
+	switch q.Mode {
+	case "":
+		q.Mode = LocationQueryPoints
+	case LocationQueryPoints, LocationQueryCounts:
+	default:
+		return fmt.Errorf("mode %q is invalid; must be %q or %q", q.Mode, LocationQueryPoints, LocationQueryCounts)
+	}
+	if q.Limit < 0 || q.Limit > MaxLocationQueryLimit {
+		return fmt.Errorf("limit %d is invalid; must be at most %d", q.Limit, MaxLocationQueryLimit)
+	}
+	if q.Limit == 0 {
+		q.Limit = DefaultLocationQueryLimit
+	}
+	return nil
+}
+
+// WalkPointCount is the number of points one walk recorded within a query's
+// polygon and window, with the first and last of them.
+type WalkPointCount struct {
+	WalkID    string    `json:"walkId"`
+	Points    int       `json:"points"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// LocationQueryResult is the answer to a LocationQuery: the matching points in
+// time order in points mode, or per-walk counts, busiest first, in counts mode.
+type LocationQueryResult struct {
+	Mode   string           `json:"mode"`
+	Points []Location       `json:"points,omitempty"`
+	Counts []WalkPointCount `json:"counts,omitempty"`
+
+	// Total is the number of matching points.
+	Total int `json:"total"`
+
+	// Truncated reports that more points matched than Points holds.
+	Truncated bool `json:"truncated,omitempty"`
+}
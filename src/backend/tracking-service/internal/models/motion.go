@@ -0,0 +1,118 @@
+package models
+
+import (
+	// time for moving, stopped and split durations (go1.21)
+	"time"
+)
+
+// movingSpeedThreshold is the speed, in meters per second, below which the
+// walker is treated as stopped between two points.
+const movingSpeedThreshold = 0.5
+
+// elevationNoiseThreshold is the altitude change, in meters, from the last
+// counted altitude below which climbs and descents are treated as GPS noise.
+const elevationNoiseThreshold = 3.0
+
+// paceSplitLength is the distance of a pace split, in meters.
+const paceSplitLength = 1000.0
+
+// PaceSplit is the time a walk took to cover one kilometer.
+type PaceSplit struct {
+	// Kilometer numbers the split from 1.
+	Kilometer int `json:"kilometer"`
+
+	// DistanceMeters is 1000, except for the final split while it is partial.
+	DistanceMeters float64 `json:"distanceMeters"`
+
+	// DurationSeconds is the time taken to cover the split.
+	DurationSeconds float64 `json:"durationSeconds"`
+
+	// PaceSecondsPerKm is the split's duration scaled to a full kilometer.
+	PaceSecondsPerKm float64 `json:"paceSecondsPerKm"`
+}
+
+// motionAccumulator accumulates elevation, moving time and pace splits point by
+// point, so statistics cover points evicted from a session's history.
+type motionAccumulator struct {
+	// elevationRef is the altitude climbs and descents are measured from.
+	elevationRef  float64
+	elevationGain float64
+	elevationLoss float64
+
+	movingTime  time.Duration
+	stoppedTime time.Duration
+
+	// splits holds the completed splits; splitDistance and splitTime measure
+	// progress through the current one.
+	splits        []PaceSplit
+	splitDistance float64
+	splitTime     time.Duration
+}
+
+// add accounts for loc, reached from prev over dist meters; prev is nil for
+// the first point.
+func (m *motionAccumulator) add(prev, loc *Location, dist float64) {
+	if prev == nil {
+		m.elevationRef = loc.Altitude
+		return
+	}
+
+	// Count an altitude change only once it exceeds the noise threshold, so
+	// jitter around a constant altitude adds neither gain nor loss.
+	if climb := loc.Altitude - m.elevationRef; climb >= elevationNoiseThreshold {
+		m.elevationGain += climb
+		m.elevationRef = loc.Altitude
+	} else if -climb >= elevationNoiseThreshold {
+		m.elevationLoss -= climb
+		m.elevationRef = loc.Altitude
+	}
+
+	elapsed := loc.Timestamp.Sub(prev.Timestamp)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	if elapsed > 0 {
+		if dist/elapsed.Seconds() >= movingSpeedThreshold {
+			m.movingTime += elapsed
+		} else {
+			m.stoppedTime += elapsed
+		}
+	}
+
+	// Close every split the segment completes, interpolating the time the
+	// walker crossed each kilometer mark.
+	for dist > 0 && m.splitDistance+dist >= paceSplitLength {
+		needed := paceSplitLength - m.splitDistance
+		part := time.Duration(float64(elapsed) * needed / dist)
+		m.splits = append(m.splits, newPaceSplit(len(m.splits)+1, paceSplitLength, m.splitTime+part))
+		m.splitDistance, m.splitTime = 0, 0
+		dist -= needed
+		elapsed -= part
+	}
+	m.splitDistance += dist
+	m.splitTime += elapsed
+}
+
+// apply copies the accumulated values into stats, ending its pace splits with
+// the current partial split, if any.
+func (m *motionAccumulator) apply(stats *TrackingStatistics) {
+	stats.ElevationGain = m.elevationGain
+	stats.ElevationLoss = m.elevationLoss
+	stats.MovingTime = m.movingTime
+	stats.StoppedTime = m.stoppedTime
+	stats.PaceSplits = make([]PaceSplit, len(m.splits), len(m.splits)+1)
+	copy(stats.PaceSplits, m.splits)
+	if m.splitDistance > 0 {
+		stats.PaceSplits = append(stats.PaceSplits, newPaceSplit(len(m.splits)+1, m.splitDistance, m.splitTime))
+	}
+}
+
+// newPaceSplit builds the split numbered kilometer, covering distance meters in d.
+func newPaceSplit(kilometer int, distance float64, d time.Duration) PaceSplit {
+	return PaceSplit{
+		Kilometer:        kilometer,
+		DistanceMeters:   distance,
+		DurationSeconds:  d.Seconds(),
+		PaceSecondsPerKm: d.Seconds() / distance * paceSplitLength,
+	}
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	// errors for owner alert validation failures (go1.21)
+	"errors"
+	// time for preference and alert timestamps (go1.21)
+	"time"
+)
+
+// Event types of owner boundary alerts.
+const (
+	// OwnerAlertEventExit is raised when the dog leaves the owner's radius.
+	OwnerAlertEventExit = "owner_boundary_exit"
+	// OwnerAlertEventReturn is raised when the dog is back within it.
+	OwnerAlertEventReturn = "owner_boundary_return"
+)
+
+// OwnerAlertPreference is an owner's own alert radius for walks of one of
+// their dogs, usually tighter than the operational geofence the walker works
+// within, so the owner hears about a wander before the tenant does.
+type OwnerAlertPreference struct {
+	OwnerID   string    `json:"ownerId"`
+	DogID     string    `json:"dogId"`
+	RadiusKm  float64   `json:"radiusKm"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Validate checks that the preference names an owner and a dog. Radius bounds
+// are checked by the geofence service.
+func (p *OwnerAlertPreference) Validate() error {
+	if p.OwnerID == "" || p.DogID == "" {
+		return errors.New("ownerId and dogId are required")
+	}
+	return nil
+}
+
+// OwnerBoundaryAlert is the notification sent to an owner when a walked dog
+// leaves or returns within their alert radius. DistanceKm is measured from
+// the center of the walk's boundary.
+type OwnerBoundaryAlert struct {
+	EventType  string    `json:"eventType"`
+	OwnerID    string    `json:"ownerId"`
+	DogID      string    `json:"dogId"`
+	WalkID     string    `json:"walkId"`
+	SessionID  string    `json:"sessionId"`
+	RadiusKm   float64   `json:"radiusKm"`
+	DistanceKm float64   `json:"distanceKm"`
+	Latitude   float64   `json:"latitude"`
+	Longitude  float64   `json:"longitude"`
+	Timestamp  time.Time `json:"timestamp"`
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	// fmt for filter validation messages (go1.21)
+	"fmt"
+	// time for cursor positions and history filter bounds (go1.21)
+	"time"
+)
+
+// PageCursor is a position in a listing ordered by timestamp, then ID: the
+// last item of the previous page. The next page holds the items strictly
+// after it, so pages stay stable while newer items are appended. The zero
+// cursor is the start of the listing.
+type PageCursor struct {
+	Timestamp time.Time
+	ID        string
+}
+
+// IsZero reports whether c is the start of a listing.
+func (c PageCursor) IsZero() bool {
+	return c.Timestamp.IsZero() && c.ID == ""
+}
+
+// HistoryFilter narrows a paginated walk history to the points recorded from
+// From, inclusive, until To, exclusive; a zero bound leaves that end open.
+// EveryNth keeps every Nth of those points, counting from the first, and
+// Bucket keeps the first point of each Bucket-long time bucket. At most one of
+// them is set; their zero values keep every point.
+type HistoryFilter struct {
+	From     time.Time
+	To       time.Time
+	EveryNth int
+	Bucket   time.Duration
+}
+
+// Validate checks that the bounds are ordered and at most one down-sampling
+// mode is set.
+func (f HistoryFilter) Validate() error {
+	if !f.From.IsZero() && !f.To.IsZero() && !f.From.Before(f.To) {
+		return fmt.Errorf("from must be before to")
+	}
+	if f.EveryNth < 0 {
+		return fmt.Errorf("every must be a positive integer")
+	}
+	if f.Bucket < 0 {
+		return fmt.Errorf("bucket must be a positive duration")
+	}
+	if f.EveryNth > 1 && f.Bucket > 0 {
+		return fmt.Errorf("every and bucket cannot be combined")
+	}
+	return nil
+}
+
+// LocationPage is a page of a walk's recorded points, oldest first.
+// NextCursor, opaque to clients, requests the following page and is empty on
+// the last one.
+type LocationPage struct {
+	Items      []Location `json:"items"`
+	NextCursor string     `json:"nextCursor,omitempty"`
+}
+
+// SessionEventPage is a page of a session's event stream, oldest first.
+type SessionEventPage struct {
+	Items      []SessionStateEvent `json:"items"`
+	NextCursor string              `json:"nextCursor,omitempty"`
+}
+
+// CurrentWalkPage is a page of the running walks, oldest first.
+type CurrentWalkPage struct {
+	Items      []CurrentWalk `json:"items"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	// time for prediction timestamps (go1.21)
+	"time"
+)
+
+// Walk prediction sources: a configured prediction endpoint, or the built-in
+// heuristic over the dog's recent walks.
+const (
+	PredictionSourceEndpoint  = "endpoint"
+	PredictionSourceHeuristic = "heuristic"
+)
+
+// WalkPrediction is the expected duration and distance of a walk, estimated
+// when its session starts from the dog's and walker's past walks.
+type WalkPrediction struct {
+	DurationSeconds float64 `json:"durationSeconds"`
+	DistanceMeters  float64 `json:"distanceMeters"`
+
+	// Source is PredictionSourceEndpoint or PredictionSourceHeuristic.
+	Source string `json:"source"`
+
+	// BasedOnWalks is the number of past walks the prediction was made from.
+	BasedOnWalks int `json:"basedOnWalks"`
+
+	PredictedAt time.Time `json:"predictedAt"`
+}
+
+// PredictionError compares a completed walk with its prediction. Errors are
+// actual minus predicted, so a walk that ran long has a positive error; the
+// percentage errors are relative to the actual value and omitted when it is 0.
+type PredictionError struct {
+	DurationErrorSeconds float64  `json:"durationErrorSeconds"`
+	DistanceErrorMeters  float64  `json:"distanceErrorMeters"`
+	DurationErrorPercent *float64 `json:"durationErrorPercent,omitempty"`
+	DistanceErrorPercent *float64 `json:"distanceErrorPercent,omitempty"`
+}
+
+// NewPredictionError measures prediction against the walk's actual duration
+// in seconds and distance in meters.
+func NewPredictionError(prediction *WalkPrediction, durationSeconds, distanceMeters float64) *PredictionError {
+	return &PredictionError{
+		DurationErrorSeconds: durationSeconds - prediction.DurationSeconds,
+		DistanceErrorMeters:  distanceMeters - prediction.DistanceMeters,
+		DurationErrorPercent: percentError(durationSeconds, prediction.DurationSeconds),
+		DistanceErrorPercent: percentError(distanceMeters, prediction.DistanceMeters),
+	}
+}
+
+// percentError returns (actual - predicted) as a percentage of actual, or nil
+// when actual is 0.
+func percentError(actual, predicted float64) *float64 {
+	if actual == 0 {
+		return nil
+	}
+	pct := (actual - predicted) / actual * 100
+	return &pct
+}
+
+// Prediction returns the session's walk prediction, or nil when none has been
+// made (yet).
+func (s *TrackingSession) Prediction() *WalkPrediction {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.prediction
+}
+
+// SetPrediction attaches a walk prediction to the session. It may be called
+// after the session is shared, since predictions arrive in the background.
+func (s *TrackingSession) SetPrediction(prediction *WalkPrediction) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.prediction = prediction
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	// time for quarantine timestamps (go1.21)
+	"time"
+)
+
+// Quarantine listing limits.
+const (
+	DefaultQuarantineLimit = 100
+	MaxQuarantineLimit     = 1000
+)
+
+// QuarantinedPoint is a location point rejected at ingestion, kept for review
+// instead of being dropped.
+type QuarantinedPoint struct {
+	// ID identifies the quarantine entry; the point's own ID may be invalid.
+	ID        string   `json:"id"`
+	SessionID string   `json:"sessionId"`
+	Location  Location `json:"location"`
+
+	// Reason names the check the point failed, e.g. out_of_range or too_fast,
+	// and Detail is the failure's message.
+	Reason string `json:"reason"`
+	Detail string `json:"detail,omitempty"`
+
+	QuarantinedAt time.Time `json:"quarantinedAt"`
+}
+
+// QuarantineQuery filters quarantined points, newest first. Empty fields match
+// any value; Limit is clamped to MaxQuarantineLimit and defaults to
+// DefaultQuarantineLimit.
+type QuarantineQuery struct {
+	SessionID string
+	Reason    string
+	Limit     int
+}
+
+// ReingestRequest names the quarantined points to ingest again.
+type ReingestRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// ReingestResult is the outcome of ingesting quarantined points again.
+type ReingestResult struct {
+	// Reingested are the quarantine IDs of the points that passed and were
+	// released from quarantine.
+	Reingested []string `json:"reingested"`
+
+	// Rejected are the points that failed again, under new quarantine entries
+	// carrying the reason they failed this time.
+	Rejected []QuarantinedPoint `json:"rejected"`
+
+	// NotFound are the requested IDs that are not quarantined.
+	NotFound []string `json:"notFound,omitempty"`
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	// errors for region profile validation failures (go1.21)
+	"errors"
+	// regexp for validating region codes (go1.21)
+	"regexp"
+	// time for profile update timestamps (go1.21)
+	"time"
+)
+
+// MaxRegionSpeedKmh bounds the speed limit a region profile may set, in km/h.
+const MaxRegionSpeedKmh = 200.0
+
+// regionCodePattern matches region codes such as "nyc" or "de-berlin".
+var regionCodePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,31}$`)
+
+// SessionSettings are the per-session limits resolved when a session starts:
+// the service's base settings with the session's region profile layered over
+// them.
+type SessionSettings struct {
+	// Region is the code of the region the session was started in, empty for
+	// sessions started without one.
+	Region string `json:"region,omitempty"`
+
+	// MaxSpeedKmh rejects fixes implying a faster movement from the previous
+	// fix, in km/h; zero accepts any speed.
+	MaxSpeedKmh float64 `json:"maxSpeedKmh"`
+
+	// GeofenceRadiusKm is the radius given to circle geofences created for the
+	// session's walk without one.
+	GeofenceRadiusKm float64 `json:"geofenceRadiusKm"`
+
+	// RetentionDays is how long the session's location points are kept; zero
+	// leaves them to the database-wide retention policy.
+	RetentionDays int `json:"retentionDays"`
+}
+
+// RegionProfile holds the settings of one city or region that differ from the
+// base configuration, e.g. a higher speed limit where walkers ride e-bikes.
+// Zero fields inherit the base setting.
+type RegionProfile struct {
+	Region           string    `json:"region"`
+	MaxSpeedKmh      float64   `json:"maxSpeedKmh,omitempty"`
+	GeofenceRadiusKm float64   `json:"geofenceRadiusKm,omitempty"`
+	RetentionDays    int       `json:"retentionDays,omitempty"`
+	UpdatedAt        time.Time `json:"updatedAt"`
+}
+
+// ValidRegionCode reports whether code is a lowercase region code of letters,
+// digits and dashes, at most 32 characters long.
+func ValidRegionCode(code string) bool {
+	return regionCodePattern.MatchString(code)
+}
+
+// Validate checks the region code and that no setting is negative or beyond
+// its bound. Geofence radius bounds are checked by the geofence service.
+func (p *RegionProfile) Validate() error {
+	if !ValidRegionCode(p.Region) {
+		return errors.New("region must be 1-32 lowercase letters, digits or dashes")
+	}
+	if p.MaxSpeedKmh < 0 || p.MaxSpeedKmh > MaxRegionSpeedKmh {
+		return ErrOutOfRange("maxSpeedKmh is out of valid range")
+	}
+	if p.GeofenceRadiusKm < 0 {
+		return ErrOutOfRange("geofenceRadiusKm cannot be negative")
+	}
+	if p.RetentionDays < 0 {
+		return ErrOutOfRange("retentionDays cannot be negative")
+	}
+	return nil
+}
+
+// Apply returns base with the profile's non-zero settings layered over it and
+// its region recorded.
+func (p *RegionProfile) Apply(base SessionSettings) SessionSettings {
+	settings := base
+	settings.Region = p.Region
+	if p.MaxSpeedKmh > 0 {
+		settings.MaxSpeedKmh = p.MaxSpeedKmh
+	}
+	if p.GeofenceRadiusKm > 0 {
+		settings.GeofenceRadiusKm = p.GeofenceRadiusKm
+	}
+	if p.RetentionDays > 0 {
+		settings.RetentionDays = p.RetentionDays
+	}
+	return settings
+}
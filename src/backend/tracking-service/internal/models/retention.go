@@ -0,0 +1,51 @@
+package models
+
+import (
+	// time for chunk ranges, cutoffs and report timestamps (go1.21)
+	"time"
+)
+
+// RetentionChunk is a hypertable chunk a retention run compresses or drops.
+// Bytes is the chunk's total on-disk size, indexes included.
+type RetentionChunk struct {
+	Table      string    `json:"table"`
+	Chunk      string    `json:"chunk"`
+	RangeStart time.Time `json:"rangeStart"`
+	RangeEnd   time.Time `json:"rangeEnd"`
+	Rows       int64     `json:"rows"`
+	Bytes      int64     `json:"bytes"`
+}
+
+// RetentionRows summarizes the location points a retention run deletes from
+// Table because they were recorded before Cutoff, for the walks started in
+// Region when the deletion is regional. Bytes is the points' stored row size;
+// Oldest and Newest are unset when no point is deleted.
+type RetentionRows struct {
+	Table  string     `json:"table"`
+	Region string     `json:"region,omitempty"`
+	Cutoff time.Time  `json:"cutoff"`
+	Rows   int64      `json:"rows"`
+	Walks  int64      `json:"walks"`
+	Bytes  int64      `json:"bytes"`
+	Oldest *time.Time `json:"oldest,omitempty"`
+	Newest *time.Time `json:"newest,omitempty"`
+}
+
+// RetentionReport lists what a retention or archival run compressed, dropped
+// and deleted or, for a dry run, what it would have without modifying data.
+type RetentionReport struct {
+	DryRun      bool             `json:"dryRun"`
+	GeneratedAt time.Time        `json:"generatedAt"`
+	Compressed  []RetentionChunk `json:"compressed"`
+	Dropped     []RetentionChunk `json:"dropped"`
+	Deleted     []RetentionRows  `json:"deleted"`
+}
+
+// DeletedRows returns the number of location points the report deleted.
+func (r *RetentionReport) DeletedRows() int64 {
+	var total int64
+	for _, d := range r.Deleted {
+		total += d.Rows
+	}
+	return total
+}
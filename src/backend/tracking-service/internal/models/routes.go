@@ -0,0 +1,63 @@
+package models
+
+import (
+	// fmt is used for validation error messages (go1.21)
+	"fmt"
+)
+
+// MaxRouteQuerySpanDegrees bounds the latitude and longitude span of a popular
+// route query, keeping it to a neighbourhood rather than a whole region.
+const MaxRouteQuerySpanDegrees = 0.5
+
+// RouteSegment is one edge of the route popularity layer: a step between two
+// adjacent grid cells crossed by a walk. Segments are undirected; FromCell is
+// always the lexically smaller cell key so both directions count together.
+type RouteSegment struct {
+	// FromCell and ToCell are the grid cell keys the segment joins.
+	FromCell string `json:"fromCell"`
+	ToCell   string `json:"toCell"`
+
+	// FromLatitude/FromLongitude and ToLatitude/ToLongitude are the cell centers.
+	FromLatitude  float64 `json:"fromLatitude"`
+	FromLongitude float64 `json:"fromLongitude"`
+	ToLatitude    float64 `json:"toLatitude"`
+	ToLongitude   float64 `json:"toLongitude"`
+}
+
+// PopularRouteSegment is a route segment with its historical usage.
+type PopularRouteSegment struct {
+	RouteSegment
+
+	// WalkCount is the number of completed walks that crossed the segment.
+	WalkCount int `json:"walkCount"`
+
+	// Popularity is WalkCount relative to the busiest segment in the same
+	// query result, from 0 to 1.
+	Popularity float64 `json:"popularity"`
+}
+
+// BoundingBox is a latitude/longitude rectangle.
+type BoundingBox struct {
+	MinLatitude  float64 `json:"minLatitude"`
+	MinLongitude float64 `json:"minLongitude"`
+	MaxLatitude  float64 `json:"maxLatitude"`
+	MaxLongitude float64 `json:"maxLongitude"`
+}
+
+// Validate checks that the box has valid coordinates, positive extent, and a
+// span no larger than MaxRouteQuerySpanDegrees in either direction.
+func (b BoundingBox) Validate() error {
+	if b.MinLatitude < MinLatitude || b.MaxLatitude > MaxLatitude {
+		return fmt.Errorf("bounding box latitudes must be between %.1f and %.1f", MinLatitude, MaxLatitude)
+	}
+	if b.MinLongitude < MinLongitude || b.MaxLongitude > MaxLongitude {
+		return fmt.Errorf("bounding box longitudes must be between %.1f and %.1f", MinLongitude, MaxLongitude)
+	}
+	if b.MinLatitude >= b.MaxLatitude || b.MinLongitude >= b.MaxLongitude {
+		return fmt.Errorf("bounding box minimums must be less than maximums")
+	}
+	if b.MaxLatitude-b.MinLatitude > MaxRouteQuerySpanDegrees || b.MaxLongitude-b.MinLongitude > MaxRouteQuerySpanDegrees {
+		return fmt.Errorf("bounding box may span at most %.1f degrees", MaxRouteQuerySpanDegrees)
+	}
+	return nil
+}
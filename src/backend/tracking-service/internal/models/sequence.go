@@ -0,0 +1,344 @@
+package models
+
+import (
+	// sort for observing points in sequence order (go1.21)
+	"sort"
+	// time for gap timing (go1.21)
+	"time"
+)
+
+// maxPendingSeqRanges bounds the missing ranges a session awaits at once; the
+// oldest is confirmed lost when a new one would exceed it.
+const maxPendingSeqRanges = 64
+
+// maxRecordedSeqGaps bounds the gap intervals a session keeps for its
+// statistics; later gaps are still counted in SequenceGapStats.
+const maxRecordedSeqGaps = 256
+
+// Statuses of network sequence gaps.
+const (
+	SeqGapPending   = "pending"
+	SeqGapRecovered = "recovered"
+	SeqGapLost      = "lost"
+)
+
+// maxSeqJump is the largest forward jump in sequence numbers treated as lost
+// points. Larger jumps are taken as a device restarting its counter, and the
+// tracker resynchronizes instead of awaiting the points in between.
+const maxSeqJump = 10000
+
+// SeqRange is an inclusive range of per-point sequence numbers.
+type SeqRange struct {
+	From uint64 `json:"from"`
+	To   uint64 `json:"to"`
+}
+
+// Len returns the number of sequence numbers in r.
+func (r SeqRange) Len() int {
+	if r.To < r.From {
+		return 0
+	}
+	return int(r.To-r.From) + 1
+}
+
+// SequenceGapStats summarizes the points a session's device numbered but the
+// service did not receive, telling network loss from GPS loss. The device
+// numbers every fix it takes, so a missing number is a point lost between
+// device and service, while a long pause between consecutive numbers is a
+// period the device took no fix.
+type SequenceGapStats struct {
+	// PointsReceived is the number of distinct numbered points received.
+	PointsReceived int `json:"pointsReceived"`
+
+	// NetworkGaps is the number of runs of missing sequence numbers, and
+	// PointsMissing the points in them. Of those, PointsRecovered arrived
+	// later, PointsLost were confirmed lost, and PointsPending are still
+	// awaited.
+	NetworkGaps     int `json:"networkGaps"`
+	PointsMissing   int `json:"pointsMissing"`
+	PointsRecovered int `json:"pointsRecovered"`
+	PointsLost      int `json:"pointsLost"`
+	PointsPending   int `json:"pointsPending"`
+
+	// GPSGaps is the number of pauses between consecutive points longer than
+	// the GPS gap threshold, and GPSGapSeconds their total length.
+	GPSGaps       int     `json:"gpsGaps"`
+	GPSGapSeconds float64 `json:"gpsGapSeconds"`
+}
+
+// SequenceGap is one interval of a session's track with data missing. A
+// network gap lies between the points either side of a run of missing
+// sequence numbers, Range, of which Missing have not arrived; its Status is
+// pending while they are awaited, lost once confirmed lost, and recovered when
+// all of them arrived. A GPS gap is a pause between consecutively numbered
+// points, and has neither range nor status.
+type SequenceGap struct {
+	Cause   string    `json:"cause"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Range   *SeqRange `json:"range,omitempty"`
+	Missing int       `json:"missing,omitempty"`
+	Status  string    `json:"status,omitempty"`
+}
+
+// Causes of sequence gaps.
+const (
+	SeqGapCauseNetwork = "network"
+	SeqGapCauseGPS     = "gps"
+)
+
+// SeqObservation is what observing a batch's sequence numbers found: the
+// ranges newly missing, to be requested from the device, the previously
+// missing points that arrived, the ranges confirmed lost after going
+// unanswered, and the length of each GPS gap between consecutive points.
+type SeqObservation struct {
+	Missing   []SeqRange
+	Recovered int
+	Lost      []SeqRange
+	GPSGaps   []time.Duration
+}
+
+// pendingSeqRange is a missing range awaited since detectedAt.
+type pendingSeqRange struct {
+	SeqRange
+	detectedAt time.Time
+}
+
+// sequenceTracker follows a session's per-point sequence numbers.
+type sequenceTracker struct {
+	highest   uint64
+	highestAt time.Time
+	pending   []pendingSeqRange
+	gaps      []SequenceGap
+	stats     SequenceGapStats
+}
+
+// record keeps gap for the session's statistics, up to maxRecordedSeqGaps.
+func (t *sequenceTracker) record(gap SequenceGap) {
+	if len(t.gaps) < maxRecordedSeqGaps {
+		t.gaps = append(t.gaps, gap)
+	}
+}
+
+// ObserveSequence records the sequence numbers of locs, which may arrive out
+// of order, at now. Missing ranges awaited for longer than confirmAfter are
+// confirmed lost, and consecutive points more than gpsGapThreshold apart are
+// counted as GPS gaps. Points without a sequence number are ignored.
+func (s *TrackingSession) ObserveSequence(locs []*Location, now time.Time, confirmAfter, gpsGapThreshold time.Duration) SeqObservation {
+	numbered := make([]*Location, 0, len(locs))
+	for _, loc := range locs {
+		if loc != nil && loc.Seq > 0 {
+			numbered = append(numbered, loc)
+		}
+	}
+	sort.SliceStable(numbered, func(i, j int) bool { return numbered[i].Seq < numbered[j].Seq })
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.sequence == nil {
+		if len(numbered) == 0 {
+			return SeqObservation{}
+		}
+		s.sequence = &sequenceTracker{}
+	}
+	t := s.sequence
+
+	var obs SeqObservation
+	for _, loc := range numbered {
+		switch {
+		case t.highest == 0 || loc.Seq > t.highest+maxSeqJump:
+			// First numbered point, or a counter restart: start over from it.
+			t.highest, t.highestAt = loc.Seq, loc.Timestamp
+			t.stats.PointsReceived++
+		case loc.Seq == t.highest+1:
+			if gap := loc.Timestamp.Sub(t.highestAt); gpsGapThreshold > 0 && gap > gpsGapThreshold {
+				obs.GPSGaps = append(obs.GPSGaps, gap)
+				t.stats.GPSGaps++
+				t.stats.GPSGapSeconds += gap.Seconds()
+				t.record(SequenceGap{Cause: SeqGapCauseGPS, Start: t.highestAt, End: loc.Timestamp})
+			}
+			t.highest, t.highestAt = loc.Seq, loc.Timestamp
+			t.stats.PointsReceived++
+		case loc.Seq > t.highest:
+			missing := SeqRange{From: t.highest + 1, To: loc.Seq - 1}
+			obs.Missing = append(obs.Missing, missing)
+			t.pending = append(t.pending, pendingSeqRange{SeqRange: missing, detectedAt: now})
+			t.stats.NetworkGaps++
+			t.stats.PointsMissing += missing.Len()
+			r := missing
+			t.record(SequenceGap{
+				Cause:   SeqGapCauseNetwork,
+				Start:   t.highestAt,
+				End:     loc.Timestamp,
+				Range:   &r,
+				Missing: missing.Len(),
+				Status:  SeqGapPending,
+			})
+			t.highest, t.highestAt = loc.Seq, loc.Timestamp
+			t.stats.PointsReceived++
+		default:
+			if t.fill(loc.Seq) {
+				obs.Recovered++
+				t.stats.PointsRecovered++
+				t.stats.PointsReceived++
+			}
+		}
+	}
+
+	for len(t.pending) > maxPendingSeqRanges {
+		obs.Lost = append(obs.Lost, t.pending[0].SeqRange)
+		t.pending = t.pending[1:]
+	}
+	if confirmAfter > 0 {
+		kept := t.pending[:0]
+		for _, p := range t.pending {
+			if now.Sub(p.detectedAt) > confirmAfter {
+				obs.Lost = append(obs.Lost, p.SeqRange)
+				continue
+			}
+			kept = append(kept, p)
+		}
+		t.pending = kept
+	}
+	for _, r := range obs.Lost {
+		t.stats.PointsLost += r.Len()
+		t.markLost(r)
+	}
+	return obs
+}
+
+// fill removes seq from the pending ranges, reporting whether it was awaited.
+// Numbers at or below the highest seen that were not awaited are duplicates.
+func (t *sequenceTracker) fill(seq uint64) bool {
+	for i, p := range t.pending {
+		if seq < p.From || seq > p.To {
+			continue
+		}
+		var split []pendingSeqRange
+		if seq > p.From {
+			split = append(split, pendingSeqRange{SeqRange: SeqRange{From: p.From, To: seq - 1}, detectedAt: p.detectedAt})
+		}
+		if seq < p.To {
+			split = append(split, pendingSeqRange{SeqRange: SeqRange{From: seq + 1, To: p.To}, detectedAt: p.detectedAt})
+		}
+		t.pending = append(t.pending[:i], append(split, t.pending[i+1:]...)...)
+		for g := range t.gaps {
+			gap := &t.gaps[g]
+			if gap.Range != nil && seq >= gap.Range.From && seq <= gap.Range.To {
+				gap.Missing--
+				if gap.Missing == 0 {
+					gap.Status = SeqGapRecovered
+				}
+				break
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// markLost marks the recorded network gaps overlapping r lost.
+func (t *sequenceTracker) markLost(r SeqRange) {
+	for g := range t.gaps {
+		gap := &t.gaps[g]
+		if gap.Range != nil && gap.Status == SeqGapPending && r.From <= gap.Range.To && r.To >= gap.Range.From {
+			gap.Status = SeqGapLost
+		}
+	}
+}
+
+// ConfirmSequenceGap marks the awaited points in r lost, as when the device
+// reports it no longer holds them, and returns how many were awaited.
+func (s *TrackingSession) ConfirmSequenceGap(r SeqRange) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.sequence == nil {
+		return 0
+	}
+	t := s.sequence
+	confirmed := 0
+	kept := make([]pendingSeqRange, 0, len(t.pending)+1)
+	for _, p := range t.pending {
+		if r.To < p.From || r.From > p.To {
+			kept = append(kept, p)
+			continue
+		}
+		overlap := SeqRange{From: maxSeq(p.From, r.From), To: minSeq(p.To, r.To)}
+		confirmed += overlap.Len()
+		t.markLost(overlap)
+		if p.From < overlap.From {
+			kept = append(kept, pendingSeqRange{SeqRange: SeqRange{From: p.From, To: overlap.From - 1}, detectedAt: p.detectedAt})
+		}
+		if overlap.To < p.To {
+			kept = append(kept, pendingSeqRange{SeqRange: SeqRange{From: overlap.To + 1, To: p.To}, detectedAt: p.detectedAt})
+		}
+	}
+	t.pending = kept
+	t.stats.PointsLost += confirmed
+	return confirmed
+}
+
+// SequenceGaps returns the session's sequence gap statistics, or nil when its
+// device does not number its points. Points still awaited when the session
+// has completed are reported lost.
+func (s *TrackingSession) SequenceGaps() *SequenceGapStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.sequenceGapsLocked()
+}
+
+// dataGapsLocked returns the recorded gaps whose data is missing: GPS gaps,
+// and network gaps not fully recovered, with those still pending reported
+// lost once the session has completed. The caller holds s.mutex.
+func (s *TrackingSession) dataGapsLocked() []SequenceGap {
+	if s.sequence == nil {
+		return nil
+	}
+	var gaps []SequenceGap
+	for _, gap := range s.sequence.gaps {
+		if gap.Status == SeqGapRecovered {
+			continue
+		}
+		if gap.Status == SeqGapPending && s.status == SessionStatusCompleted {
+			gap.Status = SeqGapLost
+		}
+		if gap.Range != nil {
+			r := *gap.Range
+			gap.Range = &r
+		}
+		gaps = append(gaps, gap)
+	}
+	return gaps
+}
+
+// sequenceGapsLocked implements SequenceGaps; the caller holds s.mutex.
+func (s *TrackingSession) sequenceGapsLocked() *SequenceGapStats {
+	if s.sequence == nil {
+		return nil
+	}
+	stats := s.sequence.stats
+	pending := 0
+	for _, p := range s.sequence.pending {
+		pending += p.Len()
+	}
+	if s.status == SessionStatusCompleted {
+		stats.PointsLost += pending
+	} else {
+		stats.PointsPending = pending
+	}
+	return &stats
+}
+
+func minSeq(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxSeq(a, b uint64) uint64 {
+	if a > b {
+		return a
+	}
+	return b
+}
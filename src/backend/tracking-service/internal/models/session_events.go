@@ -0,0 +1,161 @@
+package models
+
+import (
+	// json for encoding event payloads (go1.21)
+	"encoding/json"
+	// errors for fold validation failures (standard library)
+	"errors"
+	// fmt for descriptive fold errors (standard library)
+	"fmt"
+	// time for event timestamps (go1.21)
+	"time"
+)
+
+// Session state event types recorded in event sourcing mode. Every change to a
+// TrackingSession is captured by exactly one of these.
+const (
+	EventSessionStarted    = "session.started"
+	EventSessionPaused     = "session.paused"
+	EventSessionResumed    = "session.resumed"
+	EventSessionCompleted  = "session.completed"
+	EventLocationsAppended = "locations.appended"
+)
+
+// SessionStateEvent is one entry in a session's append-only event stream.
+// Sequence is assigned by the event store and orders events within a session.
+type SessionStateEvent struct {
+	Sequence   int64           `json:"sequence"`
+	SessionID  string          `json:"sessionId"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// sessionStartedPayload carries everything needed to recreate a session.
+type sessionStartedPayload struct {
+	WalkID      string    `json:"walkId"`
+	WalkerID    string    `json:"walkerId"`
+	DogID       string    `json:"dogId"`
+	StartTime   time.Time `json:"startTime"`
+	HistoryMode string    `json:"historyMode"`
+	BufferSize  int       `json:"bufferSize"`
+	DeviceID    string    `json:"deviceId,omitempty"`
+	DeviceTrust string    `json:"deviceTrust,omitempty"`
+}
+
+// locationsAppendedPayload carries the locations accepted by the session, in
+// the order they were applied.
+type locationsAppendedPayload struct {
+	Locations []Location `json:"locations"`
+}
+
+// sessionCompletedPayload records when the session was completed.
+type sessionCompletedPayload struct {
+	EndTime time.Time `json:"endTime"`
+}
+
+// NewSessionStateEvent captures a state transition of s as an event of the given
+// type. For EventLocationsAppended, locations must hold the accepted points in
+// application order; it is ignored for other types.
+func NewSessionStateEvent(s *TrackingSession, eventType string, locations []Location) (*SessionStateEvent, error) {
+	s.mutex.Lock()
+	var payload interface{}
+	switch eventType {
+	case EventSessionStarted:
+		payload = sessionStartedPayload{
+			WalkID:      s.walkID,
+			WalkerID:    s.walkerID,
+			DogID:       s.dogID,
+			StartTime:   s.startTime,
+			HistoryMode: s.historyMode,
+			BufferSize:  s.bufferSize,
+			DeviceID:    s.deviceID,
+			DeviceTrust: s.deviceTrust,
+		}
+	case EventLocationsAppended:
+		payload = locationsAppendedPayload{Locations: locations}
+	case EventSessionCompleted:
+		payload = sessionCompletedPayload{EndTime: s.endTime}
+	case EventSessionPaused, EventSessionResumed:
+		payload = struct{}{}
+	default:
+		s.mutex.Unlock()
+		return nil, fmt.Errorf("unknown session event type %q", eventType)
+	}
+	s.mutex.Unlock()
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s payload: %w", eventType, err)
+	}
+	return &SessionStateEvent{
+		SessionID:  s.ID,
+		Type:       eventType,
+		OccurredAt: time.Now().UTC(),
+		Payload:    raw,
+	}, nil
+}
+
+// RebuildTrackingSession reconstructs a session by folding its events in
+// sequence order. The first event must be EventSessionStarted.
+//
+// Steps:
+//  1. Recreate the session from the started event
+//  2. Re-apply each appended location batch in recorded order
+//  3. Apply pause, resume and completion transitions
+//  4. Restore the last update time from the final event
+func RebuildTrackingSession(events []SessionStateEvent) (*TrackingSession, error) {
+	if len(events) == 0 {
+		return nil, errors.New("cannot rebuild session from an empty event stream")
+	}
+	if events[0].Type != EventSessionStarted {
+		return nil, fmt.Errorf("event stream must begin with %s, got %s", EventSessionStarted, events[0].Type)
+	}
+
+	var started sessionStartedPayload
+	if err := json.Unmarshal(events[0].Payload, &started); err != nil {
+		return nil, fmt.Errorf("invalid %s payload: %w", EventSessionStarted, err)
+	}
+	historyMode := started.HistoryMode
+	if historyMode == "" {
+		historyMode = HistoryModeBounded
+	}
+	s, err := newTrackingSession(started.WalkID, started.WalkerID, started.DogID, started.BufferSize, historyMode)
+	if err != nil {
+		return nil, err
+	}
+	s.ID = events[0].SessionID
+	s.startTime = started.StartTime
+	s.SetDevice(started.DeviceID, started.DeviceTrust)
+
+	for _, evt := range events[1:] {
+		switch evt.Type {
+		case EventLocationsAppended:
+			var batch locationsAppendedPayload
+			if err := json.Unmarshal(evt.Payload, &batch); err != nil {
+				return nil, fmt.Errorf("invalid %s payload at sequence %d: %w", evt.Type, evt.Sequence, err)
+			}
+			for i := range batch.Locations {
+				if err := s.AddLocation(&batch.Locations[i]); err != nil {
+					return nil, fmt.Errorf("failed to replay location at sequence %d: %w", evt.Sequence, err)
+				}
+			}
+		case EventSessionPaused:
+			s.status = SessionStatusPaused
+		case EventSessionResumed:
+			s.status = SessionStatusActive
+		case EventSessionCompleted:
+			var completed sessionCompletedPayload
+			if err := json.Unmarshal(evt.Payload, &completed); err != nil {
+				return nil, fmt.Errorf("invalid %s payload at sequence %d: %w", evt.Type, evt.Sequence, err)
+			}
+			s.endTime = completed.EndTime
+			s.duration = s.endTime.Sub(s.startTime)
+			s.status = SessionStatusCompleted
+		default:
+			return nil, fmt.Errorf("unknown session event type %q at sequence %d", evt.Type, evt.Sequence)
+		}
+		s.lastUpdateTime = evt.OccurredAt
+	}
+	return s, nil
+}
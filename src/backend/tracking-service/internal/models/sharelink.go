@@ -0,0 +1,15 @@
+package models
+
+import (
+	// time for the link expiry (go1.21)
+	"time"
+)
+
+// ShareLink is a signed, expiring grant of read-only access to a session's
+// live stream, which an owner shares with people following the walk. Token is
+// passed as the share query parameter of the stream endpoint.
+type ShareLink struct {
+	SessionID string    `json:"sessionId"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
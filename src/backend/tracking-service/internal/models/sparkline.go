@@ -0,0 +1,93 @@
+package models
+
+import (
+	// time for minute buckets (go1.21)
+	"time"
+)
+
+// MaxSparklineMinutes caps the length of an activity sparkline. Longer series
+// keep their most recent minutes.
+const MaxSparklineMinutes = 24 * 60
+
+// ActivityBucket is one minute of a walk's track as stored by the per-minute
+// continuous aggregate: the first and last fix recorded in the minute and the
+// number of fixes.
+type ActivityBucket struct {
+	Minute     time.Time
+	FirstLat   float64
+	FirstLon   float64
+	LastLat    float64
+	LastLon    float64
+	PointCount int
+}
+
+// ActivityMinute is one point of an activity sparkline.
+type ActivityMinute struct {
+	Minute time.Time `json:"minute"`
+
+	// DistanceMeters is the distance covered during the minute, including the
+	// step from the previous minute's last fix.
+	DistanceMeters float64 `json:"distanceMeters"`
+
+	// Points is the number of fixes recorded during the minute.
+	Points int `json:"points"`
+}
+
+// ActivitySparkline is a walk's downsampled distance-per-minute series, compact
+// enough for the owner app to chart without loading the full track.
+type ActivitySparkline struct {
+	WalkID string `json:"walkId"`
+
+	// Minutes runs from the first to the last minute with fixes, one entry per
+	// minute; minutes without fixes have zero distance.
+	Minutes []ActivityMinute `json:"minutes"`
+
+	// MaxDistanceMeters is the largest per-minute distance, for scaling the chart.
+	MaxDistanceMeters float64 `json:"maxDistanceMeters"`
+
+	// TotalDistanceMeters is the sum over Minutes.
+	TotalDistanceMeters float64 `json:"totalDistanceMeters"`
+}
+
+// BuildActivitySparkline turns per-minute buckets, ordered by minute, into a
+// gap-free sparkline. Each minute's distance is approximated by the straight
+// line from the previous bucket's last fix to its first fix plus the one from
+// its first to its last fix, which is accurate at walking pace.
+//
+// Steps:
+//  1. Compute each bucket's distance from its own and the previous bucket's fixes
+//  2. Zero-fill the minutes between buckets
+//  3. Keep the last MaxSparklineMinutes and total the series
+func BuildActivitySparkline(walkID string, buckets []ActivityBucket) *ActivitySparkline {
+	sparkline := &ActivitySparkline{WalkID: walkID, Minutes: []ActivityMinute{}}
+
+	for i, b := range buckets {
+		minute := b.Minute.UTC().Truncate(time.Minute)
+		distance := distanceBetweenPoints(b.FirstLat, b.FirstLon, b.LastLat, b.LastLon)
+		if i > 0 {
+			prev := buckets[i-1]
+			distance += distanceBetweenPoints(prev.LastLat, prev.LastLon, b.FirstLat, b.FirstLon)
+
+			last := sparkline.Minutes[len(sparkline.Minutes)-1].Minute
+			for gap := last.Add(time.Minute); gap.Before(minute); gap = gap.Add(time.Minute) {
+				sparkline.Minutes = append(sparkline.Minutes, ActivityMinute{Minute: gap})
+			}
+		}
+		sparkline.Minutes = append(sparkline.Minutes, ActivityMinute{
+			Minute:         minute,
+			DistanceMeters: distance,
+			Points:         b.PointCount,
+		})
+	}
+
+	if len(sparkline.Minutes) > MaxSparklineMinutes {
+		sparkline.Minutes = sparkline.Minutes[len(sparkline.Minutes)-MaxSparklineMinutes:]
+	}
+	for _, m := range sparkline.Minutes {
+		sparkline.TotalDistanceMeters += m.DistanceMeters
+		if m.DistanceMeters > sparkline.MaxDistanceMeters {
+			sparkline.MaxDistanceMeters = m.DistanceMeters
+		}
+	}
+	return sparkline
+}
@@ -11,8 +11,8 @@ import (
 	"math"
 	// errors for error creation (standard library)
 	"errors"
-	// uuid for generating unique identifiers (github.com/google/uuid v1.3.0)
-	"github.com/google/uuid"
+	// fmt for wrapping status transition errors (standard library)
+	"fmt"
 )
 
 // SessionStatusActive indicates an ongoing tracking session.
@@ -30,6 +30,28 @@ const MaxLocationHistorySize = 1000 // Maximum number of location points to stor
 // MinLocationAccuracy defines the minimum required GPS accuracy (in meters) for accepted locations.
 const MinLocationAccuracy = 10.0 // Minimum required GPS accuracy in meters
 
+// HistoryModeBounded rejects new locations once the in-memory history reaches its buffer size.
+const HistoryModeBounded = "bounded" // History mode that errors when the buffer is full
+
+// HistoryModeWindowed keeps only the most recent buffer-size locations in memory, overwriting
+// the oldest. Statistics come from running accumulators; full history lives in the database.
+const HistoryModeWindowed = "windowed" // History mode that keeps a ring buffer of recent points
+
+// ErrLocationAccuracyTooLow is returned by AddLocation for points whose
+// effective accuracy exceeds MinLocationAccuracy.
+var ErrLocationAccuracyTooLow = errors.New("location accuracy is too low to be added")
+
+// ErrInvalidStatusTransition is returned by Pause, Resume and SetStatus when
+// the session's current status does not allow the requested change.
+var ErrInvalidStatusTransition = errors.New("invalid session status transition")
+
+// ErrImplausibleSpeed is returned by AddLocation for points implying a faster
+// movement from the previous point than the session's speed limit.
+var ErrImplausibleSpeed = errors.New("location implies an implausible speed")
+
+// locationGapThreshold is the time between consecutive points treated as a tracking gap.
+const locationGapThreshold = 5 * time.Minute
+
 // TrackingSession represents an active dog walking tracking session with
 // location history, statistics, and enhanced validation. It is designed to be
 // thread-safe, ensuring concurrent access is properly managed with a mutex.
@@ -49,18 +71,72 @@ type TrackingSession struct {
 	// dogID references the dog involved in this walking session.
 	dogID string
 
+	// tenantID references the account the walker belongs to; empty when not given.
+	tenantID string
+
+	// settings are the limits resolved from the session's region when it started.
+	settings SessionSettings
+
+	// deviceID identifies the device reporting the session's data, and deviceTrust
+	// its trust level when the session started; both empty when not known.
+	deviceID    string
+	deviceTrust string
+
+	// prediction is the walk's expected duration and distance, attached in the
+	// background after the session starts; nil until then or when disabled.
+	prediction *WalkPrediction
+
 	// startTime captures the timestamp when the session was initiated.
 	startTime time.Time
 
 	// endTime captures the timestamp when the session was completed.
 	endTime time.Time
 
-	// locationHistory maintains all successfully recorded locations for this session.
+	// locationHistory maintains recorded locations for this session. In windowed mode it
+	// is a ring buffer whose oldest entry is at historyHead once it is full.
 	locationHistory []Location
 
+	// historyMode is HistoryModeBounded or HistoryModeWindowed.
+	historyMode string
+
+	// historyHead is the ring buffer index of the oldest point in windowed mode.
+	historyHead int
+
+	// lastLocation is the most recently added point, kept outside the ring buffer so
+	// distance and speed accumulate correctly after wraparound.
+	lastLocation *Location
+
+	// pointCount is the total number of points ever added, including evicted ones.
+	pointCount int
+
+	// accuracySum accumulates the accuracy of every added point.
+	accuracySum float64
+
+	// providerCounts counts added points by location provider.
+	providerCounts map[string]int
+
+	// minSpeed and maxSpeed track instantaneous speeds (m/s); minSpeed is -1 until set.
+	minSpeed float64
+	maxSpeed float64
+
+	// hasGaps records whether any consecutive points were further apart than locationGapThreshold,
+	// with no beacon sighting in between.
+	hasGaps bool
+
+	// sequence follows the device's per-point sequence numbers; nil until a
+	// numbered point is observed.
+	sequence *sequenceTracker
+
+	// lastBeaconTime is the time of the latest beacon sighting, so time spent indoors
+	// between fixes is not counted as a gap.
+	lastBeaconTime time.Time
+
 	// totalDistance tracks the cumulative distance covered (in meters).
 	totalDistance float64
 
+	// motion accumulates elevation, moving time and pace splits.
+	motion motionAccumulator
+
 	// duration represents the total duration of this session.
 	duration time.Duration
 
@@ -96,11 +172,77 @@ type TrackingStatistics struct {
 	// MinSpeed is the minimum instantaneous speed (meters/second) observed.
 	MinSpeed float64
 
-	locationPoints   int
-	startTime        time.Time
-	endTime          time.Time
-	averageAccuracy  float64
-	hasGaps          bool
+	// Coverage is the territory explored by the walk; nil until computed at completion.
+	Coverage *TerritoryCoverage
+
+	// StartAddress and EndAddress are the reverse-geocoded addresses of the first
+	// and last points; empty until resolved at completion.
+	StartAddress string
+	EndAddress   string
+
+	// Dog is the compact profile of the walked dog; nil until resolved at
+	// completion.
+	Dog *DogProfile
+
+	// Prediction is the walk's predicted duration and distance, and
+	// PredictionError how far the walk strayed from it once completed; both nil
+	// when no prediction was made.
+	Prediction      *WalkPrediction
+	PredictionError *PredictionError
+
+	// ProviderCounts is the number of accepted points by location provider,
+	// with "unknown" for points whose device did not report one.
+	ProviderCounts map[string]int
+
+	// ElevationGain and ElevationLoss are the total climb and descent in meters,
+	// from Location.Altitude, ignoring changes within GPS altitude noise.
+	ElevationGain float64
+	ElevationLoss float64
+
+	// MovingTime and StoppedTime divide the time between points by whether the
+	// walker moved faster than a slow stroll.
+	MovingTime  time.Duration
+	StoppedTime time.Duration
+
+	// PaceSplits is the time taken for each kilometer, ending with the partial
+	// kilometer in progress.
+	PaceSplits []PaceSplit
+
+	// SequenceGaps counts the points lost in transit and the periods without a
+	// fix, from the device's sequence numbers; nil when the device does not
+	// number its points.
+	SequenceGaps *SequenceGapStats
+
+	// HasGaps flags a track with data missing: consecutive points further
+	// apart than the tracking gap threshold with no beacon sighting between
+	// them, or, for devices that number their points, any of Gaps.
+	HasGaps bool
+
+	// Gaps are the intervals of the track missing data according to the
+	// device's sequence numbers: points lost in transit and not recovered,
+	// and pauses without a fix.
+	Gaps []SequenceGap
+
+	locationPoints  int
+	startTime       time.Time
+	endTime         time.Time
+	averageAccuracy float64
+}
+
+// TerritoryCoverage describes the area explored during a walk, used by the owner
+// app's gamification features.
+type TerritoryCoverage struct {
+	// AreaSqMeters is the area of the convex hull enclosing the walk, in square meters.
+	AreaSqMeters float64 `json:"areaSqMeters"`
+
+	// CellCount is the number of distinct territory grid cells the walk touched.
+	CellCount int `json:"cellCount"`
+
+	// NewCellCount is the number of those cells the dog had never visited before.
+	NewCellCount int `json:"newCellCount"`
+
+	// NewTerritoryPercent is NewCellCount as a percentage (0-100) of CellCount.
+	NewTerritoryPercent float64 `json:"newTerritoryPercent"`
 }
 
 // NewTrackingSession creates a new, thread-safe tracking session with initialized
@@ -117,12 +259,24 @@ type TrackingStatistics struct {
 //   8. Validate all input parameters
 //   9. Return error if validation fails
 func NewTrackingSession(walkID, walkerID, dogID string, bufferSize int) (*TrackingSession, error) {
+	return newTrackingSession(walkID, walkerID, dogID, bufferSize, HistoryModeBounded)
+}
+
+// NewWindowedTrackingSession creates a tracking session that keeps only the last
+// windowSize locations in memory, so long walks never fail AddLocation because the
+// buffer is full. Session statistics still cover every point ever added.
+func NewWindowedTrackingSession(walkID, walkerID, dogID string, windowSize int) (*TrackingSession, error) {
+	return newTrackingSession(walkID, walkerID, dogID, windowSize, HistoryModeWindowed)
+}
+
+// newTrackingSession validates input and builds a session in the given history mode.
+func newTrackingSession(walkID, walkerID, dogID string, bufferSize int, historyMode string) (*TrackingSession, error) {
 	if err := validateNewSessionInput(walkID, walkerID, dogID, bufferSize); err != nil {
 		return nil, err
 	}
 
 	session := &TrackingSession{
-		ID:             uuid.NewString(),
+		ID:             NewID(),
 		status:         SessionStatusActive,
 		walkID:         walkID,
 		walkerID:       walkerID,
@@ -130,6 +284,8 @@ func NewTrackingSession(walkID, walkerID, dogID string, bufferSize int) (*Tracki
 		startTime:      time.Now().UTC(),
 		endTime:        time.Time{}, // zero value until completed
 		locationHistory: make([]Location, 0, 0),
+		historyMode:     historyMode,
+		minSpeed:        -1,
 		totalDistance:   0.0,
 		duration:        0,
 		lastUpdateTime:  time.Now().UTC(),
@@ -147,19 +303,21 @@ func NewTrackingSession(walkID, walkerID, dogID string, bufferSize int) (*Tracki
 //   1. Acquire mutex lock
 //   2. Validate location data accuracy against MinLocationAccuracy
 //   3. Check if session status is "active"
-//   4. Verify that buffer capacity has not been exceeded
-//   5. Append the new location to the history
-//   6. Update total distance based on the last location (if any)
-//   7. Update last update time
-//   8. Release mutex lock
-//   9. Return nil if successful
+//   4. Reject movement faster than the session's speed limit, if it has one
+//   5. Verify that buffer capacity has not been exceeded (bounded mode)
+//   6. Append the new location, overwriting the oldest point in windowed mode
+//   7. Update total distance and summary accumulators from the last location (if any)
+//   8. Update last update time
+//   9. Release mutex lock
+//   10. Return nil if successful
 func (s *TrackingSession) AddLocation(loc *Location) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	// Ensure location has acceptable accuracy (less than or equal to MinLocationAccuracy).
-	if loc.Accuracy > MinLocationAccuracy {
-		return errors.New("location accuracy is too low to be added")
+	// Ensure location has acceptable accuracy (less than or equal to MinLocationAccuracy),
+	// weighted by its provider so network fixes must report a tighter radius.
+	if loc.EffectiveAccuracy() > MinLocationAccuracy {
+		return ErrLocationAccuracyTooLow
 	}
 
 	// Check if the session is active.
@@ -167,18 +325,35 @@ func (s *TrackingSession) AddLocation(loc *Location) error {
 		return errors.New("cannot add location because session is not active")
 	}
 
-	// If bufferSize is set and we have reached capacity, return an error.
-	if s.bufferSize > 0 && len(s.locationHistory) >= s.bufferSize {
-		return errors.New("location buffer is full, cannot add more points")
+	// Reject points the walker could not have reached from the previous one
+	// within the session's speed limit.
+	if s.settings.MaxSpeedKmh > 0 && s.lastLocation != nil {
+		prev := s.lastLocation
+		if timeDiff := loc.Timestamp.Sub(prev.Timestamp); timeDiff > 0 {
+			dist := distanceBetweenPoints(prev.Latitude, prev.Longitude, loc.Latitude, loc.Longitude)
+			if dist/timeDiff.Seconds()*3.6 > s.settings.MaxSpeedKmh {
+				return ErrImplausibleSpeed
+			}
+		}
 	}
 
-	// Append the location record to history.
-	s.locationHistory = append(s.locationHistory, *loc)
+	// If bufferSize is set and we have reached capacity, either overwrite the oldest
+	// point (windowed mode) or return an error (bounded mode).
+	if s.bufferSize > 0 && len(s.locationHistory) >= s.bufferSize {
+		if s.historyMode != HistoryModeWindowed {
+			return errors.New("location buffer is full, cannot add more points")
+		}
+		s.locationHistory[s.historyHead] = *loc
+		s.historyHead = (s.historyHead + 1) % s.bufferSize
+	} else {
+		s.locationHistory = append(s.locationHistory, *loc)
+	}
 
-	// If we have a previous location, compute the distance increment.
-	currLen := len(s.locationHistory)
-	if currLen > 1 {
-		prev := s.locationHistory[currLen-2]
+	// If we have a previous location, compute the distance increment and speed.
+	if s.lastLocation == nil {
+		s.motion.add(nil, loc, 0)
+	} else {
+		prev := s.lastLocation
 		dist := distanceBetweenPoints(
 			prev.Latitude,
 			prev.Longitude,
@@ -186,7 +361,30 @@ func (s *TrackingSession) AddLocation(loc *Location) error {
 			loc.Longitude,
 		)
 		s.totalDistance += dist
+		s.motion.add(prev, loc, dist)
+
+		timeDiff := loc.Timestamp.Sub(prev.Timestamp)
+		if timeDiff > 0 {
+			speed := dist / timeDiff.Seconds()
+			if s.minSpeed < 0 || speed < s.minSpeed {
+				s.minSpeed = speed
+			}
+			if speed > s.maxSpeed {
+				s.maxSpeed = speed
+			}
+		}
+		if loc.Timestamp.Sub(s.lastSeen()) > locationGapThreshold {
+			s.hasGaps = true
+		}
+	}
+	last := *loc
+	s.lastLocation = &last
+	s.pointCount++
+	s.accuracySum += loc.Accuracy
+	if s.providerCounts == nil {
+		s.providerCounts = make(map[string]int)
 	}
+	s.providerCounts[loc.ProviderLabel()]++
 
 	// Update the session duration based on StartTime and new location timestamp if valid.
 	if !loc.Timestamp.IsZero() && loc.Timestamp.After(s.startTime) {
@@ -199,6 +397,36 @@ func (s *TrackingSession) AddLocation(loc *Location) error {
 	return nil
 }
 
+// AddBeaconEvent records a beacon sighting for gap accounting: a sighting
+// between two fixes shows the walker was indoors rather than out of GPS
+// reach. Sightings are not part of the location history.
+func (s *TrackingSession) AddBeaconEvent(ev *BeaconEvent) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.status != SessionStatusActive {
+		return errors.New("cannot add beacon event because session is not active")
+	}
+	if seen := s.lastSeen(); !seen.IsZero() && ev.Timestamp.Sub(seen) > locationGapThreshold {
+		s.hasGaps = true
+	}
+	if ev.Timestamp.After(s.lastBeaconTime) {
+		s.lastBeaconTime = ev.Timestamp
+	}
+	s.lastUpdateTime = time.Now().UTC()
+	return nil
+}
+
+// lastSeen returns the time of the latest fix or beacon sighting, whichever is
+// later. The caller must hold the mutex.
+func (s *TrackingSession) lastSeen() time.Time {
+	seen := s.lastBeaconTime
+	if s.lastLocation != nil && s.lastLocation.Timestamp.After(seen) {
+		seen = s.lastLocation.Timestamp
+	}
+	return seen
+}
+
 // CalculateStatistics calculates comprehensive session metrics in a thread-safe
 // manner, returning a pointer to TrackingStatistics or an error if the
 // calculation fails.
@@ -208,7 +436,8 @@ func (s *TrackingSession) AddLocation(loc *Location) error {
 //   2. Calculate total distance from session data
 //   3. Calculate duration based on session times
 //   4. Compute average speed = totalDistance / duration
-//   5. Iterate over location history to find min/max speed, detect gaps, compute accuracy
+//   5. Read min/max speed, gaps, accuracy, elevation, moving time and pace
+//      splits from the running accumulators
 //   6. Release mutex lock
 //   7. Return the calculated statistics
 func (s *TrackingSession) CalculateStatistics() (*TrackingStatistics, error) {
@@ -216,16 +445,29 @@ func (s *TrackingSession) CalculateStatistics() (*TrackingStatistics, error) {
 	defer s.mutex.Unlock()
 
 	// If no location history, return minimal stats.
-	if len(s.locationHistory) == 0 {
+	if s.pointCount == 0 {
 		return &TrackingStatistics{}, nil
 	}
 
 	stats := &TrackingStatistics{
-		TotalDistance: s.totalDistance,
-		Duration:      s.duration,
-		locationPoints: len(s.locationHistory),
-		startTime:     s.startTime,
-		endTime:       s.endTime,
+		TotalDistance:   s.totalDistance,
+		Duration:        s.duration,
+		locationPoints:  s.pointCount,
+		startTime:       s.startTime,
+		endTime:         s.endTime,
+		averageAccuracy: s.accuracySum / float64(s.pointCount),
+		HasGaps:         s.hasGaps,
+		MaxSpeed:        s.maxSpeed,
+		ProviderCounts:  make(map[string]int, len(s.providerCounts)),
+	}
+	for provider, count := range s.providerCounts {
+		stats.ProviderCounts[provider] = count
+	}
+	s.motion.apply(stats)
+	stats.SequenceGaps = s.sequenceGapsLocked()
+	stats.Gaps = s.dataGapsLocked()
+	if len(stats.Gaps) > 0 {
+		stats.HasGaps = true
 	}
 
 	// If the session has no recorded endTime, we assume "now" if it is still active.
@@ -248,64 +490,130 @@ func (s *TrackingSession) CalculateStatistics() (*TrackingStatistics, error) {
 		stats.AverageSpeed = stats.TotalDistance / stats.Duration.Seconds()
 	}
 
-	// Initialize for min/max speed calculations.
-	var minSp float64 = -1
-	var maxSp float64
-	var totalAccuracy float64
-	stats.hasGaps = false
-
-	// We'll detect large time gaps (e.g., > 5 minutes) as "gaps".
-	const gapThreshold = 5 * 60.0
-
-	for i := 1; i < len(s.locationHistory); i++ {
-		currLoc := s.locationHistory[i]
-		prevLoc := s.locationHistory[i-1]
+	// Speeds are accumulated as points arrive so evicted points still count.
+	// If there was only one location or we couldn't compute speed at all, report 0.
+	if s.minSpeed >= 0 {
+		stats.MinSpeed = s.minSpeed
+	}
 
-		// Accumulate accuracy for average.
-		totalAccuracy += currLoc.Accuracy
+	if s.prediction != nil {
+		stats.Prediction = s.prediction
+		if s.status == SessionStatusCompleted {
+			stats.PredictionError = NewPredictionError(s.prediction, stats.Duration.Seconds(), stats.TotalDistance)
+		}
+	}
 
-		// Calculate the distance between consecutive points.
-		dist := distanceBetweenPoints(
-			prevLoc.Latitude,
-			prevLoc.Longitude,
-			currLoc.Latitude,
-			currLoc.Longitude,
-		)
-		timeDiff := currLoc.Timestamp.Sub(prevLoc.Timestamp).Seconds()
+	return stats, nil
+}
 
-		if timeDiff > 0 {
-			speed := dist / timeDiff
-			if minSp < 0 || speed < minSp {
-				minSp = speed
-			}
-			if speed > maxSp {
-				maxSp = speed
+// StatisticsAsOf recomputes the statistics the session reported at asOf from
+// track, its recorded points in chronological order, and beacons, its beacon
+// sightings, measuring segments with distance (haversine when nil). Points
+// and sightings after asOf, and points AddLocation would have rejected for low
+// accuracy, are ignored; the duration runs from the session start to asOf, or
+// to the end time if the session had already completed.
+func (s *TrackingSession) StatisticsAsOf(track []Location, beacons []BeaconEvent, asOf time.Time, distance DistanceCalculator) *TrackingStatistics {
+	if distance == nil {
+		distance = HaversineDistance{}
+	}
+	s.mutex.Lock()
+	start, end := s.startTime, s.endTime
+	s.mutex.Unlock()
+
+	stats := &TrackingStatistics{startTime: start}
+	var prev *Location
+	var accepted []Location
+	minSpeed := -1.0
+	var accuracySum float64
+	var motion motionAccumulator
+	for i := range track {
+		loc := &track[i]
+		if loc.Timestamp.After(asOf) {
+			break
+		}
+		if loc.EffectiveAccuracy() > MinLocationAccuracy {
+			continue
+		}
+		if prev == nil {
+			motion.add(nil, loc, 0)
+		} else {
+			dist := distance.Distance(prev.Latitude, prev.Longitude, loc.Latitude, loc.Longitude)
+			stats.TotalDistance += dist
+			motion.add(prev, loc, dist)
+
+			timeDiff := loc.Timestamp.Sub(prev.Timestamp)
+			if timeDiff > 0 {
+				speed := dist / timeDiff.Seconds()
+				if minSpeed < 0 || speed < minSpeed {
+					minSpeed = speed
+				}
+				if speed > stats.MaxSpeed {
+					stats.MaxSpeed = speed
+				}
 			}
 		}
-
-		// Check for time gap.
-		if timeDiff > gapThreshold {
-			stats.hasGaps = true
+		accepted = append(accepted, *loc)
+		accuracySum += loc.Accuracy
+		stats.locationPoints++
+		if stats.ProviderCounts == nil {
+			stats.ProviderCounts = make(map[string]int)
 		}
+		stats.ProviderCounts[loc.ProviderLabel()]++
+		prev = loc
 	}
+	if stats.locationPoints == 0 {
+		return &TrackingStatistics{}
+	}
+	stats.averageAccuracy = accuracySum / float64(stats.locationPoints)
+	motion.apply(stats)
 
-	// Also account for the first location's accuracy.
-	if len(s.locationHistory) > 0 {
-		totalAccuracy += s.locationHistory[0].Accuracy
+	var sightings []BeaconEvent
+	for _, ev := range beacons {
+		if !ev.Timestamp.After(asOf) {
+			sightings = append(sightings, ev)
+		}
 	}
+	stats.HasGaps = BuildSessionTimeline(s.ID, s.walkID, accepted, sightings).HasGaps
 
-	// Populate final metrics.
-	if minSp < 0 {
-		// If there was only one location or we couldn't compute speed at all.
-		minSp = 0
+	effectiveEnd := asOf
+	if !end.IsZero() && end.Before(asOf) {
+		effectiveEnd = end
+		stats.endTime = end
 	}
-	stats.MinSpeed = minSp
-	stats.MaxSpeed = maxSp
-	if len(s.locationHistory) > 0 {
-		stats.averageAccuracy = totalAccuracy / float64(len(s.locationHistory))
+	if effectiveEnd.After(start) {
+		stats.Duration = effectiveEnd.Sub(start)
 	}
+	if stats.Duration.Seconds() > 0 {
+		stats.AverageSpeed = stats.TotalDistance / stats.Duration.Seconds()
+	}
+	if minSpeed >= 0 {
+		stats.MinSpeed = minSpeed
+	}
+	return stats
+}
 
-	return stats, nil
+// PointCount returns the number of points the statistics were computed from:
+// every point the session accepted, including those evicted from its
+// in-memory history.
+func (st *TrackingStatistics) PointCount() int {
+	return st.locationPoints
+}
+
+// QualityScore rates how faithfully the walk was recorded, from 0 to 100:
+// up to 70 points for the average accuracy of its fixes, better than
+// MinLocationAccuracy earning more, and 30 points for a track without gaps.
+// Walks without points score 0.
+func (st *TrackingStatistics) QualityScore() float64 {
+	if st.locationPoints == 0 {
+		return 0
+	}
+	accuracy := 1 - st.averageAccuracy/MinLocationAccuracy
+	accuracy = math.Max(0, math.Min(1, accuracy))
+	score := 70 * accuracy
+	if !st.HasGaps {
+		score += 30
+	}
+	return score
 }
 
 // Complete marks the tracking session as completed and prepares it for archival.
@@ -329,8 +637,9 @@ func (s *TrackingSession) Complete() error {
 	// Mark the session's official end time.
 	s.endTime = time.Now().UTC()
 
-	// Calculate final stats (ignoring errors).
-	_, _ = s.CalculateStatistics()
+	// Record the final duration. CalculateStatistics acquires the mutex itself, so
+	// it must not be called while it is held here.
+	s.duration = s.endTime.Sub(s.startTime)
 
 	// Update the session status to completed.
 	s.status = SessionStatusCompleted
@@ -341,6 +650,51 @@ func (s *TrackingSession) Complete() error {
 	return nil
 }
 
+// Pause suspends an active session. Locations and beacon sightings are
+// rejected until it is resumed; a paused session can still be completed.
+func (s *TrackingSession) Pause() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.transitionLocked(SessionStatusActive, SessionStatusPaused)
+}
+
+// Resume reactivates a paused session.
+func (s *TrackingSession) Resume() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.transitionLocked(SessionStatusPaused, SessionStatusActive)
+}
+
+// SetStatus moves the session to the given status through the matching
+// transition: Pause for paused, Resume for active and Complete for completed.
+// Setting the current status again is a no-op.
+func (s *TrackingSession) SetStatus(status string) error {
+	if s.Status() == status {
+		return nil
+	}
+	switch status {
+	case SessionStatusPaused:
+		return s.Pause()
+	case SessionStatusActive:
+		return s.Resume()
+	case SessionStatusCompleted:
+		return s.Complete()
+	default:
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidStatusTransition, status)
+	}
+}
+
+// transitionLocked changes the status from one value to another. The caller
+// must hold the session mutex.
+func (s *TrackingSession) transitionLocked(from, to string) error {
+	if s.status != from {
+		return fmt.Errorf("%w: cannot move session from %s to %s", ErrInvalidStatusTransition, s.status, to)
+	}
+	s.status = to
+	s.lastUpdateTime = time.Now().UTC()
+	return nil
+}
+
 // ID returns the unique identifier for this session.
 func (s *TrackingSession) IDValue() string {
 	return s.ID
@@ -348,9 +702,107 @@ func (s *TrackingSession) IDValue() string {
 
 // Status returns the current status of the session.
 func (s *TrackingSession) Status() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
 	return s.status
 }
 
+// StartTime returns when the session started.
+func (s *TrackingSession) StartTime() time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.startTime
+}
+
+// LastUpdateTime returns when the session last accepted a location or beacon
+// sighting, or changed status.
+func (s *TrackingSession) LastUpdateTime() time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lastUpdateTime
+}
+
+// WalkID returns the identifier of the dog walk this session tracks.
+func (s *TrackingSession) WalkID() string {
+	return s.walkID
+}
+
+// WalkerID returns the identifier of the walker managing this session.
+func (s *TrackingSession) WalkerID() string {
+	return s.walkerID
+}
+
+// DogID returns the identifier of the dog being walked in this session.
+func (s *TrackingSession) DogID() string {
+	return s.dogID
+}
+
+// TenantID returns the identifier of the account the walker belongs to, empty
+// when the session was started without one.
+func (s *TrackingSession) TenantID() string {
+	return s.tenantID
+}
+
+// SetTenantID records the account the walker belongs to. It must be called
+// before the session is shared.
+func (s *TrackingSession) SetTenantID(tenantID string) {
+	s.tenantID = tenantID
+}
+
+// Settings returns the limits resolved for the session when it started.
+func (s *TrackingSession) Settings() SessionSettings {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.settings
+}
+
+// SetSettings records the limits resolved from the session's region. It must
+// be called before the session is shared.
+func (s *TrackingSession) SetSettings(settings SessionSettings) {
+	s.settings = settings
+}
+
+// DeviceID returns the identifier of the device reporting the session's data,
+// empty when the session was started without one.
+func (s *TrackingSession) DeviceID() string {
+	return s.deviceID
+}
+
+// DeviceTrust returns the trust level of the session's device when the session
+// started, one of DeviceTrusted, DeviceUntrusted and DeviceUnknown, or empty
+// when devices are not checked.
+func (s *TrackingSession) DeviceTrust() string {
+	return s.deviceTrust
+}
+
+// SetDevice records the device reporting the session's data and its trust
+// level. It must be called before the session is shared.
+func (s *TrackingSession) SetDevice(deviceID, trust string) {
+	s.deviceID = deviceID
+	s.deviceTrust = trust
+}
+
+// LocationHistory returns a copy of the in-memory locations for this session in
+// chronological order.
+func (s *TrackingSession) LocationHistory() []Location {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	history := make([]Location, 0, len(s.locationHistory))
+	history = append(history, s.locationHistory[s.historyHead:]...)
+	history = append(history, s.locationHistory[:s.historyHead]...)
+	return history
+}
+
+// HistoryTruncated reports whether older points have been evicted from the
+// in-memory window, meaning LocationHistory no longer covers the whole walk.
+func (s *TrackingSession) HistoryTruncated() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.pointCount > len(s.locationHistory)
+}
+
 // MarshalJSON provides a custom JSON representation of TrackingSession with
 // necessary fields. The location history is omitted to reduce payload size
 // unless needed in specialized endpoints.
@@ -365,18 +817,28 @@ func (s *TrackingSession) MarshalJSON() ([]byte, error) {
 		WalkID        string    `json:"walkId"`
 		WalkerID      string    `json:"walkerId"`
 		DogID         string    `json:"dogId"`
+		TenantID      string    `json:"tenantId,omitempty"`
+		Region        string    `json:"region,omitempty"`
+		DeviceID      string    `json:"deviceId,omitempty"`
+		DeviceTrust   string    `json:"deviceTrust,omitempty"`
 		StartTime     time.Time `json:"startTime"`
 		EndTime       time.Time `json:"endTime"`
 		TotalDistance float64   `json:"totalDistance"`
 		Duration      float64   `json:"durationSeconds"`
 		LastUpdate    time.Time `json:"lastUpdateTime"`
 		IsArchived    bool      `json:"isArchived"`
+
+		Prediction *WalkPrediction `json:"prediction,omitempty"`
 	}{
 		ID:            s.ID,
 		Status:        s.status,
 		WalkID:        s.walkID,
 		WalkerID:      s.walkerID,
 		DogID:         s.dogID,
+		TenantID:      s.tenantID,
+		Region:        s.settings.Region,
+		DeviceID:      s.deviceID,
+		DeviceTrust:   s.deviceTrust,
 		StartTime:     s.startTime,
 		EndTime:       s.endTime,
 		TotalDistance: s.totalDistance,
@@ -384,6 +846,7 @@ func (s *TrackingSession) MarshalJSON() ([]byte, error) {
 		Duration:   s.duration.Seconds(),
 		LastUpdate: s.lastUpdateTime,
 		IsArchived: s.isArchived,
+		Prediction: s.prediction,
 	}
 
 	return json.Marshal(temp)
@@ -0,0 +1,737 @@
+package repository
+
+import (
+	// errors: Combining primary and shadow close errors (go1.21)
+	"errors"
+	// rand: Sampling reads for comparison (go1.21)
+	"math/rand"
+	// reflect: Deep comparison of primary and shadow read results (go1.21)
+	"reflect"
+	// time: Time-bounded history reads (go1.21)
+	"time"
+
+	// prometheus: Shadow write and read divergence metrics (v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+	// zap: Logging read divergences (v1.24.0)
+	"go.uber.org/zap"
+
+	// Internal models containing Location and TrackingSession definitions
+	"src/backend/tracking-service/internal/models"
+)
+
+// maxConcurrentComparisons bounds the shadow reads in flight for comparison;
+// sampled reads beyond it are skipped rather than queued.
+const maxConcurrentComparisons = 16
+
+// Store is the persistence API the tracking service depends on. It is
+// implemented by TimescaleRepository and by DualWriteRepository, which lets a
+// migration put a second backend behind the same calls.
+type Store interface {
+	SaveLocation(location *models.Location) error
+	BatchSaveLocations(locations []*models.Location) error
+	GetLocationHistory(walkID string) ([]models.Location, error)
+	GetLocationHistoryUntil(walkID string, until time.Time) ([]models.Location, error)
+	GetLocationHistoryPage(walkID string, after models.PageCursor, limit int, filter models.HistoryFilter) ([]models.Location, error)
+	GetLocationAt(walkID string, t time.Time) (*models.Location, error)
+	GetSessionStatistics(walkID string) (*models.TrackingStatistics, error)
+	SaveTerritoryCoverage(walkID, dogID string, coverage *models.TerritoryCoverage, cells []string) error
+	GetTerritoryCoverage(walkID string) (*models.TerritoryCoverage, error)
+	GetDogTerritoryCells(dogID, excludeWalkID string) (map[string]struct{}, error)
+	AppendSessionEvent(evt *models.SessionStateEvent) error
+	GetSessionEvents(sessionID string) ([]models.SessionStateEvent, error)
+	GetSessionEventsBetween(sessionID string, from, to time.Time) ([]models.SessionStateEvent, error)
+	GetSessionEventsPage(sessionID string, after models.PageCursor, limit int) ([]models.SessionStateEvent, error)
+	SampleCompletedSessions(since time.Time, limit int) ([]string, error)
+	SaveFitnessToken(token *models.FitnessToken) error
+	GetFitnessTokensForDog(dogID string) ([]models.FitnessToken, error)
+	RecordRouteSegments(walkID string, segments []models.RouteSegment) error
+	GetPopularRouteSegments(bbox models.BoundingBox, minWalks, limit int) ([]models.PopularRouteSegment, error)
+	RecordWalkStart(walkID, cell string, lat, lon float64, startedAt time.Time) error
+	GetDemandBuckets(bbox models.BoundingBox, since time.Time, loc *time.Location, minStarts int) ([]models.DemandBucket, error)
+	GetActivitySparkline(walkID string) (*models.ActivitySparkline, error)
+	QueryLocations(q models.LocationQuery) (*models.LocationQueryResult, error)
+	SaveGeofence(geofence *models.GeofenceRecord) error
+	GetGeofences(walkID string) ([]models.GeofenceRecord, error)
+	SaveGeofenceGroup(group *models.GeofenceGroupRecord) error
+	GetGeofenceGroups() ([]models.GeofenceGroupRecord, error)
+	DeleteGeofenceGroup(name string) error
+	RecordWalkSummary(summary *models.WalkSummary) error
+	HasWalkSummary(walkID string, since time.Time) (bool, error)
+	GetRecentWalkSummaries(dogID, walkerID string, since time.Time, limit int) ([]models.WalkSummary, error)
+	GetWalkerLeaderboard(tenantID string, from, to time.Time, rankBy string, limit int) ([]models.LeaderboardEntry, error)
+	SaveBeaconEvents(events []models.BeaconEvent) error
+	GetBeaconEvents(sessionID string) ([]models.BeaconEvent, error)
+	RecordWalkTransition(change *models.CurrentWalkChange) error
+	GetCurrentWalks(tenantID string) ([]models.CurrentWalk, error)
+	GetCurrentWalksPage(tenantID string, after models.PageCursor, limit int) ([]models.CurrentWalk, error)
+	GetCurrentWalkChanges(afterSeq int64, limit int) ([]models.CurrentWalkChange, error)
+	SaveRegionProfile(profile *models.RegionProfile) error
+	GetRegionProfile(region string) (*models.RegionProfile, error)
+	GetRegionProfiles() ([]models.RegionProfile, error)
+	DeleteRegionProfile(region string) error
+	RecordWalkRegion(walkID, region string) error
+	PurgeRegionLocations(region string, cutoff time.Time, dryRun bool) (*models.RetentionRows, error)
+	ManageRetention(dryRun bool) (*models.RetentionReport, error)
+	SaveOwnerAlertPreference(pref *models.OwnerAlertPreference) error
+	GetOwnerAlertPreference(ownerID, dogID string) (*models.OwnerAlertPreference, error)
+	GetOwnerAlertPreferencesForDog(dogID string) ([]models.OwnerAlertPreference, error)
+	DeleteOwnerAlertPreference(ownerID, dogID string) error
+	SaveDevice(device *models.Device) error
+	GetDevice(walkerID, deviceID string) (*models.Device, error)
+	GetWalkerDevices(walkerID string) ([]models.Device, error)
+	RevokeDevice(walkerID, deviceID string, at time.Time) error
+	SaveQuarantinedPoints(points []models.QuarantinedPoint) error
+	GetQuarantinedPoints(query models.QuarantineQuery) ([]models.QuarantinedPoint, error)
+	GetQuarantinedPointsByID(ids []string) ([]models.QuarantinedPoint, error)
+	DeleteQuarantinedPoints(ids []string) error
+	SaveEscrowedKey(key models.EscrowedStreamKey) error
+	GetEscrowedKeys(sessionID string) ([]models.EscrowedStreamKey, error)
+	SaveEscrowAccess(record models.EscrowAccessRecord) error
+	GetEscrowAccessLog(tenantID string, limit int) ([]models.EscrowAccessRecord, error)
+	SaveWalkAccess(record models.WalkAccessRecord) error
+	GetWalkAccessLog(walkID string, limit int) ([]models.WalkAccessRecord, error)
+	Close() error
+}
+
+var _ Store = (*TimescaleRepository)(nil)
+
+// DualWriteRepository is the Store used during a storage migration. Every
+// write goes to the primary and then, if it succeeded, to the shadow; shadow
+// failures are counted but never returned, so the primary stays the source of
+// truth. Reads are served from the primary, and a sample of them is repeated
+// against the shadow in the background and compared to measure divergence
+// before cutting over.
+//
+// Results are compared with reflect.DeepEqual, so both backends must return
+// values in the same form, e.g. timestamps in the same time zone.
+type DualWriteRepository struct {
+	primary    Store
+	shadow     Store
+	sampleRate float64
+	logger     *zap.Logger
+
+	comparisons chan struct{}
+
+	shadowWrites *prometheus.CounterVec
+	readCompares *prometheus.CounterVec
+}
+
+// NewDualWriteRepository wraps primary and shadow, comparing sampleRate of
+// reads (0 disables comparison, 1 compares all). Metrics are registered on
+// registry when it is non-nil.
+func NewDualWriteRepository(primary, shadow Store, sampleRate float64, logger *zap.Logger, registry *prometheus.Registry) (*DualWriteRepository, error) {
+	if primary == nil || shadow == nil {
+		return nil, invalidInput("dual-write requires both a primary and a shadow store")
+	}
+	if sampleRate < 0 || sampleRate > 1 {
+		return nil, invalidInput("read sample rate %v must be between 0 and 1", sampleRate)
+	}
+	d := &DualWriteRepository{
+		primary:     primary,
+		shadow:      shadow,
+		sampleRate:  sampleRate,
+		logger:      logger,
+		comparisons: make(chan struct{}, maxConcurrentComparisons),
+		shadowWrites: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tracking_dualwrite_shadow_writes_total",
+				Help: "Writes mirrored to the shadow store, by operation and outcome (ok or error).",
+			},
+			[]string{"op", "outcome"},
+		),
+		readCompares: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tracking_dualwrite_read_comparisons_total",
+				Help: "Sampled reads compared between primary and shadow stores, by operation and result (match, mismatch, shadow_error or skipped).",
+			},
+			[]string{"op", "result"},
+		),
+	}
+	if registry != nil {
+		registry.MustRegister(d.shadowWrites, d.readCompares)
+	}
+	return d, nil
+}
+
+// mirrorWrite applies a write to the shadow once it succeeded on the primary.
+func (d *DualWriteRepository) mirrorWrite(op string, primaryErr error, shadowWrite func() error) error {
+	if primaryErr != nil {
+		return primaryErr
+	}
+	if err := shadowWrite(); err != nil {
+		d.shadowWrites.WithLabelValues(op, "error").Inc()
+		d.logger.Warn("Shadow store write failed", zap.String("op", op), zap.Error(err))
+		return nil
+	}
+	d.shadowWrites.WithLabelValues(op, "ok").Inc()
+	return nil
+}
+
+// compareRead repeats a successful primary read against the shadow for a
+// sample of calls, in the background, and records whether the results match.
+// Primary and shadow errors wrapping ErrNotFound count as matching results.
+func (d *DualWriteRepository) compareRead(op string, primaryResult interface{}, primaryErr error, shadowRead func() (interface{}, error)) {
+	if d.sampleRate == 0 || rand.Float64() >= d.sampleRate {
+		return
+	}
+	primaryMissing := errors.Is(primaryErr, ErrNotFound)
+	if primaryErr != nil && !primaryMissing {
+		return
+	}
+	select {
+	case d.comparisons <- struct{}{}:
+	default:
+		d.readCompares.WithLabelValues(op, "skipped").Inc()
+		return
+	}
+
+	go func() {
+		defer func() { <-d.comparisons }()
+		shadowResult, shadowErr := shadowRead()
+		switch {
+		case primaryMissing && errors.Is(shadowErr, ErrNotFound):
+			d.readCompares.WithLabelValues(op, "match").Inc()
+		case shadowErr != nil && !errors.Is(shadowErr, ErrNotFound):
+			d.readCompares.WithLabelValues(op, "shadow_error").Inc()
+			d.logger.Warn("Shadow store read failed", zap.String("op", op), zap.Error(shadowErr))
+		case !primaryMissing && shadowErr == nil && reflect.DeepEqual(primaryResult, shadowResult):
+			d.readCompares.WithLabelValues(op, "match").Inc()
+		default:
+			d.readCompares.WithLabelValues(op, "mismatch").Inc()
+			d.logger.Warn("Shadow store read diverged from primary",
+				zap.String("op", op),
+				zap.Bool("primaryFound", !primaryMissing),
+				zap.Bool("shadowFound", shadowErr == nil),
+			)
+		}
+	}()
+}
+
+// SaveLocation implements Store.
+func (d *DualWriteRepository) SaveLocation(location *models.Location) error {
+	return d.mirrorWrite("SaveLocation", d.primary.SaveLocation(location), func() error {
+		return d.shadow.SaveLocation(location)
+	})
+}
+
+// BatchSaveLocations implements Store.
+func (d *DualWriteRepository) BatchSaveLocations(locations []*models.Location) error {
+	return d.mirrorWrite("BatchSaveLocations", d.primary.BatchSaveLocations(locations), func() error {
+		return d.shadow.BatchSaveLocations(locations)
+	})
+}
+
+// GetLocationHistory implements Store.
+func (d *DualWriteRepository) GetLocationHistory(walkID string) ([]models.Location, error) {
+	locations, err := d.primary.GetLocationHistory(walkID)
+	d.compareRead("GetLocationHistory", locations, err, func() (interface{}, error) {
+		return d.shadow.GetLocationHistory(walkID)
+	})
+	return locations, err
+}
+
+// GetLocationHistoryPage implements Store.
+func (d *DualWriteRepository) GetLocationHistoryPage(walkID string, after models.PageCursor, limit int, filter models.HistoryFilter) ([]models.Location, error) {
+	locations, err := d.primary.GetLocationHistoryPage(walkID, after, limit, filter)
+	d.compareRead("GetLocationHistoryPage", locations, err, func() (interface{}, error) {
+		return d.shadow.GetLocationHistoryPage(walkID, after, limit, filter)
+	})
+	return locations, err
+}
+
+// GetLocationHistoryUntil implements Store.
+func (d *DualWriteRepository) GetLocationHistoryUntil(walkID string, until time.Time) ([]models.Location, error) {
+	locations, err := d.primary.GetLocationHistoryUntil(walkID, until)
+	d.compareRead("GetLocationHistoryUntil", locations, err, func() (interface{}, error) {
+		return d.shadow.GetLocationHistoryUntil(walkID, until)
+	})
+	return locations, err
+}
+
+// GetLocationAt implements Store.
+func (d *DualWriteRepository) GetLocationAt(walkID string, t time.Time) (*models.Location, error) {
+	location, err := d.primary.GetLocationAt(walkID, t)
+	d.compareRead("GetLocationAt", location, err, func() (interface{}, error) {
+		return d.shadow.GetLocationAt(walkID, t)
+	})
+	return location, err
+}
+
+// GetSessionStatistics implements Store.
+func (d *DualWriteRepository) GetSessionStatistics(walkID string) (*models.TrackingStatistics, error) {
+	stats, err := d.primary.GetSessionStatistics(walkID)
+	d.compareRead("GetSessionStatistics", stats, err, func() (interface{}, error) {
+		return d.shadow.GetSessionStatistics(walkID)
+	})
+	return stats, err
+}
+
+// SaveTerritoryCoverage implements Store.
+func (d *DualWriteRepository) SaveTerritoryCoverage(walkID, dogID string, coverage *models.TerritoryCoverage, cells []string) error {
+	return d.mirrorWrite("SaveTerritoryCoverage", d.primary.SaveTerritoryCoverage(walkID, dogID, coverage, cells), func() error {
+		return d.shadow.SaveTerritoryCoverage(walkID, dogID, coverage, cells)
+	})
+}
+
+// GetTerritoryCoverage implements Store.
+func (d *DualWriteRepository) GetTerritoryCoverage(walkID string) (*models.TerritoryCoverage, error) {
+	coverage, err := d.primary.GetTerritoryCoverage(walkID)
+	d.compareRead("GetTerritoryCoverage", coverage, err, func() (interface{}, error) {
+		return d.shadow.GetTerritoryCoverage(walkID)
+	})
+	return coverage, err
+}
+
+// GetDogTerritoryCells implements Store.
+func (d *DualWriteRepository) GetDogTerritoryCells(dogID, excludeWalkID string) (map[string]struct{}, error) {
+	cells, err := d.primary.GetDogTerritoryCells(dogID, excludeWalkID)
+	d.compareRead("GetDogTerritoryCells", cells, err, func() (interface{}, error) {
+		return d.shadow.GetDogTerritoryCells(dogID, excludeWalkID)
+	})
+	return cells, err
+}
+
+// AppendSessionEvent implements Store.
+func (d *DualWriteRepository) AppendSessionEvent(evt *models.SessionStateEvent) error {
+	return d.mirrorWrite("AppendSessionEvent", d.primary.AppendSessionEvent(evt), func() error {
+		return d.shadow.AppendSessionEvent(evt)
+	})
+}
+
+// GetSessionEvents implements Store.
+func (d *DualWriteRepository) GetSessionEvents(sessionID string) ([]models.SessionStateEvent, error) {
+	events, err := d.primary.GetSessionEvents(sessionID)
+	d.compareRead("GetSessionEvents", events, err, func() (interface{}, error) {
+		return d.shadow.GetSessionEvents(sessionID)
+	})
+	return events, err
+}
+
+// GetSessionEventsPage implements Store. Cursor IDs are sequences assigned by the
+// primary, so shadow pages may differ when the stores' sequences diverge.
+func (d *DualWriteRepository) GetSessionEventsPage(sessionID string, after models.PageCursor, limit int) ([]models.SessionStateEvent, error) {
+	events, err := d.primary.GetSessionEventsPage(sessionID, after, limit)
+	d.compareRead("GetSessionEventsPage", events, err, func() (interface{}, error) {
+		return d.shadow.GetSessionEventsPage(sessionID, after, limit)
+	})
+	return events, err
+}
+
+// SampleCompletedSessions implements Store. The sample is random, so it is
+// read from the primary only.
+func (d *DualWriteRepository) SampleCompletedSessions(since time.Time, limit int) ([]string, error) {
+	return d.primary.SampleCompletedSessions(since, limit)
+}
+
+// GetSessionEventsBetween implements Store.
+func (d *DualWriteRepository) GetSessionEventsBetween(sessionID string, from, to time.Time) ([]models.SessionStateEvent, error) {
+	events, err := d.primary.GetSessionEventsBetween(sessionID, from, to)
+	d.compareRead("GetSessionEventsBetween", events, err, func() (interface{}, error) {
+		return d.shadow.GetSessionEventsBetween(sessionID, from, to)
+	})
+	return events, err
+}
+
+// SaveFitnessToken implements Store.
+func (d *DualWriteRepository) SaveFitnessToken(token *models.FitnessToken) error {
+	return d.mirrorWrite("SaveFitnessToken", d.primary.SaveFitnessToken(token), func() error {
+		return d.shadow.SaveFitnessToken(token)
+	})
+}
+
+// GetFitnessTokensForDog implements Store.
+func (d *DualWriteRepository) GetFitnessTokensForDog(dogID string) ([]models.FitnessToken, error) {
+	tokens, err := d.primary.GetFitnessTokensForDog(dogID)
+	d.compareRead("GetFitnessTokensForDog", tokens, err, func() (interface{}, error) {
+		return d.shadow.GetFitnessTokensForDog(dogID)
+	})
+	return tokens, err
+}
+
+// RecordRouteSegments implements Store.
+func (d *DualWriteRepository) RecordRouteSegments(walkID string, segments []models.RouteSegment) error {
+	return d.mirrorWrite("RecordRouteSegments", d.primary.RecordRouteSegments(walkID, segments), func() error {
+		return d.shadow.RecordRouteSegments(walkID, segments)
+	})
+}
+
+// GetPopularRouteSegments implements Store.
+func (d *DualWriteRepository) GetPopularRouteSegments(bbox models.BoundingBox, minWalks, limit int) ([]models.PopularRouteSegment, error) {
+	segments, err := d.primary.GetPopularRouteSegments(bbox, minWalks, limit)
+	d.compareRead("GetPopularRouteSegments", segments, err, func() (interface{}, error) {
+		return d.shadow.GetPopularRouteSegments(bbox, minWalks, limit)
+	})
+	return segments, err
+}
+
+// RecordWalkStart implements Store.
+func (d *DualWriteRepository) RecordWalkStart(walkID, cell string, lat, lon float64, startedAt time.Time) error {
+	return d.mirrorWrite("RecordWalkStart", d.primary.RecordWalkStart(walkID, cell, lat, lon, startedAt), func() error {
+		return d.shadow.RecordWalkStart(walkID, cell, lat, lon, startedAt)
+	})
+}
+
+// GetDemandBuckets implements Store.
+func (d *DualWriteRepository) GetDemandBuckets(bbox models.BoundingBox, since time.Time, loc *time.Location, minStarts int) ([]models.DemandBucket, error) {
+	buckets, err := d.primary.GetDemandBuckets(bbox, since, loc, minStarts)
+	d.compareRead("GetDemandBuckets", buckets, err, func() (interface{}, error) {
+		return d.shadow.GetDemandBuckets(bbox, since, loc, minStarts)
+	})
+	return buckets, err
+}
+
+// GetActivitySparkline implements Store.
+func (d *DualWriteRepository) GetActivitySparkline(walkID string) (*models.ActivitySparkline, error) {
+	sparkline, err := d.primary.GetActivitySparkline(walkID)
+	d.compareRead("GetActivitySparkline", sparkline, err, func() (interface{}, error) {
+		return d.shadow.GetActivitySparkline(walkID)
+	})
+	return sparkline, err
+}
+
+// QueryLocations implements Store.
+func (d *DualWriteRepository) QueryLocations(q models.LocationQuery) (*models.LocationQueryResult, error) {
+	result, err := d.primary.QueryLocations(q)
+	d.compareRead("QueryLocations", result, err, func() (interface{}, error) {
+		return d.shadow.QueryLocations(q)
+	})
+	return result, err
+}
+
+// SaveGeofence implements Store.
+func (d *DualWriteRepository) SaveGeofence(geofence *models.GeofenceRecord) error {
+	return d.mirrorWrite("SaveGeofence", d.primary.SaveGeofence(geofence), func() error {
+		return d.shadow.SaveGeofence(geofence)
+	})
+}
+
+// GetGeofences implements Store.
+func (d *DualWriteRepository) GetGeofences(walkID string) ([]models.GeofenceRecord, error) {
+	geofences, err := d.primary.GetGeofences(walkID)
+	d.compareRead("GetGeofences", geofences, err, func() (interface{}, error) {
+		return d.shadow.GetGeofences(walkID)
+	})
+	return geofences, err
+}
+
+// SaveGeofenceGroup implements Store.
+func (d *DualWriteRepository) SaveGeofenceGroup(group *models.GeofenceGroupRecord) error {
+	return d.mirrorWrite("SaveGeofenceGroup", d.primary.SaveGeofenceGroup(group), func() error {
+		return d.shadow.SaveGeofenceGroup(group)
+	})
+}
+
+// GetGeofenceGroups implements Store.
+func (d *DualWriteRepository) GetGeofenceGroups() ([]models.GeofenceGroupRecord, error) {
+	groups, err := d.primary.GetGeofenceGroups()
+	d.compareRead("GetGeofenceGroups", groups, err, func() (interface{}, error) {
+		return d.shadow.GetGeofenceGroups()
+	})
+	return groups, err
+}
+
+// DeleteGeofenceGroup implements Store.
+func (d *DualWriteRepository) DeleteGeofenceGroup(name string) error {
+	return d.mirrorWrite("DeleteGeofenceGroup", d.primary.DeleteGeofenceGroup(name), func() error {
+		return d.shadow.DeleteGeofenceGroup(name)
+	})
+}
+
+// RecordWalkSummary implements Store.
+func (d *DualWriteRepository) RecordWalkSummary(summary *models.WalkSummary) error {
+	return d.mirrorWrite("RecordWalkSummary", d.primary.RecordWalkSummary(summary), func() error {
+		return d.shadow.RecordWalkSummary(summary)
+	})
+}
+
+// HasWalkSummary implements Store.
+func (d *DualWriteRepository) HasWalkSummary(walkID string, since time.Time) (bool, error) {
+	exists, err := d.primary.HasWalkSummary(walkID, since)
+	d.compareRead("HasWalkSummary", exists, err, func() (interface{}, error) {
+		return d.shadow.HasWalkSummary(walkID, since)
+	})
+	return exists, err
+}
+
+// GetRecentWalkSummaries implements Store.
+func (d *DualWriteRepository) GetRecentWalkSummaries(dogID, walkerID string, since time.Time, limit int) ([]models.WalkSummary, error) {
+	summaries, err := d.primary.GetRecentWalkSummaries(dogID, walkerID, since, limit)
+	d.compareRead("GetRecentWalkSummaries", summaries, err, func() (interface{}, error) {
+		return d.shadow.GetRecentWalkSummaries(dogID, walkerID, since, limit)
+	})
+	return summaries, err
+}
+
+// GetWalkerLeaderboard implements Store.
+func (d *DualWriteRepository) GetWalkerLeaderboard(tenantID string, from, to time.Time, rankBy string, limit int) ([]models.LeaderboardEntry, error) {
+	entries, err := d.primary.GetWalkerLeaderboard(tenantID, from, to, rankBy, limit)
+	d.compareRead("GetWalkerLeaderboard", entries, err, func() (interface{}, error) {
+		return d.shadow.GetWalkerLeaderboard(tenantID, from, to, rankBy, limit)
+	})
+	return entries, err
+}
+
+// SaveBeaconEvents implements Store.
+func (d *DualWriteRepository) SaveBeaconEvents(events []models.BeaconEvent) error {
+	return d.mirrorWrite("SaveBeaconEvents", d.primary.SaveBeaconEvents(events), func() error {
+		return d.shadow.SaveBeaconEvents(events)
+	})
+}
+
+// GetBeaconEvents implements Store.
+func (d *DualWriteRepository) GetBeaconEvents(sessionID string) ([]models.BeaconEvent, error) {
+	events, err := d.primary.GetBeaconEvents(sessionID)
+	d.compareRead("GetBeaconEvents", events, err, func() (interface{}, error) {
+		return d.shadow.GetBeaconEvents(sessionID)
+	})
+	return events, err
+}
+
+// RecordWalkTransition implements Store. The shadow assigns its own outbox sequences, so
+// feed cursors are only meaningful against the store that issued them.
+func (d *DualWriteRepository) RecordWalkTransition(change *models.CurrentWalkChange) error {
+	return d.mirrorWrite("RecordWalkTransition", d.primary.RecordWalkTransition(change), func() error {
+		shadowChange := *change
+		return d.shadow.RecordWalkTransition(&shadowChange)
+	})
+}
+
+// GetCurrentWalks implements Store.
+func (d *DualWriteRepository) GetCurrentWalks(tenantID string) ([]models.CurrentWalk, error) {
+	walks, err := d.primary.GetCurrentWalks(tenantID)
+	d.compareRead("GetCurrentWalks", walks, err, func() (interface{}, error) {
+		return d.shadow.GetCurrentWalks(tenantID)
+	})
+	return walks, err
+}
+
+// GetCurrentWalksPage implements Store.
+func (d *DualWriteRepository) GetCurrentWalksPage(tenantID string, after models.PageCursor, limit int) ([]models.CurrentWalk, error) {
+	walks, err := d.primary.GetCurrentWalksPage(tenantID, after, limit)
+	d.compareRead("GetCurrentWalksPage", walks, err, func() (interface{}, error) {
+		return d.shadow.GetCurrentWalksPage(tenantID, after, limit)
+	})
+	return walks, err
+}
+
+// GetCurrentWalkChanges implements Store. Outbox sequences differ between the stores, so
+// reads are not compared.
+func (d *DualWriteRepository) GetCurrentWalkChanges(afterSeq int64, limit int) ([]models.CurrentWalkChange, error) {
+	return d.primary.GetCurrentWalkChanges(afterSeq, limit)
+}
+
+// SaveRegionProfile implements Store.
+func (d *DualWriteRepository) SaveRegionProfile(profile *models.RegionProfile) error {
+	return d.mirrorWrite("SaveRegionProfile", d.primary.SaveRegionProfile(profile), func() error {
+		return d.shadow.SaveRegionProfile(profile)
+	})
+}
+
+// GetRegionProfile implements Store.
+func (d *DualWriteRepository) GetRegionProfile(region string) (*models.RegionProfile, error) {
+	profile, err := d.primary.GetRegionProfile(region)
+	d.compareRead("GetRegionProfile", profile, err, func() (interface{}, error) {
+		return d.shadow.GetRegionProfile(region)
+	})
+	return profile, err
+}
+
+// GetRegionProfiles implements Store.
+func (d *DualWriteRepository) GetRegionProfiles() ([]models.RegionProfile, error) {
+	profiles, err := d.primary.GetRegionProfiles()
+	d.compareRead("GetRegionProfiles", profiles, err, func() (interface{}, error) {
+		return d.shadow.GetRegionProfiles()
+	})
+	return profiles, err
+}
+
+// DeleteRegionProfile implements Store.
+func (d *DualWriteRepository) DeleteRegionProfile(region string) error {
+	return d.mirrorWrite("DeleteRegionProfile", d.primary.DeleteRegionProfile(region), func() error {
+		return d.shadow.DeleteRegionProfile(region)
+	})
+}
+
+// RecordWalkRegion implements Store.
+func (d *DualWriteRepository) RecordWalkRegion(walkID, region string) error {
+	return d.mirrorWrite("RecordWalkRegion", d.primary.RecordWalkRegion(walkID, region), func() error {
+		return d.shadow.RecordWalkRegion(walkID, region)
+	})
+}
+
+// PurgeRegionLocations implements Store. The shadow's own summary is not
+// reported, and a dry run only reads the primary.
+func (d *DualWriteRepository) PurgeRegionLocations(region string, cutoff time.Time, dryRun bool) (*models.RetentionRows, error) {
+	purged, err := d.primary.PurgeRegionLocations(region, cutoff, dryRun)
+	if dryRun {
+		return purged, err
+	}
+	mirrorErr := d.mirrorWrite("PurgeRegionLocations", err, func() error {
+		_, shadowErr := d.shadow.PurgeRegionLocations(region, cutoff, false)
+		return shadowErr
+	})
+	return purged, mirrorErr
+}
+
+// ManageRetention implements Store. The shadow's own report is not returned,
+// and a dry run only reads the primary.
+func (d *DualWriteRepository) ManageRetention(dryRun bool) (*models.RetentionReport, error) {
+	report, err := d.primary.ManageRetention(dryRun)
+	if dryRun {
+		return report, err
+	}
+	mirrorErr := d.mirrorWrite("ManageRetention", err, func() error {
+		_, shadowErr := d.shadow.ManageRetention(false)
+		return shadowErr
+	})
+	return report, mirrorErr
+}
+
+// SaveOwnerAlertPreference implements Store.
+func (d *DualWriteRepository) SaveOwnerAlertPreference(pref *models.OwnerAlertPreference) error {
+	return d.mirrorWrite("SaveOwnerAlertPreference", d.primary.SaveOwnerAlertPreference(pref), func() error {
+		return d.shadow.SaveOwnerAlertPreference(pref)
+	})
+}
+
+// GetOwnerAlertPreference implements Store.
+func (d *DualWriteRepository) GetOwnerAlertPreference(ownerID, dogID string) (*models.OwnerAlertPreference, error) {
+	pref, err := d.primary.GetOwnerAlertPreference(ownerID, dogID)
+	d.compareRead("GetOwnerAlertPreference", pref, err, func() (interface{}, error) {
+		return d.shadow.GetOwnerAlertPreference(ownerID, dogID)
+	})
+	return pref, err
+}
+
+// GetOwnerAlertPreferencesForDog implements Store.
+func (d *DualWriteRepository) GetOwnerAlertPreferencesForDog(dogID string) ([]models.OwnerAlertPreference, error) {
+	prefs, err := d.primary.GetOwnerAlertPreferencesForDog(dogID)
+	d.compareRead("GetOwnerAlertPreferencesForDog", prefs, err, func() (interface{}, error) {
+		return d.shadow.GetOwnerAlertPreferencesForDog(dogID)
+	})
+	return prefs, err
+}
+
+// DeleteOwnerAlertPreference implements Store.
+func (d *DualWriteRepository) DeleteOwnerAlertPreference(ownerID, dogID string) error {
+	return d.mirrorWrite("DeleteOwnerAlertPreference", d.primary.DeleteOwnerAlertPreference(ownerID, dogID), func() error {
+		return d.shadow.DeleteOwnerAlertPreference(ownerID, dogID)
+	})
+}
+
+// SaveDevice implements Store.
+func (d *DualWriteRepository) SaveDevice(device *models.Device) error {
+	return d.mirrorWrite("SaveDevice", d.primary.SaveDevice(device), func() error {
+		return d.shadow.SaveDevice(device)
+	})
+}
+
+// GetDevice implements Store.
+func (d *DualWriteRepository) GetDevice(walkerID, deviceID string) (*models.Device, error) {
+	device, err := d.primary.GetDevice(walkerID, deviceID)
+	d.compareRead("GetDevice", device, err, func() (interface{}, error) {
+		return d.shadow.GetDevice(walkerID, deviceID)
+	})
+	return device, err
+}
+
+// GetWalkerDevices implements Store.
+func (d *DualWriteRepository) GetWalkerDevices(walkerID string) ([]models.Device, error) {
+	devices, err := d.primary.GetWalkerDevices(walkerID)
+	d.compareRead("GetWalkerDevices", devices, err, func() (interface{}, error) {
+		return d.shadow.GetWalkerDevices(walkerID)
+	})
+	return devices, err
+}
+
+// RevokeDevice implements Store.
+func (d *DualWriteRepository) RevokeDevice(walkerID, deviceID string, at time.Time) error {
+	return d.mirrorWrite("RevokeDevice", d.primary.RevokeDevice(walkerID, deviceID, at), func() error {
+		return d.shadow.RevokeDevice(walkerID, deviceID, at)
+	})
+}
+
+// SaveQuarantinedPoints implements Store.
+func (d *DualWriteRepository) SaveQuarantinedPoints(points []models.QuarantinedPoint) error {
+	return d.mirrorWrite("SaveQuarantinedPoints", d.primary.SaveQuarantinedPoints(points), func() error {
+		return d.shadow.SaveQuarantinedPoints(points)
+	})
+}
+
+// GetQuarantinedPoints implements Store.
+func (d *DualWriteRepository) GetQuarantinedPoints(query models.QuarantineQuery) ([]models.QuarantinedPoint, error) {
+	points, err := d.primary.GetQuarantinedPoints(query)
+	d.compareRead("GetQuarantinedPoints", points, err, func() (interface{}, error) {
+		return d.shadow.GetQuarantinedPoints(query)
+	})
+	return points, err
+}
+
+// GetQuarantinedPointsByID implements Store.
+func (d *DualWriteRepository) GetQuarantinedPointsByID(ids []string) ([]models.QuarantinedPoint, error) {
+	points, err := d.primary.GetQuarantinedPointsByID(ids)
+	d.compareRead("GetQuarantinedPointsByID", points, err, func() (interface{}, error) {
+		return d.shadow.GetQuarantinedPointsByID(ids)
+	})
+	return points, err
+}
+
+// DeleteQuarantinedPoints implements Store.
+func (d *DualWriteRepository) DeleteQuarantinedPoints(ids []string) error {
+	return d.mirrorWrite("DeleteQuarantinedPoints", d.primary.DeleteQuarantinedPoints(ids), func() error {
+		return d.shadow.DeleteQuarantinedPoints(ids)
+	})
+}
+
+// SaveEscrowedKey implements Store.
+func (d *DualWriteRepository) SaveEscrowedKey(key models.EscrowedStreamKey) error {
+	return d.mirrorWrite("SaveEscrowedKey", d.primary.SaveEscrowedKey(key), func() error {
+		return d.shadow.SaveEscrowedKey(key)
+	})
+}
+
+// GetEscrowedKeys implements Store.
+func (d *DualWriteRepository) GetEscrowedKeys(sessionID string) ([]models.EscrowedStreamKey, error) {
+	keys, err := d.primary.GetEscrowedKeys(sessionID)
+	d.compareRead("GetEscrowedKeys", keys, err, func() (interface{}, error) {
+		return d.shadow.GetEscrowedKeys(sessionID)
+	})
+	return keys, err
+}
+
+// SaveEscrowAccess implements Store.
+func (d *DualWriteRepository) SaveEscrowAccess(record models.EscrowAccessRecord) error {
+	return d.mirrorWrite("SaveEscrowAccess", d.primary.SaveEscrowAccess(record), func() error {
+		return d.shadow.SaveEscrowAccess(record)
+	})
+}
+
+// GetEscrowAccessLog implements Store.
+func (d *DualWriteRepository) GetEscrowAccessLog(tenantID string, limit int) ([]models.EscrowAccessRecord, error) {
+	records, err := d.primary.GetEscrowAccessLog(tenantID, limit)
+	d.compareRead("GetEscrowAccessLog", records, err, func() (interface{}, error) {
+		return d.shadow.GetEscrowAccessLog(tenantID, limit)
+	})
+	return records, err
+}
+
+// SaveWalkAccess implements Store.
+func (d *DualWriteRepository) SaveWalkAccess(record models.WalkAccessRecord) error {
+	return d.mirrorWrite("SaveWalkAccess", d.primary.SaveWalkAccess(record), func() error {
+		return d.shadow.SaveWalkAccess(record)
+	})
+}
+
+// GetWalkAccessLog implements Store.
+func (d *DualWriteRepository) GetWalkAccessLog(walkID string, limit int) ([]models.WalkAccessRecord, error) {
+	records, err := d.primary.GetWalkAccessLog(walkID, limit)
+	d.compareRead("GetWalkAccessLog", records, err, func() (interface{}, error) {
+		return d.shadow.GetWalkAccessLog(walkID, limit)
+	})
+	return records, err
+}
+
+// Close closes both stores.
+func (d *DualWriteRepository) Close() error {
+	return errors.Join(d.primary.Close(), d.shadow.Close())
+}
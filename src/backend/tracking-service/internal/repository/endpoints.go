@@ -0,0 +1,401 @@
+package repository
+
+import (
+	// context: Bounding endpoint health probes (go1.21)
+	"context"
+	// sql: Probe handles of the endpoints (go1.21)
+	"database/sql"
+	// driver: Connector routing new connections to the preferred endpoint (go1.21)
+	"database/sql/driver"
+	// net: Formatting endpoint addresses (go1.21)
+	"net"
+	// sort: Ordering same-zone endpoints first (go1.21)
+	"sort"
+	// strconv: Formatting endpoint ports (go1.21)
+	"strconv"
+	// sync: Guarding endpoint health and stopping the prober (go1.21)
+	"sync"
+	// time: Health probe schedule and timeout (go1.21)
+	"time"
+
+	// pq: PostgreSQL connectors for each endpoint (v1.10.9)
+	"github.com/lib/pq"
+	// prometheus: Endpoint health and traffic metrics (v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+	// zap: Logging endpoint health transitions (v1.24.0)
+	"go.uber.org/zap"
+)
+
+// DBEndpoint is one address the database is reachable at, in an availability
+// zone.
+type DBEndpoint struct {
+	Zone string
+	Host string
+	Port int
+}
+
+// Address returns the endpoint's host:port.
+func (e DBEndpoint) Address() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+}
+
+// EndpointRouter routes database connections to the preferred of several
+// endpoints of the same database, such as the per-zone endpoints of a multi-AZ
+// deployment. Endpoints in the local zone are preferred, then the others in
+// the order given. Every endpoint is probed periodically; an endpoint that
+// fails a probe or a connection attempt is skipped until it answers again, and
+// connections to a less preferred endpoint are retired once a more preferred
+// one recovers. Metrics report each endpoint's health, which one is preferred,
+// and the connections and requests each served.
+type EndpointRouter struct {
+	endpoints  []DBEndpoint
+	connectors []driver.Connector
+	probes     []*sql.DB
+	byAddress  map[string]int
+	logger     *zap.Logger
+
+	mu sync.RWMutex
+	up []bool
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+
+	endpointUp  *prometheus.GaugeVec
+	preferred   *prometheus.GaugeVec
+	connections *prometheus.CounterVec
+	requests    *prometheus.CounterVec
+}
+
+// NewEndpointRouter creates a router over endpoints, preferring those in
+// zone, connecting with the DSN dsn returns for each. Endpoints are assumed
+// healthy until probed. Metrics are registered on registry when it is
+// non-nil.
+func NewEndpointRouter(zone string, endpoints []DBEndpoint, dsn func(DBEndpoint) string, logger *zap.Logger, registry *prometheus.Registry) (*EndpointRouter, error) {
+	if len(endpoints) == 0 {
+		return nil, invalidInput("endpoint routing requires at least one endpoint")
+	}
+	ordered := append([]DBEndpoint(nil), endpoints...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Zone == zone && ordered[j].Zone != zone
+	})
+
+	r := &EndpointRouter{
+		endpoints: ordered,
+		byAddress: make(map[string]int, len(ordered)),
+		logger:    logger,
+		up:        make([]bool, len(ordered)),
+		stop:      make(chan struct{}),
+		endpointUp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "tracking_db_endpoint_up",
+			Help: "Whether the database endpoint answered its last health probe or connection attempt",
+		}, []string{"endpoint", "zone"}),
+		preferred: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "tracking_db_endpoint_preferred",
+			Help: "1 for the database endpoint new connections are routed to",
+		}, []string{"endpoint", "zone"}),
+		connections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tracking_db_endpoint_connections_total",
+			Help: "Database connections opened, by endpoint and outcome (connected or failed)",
+		}, []string{"endpoint", "zone", "outcome"}),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tracking_db_endpoint_requests_total",
+			Help: "Database statements, transactions and pool acquisitions served, by endpoint",
+		}, []string{"endpoint", "zone"}),
+	}
+	for i, ep := range ordered {
+		if _, dup := r.byAddress[ep.Address()]; dup {
+			return nil, invalidInput("endpoint %s is listed twice", ep.Address())
+		}
+		connector, err := pq.NewConnector(dsn(ep))
+		if err != nil {
+			return nil, invalidInput("invalid DSN for endpoint %s: %v", ep.Address(), err)
+		}
+		probe := sql.OpenDB(connector)
+		probe.SetMaxOpenConns(1)
+		probe.SetMaxIdleConns(1)
+
+		r.byAddress[ep.Address()] = i
+		r.connectors = append(r.connectors, connector)
+		r.probes = append(r.probes, probe)
+		r.up[i] = true
+		r.endpointUp.WithLabelValues(ep.Address(), ep.Zone).Set(1)
+	}
+	r.updatePreferred()
+
+	if registry != nil {
+		registry.MustRegister(r.endpointUp, r.preferred, r.connections, r.requests)
+	}
+	return r, nil
+}
+
+// Start probes every endpoint each interval, each probe bounded by timeout,
+// until Stop.
+func (r *EndpointRouter) Start(interval, timeout time.Duration) {
+	r.done = make(chan struct{})
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			r.probeAll(timeout)
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop ends health probing, if started, and closes the probe handles.
+func (r *EndpointRouter) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.stop)
+	})
+	if r.done != nil {
+		<-r.done
+	}
+	for _, probe := range r.probes {
+		_ = probe.Close()
+	}
+}
+
+// probeAll pings every endpoint concurrently and records whether it answered.
+func (r *EndpointRouter) probeAll(timeout time.Duration) {
+	var wg sync.WaitGroup
+	for i := range r.endpoints {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			r.setUp(i, r.probes[i].PingContext(ctx))
+		}(i)
+	}
+	wg.Wait()
+}
+
+// setUp records the health of endpoint i: up when err is nil.
+func (r *EndpointRouter) setUp(i int, err error) {
+	up := err == nil
+	r.mu.Lock()
+	changed := r.up[i] != up
+	r.up[i] = up
+	r.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	ep := r.endpoints[i]
+	if up {
+		r.endpointUp.WithLabelValues(ep.Address(), ep.Zone).Set(1)
+		r.logger.Info("Database endpoint recovered", zap.String("endpoint", ep.Address()), zap.String("zone", ep.Zone))
+	} else {
+		r.endpointUp.WithLabelValues(ep.Address(), ep.Zone).Set(0)
+		r.logger.Warn("Database endpoint is unavailable", zap.String("endpoint", ep.Address()), zap.String("zone", ep.Zone), zap.Error(err))
+	}
+	r.updatePreferred()
+}
+
+// preferredIndex returns the first healthy endpoint, or the first endpoint
+// when none is healthy.
+func (r *EndpointRouter) preferredIndex() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for i, up := range r.up {
+		if up {
+			return i
+		}
+	}
+	return 0
+}
+
+// updatePreferred reports the preferred endpoint in its gauge.
+func (r *EndpointRouter) updatePreferred() {
+	preferred := r.preferredIndex()
+	for i, ep := range r.endpoints {
+		value := 0.0
+		if i == preferred {
+			value = 1
+		}
+		r.preferred.WithLabelValues(ep.Address(), ep.Zone).Set(value)
+	}
+}
+
+// Preferred returns the endpoint new connections should go to: the first
+// healthy endpoint in preference order, or the most preferred one when none
+// is healthy.
+func (r *EndpointRouter) Preferred() DBEndpoint {
+	return r.endpoints[r.preferredIndex()]
+}
+
+// IsPreferred reports whether ep is the preferred endpoint, so connections to
+// other endpoints can be retired.
+func (r *EndpointRouter) IsPreferred(ep DBEndpoint) bool {
+	i, ok := r.byAddress[ep.Address()]
+	return ok && i == r.preferredIndex()
+}
+
+// ReportConnect records the outcome of a connection attempt to ep made outside
+// the router, such as by a pgx pool: a failure marks ep unavailable at once,
+// without waiting for the next probe.
+func (r *EndpointRouter) ReportConnect(ep DBEndpoint, err error) {
+	i, ok := r.byAddress[ep.Address()]
+	if !ok {
+		return
+	}
+	r.recordConnect(i, err)
+}
+
+// RecordRequest counts a request served by ep.
+func (r *EndpointRouter) RecordRequest(ep DBEndpoint) {
+	if i, ok := r.byAddress[ep.Address()]; ok {
+		r.served(i)
+	}
+}
+
+// recordConnect counts a connection attempt to endpoint i and records the
+// endpoint's health from its outcome.
+func (r *EndpointRouter) recordConnect(i int, err error) {
+	ep := r.endpoints[i]
+	outcome := "connected"
+	if err != nil {
+		outcome = "failed"
+	}
+	r.connections.WithLabelValues(ep.Address(), ep.Zone, outcome).Inc()
+	r.setUp(i, err)
+}
+
+// served counts a request served by endpoint i.
+func (r *EndpointRouter) served(i int) {
+	ep := r.endpoints[i]
+	r.requests.WithLabelValues(ep.Address(), ep.Zone).Inc()
+}
+
+// order returns the endpoint indexes to try connecting to: the healthy ones in
+// preference order, then the others, which may have recovered since.
+func (r *EndpointRouter) order() []int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	order := make([]int, 0, len(r.up))
+	for i, up := range r.up {
+		if up {
+			order = append(order, i)
+		}
+	}
+	for i, up := range r.up {
+		if !up {
+			order = append(order, i)
+		}
+	}
+	return order
+}
+
+// Connector returns a database/sql connector opening each connection on the
+// preferred endpoint, failing over to the next when it cannot be reached.
+// Pooled connections to an endpoint that is no longer preferred are discarded
+// when next used.
+func (r *EndpointRouter) Connector() driver.Connector {
+	return routedConnector{router: r}
+}
+
+// routedConnector is the driver.Connector returned by EndpointRouter.Connector.
+type routedConnector struct {
+	router *EndpointRouter
+}
+
+// Connect implements driver.Connector.
+func (c routedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	r := c.router
+	var lastErr error
+	for _, i := range r.order() {
+		conn, err := r.connectors[i].Connect(ctx)
+		r.recordConnect(i, err)
+		if err == nil {
+			return &routedConn{Conn: conn, router: r, endpoint: i}, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// Driver implements driver.Connector.
+func (c routedConnector) Driver() driver.Driver {
+	return c.router.connectors[0].Driver()
+}
+
+// routedConn is a connection to one of a router's endpoints. It forwards the
+// optional driver interfaces of the pq connection it wraps, counts the
+// requests it serves, and reports itself invalid once its endpoint is no
+// longer preferred so the pool replaces it.
+type routedConn struct {
+	driver.Conn
+	router   *EndpointRouter
+	endpoint int
+}
+
+// QueryContext implements driver.QueryerContext.
+func (c *routedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	c.router.served(c.endpoint)
+	return queryer.QueryContext(ctx, query, args)
+}
+
+// ExecContext implements driver.ExecerContext.
+func (c *routedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	execer, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	c.router.served(c.endpoint)
+	return execer.ExecContext(ctx, query, args)
+}
+
+// PrepareContext implements driver.ConnPrepareContext.
+func (c *routedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	c.router.served(c.endpoint)
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+// BeginTx implements driver.ConnBeginTx.
+func (c *routedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.router.served(c.endpoint)
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+// Ping implements driver.Pinger.
+func (c *routedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+// ResetSession implements driver.SessionResetter.
+func (c *routedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+// IsValid implements driver.Validator.
+func (c *routedConn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok && !validator.IsValid() {
+		return false
+	}
+	return c.router.preferredIndex() == c.endpoint
+}
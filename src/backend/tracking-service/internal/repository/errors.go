@@ -0,0 +1,48 @@
+package repository
+
+import (
+	// sql: Recognising driver-level "no rows" results (go1.21)
+	"database/sql"
+	// errors: Sentinel errors and error matching (go1.21)
+	"errors"
+	// fmt: Wrapping errors with context (go1.21)
+	"fmt"
+)
+
+// ErrInvalidInput is returned when arguments fail validation before any query
+// is issued, such as an empty identifier or an out-of-range accuracy.
+var ErrInvalidInput = errors.New("repository: invalid input")
+
+// ErrNotFound is returned when the requested record does not exist. Errors
+// wrapping it also wrap sql.ErrNoRows when that is the underlying cause.
+var ErrNotFound = errors.New("repository: not found")
+
+// ErrRetryExhausted is returned when an operation failed on every attempt.
+// LastErr holds the error from the final attempt and is exposed via Unwrap.
+type ErrRetryExhausted struct {
+	Attempts int
+	LastErr  error
+}
+
+// Error implements the error interface.
+func (e *ErrRetryExhausted) Error() string {
+	return fmt.Sprintf("repository: gave up after %d attempts: %v", e.Attempts, e.LastErr)
+}
+
+// Unwrap returns the error from the final attempt.
+func (e *ErrRetryExhausted) Unwrap() error {
+	return e.LastErr
+}
+
+// invalidInput builds an ErrInvalidInput error describing the failed check.
+func invalidInput(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidInput, fmt.Sprintf(format, args...))
+}
+
+// notFoundOr translates sql.ErrNoRows into ErrNotFound and returns other errors unchanged.
+func notFoundOr(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	}
+	return err
+}
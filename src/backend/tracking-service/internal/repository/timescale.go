@@ -3,10 +3,22 @@ package repository
 import (
 	// sql: Core database operations with transaction management (go1.21)
 	"database/sql"
-	// pq: PostgreSQL driver with TimescaleDB extension support (v1.10.9)
-	_ "github.com/lib/pq"
+	// json: Encoding polygon geofence vertices (go1.21)
+	"encoding/json"
+	// errors: Matching repository error types (go1.21)
+	"errors"
+	// fmt: Wrapping validation and lookup errors (go1.21)
+	"fmt"
+	// pq: PostgreSQL driver with TimescaleDB extension support and array binding (v1.10.9)
+	"github.com/lib/pq"
+	// strconv: Parsing event sequences from page cursors (go1.21)
+	"strconv"
+	// strings: Building request coalescing keys (go1.21)
+	"strings"
 	// time: Time operations for tracking data and retention policies (go1.21)
 	"time"
+	// singleflight: Coalescing of identical concurrent read queries (v0.4.0)
+	"golang.org/x/sync/singleflight"
 	// geom: Geospatial operations and distance calculations (v1.5.2)
 	"github.com/twpayne/go-geom"
 
@@ -23,9 +35,92 @@ const locationTableName = "location_points" // TimescaleDB hypertable name for l
 // sessionTableName is the database table that stores tracking session metadata.
 const sessionTableName = "tracking_sessions" // Table name for tracking sessions
 
+// territoryTableName stores the territory coverage computed for each completed walk.
+const territoryTableName = "walk_territory" // Table name for per-walk territory coverage
+
+// dogTerritoryCellsTableName stores every territory grid cell a dog has visited.
+const dogTerritoryCellsTableName = "dog_territory_cells" // Table name for a dog's explored cells
+
+// sessionEventsTableName is the append-only event stream backing event sourcing mode.
+const sessionEventsTableName = "session_events" // Table name for session state events
+
+// fitnessTokensTableName stores owners' OAuth tokens for fitness platform uploads.
+const fitnessTokensTableName = "fitness_tokens" // Table name for fitness platform tokens
+
+// routeSegmentsTableName aggregates, per grid segment, how many walks crossed it.
+const routeSegmentsTableName = "route_segments" // Table name for the route popularity layer
+
+// routeWalksTableName records the walks already counted into route_segments.
+const routeWalksTableName = "route_walks" // Table name for walks aggregated into route popularity
+
+// demandStartsTableName aggregates, per demand grid cell and hour, how many walks started there.
+const demandStartsTableName = "demand_starts" // Table name for walk start demand
+
+// demandWalksTableName records the walks already counted into demand_starts.
+const demandWalksTableName = "demand_walks" // Table name for walks aggregated into demand
+
+// geofencesTableName stores the circular and polygon geofences defined for walks.
+const geofencesTableName = "geofences" // Table name for walk geofences
+
+const activityMinutesViewName = "location_activity_minutes" // Continuous aggregate of per-minute walk activity
+
+// walkSummariesTableName records each completed walk of a tenant's walkers for the leaderboard.
+const walkSummariesTableName = "walk_summaries" // Hypertable of completed walk summaries
+
+const walkerDailyStatsViewName = "walker_daily_stats" // Continuous aggregate of per-walker daily totals
+
+// beaconEventsTableName records the beacon sightings reported during sessions, for indoor presence.
+const beaconEventsTableName = "beacon_events" // Hypertable of beacon proximity events
+
+// currentWalksTableName holds one row per running session, kept in step with its transitions.
+const currentWalksTableName = "current_walks" // Table of currently running walks
+
+// currentWalkChangesTableName is the outbox of current_walks changes behind the change feed.
+const currentWalkChangesTableName = "current_walk_changes" // Outbox of current walk changes
+
+// regionProfilesTableName stores the settings of each region that differ from the base configuration.
+const regionProfilesTableName = "region_profiles" // Table of regional configuration profiles
+
+// walkRegionsTableName records the region each walk was started in, for regional retention.
+const walkRegionsTableName = "walk_regions" // Table mapping walks to their regions
+
+// ownerAlertsTableName stores owners' alert radii, one row per owner and dog.
+const ownerAlertsTableName = "owner_alert_preferences" // Table of owner alert preferences
+
+// trustedDevicesTableName stores the devices walkers paired, one row per walker and device.
+const trustedDevicesTableName = "trusted_devices" // Table of walkers' paired devices
+
+// quarantinedPointsTableName stores location points rejected at ingestion, with the reason.
+const quarantinedPointsTableName = "quarantined_points" // Table of quarantined location points
+
+// streamKeyEscrowTableName stores session stream keys wrapped under the escrow public key.
+const streamKeyEscrowTableName = "stream_key_escrow" // Table of escrowed stream keys
+
+// escrowAccessLogTableName stores every access attempt to escrowed stream keys.
+const escrowAccessLogTableName = "escrow_access_log" // Table of escrow accesses
+
+// walkAccessLogTableName stores every read of a walk's location data, for its owner.
+const walkAccessLogTableName = "walk_access_log" // Table of walk data accesses
+
+// geofenceGroupsTableName stores the named, scheduled geofence groups shared by every walk.
+const geofenceGroupsTableName = "geofence_groups" // Table of geofence groups
+
+// CurrentWalksNotifyChannel is the LISTEN/NOTIFY channel on which every current walk change is
+// announced when committed, with its JSON-encoded models.CurrentWalkChange as payload.
+const CurrentWalksNotifyChannel = "current_walks_changes"
+
+// currentWalkChangesRetention is how long changes stay in the outbox for subscribers to catch up.
+var currentWalkChangesRetention = 24 * time.Hour
+
 // defaultRetentionPeriod indicates how long stored data should remain before being subject to removal.
 var defaultRetentionPeriod = 90 * 24 * time.Hour // 90 days default retention
 
+// defaultEventsChunkInterval is the time partition size of the session event stream.
+var defaultEventsChunkInterval = 7 * 24 * time.Hour // Weekly event chunks
+
+// defaultSessionsChunkInterval is the time partition size of the session summaries table.
+var defaultSessionsChunkInterval = 30 * 24 * time.Hour // Monthly session chunks
+
 // compressionInterval defines the interval after which compression policies apply to older chunks.
 var compressionInterval = 7 * 24 * time.Hour // Compression after 7 days
 
@@ -47,6 +142,16 @@ type RepositoryConfig struct {
 	// AdditionalContinuousAggregateViews can store names of any pre-configured continuous aggregates
 	// to be refreshed after inserts.
 	AdditionalContinuousAggregateViews []string
+
+	// EventsChunkInterval and SessionsChunkInterval override the time partition sizes of the
+	// session event stream and the session summaries table if non-zero.
+	EventsChunkInterval   time.Duration
+	SessionsChunkInterval time.Duration
+
+	// EventRetention and SessionRetention drop event and session summary partitions older than
+	// the given age through TimescaleDB retention policies. Zero keeps them indefinitely.
+	EventRetention   time.Duration
+	SessionRetention time.Duration
 }
 
 // compressionPolicy represents a placeholder for advanced compression configuration details.
@@ -72,6 +177,10 @@ type TimescaleRepository struct {
 	config           RepositoryConfig
 	CompressionPolicy compressionPolicy
 	RetentionPolicy   retentionPolicy
+
+	// reads coalesces identical concurrent history and statistics queries, so
+	// dashboards refreshing together share a single database round trip.
+	reads singleflight.Group
 }
 
 // NewTimescaleRepository creates a new instance of TimescaleDB repository with enhanced configuration.
@@ -86,7 +195,7 @@ type TimescaleRepository struct {
 //  7. Return configured repository instance or error.
 func NewTimescaleRepository(db *sql.DB, schema string, cfg RepositoryConfig) (*TimescaleRepository, error) {
 	if db == nil {
-		return nil, sql.ErrConnDone
+		return nil, invalidInput("database handle is nil")
 	}
 
 	// Create the repository struct
@@ -114,7 +223,7 @@ func NewTimescaleRepository(db *sql.DB, schema string, cfg RepositoryConfig) (*T
 
 	// If retention is enabled, set up background policies
 	if cfg.RetentionEnabled {
-		if err := repo.manageRetention(RetentionConfig{
+		if _, err := repo.manageRetention(RetentionConfig{
 			RetentionPeriod: repo.RetentionPolicy.MaxAge,
 			PolicyEnabled:   true,
 		}); err != nil {
@@ -186,6 +295,26 @@ func (r *TimescaleRepository) initSchema() error {
 		return errCreateLoc
 	}
 
+	// 3a. Device-reported location provider, added after the table was first deployed
+	addProviderColumnSQL := `
+		ALTER TABLE "` + r.schema + `"."` + locationTableName + `"
+		ADD COLUMN IF NOT EXISTS provider TEXT NOT NULL DEFAULT '';
+	`
+	if _, errAlterLoc := tx.Exec(addProviderColumnSQL); errAlterLoc != nil {
+		_ = tx.Rollback()
+		return errAlterLoc
+	}
+
+	// 3b. Reported altitude in meters, added after the table was first deployed
+	addAltitudeColumnSQL := `
+		ALTER TABLE "` + r.schema + `"."` + locationTableName + `"
+		ADD COLUMN IF NOT EXISTS altitude DOUBLE PRECISION NOT NULL DEFAULT 0;
+	`
+	if _, errAlterLoc := tx.Exec(addAltitudeColumnSQL); errAlterLoc != nil {
+		_ = tx.Rollback()
+		return errAlterLoc
+	}
+
 	// Make the table a hypertable if not already
 	// Use recorded_at as time dimension, with optional chunk interval from config
 	chunkIntervalSec := int64(r.config.ChunkInterval.Seconds())
@@ -227,6 +356,57 @@ func (r *TimescaleRepository) initSchema() error {
 		return errIdx
 	}
 
+	// 5b. Create a composite walk/time index so per-walk time lookups avoid scanning whole histories
+	createWalkTimeIndexSQL := `
+		CREATE INDEX IF NOT EXISTS idx_` + locationTableName + `_walk_time
+		ON "` + r.schema + `"."` + locationTableName + `" (walk_id, recorded_at DESC);
+	`
+	if _, errIdx := tx.Exec(createWalkTimeIndexSQL); errIdx != nil {
+		_ = tx.Rollback()
+		return errIdx
+	}
+
+	// 5c. Index the planar geometry of each point so polygon containment queries,
+	//     which run ST_Within on geo::geometry, can use a GIST index scan
+	createGeometryIndexSQL := `
+		CREATE INDEX IF NOT EXISTS idx_` + locationTableName + `_geom
+		ON "` + r.schema + `"."` + locationTableName + `" USING GIST ((geo::geometry));
+	`
+	if _, errIdx := tx.Exec(createGeometryIndexSQL); errIdx != nil {
+		_ = tx.Rollback()
+		return errIdx
+	}
+
+	// 5d. Store each location ID once, so uploads retried by mobile clients do not
+	//     create duplicate rows. Unique indexes of a hypertable must include its time
+	//     column; a retried point keeps its timestamp. Rows duplicated before the index
+	//     existed are removed when it is first created.
+	uniqueIndexName := "idx_" + locationTableName + "_id_time"
+	var uniqueIndex sql.NullString
+	if errIdx := tx.QueryRow(`SELECT to_regclass($1)::text;`, `"`+r.schema+`"."`+uniqueIndexName+`"`).Scan(&uniqueIndex); errIdx != nil {
+		_ = tx.Rollback()
+		return errIdx
+	}
+	if !uniqueIndex.Valid {
+		dedupSQL := `
+			DELETE FROM "` + r.schema + `"."` + locationTableName + `" AS a
+			USING "` + r.schema + `"."` + locationTableName + `" AS b
+			WHERE a.id = b.id AND a.recorded_at = b.recorded_at AND a.ctid < b.ctid;
+		`
+		if _, errDedup := tx.Exec(dedupSQL); errDedup != nil {
+			_ = tx.Rollback()
+			return errDedup
+		}
+		createUniqueIndexSQL := `
+			CREATE UNIQUE INDEX IF NOT EXISTS ` + uniqueIndexName + `
+			ON "` + r.schema + `"."` + locationTableName + `" (id, recorded_at);
+		`
+		if _, errIdx := tx.Exec(createUniqueIndexSQL); errIdx != nil {
+			_ = tx.Rollback()
+			return errIdx
+		}
+	}
+
 	// 6. Optionally create a continuous aggregate or materialized view for location summaries
 	for _, viewName := range r.config.AdditionalContinuousAggregateViews {
 		refreshViewSQL := `
@@ -242,7 +422,7 @@ func (r *TimescaleRepository) initSchema() error {
 	// 7. Also ensure a basic table for tracking_sessions (if using DB for session metadata)
 	createSessionTableSQL := `
 		CREATE TABLE IF NOT EXISTS "` + r.schema + `"."` + sessionTableName + `" (
-			id TEXT PRIMARY KEY,
+			id TEXT NOT NULL,
 			walk_id TEXT NOT NULL,
 			status TEXT NOT NULL,
 			start_time TIMESTAMPTZ NOT NULL,
@@ -250,22 +430,552 @@ func (r *TimescaleRepository) initSchema() error {
 			total_distance DOUBLE PRECISION DEFAULT 0,
 			duration_seconds DOUBLE PRECISION DEFAULT 0,
 			last_update_time TIMESTAMPTZ,
-			is_archived BOOLEAN DEFAULT FALSE
+			is_archived BOOLEAN DEFAULT FALSE,
+			PRIMARY KEY (id, start_time)
 		);
+		CREATE INDEX IF NOT EXISTS idx_` + sessionTableName + `_walk
+			ON "` + r.schema + `"."` + sessionTableName + `" (walk_id, start_time DESC);
 	`
 	if _, errSessionTbl := tx.Exec(createSessionTableSQL); errSessionTbl != nil {
 		_ = tx.Rollback()
 		return errSessionTbl
 	}
 
+	// 8. Territory coverage per walk, plus the cells each dog has explored. A cell is
+	// attributed to the walk that first visited it.
+	createTerritoryTablesSQL := `
+		CREATE TABLE IF NOT EXISTS "` + r.schema + `"."` + territoryTableName + `" (
+			walk_id TEXT PRIMARY KEY,
+			dog_id TEXT NOT NULL,
+			area_sq_m DOUBLE PRECISION NOT NULL DEFAULT 0,
+			cell_count INTEGER NOT NULL DEFAULT 0,
+			new_cell_count INTEGER NOT NULL DEFAULT 0,
+			new_territory_pct DOUBLE PRECISION NOT NULL DEFAULT 0,
+			computed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		CREATE TABLE IF NOT EXISTS "` + r.schema + `"."` + dogTerritoryCellsTableName + `" (
+			dog_id TEXT NOT NULL,
+			cell_key TEXT NOT NULL,
+			walk_id TEXT NOT NULL,
+			first_seen TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (dog_id, cell_key)
+		);
+	`
+	if _, errTerritoryTbl := tx.Exec(createTerritoryTablesSQL); errTerritoryTbl != nil {
+		_ = tx.Rollback()
+		return errTerritoryTbl
+	}
+
+	// 9. Append-only session event stream. Rows are never updated or deleted, so the
+	// BIGSERIAL id gives a stable per-session order. The table is partitioned by
+	// occurred_at in step 13, so the primary key includes it.
+	createEventsTableSQL := `
+		CREATE TABLE IF NOT EXISTS "` + r.schema + `"."` + sessionEventsTableName + `" (
+			id BIGSERIAL NOT NULL,
+			session_id TEXT NOT NULL,
+			event_type TEXT NOT NULL,
+			occurred_at TIMESTAMPTZ NOT NULL,
+			payload JSONB NOT NULL,
+			PRIMARY KEY (id, occurred_at)
+		);
+		CREATE INDEX IF NOT EXISTS idx_` + sessionEventsTableName + `_session
+			ON "` + r.schema + `"."` + sessionEventsTableName + `" (session_id, id);
+		CREATE INDEX IF NOT EXISTS idx_` + sessionEventsTableName + `_session_time
+			ON "` + r.schema + `"."` + sessionEventsTableName + `" (session_id, occurred_at);
+	`
+	if _, errEventsTbl := tx.Exec(createEventsTableSQL); errEventsTbl != nil {
+		_ = tx.Rollback()
+		return errEventsTbl
+	}
+
+	// 10. Fitness platform OAuth tokens, one row per owner and provider. dog_ids lists
+	// the dogs whose walks are uploaded automatically.
+	createFitnessTokensSQL := `
+		CREATE TABLE IF NOT EXISTS "` + r.schema + `"."` + fitnessTokensTableName + `" (
+			owner_id TEXT NOT NULL,
+			provider TEXT NOT NULL,
+			access_token TEXT NOT NULL,
+			refresh_token TEXT NOT NULL DEFAULT '',
+			expires_at TIMESTAMPTZ,
+			dog_ids TEXT[] NOT NULL DEFAULT '{}',
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (owner_id, provider)
+		);
+		CREATE INDEX IF NOT EXISTS idx_` + fitnessTokensTableName + `_dogs
+			ON "` + r.schema + `"."` + fitnessTokensTableName + `" USING GIN (dog_ids);
+	`
+	if _, errFitnessTbl := tx.Exec(createFitnessTokensSQL); errFitnessTbl != nil {
+		_ = tx.Rollback()
+		return errFitnessTbl
+	}
+
+	// 11. Route popularity layer: one row per undirected grid segment with the number
+	// of walks that crossed it. route_walks makes aggregation idempotent per walk.
+	createRouteTablesSQL := `
+		CREATE TABLE IF NOT EXISTS "` + r.schema + `"."` + routeSegmentsTableName + `" (
+			from_cell TEXT NOT NULL,
+			to_cell TEXT NOT NULL,
+			from_lat DOUBLE PRECISION NOT NULL,
+			from_lon DOUBLE PRECISION NOT NULL,
+			to_lat DOUBLE PRECISION NOT NULL,
+			to_lon DOUBLE PRECISION NOT NULL,
+			walk_count INTEGER NOT NULL DEFAULT 0,
+			last_walked_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (from_cell, to_cell)
+		);
+		CREATE INDEX IF NOT EXISTS idx_` + routeSegmentsTableName + `_position
+			ON "` + r.schema + `"."` + routeSegmentsTableName + `" (from_lat, from_lon);
+		CREATE TABLE IF NOT EXISTS "` + r.schema + `"."` + routeWalksTableName + `" (
+			walk_id TEXT PRIMARY KEY,
+			segment_count INTEGER NOT NULL DEFAULT 0,
+			aggregated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+	`
+	if _, errRouteTbl := tx.Exec(createRouteTablesSQL); errRouteTbl != nil {
+		_ = tx.Rollback()
+		return errRouteTbl
+	}
+
+	// 11b. Walk geofences. Circles use the center and radius; polygons store their
+	// counter-clockwise vertices as a JSON array, with the centroid as the center.
+	createGeofencesSQL := `
+		CREATE TABLE IF NOT EXISTS "` + r.schema + `"."` + geofencesTableName + `" (
+			id TEXT PRIMARY KEY,
+			walk_id TEXT NOT NULL,
+			shape TEXT NOT NULL DEFAULT 'circle',
+			center_lat DOUBLE PRECISION NOT NULL,
+			center_lon DOUBLE PRECISION NOT NULL,
+			radius_km DOUBLE PRECISION NOT NULL DEFAULT 0,
+			vertices JSONB,
+			active BOOLEAN NOT NULL DEFAULT TRUE,
+			boundary_violations INTEGER NOT NULL DEFAULT 0,
+			group_id TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_` + geofencesTableName + `_walk
+			ON "` + r.schema + `"."` + geofencesTableName + `" (walk_id);
+	`
+	if _, errGeofenceTbl := tx.Exec(createGeofencesSQL); errGeofenceTbl != nil {
+		_ = tx.Rollback()
+		return errGeofenceTbl
+	}
+
+	// 11c. Geofence mode (inclusion or exclusion) and violation severity, added after the table was first deployed
+	addGeofencePolicySQL := `
+		ALTER TABLE "` + r.schema + `"."` + geofencesTableName + `"
+		ADD COLUMN IF NOT EXISTS mode TEXT NOT NULL DEFAULT 'inclusion',
+		ADD COLUMN IF NOT EXISTS severity TEXT NOT NULL DEFAULT 'warning';
+	`
+	if _, errAlterGeofence := tx.Exec(addGeofencePolicySQL); errAlterGeofence != nil {
+		_ = tx.Rollback()
+		return errAlterGeofence
+	}
+
+	// 11d. Completed walk summaries feeding the walker leaderboard, partitioned by end time
+	createWalkSummariesSQL := `
+		CREATE TABLE IF NOT EXISTS "` + r.schema + `"."` + walkSummariesTableName + `" (
+			walk_id TEXT NOT NULL,
+			session_id TEXT NOT NULL,
+			tenant_id TEXT NOT NULL,
+			walker_id TEXT NOT NULL,
+			dog_id TEXT NOT NULL,
+			distance_m DOUBLE PRECISION NOT NULL DEFAULT 0,
+			duration_seconds DOUBLE PRECISION NOT NULL DEFAULT 0,
+			quality_score DOUBLE PRECISION NOT NULL DEFAULT 0,
+			ended_at TIMESTAMPTZ NOT NULL
+		);
+		SELECT create_hypertable(
+			'"` + r.schema + `"."` + walkSummariesTableName + `"',
+			'ended_at',
+			chunk_time_interval => INTERVAL '` + r.intervalToString(int64(defaultSessionsChunkInterval.Seconds())) + `',
+			if_not_exists => TRUE
+		);
+		CREATE INDEX IF NOT EXISTS idx_` + walkSummariesTableName + `_tenant
+			ON "` + r.schema + `"."` + walkSummariesTableName + `" (tenant_id, ended_at DESC);
+		CREATE INDEX IF NOT EXISTS idx_` + walkSummariesTableName + `_dog
+			ON "` + r.schema + `"."` + walkSummariesTableName + `" (dog_id, ended_at DESC);
+	`
+	if _, errSummariesTbl := tx.Exec(createWalkSummariesSQL); errSummariesTbl != nil {
+		_ = tx.Rollback()
+		return errSummariesTbl
+	}
+
+	// 11e. Beacon proximity events reported during sessions, partitioned by sighting time
+	createBeaconEventsSQL := `
+		CREATE TABLE IF NOT EXISTS "` + r.schema + `"."` + beaconEventsTableName + `" (
+			session_id TEXT NOT NULL,
+			beacon_id TEXT NOT NULL,
+			rssi SMALLINT NOT NULL,
+			seen_at TIMESTAMPTZ NOT NULL
+		);
+		SELECT create_hypertable(
+			'"` + r.schema + `"."` + beaconEventsTableName + `"',
+			'seen_at',
+			chunk_time_interval => INTERVAL '` + r.intervalToString(int64(defaultEventsChunkInterval.Seconds())) + `',
+			if_not_exists => TRUE
+		);
+		CREATE INDEX IF NOT EXISTS idx_` + beaconEventsTableName + `_session
+			ON "` + r.schema + `"."` + beaconEventsTableName + `" (session_id, seen_at);
+	`
+	if _, errBeaconTbl := tx.Exec(createBeaconEventsSQL); errBeaconTbl != nil {
+		_ = tx.Rollback()
+		return errBeaconTbl
+	}
+
+	// 11f. Currently running walks and the outbox of their changes feeding the change feed
+	createCurrentWalksSQL := `
+		CREATE TABLE IF NOT EXISTS "` + r.schema + `"."` + currentWalksTableName + `" (
+			session_id TEXT PRIMARY KEY,
+			walk_id TEXT NOT NULL,
+			walker_id TEXT NOT NULL,
+			dog_id TEXT NOT NULL,
+			tenant_id TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL,
+			started_at TIMESTAMPTZ NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_` + currentWalksTableName + `_tenant
+			ON "` + r.schema + `"."` + currentWalksTableName + `" (tenant_id, started_at);
+		CREATE TABLE IF NOT EXISTS "` + r.schema + `"."` + currentWalkChangesTableName + `" (
+			seq BIGSERIAL PRIMARY KEY,
+			change TEXT NOT NULL,
+			session_id TEXT NOT NULL,
+			walk JSONB NOT NULL,
+			changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_` + currentWalkChangesTableName + `_changed
+			ON "` + r.schema + `"."` + currentWalkChangesTableName + `" (changed_at);
+	`
+	if _, errCurrentTbl := tx.Exec(createCurrentWalksSQL); errCurrentTbl != nil {
+		_ = tx.Rollback()
+		return errCurrentTbl
+	}
+
+	// 11g. Regional configuration profiles and the region of each walk they apply to
+	createRegionProfilesSQL := `
+		CREATE TABLE IF NOT EXISTS "` + r.schema + `"."` + regionProfilesTableName + `" (
+			region TEXT PRIMARY KEY,
+			max_speed_kmh DOUBLE PRECISION NOT NULL DEFAULT 0,
+			geofence_radius_km DOUBLE PRECISION NOT NULL DEFAULT 0,
+			retention_days INTEGER NOT NULL DEFAULT 0,
+			updated_at TIMESTAMPTZ NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS "` + r.schema + `"."` + walkRegionsTableName + `" (
+			walk_id TEXT PRIMARY KEY,
+			region TEXT NOT NULL,
+			started_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+		CREATE INDEX IF NOT EXISTS idx_` + walkRegionsTableName + `_region
+			ON "` + r.schema + `"."` + walkRegionsTableName + `" (region);
+	`
+	if _, errRegionTbl := tx.Exec(createRegionProfilesSQL); errRegionTbl != nil {
+		_ = tx.Rollback()
+		return errRegionTbl
+	}
+
+	// 11h. Owners' own alert radii for the walks of their dogs
+	createOwnerAlertsSQL := `
+		CREATE TABLE IF NOT EXISTS "` + r.schema + `"."` + ownerAlertsTableName + `" (
+			owner_id TEXT NOT NULL,
+			dog_id TEXT NOT NULL,
+			radius_km DOUBLE PRECISION NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL,
+			PRIMARY KEY (owner_id, dog_id)
+		);
+		CREATE INDEX IF NOT EXISTS idx_` + ownerAlertsTableName + `_dog
+			ON "` + r.schema + `"."` + ownerAlertsTableName + `" (dog_id);
+	`
+	if _, errOwnerAlertTbl := tx.Exec(createOwnerAlertsSQL); errOwnerAlertTbl != nil {
+		_ = tx.Rollback()
+		return errOwnerAlertTbl
+	}
+
+	// 11i. Devices walkers paired to report their walks from. Revoked devices keep their
+	// row, with revoked_at set, so they cannot be paired again
+	createTrustedDevicesSQL := `
+		CREATE TABLE IF NOT EXISTS "` + r.schema + `"."` + trustedDevicesTableName + `" (
+			walker_id TEXT NOT NULL,
+			device_id TEXT NOT NULL,
+			name TEXT NOT NULL DEFAULT '',
+			platform TEXT NOT NULL,
+			paired_at TIMESTAMPTZ NOT NULL,
+			revoked_at TIMESTAMPTZ,
+			PRIMARY KEY (walker_id, device_id)
+		);
+	`
+	if _, errDeviceTbl := tx.Exec(createTrustedDevicesSQL); errDeviceTbl != nil {
+		_ = tx.Rollback()
+		return errDeviceTbl
+	}
+
+	// 11j. Location points rejected at ingestion, kept for review and reingestion. The
+	// point is stored as submitted, as JSON, since its fields may be invalid
+	createQuarantineSQL := `
+		CREATE TABLE IF NOT EXISTS "` + r.schema + `"."` + quarantinedPointsTableName + `" (
+			id TEXT PRIMARY KEY,
+			session_id TEXT NOT NULL,
+			location JSONB NOT NULL,
+			reason TEXT NOT NULL,
+			detail TEXT NOT NULL DEFAULT '',
+			quarantined_at TIMESTAMPTZ NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_` + quarantinedPointsTableName + `_time
+			ON "` + r.schema + `"."` + quarantinedPointsTableName + `" (quarantined_at DESC);
+		CREATE INDEX IF NOT EXISTS idx_` + quarantinedPointsTableName + `_session
+			ON "` + r.schema + `"."` + quarantinedPointsTableName + `" (session_id, quarantined_at DESC);
+	`
+	if _, errQuarantineTbl := tx.Exec(createQuarantineSQL); errQuarantineTbl != nil {
+		_ = tx.Rollback()
+		return errQuarantineTbl
+	}
+
+	// 11k. Stream keys escrowed for legal requests, wrapped under the escrow public key,
+	// and the append-only log of every attempt to access them
+	createEscrowSQL := `
+		CREATE TABLE IF NOT EXISTS "` + r.schema + `"."` + streamKeyEscrowTableName + `" (
+			session_id TEXT NOT NULL,
+			key_id TEXT NOT NULL,
+			tenant_id TEXT NOT NULL DEFAULT '',
+			ephemeral_key BYTEA NOT NULL,
+			nonce BYTEA NOT NULL,
+			wrapped_key BYTEA NOT NULL,
+			issued_at TIMESTAMPTZ NOT NULL,
+			PRIMARY KEY (session_id, key_id)
+		);
+		CREATE TABLE IF NOT EXISTS "` + r.schema + `"."` + escrowAccessLogTableName + `" (
+			id TEXT PRIMARY KEY,
+			session_id TEXT NOT NULL,
+			tenant_id TEXT NOT NULL DEFAULT '',
+			legal_reference TEXT NOT NULL,
+			requested_by TEXT NOT NULL,
+			custodians TEXT[] NOT NULL,
+			outcome TEXT NOT NULL,
+			key_count INTEGER NOT NULL DEFAULT 0,
+			accessed_at TIMESTAMPTZ NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_` + escrowAccessLogTableName + `_tenant
+			ON "` + r.schema + `"."` + escrowAccessLogTableName + `" (tenant_id, accessed_at DESC);
+	`
+	if _, errEscrowTbl := tx.Exec(createEscrowSQL); errEscrowTbl != nil {
+		_ = tx.Rollback()
+		return errEscrowTbl
+	}
+
+	// 11l. Walk start demand: one row per demand grid cell and UTC hour with the number
+	// of walks started there. demand_walks makes aggregation idempotent per walk.
+	createDemandTablesSQL := `
+		CREATE TABLE IF NOT EXISTS "` + r.schema + `"."` + demandStartsTableName + `" (
+			cell TEXT NOT NULL,
+			hour_start TIMESTAMPTZ NOT NULL,
+			latitude DOUBLE PRECISION NOT NULL,
+			longitude DOUBLE PRECISION NOT NULL,
+			start_count INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (cell, hour_start)
+		);
+		CREATE INDEX IF NOT EXISTS idx_` + demandStartsTableName + `_position
+			ON "` + r.schema + `"."` + demandStartsTableName + `" (latitude, longitude, hour_start);
+		CREATE TABLE IF NOT EXISTS "` + r.schema + `"."` + demandWalksTableName + `" (
+			walk_id TEXT PRIMARY KEY,
+			aggregated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		);
+	`
+	if _, errDemandTbl := tx.Exec(createDemandTablesSQL); errDemandTbl != nil {
+		_ = tx.Rollback()
+		return errDemandTbl
+	}
+
+	// 11m. Walk access log: one row per read of a walk's location data, listed
+	// by walk, or by session for reads of sessions whose walk was not known.
+	createAccessLogSQL := `
+		CREATE TABLE IF NOT EXISTS "` + r.schema + `"."` + walkAccessLogTableName + `" (
+			id TEXT PRIMARY KEY,
+			walk_id TEXT NOT NULL,
+			session_id TEXT NOT NULL DEFAULT '',
+			actor TEXT NOT NULL,
+			endpoint TEXT NOT NULL,
+			request_id TEXT NOT NULL DEFAULT '',
+			accessed_at TIMESTAMPTZ NOT NULL
+		);
+		CREATE INDEX IF NOT EXISTS idx_` + walkAccessLogTableName + `_walk
+			ON "` + r.schema + `"."` + walkAccessLogTableName + `" (walk_id, accessed_at DESC);
+		CREATE INDEX IF NOT EXISTS idx_` + walkAccessLogTableName + `_session
+			ON "` + r.schema + `"."` + walkAccessLogTableName + `" (session_id, accessed_at DESC);
+	`
+	if _, errAccessLogTbl := tx.Exec(createAccessLogSQL); errAccessLogTbl != nil {
+		_ = tx.Rollback()
+		return errAccessLogTbl
+	}
+
+	// 11n. Geofence groups, one row per group name, with their member geofences as a
+	// JSON array: members may apply to every walk, so they are not walk geofences.
+	createGeofenceGroupsSQL := `
+		CREATE TABLE IF NOT EXISTS "` + r.schema + `"."` + geofenceGroupsTableName + `" (
+			name TEXT PRIMARY KEY,
+			id TEXT NOT NULL,
+			schedule TEXT NOT NULL DEFAULT '',
+			time_zone TEXT NOT NULL DEFAULT '',
+			geofences JSONB NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL
+		);
+	`
+	if _, errGroupsTbl := tx.Exec(createGeofenceGroupsSQL); errGroupsTbl != nil {
+		_ = tx.Rollback()
+		return errGroupsTbl
+	}
+
 	// Commit if everything succeeds
 	if errCommit := tx.Commit(); errCommit != nil {
 		_ = tx.Rollback()
 		return errCommit
 	}
+
+	// 12. Per-minute activity continuous aggregate backing session sparklines. Continuous
+	// aggregates cannot be created inside a transaction, so this runs after the commit.
+	// Real-time aggregation serves the minutes not yet materialized, so walks in progress
+	// are covered up to their latest fix.
+	createActivityViewSQL := `
+		CREATE MATERIALIZED VIEW IF NOT EXISTS "` + r.schema + `"."` + activityMinutesViewName + `"
+		WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+		SELECT walk_id,
+			time_bucket(INTERVAL '1 minute', recorded_at) AS minute,
+			first(latitude, recorded_at) AS first_lat,
+			first(longitude, recorded_at) AS first_lon,
+			last(latitude, recorded_at) AS last_lat,
+			last(longitude, recorded_at) AS last_lon,
+			count(*) AS point_count
+		FROM "` + r.schema + `"."` + locationTableName + `"
+		GROUP BY walk_id, minute
+		WITH NO DATA;
+	`
+	if _, errView := r.db.Exec(createActivityViewSQL); errView != nil {
+		return errView
+	}
+	addActivityPolicySQL := `
+		SELECT add_continuous_aggregate_policy(
+			'"` + r.schema + `"."` + activityMinutesViewName + `"',
+			start_offset => INTERVAL '1 day',
+			end_offset => INTERVAL '1 minute',
+			schedule_interval => INTERVAL '1 minute',
+			if_not_exists => TRUE
+		);
+	`
+	if _, errPolicy := r.db.Exec(addActivityPolicySQL); errPolicy != nil {
+		return errPolicy
+	}
+
+	// 13. Partition the session event stream and session summaries by time, with their
+	// retention policies, so timeline queries and pruning touch only recent chunks.
+	eventsChunk := r.config.EventsChunkInterval
+	if eventsChunk <= 0 {
+		eventsChunk = defaultEventsChunkInterval
+	}
+	if err := r.partitionByTime(sessionEventsTableName, "occurred_at", eventsChunk, r.config.EventRetention); err != nil {
+		return fmt.Errorf("failed to partition %s: %w", sessionEventsTableName, err)
+	}
+	sessionsChunk := r.config.SessionsChunkInterval
+	if sessionsChunk <= 0 {
+		sessionsChunk = defaultSessionsChunkInterval
+	}
+	if err := r.partitionByTime(sessionTableName, "start_time", sessionsChunk, r.config.SessionRetention); err != nil {
+		return fmt.Errorf("failed to partition %s: %w", sessionTableName, err)
+	}
+
+	// 14. Per-walker daily totals continuous aggregate backing the leaderboard. Quality scores
+	// are summed so that averages over several days weigh every walk equally, and real-time
+	// aggregation includes walks completed since the last refresh.
+	createWalkerStatsViewSQL := `
+		CREATE MATERIALIZED VIEW IF NOT EXISTS "` + r.schema + `"."` + walkerDailyStatsViewName + `"
+		WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+		SELECT tenant_id,
+			walker_id,
+			time_bucket(INTERVAL '1 day', ended_at) AS day,
+			sum(distance_m) AS distance_m,
+			count(*) AS walk_count,
+			sum(quality_score) AS quality_sum
+		FROM "` + r.schema + `"."` + walkSummariesTableName + `"
+		GROUP BY tenant_id, walker_id, day
+		WITH NO DATA;
+	`
+	if _, errView := r.db.Exec(createWalkerStatsViewSQL); errView != nil {
+		return errView
+	}
+	addWalkerStatsPolicySQL := `
+		SELECT add_continuous_aggregate_policy(
+			'"` + r.schema + `"."` + walkerDailyStatsViewName + `"',
+			start_offset => INTERVAL '3 days',
+			end_offset => INTERVAL '1 hour',
+			schedule_interval => INTERVAL '15 minutes',
+			if_not_exists => TRUE
+		);
+	`
+	if _, errPolicy := r.db.Exec(addWalkerStatsPolicySQL); errPolicy != nil {
+		return errPolicy
+	}
 	return nil
 }
 
+// partitionByTime converts table into a hypertable on timeColumn, migrating existing rows, and
+// applies its retention policy: chunks older than retention are dropped, and a zero retention
+// removes any policy. Unique constraints of a hypertable must include its time column, so a
+// primary key on id alone, left by earlier deployments, is first widened to (id, timeColumn).
+// Tables that are already hypertables only get their retention policy updated.
+func (r *TimescaleRepository) partitionByTime(table, timeColumn string, chunkInterval, retention time.Duration) error {
+	qualified := `"` + r.schema + `"."` + table + `"`
+
+	var partitioned bool
+	existsSQL := `
+		SELECT EXISTS (
+			SELECT 1 FROM timescaledb_information.hypertables
+			WHERE hypertable_schema = $1 AND hypertable_name = $2
+		);
+	`
+	if err := r.db.QueryRow(existsSQL, r.schema, table).Scan(&partitioned); err != nil {
+		return err
+	}
+
+	if !partitioned {
+		tx, err := r.db.Begin()
+		if err != nil {
+			return err
+		}
+		migrateSQL := `
+			ALTER TABLE ` + qualified + ` DROP CONSTRAINT IF EXISTS "` + table + `_pkey";
+			ALTER TABLE ` + qualified + ` ADD PRIMARY KEY (id, ` + timeColumn + `);
+			SELECT create_hypertable(
+				'` + qualified + `',
+				'` + timeColumn + `',
+				chunk_time_interval => INTERVAL '` + r.intervalToString(int64(chunkInterval.Seconds())) + `',
+				migrate_data => TRUE
+			);
+		`
+		if _, err := tx.Exec(migrateSQL); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	if retention <= 0 {
+		_, err := r.db.Exec(`SELECT remove_retention_policy('` + qualified + `', if_exists => TRUE);`)
+		return err
+	}
+	// Replace the policy so a changed retention takes effect.
+	retentionSQL := `
+		SELECT remove_retention_policy('` + qualified + `', if_exists => TRUE);
+		SELECT add_retention_policy('` + qualified + `', drop_after => INTERVAL '` + r.intervalToString(int64(retention.Seconds())) + `');
+	`
+	_, err := r.db.Exec(retentionSQL)
+	return err
+}
+
+// Close releases the underlying database handle.
+func (r *TimescaleRepository) Close() error {
+	return r.db.Close()
+}
+
 // SaveLocation stores a new location point with advanced validation, begins a transaction,
 // inserts the record with geospatial data, updates relevant session statistics in real-time,
 // refreshes continuous aggregates if configured, and commits or rolls back on error.
@@ -273,41 +983,46 @@ func (r *TimescaleRepository) initSchema() error {
 // Steps:
 //  1. Validate location data accuracy (within domain).
 //  2. Begin transaction with appropriate isolation level.
-//  3. Insert the location point, constructing a geometry/geography column.
+//  3. Insert the location point, constructing a geometry/geography column, unless
+//     the same point was already stored.
 //  4. Update or insert partial session statistics if needed.
 //  5. Refresh continuous aggregates if configured.
 //  6. Commit transaction with minimal retry logic.
 //  7. Return error if any step fails.
 func (r *TimescaleRepository) SaveLocation(location *models.Location) error {
 	if location == nil {
-		return sql.ErrNoRows
+		return invalidInput("location is nil")
 	}
 	if !location.IsValid {
 		// Attempt validation if not valid
 		if err := location.Validate(); err != nil {
-			return err
+			return fmt.Errorf("%w: %w", ErrInvalidInput, err)
 		}
 	}
 
 	// Verify location's accuracy is within reasonable bounds
 	if location.Accuracy < 0 || location.Accuracy > 100.0 {
-		return sql.ErrNoRows
+		return invalidInput("location accuracy %.2f is outside [0, 100]", location.Accuracy)
 	}
 
 	const maxRetries = 3
 	var attempt int
+	var lastErr error
 	for attempt = 0; attempt < maxRetries; attempt++ {
 		tx, err := r.db.Begin()
 		if err != nil {
+			lastErr = err
 			continue
 		}
 
-		// Insert the location
+		// Insert the location; a point already stored by an earlier attempt of the
+		// client's upload is left as it is
 		insertSQL := `
 			INSERT INTO "` + r.schema + `"."` + locationTableName + `"
-			(id, walk_id, latitude, longitude, accuracy, speed, recorded_at, geo)
+			(id, walk_id, latitude, longitude, accuracy, speed, recorded_at, geo, provider, altitude)
 			VALUES
-			($1, $2, $3, $4, $5, $6, $7, ST_SetSRID(ST_Point($8, $9), 4326)::geography);
+			($1, $2, $3, $4, $5, $6, $7, ST_SetSRID(ST_Point($8, $9), 4326)::geography, $10, $11)
+			ON CONFLICT (id, recorded_at) DO NOTHING;
 		`
 		_, execErr := tx.Exec(
 			insertSQL,
@@ -320,9 +1035,12 @@ func (r *TimescaleRepository) SaveLocation(location *models.Location) error {
 			location.Timestamp,
 			location.Longitude,
 			location.Latitude,
+			location.Provider,
+			location.Altitude,
 		)
 		if execErr != nil {
 			_ = tx.Rollback()
+			lastErr = execErr
 			continue
 		}
 
@@ -334,6 +1052,7 @@ func (r *TimescaleRepository) SaveLocation(location *models.Location) error {
 		`
 		if _, updateErr := tx.Exec(updateSessionSQL, time.Now().UTC(), location.WalkID); updateErr != nil {
 			_ = tx.Rollback()
+			lastErr = updateErr
 			continue
 		}
 
@@ -352,30 +1071,33 @@ func (r *TimescaleRepository) SaveLocation(location *models.Location) error {
 		// Commit
 		if commitErr := tx.Commit(); commitErr != nil {
 			_ = tx.Rollback()
+			lastErr = commitErr
 			continue
 		}
 		// Successfully inserted
 		return nil
 	}
-	return sql.ErrTxDone
+	return &ErrRetryExhausted{Attempts: maxRetries, LastErr: lastErr}
 }
 
 // BatchSaveLocations persists multiple location points in a single transaction or uses
 // an efficient batch mechanism. It includes optional validation and partial rollback
 // if needed. This method is exposed for high-throughput data ingestion scenarios.
+// Points whose ID and timestamp are already stored are skipped, so retried uploads
+// are idempotent.
 func (r *TimescaleRepository) BatchSaveLocations(locations []*models.Location) error {
 	if len(locations) == 0 {
 		return nil
 	}
 
 	// Optional pre-check: validate each location's structure
-	for _, loc := range locations {
+	for idx, loc := range locations {
 		if loc == nil {
-			return sql.ErrNoRows
+			return invalidInput("location %d is nil", idx)
 		}
 		if !loc.IsValid {
 			if err := loc.Validate(); err != nil {
-				return err
+				return fmt.Errorf("%w: location %d: %w", ErrInvalidInput, idx, err)
 			}
 		}
 	}
@@ -400,7 +1122,7 @@ func (r *TimescaleRepository) BatchSaveLocations(locations []*models.Location) e
 
 		insertSQL := `
 			INSERT INTO "` + r.schema + `"."` + locationTableName + `"
-			(id, walk_id, latitude, longitude, accuracy, speed, recorded_at, geo)
+			(id, walk_id, latitude, longitude, accuracy, speed, recorded_at, geo, provider, altitude)
 			VALUES
 		`
 		values := ""
@@ -418,14 +1140,17 @@ func (r *TimescaleRepository) BatchSaveLocations(locations []*models.Location) e
 			values += "$" + r.intToString(paramIndex+4) + ", " // accuracy
 			values += "$" + r.intToString(paramIndex+5) + ", " // speed
 			values += "$" + r.intToString(paramIndex+6) + ", " // recorded_at
-			values += `ST_SetSRID(ST_Point($` + r.intToString(paramIndex+7) + `, $` + r.intToString(paramIndex+8) + `), 4326)::geography`
+			values += `ST_SetSRID(ST_Point($` + r.intToString(paramIndex+7) + `, $` + r.intToString(paramIndex+8) + `), 4326)::geography, `
+			values += "$" + r.intToString(paramIndex+9) + ", " // provider
+			values += "$" + r.intToString(paramIndex+10)       // altitude
 			values += ")"
 
-			args = append(args, loc.ID, loc.WalkID, loc.Latitude, loc.Longitude, loc.Accuracy, 0.0, loc.Timestamp, loc.Longitude, loc.Latitude)
-			paramIndex += 9
+			args = append(args, loc.ID, loc.WalkID, loc.Latitude, loc.Longitude, loc.Accuracy, 0.0, loc.Timestamp, loc.Longitude, loc.Latitude, loc.Provider, loc.Altitude)
+			paramIndex += 11
 		}
 
-		finalQuery := insertSQL + values + ";"
+		// Points already stored by a retried upload are skipped
+		finalQuery := insertSQL + values + " ON CONFLICT (id, recorded_at) DO NOTHING;"
 		if _, errExec := tx.Exec(finalQuery, args...); errExec != nil {
 			_ = tx.Rollback()
 			return errExec
@@ -440,16 +1165,38 @@ func (r *TimescaleRepository) BatchSaveLocations(locations []*models.Location) e
 	return nil
 }
 
+// coalesceKey builds the request coalescing key for a read operation and its parameters.
+func coalesceKey(op string, params ...string) string {
+	return op + "\x00" + strings.Join(params, "\x00")
+}
+
 // GetLocationHistory retrieves the list of location points associated with a particular
 // walk, ordered by their recorded timestamp. This query may leverage time-based partitioning
-// and read-optimized indexes for quick data retrieval.
+// and read-optimized indexes for quick data retrieval. Concurrent calls for the same walk
+// share one query; each caller receives its own copy of the result.
 func (r *TimescaleRepository) GetLocationHistory(walkID string) ([]models.Location, error) {
 	if walkID == "" {
-		return nil, sql.ErrNoRows
+		return nil, invalidInput("walkID is empty")
+	}
+
+	v, err, shared := r.reads.Do(coalesceKey("history", walkID), func() (interface{}, error) {
+		return r.queryLocationHistory(walkID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	results := v.([]models.Location)
+	if shared {
+		results = append([]models.Location(nil), results...)
 	}
+	return results, nil
+}
+
+// queryLocationHistory runs the location history query for GetLocationHistory.
+func (r *TimescaleRepository) queryLocationHistory(walkID string) ([]models.Location, error) {
 
 	selectSQL := `
-		SELECT id, walk_id, latitude, longitude, accuracy, recorded_at
+		SELECT id, walk_id, latitude, longitude, accuracy, recorded_at, provider, altitude
 		FROM "` + r.schema + `"."` + locationTableName + `"
 		WHERE walk_id = $1
 		ORDER BY recorded_at ASC;
@@ -461,73 +1208,1830 @@ func (r *TimescaleRepository) GetLocationHistory(walkID string) ([]models.Locati
 	}
 	defer rows.Close()
 
-	var results []models.Location
-	for rows.Next() {
-		var (
-			locID        string
-			wID          string
-			lat          float64
-			lon          float64
-			acc          float64
-			recordedTime time.Time
-		)
-		if scanErr := rows.Scan(&locID, &wID, &lat, &lon, &acc, &recordedTime); scanErr != nil {
-			return nil, scanErr
-		}
+	return scanLocationRows(rows)
+}
 
-		// Construct a validated location. We'll ignore alt/spd fields for now.
-		loc := models.Location{
-			ID:        locID,
-			WalkID:    wID,
-			Latitude:  lat,
-			Longitude: lon,
-			Accuracy:  acc,
-			Timestamp: recordedTime,
-			IsValid:   true,
-		}
-		results = append(results, loc)
+// GetLocationHistoryUntil returns the walk's location points recorded at or
+// before until, ordered by timestamp. The bound is applied in the query, so the
+// (walk_id, recorded_at) index limits the scan to the requested prefix of the walk.
+func (r *TimescaleRepository) GetLocationHistoryUntil(walkID string, until time.Time) ([]models.Location, error) {
+	if walkID == "" || until.IsZero() {
+		return nil, invalidInput("walkID and until are required")
 	}
+	until = until.UTC()
+
+	v, err, shared := r.reads.Do(coalesceKey("history-until", walkID, until.Format(time.RFC3339Nano)), func() (interface{}, error) {
+		selectSQL := `
+			SELECT id, walk_id, latitude, longitude, accuracy, recorded_at, provider, altitude
+			FROM "` + r.schema + `"."` + locationTableName + `"
+			WHERE walk_id = $1 AND recorded_at <= $2
+			ORDER BY recorded_at ASC;
+		`
 
-	return results, nil
-}
+		rows, err := r.db.Query(selectSQL, walkID, until)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		return scanLocationRows(rows)
+	})
+	if err != nil {
+		return nil, err
+	}
+	results := v.([]models.Location)
+	if shared {
+		results = append([]models.Location(nil), results...)
+	}
+	return results, nil
+}
+
+// GetLocationHistoryPage returns up to limit of the walk's location points
+// after the cursor, ordered by timestamp then ID so points sharing a timestamp
+// keep a stable order across pages. The keyset bound is applied in the query,
+// so later pages cost no more than the first. The filter's time bounds are
+// applied in the query too; down-sampling numbers or buckets the points from
+// the filter's lower bound, or the bucket holding the cursor, onwards.
+func (r *TimescaleRepository) GetLocationHistoryPage(walkID string, after models.PageCursor, limit int, filter models.HistoryFilter) ([]models.Location, error) {
+	if walkID == "" {
+		return nil, invalidInput("walkID is empty")
+	}
+	if limit < 1 {
+		return nil, invalidInput("limit %d must be at least 1", limit)
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, invalidInput("%v", err)
+	}
+
+	// $1 walk, $2/$3 cursor, $4 limit; the time bounds follow.
+	args := []interface{}{walkID, after.Timestamp.UTC(), after.ID, limit}
+	bounds := ""
+	if !filter.From.IsZero() {
+		args = append(args, filter.From.UTC())
+		bounds += ` AND recorded_at >= $` + r.intToString(int64(len(args)))
+	}
+	if !filter.To.IsZero() {
+		args = append(args, filter.To.UTC())
+		bounds += ` AND recorded_at < $` + r.intToString(int64(len(args)))
+	}
+	table := `"` + r.schema + `"."` + locationTableName + `"`
+	columns := `id, walk_id, latitude, longitude, accuracy, recorded_at, provider, altitude`
+
+	var selectSQL string
+	switch {
+	case filter.EveryNth > 1:
+		// Points are numbered over the whole filtered range so every page keeps the
+		// same stride; the keyset bound applies to the numbered points.
+		args = append(args, filter.EveryNth)
+		selectSQL = `
+			SELECT ` + columns + ` FROM (
+				SELECT ` + columns + `, row_number() OVER (ORDER BY recorded_at ASC, id ASC) AS n
+				FROM ` + table + `
+				WHERE walk_id = $1` + bounds + `
+			) numbered
+			WHERE (n - 1) % $` + r.intToString(int64(len(args))) + ` = 0 AND (recorded_at, id) > ($2, $3)
+			ORDER BY recorded_at ASC, id ASC
+			LIMIT $4;
+		`
+	case filter.Bucket > 0:
+		// Bucketing starts from the bucket holding the cursor, so a page resumes
+		// after the first point of the cursor's bucket rather than within it.
+		args = append(args, filter.Bucket.Seconds())
+		bucket := `make_interval(secs => $` + r.intToString(int64(len(args))) + `)`
+		selectSQL = `
+			SELECT ` + columns + ` FROM (
+				SELECT DISTINCT ON (time_bucket(` + bucket + `, recorded_at)) ` + columns + `
+				FROM ` + table + `
+				WHERE walk_id = $1` + bounds + ` AND recorded_at >= time_bucket(` + bucket + `, $2::timestamptz)
+				ORDER BY time_bucket(` + bucket + `, recorded_at), recorded_at ASC, id ASC
+			) firsts
+			WHERE (recorded_at, id) > ($2, $3)
+			ORDER BY recorded_at ASC, id ASC
+			LIMIT $4;
+		`
+	default:
+		selectSQL = `
+			SELECT ` + columns + `
+			FROM ` + table + `
+			WHERE walk_id = $1 AND (recorded_at, id) > ($2, $3)` + bounds + `
+			ORDER BY recorded_at ASC, id ASC
+			LIMIT $4;
+		`
+	}
+	rows, err := r.db.Query(selectSQL, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanLocationRows(rows)
+}
+
+// scanLocationRows reads location history rows selected as id, walk_id,
+// latitude, longitude, accuracy, recorded_at, provider, altitude.
+func scanLocationRows(rows *sql.Rows) ([]models.Location, error) {
+	var results []models.Location
+	for rows.Next() {
+		var (
+			locID        string
+			wID          string
+			lat          float64
+			lon          float64
+			acc          float64
+			recordedTime time.Time
+			provider     string
+			alt          float64
+		)
+		if scanErr := rows.Scan(&locID, &wID, &lat, &lon, &acc, &recordedTime, &provider, &alt); scanErr != nil {
+			return nil, scanErr
+		}
+
+		// Construct a validated location. Speed is not persisted per point.
+		loc := models.Location{
+			ID:        locID,
+			WalkID:    wID,
+			Latitude:  lat,
+			Longitude: lon,
+			Accuracy:  acc,
+			Altitude:  alt,
+			Timestamp: recordedTime,
+			IsValid:   true,
+			Provider:  provider,
+		}
+		results = append(results, loc)
+	}
+	if rowsErr := rows.Err(); rowsErr != nil {
+		return nil, rowsErr
+	}
+
+	return results, nil
+}
+
+// GetLocationAt resolves where a walk was at a given instant by returning the recorded
+// location point closest in time to t. It issues two index-backed probes (the latest
+// point at or before t and the earliest point after t) instead of fetching the whole
+// history, then returns whichever is nearer. Ties favor the earlier point.
+// ErrNotFound is returned if the walk has no recorded points.
+func (r *TimescaleRepository) GetLocationAt(walkID string, t time.Time) (*models.Location, error) {
+	if walkID == "" || t.IsZero() {
+		return nil, invalidInput("walkID and time are required")
+	}
+
+	selectSQL := `
+		(
+			SELECT id, walk_id, latitude, longitude, accuracy, recorded_at, provider
+			FROM "` + r.schema + `"."` + locationTableName + `"
+			WHERE walk_id = $1 AND recorded_at <= $2
+			ORDER BY recorded_at DESC
+			LIMIT 1
+		)
+		UNION ALL
+		(
+			SELECT id, walk_id, latitude, longitude, accuracy, recorded_at, provider
+			FROM "` + r.schema + `"."` + locationTableName + `"
+			WHERE walk_id = $1 AND recorded_at > $2
+			ORDER BY recorded_at ASC
+			LIMIT 1
+		);
+	`
+
+	rows, err := r.db.Query(selectSQL, walkID, t.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var closest *models.Location
+	var closestGap time.Duration
+	for rows.Next() {
+		loc := models.Location{IsValid: true}
+		if scanErr := rows.Scan(&loc.ID, &loc.WalkID, &loc.Latitude, &loc.Longitude, &loc.Accuracy, &loc.Timestamp, &loc.Provider); scanErr != nil {
+			return nil, scanErr
+		}
+
+		gap := loc.Timestamp.Sub(t)
+		if gap < 0 {
+			gap = -gap
+		}
+		if closest == nil || gap < closestGap {
+			candidate := loc
+			closest = &candidate
+			closestGap = gap
+		}
+	}
+	if rowsErr := rows.Err(); rowsErr != nil {
+		return nil, rowsErr
+	}
+	if closest == nil {
+		return nil, fmt.Errorf("%w: no location points for walk %s", ErrNotFound, walkID)
+	}
+	return closest, nil
+}
 
 // GetSessionStatistics retrieves aggregated session information from the tracking_sessions table
 // or calculates it on the fly. This example uses data stored in the session table, but more
 // sophisticated approaches might combine location_points analysis or continuous aggregates.
+// Concurrent calls for the same walk share one query; each caller receives its own copy.
 func (r *TimescaleRepository) GetSessionStatistics(walkID string) (*models.TrackingStatistics, error) {
 	if walkID == "" {
-		return nil, sql.ErrNoRows
+		return nil, invalidInput("walkID is empty")
+	}
+
+	v, err, shared := r.reads.Do(coalesceKey("statistics", walkID), func() (interface{}, error) {
+		return r.querySessionStatistics(walkID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	stats := v.(*models.TrackingStatistics)
+	if shared {
+		statsCopy := *stats
+		if stats.Coverage != nil {
+			coverageCopy := *stats.Coverage
+			statsCopy.Coverage = &coverageCopy
+		}
+		stats = &statsCopy
+	}
+	return stats, nil
+}
+
+// querySessionStatistics runs the statistics queries for GetSessionStatistics.
+func (r *TimescaleRepository) querySessionStatistics(walkID string) (*models.TrackingStatistics, error) {
+	query := `
+		SELECT total_distance, duration_seconds
+		FROM "` + r.schema + `"."` + sessionTableName + `"
+		WHERE walk_id = $1
+		ORDER BY start_time DESC
+		LIMIT 1;
+	`
+
+	var distance float64
+	var durationSec float64
+	err := r.db.QueryRow(query, walkID).Scan(&distance, &durationSec)
+	if err != nil {
+		return nil, notFoundOr(err)
+	}
+
+	// We'll simulate the rest of the fields in TrackingStatistics
+	stats := &models.TrackingStatistics{
+		TotalDistance: distance,
+		Duration:      time.Duration(durationSec * float64(time.Second)),
+		AverageSpeed:  0,
+		MaxSpeed:      0,
+		MinSpeed:      0,
+	}
+
+	// Basic average speed calculation
+	if stats.Duration.Seconds() > 0 {
+		stats.AverageSpeed = distance / stats.Duration.Seconds()
+	}
+
+	// Attach territory coverage when it has been computed for this walk
+	coverage, covErr := r.GetTerritoryCoverage(walkID)
+	if covErr != nil && !errors.Is(covErr, ErrNotFound) {
+		return nil, covErr
+	}
+	stats.Coverage = coverage
+
+	return stats, nil
+}
+
+// SaveTerritoryCoverage persists the coverage computed for a walk and records the
+// cells it visited against the dog. Cells already attributed to an earlier walk
+// keep their original attribution, so recomputing a walk is idempotent.
+//
+// Steps:
+//  1. Validate identifiers and coverage.
+//  2. Begin transaction.
+//  3. Upsert the walk's coverage row.
+//  4. Insert the visited cells, skipping ones the dog already owns.
+//  5. Commit transaction.
+func (r *TimescaleRepository) SaveTerritoryCoverage(walkID, dogID string, coverage *models.TerritoryCoverage, cells []string) error {
+	if walkID == "" || dogID == "" || coverage == nil {
+		return invalidInput("walkID, dogID and coverage are required")
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	upsertSQL := `
+		INSERT INTO "` + r.schema + `"."` + territoryTableName + `" (
+			walk_id, dog_id, area_sq_m, cell_count, new_cell_count, new_territory_pct, computed_at
+		) VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (walk_id) DO UPDATE SET
+			area_sq_m = EXCLUDED.area_sq_m,
+			cell_count = EXCLUDED.cell_count,
+			new_cell_count = EXCLUDED.new_cell_count,
+			new_territory_pct = EXCLUDED.new_territory_pct,
+			computed_at = EXCLUDED.computed_at;
+	`
+	if _, err := tx.Exec(upsertSQL,
+		walkID,
+		dogID,
+		coverage.AreaSqMeters,
+		coverage.CellCount,
+		coverage.NewCellCount,
+		coverage.NewTerritoryPercent,
+	); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if len(cells) > 0 {
+		cellsSQL := `
+			INSERT INTO "` + r.schema + `"."` + dogTerritoryCellsTableName + `" (dog_id, cell_key, walk_id)
+			SELECT $1, cell, $2 FROM UNNEST($3::TEXT[]) AS cell
+			ON CONFLICT (dog_id, cell_key) DO NOTHING;
+		`
+		if _, err := tx.Exec(cellsSQL, dogID, walkID, pq.Array(cells)); err != nil {
+			_ = tx.Rollback()
+			return err
+		}
+	}
+
+	if errCommit := tx.Commit(); errCommit != nil {
+		_ = tx.Rollback()
+		return errCommit
+	}
+	return nil
+}
+
+// AppendSessionEvent appends evt to its session's event stream and sets
+// evt.Sequence to the assigned position.
+func (r *TimescaleRepository) AppendSessionEvent(evt *models.SessionStateEvent) error {
+	if evt == nil || evt.SessionID == "" || evt.Type == "" {
+		return invalidInput("event sessionID and type are required")
+	}
+
+	query := `
+		INSERT INTO "` + r.schema + `"."` + sessionEventsTableName + `" (
+			session_id, event_type, occurred_at, payload
+		) VALUES ($1, $2, $3, $4)
+		RETURNING id;
+	`
+	return r.db.QueryRow(query,
+		evt.SessionID,
+		evt.Type,
+		evt.OccurredAt,
+		[]byte(evt.Payload),
+	).Scan(&evt.Sequence)
+}
+
+// GetSessionEvents returns the full event stream for a session in append order.
+// The stream is partitioned by time, so this probes every retained chunk; timeline
+// views over a known window should use GetSessionEventsBetween.
+func (r *TimescaleRepository) GetSessionEvents(sessionID string) ([]models.SessionStateEvent, error) {
+	if sessionID == "" {
+		return nil, invalidInput("sessionID is empty")
+	}
+
+	query := `
+		SELECT id, session_id, event_type, occurred_at, payload
+		FROM "` + r.schema + `"."` + sessionEventsTableName + `"
+		WHERE session_id = $1
+		ORDER BY id ASC;
+	`
+	rows, err := r.db.Query(query, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSessionEvents(rows)
+}
+
+// GetSessionEventsBetween returns a session's events that occurred in [from, to), in
+// append order. The time bounds let TimescaleDB exclude chunks outside the window,
+// keeping event timelines fast however long the stream grows.
+func (r *TimescaleRepository) GetSessionEventsBetween(sessionID string, from, to time.Time) ([]models.SessionStateEvent, error) {
+	if sessionID == "" || from.IsZero() || to.IsZero() {
+		return nil, invalidInput("sessionID, from and to are required")
+	}
+	if !from.Before(to) {
+		return nil, invalidInput("from must be before to")
+	}
+
+	query := `
+		SELECT id, session_id, event_type, occurred_at, payload
+		FROM "` + r.schema + `"."` + sessionEventsTableName + `"
+		WHERE session_id = $1 AND occurred_at >= $2 AND occurred_at < $3
+		ORDER BY id ASC;
+	`
+	rows, err := r.db.Query(query, sessionID, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSessionEvents(rows)
+}
+
+// GetSessionEventsPage returns up to limit of a session's events after the
+// cursor, whose ID is an event sequence, ordered by occurrence then sequence.
+// The time bound lets TimescaleDB skip the chunks of earlier pages.
+func (r *TimescaleRepository) GetSessionEventsPage(sessionID string, after models.PageCursor, limit int) ([]models.SessionStateEvent, error) {
+	if sessionID == "" {
+		return nil, invalidInput("sessionID is empty")
+	}
+	if limit < 1 {
+		return nil, invalidInput("limit %d must be at least 1", limit)
+	}
+	var afterSeq int64
+	if after.ID != "" {
+		var err error
+		if afterSeq, err = strconv.ParseInt(after.ID, 10, 64); err != nil {
+			return nil, invalidInput("cursor ID %q is not an event sequence", after.ID)
+		}
+	}
+
+	query := `
+		SELECT id, session_id, event_type, occurred_at, payload
+		FROM "` + r.schema + `"."` + sessionEventsTableName + `"
+		WHERE session_id = $1 AND occurred_at >= $2 AND (occurred_at, id) > ($2, $3)
+		ORDER BY occurred_at ASC, id ASC
+		LIMIT $4;
+	`
+	rows, err := r.db.Query(query, sessionID, after.Timestamp.UTC(), afterSeq, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanSessionEvents(rows)
+}
+
+// scanSessionEvents reads session event rows selected as id, session_id,
+// event_type, occurred_at, payload.
+func scanSessionEvents(rows *sql.Rows) ([]models.SessionStateEvent, error) {
+	var events []models.SessionStateEvent
+	for rows.Next() {
+		var evt models.SessionStateEvent
+		var payload []byte
+		if err := rows.Scan(&evt.Sequence, &evt.SessionID, &evt.Type, &evt.OccurredAt, &payload); err != nil {
+			return nil, err
+		}
+		evt.Payload = payload
+		events = append(events, evt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// SampleCompletedSessions returns the IDs of up to limit sessions, drawn at
+// random, that completed at or after since.
+func (r *TimescaleRepository) SampleCompletedSessions(since time.Time, limit int) ([]string, error) {
+	if limit < 1 {
+		return nil, invalidInput("limit %d must be at least 1", limit)
+	}
+
+	query := `
+		SELECT session_id
+		FROM "` + r.schema + `"."` + sessionEventsTableName + `"
+		WHERE event_type = $1 AND occurred_at >= $2
+		ORDER BY random()
+		LIMIT $3;
+	`
+	rows, err := r.db.Query(query, models.EventSessionCompleted, since.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessionIDs []string
+	for rows.Next() {
+		var sessionID string
+		if err := rows.Scan(&sessionID); err != nil {
+			return nil, err
+		}
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sessionIDs, nil
+}
+
+// SaveFitnessToken inserts or replaces an owner's tokens for a fitness provider.
+func (r *TimescaleRepository) SaveFitnessToken(token *models.FitnessToken) error {
+	if token == nil || token.OwnerID == "" || token.Provider == "" || token.AccessToken == "" {
+		return invalidInput("token ownerID, provider and accessToken are required")
+	}
+
+	var expiresAt interface{}
+	if !token.ExpiresAt.IsZero() {
+		expiresAt = token.ExpiresAt.UTC()
+	}
+	dogIDs := token.DogIDs
+	if dogIDs == nil {
+		dogIDs = []string{}
+	}
+
+	query := `
+		INSERT INTO "` + r.schema + `"."` + fitnessTokensTableName + `" (
+			owner_id, provider, access_token, refresh_token, expires_at, dog_ids, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, NOW())
+		ON CONFLICT (owner_id, provider) DO UPDATE SET
+			access_token = EXCLUDED.access_token,
+			refresh_token = EXCLUDED.refresh_token,
+			expires_at = EXCLUDED.expires_at,
+			dog_ids = EXCLUDED.dog_ids,
+			updated_at = EXCLUDED.updated_at;
+	`
+	_, err := r.db.Exec(query,
+		token.OwnerID,
+		token.Provider,
+		token.AccessToken,
+		token.RefreshToken,
+		expiresAt,
+		pq.Array(dogIDs),
+	)
+	return err
+}
+
+// GetFitnessTokensForDog returns every owner token set up to receive the dog's walks.
+func (r *TimescaleRepository) GetFitnessTokensForDog(dogID string) ([]models.FitnessToken, error) {
+	if dogID == "" {
+		return nil, invalidInput("dogID is empty")
+	}
+
+	query := `
+		SELECT owner_id, provider, access_token, refresh_token, expires_at, dog_ids
+		FROM "` + r.schema + `"."` + fitnessTokensTableName + `"
+		WHERE $1 = ANY(dog_ids);
+	`
+	rows, err := r.db.Query(query, dogID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []models.FitnessToken
+	for rows.Next() {
+		var token models.FitnessToken
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&token.OwnerID, &token.Provider, &token.AccessToken, &token.RefreshToken,
+			&expiresAt, pq.Array(&token.DogIDs)); err != nil {
+			return nil, err
+		}
+		if expiresAt.Valid {
+			token.ExpiresAt = expiresAt.Time
+		}
+		tokens = append(tokens, token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// SaveGeofence inserts or replaces a geofence. Polygon vertices are validated
+// and stored in their normalized, counter-clockwise form, and the mode and
+// severity with their defaults applied.
+func (r *TimescaleRepository) SaveGeofence(geofence *models.GeofenceRecord) error {
+	if geofence == nil || geofence.ID == "" || geofence.WalkID == "" {
+		return invalidInput("geofence id and walkID are required")
+	}
+
+	var vertices interface{}
+	switch geofence.Shape {
+	case models.GeofenceShapeCircle:
+		if geofence.RadiusKm <= 0 {
+			return invalidInput("circle geofence radius must be positive")
+		}
+	case models.GeofenceShapePolygon:
+		normalized, err := models.NormalizePolygon(geofence.Vertices)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidInput, err)
+		}
+		raw, err := json.Marshal(normalized)
+		if err != nil {
+			return err
+		}
+		vertices = string(raw)
+	default:
+		return invalidInput("geofence shape %q is invalid", geofence.Shape)
+	}
+	mode, severity, err := models.NormalizeGeofencePolicy(geofence.Mode, geofence.Severity)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidInput, err)
+	}
+
+	createdAt := geofence.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now()
+	}
+	updatedAt := geofence.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = createdAt
+	}
+
+	query := `
+		INSERT INTO "` + r.schema + `"."` + geofencesTableName + `" (
+			id, walk_id, shape, center_lat, center_lon, radius_km, vertices,
+			active, boundary_violations, group_id, created_at, updated_at, mode, severity
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		ON CONFLICT (id) DO UPDATE SET
+			shape = EXCLUDED.shape,
+			center_lat = EXCLUDED.center_lat,
+			center_lon = EXCLUDED.center_lon,
+			radius_km = EXCLUDED.radius_km,
+			vertices = EXCLUDED.vertices,
+			active = EXCLUDED.active,
+			boundary_violations = EXCLUDED.boundary_violations,
+			group_id = EXCLUDED.group_id,
+			updated_at = EXCLUDED.updated_at,
+			mode = EXCLUDED.mode,
+			severity = EXCLUDED.severity;
+	`
+	_, err = r.db.Exec(query,
+		geofence.ID,
+		geofence.WalkID,
+		geofence.Shape,
+		geofence.CenterLatitude,
+		geofence.CenterLongitude,
+		geofence.RadiusKm,
+		vertices,
+		geofence.Active,
+		geofence.BoundaryViolations,
+		geofence.GroupID,
+		createdAt.UTC(),
+		updatedAt.UTC(),
+		mode,
+		severity,
+	)
+	return err
+}
+
+// GetGeofences returns the geofences defined for a walk, oldest first.
+func (r *TimescaleRepository) GetGeofences(walkID string) ([]models.GeofenceRecord, error) {
+	if walkID == "" {
+		return nil, invalidInput("walkID is empty")
+	}
+
+	query := `
+		SELECT id, walk_id, shape, center_lat, center_lon, radius_km, vertices,
+			active, boundary_violations, group_id, created_at, updated_at, mode, severity
+		FROM "` + r.schema + `"."` + geofencesTableName + `"
+		WHERE walk_id = $1
+		ORDER BY created_at, id;
+	`
+	rows, err := r.db.Query(query, walkID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var geofences []models.GeofenceRecord
+	for rows.Next() {
+		var geofence models.GeofenceRecord
+		var vertices []byte
+		if err := rows.Scan(&geofence.ID, &geofence.WalkID, &geofence.Shape, &geofence.CenterLatitude,
+			&geofence.CenterLongitude, &geofence.RadiusKm, &vertices, &geofence.Active,
+			&geofence.BoundaryViolations, &geofence.GroupID, &geofence.CreatedAt, &geofence.UpdatedAt,
+			&geofence.Mode, &geofence.Severity); err != nil {
+			return nil, err
+		}
+		if len(vertices) > 0 {
+			if err := json.Unmarshal(vertices, &geofence.Vertices); err != nil {
+				return nil, fmt.Errorf("geofence %s has malformed vertices: %w", geofence.ID, err)
+			}
+		}
+		geofences = append(geofences, geofence)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return geofences, nil
+}
+
+// SaveGeofenceGroup inserts or replaces a geofence group under its name.
+func (r *TimescaleRepository) SaveGeofenceGroup(group *models.GeofenceGroupRecord) error {
+	if group == nil || group.ID == "" || group.Name == "" {
+		return invalidInput("geofence group id and name are required")
+	}
+	geofences, err := json.Marshal(group.Geofences)
+	if err != nil {
+		return err
+	}
+	updatedAt := group.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO "` + r.schema + `"."` + geofenceGroupsTableName + `" (
+			name, id, schedule, time_zone, geofences, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (name) DO UPDATE SET
+			id = EXCLUDED.id,
+			schedule = EXCLUDED.schedule,
+			time_zone = EXCLUDED.time_zone,
+			geofences = EXCLUDED.geofences,
+			updated_at = EXCLUDED.updated_at;
+	`
+	_, err = r.db.Exec(query, group.Name, group.ID, group.Schedule, group.TimeZone,
+		string(geofences), updatedAt.UTC())
+	return err
+}
+
+// GetGeofenceGroups returns every geofence group, ordered by name.
+func (r *TimescaleRepository) GetGeofenceGroups() ([]models.GeofenceGroupRecord, error) {
+	query := `
+		SELECT name, id, schedule, time_zone, geofences, updated_at
+		FROM "` + r.schema + `"."` + geofenceGroupsTableName + `"
+		ORDER BY name ASC;
+	`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []models.GeofenceGroupRecord
+	for rows.Next() {
+		var group models.GeofenceGroupRecord
+		var geofences []byte
+		if err := rows.Scan(&group.Name, &group.ID, &group.Schedule, &group.TimeZone,
+			&geofences, &group.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(geofences, &group.Geofences); err != nil {
+			return nil, fmt.Errorf("geofence group %s has malformed geofences: %w", group.Name, err)
+		}
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// DeleteGeofenceGroup removes the named geofence group, returning ErrNotFound
+// when there is none.
+func (r *TimescaleRepository) DeleteGeofenceGroup(name string) error {
+	if name == "" {
+		return invalidInput("geofence group name is empty")
+	}
+
+	query := `
+		DELETE FROM "` + r.schema + `"."` + geofenceGroupsTableName + `"
+		WHERE name = $1;
+	`
+	res, err := r.db.Exec(query, name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: geofence group %s", ErrNotFound, name)
+	}
+	return nil
+}
+
+// GetDogTerritoryCells returns the set of cells the dog explored on walks other
+// than excludeWalkID, forming the baseline for "new territory" comparisons.
+func (r *TimescaleRepository) GetDogTerritoryCells(dogID, excludeWalkID string) (map[string]struct{}, error) {
+	if dogID == "" {
+		return nil, invalidInput("dogID is empty")
+	}
+
+	query := `
+		SELECT cell_key
+		FROM "` + r.schema + `"."` + dogTerritoryCellsTableName + `"
+		WHERE dog_id = $1 AND walk_id <> $2;
+	`
+	rows, err := r.db.Query(query, dogID, excludeWalkID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cells := make(map[string]struct{})
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		cells[key] = struct{}{}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return cells, nil
+}
+
+// GetTerritoryCoverage retrieves the stored territory coverage for a walk,
+// returning ErrNotFound if none has been computed yet.
+func (r *TimescaleRepository) GetTerritoryCoverage(walkID string) (*models.TerritoryCoverage, error) {
+	if walkID == "" {
+		return nil, invalidInput("walkID is empty")
+	}
+
+	query := `
+		SELECT area_sq_m, cell_count, new_cell_count, new_territory_pct
+		FROM "` + r.schema + `"."` + territoryTableName + `"
+		WHERE walk_id = $1;
+	`
+	coverage := &models.TerritoryCoverage{}
+	if err := r.db.QueryRow(query, walkID).Scan(
+		&coverage.AreaSqMeters,
+		&coverage.CellCount,
+		&coverage.NewCellCount,
+		&coverage.NewTerritoryPercent,
+	); err != nil {
+		return nil, notFoundOr(err)
+	}
+	return coverage, nil
+}
+
+// RecordRouteSegments adds a completed walk's segments to the route popularity
+// layer. Each walk is counted once; recording the same walk again is a no-op.
+func (r *TimescaleRepository) RecordRouteSegments(walkID string, segments []models.RouteSegment) error {
+	if walkID == "" {
+		return invalidInput("walkID is empty")
+	}
+	if len(segments) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	claimSQL := `
+		INSERT INTO "` + r.schema + `"."` + routeWalksTableName + `" (walk_id, segment_count)
+		VALUES ($1, $2)
+		ON CONFLICT (walk_id) DO NOTHING;
+	`
+	res, err := tx.Exec(claimSQL, walkID, len(segments))
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if claimed, _ := res.RowsAffected(); claimed == 0 {
+		_ = tx.Rollback()
+		return nil
+	}
+
+	fromCells := make([]string, len(segments))
+	toCells := make([]string, len(segments))
+	fromLats := make([]float64, len(segments))
+	fromLons := make([]float64, len(segments))
+	toLats := make([]float64, len(segments))
+	toLons := make([]float64, len(segments))
+	for i, seg := range segments {
+		fromCells[i], toCells[i] = seg.FromCell, seg.ToCell
+		fromLats[i], fromLons[i] = seg.FromLatitude, seg.FromLongitude
+		toLats[i], toLons[i] = seg.ToLatitude, seg.ToLongitude
+	}
+
+	upsertSQL := `
+		INSERT INTO "` + r.schema + `"."` + routeSegmentsTableName + `" (
+			from_cell, to_cell, from_lat, from_lon, to_lat, to_lon, walk_count, last_walked_at
+		)
+		SELECT fc, tc, flat, flon, tlat, tlon, 1, NOW()
+		FROM UNNEST($1::TEXT[], $2::TEXT[], $3::DOUBLE PRECISION[], $4::DOUBLE PRECISION[],
+			$5::DOUBLE PRECISION[], $6::DOUBLE PRECISION[]) AS s(fc, tc, flat, flon, tlat, tlon)
+		ON CONFLICT (from_cell, to_cell) DO UPDATE SET
+			walk_count = "` + routeSegmentsTableName + `".walk_count + 1,
+			last_walked_at = EXCLUDED.last_walked_at;
+	`
+	if _, err := tx.Exec(upsertSQL,
+		pq.Array(fromCells),
+		pq.Array(toCells),
+		pq.Array(fromLats),
+		pq.Array(fromLons),
+		pq.Array(toLats),
+		pq.Array(toLons),
+	); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if errCommit := tx.Commit(); errCommit != nil {
+		_ = tx.Rollback()
+		return errCommit
+	}
+	return nil
+}
+
+// GetPopularRouteSegments returns the most walked segments starting inside the
+// bounding box, busiest first, with Popularity relative to the busiest returned.
+func (r *TimescaleRepository) GetPopularRouteSegments(bbox models.BoundingBox, minWalks, limit int) ([]models.PopularRouteSegment, error) {
+	if err := bbox.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidInput, err)
+	}
+	if limit < 1 {
+		return nil, invalidInput("limit %d must be at least 1", limit)
+	}
+
+	query := `
+		SELECT from_cell, to_cell, from_lat, from_lon, to_lat, to_lon, walk_count
+		FROM "` + r.schema + `"."` + routeSegmentsTableName + `"
+		WHERE from_lat BETWEEN $1 AND $2
+			AND from_lon BETWEEN $3 AND $4
+			AND walk_count >= $5
+		ORDER BY walk_count DESC, last_walked_at DESC
+		LIMIT $6;
+	`
+	rows, err := r.db.Query(query, bbox.MinLatitude, bbox.MaxLatitude, bbox.MinLongitude, bbox.MaxLongitude, minWalks, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var segments []models.PopularRouteSegment
+	for rows.Next() {
+		var seg models.PopularRouteSegment
+		if err := rows.Scan(
+			&seg.FromCell,
+			&seg.ToCell,
+			&seg.FromLatitude,
+			&seg.FromLongitude,
+			&seg.ToLatitude,
+			&seg.ToLongitude,
+			&seg.WalkCount,
+		); err != nil {
+			return nil, err
+		}
+		segments = append(segments, seg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(segments) > 0 && segments[0].WalkCount > 0 {
+		busiest := float64(segments[0].WalkCount)
+		for i := range segments {
+			segments[i].Popularity = float64(segments[i].WalkCount) / busiest
+		}
+	}
+	return segments, nil
+}
+
+// RecordWalkStart adds a walk's start to the demand grid, in the UTC hour of
+// startedAt. Each walk is counted once; recording the same walk again is a no-op.
+func (r *TimescaleRepository) RecordWalkStart(walkID, cell string, lat, lon float64, startedAt time.Time) error {
+	if walkID == "" || cell == "" {
+		return invalidInput("walkID and cell are required")
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	claimSQL := `
+		INSERT INTO "` + r.schema + `"."` + demandWalksTableName + `" (walk_id)
+		VALUES ($1)
+		ON CONFLICT (walk_id) DO NOTHING;
+	`
+	res, err := tx.Exec(claimSQL, walkID)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if claimed, _ := res.RowsAffected(); claimed == 0 {
+		_ = tx.Rollback()
+		return nil
+	}
+
+	upsertSQL := `
+		INSERT INTO "` + r.schema + `"."` + demandStartsTableName + `" (cell, hour_start, latitude, longitude, start_count)
+		VALUES ($1, $2, $3, $4, 1)
+		ON CONFLICT (cell, hour_start) DO UPDATE SET
+			start_count = "` + demandStartsTableName + `".start_count + 1;
+	`
+	if _, err := tx.Exec(upsertSQL, cell, startedAt.UTC().Truncate(time.Hour), lat, lon); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if errCommit := tx.Commit(); errCommit != nil {
+		_ = tx.Rollback()
+		return errCommit
+	}
+	return nil
+}
+
+// GetDemandBuckets returns the walk starts since since in demand cells centered
+// inside the bounding box, by cell and hour of the day in loc, leaving out
+// buckets with fewer than minStarts starts. Hours are taken from the UTC hour
+// buckets, so in zones offset by a fraction of an hour they are approximate.
+func (r *TimescaleRepository) GetDemandBuckets(bbox models.BoundingBox, since time.Time, loc *time.Location, minStarts int) ([]models.DemandBucket, error) {
+	if err := bbox.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidInput, err)
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+	if minStarts < 1 {
+		return nil, invalidInput("minStarts %d must be at least 1", minStarts)
+	}
+
+	query := `
+		SELECT cell, latitude, longitude,
+			EXTRACT(HOUR FROM hour_start AT TIME ZONE $6)::INTEGER AS local_hour,
+			SUM(start_count)::INTEGER AS starts
+		FROM "` + r.schema + `"."` + demandStartsTableName + `"
+		WHERE latitude BETWEEN $1 AND $2
+			AND longitude BETWEEN $3 AND $4
+			AND hour_start >= $5
+		GROUP BY cell, latitude, longitude, local_hour
+		HAVING SUM(start_count) >= $7
+		ORDER BY starts DESC, cell, local_hour;
+	`
+	rows, err := r.db.Query(query, bbox.MinLatitude, bbox.MaxLatitude, bbox.MinLongitude, bbox.MaxLongitude, since.UTC(), loc.String(), minStarts)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []models.DemandBucket
+	for rows.Next() {
+		var b models.DemandBucket
+		if err := rows.Scan(&b.Cell, &b.Latitude, &b.Longitude, &b.Hour, &b.Starts); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+// leaderboardRankColumns maps each leaderboard ranking to the aggregate column it orders by.
+var leaderboardRankColumns = map[string]string{
+	models.LeaderboardRankDistance: "distance_m",
+	models.LeaderboardRankWalks:    "walk_count",
+	models.LeaderboardRankQuality:  "quality_score",
+}
+
+// RecordWalkSummary stores a completed walk for the walker leaderboard.
+func (r *TimescaleRepository) RecordWalkSummary(summary *models.WalkSummary) error {
+	if summary == nil || summary.WalkID == "" || summary.TenantID == "" || summary.WalkerID == "" {
+		return invalidInput("walk summary requires walkId, tenantId and walkerId")
+	}
+	endedAt := summary.EndedAt
+	if endedAt.IsZero() {
+		endedAt = time.Now().UTC()
+	}
+
+	query := `
+		INSERT INTO "` + r.schema + `"."` + walkSummariesTableName + `" (
+			walk_id, session_id, tenant_id, walker_id, dog_id,
+			distance_m, duration_seconds, quality_score, ended_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);
+	`
+	_, err := r.db.Exec(query,
+		summary.WalkID,
+		summary.SessionID,
+		summary.TenantID,
+		summary.WalkerID,
+		summary.DogID,
+		summary.DistanceMeters,
+		summary.DurationSeconds,
+		summary.QualityScore,
+		endedAt,
+	)
+	return err
+}
+
+// HasWalkSummary reports whether a walk summary ended at or after since is
+// stored for walkID. The time bound lets TimescaleDB skip older chunks.
+func (r *TimescaleRepository) HasWalkSummary(walkID string, since time.Time) (bool, error) {
+	if walkID == "" {
+		return false, invalidInput("walkID is empty")
+	}
+
+	query := `
+		SELECT EXISTS (
+			SELECT 1 FROM "` + r.schema + `"."` + walkSummariesTableName + `"
+			WHERE walk_id = $1 AND ended_at >= $2
+		);
+	`
+	var exists bool
+	if err := r.db.QueryRow(query, walkID, since.UTC()).Scan(&exists); err != nil {
+		return false, err
+	}
+	return exists, nil
+}
+
+// GetRecentWalkSummaries returns up to limit of the most recent walks of dogID
+// ended at or after since, those with walkerID first, so walk predictions can
+// prefer the dog's history with its current walker.
+func (r *TimescaleRepository) GetRecentWalkSummaries(dogID, walkerID string, since time.Time, limit int) ([]models.WalkSummary, error) {
+	if dogID == "" {
+		return nil, invalidInput("dogID is empty")
+	}
+	if limit < 1 {
+		return nil, invalidInput("limit %d must be at least 1", limit)
+	}
+
+	query := `
+		SELECT walk_id, session_id, tenant_id, walker_id, dog_id,
+			distance_m, duration_seconds, quality_score, ended_at
+		FROM "` + r.schema + `"."` + walkSummariesTableName + `"
+		WHERE dog_id = $1 AND ended_at >= $2
+		ORDER BY (walker_id = $3) DESC, ended_at DESC
+		LIMIT $4;
+	`
+	rows, err := r.db.Query(query, dogID, since.UTC(), walkerID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var summaries []models.WalkSummary
+	for rows.Next() {
+		var summary models.WalkSummary
+		if err := rows.Scan(
+			&summary.WalkID,
+			&summary.SessionID,
+			&summary.TenantID,
+			&summary.WalkerID,
+			&summary.DogID,
+			&summary.DistanceMeters,
+			&summary.DurationSeconds,
+			&summary.QualityScore,
+			&summary.EndedAt,
+		); err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, summary)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return summaries, nil
+}
+
+// GetWalkerLeaderboard ranks the tenant's walkers over the walks ended in [from, to), read
+// from the per-walker daily totals aggregate, so from and to should fall on UTC days. Walkers
+// tied on the ranked metric share a rank.
+func (r *TimescaleRepository) GetWalkerLeaderboard(tenantID string, from, to time.Time, rankBy string, limit int) ([]models.LeaderboardEntry, error) {
+	if tenantID == "" {
+		return nil, invalidInput("tenantID is empty")
+	}
+	if !from.Before(to) {
+		return nil, invalidInput("from must be before to")
+	}
+	column, ok := leaderboardRankColumns[rankBy]
+	if !ok {
+		return nil, invalidInput("unknown leaderboard ranking %q", rankBy)
+	}
+	if limit < 1 {
+		return nil, invalidInput("limit %d must be at least 1", limit)
+	}
+
+	query := `
+		WITH totals AS (
+			SELECT walker_id,
+				sum(distance_m) AS distance_m,
+				sum(walk_count) AS walk_count,
+				sum(quality_sum) / NULLIF(sum(walk_count), 0) AS quality_score
+			FROM "` + r.schema + `"."` + walkerDailyStatsViewName + `"
+			WHERE tenant_id = $1 AND day >= $2 AND day < $3
+			GROUP BY walker_id
+		)
+		SELECT RANK() OVER (ORDER BY ` + column + ` DESC) AS rank,
+			walker_id, distance_m, walk_count, COALESCE(quality_score, 0)
+		FROM totals
+		ORDER BY rank, walker_id
+		LIMIT $4;
+	`
+	rows, err := r.db.Query(query, tenantID, from.UTC(), to.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.LeaderboardEntry
+	for rows.Next() {
+		var entry models.LeaderboardEntry
+		if err := rows.Scan(
+			&entry.Rank,
+			&entry.WalkerID,
+			&entry.DistanceMeters,
+			&entry.WalkCount,
+			&entry.QualityScore,
+		); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// SaveBeaconEvents inserts a batch of beacon sightings in a single statement.
+func (r *TimescaleRepository) SaveBeaconEvents(events []models.BeaconEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if len(events) > defaultBatchSize {
+		return invalidInput("beacon batch of %d exceeds %d events", len(events), defaultBatchSize)
+	}
+
+	values := ""
+	args := make([]interface{}, 0, len(events)*4)
+	for idx, ev := range events {
+		if ev.SessionID == "" || ev.BeaconID == "" {
+			return invalidInput("beacon event %d requires sessionId and beaconId", idx)
+		}
+		if idx > 0 {
+			values += ","
+		}
+		paramIndex := int64(idx*4 + 1)
+		values += "($" + r.intToString(paramIndex) + ", $" + r.intToString(paramIndex+1) +
+			", $" + r.intToString(paramIndex+2) + ", $" + r.intToString(paramIndex+3) + ")"
+		args = append(args, ev.SessionID, ev.BeaconID, ev.RSSI, ev.Timestamp.UTC())
+	}
+
+	query := `
+		INSERT INTO "` + r.schema + `"."` + beaconEventsTableName + `" (session_id, beacon_id, rssi, seen_at)
+		VALUES ` + values + `;
+	`
+	_, err := r.db.Exec(query, args...)
+	return err
+}
+
+// GetBeaconEvents returns the beacon sightings of a session in time order.
+func (r *TimescaleRepository) GetBeaconEvents(sessionID string) ([]models.BeaconEvent, error) {
+	if sessionID == "" {
+		return nil, invalidInput("sessionID is empty")
+	}
+
+	query := `
+		SELECT session_id, beacon_id, rssi, seen_at
+		FROM "` + r.schema + `"."` + beaconEventsTableName + `"
+		WHERE session_id = $1
+		ORDER BY seen_at ASC;
+	`
+	rows, err := r.db.Query(query, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.BeaconEvent
+	for rows.Next() {
+		var ev models.BeaconEvent
+		if err := rows.Scan(&ev.SessionID, &ev.BeaconID, &ev.RSSI, &ev.Timestamp); err != nil {
+			return nil, err
+		}
+		events = append(events, ev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// RecordWalkTransition applies a session transition to current_walks and appends it to the
+// change outbox in one transaction, announcing it on CurrentWalksNotifyChannel when the
+// transaction commits. Started and status changes upsert the walk; ended changes remove it.
+// The change's Seq and ChangedAt are set from the outbox row.
+func (r *TimescaleRepository) RecordWalkTransition(change *models.CurrentWalkChange) error {
+	if change == nil || change.Walk.SessionID == "" {
+		return invalidInput("current walk change requires a sessionId")
+	}
+	walk := change.Walk
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	switch change.Change {
+	case models.CurrentWalkStarted, models.CurrentWalkStatus:
+		upsertSQL := `
+			INSERT INTO "` + r.schema + `"."` + currentWalksTableName + `" (
+				session_id, walk_id, walker_id, dog_id, tenant_id, status, started_at, updated_at
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (session_id) DO UPDATE
+			SET status = EXCLUDED.status, updated_at = EXCLUDED.updated_at;
+		`
+		_, err = tx.Exec(upsertSQL,
+			walk.SessionID, walk.WalkID, walk.WalkerID, walk.DogID, walk.TenantID,
+			walk.Status, walk.StartedAt, walk.UpdatedAt,
+		)
+	case models.CurrentWalkEnded:
+		_, err = tx.Exec(`
+			DELETE FROM "`+r.schema+`"."`+currentWalksTableName+`"
+			WHERE session_id = $1;
+		`, walk.SessionID)
+	default:
+		err = invalidInput("unknown current walk change %q", change.Change)
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	walkJSON, err := json.Marshal(walk)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	outboxSQL := `
+		INSERT INTO "` + r.schema + `"."` + currentWalkChangesTableName + `" (change, session_id, walk)
+		VALUES ($1, $2, $3)
+		RETURNING seq, changed_at;
+	`
+	if err := tx.QueryRow(outboxSQL, change.Change, walk.SessionID, walkJSON).Scan(&change.Seq, &change.ChangedAt); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	payload, err := json.Marshal(change)
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(`SELECT pg_notify($1, $2);`, CurrentWalksNotifyChannel, string(payload)); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	// Trim the outbox as changes are written, so it never holds more than the retention window.
+	pruneSQL := `
+		DELETE FROM "` + r.schema + `"."` + currentWalkChangesTableName + `"
+		WHERE changed_at < NOW() - INTERVAL '` + r.intervalToString(int64(currentWalkChangesRetention.Seconds())) + `';
+	`
+	if _, err := tx.Exec(pruneSQL); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetCurrentWalks returns the running walks, oldest first, limited to a tenant unless
+// tenantID is empty.
+func (r *TimescaleRepository) GetCurrentWalks(tenantID string) ([]models.CurrentWalk, error) {
+	query := `
+		SELECT session_id, walk_id, walker_id, dog_id, tenant_id, status, started_at, updated_at
+		FROM "` + r.schema + `"."` + currentWalksTableName + `"
+		WHERE $1 = '' OR tenant_id = $1
+		ORDER BY started_at ASC, session_id ASC;
+	`
+	rows, err := r.db.Query(query, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanCurrentWalks(rows)
+}
+
+// GetCurrentWalksPage returns up to limit running walks after the cursor,
+// whose ID is a session ID, ordered by start time then session ID and limited
+// to a tenant unless tenantID is empty.
+func (r *TimescaleRepository) GetCurrentWalksPage(tenantID string, after models.PageCursor, limit int) ([]models.CurrentWalk, error) {
+	if limit < 1 {
+		return nil, invalidInput("limit %d must be at least 1", limit)
+	}
+
+	query := `
+		SELECT session_id, walk_id, walker_id, dog_id, tenant_id, status, started_at, updated_at
+		FROM "` + r.schema + `"."` + currentWalksTableName + `"
+		WHERE ($1 = '' OR tenant_id = $1) AND (started_at, session_id) > ($2, $3)
+		ORDER BY started_at ASC, session_id ASC
+		LIMIT $4;
+	`
+	rows, err := r.db.Query(query, tenantID, after.Timestamp.UTC(), after.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanCurrentWalks(rows)
+}
+
+// scanCurrentWalks reads current walk rows selected as session_id, walk_id,
+// walker_id, dog_id, tenant_id, status, started_at, updated_at.
+func scanCurrentWalks(rows *sql.Rows) ([]models.CurrentWalk, error) {
+	var walks []models.CurrentWalk
+	for rows.Next() {
+		var w models.CurrentWalk
+		if err := rows.Scan(&w.SessionID, &w.WalkID, &w.WalkerID, &w.DogID, &w.TenantID,
+			&w.Status, &w.StartedAt, &w.UpdatedAt); err != nil {
+			return nil, err
+		}
+		walks = append(walks, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return walks, nil
+}
+
+// GetCurrentWalkChanges returns up to limit outbox changes with a sequence above afterSeq,
+// in sequence order.
+func (r *TimescaleRepository) GetCurrentWalkChanges(afterSeq int64, limit int) ([]models.CurrentWalkChange, error) {
+	if afterSeq < 0 {
+		return nil, invalidInput("afterSeq %d cannot be negative", afterSeq)
+	}
+	if limit < 1 {
+		return nil, invalidInput("limit %d must be at least 1", limit)
+	}
+
+	query := `
+		SELECT seq, change, walk, changed_at
+		FROM "` + r.schema + `"."` + currentWalkChangesTableName + `"
+		WHERE seq > $1
+		ORDER BY seq ASC
+		LIMIT $2;
+	`
+	rows, err := r.db.Query(query, afterSeq, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []models.CurrentWalkChange
+	for rows.Next() {
+		var c models.CurrentWalkChange
+		var walkJSON []byte
+		if err := rows.Scan(&c.Seq, &c.Change, &walkJSON, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(walkJSON, &c.Walk); err != nil {
+			return nil, fmt.Errorf("failed to decode current walk change %d: %w", c.Seq, err)
+		}
+		changes = append(changes, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// SaveRegionProfile inserts or replaces a region's profile.
+func (r *TimescaleRepository) SaveRegionProfile(profile *models.RegionProfile) error {
+	if profile == nil || profile.Region == "" {
+		return invalidInput("region is required")
+	}
+	updatedAt := profile.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO "` + r.schema + `"."` + regionProfilesTableName + `" (
+			region, max_speed_kmh, geofence_radius_km, retention_days, updated_at
+		) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (region) DO UPDATE SET
+			max_speed_kmh = EXCLUDED.max_speed_kmh,
+			geofence_radius_km = EXCLUDED.geofence_radius_km,
+			retention_days = EXCLUDED.retention_days,
+			updated_at = EXCLUDED.updated_at;
+	`
+	_, err := r.db.Exec(query, profile.Region, profile.MaxSpeedKmh, profile.GeofenceRadiusKm,
+		profile.RetentionDays, updatedAt)
+	return err
+}
+
+// GetRegionProfile returns a region's profile, or ErrNotFound when it has none.
+func (r *TimescaleRepository) GetRegionProfile(region string) (*models.RegionProfile, error) {
+	if region == "" {
+		return nil, invalidInput("region is empty")
+	}
+
+	query := `
+		SELECT region, max_speed_kmh, geofence_radius_km, retention_days, updated_at
+		FROM "` + r.schema + `"."` + regionProfilesTableName + `"
+		WHERE region = $1;
+	`
+	profile := &models.RegionProfile{}
+	if err := r.db.QueryRow(query, region).Scan(
+		&profile.Region,
+		&profile.MaxSpeedKmh,
+		&profile.GeofenceRadiusKm,
+		&profile.RetentionDays,
+		&profile.UpdatedAt,
+	); err != nil {
+		return nil, notFoundOr(err)
+	}
+	return profile, nil
+}
+
+// GetRegionProfiles returns every region profile, ordered by region.
+func (r *TimescaleRepository) GetRegionProfiles() ([]models.RegionProfile, error) {
+	query := `
+		SELECT region, max_speed_kmh, geofence_radius_km, retention_days, updated_at
+		FROM "` + r.schema + `"."` + regionProfilesTableName + `"
+		ORDER BY region ASC;
+	`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var profiles []models.RegionProfile
+	for rows.Next() {
+		var p models.RegionProfile
+		if err := rows.Scan(&p.Region, &p.MaxSpeedKmh, &p.GeofenceRadiusKm, &p.RetentionDays, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// DeleteRegionProfile removes a region's profile, returning ErrNotFound when it has none.
+// Walks already recorded in the region keep their region.
+func (r *TimescaleRepository) DeleteRegionProfile(region string) error {
+	if region == "" {
+		return invalidInput("region is empty")
+	}
+
+	query := `
+		DELETE FROM "` + r.schema + `"."` + regionProfilesTableName + `"
+		WHERE region = $1;
+	`
+	res, err := r.db.Exec(query, region)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: region profile %s", ErrNotFound, region)
+	}
+	return nil
+}
+
+// SaveOwnerAlertPreference inserts or replaces an owner's alert radius for a dog.
+func (r *TimescaleRepository) SaveOwnerAlertPreference(pref *models.OwnerAlertPreference) error {
+	if pref == nil || pref.OwnerID == "" || pref.DogID == "" {
+		return invalidInput("ownerID and dogID are required")
+	}
+	updatedAt := pref.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO "` + r.schema + `"."` + ownerAlertsTableName + `" (
+			owner_id, dog_id, radius_km, updated_at
+		) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (owner_id, dog_id) DO UPDATE SET
+			radius_km = EXCLUDED.radius_km,
+			updated_at = EXCLUDED.updated_at;
+	`
+	_, err := r.db.Exec(query, pref.OwnerID, pref.DogID, pref.RadiusKm, updatedAt)
+	return err
+}
+
+// GetOwnerAlertPreference returns an owner's alert radius for a dog, or ErrNotFound when
+// the owner has not set one.
+func (r *TimescaleRepository) GetOwnerAlertPreference(ownerID, dogID string) (*models.OwnerAlertPreference, error) {
+	if ownerID == "" || dogID == "" {
+		return nil, invalidInput("ownerID and dogID are required")
+	}
+
+	query := `
+		SELECT owner_id, dog_id, radius_km, updated_at
+		FROM "` + r.schema + `"."` + ownerAlertsTableName + `"
+		WHERE owner_id = $1 AND dog_id = $2;
+	`
+	pref := &models.OwnerAlertPreference{}
+	if err := r.db.QueryRow(query, ownerID, dogID).Scan(
+		&pref.OwnerID,
+		&pref.DogID,
+		&pref.RadiusKm,
+		&pref.UpdatedAt,
+	); err != nil {
+		return nil, notFoundOr(err)
+	}
+	return pref, nil
+}
+
+// GetOwnerAlertPreferencesForDog returns the alert radius of every owner of a dog who set
+// one, ordered by owner.
+func (r *TimescaleRepository) GetOwnerAlertPreferencesForDog(dogID string) ([]models.OwnerAlertPreference, error) {
+	if dogID == "" {
+		return nil, invalidInput("dogID is empty")
+	}
+
+	query := `
+		SELECT owner_id, dog_id, radius_km, updated_at
+		FROM "` + r.schema + `"."` + ownerAlertsTableName + `"
+		WHERE dog_id = $1
+		ORDER BY owner_id ASC;
+	`
+	rows, err := r.db.Query(query, dogID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prefs []models.OwnerAlertPreference
+	for rows.Next() {
+		var p models.OwnerAlertPreference
+		if err := rows.Scan(&p.OwnerID, &p.DogID, &p.RadiusKm, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		prefs = append(prefs, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+// DeleteOwnerAlertPreference removes an owner's alert radius for a dog, returning
+// ErrNotFound when the owner has not set one.
+func (r *TimescaleRepository) DeleteOwnerAlertPreference(ownerID, dogID string) error {
+	if ownerID == "" || dogID == "" {
+		return invalidInput("ownerID and dogID are required")
+	}
+
+	query := `
+		DELETE FROM "` + r.schema + `"."` + ownerAlertsTableName + `"
+		WHERE owner_id = $1 AND dog_id = $2;
+	`
+	res, err := r.db.Exec(query, ownerID, dogID)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: owner alert preference %s/%s", ErrNotFound, ownerID, dogID)
+	}
+	return nil
+}
+
+// RecordWalkRegion records the region a walk was started in. Recording a walk again keeps
+// its first region.
+func (r *TimescaleRepository) RecordWalkRegion(walkID, region string) error {
+	if walkID == "" || region == "" {
+		return invalidInput("walkID and region are required")
+	}
+
+	query := `
+		INSERT INTO "` + r.schema + `"."` + walkRegionsTableName + `" (walk_id, region)
+		VALUES ($1, $2)
+		ON CONFLICT (walk_id) DO NOTHING;
+	`
+	_, err := r.db.Exec(query, walkID, region)
+	return err
+}
+
+// PurgeRegionLocations deletes the location points recorded before cutoff for walks started
+// in the region, returning a summary of what was deleted. With dryRun nothing is deleted and
+// the summary describes what would be.
+func (r *TimescaleRepository) PurgeRegionLocations(region string, cutoff time.Time, dryRun bool) (*models.RetentionRows, error) {
+	if region == "" {
+		return nil, invalidInput("region is empty")
+	}
+	if cutoff.IsZero() {
+		return nil, invalidInput("cutoff is zero")
+	}
+
+	join := `"` + r.schema + `"."` + walkRegionsTableName + `" AS w`
+	summary, err := r.expireLocations(join, "w.region = $1 AND l.walk_id = w.walk_id AND l.recorded_at < $2", cutoff, dryRun, region, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	summary.Region = region
+	return &summary, nil
+}
+
+// GetActivitySparkline returns the walk's distance-per-minute series from the
+// per-minute activity continuous aggregate.
+func (r *TimescaleRepository) GetActivitySparkline(walkID string) (*models.ActivitySparkline, error) {
+	if walkID == "" {
+		return nil, invalidInput("walkID is empty")
+	}
+
+	query := `
+		SELECT minute, first_lat, first_lon, last_lat, last_lon, point_count
+		FROM "` + r.schema + `"."` + activityMinutesViewName + `"
+		WHERE walk_id = $1
+		ORDER BY minute ASC;
+	`
+	rows, err := r.db.Query(query, walkID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []models.ActivityBucket
+	for rows.Next() {
+		var b models.ActivityBucket
+		if err := rows.Scan(&b.Minute, &b.FirstLat, &b.FirstLon, &b.LastLat, &b.LastLon, &b.PointCount); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(buckets) == 0 {
+		return nil, fmt.Errorf("%w: no activity recorded for walk %s", ErrNotFound, walkID)
+	}
+	return models.BuildActivitySparkline(walkID, buckets), nil
+}
+
+// QueryLocations returns the points recorded inside q.Polygon between q.From and
+// q.To, or per-walk counts of them. Containment is tested with ST_Within on the
+// point's planar geometry, served by the geo::geometry GIST index, while the
+// time bounds let TimescaleDB skip chunks outside the window.
+//
+// A DogID filter matches walks with recorded territory for the dog, plus the
+// in-progress walks listed in q.DogWalkIDs.
+func (r *TimescaleRepository) QueryLocations(q models.LocationQuery) (*models.LocationQueryResult, error) {
+	if err := q.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidInput, err)
+	}
+	polygon, err := q.Polygon.GeoJSON()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidInput, err)
+	}
+
+	where := `
+		WHERE recorded_at >= $1 AND recorded_at <= $2
+			AND ST_Within(geo::geometry, ST_SetSRID(ST_GeomFromGeoJSON($3), 4326))`
+	args := []interface{}{q.From.UTC(), q.To.UTC(), polygon}
+	if q.WalkID != "" {
+		args = append(args, q.WalkID)
+		where += `
+			AND walk_id = $` + r.intToString(int64(len(args)))
+	}
+	if q.DogID != "" {
+		args = append(args, q.DogID, pq.Array(q.DogWalkIDs))
+		where += `
+			AND (walk_id IN (
+				SELECT walk_id FROM "` + r.schema + `"."` + territoryTableName + `"
+				WHERE dog_id = $` + r.intToString(int64(len(args)-1)) + `
+			) OR walk_id = ANY($` + r.intToString(int64(len(args))) + `))`
+	}
+
+	result := &models.LocationQueryResult{Mode: q.Mode}
+	if q.Mode == models.LocationQueryCounts {
+		query := `
+			SELECT walk_id, COUNT(*), MIN(recorded_at), MAX(recorded_at)
+			FROM "` + r.schema + `"."` + locationTableName + `"` + where + `
+			GROUP BY walk_id
+			ORDER BY COUNT(*) DESC, walk_id ASC;
+		`
+		rows, err := r.db.Query(query, args...)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var c models.WalkPointCount
+			if err := rows.Scan(&c.WalkID, &c.Points, &c.FirstSeen, &c.LastSeen); err != nil {
+				return nil, err
+			}
+			result.Counts = append(result.Counts, c)
+			result.Total += c.Points
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		return result, nil
 	}
 
+	args = append(args, q.Limit)
 	query := `
-		SELECT total_distance, duration_seconds
-		FROM "` + r.schema + `"."` + sessionTableName + `"
-		WHERE walk_id = $1
-		LIMIT 1;
+		SELECT id, walk_id, latitude, longitude, accuracy, recorded_at, provider, COUNT(*) OVER ()
+		FROM "` + r.schema + `"."` + locationTableName + `"` + where + `
+		ORDER BY recorded_at ASC
+		LIMIT $` + r.intToString(int64(len(args))) + `;
 	`
-
-	var distance float64
-	var durationSec float64
-	err := r.db.QueryRow(query, walkID).Scan(&distance, &durationSec)
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	// We'll simulate the rest of the fields in TrackingStatistics
-	stats := &models.TrackingStatistics{
-		TotalDistance: distance,
-		Duration:      time.Duration(durationSec * float64(time.Second)),
-		AverageSpeed:  0,
-		MaxSpeed:      0,
-		MinSpeed:      0,
+	for rows.Next() {
+		loc := models.Location{IsValid: true}
+		if err := rows.Scan(&loc.ID, &loc.WalkID, &loc.Latitude, &loc.Longitude, &loc.Accuracy,
+			&loc.Timestamp, &loc.Provider, &result.Total); err != nil {
+			return nil, err
+		}
+		result.Points = append(result.Points, loc)
 	}
-
-	// Basic average speed calculation
-	if stats.Duration.Seconds() > 0 {
-		stats.AverageSpeed = distance / stats.Duration.Seconds()
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
-
-	return stats, nil
+	result.Truncated = result.Total > len(result.Points)
+	return result, nil
 }
 
 // ManageRetention is an exported method that triggers data retention management according
@@ -535,15 +3039,18 @@ func (r *TimescaleRepository) GetSessionStatistics(walkID string) (*models.Track
 // data from older chunks.
 //
 // Steps:
-//  1. Apply compression to eligible chunks older than the defined compression interval.
-//  2. Remove expired data beyond the configured retention window.
-//  3. Update continuous aggregates if necessary.
-//  4. Reindex affected partitions.
-//  5. Log or track retention metrics if needed.
-func (r *TimescaleRepository) ManageRetention() error {
+//  1. Apply compression to location chunks older than the compression interval.
+//  2. Remove location points beyond the configured retention window.
+//  3. Drop session event and session summary chunks older than their retention.
+//  4. Refresh continuous aggregates.
+//
+// It returns what was compressed, deleted and dropped. With dryRun nothing is modified, and the
+// report lists what a run would do now, whether or not retention is enabled.
+func (r *TimescaleRepository) ManageRetention(dryRun bool) (*models.RetentionReport, error) {
 	retConf := RetentionConfig{
 		RetentionPeriod: r.RetentionPolicy.MaxAge,
 		PolicyEnabled:   r.config.RetentionEnabled,
+		DryRun:          dryRun,
 	}
 	return r.manageRetention(retConf)
 }
@@ -552,41 +3059,93 @@ func (r *TimescaleRepository) ManageRetention() error {
 type RetentionConfig struct {
 	RetentionPeriod time.Duration
 	PolicyEnabled   bool
+	// DryRun reports what the operation would do without modifying data.
+	DryRun bool
 }
 
-// manageRetention applies compression and data pruning policies if retention is enabled.
-func (r *TimescaleRepository) manageRetention(cfg RetentionConfig) error {
-	if !cfg.PolicyEnabled {
-		return nil
+// manageRetention applies compression and data pruning policies if retention is enabled, or
+// reports what they would do for a dry run. The steps are not run in one transaction: refreshing
+// a continuous aggregate cannot run inside one.
+func (r *TimescaleRepository) manageRetention(cfg RetentionConfig) (*models.RetentionReport, error) {
+	report := &models.RetentionReport{
+		DryRun:      cfg.DryRun,
+		GeneratedAt: time.Now().UTC(),
+		Compressed:  []models.RetentionChunk{},
+		Dropped:     []models.RetentionChunk{},
+		Deleted:     []models.RetentionRows{},
+	}
+	if !cfg.PolicyEnabled && !cfg.DryRun {
+		return report, nil
 	}
 
-	tx, err := r.db.Begin()
+	// Chunks are listed up front against one cutoff each, so a dry run lists exactly the chunks
+	// a run at the same moment acts on.
+	compressBefore := report.GeneratedAt.Add(-r.CompressionPolicy.IntervalAfterChunkCreation)
+	compress, err := r.listChunks(locationTableName, compressBefore, true)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer func() {
-		if p := recover(); p != nil {
-			_ = tx.Rollback()
+
+	type dropTarget struct {
+		table  string
+		cutoff time.Time
+	}
+	var drops []dropTarget
+	var dropped []models.RetentionChunk
+	for _, p := range []struct {
+		table     string
+		retention time.Duration
+	}{
+		{sessionEventsTableName, r.config.EventRetention},
+		{sessionTableName, r.config.SessionRetention},
+	} {
+		if p.retention <= 0 {
+			continue
 		}
-	}()
+		cutoff := report.GeneratedAt.Add(-p.retention)
+		chunks, err := r.listChunks(p.table, cutoff, false)
+		if err != nil {
+			return nil, err
+		}
+		drops = append(drops, dropTarget{p.table, cutoff})
+		dropped = append(dropped, chunks...)
+	}
+
+	deleteBefore := report.GeneratedAt.Add(-cfg.RetentionPeriod)
+	if cfg.DryRun {
+		deleted, err := r.expireLocations("", "l.recorded_at < $1", deleteBefore, true, deleteBefore)
+		if err != nil {
+			return nil, err
+		}
+		report.Compressed = append(report.Compressed, compress...)
+		report.Dropped = append(report.Dropped, dropped...)
+		report.Deleted = append(report.Deleted, deleted)
+		return report, nil
+	}
 
 	// Apply compression to older chunks
-	compressSQL := `
-		SELECT compress_chunk(i.chunk_name)
-		FROM show_chunks('` + r.schema + "." + locationTableName + `') i
-		WHERE i.chunk_name NOT IN (
-			SELECT chunk
-			FROM timescaledb_information.compressed_chunks
-		);
-	`
-	_, _ = tx.Exec(compressSQL) // ignoring result, best-effort
+	for _, chunk := range compress {
+		if _, err := r.db.Exec(`SELECT compress_chunk($1::regclass, if_not_compressed => TRUE);`, chunk.Chunk); err != nil {
+			return report, err
+		}
+		report.Compressed = append(report.Compressed, chunk)
+	}
 
 	// Remove data older than RetentionPeriod
-	removeSQL := `
-		DELETE FROM "` + r.schema + `"."` + locationTableName + `"
-		WHERE recorded_at < NOW() - INTERVAL '` + r.intervalToString(int64(cfg.RetentionPeriod.Seconds())) + `';
-	`
-	_, _ = tx.Exec(removeSQL)
+	deleted, err := r.expireLocations("", "l.recorded_at < $1", deleteBefore, false, deleteBefore)
+	if err != nil {
+		return report, err
+	}
+	report.Deleted = append(report.Deleted, deleted)
+
+	// Drop event and session chunks past their retention
+	for _, d := range drops {
+		qualified := `"` + r.schema + `"."` + d.table + `"`
+		if _, err := r.db.Exec(`SELECT drop_chunks($1::regclass, older_than => $2::timestamptz);`, qualified, d.cutoff); err != nil {
+			return report, err
+		}
+	}
+	report.Dropped = append(report.Dropped, dropped...)
 
 	// Reindex or refresh continuous aggregates if needed
 	for _, viewName := range r.config.AdditionalContinuousAggregateViews {
@@ -597,14 +3156,91 @@ func (r *TimescaleRepository) manageRetention(cfg RetentionConfig) error {
 				NULL
 			);
 		`
-		_, _ = tx.Exec(refreshSQL)
+		_, _ = r.db.Exec(refreshSQL) // best-effort; the aggregates' own policies catch up
 	}
+	return report, nil
+}
 
-	if errCommit := tx.Commit(); errCommit != nil {
-		_ = tx.Rollback()
-		return errCommit
+// listChunks returns the chunks of the hypertable whose whole time range ends at or before
+// before, oldest first, with their row counts and sizes. With uncompressedOnly, compressed
+// chunks are left out.
+func (r *TimescaleRepository) listChunks(table string, before time.Time, uncompressedOnly bool) ([]models.RetentionChunk, error) {
+	query := `
+		SELECT format('%I.%I', chunk_schema, chunk_name), range_start, range_end,
+			pg_total_relation_size(format('%I.%I', chunk_schema, chunk_name)::regclass)
+		FROM timescaledb_information.chunks
+		WHERE hypertable_schema = $1
+			AND hypertable_name = $2
+			AND range_end <= $3
+			AND ($4 = FALSE OR NOT is_compressed)
+		ORDER BY range_start ASC;
+	`
+	rows, err := r.db.Query(query, r.schema, table, before, uncompressedOnly)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	defer rows.Close()
+
+	var chunks []models.RetentionChunk
+	for rows.Next() {
+		c := models.RetentionChunk{Table: table}
+		if err := rows.Scan(&c.Chunk, &c.RangeStart, &c.RangeEnd, &c.Bytes); err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Count rows once the listing is closed; chunk names come quoted from format('%I.%I').
+	for i := range chunks {
+		if err := r.db.QueryRow(`SELECT COUNT(*) FROM ` + chunks[i].Chunk + `;`).Scan(&chunks[i].Rows); err != nil {
+			return nil, err
+		}
+	}
+	return chunks, nil
+}
+
+// expireLocations deletes the location points, aliased l, matching where, and summarizes what
+// was deleted; with dryRun the points are only summarized. A non-empty join adds a table, such
+// as walk regions aliased w, that where can refer to. Args are bound to where; cutoff, the time
+// where selects points recorded before, is only reported.
+func (r *TimescaleRepository) expireLocations(join, where string, cutoff time.Time, dryRun bool, args ...interface{}) (models.RetentionRows, error) {
+	table := `"` + r.schema + `"."` + locationTableName + `" AS l`
+
+	var source string
+	if dryRun {
+		from := table
+		if join != "" {
+			from += `, ` + join
+		}
+		source = `SELECT l.walk_id, l.recorded_at, pg_column_size(l.*) AS bytes FROM ` + from + ` WHERE ` + where
+	} else {
+		using := ""
+		if join != "" {
+			using = ` USING ` + join
+		}
+		source = `DELETE FROM ` + table + using + ` WHERE ` + where + ` RETURNING l.walk_id, l.recorded_at, pg_column_size(l.*) AS bytes`
+	}
+	query := `
+		WITH expired AS (` + source + `)
+		SELECT COUNT(*), COUNT(DISTINCT walk_id), COALESCE(SUM(bytes), 0), MIN(recorded_at), MAX(recorded_at)
+		FROM expired;
+	`
+
+	summary := models.RetentionRows{Table: locationTableName, Cutoff: cutoff}
+	var oldest, newest sql.NullTime
+	if err := r.db.QueryRow(query, args...).Scan(&summary.Rows, &summary.Walks, &summary.Bytes, &oldest, &newest); err != nil {
+		return summary, err
+	}
+	if oldest.Valid {
+		summary.Oldest = &oldest.Time
+	}
+	if newest.Valid {
+		summary.Newest = &newest.Time
+	}
+	return summary, nil
 }
 
 // intervalToString converts an integer representing seconds into a string representation
@@ -680,4 +3316,375 @@ func formatInt(i int64) []byte {
 		buf = append([]byte{'-'}, buf...)
 	}
 	return buf
-}
\ No newline at end of file
+}
+
+// SaveDevice pairs a device to its walker. Pairing a device again updates its name and
+// platform, but leaves a revoked device revoked and keeps the time it was first paired.
+func (r *TimescaleRepository) SaveDevice(device *models.Device) error {
+	if device == nil || device.WalkerID == "" || device.DeviceID == "" {
+		return invalidInput("walkerID and deviceID are required")
+	}
+	pairedAt := device.PairedAt
+	if pairedAt.IsZero() {
+		pairedAt = time.Now()
+	}
+
+	query := `
+		INSERT INTO "` + r.schema + `"."` + trustedDevicesTableName + `" (
+			walker_id, device_id, name, platform, paired_at
+		) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (walker_id, device_id) DO UPDATE SET
+			name = EXCLUDED.name,
+			platform = EXCLUDED.platform;
+	`
+	_, err := r.db.Exec(query, device.WalkerID, device.DeviceID, device.Name, device.Platform, pairedAt)
+	return err
+}
+
+// GetDevice returns a device the walker paired, revoked or not, or ErrNotFound when the
+// walker never paired it.
+func (r *TimescaleRepository) GetDevice(walkerID, deviceID string) (*models.Device, error) {
+	if walkerID == "" || deviceID == "" {
+		return nil, invalidInput("walkerID and deviceID are required")
+	}
+
+	query := `
+		SELECT walker_id, device_id, name, platform, paired_at, revoked_at
+		FROM "` + r.schema + `"."` + trustedDevicesTableName + `"
+		WHERE walker_id = $1 AND device_id = $2;
+	`
+	device := &models.Device{}
+	var revokedAt sql.NullTime
+	if err := r.db.QueryRow(query, walkerID, deviceID).Scan(
+		&device.WalkerID,
+		&device.DeviceID,
+		&device.Name,
+		&device.Platform,
+		&device.PairedAt,
+		&revokedAt,
+	); err != nil {
+		return nil, notFoundOr(err)
+	}
+	if revokedAt.Valid {
+		device.RevokedAt = &revokedAt.Time
+	}
+	return device, nil
+}
+
+// GetWalkerDevices returns every device the walker paired, including revoked ones, in
+// pairing order.
+func (r *TimescaleRepository) GetWalkerDevices(walkerID string) ([]models.Device, error) {
+	if walkerID == "" {
+		return nil, invalidInput("walkerID is empty")
+	}
+
+	query := `
+		SELECT walker_id, device_id, name, platform, paired_at, revoked_at
+		FROM "` + r.schema + `"."` + trustedDevicesTableName + `"
+		WHERE walker_id = $1
+		ORDER BY paired_at ASC, device_id ASC;
+	`
+	rows, err := r.db.Query(query, walkerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var devices []models.Device
+	for rows.Next() {
+		var d models.Device
+		var revokedAt sql.NullTime
+		if err := rows.Scan(&d.WalkerID, &d.DeviceID, &d.Name, &d.Platform, &d.PairedAt, &revokedAt); err != nil {
+			return nil, err
+		}
+		if revokedAt.Valid {
+			d.RevokedAt = &revokedAt.Time
+		}
+		devices = append(devices, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return devices, nil
+}
+
+// RevokeDevice marks a device the walker paired as revoked at the given time, returning
+// ErrNotFound when the walker never paired it. Revoking a revoked device keeps the time
+// it was first revoked.
+func (r *TimescaleRepository) RevokeDevice(walkerID, deviceID string, at time.Time) error {
+	if walkerID == "" || deviceID == "" {
+		return invalidInput("walkerID and deviceID are required")
+	}
+
+	query := `
+		UPDATE "` + r.schema + `"."` + trustedDevicesTableName + `"
+		SET revoked_at = COALESCE(revoked_at, $3)
+		WHERE walker_id = $1 AND device_id = $2;
+	`
+	res, err := r.db.Exec(query, walkerID, deviceID, at)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%w: device %s of walker %s", ErrNotFound, deviceID, walkerID)
+	}
+	return nil
+}
+
+// SaveQuarantinedPoints stores location points rejected at ingestion in one statement.
+func (r *TimescaleRepository) SaveQuarantinedPoints(points []models.QuarantinedPoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	values := make([]string, 0, len(points))
+	args := make([]interface{}, 0, len(points)*6)
+	for i, p := range points {
+		if p.ID == "" {
+			return invalidInput("quarantined point %d has no ID", i)
+		}
+		locationJSON, err := json.Marshal(p.Location)
+		if err != nil {
+			return fmt.Errorf("failed to encode quarantined point %s: %w", p.ID, err)
+		}
+		n := i * 6
+		values = append(values, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d)", n+1, n+2, n+3, n+4, n+5, n+6))
+		args = append(args, p.ID, p.SessionID, locationJSON, p.Reason, p.Detail, p.QuarantinedAt)
+	}
+	query := `
+		INSERT INTO "` + r.schema + `"."` + quarantinedPointsTableName + `" (
+			id, session_id, location, reason, detail, quarantined_at
+		) VALUES ` + strings.Join(values, ", ") + `
+		ON CONFLICT (id) DO NOTHING;
+	`
+	_, err := r.db.Exec(query, args...)
+	return err
+}
+
+// GetQuarantinedPoints returns the quarantined points of the query's session and reason,
+// when given, newest first, up to its limit.
+func (r *TimescaleRepository) GetQuarantinedPoints(query models.QuarantineQuery) ([]models.QuarantinedPoint, error) {
+	if query.Limit <= 0 {
+		return nil, invalidInput("limit must be positive")
+	}
+
+	sqlQuery := `
+		SELECT id, session_id, location, reason, detail, quarantined_at
+		FROM "` + r.schema + `"."` + quarantinedPointsTableName + `"
+		WHERE ($1 = '' OR session_id = $1) AND ($2 = '' OR reason = $2)
+		ORDER BY quarantined_at DESC, id DESC
+		LIMIT $3;
+	`
+	rows, err := r.db.Query(sqlQuery, query.SessionID, query.Reason, query.Limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanQuarantinedPoints(rows)
+}
+
+// GetQuarantinedPointsByID returns the quarantined points with the given IDs that exist,
+// oldest first.
+func (r *TimescaleRepository) GetQuarantinedPointsByID(ids []string) ([]models.QuarantinedPoint, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	query := `
+		SELECT id, session_id, location, reason, detail, quarantined_at
+		FROM "` + r.schema + `"."` + quarantinedPointsTableName + `"
+		WHERE id = ANY($1)
+		ORDER BY quarantined_at ASC, id ASC;
+	`
+	rows, err := r.db.Query(query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	return scanQuarantinedPoints(rows)
+}
+
+// DeleteQuarantinedPoints removes the quarantined points with the given IDs; IDs that are
+// not quarantined are ignored.
+func (r *TimescaleRepository) DeleteQuarantinedPoints(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := `
+		DELETE FROM "` + r.schema + `"."` + quarantinedPointsTableName + `"
+		WHERE id = ANY($1);
+	`
+	_, err := r.db.Exec(query, pq.Array(ids))
+	return err
+}
+
+// scanQuarantinedPoints reads quarantined point rows and closes them.
+func scanQuarantinedPoints(rows *sql.Rows) ([]models.QuarantinedPoint, error) {
+	defer rows.Close()
+
+	var points []models.QuarantinedPoint
+	for rows.Next() {
+		var p models.QuarantinedPoint
+		var locationJSON []byte
+		if err := rows.Scan(&p.ID, &p.SessionID, &locationJSON, &p.Reason, &p.Detail, &p.QuarantinedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(locationJSON, &p.Location); err != nil {
+			return nil, fmt.Errorf("failed to decode quarantined point %s: %w", p.ID, err)
+		}
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return points, nil
+}
+
+// SaveEscrowedKey stores a wrapped session stream key. A key already escrowed under the
+// same session and key ID is kept.
+func (r *TimescaleRepository) SaveEscrowedKey(key models.EscrowedStreamKey) error {
+	if key.SessionID == "" || key.KeyID == "" {
+		return invalidInput("escrowed key needs a session and key ID")
+	}
+
+	query := `
+		INSERT INTO "` + r.schema + `"."` + streamKeyEscrowTableName + `" (
+			session_id, key_id, tenant_id, ephemeral_key, nonce, wrapped_key, issued_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (session_id, key_id) DO NOTHING;
+	`
+	_, err := r.db.Exec(query, key.SessionID, key.KeyID, key.TenantID, key.EphemeralKey, key.Nonce, key.WrappedKey, key.IssuedAt)
+	return err
+}
+
+// GetEscrowedKeys returns the wrapped stream keys of a session, oldest first.
+func (r *TimescaleRepository) GetEscrowedKeys(sessionID string) ([]models.EscrowedStreamKey, error) {
+	query := `
+		SELECT session_id, key_id, tenant_id, ephemeral_key, nonce, wrapped_key, issued_at
+		FROM "` + r.schema + `"."` + streamKeyEscrowTableName + `"
+		WHERE session_id = $1
+		ORDER BY issued_at ASC, key_id ASC;
+	`
+	rows, err := r.db.Query(query, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []models.EscrowedStreamKey
+	for rows.Next() {
+		var k models.EscrowedStreamKey
+		if err := rows.Scan(&k.SessionID, &k.KeyID, &k.TenantID, &k.EphemeralKey, &k.Nonce, &k.WrappedKey, &k.IssuedAt); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// SaveEscrowAccess appends an entry to the escrow access log.
+func (r *TimescaleRepository) SaveEscrowAccess(record models.EscrowAccessRecord) error {
+	if record.ID == "" {
+		return invalidInput("escrow access has no ID")
+	}
+
+	query := `
+		INSERT INTO "` + r.schema + `"."` + escrowAccessLogTableName + `" (
+			id, session_id, tenant_id, legal_reference, requested_by, custodians, outcome, key_count, accessed_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);
+	`
+	_, err := r.db.Exec(query, record.ID, record.SessionID, record.TenantID, record.LegalReference,
+		record.RequestedBy, pq.Array(record.Custodians), record.Outcome, record.KeyCount, record.AccessedAt)
+	return err
+}
+
+// GetEscrowAccessLog returns the escrow access log of a tenant, or of all tenants when
+// tenantID is empty, newest first, up to limit entries.
+func (r *TimescaleRepository) GetEscrowAccessLog(tenantID string, limit int) ([]models.EscrowAccessRecord, error) {
+	if limit <= 0 {
+		return nil, invalidInput("limit must be positive")
+	}
+
+	query := `
+		SELECT id, session_id, tenant_id, legal_reference, requested_by, custodians, outcome, key_count, accessed_at
+		FROM "` + r.schema + `"."` + escrowAccessLogTableName + `"
+		WHERE ($1 = '' OR tenant_id = $1)
+		ORDER BY accessed_at DESC, id DESC
+		LIMIT $2;
+	`
+	rows, err := r.db.Query(query, tenantID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []models.EscrowAccessRecord
+	for rows.Next() {
+		var rec models.EscrowAccessRecord
+		if err := rows.Scan(&rec.ID, &rec.SessionID, &rec.TenantID, &rec.LegalReference, &rec.RequestedBy,
+			pq.Array(&rec.Custodians), &rec.Outcome, &rec.KeyCount, &rec.AccessedAt); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// SaveWalkAccess appends an entry to a walk's access log.
+func (r *TimescaleRepository) SaveWalkAccess(record models.WalkAccessRecord) error {
+	if record.ID == "" || record.WalkID == "" {
+		return invalidInput("walk access has no ID or walk ID")
+	}
+
+	query := `
+		INSERT INTO "` + r.schema + `"."` + walkAccessLogTableName + `" (
+			id, walk_id, session_id, actor, endpoint, request_id, accessed_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7);
+	`
+	_, err := r.db.Exec(query, record.ID, record.WalkID, record.SessionID, record.Actor,
+		record.Endpoint, record.RequestID, record.AccessedAt)
+	return err
+}
+
+// GetWalkAccessLog returns the access log of a walk, including reads that named one of
+// its sessions, newest first, up to limit entries.
+func (r *TimescaleRepository) GetWalkAccessLog(walkID string, limit int) ([]models.WalkAccessRecord, error) {
+	if walkID == "" {
+		return nil, invalidInput("walk ID is required")
+	}
+	if limit <= 0 {
+		return nil, invalidInput("limit must be positive")
+	}
+
+	query := `
+		SELECT id, walk_id, session_id, actor, endpoint, request_id, accessed_at
+		FROM "` + r.schema + `"."` + walkAccessLogTableName + `"
+		WHERE walk_id = $1 OR session_id = $1
+		ORDER BY accessed_at DESC, id DESC
+		LIMIT $2;
+	`
+	rows, err := r.db.Query(query, walkID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []models.WalkAccessRecord
+	for rows.Next() {
+		var rec models.WalkAccessRecord
+		if err := rows.Scan(&rec.ID, &rec.WalkID, &rec.SessionID, &rec.Actor, &rec.Endpoint,
+			&rec.RequestID, &rec.AccessedAt); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
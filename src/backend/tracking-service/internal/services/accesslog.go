@@ -0,0 +1,87 @@
+package services
+
+import (
+	// errors for the disabled access log sentinel (go1.21)
+	"errors"
+	// time for access timestamps (go1.21)
+	"time"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// models package that includes the WalkAccessRecord struct
+	"src/backend/tracking-service/internal/models"
+)
+
+// ErrAccessLogDisabled is returned by walk access log listings when no access
+// log store is configured.
+var ErrAccessLogDisabled = errors.New("walk access logging is not enabled")
+
+// WalkAccessStore persists the access logs of walks. It is implemented by
+// repository.TimescaleRepository.
+type WalkAccessStore interface {
+	// SaveWalkAccess appends an entry to a walk's access log.
+	SaveWalkAccess(record models.WalkAccessRecord) error
+
+	// GetWalkAccessLog returns the access log of a walk, or of the session
+	// with that ID, newest first, up to limit entries.
+	GetWalkAccessLog(walkID string, limit int) ([]models.WalkAccessRecord, error)
+}
+
+// SetWalkAccessStore enables recording every read of a walk's location data.
+// Passing nil disables it.
+func (ts *TrackingService) SetWalkAccessStore(store WalkAccessStore) {
+	ts.accessLog = store
+}
+
+// RecordWalkAccess records that actor read the location data of the walk or
+// session id through endpoint. The walk of an active session is resolved
+// from it; other IDs are recorded as given. Failures are logged and otherwise
+// ignored, so the access log never fails the read it records.
+func (ts *TrackingService) RecordWalkAccess(id, actor, endpoint, requestID string) {
+	if ts.accessLog == nil || id == "" {
+		return
+	}
+	record := models.WalkAccessRecord{
+		ID:         models.NewID(),
+		WalkID:     id,
+		Actor:      actor,
+		Endpoint:   endpoint,
+		RequestID:  requestID,
+		AccessedAt: time.Now().UTC(),
+	}
+	if session, err := ts.getSession(id); err == nil {
+		record.SessionID = id
+		if walkID := session.WalkID(); walkID != "" {
+			record.WalkID = walkID
+		}
+	}
+	if err := ts.accessLog.SaveWalkAccess(record); err != nil {
+		ts.logger.Error("Failed to record walk access",
+			zap.String("walkID", record.WalkID),
+			zap.String("endpoint", endpoint),
+			zap.Error(err),
+		)
+	}
+}
+
+// GetWalkAccessLog lists the reads of a walk's location data, newest first.
+func (ts *TrackingService) GetWalkAccessLog(walkID string, limit int) ([]models.WalkAccessRecord, error) {
+	if ts.accessLog == nil {
+		return nil, ErrAccessLogDisabled
+	}
+	if limit <= 0 {
+		limit = models.DefaultWalkAccessLogLimit
+	}
+	if limit > models.MaxWalkAccessLogLimit {
+		limit = models.MaxWalkAccessLogLimit
+	}
+	records, err := ts.accessLog.GetWalkAccessLog(walkID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if records == nil {
+		records = []models.WalkAccessRecord{}
+	}
+	return records, nil
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+	// bytes for building the rule file (go1.21)
+	"bytes"
+	// fmt for rendering rule expressions (go1.21)
+	"fmt"
+	// sort for ordering the queue rules (go1.21)
+	"sort"
+	// strconv for quoting rule strings and formatting thresholds (go1.21)
+	"strconv"
+	// time for the configured windows and limits (go1.21)
+	"time"
+)
+
+// Error budget burn rate alerts: a fast burn spends the given fraction of the
+// budget within the long window, confirmed over the short one so the alert
+// clears soon after the burn stops.
+const (
+	fastBurnBudgetFraction = 0.02
+	slowBurnBudgetFraction = 0.05
+)
+
+// AlertThresholds are the configured limits the recommended alerting rules are
+// derived from. Rules whose feature is disabled, signalled by a nil or zero
+// field, are omitted.
+type AlertThresholds struct {
+	// BreakerTimeout is how long the database circuit breaker stays open
+	// before letting probe requests through.
+	BreakerTimeout time.Duration
+
+	// MQTTBreakerTimeout is how long the MQTT publish circuit breaker stays
+	// open before letting a probe publish through.
+	MQTTBreakerTimeout time.Duration
+
+	// SLO is the location delivery objective, when tracked.
+	SLO *SLOObjective
+
+	// QueueThresholds are the runtime monitor's queue limits by queue name,
+	// which must be exceeded for QueueSustainFor to alert.
+	QueueThresholds map[string]int
+	QueueSustainFor time.Duration
+
+	// SpoolMaxBatches is the capacity of the degradation spool.
+	SpoolMaxBatches int
+
+	// Backpressure is set when low-priority subscriptions can be paused.
+	Backpressure bool
+
+	// IntegrityInterval is the data integrity checker's schedule.
+	IntegrityInterval time.Duration
+}
+
+// AlertRule is one Prometheus alerting rule.
+type AlertRule struct {
+	Alert       string
+	Expr        string
+	For         time.Duration
+	Severity    string
+	Summary     string
+	Description string
+}
+
+// AlertRuleGroup is a named group of alerting rules evaluated together.
+type AlertRuleGroup struct {
+	Name  string
+	Rules []AlertRule
+}
+
+// RecommendedAlertRules returns the alerting rules recommended for a
+// deployment configured with th: the database and MQTT publish circuit
+// breakers staying open, location delivery latency and error budget burn,
+// internal queue backlogs, and failures of the data integrity checker, which
+// samples completed walks as the service's canary. The expressions use the metric names the service
+// registers, so they must follow any rename.
+func RecommendedAlertRules(th AlertThresholds) []AlertRuleGroup {
+	var groups []AlertRuleGroup
+	add := func(name string, rules []AlertRule) {
+		if len(rules) > 0 {
+			groups = append(groups, AlertRuleGroup{Name: name, Rules: rules})
+		}
+	}
+	add("tracking_breaker", breakerAlertRules(th))
+	add("tracking_latency", latencyAlertRules(th))
+	add("tracking_backlog", backlogAlertRules(th))
+	add("tracking_canary", canaryAlertRules(th))
+	return groups
+}
+
+// breakerAlertRules alert when the database or MQTT publish circuit breaker
+// has not closed for two of its open periods, i.e. its probes keep failing,
+// and when messages the broker could not take are dropped from the MQTT
+// fallback queue.
+func breakerAlertRules(th AlertThresholds) []AlertRule {
+	var rules []AlertRule
+	if th.BreakerTimeout > 0 {
+		rules = append(rules, AlertRule{
+			Alert:       "TrackingDBBreakerOpen",
+			Expr:        "tracking_db_breaker_state > 0",
+			For:         2 * th.BreakerTimeout,
+			Severity:    "critical",
+			Summary:     "TimescaleDB circuit breaker is not closed on {{ $labels.instance }}",
+			Description: "Location batches and session metrics are failing fast instead of reaching the database.",
+		})
+	}
+	if th.MQTTBreakerTimeout > 0 {
+		rules = append(rules,
+			AlertRule{
+				Alert:       "TrackingMQTTBreakerOpen",
+				Expr:        "tracking_mqtt_breaker_state > 0",
+				For:         2 * th.MQTTBreakerTimeout,
+				Severity:    "critical",
+				Summary:     "MQTT publish circuit breaker is not closed on {{ $labels.instance }}",
+				Description: "Messages for devices, owners and peer regions are waiting in the fallback queue instead of reaching the broker.",
+			},
+			AlertRule{
+				Alert:       "TrackingMQTTFallbackDropping",
+				Expr:        `sum by (instance) (rate(tracking_mqtt_fallback_messages_total{outcome=~"dropped|expired"}[5m])) > 0`,
+				For:         5 * time.Minute,
+				Severity:    "warning",
+				Summary:     "MQTT messages are dropped from the fallback queue on {{ $labels.instance }}",
+				Description: "The broker has been unavailable long enough for the fallback queue to fill or its messages to expire.",
+			},
+		)
+	}
+	return rules
+}
+
+// latencyAlertRules alert when location delivery misses its latency threshold
+// at the target quantile, and when the error budget burns fast enough to be
+// exhausted early.
+func latencyAlertRules(th AlertThresholds) []AlertRule {
+	slo := th.SLO
+	if slo == nil {
+		return nil
+	}
+	selector := fmt.Sprintf("{slo=%q}", slo.Name)
+	burnRate := func(fraction float64, window time.Duration) float64 {
+		return fraction * float64(slo.Period) / float64(window)
+	}
+	burnExpr := func(long, short time.Duration, rate float64) string {
+		return fmt.Sprintf("tracking_slo_burn_rate{slo=%q,window=%q} > %s and ignoring(window) tracking_slo_burn_rate{slo=%q,window=%q} > %s",
+			slo.Name, formatSLOWindow(long), formatAlertFloat(rate),
+			slo.Name, formatSLOWindow(short), formatAlertFloat(rate))
+	}
+	fastRate := burnRate(fastBurnBudgetFraction, time.Hour)
+	slowRate := burnRate(slowBurnBudgetFraction, 6*time.Hour)
+
+	return []AlertRule{
+		{
+			Alert: "TrackingDeliveryLatencyHigh",
+			Expr: fmt.Sprintf("histogram_quantile(%s, sum by (le) (rate(tracking_pipeline_latency_seconds_bucket%s[5m]))) > %s",
+				formatAlertFloat(slo.Target), selector, formatAlertFloat(slo.Threshold.Seconds())),
+			For:         10 * time.Minute,
+			Severity:    "warning",
+			Summary:     fmt.Sprintf("Location delivery latency at the %s quantile is above %s", formatAlertFloat(slo.Target), formatAlertDuration(slo.Threshold)),
+			Description: "Location updates take longer than the SLO threshold to reach subscribers.",
+		},
+		{
+			Alert:       "TrackingSLOBudgetFastBurn",
+			Expr:        burnExpr(time.Hour, 5*time.Minute, fastRate),
+			For:         2 * time.Minute,
+			Severity:    "critical",
+			Summary:     fmt.Sprintf("The %s error budget is burning at over %sx", slo.Name, formatAlertFloat(fastRate)),
+			Description: fmt.Sprintf("At this rate %.0f%% of the %s error budget is spent within an hour.", fastBurnBudgetFraction*100, formatAlertDuration(slo.Period)),
+		},
+		{
+			Alert:       "TrackingSLOBudgetSlowBurn",
+			Expr:        burnExpr(6*time.Hour, 30*time.Minute, slowRate),
+			For:         15 * time.Minute,
+			Severity:    "warning",
+			Summary:     fmt.Sprintf("The %s error budget is burning at over %sx", slo.Name, formatAlertFloat(slowRate)),
+			Description: fmt.Sprintf("At this rate %.0f%% of the %s error budget is spent within six hours.", slowBurnBudgetFraction*100, formatAlertDuration(slo.Period)),
+		},
+	}
+}
+
+// backlogAlertRules alert when internal queues stay above their limits, the
+// spool fills, or low-priority subscriptions stay paused.
+func backlogAlertRules(th AlertThresholds) []AlertRule {
+	var rules []AlertRule
+	queues := make([]string, 0, len(th.QueueThresholds))
+	for queue, limit := range th.QueueThresholds {
+		if limit > 0 {
+			queues = append(queues, queue)
+		}
+	}
+	sort.Strings(queues)
+	for _, queue := range queues {
+		rules = append(rules, AlertRule{
+			Alert:       "TrackingQueueBacklog",
+			Expr:        fmt.Sprintf("tracking_runtime_queue_depth{queue=%q} > %d", queue, th.QueueThresholds[queue]),
+			For:         th.QueueSustainFor,
+			Severity:    "warning",
+			Summary:     fmt.Sprintf("The %s queue is backed up on {{ $labels.instance }}", queue),
+			Description: fmt.Sprintf("The %s queue has held more than %d items for %s; the instance reports itself degraded.", queue, th.QueueThresholds[queue], formatAlertDuration(th.QueueSustainFor)),
+		})
+	}
+	if th.SpoolMaxBatches > 0 {
+		limit := th.SpoolMaxBatches * 8 / 10
+		rules = append(rules, AlertRule{
+			Alert:       "TrackingSpoolFilling",
+			Expr:        fmt.Sprintf("tracking_spool_batches > %d", limit),
+			For:         5 * time.Minute,
+			Severity:    "critical",
+			Summary:     "The location spool is over 80% full on {{ $labels.instance }}",
+			Description: fmt.Sprintf("Location batches are spooling while the database is unavailable; once %d are spooled, further batches fail.", th.SpoolMaxBatches),
+		})
+	}
+	if th.Backpressure {
+		rules = append(rules, AlertRule{
+			Alert:       "TrackingBackpressurePaused",
+			Expr:        "tracking_backpressure_paused == 1",
+			For:         10 * time.Minute,
+			Severity:    "warning",
+			Summary:     "Low-priority subscriptions are paused on {{ $labels.instance }}",
+			Description: "Messages published to the low-priority topics are being lost while the instance sheds load.",
+		})
+	}
+	return rules
+}
+
+// canaryAlertRules alert when the data integrity checker fails, stops
+// running, or finds discrepancies in the walks it samples.
+func canaryAlertRules(th AlertThresholds) []AlertRule {
+	if th.IntegrityInterval <= 0 {
+		return nil
+	}
+	window := 2 * th.IntegrityInterval
+	if window < 5*time.Minute {
+		window = 5 * time.Minute
+	}
+	return []AlertRule{
+		{
+			Alert:       "TrackingIntegrityRunFailing",
+			Expr:        fmt.Sprintf("increase(tracking_integrity_run_failures_total[%s]) > 0", formatAlertDuration(window)),
+			Severity:    "warning",
+			Summary:     "Data integrity runs are failing on {{ $labels.instance }}",
+			Description: "The integrity checker could not sample completed walks from the database.",
+		},
+		{
+			Alert:       "TrackingIntegrityStale",
+			Expr:        fmt.Sprintf("time() - tracking_integrity_last_run_timestamp_seconds > %s", formatAlertFloat((3 * th.IntegrityInterval).Seconds())),
+			For:         5 * time.Minute,
+			Severity:    "warning",
+			Summary:     "No data integrity run has completed recently on {{ $labels.instance }}",
+			Description: fmt.Sprintf("The integrity checker runs every %s but has not completed for three intervals.", formatAlertDuration(th.IntegrityInterval)),
+		},
+		{
+			Alert:       "TrackingIntegrityDiscrepancies",
+			Expr:        "sum by (check) (tracking_integrity_discrepancies) > 0",
+			Severity:    "warning",
+			Summary:     "Data integrity check {{ $labels.check }} found discrepancies",
+			Description: "Completed walks disagree with their event streams or stored points; see /admin/integrity.",
+		},
+	}
+}
+
+// FormatAlertRules renders groups as a Prometheus rule file.
+func FormatAlertRules(groups []AlertRuleGroup) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# Recommended alerting rules for the tracking service, generated from its configuration.\n")
+	buf.WriteString("groups:\n")
+	for _, g := range groups {
+		fmt.Fprintf(&buf, "  - name: %s\n", strconv.Quote(g.Name))
+		buf.WriteString("    rules:\n")
+		for _, r := range g.Rules {
+			fmt.Fprintf(&buf, "      - alert: %s\n", strconv.Quote(r.Alert))
+			fmt.Fprintf(&buf, "        expr: %s\n", strconv.Quote(r.Expr))
+			if r.For > 0 {
+				fmt.Fprintf(&buf, "        for: %s\n", formatAlertDuration(r.For))
+			}
+			buf.WriteString("        labels:\n")
+			fmt.Fprintf(&buf, "          severity: %s\n", strconv.Quote(r.Severity))
+			buf.WriteString("        annotations:\n")
+			fmt.Fprintf(&buf, "          summary: %s\n", strconv.Quote(r.Summary))
+			fmt.Fprintf(&buf, "          description: %s\n", strconv.Quote(r.Description))
+		}
+	}
+	return buf.Bytes()
+}
+
+// formatAlertDuration renders d in Prometheus duration syntax, such as 90s or
+// 1h30m.
+func formatAlertDuration(d time.Duration) string {
+	if d < time.Second {
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	}
+	var out string
+	for _, unit := range []struct {
+		size   time.Duration
+		suffix string
+	}{{time.Hour, "h"}, {time.Minute, "m"}, {time.Second, "s"}} {
+		if n := d / unit.size; n > 0 {
+			out += fmt.Sprintf("%d%s", n, unit.suffix)
+			d -= n * unit.size
+		}
+	}
+	return out
+}
+
+// formatAlertFloat renders f in its shortest form to six significant digits,
+// hiding the rounding of derived thresholds.
+func formatAlertFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', 6, 64)
+}
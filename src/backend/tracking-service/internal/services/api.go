@@ -0,0 +1,711 @@
+package services
+
+import (
+	// time for the signatures of time-bounded calls (go1.21)
+	"time"
+
+	// models package for the request and result types of the API
+	"src/backend/tracking-service/internal/models"
+)
+
+// TrackingAPI is the part of TrackingService that serves requests, as called
+// by the HTTP and WebSocket handlers. WithMiddleware wraps it so every method
+// passes through the same middleware chain; a method added here must also be
+// forwarded by middlewareAPI, which the compiler enforces, and then gets every
+// middleware without further changes.
+type TrackingAPI interface {
+	StartSession(walkID, walkerID, dogID string) (*models.TrackingSession, error)
+	StartOrContinueSession(tenantID, region, deviceID, walkID, walkerID, dogID string) (*models.TrackingSession, bool, error)
+	EndSession(sessionID string) error
+	SessionActive(sessionID string) bool
+	CheckIngress(sessionID, transport string) error
+	NormalizeLocation(loc *models.Location) error
+	ProcessLocationUpdate(sessionID string, loc models.Location) (BatchResult, error)
+	ProcessBatchLocations(sessionID string, locations []*models.Location) (BatchResult, error)
+	ConfirmSequenceGap(sessionID string, r models.SeqRange) (int, error)
+	ProcessSequencedUpload(upload SequencedUpload) (UploadAck, BatchResult, error)
+	AckedUploadSeq(sessionID string) uint64
+	ObserveStreamFirstFrame(sessionID string, wait time.Duration)
+	IngestBeaconEvents(sessionID string, events []models.BeaconEvent) (BeaconIngestResult, error)
+	GetSessionStatisticsAsOf(sessionID string, asOf time.Time) (*models.TrackingStatistics, error)
+	GetSessionSparkline(sessionID string) (*models.ActivitySparkline, error)
+	GetSessionTimeline(sessionID string) (*models.SessionTimeline, error)
+	GetSessionDogProfile(sessionID string) (*models.DogProfile, error)
+	ReviewSessionBreaches(sessionID string) (*models.BreachReview, error)
+	GetLocationHistoryPage(sessionID string, after models.PageCursor, limit int, filter models.HistoryFilter) ([]models.Location, *models.PageCursor, error)
+	GetRawSessionEventsPage(sessionID string, after models.PageCursor, limit int) ([]models.SessionStateEvent, *models.PageCursor, error)
+	ExportWalkGPX(sessionID string) ([]byte, error)
+	ExportSessionFIT(sessionID string) ([]byte, error)
+	LoadWalkReplay(sessionID string, speed int) (*WalkReplay, error)
+	ShareSession(sessionID, ownerID, subscriberID string) (StreamKey, error)
+	UnshareSession(sessionID, requesterID, subscriberID string) error
+	GetStreamKey(sessionID, subscriberID string) (StreamKey, error)
+	SealStreamFrame(sessionID string, payload []byte) (*SealedFrame, error)
+	AccessEscrowedKeys(sessionID string, req models.EscrowAccessRequest) (*EscrowAccessResult, error)
+	GetTerritoryCoverage(walkID string) (*models.TerritoryCoverage, error)
+	CreateGeofence(walkID string, spec models.GeofenceRecord) (*Geofence, error)
+	LoadGeofences(walkID string) ([]*Geofence, error)
+	PairDevice(walkerID string, pairing *models.DevicePairing) (*models.Device, error)
+	GetWalkerDevices(walkerID string) ([]models.Device, error)
+	RevokeDevice(walkerID, deviceID string) error
+	GetWalkerPresence() ([]WalkerPresence, error)
+	SaveFitnessToken(token *models.FitnessToken) error
+	GetOwnerAlertPreference(ownerID, dogID string) (*models.OwnerAlertPreference, error)
+	PutOwnerAlertPreference(pref *models.OwnerAlertPreference) error
+	DeleteOwnerAlertPreference(ownerID, dogID string) error
+	GetPopularRoutes(bbox models.BoundingBox, limit int) ([]models.PopularRouteSegment, error)
+	GetDemandHeatmap(bbox models.BoundingBox, tz string, days int) (*models.DemandHeatmap, error)
+	GetLeaderboard(tenantID, period, rankBy string, limit int) (*models.Leaderboard, error)
+	GetCurrentWalksPage(tenantID string, after models.PageCursor, limit int) ([]models.CurrentWalk, *models.PageCursor, error)
+	GetCurrentWalkChanges(afterSeq int64, limit int) (*models.CurrentWalkFeed, error)
+	QueryLocations(q models.LocationQuery) (*models.LocationQueryResult, error)
+	GetSLOStatus() (SLOStatus, error)
+	GetIntegrityReport() (*models.IntegrityReport, error)
+	GetRegionProfiles() ([]models.RegionProfile, error)
+	GetRegionProfile(region string) (*models.RegionProfile, error)
+	PutRegionProfile(profile *models.RegionProfile) error
+	DeleteRegionProfile(region string) error
+	GetGeofenceGroups() ([]models.GeofenceGroupRecord, error)
+	PutGeofenceGroup(spec *models.GeofenceGroupRecord) (*models.GeofenceGroupRecord, error)
+	DeleteGeofenceGroup(name string) error
+	SetSessionBatchPolicy(sessionID string, policy BatchPolicy) error
+	RebuildSession(sessionID string) (*models.TrackingSession, error)
+	PreviewRetention() (*models.RetentionReport, error)
+	GetQuarantinedPoints(query models.QuarantineQuery) ([]models.QuarantinedPoint, error)
+	ReingestQuarantined(ids []string) (*models.ReingestResult, error)
+	GetEscrowAccessLog(tenantID string, limit int) ([]models.EscrowAccessRecord, error)
+	RecordWalkAccess(id, actor, endpoint, requestID string)
+	GetWalkAccessLog(walkID string, limit int) ([]models.WalkAccessRecord, error)
+}
+
+var _ TrackingAPI = (*TrackingService)(nil)
+
+// middlewareAPI is the TrackingAPI returned by WithMiddleware.
+type middlewareAPI struct {
+	next  TrackingAPI
+	chain Middleware
+
+	// requestID is stamped on every call, set by WithRequestID.
+	requestID string
+}
+
+// WithMiddleware returns api with every method call passed through the
+// middlewares, the first outermost. Methods without an error result still
+// pass through the chain; an error from a middleware is then dropped and
+// their zero results returned.
+func WithMiddleware(api TrackingAPI, middlewares ...Middleware) TrackingAPI {
+	return &middlewareAPI{next: api, chain: Chain(middlewares...)}
+}
+
+// WithRequestID returns api with its calls stamped with requestID, so the
+// middlewares can correlate them with the request being served. api is
+// returned unchanged when it was not created by WithMiddleware.
+func WithRequestID(api TrackingAPI, requestID string) TrackingAPI {
+	s, ok := api.(*middlewareAPI)
+	if !ok || requestID == "" {
+		return api
+	}
+	scoped := *s
+	scoped.requestID = requestID
+	return &scoped
+}
+
+// invoke runs call through the chain, with run calling the wrapped method.
+func (s *middlewareAPI) invoke(call MethodCall, run func() error) error {
+	call.RequestID = s.requestID
+	return s.chain(func(MethodCall) error { return run() })(call)
+}
+
+// StartSession implements TrackingAPI.
+func (s *middlewareAPI) StartSession(walkID, walkerID, dogID string) (session *models.TrackingSession, err error) {
+	call := MethodCall{Method: "StartSession", ScopeKind: ScopeWalk, ScopeID: walkID}
+	err = s.invoke(call, func() error {
+		session, err = s.next.StartSession(walkID, walkerID, dogID)
+		return err
+	})
+	return
+}
+
+// StartOrContinueSession implements TrackingAPI.
+func (s *middlewareAPI) StartOrContinueSession(tenantID, region, deviceID, walkID, walkerID, dogID string) (session *models.TrackingSession, continued bool, err error) {
+	call := MethodCall{Method: "StartOrContinueSession", ScopeKind: ScopeWalk, ScopeID: walkID}
+	err = s.invoke(call, func() error {
+		session, continued, err = s.next.StartOrContinueSession(tenantID, region, deviceID, walkID, walkerID, dogID)
+		return err
+	})
+	return
+}
+
+// EndSession implements TrackingAPI.
+func (s *middlewareAPI) EndSession(sessionID string) error {
+	call := MethodCall{Method: "EndSession", ScopeKind: ScopeSession, ScopeID: sessionID}
+	return s.invoke(call, func() error {
+		return s.next.EndSession(sessionID)
+	})
+}
+
+// SessionActive implements TrackingAPI.
+func (s *middlewareAPI) SessionActive(sessionID string) (active bool) {
+	call := MethodCall{Method: "SessionActive", ScopeKind: ScopeSession, ScopeID: sessionID}
+	_ = s.invoke(call, func() error {
+		active = s.next.SessionActive(sessionID)
+		return nil
+	})
+	return
+}
+
+// CheckIngress implements TrackingAPI.
+func (s *middlewareAPI) CheckIngress(sessionID, transport string) error {
+	call := MethodCall{Method: "CheckIngress", ScopeKind: ScopeSession, ScopeID: sessionID}
+	return s.invoke(call, func() error {
+		return s.next.CheckIngress(sessionID, transport)
+	})
+}
+
+// NormalizeLocation implements TrackingAPI.
+func (s *middlewareAPI) NormalizeLocation(loc *models.Location) error {
+	call := MethodCall{Method: "NormalizeLocation"}
+	return s.invoke(call, func() error {
+		return s.next.NormalizeLocation(loc)
+	})
+}
+
+// ProcessLocationUpdate implements TrackingAPI.
+func (s *middlewareAPI) ProcessLocationUpdate(sessionID string, loc models.Location) (result BatchResult, err error) {
+	call := MethodCall{Method: "ProcessLocationUpdate", ScopeKind: ScopeSession, ScopeID: sessionID}
+	err = s.invoke(call, func() error {
+		result, err = s.next.ProcessLocationUpdate(sessionID, loc)
+		return err
+	})
+	return
+}
+
+// ProcessBatchLocations implements TrackingAPI.
+func (s *middlewareAPI) ProcessBatchLocations(sessionID string, locations []*models.Location) (result BatchResult, err error) {
+	call := MethodCall{Method: "ProcessBatchLocations", ScopeKind: ScopeSession, ScopeID: sessionID}
+	err = s.invoke(call, func() error {
+		result, err = s.next.ProcessBatchLocations(sessionID, locations)
+		return err
+	})
+	return
+}
+
+// ConfirmSequenceGap implements TrackingAPI.
+func (s *middlewareAPI) ConfirmSequenceGap(sessionID string, r models.SeqRange) (confirmed int, err error) {
+	call := MethodCall{Method: "ConfirmSequenceGap", ScopeKind: ScopeSession, ScopeID: sessionID}
+	err = s.invoke(call, func() error {
+		confirmed, err = s.next.ConfirmSequenceGap(sessionID, r)
+		return err
+	})
+	return
+}
+
+// ProcessSequencedUpload implements TrackingAPI.
+func (s *middlewareAPI) ProcessSequencedUpload(upload SequencedUpload) (ack UploadAck, result BatchResult, err error) {
+	call := MethodCall{Method: "ProcessSequencedUpload", ScopeKind: ScopeSession, ScopeID: upload.SessionID}
+	err = s.invoke(call, func() error {
+		ack, result, err = s.next.ProcessSequencedUpload(upload)
+		return err
+	})
+	return
+}
+
+// AckedUploadSeq implements TrackingAPI.
+func (s *middlewareAPI) AckedUploadSeq(sessionID string) (seq uint64) {
+	call := MethodCall{Method: "AckedUploadSeq", ScopeKind: ScopeSession, ScopeID: sessionID}
+	_ = s.invoke(call, func() error {
+		seq = s.next.AckedUploadSeq(sessionID)
+		return nil
+	})
+	return
+}
+
+// ObserveStreamFirstFrame implements TrackingAPI.
+func (s *middlewareAPI) ObserveStreamFirstFrame(sessionID string, wait time.Duration) {
+	call := MethodCall{Method: "ObserveStreamFirstFrame", ScopeKind: ScopeSession, ScopeID: sessionID}
+	_ = s.invoke(call, func() error {
+		s.next.ObserveStreamFirstFrame(sessionID, wait)
+		return nil
+	})
+}
+
+// IngestBeaconEvents implements TrackingAPI.
+func (s *middlewareAPI) IngestBeaconEvents(sessionID string, events []models.BeaconEvent) (result BeaconIngestResult, err error) {
+	call := MethodCall{Method: "IngestBeaconEvents", ScopeKind: ScopeSession, ScopeID: sessionID}
+	err = s.invoke(call, func() error {
+		result, err = s.next.IngestBeaconEvents(sessionID, events)
+		return err
+	})
+	return
+}
+
+// GetSessionStatisticsAsOf implements TrackingAPI.
+func (s *middlewareAPI) GetSessionStatisticsAsOf(sessionID string, asOf time.Time) (stats *models.TrackingStatistics, err error) {
+	call := MethodCall{Method: "GetSessionStatisticsAsOf", ScopeKind: ScopeSession, ScopeID: sessionID}
+	err = s.invoke(call, func() error {
+		stats, err = s.next.GetSessionStatisticsAsOf(sessionID, asOf)
+		return err
+	})
+	return
+}
+
+// GetSessionSparkline implements TrackingAPI.
+func (s *middlewareAPI) GetSessionSparkline(sessionID string) (sparkline *models.ActivitySparkline, err error) {
+	call := MethodCall{Method: "GetSessionSparkline", ScopeKind: ScopeSession, ScopeID: sessionID}
+	err = s.invoke(call, func() error {
+		sparkline, err = s.next.GetSessionSparkline(sessionID)
+		return err
+	})
+	return
+}
+
+// GetSessionTimeline implements TrackingAPI.
+func (s *middlewareAPI) GetSessionTimeline(sessionID string) (timeline *models.SessionTimeline, err error) {
+	call := MethodCall{Method: "GetSessionTimeline", ScopeKind: ScopeSession, ScopeID: sessionID}
+	err = s.invoke(call, func() error {
+		timeline, err = s.next.GetSessionTimeline(sessionID)
+		return err
+	})
+	return
+}
+
+// GetSessionDogProfile implements TrackingAPI.
+func (s *middlewareAPI) GetSessionDogProfile(sessionID string) (profile *models.DogProfile, err error) {
+	call := MethodCall{Method: "GetSessionDogProfile", ScopeKind: ScopeSession, ScopeID: sessionID}
+	err = s.invoke(call, func() error {
+		profile, err = s.next.GetSessionDogProfile(sessionID)
+		return err
+	})
+	return
+}
+
+// ReviewSessionBreaches implements TrackingAPI.
+func (s *middlewareAPI) ReviewSessionBreaches(sessionID string) (review *models.BreachReview, err error) {
+	call := MethodCall{Method: "ReviewSessionBreaches", ScopeKind: ScopeSession, ScopeID: sessionID}
+	err = s.invoke(call, func() error {
+		review, err = s.next.ReviewSessionBreaches(sessionID)
+		return err
+	})
+	return
+}
+
+// GetLocationHistoryPage implements TrackingAPI.
+func (s *middlewareAPI) GetLocationHistoryPage(sessionID string, after models.PageCursor, limit int, filter models.HistoryFilter) (locations []models.Location, next *models.PageCursor, err error) {
+	call := MethodCall{Method: "GetLocationHistoryPage", ScopeKind: ScopeSession, ScopeID: sessionID}
+	err = s.invoke(call, func() error {
+		locations, next, err = s.next.GetLocationHistoryPage(sessionID, after, limit, filter)
+		return err
+	})
+	return
+}
+
+// GetRawSessionEventsPage implements TrackingAPI.
+func (s *middlewareAPI) GetRawSessionEventsPage(sessionID string, after models.PageCursor, limit int) (events []models.SessionStateEvent, next *models.PageCursor, err error) {
+	call := MethodCall{Method: "GetRawSessionEventsPage", ScopeKind: ScopeSession, ScopeID: sessionID}
+	err = s.invoke(call, func() error {
+		events, next, err = s.next.GetRawSessionEventsPage(sessionID, after, limit)
+		return err
+	})
+	return
+}
+
+// ExportWalkGPX implements TrackingAPI.
+func (s *middlewareAPI) ExportWalkGPX(sessionID string) (gpx []byte, err error) {
+	call := MethodCall{Method: "ExportWalkGPX", ScopeKind: ScopeSession, ScopeID: sessionID}
+	err = s.invoke(call, func() error {
+		gpx, err = s.next.ExportWalkGPX(sessionID)
+		return err
+	})
+	return
+}
+
+// ExportSessionFIT implements TrackingAPI.
+func (s *middlewareAPI) ExportSessionFIT(sessionID string) (fit []byte, err error) {
+	call := MethodCall{Method: "ExportSessionFIT", ScopeKind: ScopeSession, ScopeID: sessionID}
+	err = s.invoke(call, func() error {
+		fit, err = s.next.ExportSessionFIT(sessionID)
+		return err
+	})
+	return
+}
+
+// LoadWalkReplay implements TrackingAPI.
+func (s *middlewareAPI) LoadWalkReplay(sessionID string, speed int) (replay *WalkReplay, err error) {
+	call := MethodCall{Method: "LoadWalkReplay", ScopeKind: ScopeSession, ScopeID: sessionID}
+	err = s.invoke(call, func() error {
+		replay, err = s.next.LoadWalkReplay(sessionID, speed)
+		return err
+	})
+	return
+}
+
+// ShareSession implements TrackingAPI.
+func (s *middlewareAPI) ShareSession(sessionID, ownerID, subscriberID string) (key StreamKey, err error) {
+	call := MethodCall{Method: "ShareSession", ScopeKind: ScopeSession, ScopeID: sessionID}
+	err = s.invoke(call, func() error {
+		key, err = s.next.ShareSession(sessionID, ownerID, subscriberID)
+		return err
+	})
+	return
+}
+
+// UnshareSession implements TrackingAPI.
+func (s *middlewareAPI) UnshareSession(sessionID, requesterID, subscriberID string) error {
+	call := MethodCall{Method: "UnshareSession", ScopeKind: ScopeSession, ScopeID: sessionID}
+	return s.invoke(call, func() error {
+		return s.next.UnshareSession(sessionID, requesterID, subscriberID)
+	})
+}
+
+// GetStreamKey implements TrackingAPI.
+func (s *middlewareAPI) GetStreamKey(sessionID, subscriberID string) (key StreamKey, err error) {
+	call := MethodCall{Method: "GetStreamKey", ScopeKind: ScopeSession, ScopeID: sessionID}
+	err = s.invoke(call, func() error {
+		key, err = s.next.GetStreamKey(sessionID, subscriberID)
+		return err
+	})
+	return
+}
+
+// SealStreamFrame implements TrackingAPI.
+func (s *middlewareAPI) SealStreamFrame(sessionID string, payload []byte) (sealed *SealedFrame, err error) {
+	call := MethodCall{Method: "SealStreamFrame", ScopeKind: ScopeSession, ScopeID: sessionID}
+	err = s.invoke(call, func() error {
+		sealed, err = s.next.SealStreamFrame(sessionID, payload)
+		return err
+	})
+	return
+}
+
+// AccessEscrowedKeys implements TrackingAPI.
+func (s *middlewareAPI) AccessEscrowedKeys(sessionID string, req models.EscrowAccessRequest) (result *EscrowAccessResult, err error) {
+	call := MethodCall{Method: "AccessEscrowedKeys", ScopeKind: ScopeSession, ScopeID: sessionID}
+	err = s.invoke(call, func() error {
+		result, err = s.next.AccessEscrowedKeys(sessionID, req)
+		return err
+	})
+	return
+}
+
+// GetTerritoryCoverage implements TrackingAPI.
+func (s *middlewareAPI) GetTerritoryCoverage(walkID string) (coverage *models.TerritoryCoverage, err error) {
+	call := MethodCall{Method: "GetTerritoryCoverage", ScopeKind: ScopeWalk, ScopeID: walkID}
+	err = s.invoke(call, func() error {
+		coverage, err = s.next.GetTerritoryCoverage(walkID)
+		return err
+	})
+	return
+}
+
+// CreateGeofence implements TrackingAPI.
+func (s *middlewareAPI) CreateGeofence(walkID string, spec models.GeofenceRecord) (geofence *Geofence, err error) {
+	call := MethodCall{Method: "CreateGeofence", ScopeKind: ScopeWalk, ScopeID: walkID}
+	err = s.invoke(call, func() error {
+		geofence, err = s.next.CreateGeofence(walkID, spec)
+		return err
+	})
+	return
+}
+
+// LoadGeofences implements TrackingAPI.
+func (s *middlewareAPI) LoadGeofences(walkID string) (geofences []*Geofence, err error) {
+	call := MethodCall{Method: "LoadGeofences", ScopeKind: ScopeWalk, ScopeID: walkID}
+	err = s.invoke(call, func() error {
+		geofences, err = s.next.LoadGeofences(walkID)
+		return err
+	})
+	return
+}
+
+// PairDevice implements TrackingAPI.
+func (s *middlewareAPI) PairDevice(walkerID string, pairing *models.DevicePairing) (device *models.Device, err error) {
+	call := MethodCall{Method: "PairDevice", ScopeKind: ScopeWalker, ScopeID: walkerID}
+	err = s.invoke(call, func() error {
+		device, err = s.next.PairDevice(walkerID, pairing)
+		return err
+	})
+	return
+}
+
+// GetWalkerDevices implements TrackingAPI.
+func (s *middlewareAPI) GetWalkerDevices(walkerID string) (devices []models.Device, err error) {
+	call := MethodCall{Method: "GetWalkerDevices", ScopeKind: ScopeWalker, ScopeID: walkerID}
+	err = s.invoke(call, func() error {
+		devices, err = s.next.GetWalkerDevices(walkerID)
+		return err
+	})
+	return
+}
+
+// RevokeDevice implements TrackingAPI.
+func (s *middlewareAPI) RevokeDevice(walkerID, deviceID string) error {
+	call := MethodCall{Method: "RevokeDevice", ScopeKind: ScopeWalker, ScopeID: walkerID}
+	return s.invoke(call, func() error {
+		return s.next.RevokeDevice(walkerID, deviceID)
+	})
+}
+
+// GetWalkerPresence implements TrackingAPI.
+func (s *middlewareAPI) GetWalkerPresence() (presence []WalkerPresence, err error) {
+	call := MethodCall{Method: "GetWalkerPresence"}
+	err = s.invoke(call, func() error {
+		presence, err = s.next.GetWalkerPresence()
+		return err
+	})
+	return
+}
+
+// SaveFitnessToken implements TrackingAPI.
+func (s *middlewareAPI) SaveFitnessToken(token *models.FitnessToken) error {
+	call := MethodCall{Method: "SaveFitnessToken"}
+	return s.invoke(call, func() error {
+		return s.next.SaveFitnessToken(token)
+	})
+}
+
+// GetOwnerAlertPreference implements TrackingAPI.
+func (s *middlewareAPI) GetOwnerAlertPreference(ownerID, dogID string) (pref *models.OwnerAlertPreference, err error) {
+	call := MethodCall{Method: "GetOwnerAlertPreference", ScopeKind: ScopeOwner, ScopeID: ownerID}
+	err = s.invoke(call, func() error {
+		pref, err = s.next.GetOwnerAlertPreference(ownerID, dogID)
+		return err
+	})
+	return
+}
+
+// PutOwnerAlertPreference implements TrackingAPI.
+func (s *middlewareAPI) PutOwnerAlertPreference(pref *models.OwnerAlertPreference) error {
+	call := MethodCall{Method: "PutOwnerAlertPreference"}
+	return s.invoke(call, func() error {
+		return s.next.PutOwnerAlertPreference(pref)
+	})
+}
+
+// DeleteOwnerAlertPreference implements TrackingAPI.
+func (s *middlewareAPI) DeleteOwnerAlertPreference(ownerID, dogID string) error {
+	call := MethodCall{Method: "DeleteOwnerAlertPreference", ScopeKind: ScopeOwner, ScopeID: ownerID}
+	return s.invoke(call, func() error {
+		return s.next.DeleteOwnerAlertPreference(ownerID, dogID)
+	})
+}
+
+// GetPopularRoutes implements TrackingAPI.
+func (s *middlewareAPI) GetPopularRoutes(bbox models.BoundingBox, limit int) (segments []models.PopularRouteSegment, err error) {
+	call := MethodCall{Method: "GetPopularRoutes"}
+	err = s.invoke(call, func() error {
+		segments, err = s.next.GetPopularRoutes(bbox, limit)
+		return err
+	})
+	return
+}
+
+// GetDemandHeatmap implements TrackingAPI.
+func (s *middlewareAPI) GetDemandHeatmap(bbox models.BoundingBox, tz string, days int) (heatmap *models.DemandHeatmap, err error) {
+	call := MethodCall{Method: "GetDemandHeatmap"}
+	err = s.invoke(call, func() error {
+		heatmap, err = s.next.GetDemandHeatmap(bbox, tz, days)
+		return err
+	})
+	return
+}
+
+// GetLeaderboard implements TrackingAPI.
+func (s *middlewareAPI) GetLeaderboard(tenantID, period, rankBy string, limit int) (leaderboard *models.Leaderboard, err error) {
+	call := MethodCall{Method: "GetLeaderboard", ScopeKind: ScopeTenant, ScopeID: tenantID}
+	err = s.invoke(call, func() error {
+		leaderboard, err = s.next.GetLeaderboard(tenantID, period, rankBy, limit)
+		return err
+	})
+	return
+}
+
+// GetCurrentWalksPage implements TrackingAPI.
+func (s *middlewareAPI) GetCurrentWalksPage(tenantID string, after models.PageCursor, limit int) (walks []models.CurrentWalk, next *models.PageCursor, err error) {
+	call := MethodCall{Method: "GetCurrentWalksPage"}
+	err = s.invoke(call, func() error {
+		walks, next, err = s.next.GetCurrentWalksPage(tenantID, after, limit)
+		return err
+	})
+	return
+}
+
+// GetCurrentWalkChanges implements TrackingAPI.
+func (s *middlewareAPI) GetCurrentWalkChanges(afterSeq int64, limit int) (feed *models.CurrentWalkFeed, err error) {
+	call := MethodCall{Method: "GetCurrentWalkChanges"}
+	err = s.invoke(call, func() error {
+		feed, err = s.next.GetCurrentWalkChanges(afterSeq, limit)
+		return err
+	})
+	return
+}
+
+// QueryLocations implements TrackingAPI.
+func (s *middlewareAPI) QueryLocations(q models.LocationQuery) (result *models.LocationQueryResult, err error) {
+	call := MethodCall{Method: "QueryLocations"}
+	err = s.invoke(call, func() error {
+		result, err = s.next.QueryLocations(q)
+		return err
+	})
+	return
+}
+
+// GetSLOStatus implements TrackingAPI.
+func (s *middlewareAPI) GetSLOStatus() (status SLOStatus, err error) {
+	call := MethodCall{Method: "GetSLOStatus"}
+	err = s.invoke(call, func() error {
+		status, err = s.next.GetSLOStatus()
+		return err
+	})
+	return
+}
+
+// GetIntegrityReport implements TrackingAPI.
+func (s *middlewareAPI) GetIntegrityReport() (report *models.IntegrityReport, err error) {
+	call := MethodCall{Method: "GetIntegrityReport"}
+	err = s.invoke(call, func() error {
+		report, err = s.next.GetIntegrityReport()
+		return err
+	})
+	return
+}
+
+// GetRegionProfiles implements TrackingAPI.
+func (s *middlewareAPI) GetRegionProfiles() (profiles []models.RegionProfile, err error) {
+	call := MethodCall{Method: "GetRegionProfiles"}
+	err = s.invoke(call, func() error {
+		profiles, err = s.next.GetRegionProfiles()
+		return err
+	})
+	return
+}
+
+// GetRegionProfile implements TrackingAPI.
+func (s *middlewareAPI) GetRegionProfile(region string) (profile *models.RegionProfile, err error) {
+	call := MethodCall{Method: "GetRegionProfile", ScopeKind: ScopeRegion, ScopeID: region}
+	err = s.invoke(call, func() error {
+		profile, err = s.next.GetRegionProfile(region)
+		return err
+	})
+	return
+}
+
+// PutRegionProfile implements TrackingAPI.
+func (s *middlewareAPI) PutRegionProfile(profile *models.RegionProfile) error {
+	call := MethodCall{Method: "PutRegionProfile"}
+	return s.invoke(call, func() error {
+		return s.next.PutRegionProfile(profile)
+	})
+}
+
+// DeleteRegionProfile implements TrackingAPI.
+func (s *middlewareAPI) DeleteRegionProfile(region string) error {
+	call := MethodCall{Method: "DeleteRegionProfile", ScopeKind: ScopeRegion, ScopeID: region}
+	return s.invoke(call, func() error {
+		return s.next.DeleteRegionProfile(region)
+	})
+}
+
+// GetGeofenceGroups implements TrackingAPI.
+func (s *middlewareAPI) GetGeofenceGroups() (groups []models.GeofenceGroupRecord, err error) {
+	call := MethodCall{Method: "GetGeofenceGroups"}
+	err = s.invoke(call, func() error {
+		groups, err = s.next.GetGeofenceGroups()
+		return err
+	})
+	return
+}
+
+// PutGeofenceGroup implements TrackingAPI.
+func (s *middlewareAPI) PutGeofenceGroup(spec *models.GeofenceGroupRecord) (group *models.GeofenceGroupRecord, err error) {
+	call := MethodCall{Method: "PutGeofenceGroup"}
+	err = s.invoke(call, func() error {
+		group, err = s.next.PutGeofenceGroup(spec)
+		return err
+	})
+	return
+}
+
+// DeleteGeofenceGroup implements TrackingAPI.
+func (s *middlewareAPI) DeleteGeofenceGroup(name string) error {
+	call := MethodCall{Method: "DeleteGeofenceGroup"}
+	return s.invoke(call, func() error {
+		return s.next.DeleteGeofenceGroup(name)
+	})
+}
+
+// SetSessionBatchPolicy implements TrackingAPI.
+func (s *middlewareAPI) SetSessionBatchPolicy(sessionID string, policy BatchPolicy) error {
+	call := MethodCall{Method: "SetSessionBatchPolicy", ScopeKind: ScopeSession, ScopeID: sessionID}
+	return s.invoke(call, func() error {
+		return s.next.SetSessionBatchPolicy(sessionID, policy)
+	})
+}
+
+// RebuildSession implements TrackingAPI.
+func (s *middlewareAPI) RebuildSession(sessionID string) (session *models.TrackingSession, err error) {
+	call := MethodCall{Method: "RebuildSession", ScopeKind: ScopeSession, ScopeID: sessionID}
+	err = s.invoke(call, func() error {
+		session, err = s.next.RebuildSession(sessionID)
+		return err
+	})
+	return
+}
+
+// PreviewRetention implements TrackingAPI.
+func (s *middlewareAPI) PreviewRetention() (report *models.RetentionReport, err error) {
+	call := MethodCall{Method: "PreviewRetention"}
+	err = s.invoke(call, func() error {
+		report, err = s.next.PreviewRetention()
+		return err
+	})
+	return
+}
+
+// GetQuarantinedPoints implements TrackingAPI.
+func (s *middlewareAPI) GetQuarantinedPoints(query models.QuarantineQuery) (points []models.QuarantinedPoint, err error) {
+	call := MethodCall{Method: "GetQuarantinedPoints"}
+	err = s.invoke(call, func() error {
+		points, err = s.next.GetQuarantinedPoints(query)
+		return err
+	})
+	return
+}
+
+// ReingestQuarantined implements TrackingAPI.
+func (s *middlewareAPI) ReingestQuarantined(ids []string) (result *models.ReingestResult, err error) {
+	call := MethodCall{Method: "ReingestQuarantined"}
+	err = s.invoke(call, func() error {
+		result, err = s.next.ReingestQuarantined(ids)
+		return err
+	})
+	return
+}
+
+// GetEscrowAccessLog implements TrackingAPI.
+func (s *middlewareAPI) GetEscrowAccessLog(tenantID string, limit int) (records []models.EscrowAccessRecord, err error) {
+	call := MethodCall{Method: "GetEscrowAccessLog"}
+	err = s.invoke(call, func() error {
+		records, err = s.next.GetEscrowAccessLog(tenantID, limit)
+		return err
+	})
+	return
+}
+
+// RecordWalkAccess implements TrackingAPI.
+func (s *middlewareAPI) RecordWalkAccess(id, actor, endpoint, requestID string) {
+	call := MethodCall{Method: "RecordWalkAccess", ScopeKind: ScopeWalk, ScopeID: id}
+	_ = s.invoke(call, func() error {
+		s.next.RecordWalkAccess(id, actor, endpoint, requestID)
+		return nil
+	})
+}
+
+// GetWalkAccessLog implements TrackingAPI.
+func (s *middlewareAPI) GetWalkAccessLog(walkID string, limit int) (records []models.WalkAccessRecord, err error) {
+	call := MethodCall{Method: "GetWalkAccessLog", ScopeKind: ScopeWalk, ScopeID: walkID}
+	err = s.invoke(call, func() error {
+		records, err = s.next.GetWalkAccessLog(walkID, limit)
+		return err
+	})
+	return
+}
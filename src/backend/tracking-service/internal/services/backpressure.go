@@ -0,0 +1,193 @@
+package services
+
+import (
+	// errors for the watermark validation sentinel (go1.21)
+	"errors"
+	// sync for guarding the sampled queues and stopping the monitor once (go1.21)
+	"sync"
+	// time for the sampling interval (go1.21)
+	"time"
+
+	// prometheus for saturation and pause metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+)
+
+// ErrInvalidWatermarks is returned when the resume watermark is not below the
+// pause watermark, or either lies outside (0, 1].
+var ErrInvalidWatermarks = errors.New("backpressure watermarks must satisfy 0 < low < high <= 1")
+
+// SubscriptionPauser pauses and resumes consumption of message bus
+// subscriptions. It is implemented by the service's MQTT client.
+type SubscriptionPauser interface {
+	// PauseSubscription stops consuming topic until it is resumed.
+	PauseSubscription(topic string) error
+
+	// ResumeSubscription consumes topic again with its original handler.
+	ResumeSubscription(topic string) error
+}
+
+// backpressureQueue is one internal queue sampled for saturation.
+type backpressureQueue struct {
+	name     string
+	depth    func() int
+	capacity int
+}
+
+// BackpressureController protects the service from memory blowups while the
+// database is slow: it samples the fill of internal processing queues and,
+// once any of them passes the high watermark, pauses consumption of
+// low-priority topics, resuming them when every queue has fallen below the
+// low watermark. Messages published to a paused topic are not delivered, so
+// only topics whose messages can be lost, such as presence heartbeats, should
+// be paused.
+type BackpressureController struct {
+	pauser SubscriptionPauser
+	topics []string
+	high   float64
+	low    float64
+	logger *zap.Logger
+
+	mu     sync.Mutex
+	queues []backpressureQueue
+	paused bool
+
+	saturation  *prometheus.GaugeVec
+	pausedGauge prometheus.Gauge
+	transitions *prometheus.CounterVec
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+// NewBackpressureController creates a controller pausing topics through
+// pauser between the given watermarks, fractions of queue capacity, and
+// registers its metrics on registry when non-nil.
+func NewBackpressureController(pauser SubscriptionPauser, topics []string, high, low float64, logger *zap.Logger, registry *prometheus.Registry) (*BackpressureController, error) {
+	if low <= 0 || low >= high || high > 1 {
+		return nil, ErrInvalidWatermarks
+	}
+	c := &BackpressureController{
+		pauser: pauser,
+		topics: append([]string(nil), topics...),
+		high:   high,
+		low:    low,
+		logger: logger,
+		saturation: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "tracking_backpressure_queue_saturation",
+			Help: "Fill of internal processing queues as a fraction of their capacity, by queue",
+		}, []string{"queue"}),
+		pausedGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "tracking_backpressure_paused",
+			Help: "1 while low-priority subscriptions are paused for backpressure, 0 otherwise",
+		}),
+		transitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tracking_backpressure_transitions_total",
+			Help: "Pauses and resumptions of low-priority subscriptions, by action",
+		}, []string{"action"}),
+		stop: make(chan struct{}),
+	}
+	if registry != nil {
+		registry.MustRegister(c.saturation, c.pausedGauge, c.transitions)
+	}
+	return c, nil
+}
+
+// AddQueue samples a queue holding up to capacity items, whose current depth
+// is reported by depth. Queues with a non-positive capacity are ignored.
+func (c *BackpressureController) AddQueue(name string, depth func() int, capacity int) {
+	if capacity <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.queues = append(c.queues, backpressureQueue{name: name, depth: depth, capacity: capacity})
+}
+
+// Paused reports whether low-priority subscriptions are currently paused.
+func (c *BackpressureController) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+// Start samples the queues every interval in the background.
+func (c *BackpressureController) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				c.Check()
+			}
+		}
+	}()
+}
+
+// Stop ends sampling and resumes any paused subscriptions.
+func (c *BackpressureController) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.paused {
+			c.resumeLocked(0)
+		}
+	})
+}
+
+// Check samples the queues once, pausing or resuming the low-priority
+// subscriptions when a watermark is crossed.
+func (c *BackpressureController) Check() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	worst, worstQueue := 0.0, ""
+	for _, q := range c.queues {
+		fill := float64(q.depth()) / float64(q.capacity)
+		c.saturation.WithLabelValues(q.name).Set(fill)
+		if fill > worst {
+			worst, worstQueue = fill, q.name
+		}
+	}
+
+	switch {
+	case !c.paused && worst >= c.high:
+		c.paused = true
+		c.pausedGauge.Set(1)
+		c.transitions.WithLabelValues("pause").Inc()
+		c.logger.Warn("Internal queues saturated; pausing low-priority subscriptions",
+			zap.String("queue", worstQueue),
+			zap.Float64("saturation", worst),
+			zap.Strings("topics", c.topics),
+		)
+		for _, topic := range c.topics {
+			if err := c.pauser.PauseSubscription(topic); err != nil {
+				c.logger.Error("Failed to pause subscription", zap.String("topic", topic), zap.Error(err))
+			}
+		}
+	case c.paused && worst <= c.low:
+		c.resumeLocked(worst)
+	}
+}
+
+// resumeLocked resumes the paused subscriptions. c.mu must be held.
+func (c *BackpressureController) resumeLocked(saturation float64) {
+	c.paused = false
+	c.pausedGauge.Set(0)
+	c.transitions.WithLabelValues("resume").Inc()
+	c.logger.Info("Internal queue pressure subsided; resuming low-priority subscriptions",
+		zap.Float64("saturation", saturation),
+		zap.Strings("topics", c.topics),
+	)
+	for _, topic := range c.topics {
+		if err := c.pauser.ResumeSubscription(topic); err != nil {
+			c.logger.Error("Failed to resume subscription", zap.String("topic", topic), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	// errors for the beacon sentinels (go1.21)
+	"errors"
+	// fmt for wrapping validation and repository errors (go1.21)
+	"fmt"
+	// sort for ordering sightings before they reach the session (go1.21)
+	"sort"
+
+	// models package that includes the BeaconEvent and SessionTimeline structs
+	"src/backend/tracking-service/internal/models"
+)
+
+var (
+	// ErrBeaconsDisabled is returned by beacon ingestion and session timelines
+	// when no beacon store is configured.
+	ErrBeaconsDisabled = errors.New("beacon ingestion is not enabled")
+
+	// ErrInvalidBeaconEvent wraps the validation failures of a beacon sighting.
+	ErrInvalidBeaconEvent = errors.New("invalid beacon event")
+
+	// ErrBeaconSessionClosed is returned when sightings arrive for a session
+	// that is not active.
+	ErrBeaconSessionClosed = errors.New("beacon session is not active")
+)
+
+// BeaconStore persists beacon sightings in their own hypertable. It is
+// implemented by repository.TimescaleRepository.
+type BeaconStore interface {
+	// SaveBeaconEvents inserts a batch of sightings.
+	SaveBeaconEvents(events []models.BeaconEvent) error
+
+	// GetBeaconEvents returns a session's sightings in time order.
+	GetBeaconEvents(sessionID string) ([]models.BeaconEvent, error)
+}
+
+// BeaconIngestResult reports how many sightings of a batch were stored and
+// how many were ignored for a signal weaker than the configured minimum.
+type BeaconIngestResult struct {
+	SessionID string `json:"sessionId"`
+	Accepted  int    `json:"accepted"`
+	Ignored   int    `json:"ignored"`
+}
+
+// SetBeaconStore enables beacon ingestion. Sightings weaker than minRSSI dBm
+// are ignored, as the device is then unlikely to be inside the facility.
+// Passing nil disables it.
+func (ts *TrackingService) SetBeaconStore(store BeaconStore, minRSSI int) {
+	ts.beaconStore = store
+	ts.beaconMinRSSI = minRSSI
+}
+
+// IngestBeaconEvents stores a batch of beacon sightings for an active
+// session and records them on the session, so that indoor periods between
+// GPS fixes are not counted as tracking gaps.
+//
+// Steps:
+//  1. Check the session is active and validate every sighting
+//  2. Drop sightings weaker than the minimum RSSI
+//  3. Persist the rest, then apply them to the session in time order
+func (ts *TrackingService) IngestBeaconEvents(sessionID string, events []models.BeaconEvent) (BeaconIngestResult, error) {
+	result := BeaconIngestResult{SessionID: sessionID}
+	if ts.beaconStore == nil {
+		return result, ErrBeaconsDisabled
+	}
+	session, err := ts.getSession(sessionID)
+	if err != nil {
+		return result, fmt.Errorf("%w: %v", ErrBeaconSessionClosed, err)
+	}
+	if len(events) > MaxBatchSize {
+		return result, fmt.Errorf("%w: batch exceeds maximum size of %d", ErrInvalidBeaconEvent, MaxBatchSize)
+	}
+
+	accepted := make([]models.BeaconEvent, 0, len(events))
+	for i := range events {
+		ev := events[i]
+		if err := ev.Validate(); err != nil {
+			return result, fmt.Errorf("%w: event %d: %v", ErrInvalidBeaconEvent, i, err)
+		}
+		if ev.RSSI < ts.beaconMinRSSI {
+			result.Ignored++
+			continue
+		}
+		ev.SessionID = sessionID
+		ev.Timestamp = ev.Timestamp.UTC()
+		accepted = append(accepted, ev)
+	}
+	if len(accepted) == 0 {
+		return result, nil
+	}
+	sort.SliceStable(accepted, func(i, j int) bool {
+		return accepted[i].Timestamp.Before(accepted[j].Timestamp)
+	})
+
+	if err := ts.beaconStore.SaveBeaconEvents(accepted); err != nil {
+		return result, fmt.Errorf("failed to store beacon events for session %s: %w", sessionID, err)
+	}
+	for i := range accepted {
+		if err := session.AddBeaconEvent(&accepted[i]); err != nil {
+			return result, fmt.Errorf("%w: %v", ErrBeaconSessionClosed, err)
+		}
+	}
+	result.Accepted = len(accepted)
+	return result, nil
+}
+
+// GetSessionTimeline returns a session's GPS track merged with the indoor
+// periods of its beacon sightings, marking as gaps only the stretches in
+// which the walker was seen by neither.
+func (ts *TrackingService) GetSessionTimeline(sessionID string) (*models.SessionTimeline, error) {
+	if ts.beaconStore == nil {
+		return nil, ErrBeaconsDisabled
+	}
+	session, err := ts.resolveSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	track, err := ts.fullLocationHistory(session)
+	if err != nil {
+		return nil, err
+	}
+	events, err := ts.sessionBeaconEvents(session.ID)
+	if err != nil {
+		return nil, err
+	}
+	return models.BuildSessionTimeline(session.ID, session.WalkID(), track, events), nil
+}
+
+// sessionBeaconEvents returns the session's stored sightings, or none when
+// beacon ingestion is disabled.
+func (ts *TrackingService) sessionBeaconEvents(sessionID string) ([]models.BeaconEvent, error) {
+	if ts.beaconStore == nil {
+		return nil, nil
+	}
+	events, err := ts.beaconStore.GetBeaconEvents(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load beacon events for session %s: %w", sessionID, err)
+	}
+	return events, nil
+}
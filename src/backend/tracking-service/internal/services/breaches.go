@@ -0,0 +1,171 @@
+package services
+
+import (
+	// sort for ordering the track, breaches and breach intervals (go1.21)
+	"sort"
+	// time for breach durations (go1.21)
+	"time"
+
+	// models package that includes the GeofenceBreach struct
+	"src/backend/tracking-service/internal/models"
+)
+
+// ReviewSessionBreaches reconstructs every breach of the geofences of a
+// session's walk from its stored track, for incident reviews: the points
+// immediately before and after each breach, how far past the boundary each
+// point in violation lay, and the total time spent in breach.
+//
+// Steps:
+//  1. Resolve the session, rebuilding a finished one from its event stream
+//  2. Load the full track, falling back to persisted history if truncated
+//  3. Load the walk's geofences and find the breaches of each
+//  4. Order the breaches and total the time spent in any of them
+func (ts *TrackingService) ReviewSessionBreaches(sessionID string) (*models.BreachReview, error) {
+	if ts.geofenceStore == nil {
+		return nil, ErrGeofencesDisabled
+	}
+	session, err := ts.resolveSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := ts.fullLocationHistory(session)
+	if err != nil {
+		return nil, err
+	}
+	track := make([]models.Location, len(history))
+	copy(track, history)
+	sort.SliceStable(track, func(i, j int) bool { return track[i].Timestamp.Before(track[j].Timestamp) })
+
+	geofences, err := ts.LoadGeofences(session.WalkID())
+	if err != nil {
+		return nil, err
+	}
+	review := &models.BreachReview{
+		SessionID: sessionID,
+		WalkID:    session.WalkID(),
+		Breaches:  []models.GeofenceBreach{},
+	}
+	for _, g := range geofences {
+		review.Breaches = append(review.Breaches, g.breachesAlong(track)...)
+	}
+	sort.SliceStable(review.Breaches, func(i, j int) bool {
+		return review.Breaches[i].StartedAt.Before(review.Breaches[j].StartedAt)
+	})
+	review.TotalOutsideSeconds = breachUnion(review.Breaches).Seconds()
+	return review, nil
+}
+
+// breachesAlong returns the continuous violations of g along a time-ordered
+// track. Only points from the geofence's creation, until its deactivation when
+// it is no longer active, and within its schedule are checked, so a walk is
+// not held to a zone that did not apply at the time. Unlike ContainsPoint, it
+// does not count violations.
+func (g *Geofence) breachesAlong(track []models.Location) []models.GeofenceBreach {
+	var breaches []models.GeofenceBreach
+	var current *models.GeofenceBreach
+	for i := range track {
+		point := track[i]
+		distance, violated := g.breachDistance(&point)
+		if !violated {
+			if current != nil {
+				current.After = &point
+				current.EndedAt = point.Timestamp
+				breaches = append(breaches, closeBreach(current))
+				current = nil
+			}
+			continue
+		}
+
+		if current == nil {
+			violation := g.Violation(&point)
+			current = &models.GeofenceBreach{
+				GeofenceID: g.ID,
+				EventType:  violation.EventType,
+				Mode:       violation.Mode,
+				Severity:   violation.Severity,
+				StartedAt:  point.Timestamp,
+			}
+			if i > 0 {
+				before := track[i-1]
+				current.Before = &before
+			}
+		}
+		current.Samples = append(current.Samples, models.BreachSample{
+			Timestamp:      point.Timestamp,
+			Latitude:       point.Latitude,
+			Longitude:      point.Longitude,
+			DistanceMeters: distance,
+		})
+		if distance > current.MaxDistanceMeters {
+			current.MaxDistanceMeters = distance
+		}
+		current.EndedAt = point.Timestamp
+	}
+	if current != nil {
+		breaches = append(breaches, closeBreach(current))
+	}
+	return breaches
+}
+
+// closeBreach sets the duration of a breach whose end is known.
+func closeBreach(breach *models.GeofenceBreach) models.GeofenceBreach {
+	breach.DurationSeconds = breach.EndedAt.Sub(breach.StartedAt).Seconds()
+	return *breach
+}
+
+// breachDistance reports whether point violates g while it applies, and if so
+// how far past the boundary it lies, in meters.
+func (g *Geofence) breachDistance(point *models.Location) (float64, bool) {
+	if !g.appliesAt(point.Timestamp) {
+		return 0, false
+	}
+
+	var inside bool
+	var distance float64
+	if g.IsPolygon() {
+		inside = models.PolygonContains(g.Vertices, point.Latitude, point.Longitude)
+		distance = models.PolygonBoundaryDistance(g.Vertices, point.Latitude, point.Longitude)
+	} else {
+		fromCenter := models.HaversineDistance{}.Distance(g.CenterLatitude, g.CenterLongitude, point.Latitude, point.Longitude)
+		radius := g.RadiusKm * 1000
+		inside = fromCenter <= radius
+		distance = fromCenter - radius
+		if inside {
+			distance = -distance
+		}
+	}
+	if !g.violatedBy(inside) {
+		return 0, false
+	}
+	return distance, true
+}
+
+// appliesAt reports whether g applies at t: after its creation, before its
+// deactivation when it is no longer active, and within its schedule.
+func (g *Geofence) appliesAt(t time.Time) bool {
+	return !t.Before(g.CreatedAt) && (g.Active || !t.After(g.UpdatedAt)) && g.EnforcedAt(t)
+}
+
+// breachUnion returns the time covered by at least one breach.
+func breachUnion(breaches []models.GeofenceBreach) time.Duration {
+	intervals := make([][2]time.Time, 0, len(breaches))
+	for _, b := range breaches {
+		intervals = append(intervals, [2]time.Time{b.StartedAt, b.EndedAt})
+	}
+	sort.Slice(intervals, func(i, j int) bool { return intervals[i][0].Before(intervals[j][0]) })
+
+	var total time.Duration
+	var start, end time.Time
+	for i, iv := range intervals {
+		if i > 0 && !iv[0].After(end) {
+			if iv[1].After(end) {
+				end = iv[1]
+			}
+			continue
+		}
+		total += end.Sub(start)
+		start, end = iv[0], iv[1]
+	}
+	return total + end.Sub(start)
+}
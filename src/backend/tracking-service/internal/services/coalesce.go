@@ -0,0 +1,425 @@
+package services
+
+import (
+	// errors for sentinel coalescing errors (standard library)
+	"errors"
+	// fmt for formatting error messages (standard library)
+	"fmt"
+	// sync for guarding per-session buffers (standard library)
+	"sync"
+	// time for flush windows and arrival-rate tracking (standard library)
+	"time"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+	// prometheus for batch size and latency metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+
+	// models package that includes the Location struct
+	"src/backend/tracking-service/internal/models"
+)
+
+// Flush reasons recorded by the coalescing writer's metrics.
+const (
+	flushReasonSize    = "size"
+	flushReasonWindow  = "window"
+	flushReasonSession = "session"
+	flushReasonClose   = "close"
+	flushReasonDrain   = "drain"
+)
+
+// arrivalRateSmoothing is the EWMA weight given to the newest inter-arrival gap.
+const arrivalRateSmoothing = 0.3
+
+var (
+	// ErrCoalescingDisabled is returned by batch policy operations when location
+	// writes are not routed through a CoalescingWriter.
+	ErrCoalescingDisabled = errors.New("location write coalescing is not enabled")
+
+	// ErrInvalidBatchPolicy wraps the validation failures of a batch policy.
+	ErrInvalidBatchPolicy = errors.New("invalid batch policy")
+
+	// ErrBatchSessionInactive is returned when a batch policy is set for a
+	// session that is not active on this instance.
+	ErrBatchSessionInactive = errors.New("session is not active")
+)
+
+// BatchPolicy controls when a CoalescingWriter flushes a session's buffered
+// locations: as soon as MaxPoints have accumulated, or once the flush window has
+// elapsed since the first buffered point. The window is MaxWindow while points
+// arrive fast enough to fill a batch within it, and shrinks towards MinWindow as
+// the arrival rate drops, so slow sessions are not held back waiting for points
+// that will not come soon.
+type BatchPolicy struct {
+	MaxPoints int
+	MinWindow time.Duration
+	MaxWindow time.Duration
+}
+
+// Validate checks that the policy describes a usable flush window.
+func (p BatchPolicy) Validate() error {
+	if p.MaxPoints < 1 {
+		return fmt.Errorf("batch max points %d is invalid; must be at least 1", p.MaxPoints)
+	}
+	if p.MinWindow <= 0 || p.MaxWindow < p.MinWindow {
+		return fmt.Errorf("batch window [%s, %s] is invalid; min must be positive and no greater than max", p.MinWindow, p.MaxWindow)
+	}
+	return nil
+}
+
+// window returns the flush window for a session whose points arrive on average
+// every interval. A zero interval means the rate is not known yet, and the
+// shortest window is used.
+func (p BatchPolicy) window(interval time.Duration) time.Duration {
+	if interval <= 0 {
+		return p.MinWindow
+	}
+	fill := interval * time.Duration(p.MaxPoints)
+	if fill <= p.MaxWindow {
+		return p.MaxWindow
+	}
+	// Shrink in inverse proportion to how much longer the batch takes to fill
+	// than the longest window allows.
+	w := time.Duration(float64(p.MaxWindow) * float64(p.MaxWindow) / float64(fill))
+	if w < p.MinWindow {
+		return p.MinWindow
+	}
+	return w
+}
+
+// sessionBatch is one session's pending locations and arrival statistics.
+type sessionBatch struct {
+	points      []*models.Location
+	firstQueued time.Time
+	lastArrival time.Time
+	interval    time.Duration
+	timer       *time.Timer
+	policy      *BatchPolicy
+	waiters     []func(error)
+}
+
+// CoalescingWriter is a TimescaleDB decorator that buffers location writes per
+// session and stores them in larger batches, trading a bounded delay for far
+// fewer round trips. StoreLocationBatch returns once points are buffered;
+// failures of deferred flushes are logged and counted rather than returned.
+type CoalescingWriter struct {
+	TimescaleDB
+
+	policy BatchPolicy
+	logger *zap.Logger
+
+	mu       sync.Mutex
+	sessions map[string]*sessionBatch
+	closed   bool
+
+	// writing counts the points of flushes still being written.
+	writing int
+
+	batchSize      *prometheus.HistogramVec
+	flushLatency   *prometheus.HistogramVec
+	flushFailures  prometheus.Counter
+	windowDuration prometheus.Histogram
+}
+
+// NewCoalescingWriter wraps db with per-session write coalescing using policy as
+// the global default. Metrics are registered on registry when it is non-nil.
+func NewCoalescingWriter(db TimescaleDB, policy BatchPolicy, logger *zap.Logger, registry *prometheus.Registry) (*CoalescingWriter, error) {
+	if db == nil {
+		return nil, fmt.Errorf("write coalescing requires a database")
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	cw := &CoalescingWriter{
+		TimescaleDB: db,
+		policy:      policy,
+		logger:      logger,
+		sessions:    make(map[string]*sessionBatch),
+		batchSize: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "tracking_location_batch_size",
+				Help:    "Number of locations written per coalesced batch, by flush reason.",
+				Buckets: prometheus.ExponentialBuckets(1, 2, 9),
+			},
+			[]string{"reason"},
+		),
+		flushLatency: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "tracking_location_batch_delay_seconds",
+				Help:    "Time the oldest location of a batch spent buffered before being written, by flush reason.",
+				Buckets: prometheus.ExponentialBuckets(0.005, 2, 10),
+			},
+			[]string{"reason"},
+		),
+		flushFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tracking_location_batch_flush_failures_total",
+			Help: "Coalesced location batches that failed to be written.",
+		}),
+		windowDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "tracking_location_batch_window_seconds",
+			Help:    "Adaptive flush window chosen when a session starts a new batch.",
+			Buckets: prometheus.ExponentialBuckets(0.005, 2, 10),
+		}),
+	}
+	if registry != nil {
+		registry.MustRegister(cw.batchSize, cw.flushLatency, cw.flushFailures, cw.windowDuration)
+	}
+	return cw, nil
+}
+
+// SetSessionPolicy overrides the global batch policy for one session until
+// FlushSession is called for it.
+func (cw *CoalescingWriter) SetSessionPolicy(sessionID string, policy BatchPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	cw.batchLocked(sessionID).policy = &policy
+	return nil
+}
+
+// StoreLocationBatch buffers locBatch for the session and flushes when the
+// session's policy says so. Batches at least MaxPoints long are written
+// immediately, after any points already buffered for the session.
+func (cw *CoalescingWriter) StoreLocationBatch(sessionID string, locBatch []*models.Location) error {
+	return cw.enqueue(sessionID, locBatch, nil)
+}
+
+// StoreLocationBatchAsync buffers locBatch like StoreLocationBatch and calls done
+// with the result once the batch containing the points has been written, so
+// callers can tell when the points are durable.
+func (cw *CoalescingWriter) StoreLocationBatchAsync(sessionID string, locBatch []*models.Location, done func(error)) {
+	if err := cw.enqueue(sessionID, locBatch, done); err != nil {
+		cw.logger.Error("Failed to flush coalesced locations",
+			zap.String("sessionID", sessionID),
+			zap.Error(err),
+		)
+	}
+}
+
+// enqueue appends points to the session's batch, registering done to be called
+// when they are written.
+//
+// Steps:
+//  1. Update the session's arrival-rate estimate
+//  2. Append the points, starting a flush timer for the first buffered point
+//  3. Flush synchronously once MaxPoints have accumulated
+func (cw *CoalescingWriter) enqueue(sessionID string, locBatch []*models.Location, done func(error)) error {
+	if len(locBatch) == 0 {
+		if done != nil {
+			done(nil)
+		}
+		return nil
+	}
+
+	cw.mu.Lock()
+	if cw.closed {
+		cw.mu.Unlock()
+		err := cw.TimescaleDB.StoreLocationBatch(sessionID, locBatch)
+		if done != nil {
+			done(err)
+		}
+		return err
+	}
+
+	now := time.Now()
+	batch := cw.batchLocked(sessionID)
+	policy := cw.policy
+	if batch.policy != nil {
+		policy = *batch.policy
+	}
+
+	if !batch.lastArrival.IsZero() {
+		gap := now.Sub(batch.lastArrival) / time.Duration(len(locBatch))
+		if batch.interval == 0 {
+			batch.interval = gap
+		} else {
+			batch.interval = time.Duration(arrivalRateSmoothing*float64(gap) + (1-arrivalRateSmoothing)*float64(batch.interval))
+		}
+	}
+	batch.lastArrival = now
+
+	if len(batch.points) == 0 {
+		batch.firstQueued = now
+		window := policy.window(batch.interval)
+		cw.windowDuration.Observe(window.Seconds())
+		batch.timer = time.AfterFunc(window, func() {
+			if err := cw.flush(sessionID, flushReasonWindow); err != nil {
+				cw.logger.Error("Failed to flush coalesced locations",
+					zap.String("sessionID", sessionID),
+					zap.Error(err),
+				)
+			}
+		})
+	}
+	batch.points = append(batch.points, locBatch...)
+	if done != nil {
+		batch.waiters = append(batch.waiters, done)
+	}
+	full := len(batch.points) >= policy.MaxPoints
+	cw.mu.Unlock()
+
+	if full {
+		return cw.flush(sessionID, flushReasonSize)
+	}
+	return nil
+}
+
+// FlushSession writes any locations buffered for the session and drops its
+// batch state, including a per-session policy. Call it when a session ends.
+func (cw *CoalescingWriter) FlushSession(sessionID string) error {
+	err := cw.flush(sessionID, flushReasonSession)
+	cw.mu.Lock()
+	if batch, ok := cw.sessions[sessionID]; ok && len(batch.points) == 0 {
+		delete(cw.sessions, sessionID)
+	}
+	cw.mu.Unlock()
+	return err
+}
+
+// Flush writes every session's buffered locations without closing the
+// buffer, returning the first failure. It is used while draining for
+// shutdown, before the database is closed.
+func (cw *CoalescingWriter) Flush() error {
+	return cw.flushAll(flushReasonDrain)
+}
+
+// Close flushes every session's buffered locations, then closes the wrapped
+// database. Writes arriving after Close bypass the buffer.
+func (cw *CoalescingWriter) Close() error {
+	cw.mu.Lock()
+	cw.closed = true
+	cw.mu.Unlock()
+
+	flushErr := cw.flushAll(flushReasonClose)
+	if err := cw.TimescaleDB.Close(); err != nil {
+		return err
+	}
+	return flushErr
+}
+
+// flushAll flushes every session with buffered locations, returning the first
+// failure.
+func (cw *CoalescingWriter) flushAll(reason string) error {
+	cw.mu.Lock()
+	sessionIDs := make([]string, 0, len(cw.sessions))
+	for id := range cw.sessions {
+		sessionIDs = append(sessionIDs, id)
+	}
+	cw.mu.Unlock()
+
+	var flushErr error
+	for _, id := range sessionIDs {
+		if err := cw.flush(id, reason); err != nil && flushErr == nil {
+			flushErr = err
+		}
+	}
+	return flushErr
+}
+
+// BufferedPoints returns the number of points buffered or being written, for
+// backpressure: it grows while the database is slow to accept flushes.
+func (cw *CoalescingWriter) BufferedPoints() int {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	n := cw.writing
+	for _, batch := range cw.sessions {
+		n += len(batch.points)
+	}
+	return n
+}
+
+// batchLocked returns the session's batch state, creating it if needed.
+// cw.mu must be held.
+func (cw *CoalescingWriter) batchLocked(sessionID string) *sessionBatch {
+	batch, ok := cw.sessions[sessionID]
+	if !ok {
+		batch = &sessionBatch{}
+		cw.sessions[sessionID] = batch
+	}
+	return batch
+}
+
+// flush takes the session's buffered points and writes them outside the lock.
+func (cw *CoalescingWriter) flush(sessionID, reason string) error {
+	cw.mu.Lock()
+	batch, ok := cw.sessions[sessionID]
+	if !ok || len(batch.points) == 0 {
+		cw.mu.Unlock()
+		return nil
+	}
+	points := batch.points
+	queued := batch.firstQueued
+	waiters := batch.waiters
+	batch.points = nil
+	batch.waiters = nil
+	if batch.timer != nil {
+		batch.timer.Stop()
+		batch.timer = nil
+	}
+	cw.writing += len(points)
+	cw.mu.Unlock()
+
+	cw.batchSize.WithLabelValues(reason).Observe(float64(len(points)))
+	cw.flushLatency.WithLabelValues(reason).Observe(time.Since(queued).Seconds())
+	err := cw.TimescaleDB.StoreLocationBatch(sessionID, points)
+	cw.mu.Lock()
+	cw.writing -= len(points)
+	cw.mu.Unlock()
+	for _, done := range waiters {
+		done(err)
+	}
+	if err != nil {
+		cw.flushFailures.Inc()
+		return fmt.Errorf("failed to write %d coalesced locations: %w", len(points), err)
+	}
+	return nil
+}
+
+// SetSessionBatchPolicy overrides the write coalescing policy for one active
+// session; the configured policy applies to every other session. It takes
+// effect from the session's next buffered point.
+func (ts *TrackingService) SetSessionBatchPolicy(sessionID string, policy BatchPolicy) error {
+	cw, ok := ts.db.(*CoalescingWriter)
+	if !ok {
+		return ErrCoalescingDisabled
+	}
+	if _, err := ts.getSession(sessionID); err != nil {
+		return fmt.Errorf("%w: %w", ErrBatchSessionInactive, err)
+	}
+	if err := cw.SetSessionPolicy(sessionID, policy); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidBatchPolicy, err)
+	}
+	return nil
+}
+
+// FlushLocationWrites writes every location still buffered by write
+// coalescing, so a shutting down instance loses none of them. It is a no-op
+// when coalescing is disabled.
+func (ts *TrackingService) FlushLocationWrites() error {
+	cw, ok := ts.db.(*CoalescingWriter)
+	if !ok {
+		return nil
+	}
+	return cw.Flush()
+}
+
+// flushSessionWrites writes any locations still buffered for a session that is
+// ending, so its persisted track is complete before coverage and uploads run.
+func (ts *TrackingService) flushSessionWrites(sessionID string) {
+	cw, ok := ts.db.(*CoalescingWriter)
+	if !ok {
+		return
+	}
+	if err := cw.FlushSession(sessionID); err != nil {
+		ts.logger.Warn("Failed to flush buffered locations",
+			zap.String("sessionID", sessionID),
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	// errors for the completed session sentinel (standard library)
+	"errors"
+	// fmt for constructor validation errors (standard library)
+	"fmt"
+	// sync for guarding the completed session set (standard library)
+	"sync"
+	// time for retention of completed session IDs (standard library)
+	"time"
+
+	// prometheus for post-completion message metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Ingress transports, used to label rejected post-completion messages.
+const (
+	IngressHTTP      = "http"
+	IngressWebSocket = "websocket"
+	IngressMQTT      = "mqtt"
+)
+
+// ErrSessionCompleted is returned at ingress for location messages addressed to
+// a session that has already ended. Devices receiving a terminal ack for it must
+// stop sending.
+var ErrSessionCompleted = errors.New("session has been completed")
+
+// CompletedSessionFilter remembers recently ended sessions so location messages
+// still in flight from their devices are rejected at ingress, before any
+// validation or session lookup, with a terminal ack rather than a retryable
+// error.
+type CompletedSessionFilter struct {
+	retention time.Duration
+
+	mu        sync.Mutex
+	ended     map[string]time.Time
+	lastPrune time.Time
+
+	rejected *prometheus.CounterVec
+}
+
+// NewCompletedSessionFilter creates a filter remembering ended sessions for
+// retention. Metrics are registered on registry when it is non-nil.
+func NewCompletedSessionFilter(retention time.Duration, registry *prometheus.Registry) (*CompletedSessionFilter, error) {
+	if retention <= 0 {
+		return nil, fmt.Errorf("completed session retention must be positive")
+	}
+	f := &CompletedSessionFilter{
+		retention: retention,
+		ended:     make(map[string]time.Time),
+		lastPrune: time.Now(),
+		rejected: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tracking_post_completion_messages_total",
+				Help: "Location messages received for already completed sessions, by ingress transport.",
+			},
+			[]string{"transport"},
+		),
+	}
+	if registry != nil {
+		registry.MustRegister(f.rejected)
+	}
+	return f, nil
+}
+
+// MarkCompleted records that sessionID has ended.
+func (f *CompletedSessionFilter) MarkCompleted(sessionID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	f.ended[sessionID] = now
+	if now.Sub(f.lastPrune) >= f.retention/10 {
+		for id, endedAt := range f.ended {
+			if now.Sub(endedAt) > f.retention {
+				delete(f.ended, id)
+			}
+		}
+		f.lastPrune = now
+	}
+}
+
+// Completed reports whether sessionID ended within the retention period.
+func (f *CompletedSessionFilter) Completed(sessionID string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	endedAt, ok := f.ended[sessionID]
+	return ok && time.Since(endedAt) <= f.retention
+}
+
+// SetCompletedSessionFilter enables early rejection of location messages for
+// ended sessions. Passing nil disables it.
+func (ts *TrackingService) SetCompletedSessionFilter(filter *CompletedSessionFilter) {
+	ts.completed = filter
+}
+
+// CheckIngress is called by every location ingress before processing a message
+// for sessionID. It returns ErrSessionCompleted, counting the message against
+// transport, when the session has already ended.
+func (ts *TrackingService) CheckIngress(sessionID, transport string) error {
+	if ts.completed == nil || !ts.completed.Completed(sessionID) {
+		return nil
+	}
+	ts.completed.rejected.WithLabelValues(transport).Inc()
+	return ErrSessionCompleted
+}
+
+// TerminalAck is the ack sent in reply to messages for an ended session,
+// telling the device to discard its buffered uploads and stop sending.
+func TerminalAck(sessionID string) UploadAck {
+	return UploadAck{SessionID: sessionID, Terminal: true}
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	// errors for the conflicting walk sentinel (go1.21)
+	"errors"
+	// fmt for wrapping the conflict with the walk ID (go1.21)
+	"fmt"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// models package that includes the TrackingSession struct
+	"src/backend/tracking-service/internal/models"
+)
+
+// ErrWalkSessionConflict is returned when a session is started for a walk
+// whose active session belongs to another walker, dog or tenant.
+var ErrWalkSessionConflict = errors.New("walk has an active session for another walker or dog")
+
+// StartOrContinueSession starts a session like StartDeviceSession, unless
+// the walk already has an active session, typically because the walker app
+// restarted mid-walk and started again for the same booking. That session is
+// then continued instead of a parallel one being created: it keeps its
+// accumulated distance and statistics, its settings including the original
+// geofence radius, and its upload acks, and is resumed if paused. The
+// reported continued is true in that case.
+//
+// The device is checked against the walker's paired devices either way. The
+// region of a continued session is not changed, and its session must belong
+// to the same walker and dog, and tenant when given, or
+// ErrWalkSessionConflict is returned.
+func (ts *TrackingService) StartOrContinueSession(tenantID, region, deviceID, walkID, walkerID, dogID string) (*models.TrackingSession, bool, error) {
+	ts.startMu.Lock()
+	defer ts.startMu.Unlock()
+
+	if existing := ts.activeWalkSession(walkID); existing != nil {
+		session, err := ts.continueSession(existing, tenantID, deviceID, walkerID, dogID)
+		if err != nil {
+			return nil, false, err
+		}
+		return session, true, nil
+	}
+	session, err := ts.startSession(tenantID, region, deviceID, walkID, walkerID, dogID)
+	return session, false, err
+}
+
+// activeWalkSession returns the active or paused session of walkID, or nil.
+func (ts *TrackingService) activeWalkSession(walkID string) *models.TrackingSession {
+	var found *models.TrackingSession
+	ts.activeSessions.Range(func(_, value interface{}) bool {
+		session, ok := value.(*models.TrackingSession)
+		if ok && session.WalkID() == walkID && session.Status() != models.SessionStatusCompleted {
+			found = session
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// continueSession hands the walk's existing session to a restarted app.
+func (ts *TrackingService) continueSession(session *models.TrackingSession, tenantID, deviceID, walkerID, dogID string) (*models.TrackingSession, error) {
+	if session.WalkerID() != walkerID || session.DogID() != dogID || (tenantID != "" && tenantID != session.TenantID()) {
+		return nil, fmt.Errorf("%w: walk %s is tracked by session %s", ErrWalkSessionConflict, session.WalkID(), session.ID)
+	}
+	if _, err := ts.checkDevice(session.TenantID(), walkerID, deviceID); err != nil {
+		return nil, err
+	}
+
+	if session.Status() == models.SessionStatusPaused {
+		if err := session.Resume(); err != nil {
+			return nil, fmt.Errorf("failed to resume session %s: %w", session.ID, err)
+		}
+		ts.recordStateEvent(session, models.EventSessionResumed, nil)
+		ts.replicateLifecycle(SessionEventResumed, session)
+	}
+
+	ts.logger.Info("Tracking session continued",
+		zap.String("sessionID", session.ID),
+		zap.String("walkID", session.WalkID()),
+		zap.String("deviceID", deviceID),
+		zap.Uint64("ackedUploadSeq", ts.uploadAcks.Acked(session.ID)),
+	)
+	return session, nil
+}
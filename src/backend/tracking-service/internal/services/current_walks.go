@@ -0,0 +1,132 @@
+package services
+
+import (
+	// errors for the disabled sentinel (go1.21)
+	"errors"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// models package that includes the CurrentWalk and CurrentWalkChange structs
+	"src/backend/tracking-service/internal/models"
+)
+
+// DefaultCurrentWalkFeedLimit is the number of changes returned when a feed
+// query does not specify a limit, and MaxCurrentWalkFeedLimit is the most a
+// query may request.
+const (
+	DefaultCurrentWalkFeedLimit = 100
+	MaxCurrentWalkFeedLimit     = 1000
+)
+
+// ErrCurrentWalksDisabled is returned by current walk queries when no current
+// walks store is configured.
+var ErrCurrentWalksDisabled = errors.New("current walks view is not enabled")
+
+// CurrentWalksStore keeps the table of running walks and the outbox of its
+// changes. It is implemented by repository.TimescaleRepository.
+type CurrentWalksStore interface {
+	// RecordWalkTransition applies a change to the table and appends it to the
+	// outbox in one transaction, setting its Seq and ChangedAt.
+	RecordWalkTransition(change *models.CurrentWalkChange) error
+	// GetCurrentWalks returns the running walks, of one tenant unless tenantID is empty.
+	GetCurrentWalks(tenantID string) ([]models.CurrentWalk, error)
+	// GetCurrentWalksPage returns up to limit running walks after the cursor,
+	// ordered by start time then session ID.
+	GetCurrentWalksPage(tenantID string, after models.PageCursor, limit int) ([]models.CurrentWalk, error)
+	// GetCurrentWalkChanges returns up to limit changes after afterSeq, in order.
+	GetCurrentWalkChanges(afterSeq int64, limit int) ([]models.CurrentWalkChange, error)
+}
+
+// SetCurrentWalksStore enables the current walks view: every session start,
+// status change and end is applied to it and published on its change feed.
+// Passing nil disables it.
+func (ts *TrackingService) SetCurrentWalksStore(store CurrentWalksStore) {
+	ts.currentWalks = store
+}
+
+// GetCurrentWalks lists the running walks, of one tenant unless tenantID is
+// empty, from the current walks view rather than the session history.
+func (ts *TrackingService) GetCurrentWalks(tenantID string) ([]models.CurrentWalk, error) {
+	if ts.currentWalks == nil {
+		return nil, ErrCurrentWalksDisabled
+	}
+	walks, err := ts.currentWalks.GetCurrentWalks(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if walks == nil {
+		walks = []models.CurrentWalk{}
+	}
+	return walks, nil
+}
+
+// GetCurrentWalksPage lists a page of the running walks, of one tenant unless
+// tenantID is empty, oldest first, with the cursor of the next page, or nil on
+// the last one. A limit of zero uses DefaultPageSize.
+func (ts *TrackingService) GetCurrentWalksPage(tenantID string, after models.PageCursor, limit int) ([]models.CurrentWalk, *models.PageCursor, error) {
+	if ts.currentWalks == nil {
+		return nil, nil, ErrCurrentWalksDisabled
+	}
+	limit = pageLimit(limit)
+	walks, err := ts.currentWalks.GetCurrentWalksPage(tenantID, after, limit+1)
+	if err != nil {
+		return nil, nil, err
+	}
+	var next *models.PageCursor
+	if len(walks) > limit {
+		walks = walks[:limit]
+		last := walks[limit-1]
+		next = &models.PageCursor{Timestamp: last.StartedAt, ID: last.SessionID}
+	}
+	if walks == nil {
+		walks = []models.CurrentWalk{}
+	}
+	return walks, next, nil
+}
+
+// GetCurrentWalkChanges returns the page of the change feed after afterSeq,
+// for dashboards that poll instead of listening on the database channel. A
+// limit of zero uses the default.
+func (ts *TrackingService) GetCurrentWalkChanges(afterSeq int64, limit int) (*models.CurrentWalkFeed, error) {
+	if ts.currentWalks == nil {
+		return nil, ErrCurrentWalksDisabled
+	}
+	if limit <= 0 {
+		limit = DefaultCurrentWalkFeedLimit
+	}
+	if limit > MaxCurrentWalkFeedLimit {
+		limit = MaxCurrentWalkFeedLimit
+	}
+	changes, err := ts.currentWalks.GetCurrentWalkChanges(afterSeq, limit)
+	if err != nil {
+		return nil, err
+	}
+	feed := &models.CurrentWalkFeed{Changes: changes, NextSeq: afterSeq}
+	if feed.Changes == nil {
+		feed.Changes = []models.CurrentWalkChange{}
+	}
+	if n := len(feed.Changes); n > 0 {
+		feed.NextSeq = feed.Changes[n-1].Seq
+	}
+	return feed, nil
+}
+
+// recordWalkTransition applies a session transition to the current walks
+// view when it is enabled. Failures are logged; they never fail the
+// transition itself.
+func (ts *TrackingService) recordWalkTransition(session *models.TrackingSession, change string) {
+	if ts.currentWalks == nil {
+		return
+	}
+	if err := ts.currentWalks.RecordWalkTransition(&models.CurrentWalkChange{
+		Change: change,
+		Walk:   models.NewCurrentWalk(session),
+	}); err != nil {
+		ts.logger.Error("Failed to record current walk transition",
+			zap.String("sessionID", session.ID),
+			zap.String("change", change),
+			zap.Error(err),
+		)
+	}
+}
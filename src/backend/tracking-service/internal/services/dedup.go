@@ -0,0 +1,184 @@
+package services
+
+import (
+	// errors for the settings validation sentinel (go1.21)
+	"errors"
+	// maphash for the filter's seeded hash functions (go1.21)
+	"hash/maphash"
+	// math for sizing the filter (go1.21)
+	"math"
+	// sync for guarding the filter generations (go1.21)
+	"sync"
+
+	// prometheus for skipped duplicate metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// models package that includes the Location struct
+	"src/backend/tracking-service/internal/models"
+)
+
+// ErrInvalidDedupSettings is returned when the recent location filter's
+// capacity is not positive or its false positive rate is not within (0, 1).
+var ErrInvalidDedupSettings = errors.New("location dedup needs a positive capacity and a false positive rate within (0, 1)")
+
+// RecentLocationFilter remembers the IDs of recently stored location points in
+// a bloom filter, so the points of uploads retried by mobile clients are
+// skipped before they reach the session or the database. It keeps two
+// generations of capacity IDs each and drops the older one when the newer one
+// fills, so memory stays bounded and IDs age out.
+//
+// A bloom filter can report an ID it never saw, at the configured false
+// positive rate, and that point is then skipped. The database's unique index
+// on location IDs is the authoritative deduplication; the filter saves the
+// work on retries.
+type RecentLocationFilter struct {
+	bits   uint64
+	hashes int
+	limit  int
+	seeds  [2]maphash.Seed
+
+	mu       sync.Mutex
+	current  *bloomGeneration
+	previous *bloomGeneration
+
+	skipped prometheus.Counter
+}
+
+// bloomGeneration is one generation of the filter and how many IDs it holds.
+type bloomGeneration struct {
+	words []uint64
+	count int
+}
+
+// NewRecentLocationFilter creates a filter sized for capacity IDs per
+// generation at falsePositiveRate. Its metrics are registered on registry when
+// non-nil.
+func NewRecentLocationFilter(capacity int, falsePositiveRate float64, registry *prometheus.Registry) (*RecentLocationFilter, error) {
+	if capacity <= 0 || falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		return nil, ErrInvalidDedupSettings
+	}
+	bits := math.Ceil(-float64(capacity) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	hashes := int(math.Round(bits / float64(capacity) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+	f := &RecentLocationFilter{
+		bits:   uint64(bits),
+		hashes: hashes,
+		limit:  capacity,
+		seeds:  [2]maphash.Seed{maphash.MakeSeed(), maphash.MakeSeed()},
+		skipped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tracking_location_duplicates_skipped_total",
+			Help: "Location points skipped at ingestion because their ID was recently stored",
+		}),
+	}
+	f.current = f.newGeneration()
+	f.previous = f.newGeneration()
+	if registry != nil {
+		registry.MustRegister(f.skipped)
+	}
+	return f, nil
+}
+
+// newGeneration allocates an empty generation.
+func (f *RecentLocationFilter) newGeneration() *bloomGeneration {
+	return &bloomGeneration{words: make([]uint64, (f.bits+63)/64)}
+}
+
+// positions returns the bit positions of id, by double hashing.
+func (f *RecentLocationFilter) positions(id string) []uint64 {
+	h1 := maphash.String(f.seeds[0], id)
+	h2 := maphash.String(f.seeds[1], id) | 1
+	positions := make([]uint64, f.hashes)
+	for i := range positions {
+		positions[i] = (h1 + uint64(i)*h2) % f.bits
+	}
+	return positions
+}
+
+// Seen reports whether id was probably added to either generation.
+func (f *RecentLocationFilter) Seen(id string) bool {
+	positions := f.positions(id)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.current.has(positions) || f.previous.has(positions)
+}
+
+// Add records ids as stored, rotating generations as the current one fills.
+func (f *RecentLocationFilter) Add(ids ...string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, id := range ids {
+		if f.current.count >= f.limit {
+			f.previous, f.current = f.current, f.newGeneration()
+		}
+		for _, p := range f.positions(id) {
+			f.current.words[p/64] |= 1 << (p % 64)
+		}
+		f.current.count++
+	}
+}
+
+// has reports whether every bit at positions is set.
+func (g *bloomGeneration) has(positions []uint64) bool {
+	for _, p := range positions {
+		if g.words[p/64]&(1<<(p%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// SetRecentLocationFilter enables skipping points whose IDs were recently
+// stored. Passing nil disables it.
+func (ts *TrackingService) SetRecentLocationFilter(filter *RecentLocationFilter) {
+	ts.recentLocations = filter
+}
+
+// dropDuplicates removes from locations the points already stored according
+// to the recent location filter, and all but the first point of each ID
+// repeated within the batch, returning the remaining points and how many were
+// dropped. Points without an ID are kept.
+func (ts *TrackingService) dropDuplicates(sessionID string, locations []*models.Location) ([]*models.Location, int) {
+	if ts.recentLocations == nil {
+		return locations, 0
+	}
+	kept := locations[:0]
+	inBatch := make(map[string]struct{}, len(locations))
+	for _, loc := range locations {
+		if loc.ID != "" {
+			if _, repeated := inBatch[loc.ID]; repeated || ts.recentLocations.Seen(loc.ID) {
+				ts.observeQuality(loc, PointDuplicate)
+				continue
+			}
+			inBatch[loc.ID] = struct{}{}
+		}
+		kept = append(kept, loc)
+	}
+	dropped := len(locations) - len(kept)
+	if dropped > 0 {
+		ts.recentLocations.skipped.Add(float64(dropped))
+		ts.logger.Debug("Skipped duplicate locations",
+			zap.String("sessionID", sessionID),
+			zap.Int("duplicates", dropped),
+		)
+	}
+	return kept, dropped
+}
+
+// rememberStored adds the IDs of stored points to the recent location filter.
+func (ts *TrackingService) rememberStored(locations []*models.Location) {
+	if ts.recentLocations == nil {
+		return
+	}
+	ids := make([]string, 0, len(locations))
+	for _, loc := range locations {
+		if loc.ID != "" {
+			ids = append(ids, loc.ID)
+		}
+	}
+	ts.recentLocations.Add(ids...)
+}
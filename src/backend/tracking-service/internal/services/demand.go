@@ -0,0 +1,154 @@
+package services
+
+import (
+	// errors for the demand sentinels (go1.21)
+	"errors"
+	// fmt for wrapping validation errors (go1.21)
+	"fmt"
+	// sort for ordering buckets (go1.21)
+	"sort"
+	// time for demand windows and time zones (go1.21)
+	"time"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// models package that includes the DemandHeatmap struct
+	"src/backend/tracking-service/internal/models"
+	// utils package providing the demand grid
+	"src/backend/tracking-service/internal/utils"
+)
+
+// DefaultDemandDays is the window of a demand query that does not specify
+// one, and MaxDemandDays the longest a query may request.
+const (
+	DefaultDemandDays = 28
+	MaxDemandDays     = 90
+)
+
+var (
+	// ErrDemandDisabled is returned by demand queries when no demand store is
+	// configured.
+	ErrDemandDisabled = errors.New("demand analytics are not enabled")
+
+	// ErrInvalidDemandQuery is returned for unknown time zones or windows
+	// outside 1 to MaxDemandDays days.
+	ErrInvalidDemandQuery = errors.New("invalid demand query")
+)
+
+// DemandStore aggregates walk starts by grid cell and hour. It is implemented
+// by repository.TimescaleRepository.
+type DemandStore interface {
+	// RecordWalkStart counts a walk's start once per walk, in the hour of startedAt.
+	RecordWalkStart(walkID, cell string, lat, lon float64, startedAt time.Time) error
+	// GetDemandBuckets returns the starts since since in cells centered in the box,
+	// by cell and hour of the day in loc, for buckets with at least minStarts starts.
+	GetDemandBuckets(bbox models.BoundingBox, since time.Time, loc *time.Location, minStarts int) ([]models.DemandBucket, error)
+}
+
+// SetDemandStore enables demand analytics: completed walks are counted by the
+// cell and hour they started in. Buckets with fewer than minStarts starts are
+// never returned, so a walker's regular start cannot be singled out. Passing
+// nil disables it.
+func (ts *TrackingService) SetDemandStore(store DemandStore, minStarts int) {
+	if minStarts < 1 {
+		minStarts = 1
+	}
+	ts.demandStore = store
+	ts.demandMinStarts = minStarts
+}
+
+// GetDemandHeatmap returns where and when walks started inside the box over
+// the last days days, by hour of the day in the IANA time zone tz, for
+// suggesting to walkers where and when demand is highest. An empty tz means
+// UTC and zero days the default window.
+func (ts *TrackingService) GetDemandHeatmap(bbox models.BoundingBox, tz string, days int) (*models.DemandHeatmap, error) {
+	if ts.demandStore == nil {
+		return nil, ErrDemandDisabled
+	}
+	if days == 0 {
+		days = DefaultDemandDays
+	}
+	if days < 1 || days > MaxDemandDays {
+		return nil, fmt.Errorf("%w: days must be between 1 and %d", ErrInvalidDemandQuery, MaxDemandDays)
+	}
+	if tz == "" {
+		tz = "UTC"
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown time zone %q", ErrInvalidDemandQuery, tz)
+	}
+
+	to := time.Now().UTC().Truncate(time.Hour)
+	from := to.AddDate(0, 0, -days)
+	buckets, err := ts.demandStore.GetDemandBuckets(bbox, from, loc, ts.demandMinStarts)
+	if err != nil {
+		return nil, err
+	}
+	return newDemandHeatmap(bbox, from, to, tz, ts.demandMinStarts, buckets), nil
+}
+
+// newDemandHeatmap orders buckets busiest first and totals them by hour, with
+// intensities relative to the busiest bucket and hour.
+func newDemandHeatmap(bbox models.BoundingBox, from, to time.Time, tz string, minStarts int, buckets []models.DemandBucket) *models.DemandHeatmap {
+	heatmap := &models.DemandHeatmap{
+		Area:      bbox,
+		From:      from,
+		To:        to,
+		Timezone:  tz,
+		MinStarts: minStarts,
+		Buckets:   buckets,
+		Hours:     make([]models.DemandHour, 24),
+		PeakHour:  -1,
+	}
+	if heatmap.Buckets == nil {
+		heatmap.Buckets = []models.DemandBucket{}
+	}
+	sort.SliceStable(heatmap.Buckets, func(i, j int) bool { return heatmap.Buckets[i].Starts > heatmap.Buckets[j].Starts })
+
+	for h := range heatmap.Hours {
+		heatmap.Hours[h].Hour = h
+	}
+	for _, b := range heatmap.Buckets {
+		if b.Hour >= 0 && b.Hour < 24 {
+			heatmap.Hours[b.Hour].Starts += b.Starts
+		}
+	}
+	if len(heatmap.Buckets) > 0 && heatmap.Buckets[0].Starts > 0 {
+		busiest := float64(heatmap.Buckets[0].Starts)
+		for i := range heatmap.Buckets {
+			heatmap.Buckets[i].Intensity = float64(heatmap.Buckets[i].Starts) / busiest
+		}
+	}
+	peak := 0
+	for h, hour := range heatmap.Hours {
+		if hour.Starts > peak {
+			peak, heatmap.PeakHour = hour.Starts, h
+		}
+	}
+	if peak > 0 {
+		for h := range heatmap.Hours {
+			heatmap.Hours[h].Intensity = float64(heatmap.Hours[h].Starts) / float64(peak)
+		}
+	}
+	return heatmap
+}
+
+// recordWalkStart counts an ended session's start into the demand grid, at
+// its first recorded point and start time. Failures are logged and never
+// affect the session.
+func (ts *TrackingService) recordWalkStart(session *models.TrackingSession) {
+	track, err := ts.fullLocationHistory(session)
+	if err == nil && len(track) > 0 {
+		cell, lat, lon := utils.DemandCell(track[0].Latitude, track[0].Longitude)
+		err = ts.demandStore.RecordWalkStart(session.WalkID(), cell, lat, lon, session.StartTime())
+	}
+	if err != nil {
+		ts.logger.Warn("Failed to record walk start demand",
+			zap.String("sessionID", session.ID),
+			zap.String("walkID", session.WalkID()),
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	// hmac and sha256 for verifying pairing attestations (go1.21)
+	"crypto/hmac"
+	"crypto/sha256"
+	// base64 for encoding attestations (go1.21)
+	"encoding/base64"
+	// errors for the device sentinels (go1.21)
+	"errors"
+	// fmt for wrapping validation and repository errors (go1.21)
+	"fmt"
+	// time for pairing and revocation timestamps (go1.21)
+	"time"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// models package that includes the Device struct
+	"src/backend/tracking-service/internal/models"
+)
+
+var (
+	// ErrDevicePairingDisabled is returned by device pairing and revocation
+	// when no device store is configured.
+	ErrDevicePairingDisabled = errors.New("device pairing is not enabled")
+
+	// ErrInvalidDevicePairing wraps the validation failures of a pairing
+	// request.
+	ErrInvalidDevicePairing = errors.New("invalid device pairing")
+
+	// ErrInvalidDeviceAttestation is returned when a pairing's attestation
+	// was not signed by the app.
+	ErrInvalidDeviceAttestation = errors.New("invalid device attestation")
+
+	// ErrDeviceRevoked is returned when pairing a device the walker revoked.
+	ErrDeviceRevoked = errors.New("device has been revoked")
+
+	// ErrUntrustedDevice is returned when a session is started from a device
+	// that is not trusted and the tenant's device policy rejects it.
+	ErrUntrustedDevice = errors.New("device is not trusted")
+)
+
+// DeviceStore persists the devices walkers paired. It is implemented by
+// repository.TimescaleRepository.
+type DeviceStore interface {
+	// SaveDevice pairs a device to its walker, leaving a revoked device revoked.
+	SaveDevice(device *models.Device) error
+
+	// GetDevice returns a device the walker paired, revoked or not.
+	GetDevice(walkerID, deviceID string) (*models.Device, error)
+
+	// GetWalkerDevices returns every device the walker paired.
+	GetWalkerDevices(walkerID string) ([]models.Device, error)
+
+	// RevokeDevice marks a device the walker paired as revoked.
+	RevokeDevice(walkerID, deviceID string, at time.Time) error
+}
+
+// DevicePolicy decides, per tenant, what happens to sessions started from a
+// device that is not trusted. Each policy is one of models.DevicePolicyAllow,
+// DevicePolicyFlag and DevicePolicyReject.
+type DevicePolicy struct {
+	// Default applies to tenants without their own policy and to sessions
+	// started without a tenant.
+	Default string
+
+	// Tenants maps tenant IDs to their policy.
+	Tenants map[string]string
+}
+
+// For returns the policy of the tenant.
+func (p DevicePolicy) For(tenantID string) string {
+	if policy, ok := p.Tenants[tenantID]; ok {
+		return policy
+	}
+	return p.Default
+}
+
+// SetDeviceRegistry enables device pairing: walkers pair the devices they
+// report walks from with a request attested by the app, sessions record the
+// trust of the device they were started from, and policy decides what happens
+// to sessions from devices that are not trusted. attestationKey is the app's
+// signing secret. Passing a nil store disables it.
+func (ts *TrackingService) SetDeviceRegistry(store DeviceStore, attestationKey []byte, policy DevicePolicy) {
+	ts.devices = store
+	ts.deviceAttestationKey = append([]byte(nil), attestationKey...)
+	ts.devicePolicy = policy
+}
+
+// DeviceAttestation returns the attestation the app signs a pairing of the
+// walker's device with: the base64url HMAC-SHA256, keyed with the app's
+// signing secret, of the walker ID, device ID and platform, each on its own
+// line.
+func DeviceAttestation(key []byte, walkerID, deviceID, platform string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(walkerID + "\n" + deviceID + "\n" + platform))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// PairDevice verifies a pairing request of the walker's app and records the
+// device as trusted. Pairing a paired device again updates its name and
+// platform; a revoked device cannot be paired again.
+//
+// Steps:
+//  1. Validate the request
+//  2. Verify the attestation against the app's signing secret
+//  3. Refuse devices the walker revoked, then store the device
+func (ts *TrackingService) PairDevice(walkerID string, pairing *models.DevicePairing) (*models.Device, error) {
+	if ts.devices == nil {
+		return nil, ErrDevicePairingDisabled
+	}
+	if walkerID == "" {
+		return nil, fmt.Errorf("%w: walkerId is required", ErrInvalidDevicePairing)
+	}
+	if err := pairing.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDevicePairing, err)
+	}
+	expected := DeviceAttestation(ts.deviceAttestationKey, walkerID, pairing.DeviceID, pairing.Platform)
+	if !hmac.Equal([]byte(pairing.Attestation), []byte(expected)) {
+		return nil, ErrInvalidDeviceAttestation
+	}
+
+	device := &models.Device{
+		DeviceID: pairing.DeviceID,
+		WalkerID: walkerID,
+		Name:     pairing.Name,
+		Platform: pairing.Platform,
+		PairedAt: time.Now().UTC(),
+	}
+	if existing, err := ts.devices.GetDevice(walkerID, pairing.DeviceID); err == nil {
+		if existing.RevokedAt != nil {
+			return nil, fmt.Errorf("%w: device %s was revoked at %s", ErrDeviceRevoked, pairing.DeviceID, existing.RevokedAt.Format(time.RFC3339))
+		}
+		device.PairedAt = existing.PairedAt
+	}
+	if err := ts.devices.SaveDevice(device); err != nil {
+		return nil, fmt.Errorf("failed to pair device %s: %w", pairing.DeviceID, err)
+	}
+	ts.logger.Info("Device paired",
+		zap.String("walkerID", walkerID),
+		zap.String("deviceID", device.DeviceID),
+		zap.String("platform", device.Platform),
+	)
+	return device, nil
+}
+
+// GetWalkerDevices returns every device the walker paired, including revoked
+// ones.
+func (ts *TrackingService) GetWalkerDevices(walkerID string) ([]models.Device, error) {
+	if ts.devices == nil {
+		return nil, ErrDevicePairingDisabled
+	}
+	devices, err := ts.devices.GetWalkerDevices(walkerID)
+	if err != nil {
+		return nil, err
+	}
+	if devices == nil {
+		devices = []models.Device{}
+	}
+	return devices, nil
+}
+
+// RevokeDevice revokes a device the walker paired, for example one that was
+// lost, so sessions started from it afterwards are untrusted. Sessions already
+// running from it keep the trust they started with. Store errors are returned
+// unchanged, so a device the walker never paired is repository.ErrNotFound.
+func (ts *TrackingService) RevokeDevice(walkerID, deviceID string) error {
+	if ts.devices == nil {
+		return ErrDevicePairingDisabled
+	}
+	if err := ts.devices.RevokeDevice(walkerID, deviceID, time.Now().UTC()); err != nil {
+		return err
+	}
+	ts.logger.Info("Device revoked",
+		zap.String("walkerID", walkerID),
+		zap.String("deviceID", deviceID),
+	)
+	return nil
+}
+
+// checkDevice returns the trust of the device a session of the walker is
+// started from, empty when the registry is disabled, applying the tenant's
+// device policy to devices that are not trusted. A device that cannot be
+// looked up, whether never paired or for a store failure, is unknown.
+func (ts *TrackingService) checkDevice(tenantID, walkerID, deviceID string) (string, error) {
+	if ts.devices == nil {
+		return "", nil
+	}
+	trust := models.DeviceUnknown
+	if deviceID != "" {
+		device, err := ts.devices.GetDevice(walkerID, deviceID)
+		if err != nil {
+			ts.logger.Debug("Session device not found; treating it as unknown",
+				zap.String("walkerID", walkerID),
+				zap.String("deviceID", deviceID),
+				zap.Error(err),
+			)
+		} else {
+			trust = device.Trust()
+		}
+	}
+	if trust == models.DeviceTrusted {
+		return trust, nil
+	}
+
+	switch ts.devicePolicy.For(tenantID) {
+	case models.DevicePolicyReject:
+		return "", fmt.Errorf("%w: device %q of walker %s is %s", ErrUntrustedDevice, deviceID, walkerID, trust)
+	case models.DevicePolicyFlag:
+		ts.logger.Warn("Session started from a device that is not trusted",
+			zap.String("tenantID", tenantID),
+			zap.String("walkerID", walkerID),
+			zap.String("deviceID", deviceID),
+			zap.String("trust", trust),
+		)
+	}
+	return trust, nil
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	// list for least-recently-used ordering of cached profiles (go1.21)
+	"container/list"
+	// context for bounding pets service requests (go1.21)
+	"context"
+	// json for decoding pets service responses (go1.21)
+	"encoding/json"
+	// errors for sentinel dog profile errors (go1.21)
+	"errors"
+	// fmt for formatting error messages (go1.21)
+	"fmt"
+	// io for draining error response bodies (go1.21)
+	"io"
+	// http for calling the pets service (go1.21)
+	"net/http"
+	// url for escaping dog IDs into request paths (go1.21)
+	"net/url"
+	// strings for trimming base URLs and error bodies (go1.21)
+	"strings"
+	// sync for guarding the profile cache (go1.21)
+	"sync"
+	// time for request timeouts and cache expiry (go1.21)
+	"time"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+	// prometheus for dog profile lookup metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+
+	// models package for the DogProfile struct
+	"src/backend/tracking-service/internal/models"
+)
+
+// ErrDogNotFound is returned when the pets service has no profile for a dog.
+var ErrDogNotFound = errors.New("dog profile not found")
+
+// PetsClient fetches dog profiles from the pets service.
+type PetsClient interface {
+	// GetDogProfile returns the profile of dogID, or ErrDogNotFound.
+	GetDogProfile(ctx context.Context, dogID string) (*models.DogProfile, error)
+}
+
+// HTTPPetsClient fetches dog profiles from the pets service REST API, at
+// GET {baseURL}/dogs/{dogID}.
+type HTTPPetsClient struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewHTTPPetsClient creates a client of the pets service at baseURL, sending
+// token as a bearer token when non-empty, with each request bounded by
+// timeout.
+func NewHTTPPetsClient(baseURL, token string, timeout time.Duration) (*HTTPPetsClient, error) {
+	baseURL = strings.TrimRight(baseURL, "/")
+	if baseURL == "" {
+		return nil, fmt.Errorf("pets service client requires a base URL")
+	}
+	return &HTTPPetsClient{baseURL: baseURL, token: token, httpClient: &http.Client{Timeout: timeout}}, nil
+}
+
+// GetDogProfile implements PetsClient.
+func (c *HTTPPetsClient) GetDogProfile(ctx context.Context, dogID string) (*models.DogProfile, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/dogs/"+url.PathEscape(dogID), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, ErrDogNotFound
+	default:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("pets service returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var profile models.DogProfile
+	if err := json.NewDecoder(resp.Body).Decode(&profile); err != nil {
+		return nil, fmt.Errorf("invalid pets service response: %w", err)
+	}
+	if profile.ID == "" {
+		profile.ID = dogID
+	}
+	return &profile, nil
+}
+
+// dogProfileEntry is a cached lookup; a nil profile caches a miss.
+type dogProfileEntry struct {
+	dogID    string
+	profile  *models.DogProfile
+	cachedAt time.Time
+}
+
+// DogProfileCache wraps a PetsClient with a bounded least-recently-used
+// profile cache, so the dogs walked every day rarely reach the pets service.
+// Misses are cached too.
+type DogProfileCache struct {
+	next PetsClient
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+
+	lookups *prometheus.CounterVec
+}
+
+// NewDogProfileCache wraps next with a cache of size entries kept for ttl.
+// Metrics are registered on registry when it is non-nil.
+func NewDogProfileCache(next PetsClient, size int, ttl time.Duration, registry *prometheus.Registry) (*DogProfileCache, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("dog profile cache size must be at least 1")
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("dog profile cache TTL must be positive")
+	}
+	c := &DogProfileCache{
+		next:    next,
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		lookups: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tracking_dog_profile_lookups_total",
+				Help: "Dog profile lookups, by outcome (cache_hit, resolved, not_found or error).",
+			},
+			[]string{"outcome"},
+		),
+	}
+	if registry != nil {
+		registry.MustRegister(c.lookups)
+	}
+	return c, nil
+}
+
+// GetDogProfile implements PetsClient.
+func (c *DogProfileCache) GetDogProfile(ctx context.Context, dogID string) (*models.DogProfile, error) {
+	if profile, ok := c.cached(dogID); ok {
+		c.lookups.WithLabelValues("cache_hit").Inc()
+		if profile == nil {
+			return nil, ErrDogNotFound
+		}
+		return profile, nil
+	}
+
+	profile, err := c.next.GetDogProfile(ctx, dogID)
+	switch {
+	case errors.Is(err, ErrDogNotFound):
+		c.lookups.WithLabelValues("not_found").Inc()
+		c.store(dogID, nil)
+		return nil, err
+	case err != nil:
+		c.lookups.WithLabelValues("error").Inc()
+		return nil, err
+	}
+	c.lookups.WithLabelValues("resolved").Inc()
+	c.store(dogID, profile)
+	return profile, nil
+}
+
+// cached returns an unexpired cache entry, marking it most recently used.
+func (c *DogProfileCache) cached(dogID string) (*models.DogProfile, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[dogID]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*dogProfileEntry)
+	if time.Since(entry.cachedAt) > c.ttl {
+		c.order.Remove(elem)
+		delete(c.entries, dogID)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.profile, true
+}
+
+// store caches profile for dogID, evicting the least recently used entry when full.
+func (c *DogProfileCache) store(dogID string, profile *models.DogProfile) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[dogID]; ok {
+		entry := elem.Value.(*dogProfileEntry)
+		entry.profile, entry.cachedAt = profile, time.Now()
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[dogID] = c.order.PushFront(&dogProfileEntry{dogID: dogID, profile: profile, cachedAt: time.Now()})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*dogProfileEntry).dogID)
+	}
+}
+
+// SetDogProfiles enables enriching session-start stream frames and walk
+// summaries with the walked dog's profile, each lookup bounded by timeout.
+// Passing nil disables it.
+func (ts *TrackingService) SetDogProfiles(client PetsClient, timeout time.Duration) {
+	ts.dogProfiles = client
+	ts.dogProfileTimeout = timeout
+}
+
+// GetSessionDogProfile returns the profile of the dog walked in an active
+// session, or nil when dog profiles are not configured or the pets service has
+// no profile for the dog.
+func (ts *TrackingService) GetSessionDogProfile(sessionID string) (*models.DogProfile, error) {
+	if ts.dogProfiles == nil {
+		return nil, nil
+	}
+	session, err := ts.getSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.DogID() == "" {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ts.dogProfileTimeout)
+	defer cancel()
+	profile, err := ts.dogProfiles.GetDogProfile(ctx, session.DogID())
+	if errors.Is(err, ErrDogNotFound) {
+		return nil, nil
+	}
+	return profile, err
+}
+
+// dogProfile resolves the profile of the session's dog, returning nil on
+// failure. Failures other than a missing profile are logged.
+func (ts *TrackingService) dogProfile(session *models.TrackingSession) *models.DogProfile {
+	if session.DogID() == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ts.dogProfileTimeout)
+	defer cancel()
+	profile, err := ts.dogProfiles.GetDogProfile(ctx, session.DogID())
+	if err != nil && !errors.Is(err, ErrDogNotFound) {
+		ts.logger.Warn("Dog profile lookup failed",
+			zap.String("sessionID", session.ID),
+			zap.String("dogID", session.DogID()),
+			zap.Error(err),
+		)
+	}
+	return profile
+}
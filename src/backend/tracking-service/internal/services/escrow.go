@@ -0,0 +1,488 @@
+package services
+
+import (
+	// aes and cipher for wrapping escrowed keys with AES-256-GCM (go1.21)
+	"crypto/aes"
+	"crypto/cipher"
+	// ecdh for the X25519 escrow key pair (go1.21)
+	"crypto/ecdh"
+	// rand for ephemeral keys, nonces and custodian shares (go1.21)
+	"crypto/rand"
+	// sha256 for deriving wrapping keys from X25519 secrets (go1.21)
+	"crypto/sha256"
+	// subtle for comparing the reconstructed escrow key in constant time (go1.21)
+	"crypto/subtle"
+	// base64 for encoding keys and custodian shares (go1.21)
+	"encoding/base64"
+	// json for encoding tenant notifications (go1.21)
+	"encoding/json"
+	// errors for the escrow sentinels (go1.21)
+	"errors"
+	// fmt for wrapping errors (go1.21)
+	"fmt"
+	// time for escrow access timestamps (go1.21)
+	"time"
+
+	// prometheus for key escrow metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// models package that includes the escrow structs
+	"src/backend/tracking-service/internal/models"
+
+	// utils package for MQTT message classes
+	"src/backend/tracking-service/internal/utils"
+)
+
+// TenantEscrowTopicFormat is the MQTT topic on which a tenant's administrators
+// are notified of every escrow access to their walks, by tenant ID.
+const TenantEscrowTopicFormat = "tenants/%s/escrow/access"
+
+// escrowKeyInfo separates escrow wrapping keys from other uses of the X25519
+// secrets they are derived from.
+const escrowKeyInfo = "tracking-service stream key escrow v1"
+
+var (
+	// ErrKeyEscrowDisabled is returned by escrow access when no escrow is
+	// configured.
+	ErrKeyEscrowDisabled = errors.New("key escrow is not enabled")
+
+	// ErrInvalidEscrowRequest wraps the validation failures of an escrow
+	// access request.
+	ErrInvalidEscrowRequest = errors.New("invalid escrow access request")
+
+	// ErrEscrowSharesRejected is returned when the custodian shares presented
+	// are incomplete or do not reconstruct the escrow private key.
+	ErrEscrowSharesRejected = errors.New("custodian shares do not reconstruct the escrow key")
+
+	// ErrNoEscrowedKeys is returned when no stream key of the session was
+	// escrowed, e.g. because it was never shared.
+	ErrNoEscrowedKeys = errors.New("no stream keys are escrowed for the session")
+
+	// ErrInvalidEscrowSettings is returned when the escrow public key is not
+	// a base64 X25519 key or fewer than two distinct custodians are named.
+	ErrInvalidEscrowSettings = errors.New("key escrow needs a base64 X25519 public key and at least two distinct custodians")
+)
+
+// KeyEscrowStore persists escrowed stream keys and the escrow audit log. It
+// is implemented by repository.TimescaleRepository.
+type KeyEscrowStore interface {
+	// SaveEscrowedKey stores a wrapped stream key.
+	SaveEscrowedKey(key models.EscrowedStreamKey) error
+
+	// GetEscrowedKeys returns the wrapped stream keys of a session, oldest
+	// first.
+	GetEscrowedKeys(sessionID string) ([]models.EscrowedStreamKey, error)
+
+	// SaveEscrowAccess appends an entry to the escrow audit log.
+	SaveEscrowAccess(record models.EscrowAccessRecord) error
+
+	// GetEscrowAccessLog returns the escrow audit log of a tenant, or of all
+	// tenants when tenantID is empty, newest first.
+	GetEscrowAccessLog(tenantID string, limit int) ([]models.EscrowAccessRecord, error)
+}
+
+// StreamKeyEscrow keeps a copy of every session stream key so the live frames
+// of a walk can be decrypted under a legal request. Keys are wrapped under an
+// X25519 public key whose private key the service never holds: it is split
+// into one share per custodian, all of which must be presented together to
+// release a walk's keys, so no single person can decrypt a walk. Every access
+// attempt is written to the escrow audit log before any key is released, and
+// notified to the walk's tenant.
+type StreamKeyEscrow struct {
+	publicKey  *ecdh.PublicKey
+	custodians []string
+	store      KeyEscrowStore
+
+	escrowed prometheus.Counter
+	failures prometheus.Counter
+	accesses *prometheus.CounterVec
+}
+
+// EscrowAccessResult is the outcome of a granted escrow access: the stream
+// keys the session was encrypted with, oldest first, and the audit log entry
+// recording the access.
+type EscrowAccessResult struct {
+	AccessID  string      `json:"accessId"`
+	SessionID string      `json:"sessionId"`
+	Keys      []StreamKey `json:"keys"`
+}
+
+// NewStreamKeyEscrow creates an escrow wrapping keys under the base64 X25519
+// publicKey, whose private key is split among custodians, and keeping them in
+// store. Its metrics are registered on registry when non-nil.
+func NewStreamKeyEscrow(publicKey string, custodians []string, store KeyEscrowStore, registry *prometheus.Registry) (*StreamKeyEscrow, error) {
+	raw, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil {
+		return nil, ErrInvalidEscrowSettings
+	}
+	key, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, ErrInvalidEscrowSettings
+	}
+	if !validCustodians(custodians) {
+		return nil, ErrInvalidEscrowSettings
+	}
+
+	e := &StreamKeyEscrow{
+		publicKey:  key,
+		custodians: append([]string(nil), custodians...),
+		store:      store,
+		escrowed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tracking_escrow_keys_escrowed_total",
+			Help: "Session stream keys wrapped and stored in key escrow",
+		}),
+		failures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tracking_escrow_failures_total",
+			Help: "Session stream keys that could not be escrowed and were discarded",
+		}),
+		accesses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tracking_escrow_accesses_total",
+			Help: "Escrow access attempts under legal requests, by outcome",
+		}, []string{"outcome"}),
+	}
+	if registry != nil {
+		registry.MustRegister(e.escrowed, e.failures, e.accesses)
+	}
+	return e, nil
+}
+
+// GenerateEscrowKey creates an escrow key pair, returning the base64 public
+// key to configure and one base64 share of the private key per custodian. The
+// private key is the XOR of all shares and is not kept.
+func GenerateEscrowKey(custodians []string) (string, []models.EscrowShare, error) {
+	if !validCustodians(custodians) {
+		return "", nil, ErrInvalidEscrowSettings
+	}
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate escrow key: %w", err)
+	}
+	remainder := private.Bytes()
+	shares := make([]models.EscrowShare, len(custodians))
+	for i, custodian := range custodians {
+		share := remainder
+		if i < len(custodians)-1 {
+			share = make([]byte, len(remainder))
+			if _, err := rand.Read(share); err != nil {
+				return "", nil, fmt.Errorf("failed to generate escrow share: %w", err)
+			}
+			for j := range remainder {
+				remainder[j] ^= share[j]
+			}
+		}
+		shares[i] = models.EscrowShare{Custodian: custodian, Share: base64.StdEncoding.EncodeToString(share)}
+	}
+	return base64.StdEncoding.EncodeToString(private.PublicKey().Bytes()), shares, nil
+}
+
+// validCustodians reports whether custodians names at least two distinct
+// custodians.
+func validCustodians(custodians []string) bool {
+	seen := make(map[string]bool, len(custodians))
+	for _, c := range custodians {
+		if c == "" || seen[c] {
+			return false
+		}
+		seen[c] = true
+	}
+	return len(custodians) >= 2
+}
+
+// wrap seals key under the escrow public key with a fresh ephemeral key.
+func (e *StreamKeyEscrow) wrap(sessionID, tenantID string, key StreamKey) (models.EscrowedStreamKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(key.Key)
+	if err != nil {
+		return models.EscrowedStreamKey{}, fmt.Errorf("invalid stream key: %w", err)
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return models.EscrowedStreamKey{}, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+	secret, err := ephemeral.ECDH(e.publicKey)
+	if err != nil {
+		return models.EscrowedStreamKey{}, err
+	}
+	aead, err := escrowCipher(secret, ephemeral.PublicKey().Bytes(), e.publicKey.Bytes())
+	if err != nil {
+		return models.EscrowedStreamKey{}, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return models.EscrowedStreamKey{}, fmt.Errorf("failed to generate escrow nonce: %w", err)
+	}
+	return models.EscrowedStreamKey{
+		SessionID:    sessionID,
+		TenantID:     tenantID,
+		KeyID:        key.KeyID,
+		EphemeralKey: ephemeral.PublicKey().Bytes(),
+		Nonce:        nonce,
+		WrappedKey:   aead.Seal(nil, nonce, raw, []byte(sessionID+"."+key.KeyID)),
+		IssuedAt:     key.IssuedAt,
+	}, nil
+}
+
+// unwrap opens an escrowed key with the reconstructed escrow private key.
+func (e *StreamKeyEscrow) unwrap(private *ecdh.PrivateKey, escrowed models.EscrowedStreamKey) (StreamKey, error) {
+	ephemeral, err := ecdh.X25519().NewPublicKey(escrowed.EphemeralKey)
+	if err != nil {
+		return StreamKey{}, fmt.Errorf("invalid ephemeral key of stream key %s: %w", escrowed.KeyID, err)
+	}
+	secret, err := private.ECDH(ephemeral)
+	if err != nil {
+		return StreamKey{}, err
+	}
+	aead, err := escrowCipher(secret, escrowed.EphemeralKey, e.publicKey.Bytes())
+	if err != nil {
+		return StreamKey{}, err
+	}
+	raw, err := aead.Open(nil, escrowed.Nonce, escrowed.WrappedKey, []byte(escrowed.SessionID+"."+escrowed.KeyID))
+	if err != nil {
+		return StreamKey{}, fmt.Errorf("failed to unwrap stream key %s: %w", escrowed.KeyID, err)
+	}
+	return StreamKey{
+		KeyID:     escrowed.KeyID,
+		Algorithm: StreamKeyAlgorithm,
+		Key:       base64.StdEncoding.EncodeToString(raw),
+		IssuedAt:  escrowed.IssuedAt,
+	}, nil
+}
+
+// escrowCipher derives the AES-256-GCM cipher wrapping a key from an X25519
+// secret and the public keys of the exchange.
+func escrowCipher(secret, ephemeralKey, recipientKey []byte) (cipher.AEAD, error) {
+	h := sha256.New()
+	h.Write([]byte(escrowKeyInfo))
+	h.Write(secret)
+	h.Write(ephemeralKey)
+	h.Write(recipientKey)
+	block, err := aes.NewCipher(h.Sum(nil))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create escrow cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// reconstruct combines the custodian shares into the escrow private key. It
+// returns ErrEscrowSharesRejected unless every custodian presented exactly one
+// well-formed share and together they match the escrow public key.
+func (e *StreamKeyEscrow) reconstruct(shares []models.EscrowShare) (*ecdh.PrivateKey, error) {
+	byCustodian := make(map[string]string, len(shares))
+	for _, s := range shares {
+		if _, dup := byCustodian[s.Custodian]; dup {
+			return nil, ErrEscrowSharesRejected
+		}
+		byCustodian[s.Custodian] = s.Share
+	}
+	if len(byCustodian) != len(e.custodians) {
+		return nil, ErrEscrowSharesRejected
+	}
+
+	combined := make([]byte, 32)
+	defer clear(combined)
+	for _, custodian := range e.custodians {
+		encoded, ok := byCustodian[custodian]
+		if !ok {
+			return nil, ErrEscrowSharesRejected
+		}
+		share, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(share) != len(combined) {
+			return nil, ErrEscrowSharesRejected
+		}
+		for i := range combined {
+			combined[i] ^= share[i]
+		}
+	}
+	private, err := ecdh.X25519().NewPrivateKey(combined)
+	if err != nil {
+		return nil, ErrEscrowSharesRejected
+	}
+	if subtle.ConstantTimeCompare(private.PublicKey().Bytes(), e.publicKey.Bytes()) != 1 {
+		return nil, ErrEscrowSharesRejected
+	}
+	return private, nil
+}
+
+// ---------------------------------------------------------------------------
+// TrackingService Integration
+// ---------------------------------------------------------------------------
+
+// SetKeyEscrow escrows every stream key the stream keyring issues and enables
+// escrow access. It must be called after SetStreamKeyring. Passing nil
+// disables it.
+func (ts *TrackingService) SetKeyEscrow(escrow *StreamKeyEscrow) {
+	ts.keyEscrow = escrow
+	if ts.streamKeys == nil {
+		return
+	}
+	if escrow == nil {
+		ts.streamKeys.SetEscrow(nil)
+		return
+	}
+	ts.streamKeys.SetEscrow(ts.escrowStreamKey)
+}
+
+// escrowStreamKey wraps and stores a session's newly issued stream key with
+// the session's tenant.
+func (ts *TrackingService) escrowStreamKey(sessionID string, key StreamKey) error {
+	e := ts.keyEscrow
+	tenantID := ""
+	if session, err := ts.getSession(sessionID); err == nil {
+		tenantID = session.TenantID()
+	}
+	escrowed, err := e.wrap(sessionID, tenantID, key)
+	if err == nil {
+		err = e.store.SaveEscrowedKey(escrowed)
+	}
+	if err != nil {
+		e.failures.Inc()
+		ts.logger.Error("Failed to escrow stream key",
+			zap.String("sessionID", sessionID),
+			zap.String("keyID", key.KeyID),
+			zap.Error(err),
+		)
+		return err
+	}
+	e.escrowed.Inc()
+	return nil
+}
+
+// AccessEscrowedKeys releases the stream keys a walk's session was encrypted
+// with, under the legal request described by req, once every custodian has
+// presented their share. Every attempt with a legal reference is recorded in
+// the escrow audit log, and notified to the walk's tenant, whatever its
+// outcome; keys are never released when the access could not be recorded.
+//
+// Steps:
+//  1. Load the session's escrowed keys, which name its tenant
+//  2. Reconstruct the escrow private key from the shares and unwrap the keys
+//  3. Record the access in the audit log
+//  4. Notify the tenant and return the keys
+func (ts *TrackingService) AccessEscrowedKeys(sessionID string, req models.EscrowAccessRequest) (*EscrowAccessResult, error) {
+	e := ts.keyEscrow
+	if e == nil {
+		return nil, ErrKeyEscrowDisabled
+	}
+	if err := req.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEscrowRequest, err)
+	}
+
+	escrowed, err := e.store.GetEscrowedKeys(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	record := models.EscrowAccessRecord{
+		ID:             models.NewID(),
+		SessionID:      sessionID,
+		LegalReference: req.LegalReference,
+		RequestedBy:    req.RequestedBy,
+		Custodians:     make([]string, 0, len(req.Shares)),
+		AccessedAt:     time.Now().UTC(),
+	}
+	for _, s := range req.Shares {
+		record.Custodians = append(record.Custodians, s.Custodian)
+	}
+
+	var keys []StreamKey
+	var accessErr error
+	if len(escrowed) == 0 {
+		record.Outcome = models.EscrowAccessNotFound
+		accessErr = ErrNoEscrowedKeys
+	} else {
+		record.TenantID = escrowed[0].TenantID
+		keys, accessErr = e.unwrapAll(req.Shares, escrowed)
+		switch {
+		case accessErr == nil:
+			record.Outcome = models.EscrowAccessGranted
+			record.KeyCount = len(keys)
+		case errors.Is(accessErr, ErrEscrowSharesRejected):
+			record.Outcome = models.EscrowAccessDenied
+		default:
+			record.Outcome = models.EscrowAccessFailed
+		}
+	}
+
+	if err := e.store.SaveEscrowAccess(record); err != nil {
+		return nil, fmt.Errorf("failed to record escrow access: %w", err)
+	}
+	e.accesses.WithLabelValues(record.Outcome).Inc()
+	ts.logger.Warn("Escrowed stream keys accessed",
+		zap.String("accessID", record.ID),
+		zap.String("sessionID", sessionID),
+		zap.String("tenantID", record.TenantID),
+		zap.String("legalReference", record.LegalReference),
+		zap.String("requestedBy", record.RequestedBy),
+		zap.Strings("custodians", record.Custodians),
+		zap.String("outcome", record.Outcome),
+	)
+	ts.notifyEscrowAccess(record)
+
+	if accessErr != nil {
+		return nil, accessErr
+	}
+	return &EscrowAccessResult{AccessID: record.ID, SessionID: sessionID, Keys: keys}, nil
+}
+
+// unwrapAll reconstructs the escrow private key from shares and unwraps every
+// escrowed key with it.
+func (e *StreamKeyEscrow) unwrapAll(shares []models.EscrowShare, escrowed []models.EscrowedStreamKey) ([]StreamKey, error) {
+	private, err := e.reconstruct(shares)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]StreamKey, 0, len(escrowed))
+	for _, k := range escrowed {
+		key, err := e.unwrap(private, k)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// notifyEscrowAccess publishes an escrow audit log entry to its tenant's
+// administrators. Accesses to walks without a tenant, or whose tenant is
+// unknown because nothing was escrowed, are only logged. Failures are logged
+// and otherwise ignored; the entry is already recorded.
+func (ts *TrackingService) notifyEscrowAccess(record models.EscrowAccessRecord) {
+	if ts.mqttClient == nil || record.TenantID == "" {
+		return
+	}
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	topic := fmt.Sprintf(TenantEscrowTopicFormat, record.TenantID)
+	if err := ts.mqttClient.Publish(utils.MessageClassAlert, topic, payload); err != nil {
+		ts.logger.Error("Failed to notify tenant of escrow access",
+			zap.String("accessID", record.ID),
+			zap.String("tenantID", record.TenantID),
+			zap.Error(err),
+		)
+	}
+}
+
+// GetEscrowAccessLog lists the escrow audit log of a tenant, or of all tenants
+// when tenantID is empty, newest first.
+func (ts *TrackingService) GetEscrowAccessLog(tenantID string, limit int) ([]models.EscrowAccessRecord, error) {
+	if ts.keyEscrow == nil {
+		return nil, ErrKeyEscrowDisabled
+	}
+	if limit <= 0 {
+		limit = models.DefaultEscrowAuditLimit
+	}
+	if limit > models.MaxEscrowAuditLimit {
+		limit = models.MaxEscrowAuditLimit
+	}
+	records, err := ts.keyEscrow.store.GetEscrowAccessLog(tenantID, limit)
+	if err != nil {
+		return nil, err
+	}
+	if records == nil {
+		records = []models.EscrowAccessRecord{}
+	}
+	return records, nil
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	// errors for sentinel event sourcing errors (standard library)
+	"errors"
+	// fmt for formatting error messages (standard library)
+	"fmt"
+	// strconv for encoding event sequences in page cursors (standard library)
+	"strconv"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// models package that includes the TrackingSession and SessionStateEvent structs
+	"src/backend/tracking-service/internal/models"
+)
+
+var (
+	// ErrEventSourcingDisabled is returned by event stream queries when no event
+	// store is configured.
+	ErrEventSourcingDisabled = errors.New("event sourcing is not enabled")
+
+	// ErrNoSessionEvents is returned by RebuildSession when no events were
+	// recorded for the session.
+	ErrNoSessionEvents = errors.New("no events recorded for session")
+)
+
+// SessionEventStore persists the append-only stream of session state events.
+// It is implemented by repository.TimescaleRepository.
+type SessionEventStore interface {
+	// AppendSessionEvent appends an event and sets its assigned Sequence.
+	AppendSessionEvent(evt *models.SessionStateEvent) error
+	// GetSessionEvents returns a session's events in append order.
+	GetSessionEvents(sessionID string) ([]models.SessionStateEvent, error)
+	// GetSessionEventsPage returns up to limit of a session's events after
+	// the cursor, ordered by occurrence then sequence.
+	GetSessionEventsPage(sessionID string, after models.PageCursor, limit int) ([]models.SessionStateEvent, error)
+}
+
+// SetSessionEventStore enables event sourcing mode: every session state
+// transition and accepted location batch is appended to store. Passing nil
+// disables it.
+func (ts *TrackingService) SetSessionEventStore(store SessionEventStore) {
+	ts.eventStore = store
+}
+
+// GetRawSessionEvents returns the recorded event stream for a session, for
+// audits and debugging.
+func (ts *TrackingService) GetRawSessionEvents(sessionID string) ([]models.SessionStateEvent, error) {
+	if ts.eventStore == nil {
+		return nil, ErrEventSourcingDisabled
+	}
+	return ts.eventStore.GetSessionEvents(sessionID)
+}
+
+// GetRawSessionEventsPage returns a page of the recorded event stream for a
+// session, oldest first, with the cursor of the next page, or nil on the last
+// one. A limit of zero uses DefaultPageSize.
+func (ts *TrackingService) GetRawSessionEventsPage(sessionID string, after models.PageCursor, limit int) ([]models.SessionStateEvent, *models.PageCursor, error) {
+	if ts.eventStore == nil {
+		return nil, nil, ErrEventSourcingDisabled
+	}
+	limit = pageLimit(limit)
+	events, err := ts.eventStore.GetSessionEventsPage(sessionID, after, limit+1)
+	if err != nil {
+		return nil, nil, err
+	}
+	var next *models.PageCursor
+	if len(events) > limit {
+		events = events[:limit]
+		last := events[limit-1]
+		next = &models.PageCursor{Timestamp: last.OccurredAt, ID: strconv.FormatInt(last.Sequence, 10)}
+	}
+	if events == nil {
+		events = []models.SessionStateEvent{}
+	}
+	return events, next, nil
+}
+
+// RebuildSession reconstructs a session from its event stream and, unless it
+// has completed, restores it into activeSessions. This recovers precise state
+// after a crash or after a bug corrupted the in-memory copy.
+//
+// Steps:
+//  1. Load the session's events from the store
+//  2. Fold them into a fresh TrackingSession
+//  3. Replace any in-memory copy of a still-running session
+func (ts *TrackingService) RebuildSession(sessionID string) (*models.TrackingSession, error) {
+	events, err := ts.GetRawSessionEvents(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, ErrNoSessionEvents
+	}
+	session, err := models.RebuildTrackingSession(events)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rebuild session %s: %w", sessionID, err)
+	}
+
+	if session.Status() != models.SessionStatusCompleted {
+		ts.activeSessions.Store(session.ID, session)
+	}
+	ts.logger.Info("Session rebuilt from event stream",
+		zap.String("sessionID", sessionID),
+		zap.Int("events", len(events)),
+		zap.String("status", session.Status()),
+	)
+	return session, nil
+}
+
+// recordStateEvent appends a state transition to the event stream when event
+// sourcing is enabled. Failures are logged; they never fail the live operation.
+func (ts *TrackingService) recordStateEvent(session *models.TrackingSession, eventType string, locations []models.Location) {
+	if ts.eventStore == nil {
+		return
+	}
+	evt, err := models.NewSessionStateEvent(session, eventType, locations)
+	if err == nil {
+		err = ts.eventStore.AppendSessionEvent(evt)
+	}
+	if err != nil {
+		ts.logger.Error("Failed to record session state event",
+			zap.String("sessionID", session.ID),
+			zap.String("eventType", eventType),
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	// errors for the export sentinels (go1.21)
+	"errors"
+	// fmt for wrapping repository errors (go1.21)
+	"fmt"
+
+	// models package that includes the Location struct
+	"src/backend/tracking-service/internal/models"
+	// utils package providing the GPX encoder
+	"src/backend/tracking-service/internal/utils"
+)
+
+// ErrHistoryExportDisabled is returned by history export when no export store
+// is configured.
+var ErrHistoryExportDisabled = errors.New("location history export is not enabled")
+
+// HistoryExportStore loads the persisted track of a walk in time order. It is
+// implemented by repository.TimescaleRepository.
+type HistoryExportStore interface {
+	// GetLocationHistory returns the full persisted track of a walk.
+	GetLocationHistory(walkID string) ([]models.Location, error)
+}
+
+// SetHistoryExportStore enables exporting walk histories from the database.
+// Passing nil disables it.
+func (ts *TrackingService) SetHistoryExportStore(store HistoryExportStore) {
+	ts.exportStore = store
+}
+
+// ExportWalkGPX encodes the persisted track of a session's walk as a GPX 1.1
+// document, for import into fitness apps. Unlike FIT export it always reads the
+// database, so the file carries every stored point with its elevation and
+// accuracy, whether or not the walk has ended.
+//
+// Steps:
+//  1. Resolve the session, rebuilding a finished one from its event stream
+//  2. Load the walk's persisted track
+//  3. Encode the track as GPX
+func (ts *TrackingService) ExportWalkGPX(sessionID string) ([]byte, error) {
+	if ts.exportStore == nil {
+		return nil, ErrHistoryExportDisabled
+	}
+	session, err := ts.resolveSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	track, err := ts.exportStore.GetLocationHistory(session.WalkID())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load history of walk %s: %w", session.WalkID(), err)
+	}
+	if len(track) == 0 {
+		return nil, ErrSessionTrackNotFound
+	}
+	return utils.EncodeWalkGPX("Walk "+session.WalkID(), track)
+}
@@ -0,0 +1,332 @@
+package services
+
+import (
+	// bytes for building multipart upload bodies (go1.21)
+	"bytes"
+	// context for bounding upload and refresh requests (go1.21)
+	"context"
+	// json for decoding OAuth token responses (go1.21)
+	"encoding/json"
+	// errors for sentinel export errors (go1.21)
+	"errors"
+	// fmt for formatting error messages (go1.21)
+	"fmt"
+	// io for draining error response bodies (go1.21)
+	"io"
+	// multipart for the Strava upload form (go1.21)
+	"mime/multipart"
+	// http for calling fitness platform APIs (go1.21)
+	"net/http"
+	// url for OAuth refresh form encoding (go1.21)
+	"net/url"
+	// strings for request bodies (go1.21)
+	"strings"
+	// time for token expiry and request timeouts (go1.21)
+	"time"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// models package that includes the TrackingSession and FitnessToken structs
+	"src/backend/tracking-service/internal/models"
+	// utils package providing the FIT encoder
+	"src/backend/tracking-service/internal/utils"
+)
+
+// stravaUploadURL and stravaTokenURL are the Strava API endpoints used for uploads.
+const (
+	stravaUploadURL = "https://www.strava.com/api/v3/uploads"
+	stravaTokenURL  = "https://www.strava.com/oauth/token"
+)
+
+// fitnessTokenRefreshMargin refreshes access tokens that expire within this window,
+// so they cannot expire mid-upload.
+const fitnessTokenRefreshMargin = 2 * time.Minute
+
+// ErrSessionTrackNotFound is returned by FIT export when the session is neither
+// active nor recoverable from the event stream.
+var ErrSessionTrackNotFound = errors.New("session track not found")
+
+// ErrFitnessIntegrationDisabled is returned by token operations when automatic
+// fitness uploads are not configured.
+var ErrFitnessIntegrationDisabled = errors.New("fitness integration is not enabled")
+
+// FitnessTokenStore persists owners' fitness platform tokens. It is implemented
+// by repository.TimescaleRepository.
+type FitnessTokenStore interface {
+	// SaveFitnessToken inserts or replaces an owner's tokens for a provider.
+	SaveFitnessToken(token *models.FitnessToken) error
+	// GetFitnessTokensForDog returns every token set up to receive the dog's walks.
+	GetFitnessTokensForDog(dogID string) ([]models.FitnessToken, error)
+}
+
+// FitnessUploader uploads FIT files to one fitness platform.
+type FitnessUploader interface {
+	// Provider returns the provider name tokens are stored under.
+	Provider() string
+	// Refresh exchanges the token's refresh token for new access credentials in place.
+	Refresh(ctx context.Context, token *models.FitnessToken) error
+	// Upload submits a FIT file as a new activity with the given name.
+	Upload(ctx context.Context, token *models.FitnessToken, name string, fit []byte) error
+}
+
+// SetFitnessIntegration enables automatic upload of completed walks to the
+// platforms owners have connected. Tokens for providers without an uploader
+// are skipped. Passing a nil store disables uploads.
+func (ts *TrackingService) SetFitnessIntegration(store FitnessTokenStore, timeout time.Duration, uploaders ...FitnessUploader) {
+	ts.fitnessStore = store
+	ts.fitnessTimeout = timeout
+	ts.fitnessUploaders = make(map[string]FitnessUploader, len(uploaders))
+	for _, u := range uploaders {
+		ts.fitnessUploaders[u.Provider()] = u
+	}
+}
+
+// SaveFitnessToken stores an owner's OAuth tokens for a fitness platform.
+func (ts *TrackingService) SaveFitnessToken(token *models.FitnessToken) error {
+	if ts.fitnessStore == nil {
+		return ErrFitnessIntegrationDisabled
+	}
+	return ts.fitnessStore.SaveFitnessToken(token)
+}
+
+// ExportSessionFIT encodes a session's track as a FIT file.
+//
+// Steps:
+//  1. Resolve the session from activeSessions, or rebuild a finished one from
+//     its event stream when event sourcing is enabled
+//  2. Load the full track, falling back to persisted history if truncated
+//  3. Encode the track as a FIT activity
+func (ts *TrackingService) ExportSessionFIT(sessionID string) ([]byte, error) {
+	session, err := ts.resolveSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	track, err := ts.fullLocationHistory(session)
+	if err != nil {
+		return nil, err
+	}
+	if len(track) == 0 {
+		return nil, ErrSessionTrackNotFound
+	}
+	return utils.EncodeWalkFIT(track)
+}
+
+// resolveSession returns the session from activeSessions, or rebuilds a finished
+// one from its event stream when event sourcing is enabled. Unknown sessions
+// return ErrSessionTrackNotFound.
+func (ts *TrackingService) resolveSession(sessionID string) (*models.TrackingSession, error) {
+	session, err := ts.getSession(sessionID)
+	if err == nil {
+		return session, nil
+	}
+	if ts.eventStore == nil {
+		return nil, ErrSessionTrackNotFound
+	}
+	events, err := ts.eventStore.GetSessionEvents(sessionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load events for session %s: %w", sessionID, err)
+	}
+	if len(events) == 0 {
+		return nil, ErrSessionTrackNotFound
+	}
+	if session, err = models.RebuildTrackingSession(events); err != nil {
+		return nil, fmt.Errorf("failed to rebuild session %s: %w", sessionID, err)
+	}
+	return session, nil
+}
+
+// fullLocationHistory returns the session's whole track, loading the persisted
+// history when older points have been evicted from the in-memory window.
+func (ts *TrackingService) fullLocationHistory(session *models.TrackingSession) ([]models.Location, error) {
+	if !session.HistoryTruncated() || ts.territoryStore == nil {
+		return session.LocationHistory(), nil
+	}
+	persisted, err := ts.territoryStore.GetLocationHistory(session.WalkID())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load full history for walk %s: %w", session.WalkID(), err)
+	}
+	return persisted, nil
+}
+
+// uploadCompletedWalk uploads a completed session's FIT file to every platform
+// connected for its dog. It runs in the background; failures are logged per
+// provider and never affect the session.
+func (ts *TrackingService) uploadCompletedWalk(session *models.TrackingSession) {
+	if ts.fitnessStore == nil || len(ts.fitnessUploaders) == 0 {
+		return
+	}
+
+	tokens, err := ts.fitnessStore.GetFitnessTokensForDog(session.DogID())
+	if err != nil {
+		ts.logger.Warn("Failed to load fitness tokens", zap.String("dogID", session.DogID()), zap.Error(err))
+		return
+	}
+	if len(tokens) == 0 {
+		return
+	}
+
+	track, err := ts.fullLocationHistory(session)
+	if err == nil && len(track) == 0 {
+		err = ErrSessionTrackNotFound
+	}
+	var fit []byte
+	if err == nil {
+		fit, err = utils.EncodeWalkFIT(track)
+	}
+	if err != nil {
+		ts.logger.Warn("Failed to encode walk for fitness upload", zap.String("sessionID", session.ID), zap.Error(err))
+		return
+	}
+
+	name := fmt.Sprintf("Dog walk %s", track[0].Timestamp.Format("2006-01-02 15:04"))
+	for i := range tokens {
+		token := &tokens[i]
+		uploader, ok := ts.fitnessUploaders[token.Provider]
+		if !ok {
+			continue
+		}
+		if err := ts.uploadWithToken(uploader, token, name, fit); err != nil {
+			ts.logger.Warn("Fitness platform upload failed",
+				zap.String("sessionID", session.ID),
+				zap.String("ownerID", token.OwnerID),
+				zap.String("provider", token.Provider),
+				zap.Error(err),
+			)
+			continue
+		}
+		ts.logger.Info("Walk uploaded to fitness platform",
+			zap.String("sessionID", session.ID),
+			zap.String("ownerID", token.OwnerID),
+			zap.String("provider", token.Provider),
+		)
+	}
+}
+
+// uploadWithToken refreshes an expiring token, persisting the new credentials,
+// and uploads the FIT file.
+func (ts *TrackingService) uploadWithToken(uploader FitnessUploader, token *models.FitnessToken, name string, fit []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ts.fitnessTimeout)
+	defer cancel()
+
+	if token.Expired(time.Now(), fitnessTokenRefreshMargin) {
+		if err := uploader.Refresh(ctx, token); err != nil {
+			return fmt.Errorf("token refresh failed: %w", err)
+		}
+		if err := ts.fitnessStore.SaveFitnessToken(token); err != nil {
+			ts.logger.Warn("Failed to persist refreshed fitness token",
+				zap.String("ownerID", token.OwnerID),
+				zap.String("provider", token.Provider),
+				zap.Error(err),
+			)
+		}
+	}
+	return uploader.Upload(ctx, token, name, fit)
+}
+
+// StravaUploader uploads FIT files through the Strava API.
+type StravaUploader struct {
+	clientID     string
+	clientSecret string
+	httpClient   *http.Client
+}
+
+// NewStravaUploader creates an uploader for the Strava OAuth application.
+func NewStravaUploader(clientID, clientSecret string, timeout time.Duration) *StravaUploader {
+	return &StravaUploader{
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		httpClient:   &http.Client{Timeout: timeout},
+	}
+}
+
+// Provider implements FitnessUploader.
+func (s *StravaUploader) Provider() string {
+	return models.FitnessProviderStrava
+}
+
+// Refresh implements FitnessUploader using the refresh_token grant.
+func (s *StravaUploader) Refresh(ctx context.Context, token *models.FitnessToken) error {
+	if token.RefreshToken == "" {
+		return fmt.Errorf("strava token for owner %s has no refresh token", token.OwnerID)
+	}
+	form := url.Values{
+		"client_id":     {s.clientID},
+		"client_secret": {s.clientSecret},
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {token.RefreshToken},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, stravaTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return stravaError(resp)
+	}
+
+	var refreshed struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresAt    int64  `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&refreshed); err != nil {
+		return fmt.Errorf("invalid strava token response: %w", err)
+	}
+	token.AccessToken = refreshed.AccessToken
+	if refreshed.RefreshToken != "" {
+		token.RefreshToken = refreshed.RefreshToken
+	}
+	token.ExpiresAt = time.Unix(refreshed.ExpiresAt, 0).UTC()
+	return nil
+}
+
+// Upload implements FitnessUploader. Strava processes uploads asynchronously;
+// an accepted upload is considered successful.
+func (s *StravaUploader) Upload(ctx context.Context, token *models.FitnessToken, name string, fit []byte) error {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	_ = form.WriteField("data_type", "fit")
+	_ = form.WriteField("name", name)
+	_ = form.WriteField("sport_type", "Walk")
+	part, err := form.CreateFormFile("file", "walk.fit")
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(fit); err != nil {
+		return err
+	}
+	if err := form.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, stravaUploadURL, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return stravaError(resp)
+	}
+	return nil
+}
+
+// stravaError builds an error from a failed Strava response.
+func stravaError(resp *http.Response) error {
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("strava returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	// fnv for stable rollout bucketing (go1.21)
+	"hash/fnv"
+	// sync for guarding runtime flag overrides and the watcher's stop signal (go1.21)
+	"sync"
+	// time for the log level refresh interval (go1.21)
+	"time"
+
+	// zap for structured logging and the live log level (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Feature flags gating pipeline stages during their gradual rollout.
+const (
+	FlagKalmanFilter     = "kalman-filter"
+	FlagDeltaEncoding    = "delta-encoding"
+	FlagAdaptiveSampling = "adaptive-sampling"
+)
+
+// FlagLogLevel is the string flag holding the service's log level.
+const FlagLogLevel = "log-level"
+
+// Rollout bucketing keys, matching config.FlagBySession and config.FlagByTenant.
+const (
+	FlagBySession = "session"
+	FlagByTenant  = "tenant"
+)
+
+// FlagSubject identifies what a flag is evaluated for. TenantID is the account
+// a session belongs to; the tracking service uses the session's walker.
+type FlagSubject struct {
+	TenantID  string
+	SessionID string
+}
+
+// FeatureFlags is a feature-flag client. Implementations may be backed by
+// static configuration or by a remote flags service; evaluation must be cheap
+// since it runs on the ingestion path.
+type FeatureFlags interface {
+	// Enabled reports whether flag is on for subject. Unknown flags are off.
+	Enabled(flag string, subject FlagSubject) bool
+
+	// Value returns a string-valued flag, or fallback when it is unset.
+	Value(flag, fallback string) string
+}
+
+// FlagRollout enables a flag for Percentage (0-100) of subjects, bucketed by
+// session or by tenant.
+type FlagRollout struct {
+	Percentage float64
+	By         string
+}
+
+// StaticFeatureFlags is a FeatureFlags backed by configuration, with runtime
+// overrides through SetRollout and SetValue.
+type StaticFeatureFlags struct {
+	mu       sync.RWMutex
+	rollouts map[string]FlagRollout
+	values   map[string]string
+}
+
+// NewStaticFeatureFlags creates a client from the configured rollouts and
+// string values.
+func NewStaticFeatureFlags(rollouts map[string]FlagRollout, values map[string]string) *StaticFeatureFlags {
+	f := &StaticFeatureFlags{
+		rollouts: make(map[string]FlagRollout, len(rollouts)),
+		values:   make(map[string]string, len(values)),
+	}
+	for name, rollout := range rollouts {
+		f.rollouts[name] = rollout
+	}
+	for name, value := range values {
+		f.values[name] = value
+	}
+	return f
+}
+
+// Enabled implements FeatureFlags. A subject falls inside a rollout when the
+// hash of the flag and its bucketing key lands below the percentage, so raising
+// the percentage only ever adds subjects.
+func (f *StaticFeatureFlags) Enabled(flag string, subject FlagSubject) bool {
+	f.mu.RLock()
+	rollout, ok := f.rollouts[flag]
+	f.mu.RUnlock()
+	if !ok || rollout.Percentage <= 0 {
+		return false
+	}
+	if rollout.Percentage >= 100 {
+		return true
+	}
+	key := subject.SessionID
+	if rollout.By == FlagByTenant {
+		key = subject.TenantID
+	}
+	if key == "" {
+		return false
+	}
+	return rolloutBucket(flag, key) < rollout.Percentage
+}
+
+// Value implements FeatureFlags.
+func (f *StaticFeatureFlags) Value(flag, fallback string) string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if value, ok := f.values[flag]; ok {
+		return value
+	}
+	return fallback
+}
+
+// SetRollout replaces the rollout of flag.
+func (f *StaticFeatureFlags) SetRollout(flag string, rollout FlagRollout) {
+	f.mu.Lock()
+	f.rollouts[flag] = rollout
+	f.mu.Unlock()
+}
+
+// SetValue replaces the value of a string flag.
+func (f *StaticFeatureFlags) SetValue(flag, value string) {
+	f.mu.Lock()
+	f.values[flag] = value
+	f.mu.Unlock()
+}
+
+// rolloutBucket maps flag and key to a stable position in [0, 100). Hashing the
+// flag with the key keeps the subjects of different flags independent.
+func rolloutBucket(flag, key string) float64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(flag))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(key))
+	return float64(h.Sum64()%10000) / 100
+}
+
+// ---------------------------------------------------------------------------
+// Log Level Watcher
+// ---------------------------------------------------------------------------
+
+// LogLevelWatcher applies the log-level flag to a live zap level, re-reading
+// it periodically so the level can be changed without a restart.
+type LogLevelWatcher struct {
+	flags    FeatureFlags
+	level    zap.AtomicLevel
+	interval time.Duration
+	logger   *zap.Logger
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+// NewLogLevelWatcher creates a watcher that refreshes level from flags every interval.
+func NewLogLevelWatcher(flags FeatureFlags, level zap.AtomicLevel, interval time.Duration, logger *zap.Logger) *LogLevelWatcher {
+	return &LogLevelWatcher{
+		flags:    flags,
+		level:    level,
+		interval: interval,
+		logger:   logger,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start applies the flag immediately and then on every interval in the background.
+func (w *LogLevelWatcher) Start() {
+	w.Refresh()
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				w.Refresh()
+			}
+		}
+	}()
+}
+
+// Stop ends background refreshing.
+func (w *LogLevelWatcher) Stop() {
+	w.stopOnce.Do(func() { close(w.stop) })
+}
+
+// Refresh reads the log-level flag and applies it if it changed. Unparseable
+// values are logged and ignored.
+func (w *LogLevelWatcher) Refresh() {
+	current := w.level.Level()
+	value := w.flags.Value(FlagLogLevel, current.String())
+
+	var next zapcore.Level
+	if err := next.UnmarshalText([]byte(value)); err != nil {
+		w.logger.Warn("Ignoring invalid log level flag", zap.String("value", value))
+		return
+	}
+	if next != current {
+		w.level.SetLevel(next)
+		w.logger.Info("Log level changed",
+			zap.String("from", current.String()),
+			zap.String("to", next.String()),
+		)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// TrackingService Integration
+// ---------------------------------------------------------------------------
+
+// SetFeatureFlags sets the client consulted by FeatureEnabled. Passing nil
+// turns every flagged stage off.
+func (ts *TrackingService) SetFeatureFlags(flags FeatureFlags) {
+	ts.flags = flags
+}
+
+// FeatureEnabled reports whether flag is rolled out to sessionID, with the
+// session's walker as its tenant. Pipeline stages under gradual rollout call it
+// instead of reading a boolean config toggle.
+func (ts *TrackingService) FeatureEnabled(flag, sessionID string) bool {
+	if ts.flags == nil {
+		return false
+	}
+	subject := FlagSubject{SessionID: sessionID}
+	if session, err := ts.getSession(sessionID); err == nil {
+		subject.TenantID = session.WalkerID()
+	}
+	return ts.flags.Enabled(flag, subject)
+}
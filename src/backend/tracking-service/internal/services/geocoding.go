@@ -0,0 +1,421 @@
+package services
+
+import (
+	// list for least-recently-used ordering of cached addresses (go1.21)
+	"container/list"
+	// context for bounding geocoding requests (go1.21)
+	"context"
+	// json for decoding provider responses (go1.21)
+	"encoding/json"
+	// errors for sentinel geocoding errors (go1.21)
+	"errors"
+	// fmt for formatting request URLs and error messages (go1.21)
+	"fmt"
+	// io for draining error response bodies (go1.21)
+	"io"
+	// math for rounding coordinates to cache keys (go1.21)
+	"math"
+	// http for calling geocoding APIs (go1.21)
+	"net/http"
+	// url for query encoding (go1.21)
+	"net/url"
+	// strings for trimming provider error bodies (go1.21)
+	"strings"
+	// sync for guarding the address cache (go1.21)
+	"sync"
+	// time for request timeouts and cache expiry (go1.21)
+	"time"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+	// prometheus for geocoding lookup metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+	// rate for limiting requests to the provider (golang.org/x/time/rate v0.3.0)
+	"golang.org/x/time/rate"
+
+	// models package that includes the TrackingSession and TrackingStatistics structs
+	"src/backend/tracking-service/internal/models"
+)
+
+// Reverse geocoding providers selectable by name.
+const (
+	GeocoderNominatim = "nominatim"
+	GeocoderGoogle    = "google"
+	GeocoderMapbox    = "mapbox"
+)
+
+// Provider API endpoints. Nominatim may be pointed at a self-hosted instance.
+const (
+	nominatimDefaultURL = "https://nominatim.openstreetmap.org"
+	googleGeocodeURL    = "https://maps.googleapis.com/maps/api/geocode/json"
+	mapboxGeocodeURL    = "https://api.mapbox.com/geocoding/v5/mapbox.places"
+)
+
+// geocodeCachePrecision is the number of decimal places coordinates are rounded
+// to for caching, about 11 m at the equator, so points at the same doorstep
+// share an entry.
+const geocodeCachePrecision = 4
+
+// ErrAddressNotFound is returned when the provider has no address for a point,
+// such as one in open water.
+var ErrAddressNotFound = errors.New("no address found for location")
+
+// ReverseGeocoder resolves coordinates to a human-readable address.
+type ReverseGeocoder interface {
+	// Provider returns the provider name, used to label metrics.
+	Provider() string
+	// ReverseGeocode returns the address nearest to lat/lon, or ErrAddressNotFound.
+	ReverseGeocode(ctx context.Context, lat, lon float64) (string, error)
+}
+
+// GeocoderOptions selects and configures a reverse geocoding provider.
+type GeocoderOptions struct {
+	// Provider is one of GeocoderNominatim, GeocoderGoogle or GeocoderMapbox.
+	Provider string
+	// APIKey is the Google API key or Mapbox access token; unused by Nominatim.
+	APIKey string
+	// BaseURL overrides the Nominatim endpoint, e.g. for a self-hosted instance.
+	BaseURL string
+	// UserAgent identifies the application, as the Nominatim usage policy requires.
+	UserAgent string
+	// Timeout bounds each HTTP request.
+	Timeout time.Duration
+}
+
+// NewReverseGeocoder creates the geocoder for opts.Provider.
+func NewReverseGeocoder(opts GeocoderOptions) (ReverseGeocoder, error) {
+	httpClient := &http.Client{Timeout: opts.Timeout}
+	switch opts.Provider {
+	case GeocoderNominatim:
+		baseURL := strings.TrimRight(opts.BaseURL, "/")
+		if baseURL == "" {
+			baseURL = nominatimDefaultURL
+		}
+		return &NominatimGeocoder{baseURL: baseURL, userAgent: opts.UserAgent, httpClient: httpClient}, nil
+	case GeocoderGoogle:
+		if opts.APIKey == "" {
+			return nil, fmt.Errorf("google geocoding requires an API key")
+		}
+		return &GoogleGeocoder{apiKey: opts.APIKey, httpClient: httpClient}, nil
+	case GeocoderMapbox:
+		if opts.APIKey == "" {
+			return nil, fmt.Errorf("mapbox geocoding requires an access token")
+		}
+		return &MapboxGeocoder{accessToken: opts.APIKey, httpClient: httpClient}, nil
+	default:
+		return nil, fmt.Errorf("unknown geocoding provider %q", opts.Provider)
+	}
+}
+
+// NominatimGeocoder resolves addresses through the OpenStreetMap Nominatim API.
+type NominatimGeocoder struct {
+	baseURL    string
+	userAgent  string
+	httpClient *http.Client
+}
+
+// Provider implements ReverseGeocoder.
+func (g *NominatimGeocoder) Provider() string {
+	return GeocoderNominatim
+}
+
+// ReverseGeocode implements ReverseGeocoder.
+func (g *NominatimGeocoder) ReverseGeocode(ctx context.Context, lat, lon float64) (string, error) {
+	query := url.Values{
+		"format": {"jsonv2"},
+		"lat":    {formatCoordinate(lat)},
+		"lon":    {formatCoordinate(lon)},
+	}
+	var result struct {
+		DisplayName string `json:"display_name"`
+		Error       string `json:"error"`
+	}
+	header := http.Header{}
+	if g.userAgent != "" {
+		header.Set("User-Agent", g.userAgent)
+	}
+	if err := getGeocodeJSON(ctx, g.httpClient, g.baseURL+"/reverse?"+query.Encode(), header, GeocoderNominatim, &result); err != nil {
+		return "", err
+	}
+	if result.DisplayName == "" {
+		return "", ErrAddressNotFound
+	}
+	return result.DisplayName, nil
+}
+
+// GoogleGeocoder resolves addresses through the Google Geocoding API.
+type GoogleGeocoder struct {
+	apiKey     string
+	httpClient *http.Client
+}
+
+// Provider implements ReverseGeocoder.
+func (g *GoogleGeocoder) Provider() string {
+	return GeocoderGoogle
+}
+
+// ReverseGeocode implements ReverseGeocoder.
+func (g *GoogleGeocoder) ReverseGeocode(ctx context.Context, lat, lon float64) (string, error) {
+	query := url.Values{
+		"latlng": {formatCoordinate(lat) + "," + formatCoordinate(lon)},
+		"key":    {g.apiKey},
+	}
+	var result struct {
+		Status       string `json:"status"`
+		ErrorMessage string `json:"error_message"`
+		Results      []struct {
+			FormattedAddress string `json:"formatted_address"`
+		} `json:"results"`
+	}
+	if err := getGeocodeJSON(ctx, g.httpClient, googleGeocodeURL+"?"+query.Encode(), nil, GeocoderGoogle, &result); err != nil {
+		return "", err
+	}
+	switch result.Status {
+	case "OK":
+		if len(result.Results) > 0 && result.Results[0].FormattedAddress != "" {
+			return result.Results[0].FormattedAddress, nil
+		}
+		return "", ErrAddressNotFound
+	case "ZERO_RESULTS":
+		return "", ErrAddressNotFound
+	default:
+		return "", fmt.Errorf("google geocoding returned %s: %s", result.Status, result.ErrorMessage)
+	}
+}
+
+// MapboxGeocoder resolves addresses through the Mapbox Geocoding API.
+type MapboxGeocoder struct {
+	accessToken string
+	httpClient  *http.Client
+}
+
+// Provider implements ReverseGeocoder.
+func (g *MapboxGeocoder) Provider() string {
+	return GeocoderMapbox
+}
+
+// ReverseGeocode implements ReverseGeocoder.
+func (g *MapboxGeocoder) ReverseGeocode(ctx context.Context, lat, lon float64) (string, error) {
+	query := url.Values{
+		"access_token": {g.accessToken},
+		"limit":        {"1"},
+	}
+	endpoint := fmt.Sprintf("%s/%s,%s.json?%s", mapboxGeocodeURL, formatCoordinate(lon), formatCoordinate(lat), query.Encode())
+	var result struct {
+		Features []struct {
+			PlaceName string `json:"place_name"`
+		} `json:"features"`
+	}
+	if err := getGeocodeJSON(ctx, g.httpClient, endpoint, nil, GeocoderMapbox, &result); err != nil {
+		return "", err
+	}
+	if len(result.Features) == 0 || result.Features[0].PlaceName == "" {
+		return "", ErrAddressNotFound
+	}
+	return result.Features[0].PlaceName, nil
+}
+
+// getGeocodeJSON issues a GET request to a provider and decodes a successful
+// JSON response into out.
+func getGeocodeJSON(ctx context.Context, client *http.Client, endpoint string, header http.Header, provider string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s geocoding returned %s: %s", provider, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid %s geocoding response: %w", provider, err)
+	}
+	return nil
+}
+
+// formatCoordinate renders a coordinate with enough precision for geocoding.
+func formatCoordinate(v float64) string {
+	return fmt.Sprintf("%.6f", v)
+}
+
+// geocodeCacheKey identifies a rounded coordinate pair.
+type geocodeCacheKey struct {
+	lat, lon int64
+}
+
+// geocodeCacheEntry is a cached lookup; an empty address caches a miss.
+type geocodeCacheEntry struct {
+	key      geocodeCacheKey
+	address  string
+	cachedAt time.Time
+}
+
+// CachingGeocoder wraps a ReverseGeocoder with a bounded least-recently-used
+// address cache and a request rate limit, so walks starting and ending at the
+// same homes rarely reach the provider and bursts of completions stay within
+// its usage policy. Misses are cached too.
+type CachingGeocoder struct {
+	next    ReverseGeocoder
+	limiter *rate.Limiter
+	size    int
+	ttl     time.Duration
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[geocodeCacheKey]*list.Element
+
+	lookups *prometheus.CounterVec
+}
+
+// NewCachingGeocoder wraps next with a cache of size entries kept for ttl and a
+// limit of ratePerSecond provider requests. Metrics are registered on registry
+// when it is non-nil.
+func NewCachingGeocoder(next ReverseGeocoder, size int, ttl time.Duration, ratePerSecond float64, registry *prometheus.Registry) (*CachingGeocoder, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("geocoding cache size must be at least 1")
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("geocoding cache TTL must be positive")
+	}
+	if ratePerSecond <= 0 {
+		return nil, fmt.Errorf("geocoding rate limit must be positive")
+	}
+	g := &CachingGeocoder{
+		next:    next,
+		limiter: rate.NewLimiter(rate.Limit(ratePerSecond), 1),
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[geocodeCacheKey]*list.Element),
+		lookups: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "tracking_geocode_lookups_total",
+				Help:        "Reverse geocoding lookups, by outcome (cache_hit, resolved, not_found or error).",
+				ConstLabels: prometheus.Labels{"provider": next.Provider()},
+			},
+			[]string{"outcome"},
+		),
+	}
+	if registry != nil {
+		registry.MustRegister(g.lookups)
+	}
+	return g, nil
+}
+
+// Provider implements ReverseGeocoder.
+func (g *CachingGeocoder) Provider() string {
+	return g.next.Provider()
+}
+
+// ReverseGeocode implements ReverseGeocoder. Uncached lookups wait for the rate
+// limiter, failing if ctx expires first.
+func (g *CachingGeocoder) ReverseGeocode(ctx context.Context, lat, lon float64) (string, error) {
+	scale := math.Pow10(geocodeCachePrecision)
+	key := geocodeCacheKey{lat: int64(math.Round(lat * scale)), lon: int64(math.Round(lon * scale))}
+
+	if address, ok := g.cached(key); ok {
+		g.lookups.WithLabelValues("cache_hit").Inc()
+		if address == "" {
+			return "", ErrAddressNotFound
+		}
+		return address, nil
+	}
+
+	if err := g.limiter.Wait(ctx); err != nil {
+		g.lookups.WithLabelValues("error").Inc()
+		return "", fmt.Errorf("geocoding rate limit: %w", err)
+	}
+	address, err := g.next.ReverseGeocode(ctx, lat, lon)
+	switch {
+	case errors.Is(err, ErrAddressNotFound):
+		g.lookups.WithLabelValues("not_found").Inc()
+		g.store(key, "")
+		return "", err
+	case err != nil:
+		g.lookups.WithLabelValues("error").Inc()
+		return "", err
+	}
+	g.lookups.WithLabelValues("resolved").Inc()
+	g.store(key, address)
+	return address, nil
+}
+
+// cached returns an unexpired cache entry, marking it most recently used.
+func (g *CachingGeocoder) cached(key geocodeCacheKey) (string, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	elem, ok := g.entries[key]
+	if !ok {
+		return "", false
+	}
+	entry := elem.Value.(*geocodeCacheEntry)
+	if time.Since(entry.cachedAt) > g.ttl {
+		g.order.Remove(elem)
+		delete(g.entries, key)
+		return "", false
+	}
+	g.order.MoveToFront(elem)
+	return entry.address, true
+}
+
+// store caches address for key, evicting the least recently used entry when full.
+func (g *CachingGeocoder) store(key geocodeCacheKey, address string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if elem, ok := g.entries[key]; ok {
+		entry := elem.Value.(*geocodeCacheEntry)
+		entry.address, entry.cachedAt = address, time.Now()
+		g.order.MoveToFront(elem)
+		return
+	}
+	g.entries[key] = g.order.PushFront(&geocodeCacheEntry{key: key, address: address, cachedAt: time.Now()})
+	if g.order.Len() > g.size {
+		oldest := g.order.Back()
+		g.order.Remove(oldest)
+		delete(g.entries, oldest.Value.(*geocodeCacheEntry).key)
+	}
+}
+
+// SetReverseGeocoder enables resolving the start and end addresses of completed
+// walks into their summaries, each lookup bounded by timeout. Passing nil
+// disables it.
+func (ts *TrackingService) SetReverseGeocoder(geocoder ReverseGeocoder, timeout time.Duration) {
+	ts.geocoder = geocoder
+	ts.geocodeTimeout = timeout
+}
+
+// geocodeSummary resolves the addresses of the first and last points of the
+// session's track onto stats. Failures are logged and leave the address empty.
+func (ts *TrackingService) geocodeSummary(session *models.TrackingSession, stats *models.TrackingStatistics) {
+	track, err := ts.fullLocationHistory(session)
+	if err != nil || len(track) == 0 {
+		ts.logger.Warn("No track to geocode for walk summary", zap.String("sessionID", session.ID), zap.Error(err))
+		return
+	}
+	stats.StartAddress = ts.reverseGeocode(session.ID, track[0])
+	stats.EndAddress = ts.reverseGeocode(session.ID, track[len(track)-1])
+}
+
+// reverseGeocode resolves one point's address, returning "" on failure.
+func (ts *TrackingService) reverseGeocode(sessionID string, loc models.Location) string {
+	ctx, cancel := context.WithTimeout(context.Background(), ts.geocodeTimeout)
+	defer cancel()
+	address, err := ts.geocoder.ReverseGeocode(ctx, loc.Latitude, loc.Longitude)
+	if err != nil && !errors.Is(err, ErrAddressNotFound) {
+		ts.logger.Warn("Reverse geocoding failed",
+			zap.String("sessionID", sessionID),
+			zap.String("provider", ts.geocoder.Provider()),
+			zap.Error(err),
+		)
+	}
+	return address
+}
@@ -51,8 +51,23 @@ type Geofence struct {
 
 	// RadiusKm represents the current radius of the geofence in kilometers. It must be between
 	// MinRadius and MaxRadius values and can be updated dynamically if the geofence remains active.
+	// For polygons it is the distance from the centroid to the farthest vertex and is not enforced.
 	RadiusKm float64
 
+	// Shape is models.GeofenceShapeCircle or models.GeofenceShapePolygon. An empty shape is a circle.
+	Shape string
+
+	// Vertices is the boundary of a polygon geofence, counter-clockwise and without repeating the
+	// first vertex. It is nil for circles.
+	Vertices []models.GeofenceVertex
+
+	// Mode is models.GeofenceModeInclusion, violated by leaving the zone, or
+	// models.GeofenceModeExclusion, violated by entering it. An empty mode is inclusion.
+	Mode string
+
+	// Severity is the tenant-configured severity of violations, one of the models.GeofenceSeverity values.
+	Severity string
+
 	// CreatedAt captures the timestamp of when this geofence was initially created.
 	CreatedAt time.Time
 
@@ -62,8 +77,15 @@ type Geofence struct {
 	// Active indicates whether the geofence is currently active. Once deactivated, it should not be updated further.
 	Active bool
 
-	// BoundaryViolations counts how many times a provided point was found to be outside this geofence boundary.
+	// BoundaryViolations counts how many times a provided point was found to violate this geofence, outside
+	// an inclusion zone or inside an exclusion zone, while the geofence was enforced.
 	BoundaryViolations int
+
+	// GroupID is the ID of the GeofenceGroup this geofence belongs to, if any.
+	GroupID string
+
+	// Schedule restricts when the geofence is enforced. A nil schedule means always enforced.
+	Schedule *GeofenceSchedule
 }
 
 // ValidateGeofenceParameters performs comprehensive validation for latitude, longitude, and radius
@@ -138,6 +160,9 @@ func NewGeofence(walkID string, latitude, longitude, radiusKm float64) (*Geofenc
 		CenterLatitude:    latitude,
 		CenterLongitude:   longitude,
 		RadiusKm:          finalRadius,
+		Shape:             models.GeofenceShapeCircle,
+		Mode:              models.GeofenceModeInclusion,
+		Severity:          models.GeofenceSeverityWarning,
 		CreatedAt:         nowUTC,
 		UpdatedAt:         nowUTC,
 		Active:            true,
@@ -147,14 +172,125 @@ func NewGeofence(walkID string, latitude, longitude, radiusKm float64) (*Geofenc
 	return gf, nil
 }
 
+// NewPolygonGeofence creates a new active Geofence bounded by the given polygon, such as a park or
+// neighbourhood outline. The vertices are validated and normalized by models.NormalizePolygon, so
+// they may be given in either winding order, with or without a closing vertex.
+//
+// Steps:
+//  1. Validate and normalize the vertices.
+//  2. Reject polygons that do not fit within a MaxRadius circle around their centroid, matching
+//     the size limit of circular geofences.
+//  3. Initialize the geofence with the centroid as its center.
+func NewPolygonGeofence(walkID string, vertices []models.GeofenceVertex) (*Geofence, error) {
+	// Validate and normalize the boundary
+	normalized, err := models.NormalizePolygon(vertices)
+	if err != nil {
+		return nil, fmt.Errorf("geofence parameter validation failed: %w", err)
+	}
+
+	// Bound the polygon's extent around its centroid
+	centroid := models.PolygonCentroid(normalized)
+	extent := polygonExtentKm(centroid, normalized)
+	if extent > MaxRadius {
+		return nil, fmt.Errorf("geofence parameter validation failed: polygon extends %.3f km from its centroid, beyond %.3f", extent, MaxRadius)
+	}
+
+	nowUTC := time.Now().UTC()
+	return &Geofence{
+		ID:              uuid.NewString(),
+		WalkID:          walkID,
+		CenterLatitude:  centroid.Latitude,
+		CenterLongitude: centroid.Longitude,
+		RadiusKm:        extent,
+		Shape:           models.GeofenceShapePolygon,
+		Vertices:        normalized,
+		Mode:            models.GeofenceModeInclusion,
+		Severity:        models.GeofenceSeverityWarning,
+		CreatedAt:       nowUTC,
+		UpdatedAt:       nowUTC,
+		Active:          true,
+	}, nil
+}
+
+// polygonExtentKm returns the great-circle distance from center to the farthest vertex.
+func polygonExtentKm(center models.GeofenceVertex, vertices []models.GeofenceVertex) float64 {
+	lat1 := center.Latitude * math.Pi / 180
+	var extent float64
+	for _, v := range vertices {
+		lat2 := v.Latitude * math.Pi / 180
+		dLat := lat2 - lat1
+		dLon := (v.Longitude - center.Longitude) * math.Pi / 180
+		a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+		extent = math.Max(extent, 2*utils.EarthRadius*math.Asin(math.Sqrt(a)))
+	}
+	return extent
+}
+
+// IsPolygon reports whether the geofence is bounded by a polygon rather than a circle.
+func (g *Geofence) IsPolygon() bool {
+	return g.Shape == models.GeofenceShapePolygon
+}
+
+// IsExclusion reports whether the geofence is a no-go zone, violated by entering it.
+func (g *Geofence) IsExclusion() bool {
+	return g.Mode == models.GeofenceModeExclusion
+}
+
+// SetPolicy sets the geofence's mode and violation severity, validated and defaulted by
+// models.NormalizeGeofencePolicy.
+func (g *Geofence) SetPolicy(mode, severity string) error {
+	mode, severity, err := models.NormalizeGeofencePolicy(mode, severity)
+	if err != nil {
+		return err
+	}
+	g.Mode = mode
+	g.Severity = severity
+	g.UpdatedAt = time.Now().UTC()
+	return nil
+}
+
+// violatedBy reports whether a point with the given containment violates the geofence: outside an
+// inclusion zone, or inside an exclusion zone.
+func (g *Geofence) violatedBy(inside bool) bool {
+	return inside == g.IsExclusion()
+}
+
+// Violation describes the violation of the geofence by point, with the event type of its mode.
+func (g *Geofence) Violation(point *models.Location) models.GeofenceViolation {
+	mode, severity, err := models.NormalizeGeofencePolicy(g.Mode, g.Severity)
+	if err != nil {
+		// A policy set directly rather than through SetPolicy is reported as it is.
+		mode, severity = g.Mode, g.Severity
+	}
+	eventType := models.GeofenceEventExit
+	if g.IsExclusion() {
+		eventType = models.GeofenceEventExclusionEntry
+	}
+	return models.GeofenceViolation{
+		EventType:  eventType,
+		GeofenceID: g.ID,
+		WalkID:     g.WalkID,
+		GroupID:    g.GroupID,
+		Mode:       mode,
+		Severity:   severity,
+		Latitude:   point.Latitude,
+		Longitude:  point.Longitude,
+		Timestamp:  point.Timestamp,
+	}
+}
+
 // ContainsPoint checks if the given Location point lies within the geofence boundary.
+// Containment is geometric for both modes; whether it is a violation depends on the mode.
 // It performs the following steps:
 //   1. Verifies that the geofence is currently active; returns an error if inactive.
 //   2. Validates the input point, ensuring it meets location constraints.
-//   3. Calculates the distance between the geofence center and the point using the haversine formula
-//      via the CalculateDistance function.
-//   4. Compares the distance to the RadiusKm of the geofence.
-//   5. If the point is outside the boundary, increments the BoundaryViolations counter.
+//   3. For polygons, tests the point against the vertices by ray casting, counting points on the
+//      boundary as inside.
+//   4. For circles, calculates the distance between the geofence center and the point using the
+//      haversine formula via the CalculateDistance function and compares it to RadiusKm.
+//   5. If the point violates the geofence, lying outside an inclusion zone or inside an exclusion
+//      zone, and the geofence's schedule is active at the point's timestamp, increments the
+//      BoundaryViolations counter.
 //   6. Returns a boolean indicating containment (true) or exclusion (false), along with any error.
 //
 // Returns (true, nil) if the point is within the geofence,
@@ -174,6 +310,13 @@ func (g *Geofence) ContainsPoint(point *models.Location) (bool, error) {
 		return false, fmt.Errorf("containsPoint error: invalid location data: %w", err)
 	}
 
+	// Polygons are tested directly against their vertices
+	if g.IsPolygon() {
+		inside := models.PolygonContains(g.Vertices, point.Latitude, point.Longitude)
+		g.countViolation(inside, point.Timestamp)
+		return inside, nil
+	}
+
 	// Build a temporary Location struct to represent the geofence center
 	center := &models.Location{
 		ID:        "", // not relevant for distance calculation
@@ -189,15 +332,24 @@ func (g *Geofence) ContainsPoint(point *models.Location) (bool, error) {
 		return false, fmt.Errorf("containsPoint error: distance calculation failed: %w", err)
 	}
 
-	// Check if the distance is within the geofence radius
-	if distance <= g.RadiusKm {
-		// Point is inside the boundary
-		return true, nil
+	// Check if the distance is within the geofence radius, counting a violation only while the zone is enforced
+	inside := distance <= g.RadiusKm
+	g.countViolation(inside, point.Timestamp)
+	return inside, nil
+}
+
+// countViolation increments BoundaryViolations when a point at t with the given containment violates
+// the geofence while it is enforced.
+func (g *Geofence) countViolation(inside bool, t time.Time) {
+	if g.violatedBy(inside) && g.EnforcedAt(t) {
+		g.BoundaryViolations++
 	}
+}
 
-	// If point is outside, increment boundary violation counter
-	g.BoundaryViolations++
-	return false, nil
+// EnforcedAt reports whether the geofence's activation schedule is open at t. Breaches outside
+// the schedule (e.g. a school zone outside pickup hours) are not counted.
+func (g *Geofence) EnforcedAt(t time.Time) bool {
+	return g.Schedule.ActiveAt(t)
 }
 
 // UpdateRadius attempts to update the geofence's RadiusKm to the newRadius specified,
@@ -216,6 +368,11 @@ func (g *Geofence) UpdateRadius(newRadius float64) error {
 		return errors.New("updateRadius error: cannot update an inactive geofence")
 	}
 
+	// Polygon boundaries are defined by their vertices, not a radius
+	if g.IsPolygon() {
+		return errors.New("updateRadius error: cannot set the radius of a polygon geofence")
+	}
+
 	// Validate geofence parameters using the current center and proposed new radius
 	if err := ValidateGeofenceParameters(g.CenterLatitude, g.CenterLongitude, newRadius); err != nil {
 		return err
@@ -0,0 +1,205 @@
+package services
+
+import (
+	// errors for the disabled and invalid geofence group sentinels (go1.21)
+	"errors"
+	// fmt for wrapping validation errors (go1.21)
+	"fmt"
+	// time for group update timestamps (go1.21)
+	"time"
+
+	// models package that includes the GeofenceGroupRecord struct
+	"src/backend/tracking-service/internal/models"
+)
+
+var (
+	// ErrGeofenceGroupsDisabled is returned by geofence group administration
+	// when no geofence group store is configured.
+	ErrGeofenceGroupsDisabled = errors.New("geofence groups are not enabled")
+
+	// ErrInvalidGeofenceGroup wraps the validation failures of a geofence group.
+	ErrInvalidGeofenceGroup = errors.New("invalid geofence group")
+)
+
+// GeofenceGroupStore persists geofence groups. It is implemented by
+// repository.TimescaleRepository.
+type GeofenceGroupStore interface {
+	// SaveGeofenceGroup inserts or replaces a group under its name.
+	SaveGeofenceGroup(group *models.GeofenceGroupRecord) error
+
+	// GetGeofenceGroups returns every group, ordered by name.
+	GetGeofenceGroups() ([]models.GeofenceGroupRecord, error)
+
+	// DeleteGeofenceGroup removes the named group.
+	DeleteGeofenceGroup(name string) error
+}
+
+// SetGeofenceGroupStore enables geofence group administration. Passing nil
+// disables it; groups already registered stay in force.
+func (ts *TrackingService) SetGeofenceGroupStore(store GeofenceGroupStore) {
+	ts.geofenceGroupStore = store
+}
+
+// PutGeofenceGroup validates, stores and registers a geofence group, replacing
+// any group of the same name, and returns its stored form. Members are created
+// from their shape, boundary, mode, severity and walk as CreateGeofence does;
+// their IDs, state and timestamps are ignored, and circles without a radius
+// get the base setting. The group is enforced from the next point checked.
+func (ts *TrackingService) PutGeofenceGroup(spec *models.GeofenceGroupRecord) (*models.GeofenceGroupRecord, error) {
+	if ts.geofenceGroupStore == nil {
+		return nil, ErrGeofenceGroupsDisabled
+	}
+	if len(spec.Geofences) == 0 {
+		return nil, fmt.Errorf("%w: geofences are required", ErrInvalidGeofenceGroup)
+	}
+	var schedule *GeofenceSchedule
+	if spec.Schedule != "" {
+		var err error
+		if schedule, err = ParseGeofenceSchedule(spec.Schedule, spec.TimeZone); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidGeofenceGroup, err)
+		}
+	}
+	group, err := NewGeofenceGroup(spec.Name, schedule)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidGeofenceGroup, err)
+	}
+	if existing, ok := ts.geofenceGroups.Get(spec.Name); ok {
+		group.ID = existing.ID
+	}
+	for i, member := range spec.Geofences {
+		if (member.Shape == "" || member.Shape == models.GeofenceShapeCircle) && member.RadiusKm == 0 {
+			member.RadiusKm = ts.baseSessionSettings().GeofenceRadiusKm
+		}
+		g, err := newGeofenceFromSpec(member)
+		if err != nil {
+			return nil, fmt.Errorf("%w: geofence %d: %w", ErrInvalidGeofenceGroup, i, err)
+		}
+		group.Add(g)
+	}
+
+	// Timestamps are stored to the microsecond, so a reload finds them equal.
+	rec := group.record(spec.Schedule, spec.TimeZone, time.Now().UTC().Truncate(time.Microsecond))
+	if err := ts.geofenceGroupStore.SaveGeofenceGroup(&rec); err != nil {
+		return nil, err
+	}
+	group.updatedAt = rec.UpdatedAt
+	ts.geofenceGroups.Register(group)
+	return &rec, nil
+}
+
+// GetGeofenceGroups lists the stored geofence groups.
+func (ts *TrackingService) GetGeofenceGroups() ([]models.GeofenceGroupRecord, error) {
+	if ts.geofenceGroupStore == nil {
+		return nil, ErrGeofenceGroupsDisabled
+	}
+	groups, err := ts.geofenceGroupStore.GetGeofenceGroups()
+	if err != nil {
+		return nil, err
+	}
+	if groups == nil {
+		groups = []models.GeofenceGroupRecord{}
+	}
+	return groups, nil
+}
+
+// DeleteGeofenceGroup removes the named geofence group from the store and
+// stops enforcing it. Store errors are returned unchanged, so a missing group
+// is repository.ErrNotFound.
+func (ts *TrackingService) DeleteGeofenceGroup(name string) error {
+	if ts.geofenceGroupStore == nil {
+		return ErrGeofenceGroupsDisabled
+	}
+	if err := ts.geofenceGroupStore.DeleteGeofenceGroup(name); err != nil {
+		return err
+	}
+	ts.geofenceGroups.Remove(name)
+	return nil
+}
+
+// LoadGeofenceGroups brings the registered geofence groups in line with the
+// store: stored groups are registered, unless the registered one is already
+// current, and registered groups no longer stored are dropped. It returns how
+// many groups are registered. A stored group that no longer passes validation
+// is reported rather than skipped, the other groups still being loaded.
+func (ts *TrackingService) LoadGeofenceGroups() (int, error) {
+	if ts.geofenceGroupStore == nil {
+		return 0, ErrGeofenceGroupsDisabled
+	}
+	records, err := ts.geofenceGroupStore.GetGeofenceGroups()
+	if err != nil {
+		return 0, err
+	}
+
+	stored := make(map[string]bool, len(records))
+	var firstErr error
+	for _, rec := range records {
+		stored[rec.Name] = true
+		if current, ok := ts.geofenceGroups.Get(rec.Name); ok && current.ID == rec.ID && current.updatedAt.Equal(rec.UpdatedAt) {
+			continue
+		}
+		group, err := geofenceGroupFromRecord(rec)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("geofence group %s: %w", rec.Name, err)
+			}
+			continue
+		}
+		ts.geofenceGroups.Register(group)
+	}
+
+	registered := 0
+	for _, name := range ts.geofenceGroups.names() {
+		if !stored[name] {
+			ts.geofenceGroups.Remove(name)
+			continue
+		}
+		registered++
+	}
+	return registered, firstErr
+}
+
+// geofenceGroupFromRecord rebuilds a geofence group from its stored form,
+// keeping the IDs and state of the group and its members.
+func geofenceGroupFromRecord(rec models.GeofenceGroupRecord) (*GeofenceGroup, error) {
+	var schedule *GeofenceSchedule
+	if rec.Schedule != "" {
+		var err error
+		if schedule, err = ParseGeofenceSchedule(rec.Schedule, rec.TimeZone); err != nil {
+			return nil, err
+		}
+	}
+	group, err := NewGeofenceGroup(rec.Name, schedule)
+	if err != nil {
+		return nil, err
+	}
+	group.ID = rec.ID
+	group.updatedAt = rec.UpdatedAt
+	for _, member := range rec.Geofences {
+		g, err := GeofenceFromRecord(member)
+		if err != nil {
+			return nil, fmt.Errorf("geofence %s: %w", member.ID, err)
+		}
+		group.Add(g)
+	}
+	return group, nil
+}
+
+// record returns the stored form of the group, with the schedule spec and
+// time zone it was parsed from.
+func (gg *GeofenceGroup) record(schedule, timeZone string, updatedAt time.Time) models.GeofenceGroupRecord {
+	gg.mu.RLock()
+	defer gg.mu.RUnlock()
+
+	members := make([]models.GeofenceRecord, 0, len(gg.Geofences))
+	for _, g := range gg.Geofences {
+		members = append(members, g.Record())
+	}
+	return models.GeofenceGroupRecord{
+		ID:        gg.ID,
+		Name:      gg.Name,
+		Schedule:  schedule,
+		TimeZone:  timeZone,
+		Geofences: members,
+		UpdatedAt: updatedAt,
+	}
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	// math for bounding box geometry (go1.21)
+	"math"
+
+	// sort for Sort-Tile-Recursive bulk loading (go1.21)
+	"sort"
+
+	// models provides the coordinate bounds of the bounding boxes
+	"src/backend/tracking-service/internal/models"
+
+	// utils provides EarthRadius for converting radii to angular extents
+	"src/backend/tracking-service/internal/utils"
+)
+
+// geofenceIndexNodeCapacity is the maximum number of entries or children per
+// R-tree node.
+const geofenceIndexNodeCapacity = 16
+
+// geofenceBoundsMargin pads bounding boxes, in degrees, so that rounding never
+// excludes a point the haversine check would place on the boundary.
+const geofenceBoundsMargin = 1e-9
+
+// geofenceBounds is a latitude/longitude bounding box in degrees.
+type geofenceBounds struct {
+	minLat, minLon, maxLat, maxLon float64
+}
+
+// contains reports whether the point lies inside the box.
+func (b geofenceBounds) contains(lat, lon float64) bool {
+	return lat >= b.minLat && lat <= b.maxLat && lon >= b.minLon && lon <= b.maxLon
+}
+
+// union returns the smallest box covering b and o.
+func (b geofenceBounds) union(o geofenceBounds) geofenceBounds {
+	return geofenceBounds{
+		minLat: math.Min(b.minLat, o.minLat),
+		minLon: math.Min(b.minLon, o.minLon),
+		maxLat: math.Max(b.maxLat, o.maxLat),
+		maxLon: math.Max(b.maxLon, o.maxLon),
+	}
+}
+
+// center returns the box's midpoint, used to order nodes during bulk loading.
+func (b geofenceBounds) center() (lat, lon float64) {
+	return (b.minLat + b.maxLat) / 2, (b.minLon + b.maxLon) / 2
+}
+
+// circleBounds returns the bounding box of a spherical cap of radiusKm around
+// the center. Caps reaching a pole or crossing the antimeridian span every
+// longitude, which is conservative but never misses a containing geofence.
+func circleBounds(lat, lon, radiusKm float64) geofenceBounds {
+	angular := radiusKm / utils.EarthRadius
+	dLat := angular * 180 / math.Pi
+	b := geofenceBounds{
+		minLat: math.Max(lat-dLat, models.MinLatitude),
+		maxLat: math.Min(lat+dLat, models.MaxLatitude),
+		minLon: models.MinLongitude,
+		maxLon: models.MaxLongitude,
+	}
+	if lat-dLat > models.MinLatitude && lat+dLat < models.MaxLatitude {
+		if ratio := math.Sin(angular) / math.Cos(lat*math.Pi/180); ratio < 1 {
+			dLon := math.Asin(ratio) * 180 / math.Pi
+			if lon-dLon >= models.MinLongitude && lon+dLon <= models.MaxLongitude {
+				b.minLon, b.maxLon = lon-dLon, lon+dLon
+			}
+		}
+	}
+	b.minLat -= geofenceBoundsMargin
+	b.minLon -= geofenceBoundsMargin
+	b.maxLat += geofenceBoundsMargin
+	b.maxLon += geofenceBoundsMargin
+	return b
+}
+
+// polygonBounds returns the bounding box of a polygon's vertices. Polygon
+// edges are straight in latitude/longitude, so the box covers them exactly.
+func polygonBounds(vertices []models.GeofenceVertex) geofenceBounds {
+	b := geofenceBounds{
+		minLat: math.Inf(1), minLon: math.Inf(1),
+		maxLat: math.Inf(-1), maxLon: math.Inf(-1),
+	}
+	for _, v := range vertices {
+		b.minLat = math.Min(b.minLat, v.Latitude)
+		b.minLon = math.Min(b.minLon, v.Longitude)
+		b.maxLat = math.Max(b.maxLat, v.Latitude)
+		b.maxLon = math.Max(b.maxLon, v.Longitude)
+	}
+	b.minLat -= geofenceBoundsMargin
+	b.minLon -= geofenceBoundsMargin
+	b.maxLat += geofenceBoundsMargin
+	b.maxLon += geofenceBoundsMargin
+	return b
+}
+
+// geofenceBoundsOf returns the bounding box of g's boundary.
+func geofenceBoundsOf(g *Geofence) geofenceBounds {
+	if g.IsPolygon() {
+		return polygonBounds(g.Vertices)
+	}
+	return circleBounds(g.CenterLatitude, g.CenterLongitude, g.RadiusKm)
+}
+
+// geofenceGeometry is the center and radius a geofence was indexed with.
+type geofenceGeometry struct {
+	lat, lon, radiusKm float64
+}
+
+// geometryOf returns the current geometry of g.
+func geometryOf(g *Geofence) geofenceGeometry {
+	return geofenceGeometry{lat: g.CenterLatitude, lon: g.CenterLongitude, radiusKm: g.RadiusKm}
+}
+
+// geofenceIndexEntry is a geofence and its bounding box in an R-tree leaf.
+type geofenceIndexEntry struct {
+	bounds   geofenceBounds
+	geofence *Geofence
+}
+
+// geofenceIndexNode is an R-tree node: leaves hold entries, inner nodes hold
+// children.
+type geofenceIndexNode struct {
+	bounds   geofenceBounds
+	children []*geofenceIndexNode
+	entries  []geofenceIndexEntry
+}
+
+// GeofenceIndex is an immutable R-tree over geofence bounding boxes, bulk
+// loaded with Sort-Tile-Recursive packing. A containment check against
+// hundreds of geofences then only runs the containment test on the few whose
+// boxes cover the point; every other geofence is known to exclude it.
+//
+// The index records the geometry each geofence had when it was built. After
+// UpdateRadius the geofence is no longer Current and must be checked directly
+// until the index is rebuilt.
+type GeofenceIndex struct {
+	root     *geofenceIndexNode
+	geometry map[*Geofence]geofenceGeometry
+}
+
+// NewGeofenceIndex builds an index over geofences.
+//
+// Steps:
+//  1. Compute the bounding box of every geofence as a leaf entry
+//  2. Pack entries into leaves of up to geofenceIndexNodeCapacity, tiling by
+//     longitude and then latitude
+//  3. Pack nodes into parents the same way until a single root remains
+func NewGeofenceIndex(geofences []*Geofence) *GeofenceIndex {
+	idx := &GeofenceIndex{geometry: make(map[*Geofence]geofenceGeometry, len(geofences))}
+	if len(geofences) == 0 {
+		return idx
+	}
+
+	nodes := make([]*geofenceIndexNode, 0, len(geofences))
+	for _, g := range geofences {
+		geom := geometryOf(g)
+		idx.geometry[g] = geom
+		bounds := geofenceBoundsOf(g)
+		nodes = append(nodes, &geofenceIndexNode{
+			bounds:  bounds,
+			entries: []geofenceIndexEntry{{bounds: bounds, geofence: g}},
+		})
+	}
+
+	leafLevel := true
+	for len(nodes) > 1 || leafLevel {
+		nodes = packGeofenceNodes(nodes, leafLevel)
+		leafLevel = false
+	}
+	idx.root = nodes[0]
+	return idx
+}
+
+// packGeofenceNodes groups nodes into parents using Sort-Tile-Recursive
+// packing. At the leaf level the single-geofence entries are merged into leaves.
+func packGeofenceNodes(nodes []*geofenceIndexNode, leafLevel bool) []*geofenceIndexNode {
+	parentCount := (len(nodes) + geofenceIndexNodeCapacity - 1) / geofenceIndexNodeCapacity
+	sliceCount := int(math.Ceil(math.Sqrt(float64(parentCount))))
+	sliceSize := sliceCount * geofenceIndexNodeCapacity
+
+	sort.Slice(nodes, func(i, j int) bool {
+		_, lonI := nodes[i].bounds.center()
+		_, lonJ := nodes[j].bounds.center()
+		return lonI < lonJ
+	})
+
+	parents := make([]*geofenceIndexNode, 0, parentCount)
+	for start := 0; start < len(nodes); start += sliceSize {
+		slice := nodes[start:minInt(start+sliceSize, len(nodes))]
+		sort.Slice(slice, func(i, j int) bool {
+			latI, _ := slice[i].bounds.center()
+			latJ, _ := slice[j].bounds.center()
+			return latI < latJ
+		})
+		for i := 0; i < len(slice); i += geofenceIndexNodeCapacity {
+			group := slice[i:minInt(i+geofenceIndexNodeCapacity, len(slice))]
+			parent := &geofenceIndexNode{bounds: group[0].bounds}
+			for _, n := range group {
+				parent.bounds = parent.bounds.union(n.bounds)
+				if leafLevel {
+					parent.entries = append(parent.entries, n.entries...)
+				} else {
+					parent.children = append(parent.children, n)
+				}
+			}
+			parents = append(parents, parent)
+		}
+	}
+	return parents
+}
+
+// minInt returns the smaller of a and b.
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Candidates returns the indexed geofences whose bounding boxes cover the
+// point. Only these can contain it; callers still run the exact check.
+func (idx *GeofenceIndex) Candidates(lat, lon float64) map[*Geofence]struct{} {
+	candidates := make(map[*Geofence]struct{})
+	if idx.root == nil {
+		return candidates
+	}
+	stack := []*geofenceIndexNode{idx.root}
+	for len(stack) > 0 {
+		node := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !node.bounds.contains(lat, lon) {
+			continue
+		}
+		for _, e := range node.entries {
+			if e.bounds.contains(lat, lon) {
+				candidates[e.geofence] = struct{}{}
+			}
+		}
+		stack = append(stack, node.children...)
+	}
+	return candidates
+}
+
+// Current reports whether g is indexed with its present center and radius, so
+// that its absence from Candidates proves the point lies outside it.
+func (idx *GeofenceIndex) Current(g *Geofence) bool {
+	geom, ok := idx.geometry[g]
+	return ok && geom == geometryOf(g)
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"src/backend/tracking-service/internal/models"
+)
+
+// benchmarkGeofences returns n circle geofences of 0.1-0.5 km scattered over
+// a city-sized area, and points sampled over the same area.
+func benchmarkGeofences(b *testing.B, n, points int) ([]*Geofence, []*models.Location) {
+	b.Helper()
+	rng := rand.New(rand.NewSource(int64(n)))
+	const lat, lon, spread = 40.7128, -74.0060, 0.15
+
+	zones := make([]*Geofence, 0, n)
+	for i := 0; i < n; i++ {
+		g, err := NewGeofence("walk-bench",
+			lat+(rng.Float64()-0.5)*spread,
+			lon+(rng.Float64()-0.5)*spread,
+			0.1+rng.Float64()*0.4)
+		if err != nil {
+			b.Fatalf("NewGeofence: %v", err)
+		}
+		zones = append(zones, g)
+	}
+
+	now := time.Now().UTC()
+	locs := make([]*models.Location, 0, points)
+	for i := 0; i < points; i++ {
+		locs = append(locs, &models.Location{
+			ID:        uuid.NewString(),
+			WalkID:    "walk-bench",
+			Latitude:  lat + (rng.Float64()-0.5)*spread,
+			Longitude: lon + (rng.Float64()-0.5)*spread,
+			Accuracy:  5,
+			Timestamp: now,
+		})
+	}
+	return zones, locs
+}
+
+// BenchmarkGeofenceIndex compares finding the zones containing a point by
+// checking every zone against checking only the index's candidates, for
+// hundreds of zones.
+func BenchmarkGeofenceIndex(b *testing.B) {
+	for _, n := range []int{100, 500, 1000} {
+		zones, points := benchmarkGeofences(b, n, 256)
+		idx := NewGeofenceIndex(zones)
+
+		linear := func(point *models.Location) int {
+			inside := 0
+			for _, g := range zones {
+				if ok, err := g.ContainsPoint(point); err != nil {
+					b.Fatalf("ContainsPoint: %v", err)
+				} else if ok {
+					inside++
+				}
+			}
+			return inside
+		}
+		indexed := func(point *models.Location) int {
+			inside := 0
+			for g := range idx.Candidates(point.Latitude, point.Longitude) {
+				if ok, err := g.ContainsPoint(point); err != nil {
+					b.Fatalf("ContainsPoint: %v", err)
+				} else if ok {
+					inside++
+				}
+			}
+			return inside
+		}
+		for _, point := range points {
+			if want, got := linear(point), indexed(point); want != got {
+				b.Fatalf("%d zones: index finds %d zones containing (%f, %f), linear scan %d",
+					n, got, point.Latitude, point.Longitude, want)
+			}
+		}
+
+		b.Run(fmt.Sprintf("linear/zones=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				linear(points[i%len(points)])
+			}
+		})
+		b.Run(fmt.Sprintf("candidates/zones=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				indexed(points[i%len(points)])
+			}
+		})
+	}
+}
@@ -0,0 +1,395 @@
+package services
+
+import (
+	// time for evaluating activation windows in the schedule's time zone (go1.21)
+	"time"
+
+	// errors for schedule validation failures (go1.21)
+	"errors"
+
+	// fmt for descriptive parse errors
+	"fmt"
+
+	// strings for parsing schedule specs
+	"strings"
+
+	// sync for the thread-safe group registry
+	"sync"
+
+	// uuid for generating unique V4 UUIDs for group IDs (v1.3.0)
+	"github.com/google/uuid"
+
+	// models provides the Location struct used for real-time GPS coordinate representations
+	"src/backend/tracking-service/internal/models"
+)
+
+// weekdayNames maps schedule day abbreviations onto time.Weekday values.
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ActivationWindow is a recurring period during which a geofence is enforced,
+// such as weekday school pickup hours. Times are minutes after local midnight;
+// a window whose end is before its start runs past midnight into the next day.
+type ActivationWindow struct {
+	// Days lists the weekdays on which the window opens. All days when empty.
+	Days []time.Weekday
+
+	// StartMinute is the window start, in minutes after midnight.
+	StartMinute int
+
+	// EndMinute is the window end (exclusive), in minutes after midnight.
+	EndMinute int
+}
+
+// GeofenceSchedule determines when geofences are enforced. A nil schedule, or
+// one with no windows, is always active.
+type GeofenceSchedule struct {
+	// Location is the time zone in which windows are evaluated.
+	Location *time.Location
+
+	// Windows are the recurring activation periods.
+	Windows []ActivationWindow
+}
+
+// ParseGeofenceSchedule parses a cron-like schedule of ";"-separated windows in
+// the named IANA time zone, for example "Mon-Fri 07:30-09:00; Mon-Fri 14:45-15:30".
+// Days may be a range ("Mon-Fri"), a list ("Sat,Sun") or "*" for every day; a
+// window such as "* 22:00-06:00" runs past midnight.
+//
+// Steps:
+//  1. Resolve the time zone
+//  2. Split the spec into windows
+//  3. Parse each window's day set and time range
+func ParseGeofenceSchedule(spec, timeZone string) (*GeofenceSchedule, error) {
+	loc := time.UTC
+	if timeZone != "" {
+		var err error
+		if loc, err = time.LoadLocation(timeZone); err != nil {
+			return nil, fmt.Errorf("geofence schedule: invalid time zone %q: %w", timeZone, err)
+		}
+	}
+
+	schedule := &GeofenceSchedule{Location: loc}
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		window, err := parseActivationWindow(part)
+		if err != nil {
+			return nil, err
+		}
+		schedule.Windows = append(schedule.Windows, window)
+	}
+	if len(schedule.Windows) == 0 {
+		return nil, errors.New("geofence schedule: no activation windows specified")
+	}
+	return schedule, nil
+}
+
+// parseActivationWindow parses a single "<days> <HH:MM>-<HH:MM>" window.
+func parseActivationWindow(spec string) (ActivationWindow, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 2 {
+		return ActivationWindow{}, fmt.Errorf("geofence schedule: window %q must be \"<days> <HH:MM>-<HH:MM>\"", spec)
+	}
+
+	var window ActivationWindow
+	if fields[0] != "*" {
+		for _, dayPart := range strings.Split(fields[0], ",") {
+			days, err := parseDayRange(dayPart)
+			if err != nil {
+				return ActivationWindow{}, err
+			}
+			window.Days = append(window.Days, days...)
+		}
+	}
+
+	bounds := strings.Split(fields[1], "-")
+	if len(bounds) != 2 {
+		return ActivationWindow{}, fmt.Errorf("geofence schedule: time range %q must be \"HH:MM-HH:MM\"", fields[1])
+	}
+	var err error
+	if window.StartMinute, err = parseClockMinute(bounds[0]); err != nil {
+		return ActivationWindow{}, err
+	}
+	if window.EndMinute, err = parseClockMinute(bounds[1]); err != nil {
+		return ActivationWindow{}, err
+	}
+	if window.StartMinute == window.EndMinute {
+		return ActivationWindow{}, fmt.Errorf("geofence schedule: window %q has zero length", spec)
+	}
+	return window, nil
+}
+
+// parseDayRange parses "Mon" or a wrapping range such as "Fri-Mon".
+func parseDayRange(spec string) ([]time.Weekday, error) {
+	bounds := strings.Split(strings.ToLower(strings.TrimSpace(spec)), "-")
+	first, ok := weekdayNames[bounds[0]]
+	if !ok {
+		return nil, fmt.Errorf("geofence schedule: unknown day %q", bounds[0])
+	}
+	if len(bounds) == 1 {
+		return []time.Weekday{first}, nil
+	}
+	last, ok := weekdayNames[bounds[1]]
+	if len(bounds) != 2 || !ok {
+		return nil, fmt.Errorf("geofence schedule: invalid day range %q", spec)
+	}
+
+	var days []time.Weekday
+	for d := first; ; d = (d + 1) % 7 {
+		days = append(days, d)
+		if d == last {
+			break
+		}
+	}
+	return days, nil
+}
+
+// parseClockMinute parses "HH:MM" into minutes after midnight; "24:00" is allowed as an end time.
+func parseClockMinute(clock string) (int, error) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(strings.TrimSpace(clock), "%d:%d", &hour, &minute); err != nil {
+		return 0, fmt.Errorf("geofence schedule: invalid time %q", clock)
+	}
+	if hour < 0 || hour > 24 || minute < 0 || minute > 59 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("geofence schedule: time %q out of range", clock)
+	}
+	return hour*60 + minute, nil
+}
+
+// ActiveAt reports whether any window is open at t.
+func (s *GeofenceSchedule) ActiveAt(t time.Time) bool {
+	if s == nil || len(s.Windows) == 0 {
+		return true
+	}
+	local := t.In(s.Location)
+	minute := local.Hour()*60 + local.Minute()
+	today := local.Weekday()
+	yesterday := (today + 6) % 7
+
+	for _, w := range s.Windows {
+		if w.StartMinute < w.EndMinute {
+			if w.opensOn(today) && minute >= w.StartMinute && minute < w.EndMinute {
+				return true
+			}
+			continue
+		}
+		// Overnight window: the evening part belongs to today, the early-morning
+		// part to the window opened yesterday.
+		if w.opensOn(today) && minute >= w.StartMinute {
+			return true
+		}
+		if w.opensOn(yesterday) && minute < w.EndMinute {
+			return true
+		}
+	}
+	return false
+}
+
+// opensOn reports whether the window opens on the given weekday.
+func (w ActivationWindow) opensOn(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, d := range w.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// GeofenceGroup is a named set of geofences sharing one activation schedule,
+// for example all school zones near a walker's routes.
+type GeofenceGroup struct {
+	// ID is a unique identifier for the group, generated as a UUIDv4 at creation.
+	ID string
+
+	// Name is the unique, human-readable group name.
+	Name string
+
+	// Schedule controls when the group's geofences are enforced; nil means always.
+	Schedule *GeofenceSchedule
+
+	// updatedAt is when the stored group was last changed, zero for groups
+	// never stored; it tells whether a reload must replace the group.
+	updatedAt time.Time
+
+	// mu guards Geofences.
+	mu sync.RWMutex
+
+	// Geofences are the group's members.
+	Geofences []*Geofence
+
+	// index is the spatial index of Geofences, built lazily on the first check
+	// after the membership changes. It is guarded by indexMu while mu is read
+	// locked, and by mu alone while mu is write locked.
+	indexMu sync.Mutex
+	index   *GeofenceIndex
+}
+
+// NewGeofenceGroup creates an empty named group with the given schedule.
+func NewGeofenceGroup(name string, schedule *GeofenceSchedule) (*GeofenceGroup, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, errors.New("geofence group name cannot be empty")
+	}
+	return &GeofenceGroup{
+		ID:       uuid.NewString(),
+		Name:     name,
+		Schedule: schedule,
+	}, nil
+}
+
+// Add places a geofence in the group; the geofence adopts the group's schedule.
+func (gg *GeofenceGroup) Add(g *Geofence) {
+	gg.mu.Lock()
+	defer gg.mu.Unlock()
+
+	g.GroupID = gg.ID
+	g.Schedule = gg.Schedule
+	gg.Geofences = append(gg.Geofences, g)
+	gg.index = nil
+}
+
+// spatialIndex returns the group's index, building it if needed. The caller
+// must hold mu for reading.
+func (gg *GeofenceGroup) spatialIndex() *GeofenceIndex {
+	gg.indexMu.Lock()
+	defer gg.indexMu.Unlock()
+	if gg.index == nil {
+		gg.index = NewGeofenceIndex(gg.Geofences)
+	}
+	return gg.index
+}
+
+// invalidateIndex discards idx so the next check rebuilds it, unless it has
+// already been replaced. The caller must hold mu for reading.
+func (gg *GeofenceGroup) invalidateIndex(idx *GeofenceIndex) {
+	gg.indexMu.Lock()
+	if gg.index == idx {
+		gg.index = nil
+	}
+	gg.indexMu.Unlock()
+}
+
+// Breaches returns the group's geofences that the point violates while they
+// are enforced: inclusion zones it lies outside of and exclusion zones it lies
+// inside. Members scoped to a different walk are skipped; members without a
+// WalkID apply to every walk.
+//
+// Only members whose bounding boxes cover the point, per the group's spatial
+// index, get the exact distance check; for the rest, inclusion zones are
+// breached outright and exclusion zones are not. Members resized since the
+// index was built are checked exactly and trigger a rebuild.
+func (gg *GeofenceGroup) Breaches(walkID string, point *models.Location) ([]*Geofence, error) {
+	if point == nil {
+		return nil, errors.New("geofence group breaches: nil location provided")
+	}
+	if !gg.Schedule.ActiveAt(point.Timestamp) {
+		return nil, nil
+	}
+	if err := point.Validate(); err != nil {
+		return nil, fmt.Errorf("geofence group breaches: invalid location data: %w", err)
+	}
+
+	gg.mu.RLock()
+	defer gg.mu.RUnlock()
+
+	idx := gg.spatialIndex()
+	candidates := idx.Candidates(point.Latitude, point.Longitude)
+	stale := false
+
+	var breached []*Geofence
+	for _, g := range gg.Geofences {
+		if !g.Active || (g.WalkID != "" && g.WalkID != walkID) {
+			continue
+		}
+		if !idx.Current(g) {
+			stale = true
+		} else if _, ok := candidates[g]; !ok {
+			g.countViolation(false, point.Timestamp)
+			if g.violatedBy(false) {
+				breached = append(breached, g)
+			}
+			continue
+		}
+		inside, err := g.ContainsPoint(point)
+		if err != nil {
+			return nil, err
+		}
+		if g.violatedBy(inside) {
+			breached = append(breached, g)
+		}
+	}
+	if stale {
+		gg.invalidateIndex(idx)
+	}
+	return breached, nil
+}
+
+// GeofenceGroupRegistry holds named geofence groups for containment checks.
+type GeofenceGroupRegistry struct {
+	groups sync.Map
+}
+
+// Register adds or replaces a group under its name.
+func (r *GeofenceGroupRegistry) Register(group *GeofenceGroup) {
+	r.groups.Store(group.Name, group)
+}
+
+// Remove deletes the named group.
+func (r *GeofenceGroupRegistry) Remove(name string) {
+	r.groups.Delete(name)
+}
+
+// Get returns the named group, if registered.
+func (r *GeofenceGroupRegistry) Get(name string) (*GeofenceGroup, bool) {
+	val, ok := r.groups.Load(name)
+	if !ok {
+		return nil, false
+	}
+	group, ok := val.(*GeofenceGroup)
+	return group, ok
+}
+
+// names returns the names of the registered groups.
+func (r *GeofenceGroupRegistry) names() []string {
+	var names []string
+	r.groups.Range(func(key, _ interface{}) bool {
+		if name, ok := key.(string); ok {
+			names = append(names, name)
+		}
+		return true
+	})
+	return names
+}
+
+// Breaches evaluates every registered group against the point and returns the
+// enforced geofences it breaches.
+func (r *GeofenceGroupRegistry) Breaches(walkID string, point *models.Location) ([]*Geofence, error) {
+	var all []*Geofence
+	var firstErr error
+	r.groups.Range(func(_, value interface{}) bool {
+		group, ok := value.(*GeofenceGroup)
+		if !ok {
+			return true
+		}
+		breached, err := group.Breaches(walkID, point)
+		if err != nil {
+			firstErr = err
+			return false
+		}
+		all = append(all, breached...)
+		return true
+	})
+	return all, firstErr
+}
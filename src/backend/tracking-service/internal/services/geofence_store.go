@@ -0,0 +1,174 @@
+package services
+
+import (
+	// errors for the disabled and invalid geofence sentinels (go1.21)
+	"errors"
+	// fmt for wrapping validation errors (go1.21)
+	"fmt"
+
+	// models package that includes the GeofenceRecord struct
+	"src/backend/tracking-service/internal/models"
+)
+
+var (
+	// ErrGeofencesDisabled is returned by geofence persistence when no geofence
+	// store is configured.
+	ErrGeofencesDisabled = errors.New("geofence persistence is not enabled")
+
+	// ErrInvalidGeofence wraps the validation failures of a geofence definition.
+	ErrInvalidGeofence = errors.New("invalid geofence")
+)
+
+// GeofenceStore persists walk geofences. It is implemented by
+// repository.TimescaleRepository.
+type GeofenceStore interface {
+	// SaveGeofence inserts or replaces a geofence.
+	SaveGeofence(geofence *models.GeofenceRecord) error
+
+	// GetGeofences returns the geofences defined for a walk, oldest first.
+	GetGeofences(walkID string) ([]models.GeofenceRecord, error)
+}
+
+// SetGeofenceStore enables geofence persistence. Passing nil disables it.
+func (ts *TrackingService) SetGeofenceStore(store GeofenceStore) {
+	ts.geofenceStore = store
+}
+
+// Record returns the persisted form of g.
+func (g *Geofence) Record() models.GeofenceRecord {
+	shape := g.Shape
+	if shape == "" {
+		shape = models.GeofenceShapeCircle
+	}
+	mode, severity, err := models.NormalizeGeofencePolicy(g.Mode, g.Severity)
+	if err != nil {
+		mode, severity = g.Mode, g.Severity
+	}
+	return models.GeofenceRecord{
+		ID:                 g.ID,
+		WalkID:             g.WalkID,
+		Shape:              shape,
+		CenterLatitude:     g.CenterLatitude,
+		CenterLongitude:    g.CenterLongitude,
+		RadiusKm:           g.RadiusKm,
+		Vertices:           append([]models.GeofenceVertex(nil), g.Vertices...),
+		Mode:               mode,
+		Severity:           severity,
+		Active:             g.Active,
+		BoundaryViolations: g.BoundaryViolations,
+		GroupID:            g.GroupID,
+		CreatedAt:          g.CreatedAt,
+		UpdatedAt:          g.UpdatedAt,
+	}
+}
+
+// GeofenceFromRecord rebuilds a geofence from its persisted form, validating
+// its boundary as NewGeofence and NewPolygonGeofence do and its mode and
+// severity as SetPolicy does. The record's ID, state and timestamps are kept.
+func GeofenceFromRecord(rec models.GeofenceRecord) (*Geofence, error) {
+	g, err := newGeofenceFromSpec(rec)
+	if err != nil {
+		return nil, err
+	}
+	g.ID = rec.ID
+	g.Active = rec.Active
+	g.BoundaryViolations = rec.BoundaryViolations
+	g.GroupID = rec.GroupID
+	if !rec.CreatedAt.IsZero() {
+		g.CreatedAt = rec.CreatedAt
+	}
+	if !rec.UpdatedAt.IsZero() {
+		g.UpdatedAt = rec.UpdatedAt
+	}
+	return g, nil
+}
+
+// newGeofenceFromSpec creates a geofence of the shape rec describes, from its
+// center and radius for circles or its vertices for polygons, with its mode
+// and severity.
+func newGeofenceFromSpec(rec models.GeofenceRecord) (*Geofence, error) {
+	var g *Geofence
+	var err error
+	switch rec.Shape {
+	case "", models.GeofenceShapeCircle:
+		g, err = NewGeofence(rec.WalkID, rec.CenterLatitude, rec.CenterLongitude, rec.RadiusKm)
+	case models.GeofenceShapePolygon:
+		g, err = NewPolygonGeofence(rec.WalkID, rec.Vertices)
+	default:
+		return nil, fmt.Errorf("geofence shape %q is invalid; must be %q or %q",
+			rec.Shape, models.GeofenceShapeCircle, models.GeofenceShapePolygon)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := g.SetPolicy(rec.Mode, rec.Severity); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// CreateGeofence defines a new geofence for a walk, such as the outline of the
+// park a walker is allowed to roam or a no-go zone around a busy road, and
+// persists it. spec supplies the shape, boundary, mode and severity; its ID,
+// state and timestamps are ignored. A circle without a radius gets the
+// default radius of the walk's region.
+func (ts *TrackingService) CreateGeofence(walkID string, spec models.GeofenceRecord) (*Geofence, error) {
+	if ts.geofenceStore == nil {
+		return nil, ErrGeofencesDisabled
+	}
+	if walkID == "" {
+		return nil, fmt.Errorf("%w: walkID is required", ErrInvalidGeofence)
+	}
+	spec.WalkID = walkID
+	if (spec.Shape == "" || spec.Shape == models.GeofenceShapeCircle) && spec.RadiusKm == 0 {
+		spec.RadiusKm = ts.defaultGeofenceRadius(walkID)
+	}
+	g, err := newGeofenceFromSpec(spec)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidGeofence, err)
+	}
+	g.GroupID = spec.GroupID
+
+	rec := g.Record()
+	if err := ts.geofenceStore.SaveGeofence(&rec); err != nil {
+		return nil, err
+	}
+	ts.expireGeofenceZones(walkID)
+	return g, nil
+}
+
+// SaveGeofence persists the current state of g, e.g. after its violations
+// were counted or it was deactivated.
+func (ts *TrackingService) SaveGeofence(g *Geofence) error {
+	if ts.geofenceStore == nil {
+		return ErrGeofencesDisabled
+	}
+	rec := g.Record()
+	if err := ts.geofenceStore.SaveGeofence(&rec); err != nil {
+		return err
+	}
+	ts.expireGeofenceZones(g.WalkID)
+	return nil
+}
+
+// LoadGeofences returns the persisted geofences of a walk. A stored geofence
+// that no longer passes validation is reported rather than skipped, so a walk
+// is never silently left unfenced.
+func (ts *TrackingService) LoadGeofences(walkID string) ([]*Geofence, error) {
+	if ts.geofenceStore == nil {
+		return nil, ErrGeofencesDisabled
+	}
+	records, err := ts.geofenceStore.GetGeofences(walkID)
+	if err != nil {
+		return nil, err
+	}
+	geofences := make([]*Geofence, 0, len(records))
+	for _, rec := range records {
+		g, err := GeofenceFromRecord(rec)
+		if err != nil {
+			return nil, fmt.Errorf("geofence %s: %w", rec.ID, err)
+		}
+		geofences = append(geofences, g)
+	}
+	return geofences, nil
+}
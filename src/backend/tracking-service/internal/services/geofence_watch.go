@@ -0,0 +1,332 @@
+package services
+
+import (
+	// sync for the per-session geofence state (go1.21)
+	"sync"
+	// time for zone refreshes and point ordering (go1.21)
+	"time"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// models package that includes the Location and GeofenceViolation structs
+	"src/backend/tracking-service/internal/models"
+)
+
+// geofenceStageName is the name of the publish stage evaluating geofences
+// inline.
+const geofenceStageName = "geofence.breaches"
+
+// geofenceWatch is the inline geofence state of one session: the walk's
+// persisted zones and their spatial index as last loaded, the zones the
+// session's newest point breached, and that point and its time.
+type geofenceWatch struct {
+	mu       sync.Mutex
+	walkID   string
+	zones    []*Geofence
+	index    *GeofenceIndex
+	loadedAt time.Time
+	breached map[string]bool
+	lastAt   time.Time
+	last     *models.Location
+}
+
+// SetInlineGeofencing evaluates geofences as points are ingested rather than
+// only when MonitorSessionHealth polls: every accepted point of a batch is
+// checked, in order, against the walk's geofences and the geofence groups, and
+// a violation event is published the moment a zone is breached. A zone stays
+// breached, without further events, until a point complies with it again.
+// The walk's geofences are reloaded once they are older than refresh, or when
+// one is created. Zero disables inline evaluation.
+func (ts *TrackingService) SetInlineGeofencing(refresh time.Duration) {
+	ts.geofenceRefresh = refresh
+	ts.pipeline.Unregister(geofenceStageName)
+	if refresh <= 0 {
+		return
+	}
+	if err := ts.pipeline.Register(PhasePublish, StageFunc(geofenceStageName, ts.geofenceStage)); err != nil {
+		ts.logger.Error("Failed to register inline geofence stage", zap.Error(err))
+	}
+}
+
+// geofenceStage checks every accepted point of the batch against the
+// session's zones.
+func (ts *TrackingService) geofenceStage(batch *PipelineBatch) error {
+	if len(batch.Accepted) == 0 {
+		return nil
+	}
+	ts.evaluateGeofences(batch.SessionID, batch.Session, batch.Accepted)
+	return nil
+}
+
+// evaluateGeofences checks points, in batch order, against the session's
+// zones and publishes a violation for each zone a point newly breaches.
+// Points older than the last one evaluated are ignored, so a late batch
+// cannot reopen a breach the walk has since left.
+func (ts *TrackingService) evaluateGeofences(sessionID string, session *models.TrackingSession, points []models.Location) {
+	watch := ts.geofenceWatch(sessionID, session.WalkID())
+
+	watch.mu.Lock()
+	ts.refreshGeofenceZones(watch)
+	var violations []models.GeofenceViolation
+	for i := range points {
+		point := &points[i]
+		if point.Timestamp.Before(watch.lastAt) {
+			continue
+		}
+		watch.lastAt = point.Timestamp
+		last := *point
+		watch.last = &last
+
+		breached := make(map[string]bool, len(watch.breached))
+		candidates := watch.index.Candidates(point.Latitude, point.Longitude)
+		for _, g := range watch.zones {
+			if !zoneBreached(g, candidates, point) {
+				continue
+			}
+			breached[g.ID] = true
+			if !watch.breached[g.ID] {
+				violations = append(violations, g.Violation(point))
+			}
+		}
+		groupBreaches, err := ts.geofenceGroups.Breaches(watch.walkID, point)
+		if err != nil {
+			ts.logger.Warn("Error checking geofence group compliance", zap.String("sessionID", sessionID), zap.Error(err))
+		}
+		for _, g := range groupBreaches {
+			breached[g.ID] = true
+			if !watch.breached[g.ID] {
+				violations = append(violations, g.Violation(point))
+			}
+		}
+		watch.breached = breached
+	}
+	watch.mu.Unlock()
+
+	if len(violations) == 0 {
+		return
+	}
+	for _, violation := range violations {
+		ts.logger.Warn("Session geofence boundary violation",
+			zap.String("sessionID", sessionID),
+			zap.String("geofenceID", violation.GeofenceID),
+			zap.String("groupID", violation.GroupID),
+			zap.String("eventType", violation.EventType),
+			zap.String("severity", violation.Severity),
+		)
+	}
+	ts.publishGeofenceViolations(sessionID, violations)
+	ts.updateHealthMetric(sessionID, HealthStatusGeofenceWarning)
+}
+
+// zoneBreached reports whether point violates the walk zone g. Zones whose
+// bounding box, per candidates, does not cover the point cannot contain it,
+// so they skip the exact check.
+func zoneBreached(g *Geofence, candidates map[*Geofence]struct{}, point *models.Location) bool {
+	if _, ok := candidates[g]; !ok {
+		return !g.IsExclusion() && g.appliesAt(point.Timestamp)
+	}
+	_, violated := g.breachDistance(point)
+	return violated
+}
+
+// geofenceWatch returns the inline geofence state of a session, creating it
+// on the session's first evaluation. A batch racing the session's end still
+// gets a watch to evaluate against, but it is not kept.
+func (ts *TrackingService) geofenceWatch(sessionID, walkID string) *geofenceWatch {
+	if v, ok := ts.geofenceWatches.Load(sessionID); ok {
+		return v.(*geofenceWatch)
+	}
+	watch := &geofenceWatch{walkID: walkID, index: NewGeofenceIndex(nil)}
+	v, _ := ts.geofenceWatches.LoadOrStore(sessionID, watch)
+	if _, active := ts.activeSessions.Load(sessionID); !active {
+		ts.forgetGeofenceWatch(sessionID)
+	}
+	return v.(*geofenceWatch)
+}
+
+// pollGeofenceGroups re-checks the newest point of a session against the
+// geofence groups as of now, so a scheduled group that becomes active after
+// the walker stopped sending points is still breached. Zones the session's
+// inline state already holds breached are not published again, and are left
+// for the next point to clear. It reports whether a zone was newly breached.
+func (ts *TrackingService) pollGeofenceGroups(sessionID string, session *models.TrackingSession, last *models.Location, now time.Time) bool {
+	watch := ts.geofenceWatch(sessionID, session.WalkID())
+	point := *last
+	point.Timestamp = now
+
+	watch.mu.Lock()
+	breached, err := ts.geofenceGroups.Breaches(watch.walkID, &point)
+	if err != nil {
+		ts.logger.Warn("Error checking geofence group compliance", zap.String("sessionID", sessionID), zap.Error(err))
+	}
+	var violations []models.GeofenceViolation
+	for _, g := range breached {
+		if watch.breached[g.ID] {
+			continue
+		}
+		if watch.breached == nil {
+			watch.breached = make(map[string]bool)
+		}
+		watch.breached[g.ID] = true
+		violations = append(violations, g.Violation(&point))
+	}
+	watch.mu.Unlock()
+
+	if len(violations) == 0 {
+		return false
+	}
+	for _, violation := range violations {
+		ts.logger.Warn("Session geofence group boundary violation",
+			zap.String("sessionID", sessionID),
+			zap.String("geofenceID", violation.GeofenceID),
+			zap.String("groupID", violation.GroupID),
+			zap.String("eventType", violation.EventType),
+			zap.String("severity", violation.Severity),
+		)
+	}
+	ts.publishGeofenceViolations(sessionID, violations)
+	ts.updateHealthMetric(sessionID, HealthStatusGeofenceWarning)
+	return true
+}
+
+// PollGeofenceGroups re-checks the newest point evaluated inline for every
+// active session against the geofence groups as of now, and returns how many
+// sessions newly breached a zone. Without inline geofencing it does nothing.
+func (ts *TrackingService) PollGeofenceGroups(now time.Time) int {
+	if ts.geofenceRefresh <= 0 {
+		return 0
+	}
+	polled := 0
+	ts.geofenceWatches.Range(func(key, value interface{}) bool {
+		sessionID, _ := key.(string)
+		session, err := ts.getSession(sessionID)
+		if err != nil {
+			return true
+		}
+		watch := value.(*geofenceWatch)
+		watch.mu.Lock()
+		last := watch.last
+		watch.mu.Unlock()
+		if last != nil && ts.pollGeofenceGroups(sessionID, session, last, now) {
+			polled++
+		}
+		return true
+	})
+	return polled
+}
+
+// GeofenceGroupPoller periodically polls the geofence groups against the
+// sessions evaluated inline, so a scheduled group that becomes active while a
+// walker sends no points is still breached. Each sweep first reloads the
+// groups from their store, when one is configured, so groups defined through
+// another instance apply here too.
+type GeofenceGroupPoller struct {
+	ts       *TrackingService
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewGeofenceGroupPoller creates a geofence group poll for the service's
+// sessions.
+func NewGeofenceGroupPoller(ts *TrackingService) *GeofenceGroupPoller {
+	return &GeofenceGroupPoller{ts: ts, stop: make(chan struct{})}
+}
+
+// Start polls the geofence groups every interval until Stop is called.
+func (p *GeofenceGroupPoller) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				return
+			case now := <-ticker.C:
+				p.sweep(now)
+			}
+		}
+	}()
+}
+
+// sweep reloads the geofence groups, then polls them and drops the inline
+// state of sessions that have ended.
+func (p *GeofenceGroupPoller) sweep(now time.Time) {
+	if p.ts.geofenceGroupStore != nil {
+		if _, err := p.ts.LoadGeofenceGroups(); err != nil {
+			p.ts.logger.Warn("Failed to reload geofence groups", zap.Error(err))
+		}
+	}
+	if breached := p.ts.PollGeofenceGroups(now.UTC()); breached > 0 {
+		p.ts.logger.Info("Geofence group poll found new breaches", zap.Int("sessions", breached))
+	}
+	p.ts.forgetEndedGeofenceWatches()
+}
+
+// Stop ends the background poll.
+func (p *GeofenceGroupPoller) Stop() {
+	p.stopOnce.Do(func() { close(p.stop) })
+}
+
+// refreshGeofenceZones reloads the walk's geofences of watch when they are
+// older than the refresh interval, keeping the previous ones if loading
+// fails. The caller must hold watch.mu.
+func (ts *TrackingService) refreshGeofenceZones(watch *geofenceWatch) {
+	if ts.geofenceStore == nil || time.Since(watch.loadedAt) < ts.geofenceRefresh {
+		return
+	}
+	zones, err := ts.LoadGeofences(watch.walkID)
+	if err != nil {
+		ts.logger.Warn("Failed to load walk geofences for inline evaluation",
+			zap.String("walkID", watch.walkID),
+			zap.Error(err),
+		)
+		return
+	}
+	watch.zones = zones
+	watch.index = NewGeofenceIndex(zones)
+	watch.loadedAt = time.Now()
+}
+
+// expireGeofenceZones makes the sessions of a walk reload its geofences with
+// their next point, so a zone applies as soon as it is created.
+func (ts *TrackingService) expireGeofenceZones(walkID string) {
+	ts.geofenceWatches.Range(func(_, value interface{}) bool {
+		watch := value.(*geofenceWatch)
+		if watch.walkID == walkID {
+			watch.mu.Lock()
+			watch.loadedAt = time.Time{}
+			watch.mu.Unlock()
+		}
+		return true
+	})
+}
+
+// inGeofenceBreach reports whether the newest point evaluated inline for a
+// session breached any zone.
+func (ts *TrackingService) inGeofenceBreach(sessionID string) bool {
+	v, ok := ts.geofenceWatches.Load(sessionID)
+	if !ok {
+		return false
+	}
+	watch := v.(*geofenceWatch)
+	watch.mu.Lock()
+	defer watch.mu.Unlock()
+	return len(watch.breached) > 0
+}
+
+// forgetGeofenceWatch drops the inline geofence state of an ended session.
+func (ts *TrackingService) forgetGeofenceWatch(sessionID string) {
+	ts.geofenceWatches.Delete(sessionID)
+}
+
+// forgetEndedGeofenceWatches drops the inline geofence state of every session
+// no longer active, whichever path removed it.
+func (ts *TrackingService) forgetEndedGeofenceWatches() {
+	ts.geofenceWatches.Range(func(key, _ interface{}) bool {
+		if _, active := ts.activeSessions.Load(key); !active {
+			ts.geofenceWatches.Delete(key)
+		}
+		return true
+	})
+}
@@ -0,0 +1,289 @@
+package services
+
+import (
+	// errors for the disabled and no-report sentinels (go1.21)
+	"errors"
+	// fmt for wrapping repository errors (go1.21)
+	"fmt"
+	// math for the distance tolerance (go1.21)
+	"math"
+	// sync for guarding the last report and stopping the schedule once (go1.21)
+	"sync"
+	// time for the lookback window and the run schedule (go1.21)
+	"time"
+
+	// prometheus for discrepancy metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// models package for the IntegrityReport struct
+	"src/backend/tracking-service/internal/models"
+)
+
+// ErrIntegrityChecksDisabled is returned by integrity queries when no checker
+// is configured.
+var ErrIntegrityChecksDisabled = errors.New("data integrity checks are not enabled")
+
+// ErrNoIntegrityReport is returned when the integrity checker has not completed
+// a run yet.
+var ErrNoIntegrityReport = errors.New("no data integrity report is available yet")
+
+// integrityDistanceFloor is the distance difference, in meters, below which
+// sessions never fail the distance check, absorbing rounding on short walks.
+const integrityDistanceFloor = 1.0
+
+// IntegrityStore reads the persisted data integrity runs check sessions
+// against. It is implemented by repository.TimescaleRepository.
+type IntegrityStore interface {
+	// SampleCompletedSessions returns up to limit random sessions completed at
+	// or after since.
+	SampleCompletedSessions(since time.Time, limit int) ([]string, error)
+
+	// GetLocationHistory returns the walk's persisted points in chronological order.
+	GetLocationHistory(walkID string) ([]models.Location, error)
+
+	// HasWalkSummary reports whether a summary ended at or after since is stored
+	// for the walk.
+	HasWalkSummary(walkID string, since time.Time) (bool, error)
+}
+
+// IntegrityChecker periodically samples recently completed walks and checks
+// that the data derived while they were tracked agrees with what was
+// persisted: the accumulated distance with the distance recomputed from the
+// stored points, the accepted point counter with the stored points the session
+// would have accepted, and, when the leaderboard is enabled, that a summary was
+// recorded. Sessions are rebuilt from their event streams, so event sourcing
+// must be enabled. Discrepancies are logged, exported as metrics and kept in
+// the last report.
+type IntegrityChecker struct {
+	ts         *TrackingService
+	store      IntegrityStore
+	lookback   time.Duration
+	sampleSize int
+	tolerance  float64
+	logger     *zap.Logger
+
+	mu   sync.Mutex
+	last *models.IntegrityReport
+
+	checked       prometheus.Counter
+	discrepancies *prometheus.GaugeVec
+	lastRun       prometheus.Gauge
+	failures      prometheus.Counter
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+// NewIntegrityChecker creates a checker sampling up to sampleSize sessions
+// completed within lookback of each run, flagging distances that differ by
+// more than tolerance, a fraction of the recomputed distance, and registers its
+// metrics on registry when non-nil.
+func NewIntegrityChecker(ts *TrackingService, store IntegrityStore, lookback time.Duration, sampleSize int, tolerance float64, logger *zap.Logger, registry *prometheus.Registry) (*IntegrityChecker, error) {
+	if lookback <= 0 || sampleSize < 1 || tolerance < 0 {
+		return nil, fmt.Errorf("invalid integrity check settings: lookback %s, sample size %d, tolerance %g", lookback, sampleSize, tolerance)
+	}
+	c := &IntegrityChecker{
+		ts:         ts,
+		store:      store,
+		lookback:   lookback,
+		sampleSize: sampleSize,
+		tolerance:  tolerance,
+		logger:     logger,
+		checked: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tracking_integrity_sessions_checked_total",
+			Help: "Completed sessions checked by the data integrity checker",
+		}),
+		discrepancies: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "tracking_integrity_discrepancies",
+			Help: "Discrepancies found by the last data integrity run, by check",
+		}, []string{"check"}),
+		lastRun: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "tracking_integrity_last_run_timestamp_seconds",
+			Help: "Unix time the last data integrity run completed",
+		}),
+		failures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tracking_integrity_run_failures_total",
+			Help: "Data integrity runs that could not sample sessions",
+		}),
+		stop: make(chan struct{}),
+	}
+	if registry != nil {
+		registry.MustRegister(c.checked, c.discrepancies, c.lastRun, c.failures)
+	}
+	return c, nil
+}
+
+// Start runs the checks in the background, once right away and then every
+// interval.
+func (c *IntegrityChecker) Start(interval time.Duration) {
+	go func() {
+		c.runLogged()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				c.runLogged()
+			}
+		}
+	}()
+}
+
+// Stop ends the scheduled runs.
+func (c *IntegrityChecker) Stop() {
+	c.stopOnce.Do(func() { close(c.stop) })
+}
+
+// runLogged runs the checks, logging a failed run.
+func (c *IntegrityChecker) runLogged() {
+	if _, err := c.Run(); err != nil {
+		c.logger.Error("Data integrity run failed", zap.Error(err))
+	}
+}
+
+// Run checks a fresh sample of recently completed sessions and returns the
+// report, which also becomes the last report.
+//
+// Steps:
+//  1. Sample sessions completed within the lookback window
+//  2. Rebuild each session and take the statistics it reported
+//  3. Recompute distance and accepted points from the walk's stored track
+//     with the haversine formula of live updates
+//  4. Check the leaderboard summary exists for tenant sessions when the
+//     leaderboard is enabled
+//  5. Publish the discrepancy counts and keep the report
+func (c *IntegrityChecker) Run() (*models.IntegrityReport, error) {
+	startedAt := time.Now().UTC()
+	report := &models.IntegrityReport{
+		StartedAt:     startedAt,
+		Since:         startedAt.Add(-c.lookback),
+		Discrepancies: []models.IntegrityDiscrepancy{},
+	}
+	sessionIDs, err := c.store.SampleCompletedSessions(report.Since, c.sampleSize)
+	if err != nil {
+		c.failures.Inc()
+		return nil, fmt.Errorf("failed to sample completed sessions: %w", err)
+	}
+
+	for _, sessionID := range sessionIDs {
+		found, err := c.checkSession(sessionID, report.Since)
+		if err != nil {
+			c.logger.Warn("Skipping session in data integrity run",
+				zap.String("sessionID", sessionID),
+				zap.Error(err),
+			)
+			report.SessionsSkipped++
+			continue
+		}
+		report.SessionsChecked++
+		report.Discrepancies = append(report.Discrepancies, found...)
+	}
+	report.CompletedAt = time.Now().UTC()
+
+	counts := map[string]int{
+		models.IntegrityCheckDistance:       0,
+		models.IntegrityCheckPointCount:     0,
+		models.IntegrityCheckMissingSummary: 0,
+	}
+	for _, d := range report.Discrepancies {
+		counts[d.Check]++
+		c.logger.Warn("Data integrity discrepancy",
+			zap.String("check", d.Check),
+			zap.String("sessionID", d.SessionID),
+			zap.String("walkID", d.WalkID),
+			zap.Float64("expected", d.Expected),
+			zap.Float64("actual", d.Actual),
+		)
+	}
+	for check, n := range counts {
+		c.discrepancies.WithLabelValues(check).Set(float64(n))
+	}
+	c.checked.Add(float64(report.SessionsChecked))
+	c.lastRun.Set(float64(report.CompletedAt.Unix()))
+	c.logger.Info("Data integrity run completed",
+		zap.Int("sessionsChecked", report.SessionsChecked),
+		zap.Int("sessionsSkipped", report.SessionsSkipped),
+		zap.Int("discrepancies", len(report.Discrepancies)),
+	)
+
+	c.mu.Lock()
+	c.last = report
+	c.mu.Unlock()
+	return report, nil
+}
+
+// checkSession returns the invariants the session breaks. Summaries are looked
+// up from since, the start of the lookback window.
+func (c *IntegrityChecker) checkSession(sessionID string, since time.Time) ([]models.IntegrityDiscrepancy, error) {
+	session, err := c.ts.resolveSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := session.CalculateStatistics()
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate statistics of session %s: %w", sessionID, err)
+	}
+	walkID := session.WalkID()
+	track, err := c.store.GetLocationHistory(walkID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load history of walk %s: %w", walkID, err)
+	}
+	recomputed := session.StatisticsAsOf(track, nil, time.Now().UTC(), nil)
+
+	var found []models.IntegrityDiscrepancy
+	flag := func(check string, expected, actual float64) {
+		found = append(found, models.IntegrityDiscrepancy{
+			Check:     check,
+			SessionID: sessionID,
+			WalkID:    walkID,
+			Expected:  expected,
+			Actual:    actual,
+		})
+	}
+	allowed := math.Max(c.tolerance*recomputed.TotalDistance, integrityDistanceFloor)
+	if math.Abs(stats.TotalDistance-recomputed.TotalDistance) > allowed {
+		flag(models.IntegrityCheckDistance, recomputed.TotalDistance, stats.TotalDistance)
+	}
+	if recomputed.PointCount() != stats.PointCount() {
+		flag(models.IntegrityCheckPointCount, float64(recomputed.PointCount()), float64(stats.PointCount()))
+	}
+	if c.ts.leaderboardStore != nil && session.TenantID() != "" {
+		exists, err := c.store.HasWalkSummary(walkID, since)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up summary of walk %s: %w", walkID, err)
+		}
+		if !exists {
+			flag(models.IntegrityCheckMissingSummary, 1, 0)
+		}
+	}
+	return found, nil
+}
+
+// LastReport returns the report of the last completed run.
+func (c *IntegrityChecker) LastReport() (*models.IntegrityReport, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.last == nil {
+		return nil, ErrNoIntegrityReport
+	}
+	return c.last, nil
+}
+
+// SetIntegrityChecker enables the data integrity report. Passing nil disables
+// it.
+func (ts *TrackingService) SetIntegrityChecker(checker *IntegrityChecker) {
+	ts.integrity = checker
+}
+
+// GetIntegrityReport returns the report of the last data integrity run.
+func (ts *TrackingService) GetIntegrityReport() (*models.IntegrityReport, error) {
+	if ts.integrity == nil {
+		return nil, ErrIntegrityChecksDisabled
+	}
+	return ts.integrity.LastReport()
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	// errors for the leaderboard sentinels (go1.21)
+	"errors"
+	// fmt for wrapping validation errors (go1.21)
+	"fmt"
+	// time for leaderboard periods (go1.21)
+	"time"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// models package that includes the WalkSummary and Leaderboard structs
+	"src/backend/tracking-service/internal/models"
+)
+
+// DefaultLeaderboardLimit is the number of walkers returned when a query does
+// not specify a limit, and MaxLeaderboardLimit is the most a query may request.
+const (
+	DefaultLeaderboardLimit = 50
+	MaxLeaderboardLimit     = 500
+)
+
+var (
+	// ErrLeaderboardDisabled is returned by leaderboard queries when no
+	// leaderboard store is configured.
+	ErrLeaderboardDisabled = errors.New("walker leaderboard is not enabled")
+
+	// ErrInvalidLeaderboardQuery is returned for unknown periods or rankings.
+	ErrInvalidLeaderboardQuery = errors.New("invalid leaderboard query")
+)
+
+// LeaderboardStore records completed walks and ranks walkers from their
+// continuous aggregates. It is implemented by repository.TimescaleRepository.
+type LeaderboardStore interface {
+	// RecordWalkSummary stores a completed walk.
+	RecordWalkSummary(summary *models.WalkSummary) error
+	// GetWalkerLeaderboard ranks the tenant's walkers by rankBy over the walks ended in [from, to).
+	GetWalkerLeaderboard(tenantID string, from, to time.Time, rankBy string, limit int) ([]models.LeaderboardEntry, error)
+}
+
+// SetLeaderboardStore enables the walker leaderboard. Walks of sessions
+// started with a tenant are recorded when they end. Passing nil disables it.
+func (ts *TrackingService) SetLeaderboardStore(store LeaderboardStore) {
+	ts.leaderboardStore = store
+}
+
+// LeaderboardWindow returns the bounds of the period containing now, in UTC:
+// the day, the ISO week starting on Monday, or the calendar month.
+func LeaderboardWindow(period string, now time.Time) (time.Time, time.Time, error) {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch period {
+	case models.LeaderboardPeriodDay:
+		return day, day.AddDate(0, 0, 1), nil
+	case models.LeaderboardPeriodWeek:
+		start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return start, start.AddDate(0, 0, 7), nil
+	case models.LeaderboardPeriodMonth:
+		start := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0), nil
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: unknown period %q", ErrInvalidLeaderboardQuery, period)
+	}
+}
+
+// GetLeaderboard ranks the tenant's walkers over the current period by
+// distance, number of walks or average quality score, for the walker
+// gamification program. A limit of zero uses the default.
+func (ts *TrackingService) GetLeaderboard(tenantID, period, rankBy string, limit int) (*models.Leaderboard, error) {
+	if ts.leaderboardStore == nil {
+		return nil, ErrLeaderboardDisabled
+	}
+	if tenantID == "" {
+		return nil, fmt.Errorf("%w: tenant is required", ErrInvalidLeaderboardQuery)
+	}
+	switch rankBy {
+	case models.LeaderboardRankDistance, models.LeaderboardRankWalks, models.LeaderboardRankQuality:
+	default:
+		return nil, fmt.Errorf("%w: unknown ranking %q", ErrInvalidLeaderboardQuery, rankBy)
+	}
+	from, to, err := LeaderboardWindow(period, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = DefaultLeaderboardLimit
+	}
+	if limit > MaxLeaderboardLimit {
+		limit = MaxLeaderboardLimit
+	}
+
+	entries, err := ts.leaderboardStore.GetWalkerLeaderboard(tenantID, from, to, rankBy, limit)
+	if err != nil {
+		return nil, err
+	}
+	if entries == nil {
+		entries = []models.LeaderboardEntry{}
+	}
+	return &models.Leaderboard{
+		TenantID: tenantID,
+		Period:   period,
+		RankBy:   rankBy,
+		From:     from,
+		To:       to,
+		Entries:  entries,
+	}, nil
+}
+
+// recordLeaderboardWalk records an ended session's walk on its tenant's
+// leaderboard. Failures are logged and never affect the session.
+func (ts *TrackingService) recordLeaderboardWalk(session *models.TrackingSession) {
+	stats, err := session.CalculateStatistics()
+	if err == nil {
+		err = ts.leaderboardStore.RecordWalkSummary(&models.WalkSummary{
+			WalkID:          session.WalkID(),
+			SessionID:       session.ID,
+			TenantID:        session.TenantID(),
+			WalkerID:        session.WalkerID(),
+			DogID:           session.DogID(),
+			DistanceMeters:  stats.TotalDistance,
+			DurationSeconds: stats.Duration.Seconds(),
+			QualityScore:    stats.QualityScore(),
+			EndedAt:         time.Now().UTC(),
+		})
+	}
+	if err != nil {
+		ts.logger.Warn("Failed to record walk on leaderboard",
+			zap.String("sessionID", session.ID),
+			zap.String("walkID", session.WalkID()),
+			zap.Error(err),
+		)
+	}
+}
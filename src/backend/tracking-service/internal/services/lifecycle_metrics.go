@@ -0,0 +1,115 @@
+package services
+
+import (
+	// sync for the sessions awaiting their first point (standard library)
+	"sync"
+	// time for the measured latencies (standard library)
+	"time"
+
+	// prometheus for lifecycle metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+
+	// models package that includes the TrackingSession struct
+	"src/backend/tracking-service/internal/models"
+	// utils for bounding the tenant label
+	"src/backend/tracking-service/internal/utils"
+)
+
+// noTenantLabel is the tenant label of sessions started without a tenant.
+const noTenantLabel = "none"
+
+// SessionLifecycleMetrics exports per tenant how long live tracking takes to
+// get going: the delay from session creation to the first valid point, and
+// how long stream subscribers wait for their first location frame.
+type SessionLifecycleMetrics struct {
+	tenants    *utils.LabelGuard
+	firstPoint *prometheus.HistogramVec
+	firstFrame *prometheus.HistogramVec
+
+	// awaiting holds the start of sessions without an accepted point yet,
+	// keyed by session ID.
+	awaiting sync.Map
+}
+
+// sessionStart is when a session awaiting its first point was created.
+type sessionStart struct {
+	tenant    string
+	startedAt time.Time
+}
+
+// NewSessionLifecycleMetrics creates the metrics, with at most maxTenants
+// distinct tenant labels, registering them on registry when non-nil.
+func NewSessionLifecycleMetrics(registry *prometheus.Registry, maxTenants int) *SessionLifecycleMetrics {
+	m := &SessionLifecycleMetrics{
+		tenants: utils.NewLabelGuard("tenant", maxTenants),
+		firstPoint: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "tracking_session_time_to_first_point_seconds",
+			Help:    "Delay from session creation to its first accepted location, by tenant",
+			Buckets: []float64{1, 2, 5, 10, 20, 30, 60, 120, 300, 600},
+		}, []string{"tenant"}),
+		firstFrame: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "tracking_stream_first_frame_seconds",
+			Help:    "Delay from a stream subscribing to a session to its first location frame, by tenant",
+			Buckets: []float64{0.1, 0.5, 1, 2, 5, 10, 30, 60, 120, 300},
+		}, []string{"tenant"}),
+	}
+	if registry != nil {
+		registry.MustRegister(m.firstPoint, m.firstFrame)
+	}
+	return m
+}
+
+// tenantLabel returns the bounded label value of tenantID.
+func (m *SessionLifecycleMetrics) tenantLabel(tenantID string) string {
+	if tenantID == "" {
+		tenantID = noTenantLabel
+	}
+	return m.tenants.Value(tenantID)
+}
+
+// SessionStarted starts timing the session's first point.
+func (m *SessionLifecycleMetrics) SessionStarted(session *models.TrackingSession) {
+	m.awaiting.Store(session.ID, sessionStart{tenant: session.TenantID(), startedAt: time.Now()})
+}
+
+// PointsAccepted records the session's time to first point when it is still
+// awaiting one. Concurrent batches record it once.
+func (m *SessionLifecycleMetrics) PointsAccepted(sessionID string) {
+	v, ok := m.awaiting.LoadAndDelete(sessionID)
+	if !ok {
+		return
+	}
+	start := v.(sessionStart)
+	m.firstPoint.WithLabelValues(m.tenantLabel(start.tenant)).Observe(time.Since(start.startedAt).Seconds())
+}
+
+// Forget stops timing a session that ended without points.
+func (m *SessionLifecycleMetrics) Forget(sessionID string) {
+	m.awaiting.Delete(sessionID)
+}
+
+// ObserveFirstFrame records how long a stream subscriber of tenantID's
+// session waited for its first frame.
+func (m *SessionLifecycleMetrics) ObserveFirstFrame(tenantID string, wait time.Duration) {
+	m.firstFrame.WithLabelValues(m.tenantLabel(tenantID)).Observe(wait.Seconds())
+}
+
+// SetSessionLifecycleMetrics enables time-to-first-point and stream setup
+// metrics. Passing nil disables them.
+func (ts *TrackingService) SetSessionLifecycleMetrics(metrics *SessionLifecycleMetrics) {
+	ts.lifecycle = metrics
+}
+
+// ObserveStreamFirstFrame records how long a stream subscriber of sessionID
+// waited for its first frame, labelled with the session's tenant, when
+// lifecycle metrics are enabled.
+func (ts *TrackingService) ObserveStreamFirstFrame(sessionID string, wait time.Duration) {
+	if ts.lifecycle == nil {
+		return
+	}
+	var tenantID string
+	if session, err := ts.getSession(sessionID); err == nil {
+		tenantID = session.TenantID()
+	}
+	ts.lifecycle.ObserveFirstFrame(tenantID, wait)
+}
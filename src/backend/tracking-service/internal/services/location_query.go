@@ -0,0 +1,56 @@
+package services
+
+import (
+	// errors for the disabled and invalid query sentinels (go1.21)
+	"errors"
+	// fmt for wrapping validation errors (go1.21)
+	"fmt"
+
+	// models package that includes the LocationQuery struct
+	"src/backend/tracking-service/internal/models"
+)
+
+var (
+	// ErrLocationQueryDisabled is returned by location queries when no query
+	// store is configured.
+	ErrLocationQueryDisabled = errors.New("location queries are not enabled")
+
+	// ErrInvalidLocationQuery wraps the validation failures of a location query.
+	ErrInvalidLocationQuery = errors.New("invalid location query")
+)
+
+// LocationQueryStore searches persisted points by polygon and time window. It
+// is implemented by repository.TimescaleRepository.
+type LocationQueryStore interface {
+	// QueryLocations returns the points inside the query's polygon and window,
+	// or per-walk counts of them.
+	QueryLocations(q models.LocationQuery) (*models.LocationQueryResult, error)
+}
+
+// SetLocationQueryStore enables spatio-temporal location queries. Passing nil
+// disables them.
+func (ts *TrackingService) SetLocationQueryStore(store LocationQueryStore) {
+	ts.locationQueries = store
+}
+
+// QueryLocations finds the points recorded inside a polygon during a time
+// window, for lost-dog searches. A dog filter covers the dog's completed walks
+// and, through the live sessions held here, its walks still in progress.
+func (ts *TrackingService) QueryLocations(q models.LocationQuery) (*models.LocationQueryResult, error) {
+	if ts.locationQueries == nil {
+		return nil, ErrLocationQueryDisabled
+	}
+	if err := q.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidLocationQuery, err)
+	}
+	if q.DogID != "" {
+		q.DogWalkIDs = nil
+		ts.activeSessions.Range(func(_, value interface{}) bool {
+			if session, ok := value.(*models.TrackingSession); ok && session.DogID() == q.DogID {
+				q.DogWalkIDs = append(q.DogWalkIDs, session.WalkID())
+			}
+			return true
+		})
+	}
+	return ts.locationQueries.QueryLocations(q)
+}
@@ -0,0 +1,452 @@
+package services
+
+import (
+	// bytes for Valhalla request bodies (go1.21)
+	"bytes"
+	// context for bounding map matching requests (go1.21)
+	"context"
+	// json for encoding requests and decoding provider responses (go1.21)
+	"encoding/json"
+	// errors for sentinel map matching errors (go1.21)
+	"errors"
+	// fmt for formatting request URLs and error messages (go1.21)
+	"fmt"
+	// io for draining error response bodies (go1.21)
+	"io"
+	// math for clamping search radiuses (go1.21)
+	"math"
+	// http for calling map matching services (go1.21)
+	"net/http"
+	// url for query encoding (go1.21)
+	"net/url"
+	// sort for matching points in time order (go1.21)
+	"sort"
+	// strconv for formatting timestamps and radiuses (go1.21)
+	"strconv"
+	// strings for building coordinate lists and trimming error bodies (go1.21)
+	"strings"
+	// sync for guarding the per-session context points (go1.21)
+	"sync"
+	// time for request timeouts (go1.21)
+	"time"
+
+	// prometheus for map matching metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+
+	// models package for the Location struct
+	"src/backend/tracking-service/internal/models"
+	// utils package for measuring how far points are snapped
+	"src/backend/tracking-service/internal/utils"
+)
+
+// Map matching services selectable by name.
+const (
+	MapMatcherOSRM     = "osrm"
+	MapMatcherValhalla = "valhalla"
+)
+
+// mapMatchStageName is the name of the map matching stage in the pipeline.
+const mapMatchStageName = "mapmatch"
+
+// maxMapMatchPoints bounds the points sent in one request, within the default
+// coordinate limit of OSRM's match service; longer tracks are matched in
+// consecutive chunks.
+const maxMapMatchPoints = 100
+
+// Search radius bounds, in meters, around each point for candidate roads and
+// paths, derived from the point's accuracy.
+const (
+	minMapMatchRadius = 5.0
+	maxMapMatchRadius = 50.0
+)
+
+// ErrNoMapMatch is returned when the service found no route through a track,
+// such as one walked entirely off the mapped network.
+var ErrNoMapMatch = errors.New("no route matches the track")
+
+// SnappedPoint is the position a map matcher snapped a point to. Matched is
+// false for points it could not place on the network.
+type SnappedPoint struct {
+	Latitude  float64
+	Longitude float64
+	Matched   bool
+}
+
+// MapMatcher snaps recorded tracks to the road and path network.
+type MapMatcher interface {
+	// Provider returns the service name, used to label metrics.
+	Provider() string
+	// Match returns the snapped position of each point of track, which is in
+	// time order, or ErrNoMapMatch.
+	Match(ctx context.Context, track []models.Location) ([]SnappedPoint, error)
+}
+
+// MapMatcherOptions selects and configures a map matching service.
+type MapMatcherOptions struct {
+	// Provider is one of MapMatcherOSRM or MapMatcherValhalla.
+	Provider string
+	// BaseURL is the service endpoint, e.g. a self-hosted OSRM instance.
+	BaseURL string
+	// Profile is the OSRM profile or Valhalla costing model, such as "foot"
+	// or "pedestrian".
+	Profile string
+	// Timeout bounds each HTTP request.
+	Timeout time.Duration
+}
+
+// NewMapMatcher creates the map matcher for opts.Provider.
+func NewMapMatcher(opts MapMatcherOptions) (MapMatcher, error) {
+	baseURL := strings.TrimRight(opts.BaseURL, "/")
+	if baseURL == "" {
+		return nil, fmt.Errorf("map matching requires a service URL")
+	}
+	httpClient := &http.Client{Timeout: opts.Timeout}
+	switch opts.Provider {
+	case MapMatcherOSRM:
+		profile := opts.Profile
+		if profile == "" {
+			profile = "foot"
+		}
+		return &OSRMMatcher{baseURL: baseURL, profile: profile, httpClient: httpClient}, nil
+	case MapMatcherValhalla:
+		costing := opts.Profile
+		if costing == "" {
+			costing = "pedestrian"
+		}
+		return &ValhallaMatcher{baseURL: baseURL, costing: costing, httpClient: httpClient}, nil
+	default:
+		return nil, fmt.Errorf("unknown map matching provider %q", opts.Provider)
+	}
+}
+
+// OSRMMatcher snaps tracks through the match service of an OSRM server.
+type OSRMMatcher struct {
+	baseURL    string
+	profile    string
+	httpClient *http.Client
+}
+
+// Provider implements MapMatcher.
+func (m *OSRMMatcher) Provider() string {
+	return MapMatcherOSRM
+}
+
+// Match implements MapMatcher.
+func (m *OSRMMatcher) Match(ctx context.Context, track []models.Location) ([]SnappedPoint, error) {
+	coords := make([]string, len(track))
+	timestamps := make([]string, len(track))
+	radiuses := make([]string, len(track))
+	for i, loc := range track {
+		coords[i] = formatCoordinate(loc.Longitude) + "," + formatCoordinate(loc.Latitude)
+		timestamps[i] = strconv.FormatInt(loc.Timestamp.Unix(), 10)
+		radiuses[i] = strconv.FormatFloat(mapMatchRadius(loc), 'f', 1, 64)
+	}
+	query := url.Values{
+		"timestamps": {strings.Join(timestamps, ";")},
+		"radiuses":   {strings.Join(radiuses, ";")},
+		"gaps":       {"ignore"},
+		"overview":   {"false"},
+	}
+	endpoint := fmt.Sprintf("%s/match/v1/%s/%s?%s", m.baseURL, url.PathEscape(m.profile), strings.Join(coords, ";"), query.Encode())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Code        string `json:"code"`
+		Message     string `json:"message"`
+		Tracepoints []*struct {
+			Location [2]float64 `json:"location"`
+		} `json:"tracepoints"`
+	}
+	// OSRM answers failed matches with an error status and a JSON code.
+	if err := doMapMatch(m.httpClient, req, MapMatcherOSRM, &result, http.StatusBadRequest); err != nil {
+		return nil, err
+	}
+	switch result.Code {
+	case "Ok":
+	case "NoMatch", "NoSegment":
+		return nil, ErrNoMapMatch
+	default:
+		return nil, fmt.Errorf("osrm map matching returned %s: %s", result.Code, result.Message)
+	}
+	if len(result.Tracepoints) != len(track) {
+		return nil, fmt.Errorf("osrm map matching returned %d tracepoints for %d points", len(result.Tracepoints), len(track))
+	}
+	snapped := make([]SnappedPoint, len(track))
+	for i, tp := range result.Tracepoints {
+		if tp == nil {
+			continue
+		}
+		snapped[i] = SnappedPoint{Latitude: tp.Location[1], Longitude: tp.Location[0], Matched: true}
+	}
+	return snapped, nil
+}
+
+// ValhallaMatcher snaps tracks through the trace_attributes service of a
+// Valhalla server.
+type ValhallaMatcher struct {
+	baseURL    string
+	costing    string
+	httpClient *http.Client
+}
+
+// Provider implements MapMatcher.
+func (m *ValhallaMatcher) Provider() string {
+	return MapMatcherValhalla
+}
+
+// valhallaNoMatchCodes are the Valhalla error codes of tracks it could not
+// match to the network.
+var valhallaNoMatchCodes = map[int]bool{171: true, 442: true, 443: true, 444: true}
+
+// Match implements MapMatcher.
+func (m *ValhallaMatcher) Match(ctx context.Context, track []models.Location) ([]SnappedPoint, error) {
+	type shapePoint struct {
+		Lat    float64 `json:"lat"`
+		Lon    float64 `json:"lon"`
+		Time   int64   `json:"time"`
+		Radius float64 `json:"radius"`
+	}
+	shape := make([]shapePoint, len(track))
+	for i, loc := range track {
+		shape[i] = shapePoint{Lat: loc.Latitude, Lon: loc.Longitude, Time: loc.Timestamp.Unix(), Radius: mapMatchRadius(loc)}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"shape":       shape,
+		"costing":     m.costing,
+		"shape_match": "map_snap",
+		"filters": map[string]interface{}{
+			"attributes": []string{"matched.point", "matched.type"},
+			"action":     "include",
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.baseURL+"/trace_attributes", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var result struct {
+		ErrorCode     int    `json:"error_code"`
+		Error         string `json:"error"`
+		MatchedPoints []struct {
+			Lat  float64 `json:"lat"`
+			Lon  float64 `json:"lon"`
+			Type string  `json:"type"`
+		} `json:"matched_points"`
+	}
+	// Valhalla answers failed matches with an error status and a JSON code.
+	if err := doMapMatch(m.httpClient, req, MapMatcherValhalla, &result, http.StatusBadRequest); err != nil {
+		return nil, err
+	}
+	if result.ErrorCode != 0 {
+		if valhallaNoMatchCodes[result.ErrorCode] {
+			return nil, ErrNoMapMatch
+		}
+		return nil, fmt.Errorf("valhalla map matching returned error %d: %s", result.ErrorCode, result.Error)
+	}
+	if len(result.MatchedPoints) != len(track) {
+		return nil, fmt.Errorf("valhalla map matching returned %d points for %d points", len(result.MatchedPoints), len(track))
+	}
+	snapped := make([]SnappedPoint, len(track))
+	for i, p := range result.MatchedPoints {
+		snapped[i] = SnappedPoint{Latitude: p.Lat, Longitude: p.Lon, Matched: p.Type == "matched"}
+	}
+	return snapped, nil
+}
+
+// doMapMatch sends a map matching request and decodes the JSON response into
+// out, both for successful responses and for errorStatus, with which the
+// service reports failed matches in the body.
+func doMapMatch(client *http.Client, req *http.Request, provider string, out interface{}, errorStatus int) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != errorStatus {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s map matching returned %s: %s", provider, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid %s map matching response: %w", provider, err)
+	}
+	return nil
+}
+
+// mapMatchRadius is the search radius around loc, its accuracy clamped to
+// the radius bounds.
+func mapMatchRadius(loc models.Location) float64 {
+	return math.Min(math.Max(loc.Accuracy, minMapMatchRadius), maxMapMatchRadius)
+}
+
+// MapMatchingStage is the optional filter stage that snaps each batch's points
+// to the road and path network before they accumulate distance, so tracks
+// recorded between tall buildings, where reflections scatter fixes across the
+// street, measure the route actually walked. The last points of each session
+// are matched along with the batch as context, since a lone point cannot be
+// matched. Points the service cannot place, or would move further than the
+// maximum snap distance, such as those walked across a park, keep their
+// recorded position.
+type MapMatchingStage struct {
+	matcher     MapMatcher
+	timeout     time.Duration
+	contextSize int
+	maxSnap     float64
+
+	mu     sync.Mutex
+	recent map[string][]models.Location
+
+	points      *prometheus.CounterVec
+	corrections prometheus.Histogram
+}
+
+// NewMapMatchingStage creates a stage matching through matcher, with each
+// request bounded by timeout, contextSize previous points of the session sent
+// as context, and points moved at most maxSnapMeters. Metrics are registered
+// on registry when it is non-nil.
+func NewMapMatchingStage(matcher MapMatcher, timeout time.Duration, contextSize int, maxSnapMeters float64, registry *prometheus.Registry) *MapMatchingStage {
+	s := &MapMatchingStage{
+		matcher:     matcher,
+		timeout:     timeout,
+		contextSize: contextSize,
+		maxSnap:     maxSnapMeters,
+		recent:      make(map[string][]models.Location),
+		points: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "tracking_map_match_points_total",
+				Help:        "Points passed through map matching, by outcome (snapped, unmatched, too_far, skipped or error).",
+				ConstLabels: prometheus.Labels{"provider": matcher.Provider()},
+			},
+			[]string{"outcome"},
+		),
+		corrections: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "tracking_map_match_correction_meters",
+			Help:    "Distance each snapped point was moved by map matching, in meters.",
+			Buckets: []float64{1, 2, 5, 10, 20, 30, 50},
+		}),
+	}
+	if registry != nil {
+		registry.MustRegister(s.points, s.corrections)
+	}
+	return s
+}
+
+// Name implements PipelineStage.
+func (s *MapMatchingStage) Name() string {
+	return mapMatchStageName
+}
+
+// Process implements PipelineStage. It snaps the batch's points in place,
+// in time order.
+func (s *MapMatchingStage) Process(batch *PipelineBatch) error {
+	if len(batch.Locations) == 0 {
+		return nil
+	}
+	ordered := append([]*models.Location(nil), batch.Locations...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Timestamp.Before(ordered[j].Timestamp)
+	})
+
+	s.mu.Lock()
+	track := append([]models.Location(nil), s.recent[batch.SessionID]...)
+	contextLen := len(track)
+	for _, loc := range ordered {
+		track = append(track, *loc)
+	}
+	if keep := len(track) - s.contextSize; keep > 0 {
+		s.recent[batch.SessionID] = append([]models.Location(nil), track[keep:]...)
+	} else {
+		s.recent[batch.SessionID] = append([]models.Location(nil), track...)
+	}
+	s.mu.Unlock()
+
+	if len(track) < 2 {
+		s.points.WithLabelValues("skipped").Add(float64(len(ordered)))
+		return nil
+	}
+
+	var firstErr error
+	for start := 0; start < len(track); start += maxMapMatchPoints {
+		end := start + maxMapMatchPoints
+		if end > len(track) {
+			end = len(track)
+		}
+		if end <= contextLen {
+			continue
+		}
+		if err := s.matchChunk(track[start:end], ordered, contextLen-start); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// matchChunk matches one chunk of the track and snaps the batch points in it.
+// offset is the index in chunk of ordered[0], negative when the chunk starts
+// after it.
+func (s *MapMatchingStage) matchChunk(chunk []models.Location, ordered []*models.Location, offset int) error {
+	first, last := offset, offset+len(ordered)
+	if first < 0 {
+		first = 0
+	}
+	if last > len(chunk) {
+		last = len(chunk)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	snapped, err := s.matcher.Match(ctx, chunk)
+	if errors.Is(err, ErrNoMapMatch) {
+		s.points.WithLabelValues("unmatched").Add(float64(last - first))
+		return nil
+	}
+	if err != nil {
+		s.points.WithLabelValues("error").Add(float64(last - first))
+		return fmt.Errorf("%s map matching: %w", s.matcher.Provider(), err)
+	}
+
+	for i := first; i < last; i++ {
+		loc, point := ordered[i-offset], snapped[i]
+		if !point.Matched {
+			s.points.WithLabelValues("unmatched").Inc()
+			continue
+		}
+		moved := *loc
+		moved.Latitude, moved.Longitude = point.Latitude, point.Longitude
+		distance, distErr := utils.CalculateDistance(loc, &moved)
+		if distErr != nil || distance > s.maxSnap {
+			s.points.WithLabelValues("too_far").Inc()
+			continue
+		}
+		loc.Latitude, loc.Longitude = point.Latitude, point.Longitude
+		s.points.WithLabelValues("snapped").Inc()
+		s.corrections.Observe(distance)
+	}
+	return nil
+}
+
+// Forget drops the context points of an ended session.
+func (s *MapMatchingStage) Forget(sessionID string) {
+	s.mu.Lock()
+	delete(s.recent, sessionID)
+	s.mu.Unlock()
+}
+
+// SetMapMatching enables snapping points to the road and path network with
+// stage, registered as a custom stage of the filter phase so it runs after
+// smoothing and before points accumulate distance. Its failures are logged
+// and leave the batch's points as recorded.
+func (ts *TrackingService) SetMapMatching(stage *MapMatchingStage) error {
+	if err := ts.pipeline.Register(PhaseFilter, stage); err != nil {
+		return err
+	}
+	ts.mapMatching = stage
+	return nil
+}
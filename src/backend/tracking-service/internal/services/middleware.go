@@ -0,0 +1,201 @@
+package services
+
+import (
+	// errors for the middleware sentinels (go1.21)
+	"errors"
+	// fmt for describing recovered panics (go1.21)
+	"fmt"
+	// time for call durations (go1.21)
+	"time"
+
+	// prometheus for call latency metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// utils package for capturing diagnostics bundles of recovered panics
+	"src/backend/tracking-service/internal/utils"
+)
+
+// ErrMissingScope is returned, without calling the method, when a call scoped
+// to a session, walk, walker or other resource names none.
+var ErrMissingScope = errors.New("call is missing the identifier of the resource it concerns")
+
+// ErrMethodPanicked is returned when a method panicked; the panic was
+// recovered by RecoveryMiddleware.
+var ErrMethodPanicked = errors.New("tracking service method panicked")
+
+// Kinds of resource a method call can be scoped to.
+const (
+	ScopeSession = "session"
+	ScopeWalk    = "walk"
+	ScopeWalker  = "walker"
+	ScopeTenant  = "tenant"
+	ScopeRegion  = "region"
+	ScopeOwner   = "owner"
+)
+
+// MethodCall describes one call of a TrackingAPI method passing through the
+// middleware chain. ScopeKind names the kind of resource the call concerns,
+// empty for calls that concern none, and ScopeID identifies it. RequestID is
+// the X-Request-ID of the request the call serves, when known.
+type MethodCall struct {
+	Method    string
+	ScopeKind string
+	ScopeID   string
+	RequestID string
+}
+
+// sessionID returns the session the call concerns, or "".
+func (c MethodCall) sessionID() string {
+	if c.ScopeKind == ScopeSession {
+		return c.ScopeID
+	}
+	return ""
+}
+
+// Invoker runs a method call, returning the method's error.
+type Invoker func(call MethodCall) error
+
+// Middleware wraps an Invoker with a cross-cutting concern, such as logging
+// or metrics, applied to every TrackingAPI method alike.
+type Middleware func(next Invoker) Invoker
+
+// Chain composes middlewares so the first is outermost: it sees a call
+// first and its result last.
+func Chain(middlewares ...Middleware) Middleware {
+	return func(next Invoker) Invoker {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			next = middlewares[i](next)
+		}
+		return next
+	}
+}
+
+// LoggingMiddleware logs every call with its duration at debug level, and
+// failed calls at warn level, with the request ID of calls that have one.
+func LoggingMiddleware(logger *zap.Logger) Middleware {
+	return func(next Invoker) Invoker {
+		return func(call MethodCall) error {
+			start := time.Now()
+			err := next(call)
+			fields := []zap.Field{
+				zap.String("method", call.Method),
+				zap.String("scope", call.ScopeKind),
+				zap.String("scopeID", call.ScopeID),
+				zap.Duration("duration", time.Since(start)),
+			}
+			if call.RequestID != "" {
+				fields = append(fields, zap.String("requestID", call.RequestID))
+			}
+			if err != nil {
+				logger.Warn("Tracking service call failed", append(fields, zap.Error(err))...)
+			} else {
+				logger.Debug("Tracking service call", fields...)
+			}
+			return err
+		}
+	}
+}
+
+// MetricsMiddleware observes the duration of every call by method and
+// outcome, registering its histogram on registry when non-nil.
+func MetricsMiddleware(registry *prometheus.Registry) Middleware {
+	duration := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "tracking_service_call_duration_seconds",
+		Help:    "Time taken by tracking service calls, by method and outcome (ok or error)",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "outcome"})
+	if registry != nil {
+		registry.MustRegister(duration)
+	}
+	return func(next Invoker) Invoker {
+		return func(call MethodCall) error {
+			start := time.Now()
+			err := next(call)
+			outcome := "ok"
+			if err != nil {
+				outcome = "error"
+			}
+			duration.WithLabelValues(call.Method, outcome).Observe(time.Since(start).Seconds())
+			return err
+		}
+	}
+}
+
+// Tracer records the steps of a call. It is implemented by
+// utils.DiagnosticsRecorder, whose trail then shows the service calls that
+// preceded a panic.
+type Tracer interface {
+	Trace(component, sessionID, detail string)
+}
+
+// TracingMiddleware records the start and outcome of every call with tracer,
+// noting the request ID of calls that have one.
+func TracingMiddleware(tracer Tracer) Middleware {
+	return func(next Invoker) Invoker {
+		return func(call MethodCall) error {
+			component := "service." + call.Method
+			startDetail := "start " + call.ScopeKind + " " + call.ScopeID
+			if call.RequestID != "" {
+				startDetail += " request " + call.RequestID
+			}
+			tracer.Trace(component, call.sessionID(), startDetail)
+			start := time.Now()
+			err := next(call)
+			detail := "ok in " + time.Since(start).String()
+			if err != nil {
+				detail = "failed in " + time.Since(start).String() + ": " + err.Error()
+			}
+			tracer.Trace(component, call.sessionID(), detail)
+			return err
+		}
+	}
+}
+
+// RequireScopeMiddleware rejects calls scoped to a resource that name none
+// with ErrMissingScope, before the method runs.
+func RequireScopeMiddleware() Middleware {
+	return func(next Invoker) Invoker {
+		return func(call MethodCall) error {
+			if call.ScopeKind != "" && call.ScopeID == "" {
+				return fmt.Errorf("%w: %s requires a %s", ErrMissingScope, call.Method, call.ScopeKind)
+			}
+			return next(call)
+		}
+	}
+}
+
+// AuthorizeMiddleware runs authorize before every call, rejecting the call
+// with the error it returns.
+func AuthorizeMiddleware(authorize func(call MethodCall) error) Middleware {
+	return func(next Invoker) Invoker {
+		return func(call MethodCall) error {
+			if err := authorize(call); err != nil {
+				return err
+			}
+			return next(call)
+		}
+	}
+}
+
+// RecoveryMiddleware turns a panic in a method into ErrMethodPanicked,
+// capturing a diagnostics bundle with diagnostics, which may be nil.
+func RecoveryMiddleware(diagnostics *utils.DiagnosticsRecorder) Middleware {
+	return func(next Invoker) Invoker {
+		return func(call MethodCall) (err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					dctx := &utils.DiagnosticContext{SessionID: call.sessionID()}
+					if call.RequestID != "" {
+						dctx.Fields = map[string]string{"requestID": call.RequestID}
+					}
+					diagnostics.Capture("service."+call.Method, p, dctx)
+					err = fmt.Errorf("%w: %s: %v", ErrMethodPanicked, call.Method, p)
+				}
+			}()
+			return next(call)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package services
+
+import (
+	// json for encoding owner alerts (go1.21)
+	"encoding/json"
+	// errors for the owner alert sentinels (go1.21)
+	"errors"
+	// fmt for wrapping validation errors and building topics (go1.21)
+	"fmt"
+	// sync for the per-session alert state (go1.21)
+	"sync"
+	// time for preference timestamps (go1.21)
+	"time"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// models package that includes the OwnerAlertPreference struct
+	"src/backend/tracking-service/internal/models"
+
+	// utils package for MQTT message classes
+	"src/backend/tracking-service/internal/utils"
+)
+
+// ownerAlertStageName is the name of the publish stage evaluating owners'
+// alert radii.
+const ownerAlertStageName = "owner.alerts"
+
+var (
+	// ErrOwnerAlertsDisabled is returned by owner alert preferences when no
+	// owner alert store is configured.
+	ErrOwnerAlertsDisabled = errors.New("owner alerts are not enabled")
+
+	// ErrInvalidOwnerAlert wraps the validation failures of an owner alert
+	// preference.
+	ErrInvalidOwnerAlert = errors.New("invalid owner alert preference")
+)
+
+// OwnerAlertStore persists owners' alert radii. It is implemented by
+// repository.TimescaleRepository.
+type OwnerAlertStore interface {
+	// SaveOwnerAlertPreference inserts or replaces an owner's radius for a dog.
+	SaveOwnerAlertPreference(pref *models.OwnerAlertPreference) error
+
+	// GetOwnerAlertPreference returns an owner's radius for a dog.
+	GetOwnerAlertPreference(ownerID, dogID string) (*models.OwnerAlertPreference, error)
+
+	// GetOwnerAlertPreferencesForDog returns the radius of every owner of a
+	// dog who set one.
+	GetOwnerAlertPreferencesForDog(dogID string) ([]models.OwnerAlertPreference, error)
+
+	// DeleteOwnerAlertPreference removes an owner's radius for a dog.
+	DeleteOwnerAlertPreference(ownerID, dogID string) error
+}
+
+// ownerAlertWatch is the owner alert state of one session: the center radii
+// are measured from, the radii of the dog's owners when the walk's first
+// point arrived, and which owners were last told the dog is outside theirs.
+type ownerAlertWatch struct {
+	mu        sync.Mutex
+	centerLat float64
+	centerLon float64
+	prefs     []models.OwnerAlertPreference
+	outside   map[string]bool
+	lastAt    time.Time
+}
+
+// SetOwnerAlertStore enables owner alerts: each accepted batch is checked
+// against the alert radii the dog's owners set, next to the tenant geofence,
+// and owners are notified when the dog leaves or returns within theirs.
+// Passing nil disables them.
+func (ts *TrackingService) SetOwnerAlertStore(store OwnerAlertStore) {
+	ts.ownerAlerts = store
+	ts.pipeline.Unregister(ownerAlertStageName)
+	if store == nil {
+		return
+	}
+	if err := ts.pipeline.Register(PhasePublish, StageFunc(ownerAlertStageName, ts.ownerAlertStage)); err != nil {
+		ts.logger.Error("Failed to register owner alert stage", zap.Error(err))
+	}
+}
+
+// GetOwnerAlertPreference returns an owner's alert radius for a dog. Store
+// errors are returned unchanged, so a dog without one is
+// repository.ErrNotFound.
+func (ts *TrackingService) GetOwnerAlertPreference(ownerID, dogID string) (*models.OwnerAlertPreference, error) {
+	if ts.ownerAlerts == nil {
+		return nil, ErrOwnerAlertsDisabled
+	}
+	return ts.ownerAlerts.GetOwnerAlertPreference(ownerID, dogID)
+}
+
+// PutOwnerAlertPreference validates and stores an owner's alert radius for a
+// dog. It applies from the dog's next walk; a walk in progress keeps the radii
+// it started with.
+func (ts *TrackingService) PutOwnerAlertPreference(pref *models.OwnerAlertPreference) error {
+	if ts.ownerAlerts == nil {
+		return ErrOwnerAlertsDisabled
+	}
+	if err := pref.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidOwnerAlert, err)
+	}
+	if pref.RadiusKm < MinRadius || pref.RadiusKm > MaxRadius {
+		return fmt.Errorf("%w: radiusKm must be between %.1f and %.1f", ErrInvalidOwnerAlert, MinRadius, MaxRadius)
+	}
+	pref.UpdatedAt = time.Now().UTC()
+	return ts.ownerAlerts.SaveOwnerAlertPreference(pref)
+}
+
+// DeleteOwnerAlertPreference removes an owner's alert radius for a dog, so
+// the owner is no longer notified from the dog's next walk.
+func (ts *TrackingService) DeleteOwnerAlertPreference(ownerID, dogID string) error {
+	if ts.ownerAlerts == nil {
+		return ErrOwnerAlertsDisabled
+	}
+	return ts.ownerAlerts.DeleteOwnerAlertPreference(ownerID, dogID)
+}
+
+// ownerAlertStage checks the newest point of the batch against the owners'
+// alert radii.
+func (ts *TrackingService) ownerAlertStage(batch *PipelineBatch) error {
+	if len(batch.Accepted) == 0 {
+		return nil
+	}
+	ts.evaluateOwnerAlerts(batch.SessionID, batch.Session, &batch.Accepted[len(batch.Accepted)-1])
+	return nil
+}
+
+// evaluateOwnerAlerts notifies the owners whose alert radius loc crosses,
+// leaving or returning within it. Points older than the last one evaluated
+// are ignored, so the health monitor and ingestion can both evaluate a
+// session.
+func (ts *TrackingService) evaluateOwnerAlerts(sessionID string, session *models.TrackingSession, loc *models.Location) {
+	if ts.ownerAlerts == nil {
+		return
+	}
+	watch := ts.ownerAlertWatch(sessionID, session, loc)
+	if watch == nil || len(watch.prefs) == 0 {
+		return
+	}
+	distance := models.HaversineDistance{}.Distance(watch.centerLat, watch.centerLon, loc.Latitude, loc.Longitude) / 1000
+
+	watch.mu.Lock()
+	if loc.Timestamp.Before(watch.lastAt) {
+		watch.mu.Unlock()
+		return
+	}
+	watch.lastAt = loc.Timestamp
+	var alerts []models.OwnerBoundaryAlert
+	for _, pref := range watch.prefs {
+		outside := distance > pref.RadiusKm
+		if outside == watch.outside[pref.OwnerID] {
+			continue
+		}
+		watch.outside[pref.OwnerID] = outside
+		eventType := models.OwnerAlertEventReturn
+		if outside {
+			eventType = models.OwnerAlertEventExit
+		}
+		alerts = append(alerts, models.OwnerBoundaryAlert{
+			EventType:  eventType,
+			OwnerID:    pref.OwnerID,
+			DogID:      pref.DogID,
+			WalkID:     session.WalkID(),
+			SessionID:  sessionID,
+			RadiusKm:   pref.RadiusKm,
+			DistanceKm: distance,
+			Latitude:   loc.Latitude,
+			Longitude:  loc.Longitude,
+			Timestamp:  loc.Timestamp,
+		})
+	}
+	watch.mu.Unlock()
+
+	for _, alert := range alerts {
+		ts.logger.Info("Owner alert radius crossed",
+			zap.String("sessionID", sessionID),
+			zap.String("ownerID", alert.OwnerID),
+			zap.String("eventType", alert.EventType),
+			zap.Float64("radiusKm", alert.RadiusKm),
+			zap.Float64("distanceKm", alert.DistanceKm),
+		)
+		ts.publishOwnerAlert(alert)
+	}
+}
+
+// ownerAlertWatch returns the owner alert state of a session, resolving it on
+// the session's first evaluation: the radii of the dog's owners, measured from
+// the center of the walk's first active inclusion geofence, or from the walk's
+// first point when it has none. It returns nil when the radii cannot be
+// loaded, so they are tried again with the next point.
+func (ts *TrackingService) ownerAlertWatch(sessionID string, session *models.TrackingSession, loc *models.Location) *ownerAlertWatch {
+	if v, ok := ts.ownerWatches.Load(sessionID); ok {
+		return v.(*ownerAlertWatch)
+	}
+	prefs, err := ts.ownerAlerts.GetOwnerAlertPreferencesForDog(session.DogID())
+	if err != nil {
+		ts.logger.Warn("Failed to load owner alert preferences",
+			zap.String("sessionID", sessionID),
+			zap.String("dogID", session.DogID()),
+			zap.Error(err),
+		)
+		return nil
+	}
+	watch := &ownerAlertWatch{prefs: prefs, outside: make(map[string]bool, len(prefs))}
+	watch.centerLat, watch.centerLon = ts.walkBoundaryCenter(session, loc)
+	v, _ := ts.ownerWatches.LoadOrStore(sessionID, watch)
+	return v.(*ownerAlertWatch)
+}
+
+// walkBoundaryCenter returns the center owners' radii are measured from: that
+// of the walk's first active inclusion geofence, or the walk's first point,
+// falling back to loc.
+func (ts *TrackingService) walkBoundaryCenter(session *models.TrackingSession, loc *models.Location) (float64, float64) {
+	if ts.geofenceStore != nil {
+		geofences, err := ts.LoadGeofences(session.WalkID())
+		if err != nil {
+			ts.logger.Warn("Failed to load walk geofences for owner alerts",
+				zap.String("walkID", session.WalkID()),
+				zap.Error(err),
+			)
+		}
+		for _, g := range geofences {
+			if g.Active && !g.IsExclusion() {
+				return g.CenterLatitude, g.CenterLongitude
+			}
+		}
+	}
+	if history := session.LocationHistory(); len(history) > 0 {
+		return history[0].Latitude, history[0].Longitude
+	}
+	return loc.Latitude, loc.Longitude
+}
+
+// forgetOwnerAlerts drops the owner alert state of an ended session.
+func (ts *TrackingService) forgetOwnerAlerts(sessionID string) {
+	ts.ownerWatches.Delete(sessionID)
+}
+
+// publishOwnerAlert sends an owner alert to the owner's alert topic. Failures
+// are logged and otherwise ignored.
+func (ts *TrackingService) publishOwnerAlert(alert models.OwnerBoundaryAlert) {
+	if ts.mqttClient == nil {
+		return
+	}
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		return
+	}
+	topic := fmt.Sprintf("tracking/owners/%s/alerts", alert.OwnerID)
+	if err := ts.mqttClient.Publish(utils.MessageClassAlert, topic, payload); err != nil {
+		ts.logger.Error("Failed to publish owner alert",
+			zap.String("sessionID", alert.SessionID),
+			zap.String("ownerID", alert.OwnerID),
+			zap.String("eventType", alert.EventType),
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	// errors for the disabled sentinel (go1.21)
+	"errors"
+	// fmt for wrapping store errors (go1.21)
+	"fmt"
+
+	// models package that includes the Location and PageCursor structs
+	"src/backend/tracking-service/internal/models"
+)
+
+// DefaultPageSize is the number of items returned by a paginated listing that
+// does not specify a limit, and MaxPageSize is the most a listing returns
+// whatever limit is requested.
+const (
+	DefaultPageSize = 100
+	MaxPageSize     = 1000
+)
+
+// ErrHistoryPagesDisabled is returned by paginated history queries when no
+// history page store is configured.
+var ErrHistoryPagesDisabled = errors.New("paginated location history is not enabled")
+
+// HistoryPageStore loads the persisted track of a walk a page at a time. It is
+// implemented by repository.TimescaleRepository.
+type HistoryPageStore interface {
+	// GetLocationHistoryPage returns up to limit points after the cursor
+	// matching the filter, ordered by timestamp then ID.
+	GetLocationHistoryPage(walkID string, after models.PageCursor, limit int, filter models.HistoryFilter) ([]models.Location, error)
+}
+
+// SetHistoryPageStore enables paginated location history. Passing nil
+// disables it.
+func (ts *TrackingService) SetHistoryPageStore(store HistoryPageStore) {
+	ts.historyPages = store
+}
+
+// GetLocationHistoryPage returns a page of the persisted track of a session's
+// walk, oldest first, narrowed and down-sampled by filter, with the cursor of
+// the next page, or nil on the last one. A limit of zero uses DefaultPageSize.
+func (ts *TrackingService) GetLocationHistoryPage(sessionID string, after models.PageCursor, limit int, filter models.HistoryFilter) ([]models.Location, *models.PageCursor, error) {
+	if ts.historyPages == nil {
+		return nil, nil, ErrHistoryPagesDisabled
+	}
+	session, err := ts.resolveSession(sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	limit = pageLimit(limit)
+	locations, err := ts.historyPages.GetLocationHistoryPage(session.WalkID(), after, limit+1, filter)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load history page of walk %s: %w", session.WalkID(), err)
+	}
+	var next *models.PageCursor
+	if len(locations) > limit {
+		locations = locations[:limit]
+		last := locations[limit-1]
+		next = &models.PageCursor{Timestamp: last.Timestamp, ID: last.ID}
+	}
+	if locations == nil {
+		locations = []models.Location{}
+	}
+	return locations, next, nil
+}
+
+// pageLimit returns the page size for a requested limit: DefaultPageSize for
+// zero or less, capped at MaxPageSize. Listings fetch one item more to learn
+// whether a next page exists.
+func pageLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		return MaxPageSize
+	}
+	return limit
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	// errors for the registration sentinels (go1.21)
+	"errors"
+	// fmt for wrapping registration errors and recovered panics (go1.21)
+	"fmt"
+	// sync for guarding the registered stages (go1.21)
+	"sync"
+	// time for the batch ingest timestamp (go1.21)
+	"time"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// models package that includes the Location and TrackingSession structs
+	"src/backend/tracking-service/internal/models"
+)
+
+// PipelinePhase names a phase of location batch processing. Batches pass the
+// phases in the order they are declared.
+type PipelinePhase string
+
+// Phases of the location pipeline.
+const (
+	// PhaseValidate reprojects the points and drops the invalid and
+	// already stored ones.
+	PhaseValidate PipelinePhase = "validate"
+	// PhaseFilter smooths and thins the valid points before they count.
+	PhaseFilter PipelinePhase = "filter"
+	// PhaseEnrich annotates the points; it has no built-in stage.
+	PhaseEnrich PipelinePhase = "enrich"
+	// PhasePersist adds the points to the session and stores them.
+	PhasePersist PipelinePhase = "persist"
+	// PhasePublish delivers the stored points to MQTT and live subscribers.
+	PhasePublish PipelinePhase = "publish"
+)
+
+// pipelinePhases lists the phases in the order batches pass them.
+var pipelinePhases = []PipelinePhase{PhaseValidate, PhaseFilter, PhaseEnrich, PhasePersist, PhasePublish}
+
+var (
+	// ErrUnknownPipelinePhase is returned when registering a stage for a phase
+	// the pipeline does not have.
+	ErrUnknownPipelinePhase = errors.New("unknown pipeline phase")
+
+	// ErrDuplicatePipelineStage is returned when registering a stage under a
+	// name already in use.
+	ErrDuplicatePipelineStage = errors.New("pipeline stage already registered")
+)
+
+// PipelineBatch is one batch of points moving through the pipeline. Stages
+// read and amend it in place.
+type PipelineBatch struct {
+	// SessionID and Session identify the session the batch belongs to.
+	SessionID string
+	Session   *models.TrackingSession
+
+	// Locations are the points still in the batch: after validation, the valid
+	// ones. Stages before PhasePersist may drop or amend them.
+	Locations []*models.Location
+
+	// Accepted are the points the session took, in batch order. It is set by
+	// PhasePersist and read by PhasePublish.
+	Accepted []models.Location
+
+	// Result is the outcome reported to the caller.
+	Result *BatchResult
+
+	// IngestedAt is when processing of the batch started.
+	IngestedAt time.Time
+
+	// onCommit reports the database write to sequenced uploads; see processBatch.
+	onCommit func(error)
+
+	// rejected are the points the built-in stages rejected, quarantined once
+	// the batch finishes when quarantine is enabled.
+	rejected []rejectedPoint
+}
+
+// TenantID returns the account of the batch's session, empty when not given.
+func (b *PipelineBatch) TenantID() string {
+	return b.Session.TenantID()
+}
+
+// PipelineStage is a step of location batch processing. Custom stages run
+// after the built-in stage of their phase, in registration order. Their
+// errors and panics are logged and never fail the batch, so a tenant's hook
+// cannot hold back ingestion.
+type PipelineStage interface {
+	// Name identifies the stage in logs; it must be unique in the pipeline.
+	Name() string
+
+	// Process handles the batch.
+	Process(batch *PipelineBatch) error
+}
+
+// funcStage is a PipelineStage backed by a function.
+type funcStage struct {
+	name string
+	fn   func(*PipelineBatch) error
+}
+
+func (s funcStage) Name() string                       { return s.name }
+func (s funcStage) Process(batch *PipelineBatch) error { return s.fn(batch) }
+
+// StageFunc returns a stage named name that processes batches with fn.
+func StageFunc(name string, fn func(*PipelineBatch) error) PipelineStage {
+	return funcStage{name: name, fn: fn}
+}
+
+// tenantStage runs a stage for the sessions of some tenants only.
+type tenantStage struct {
+	PipelineStage
+	tenants map[string]struct{}
+}
+
+func (s tenantStage) Process(batch *PipelineBatch) error {
+	if _, ok := s.tenants[batch.TenantID()]; !ok {
+		return nil
+	}
+	return s.PipelineStage.Process(batch)
+}
+
+// TenantStage scopes stage to the batches of the listed tenants' sessions.
+func TenantStage(stage PipelineStage, tenantIDs ...string) PipelineStage {
+	tenants := make(map[string]struct{}, len(tenantIDs))
+	for _, id := range tenantIDs {
+		tenants[id] = struct{}{}
+	}
+	return tenantStage{PipelineStage: stage, tenants: tenants}
+}
+
+// PipelineStageInfo describes a stage of the pipeline.
+type PipelineStageInfo struct {
+	Phase   PipelinePhase `json:"phase"`
+	Name    string        `json:"name"`
+	Builtin bool          `json:"builtin"`
+}
+
+// LocationPipeline runs location batches through the built-in stages of each
+// phase and the custom stages registered for it.
+type LocationPipeline struct {
+	logger  *zap.Logger
+	builtin map[PipelinePhase]PipelineStage
+
+	mu     sync.RWMutex
+	custom map[PipelinePhase][]PipelineStage
+}
+
+// newLocationPipeline creates the service's pipeline with its built-in stages.
+func (ts *TrackingService) newLocationPipeline() *LocationPipeline {
+	return &LocationPipeline{
+		logger: ts.logger,
+		builtin: map[PipelinePhase]PipelineStage{
+			PhaseValidate: StageFunc("builtin.validate", ts.validateStage),
+			PhaseFilter:   StageFunc("builtin.smooth", ts.smoothStage),
+			PhasePersist:  StageFunc("builtin.persist", ts.persistStage),
+			PhasePublish:  StageFunc("builtin.publish", ts.publishStage),
+		},
+		custom: make(map[PipelinePhase][]PipelineStage),
+	}
+}
+
+// Pipeline returns the pipeline location batches are processed by, for
+// registering custom stages.
+func (ts *TrackingService) Pipeline() *LocationPipeline {
+	return ts.pipeline
+}
+
+// Register adds stage to the end of phase's custom stages.
+func (p *LocationPipeline) Register(phase PipelinePhase, stage PipelineStage) error {
+	if !validPipelinePhase(phase) {
+		return fmt.Errorf("%w: %q", ErrUnknownPipelinePhase, phase)
+	}
+	name := stage.Name()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, s := range p.builtin {
+		if s.Name() == name {
+			return fmt.Errorf("%w: %q", ErrDuplicatePipelineStage, name)
+		}
+	}
+	for _, stages := range p.custom {
+		for _, s := range stages {
+			if s.Name() == name {
+				return fmt.Errorf("%w: %q", ErrDuplicatePipelineStage, name)
+			}
+		}
+	}
+	p.custom[phase] = append(p.custom[phase], stage)
+	return nil
+}
+
+// Unregister removes the custom stage named name, reporting whether it was
+// registered. Batches already past it are unaffected.
+func (p *LocationPipeline) Unregister(name string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for phase, stages := range p.custom {
+		for i, s := range stages {
+			if s.Name() == name {
+				p.custom[phase] = append(stages[:i:i], stages[i+1:]...)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Stages lists the stages in the order batches pass them.
+func (p *LocationPipeline) Stages() []PipelineStageInfo {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var infos []PipelineStageInfo
+	for _, phase := range pipelinePhases {
+		if s, ok := p.builtin[phase]; ok {
+			infos = append(infos, PipelineStageInfo{Phase: phase, Name: s.Name(), Builtin: true})
+		}
+		for _, s := range p.custom[phase] {
+			infos = append(infos, PipelineStageInfo{Phase: phase, Name: s.Name()})
+		}
+	}
+	return infos
+}
+
+// Run passes batch through every phase. It stops at the first error of a
+// built-in stage and returns it.
+func (p *LocationPipeline) Run(batch *PipelineBatch) error {
+	for _, phase := range pipelinePhases {
+		if s, ok := p.builtin[phase]; ok {
+			if err := s.Process(batch); err != nil {
+				return err
+			}
+		}
+		p.mu.RLock()
+		stages := append([]PipelineStage(nil), p.custom[phase]...)
+		p.mu.RUnlock()
+		for _, s := range stages {
+			if err := runCustomStage(s, batch); err != nil {
+				p.logger.Warn("Custom pipeline stage failed",
+					zap.String("sessionID", batch.SessionID),
+					zap.String("phase", string(phase)),
+					zap.String("stage", s.Name()),
+					zap.Error(err),
+				)
+			}
+		}
+	}
+	return nil
+}
+
+// runCustomStage runs a custom stage, turning a panic into an error.
+func runCustomStage(s PipelineStage, batch *PipelineBatch) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return s.Process(batch)
+}
+
+// validPipelinePhase reports whether phase is a phase of the pipeline.
+func validPipelinePhase(phase PipelinePhase) bool {
+	for _, p := range pipelinePhases {
+		if p == phase {
+			return true
+		}
+	}
+	return false
+}
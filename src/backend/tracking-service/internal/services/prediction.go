@@ -0,0 +1,315 @@
+package services
+
+import (
+	// bytes for encoding prediction requests (go1.21)
+	"bytes"
+	// context for bounding prediction lookups (go1.21)
+	"context"
+	// json for the prediction endpoint payloads (go1.21)
+	"encoding/json"
+	// errors for sentinel prediction errors (go1.21)
+	"errors"
+	// fmt for formatting error messages (go1.21)
+	"fmt"
+	// io for draining error response bodies (go1.21)
+	"io"
+	// math for absolute percentage errors (go1.21)
+	"math"
+	// http for calling the prediction endpoint (go1.21)
+	"net/http"
+	// sort for medians of past walks (go1.21)
+	"sort"
+	// strings for trimming error bodies (go1.21)
+	"strings"
+	// time for request timeouts and the history window (go1.21)
+	"time"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+	// prometheus for prediction metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+
+	// models package for WalkPrediction and WalkSummary
+	"src/backend/tracking-service/internal/models"
+)
+
+// ErrNoWalkHistory is returned by the heuristic predictor when the dog has no
+// past walks to predict from.
+var ErrNoWalkHistory = errors.New("no walk history to predict from")
+
+// minWalkerHistory is the number of walks with the current walker the
+// heuristic needs before it ignores the dog's walks with other walkers.
+const minWalkerHistory = 3
+
+// WalkHistoryStore reads the completed walks predictions are made from.
+// It is implemented by repository.TimescaleRepository.
+type WalkHistoryStore interface {
+	GetRecentWalkSummaries(dogID, walkerID string, since time.Time, limit int) ([]models.WalkSummary, error)
+}
+
+// PredictionRequest describes a walk that has just started, with the dog's
+// recent walks, most recent with the same walker first.
+type PredictionRequest struct {
+	SessionID string               `json:"sessionId"`
+	WalkID    string               `json:"walkId"`
+	TenantID  string               `json:"tenantId,omitempty"`
+	WalkerID  string               `json:"walkerId"`
+	DogID     string               `json:"dogId"`
+	StartedAt time.Time            `json:"startedAt"`
+	History   []models.WalkSummary `json:"history"`
+}
+
+// WalkPredictor estimates the duration and distance of a walk at its start.
+type WalkPredictor interface {
+	PredictWalk(ctx context.Context, req *PredictionRequest) (*models.WalkPrediction, error)
+}
+
+// HeuristicWalkPredictor predicts the median duration and distance of the
+// dog's recent walks with the same walker, or of all its recent walks when it
+// has fewer than three with this walker.
+type HeuristicWalkPredictor struct{}
+
+// PredictWalk implements WalkPredictor.
+func (HeuristicWalkPredictor) PredictWalk(_ context.Context, req *PredictionRequest) (*models.WalkPrediction, error) {
+	history := make([]models.WalkSummary, 0, len(req.History))
+	for _, walk := range req.History {
+		if walk.WalkerID == req.WalkerID {
+			history = append(history, walk)
+		}
+	}
+	if len(history) < minWalkerHistory {
+		history = req.History
+	}
+	if len(history) == 0 {
+		return nil, ErrNoWalkHistory
+	}
+
+	durations := make([]float64, len(history))
+	distances := make([]float64, len(history))
+	for i, walk := range history {
+		durations[i] = walk.DurationSeconds
+		distances[i] = walk.DistanceMeters
+	}
+	return &models.WalkPrediction{
+		DurationSeconds: median(durations),
+		DistanceMeters:  median(distances),
+		Source:          models.PredictionSourceHeuristic,
+		BasedOnWalks:    len(history),
+		PredictedAt:     time.Now().UTC(),
+	}, nil
+}
+
+// median returns the median of values, reordering them.
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 1 {
+		return values[mid]
+	}
+	return (values[mid-1] + values[mid]) / 2
+}
+
+// HTTPWalkPredictor asks a prediction endpoint, POSTing the PredictionRequest
+// as JSON and reading back {"durationSeconds": ..., "distanceMeters": ...}.
+type HTTPWalkPredictor struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewHTTPWalkPredictor creates a predictor calling endpointURL, with each
+// request bounded by timeout.
+func NewHTTPWalkPredictor(endpointURL string, timeout time.Duration) (*HTTPWalkPredictor, error) {
+	if endpointURL == "" {
+		return nil, fmt.Errorf("walk predictor requires an endpoint URL")
+	}
+	return &HTTPWalkPredictor{url: endpointURL, httpClient: &http.Client{Timeout: timeout}}, nil
+}
+
+// PredictWalk implements WalkPredictor.
+func (p *HTTPWalkPredictor) PredictWalk(ctx context.Context, req *PredictionRequest) (*models.WalkPrediction, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Accept", "application/json")
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("prediction endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var out struct {
+		DurationSeconds float64 `json:"durationSeconds"`
+		DistanceMeters  float64 `json:"distanceMeters"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("invalid prediction endpoint response: %w", err)
+	}
+	if out.DurationSeconds < 0 || out.DistanceMeters < 0 {
+		return nil, fmt.Errorf("prediction endpoint returned a negative estimate")
+	}
+	return &models.WalkPrediction{
+		DurationSeconds: out.DurationSeconds,
+		DistanceMeters:  out.DistanceMeters,
+		Source:          models.PredictionSourceEndpoint,
+		BasedOnWalks:    len(req.History),
+		PredictedAt:     time.Now().UTC(),
+	}, nil
+}
+
+// WalkPredictionHook predicts each walk's duration and distance when its
+// session starts, from the dog's walks over a trailing window, and measures
+// the prediction against the completed walk. When the configured predictor
+// fails, the built-in heuristic is tried instead.
+type WalkPredictionHook struct {
+	predictor     WalkPredictor
+	history       WalkHistoryStore
+	historyWalks  int
+	historyWindow time.Duration
+	timeout       time.Duration
+
+	predictions  *prometheus.CounterVec
+	errorPercent *prometheus.HistogramVec
+}
+
+// NewWalkPredictionHook creates a hook asking predictor, with the dog's last
+// historyWalks walks ended within historyWindow read from history, each
+// prediction bounded by timeout. Metrics are registered on registry when it
+// is non-nil.
+func NewWalkPredictionHook(predictor WalkPredictor, history WalkHistoryStore, historyWalks int, historyWindow, timeout time.Duration, registry *prometheus.Registry) (*WalkPredictionHook, error) {
+	if predictor == nil {
+		return nil, fmt.Errorf("walk prediction requires a predictor")
+	}
+	if historyWalks < 1 {
+		return nil, fmt.Errorf("walk prediction history must cover at least 1 walk")
+	}
+	h := &WalkPredictionHook{
+		predictor:     predictor,
+		history:       history,
+		historyWalks:  historyWalks,
+		historyWindow: historyWindow,
+		timeout:       timeout,
+		predictions: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tracking_walk_predictions_total",
+				Help: "Walk predictions at session start, by source (endpoint or heuristic) and outcome (predicted, no_history or error).",
+			},
+			[]string{"source", "outcome"},
+		),
+		errorPercent: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "tracking_walk_prediction_error_percent",
+				Help:    "Absolute error of walk predictions as a percentage of the completed walk, by source and metric (duration or distance).",
+				Buckets: []float64{5, 10, 20, 30, 50, 75, 100, 150, 200},
+			},
+			[]string{"source", "metric"},
+		),
+	}
+	if registry != nil {
+		registry.MustRegister(h.predictions, h.errorPercent)
+	}
+	return h, nil
+}
+
+// Predict builds the prediction request for session and asks the predictor,
+// falling back to the heuristic when an endpoint fails.
+func (h *WalkPredictionHook) Predict(session *models.TrackingSession) (*models.WalkPrediction, error) {
+	req := &PredictionRequest{
+		SessionID: session.ID,
+		WalkID:    session.WalkID(),
+		TenantID:  session.TenantID(),
+		WalkerID:  session.WalkerID(),
+		DogID:     session.DogID(),
+		StartedAt: session.StartTime(),
+		History:   []models.WalkSummary{},
+	}
+	if h.history != nil {
+		since := req.StartedAt.Add(-h.historyWindow)
+		history, err := h.history.GetRecentWalkSummaries(req.DogID, req.WalkerID, since, h.historyWalks)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read walk history: %w", err)
+		}
+		if history != nil {
+			req.History = history
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+	prediction, err := h.predictor.PredictWalk(ctx, req)
+	if _, heuristic := h.predictor.(HeuristicWalkPredictor); err != nil && !heuristic {
+		h.predictions.WithLabelValues(models.PredictionSourceEndpoint, "error").Inc()
+		prediction, err = HeuristicWalkPredictor{}.PredictWalk(ctx, req)
+	}
+	switch {
+	case errors.Is(err, ErrNoWalkHistory):
+		h.predictions.WithLabelValues(models.PredictionSourceHeuristic, "no_history").Inc()
+		return nil, err
+	case err != nil:
+		h.predictions.WithLabelValues(models.PredictionSourceHeuristic, "error").Inc()
+		return nil, err
+	}
+	h.predictions.WithLabelValues(prediction.Source, "predicted").Inc()
+	return prediction, nil
+}
+
+// Observe records the absolute percentage errors of a completed walk's
+// prediction.
+func (h *WalkPredictionHook) Observe(stats *models.TrackingStatistics) {
+	if stats.Prediction == nil || stats.PredictionError == nil {
+		return
+	}
+	source := stats.Prediction.Source
+	if pct := stats.PredictionError.DurationErrorPercent; pct != nil {
+		h.errorPercent.WithLabelValues(source, "duration").Observe(math.Abs(*pct))
+	}
+	if pct := stats.PredictionError.DistanceErrorPercent; pct != nil {
+		h.errorPercent.WithLabelValues(source, "distance").Observe(math.Abs(*pct))
+	}
+}
+
+// SetWalkPrediction enables predicting each walk's duration and distance at
+// session start; the prediction is attached to the session and its error
+// reported in the walk's summary. Passing nil disables it.
+func (ts *TrackingService) SetWalkPrediction(hook *WalkPredictionHook) {
+	ts.walkPrediction = hook
+}
+
+// predictWalk attaches a prediction to a newly started session. Failures are
+// logged; a dog without past walks simply goes without a prediction.
+func (ts *TrackingService) predictWalk(session *models.TrackingSession) {
+	prediction, err := ts.walkPrediction.Predict(session)
+	if err != nil {
+		if !errors.Is(err, ErrNoWalkHistory) {
+			ts.logger.Warn("Walk prediction failed",
+				zap.String("sessionID", session.ID),
+				zap.String("dogID", session.DogID()),
+				zap.Error(err),
+			)
+		}
+		return
+	}
+	session.SetPrediction(prediction)
+}
+
+// observeWalkPrediction records how far a completed walk strayed from its
+// prediction.
+func (ts *TrackingService) observeWalkPrediction(session *models.TrackingSession) {
+	if session.Prediction() == nil {
+		return
+	}
+	stats, err := session.CalculateStatistics()
+	if err != nil {
+		return
+	}
+	ts.walkPrediction.Observe(stats)
+}
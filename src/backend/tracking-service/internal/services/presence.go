@@ -0,0 +1,365 @@
+package services
+
+import (
+	// json for decoding heartbeats and encoding offline alerts (go1.21)
+	"encoding/json"
+	// errors for sentinel presence errors (go1.21)
+	"errors"
+	// fmt for formatting topics and error messages (go1.21)
+	"fmt"
+	// sort for returning presence snapshots in a stable order (go1.21)
+	"sort"
+	// sync for guarding the presence registry (go1.21)
+	"sync"
+	// time for heartbeat timestamps and offline detection (go1.21)
+	"time"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+	// prometheus for presence metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+
+	// models package that includes the TrackingSession struct
+	"src/backend/tracking-service/internal/models"
+
+	// utils package for MQTT message classes
+	"src/backend/tracking-service/internal/utils"
+)
+
+// WalkerPresenceTopicFormat is the topic a walker's app publishes presence
+// heartbeats on. The placeholder is the walker ID.
+const WalkerPresenceTopicFormat = "walkers/presence/%s"
+
+// WalkerPresenceSubscription matches the presence topics of every walker.
+const WalkerPresenceSubscription = "walkers/presence/+"
+
+// WalkerOfflineAlertTopicFormat is the topic offline alerts are published on
+// when a walker drops presence during an active session.
+const WalkerOfflineAlertTopicFormat = "walkers/alerts/%s/offline"
+
+// Presence statuses carried by heartbeats. A walker may announce "offline"
+// explicitly; otherwise it goes offline when heartbeats stop.
+const (
+	PresenceOnline  = "online"
+	PresenceOffline = "offline"
+)
+
+// ErrPresenceDisabled is returned by presence queries when presence tracking is
+// not configured.
+var ErrPresenceDisabled = errors.New("walker presence tracking is not enabled")
+
+// PresenceHeartbeat is the payload a walker publishes on its presence topic.
+type PresenceHeartbeat struct {
+	WalkerID  string    `json:"walkerId"`
+	Status    string    `json:"status"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// WalkerPresence is the current presence state of a walker.
+type WalkerPresence struct {
+	WalkerID string    `json:"walkerId"`
+	Online   bool      `json:"online"`
+	LastSeen time.Time `json:"lastSeen"`
+	// Since is when the walker last transitioned online or offline.
+	Since time.Time `json:"since"`
+}
+
+// WalkerOfflineAlert is published when a walker with active sessions goes offline.
+type WalkerOfflineAlert struct {
+	WalkerID   string    `json:"walkerId"`
+	SessionIDs []string  `json:"sessionIds"`
+	LastSeen   time.Time `json:"lastSeen"`
+	DetectedAt time.Time `json:"detectedAt"`
+}
+
+// PresenceRegistry tracks walker presence from heartbeats, independently of
+// tracking sessions, and reports online/offline transitions.
+type PresenceRegistry struct {
+	bus     MessageBus
+	timeout time.Duration
+	logger  *zap.Logger
+
+	mu      sync.Mutex
+	walkers map[string]*WalkerPresence
+
+	// onOffline is invoked, outside the lock, for every online-to-offline transition.
+	onOffline func(presence WalkerPresence)
+
+	stopOnce sync.Once
+	stop     chan struct{}
+
+	transitionsCounter *prometheus.CounterVec
+	onlineGauge        prometheus.Gauge
+}
+
+// NewPresenceRegistry creates a registry that marks walkers offline once their
+// last heartbeat is older than timeout. Metrics are registered on the given
+// registry when it is non-nil.
+func NewPresenceRegistry(bus MessageBus, timeout time.Duration, logger *zap.Logger, registry *prometheus.Registry) (*PresenceRegistry, error) {
+	if bus == nil {
+		return nil, fmt.Errorf("presence tracking requires a message bus")
+	}
+	if timeout <= 0 {
+		return nil, fmt.Errorf("presence timeout must be greater than zero")
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	pr := &PresenceRegistry{
+		bus:     bus,
+		timeout: timeout,
+		logger:  logger,
+		walkers: make(map[string]*WalkerPresence),
+		stop:    make(chan struct{}),
+		transitionsCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tracking_walker_presence_transitions_total",
+				Help: "Walker presence transitions by resulting state.",
+			},
+			[]string{"state"},
+		),
+		onlineGauge: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "tracking_walkers_online",
+			Help: "Number of walkers currently online.",
+		}),
+	}
+	if registry != nil {
+		registry.MustRegister(pr.transitionsCounter, pr.onlineGauge)
+	}
+	return pr, nil
+}
+
+// Start subscribes to walker presence topics and sweeps for expired heartbeats
+// every sweepInterval until Stop is called.
+func (pr *PresenceRegistry) Start(sweepInterval time.Duration) error {
+	err := pr.bus.Subscribe(WalkerPresenceSubscription, func(payload []byte) {
+		var hb PresenceHeartbeat
+		if err := json.Unmarshal(payload, &hb); err != nil {
+			pr.logger.Warn("Discarding malformed presence heartbeat", zap.Error(err))
+			return
+		}
+		if err := pr.Heartbeat(hb); err != nil {
+			pr.logger.Warn("Rejected presence heartbeat", zap.String("walkerID", hb.WalkerID), zap.Error(err))
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to presence topics: %w", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(sweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-pr.stop:
+				return
+			case now := <-ticker.C:
+				pr.Sweep(now.UTC())
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop ends the background sweep.
+func (pr *PresenceRegistry) Stop() {
+	pr.stopOnce.Do(func() { close(pr.stop) })
+}
+
+// Heartbeat records a presence heartbeat. Heartbeats older than the walker's
+// last one are ignored, so out-of-order delivery cannot flip the state back.
+func (pr *PresenceRegistry) Heartbeat(hb PresenceHeartbeat) error {
+	if hb.WalkerID == "" {
+		return fmt.Errorf("presence heartbeat is missing walkerId")
+	}
+	if hb.Status == "" {
+		hb.Status = PresenceOnline
+	}
+	if hb.Status != PresenceOnline && hb.Status != PresenceOffline {
+		return fmt.Errorf("invalid presence status %q", hb.Status)
+	}
+	if hb.Timestamp.IsZero() {
+		hb.Timestamp = time.Now().UTC()
+	}
+
+	pr.mu.Lock()
+	state, known := pr.walkers[hb.WalkerID]
+	if !known {
+		state = &WalkerPresence{WalkerID: hb.WalkerID}
+		pr.walkers[hb.WalkerID] = state
+	} else if hb.Timestamp.Before(state.LastSeen) {
+		pr.mu.Unlock()
+		return nil
+	}
+	state.LastSeen = hb.Timestamp
+
+	// A walker first seen offline starts in that state without a transition.
+	online := hb.Status == PresenceOnline
+	if !known {
+		state.Since = hb.Timestamp
+	}
+	changed := state.Online != online
+	if changed {
+		state.Online = online
+		state.Since = hb.Timestamp
+		pr.recordTransitionLocked(online)
+	}
+	snapshot := *state
+	pr.mu.Unlock()
+
+	if changed {
+		pr.logTransition(snapshot)
+		if !online {
+			pr.notifyOffline(snapshot)
+		}
+	}
+	return nil
+}
+
+// Sweep marks every online walker whose last heartbeat is older than the
+// timeout as offline.
+func (pr *PresenceRegistry) Sweep(now time.Time) {
+	var expired []WalkerPresence
+
+	pr.mu.Lock()
+	for _, state := range pr.walkers {
+		if state.Online && now.Sub(state.LastSeen) > pr.timeout {
+			state.Online = false
+			state.Since = now
+			pr.recordTransitionLocked(false)
+			expired = append(expired, *state)
+		}
+	}
+	pr.mu.Unlock()
+
+	for _, presence := range expired {
+		pr.logTransition(presence)
+		pr.notifyOffline(presence)
+	}
+}
+
+// Get returns the presence state of a walker, if it has ever sent a heartbeat.
+func (pr *PresenceRegistry) Get(walkerID string) (WalkerPresence, bool) {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	state, ok := pr.walkers[walkerID]
+	if !ok {
+		return WalkerPresence{}, false
+	}
+	return *state, true
+}
+
+// Snapshot returns the presence state of every known walker, ordered by walker ID.
+func (pr *PresenceRegistry) Snapshot() []WalkerPresence {
+	pr.mu.Lock()
+	out := make([]WalkerPresence, 0, len(pr.walkers))
+	for _, state := range pr.walkers {
+		out = append(out, *state)
+	}
+	pr.mu.Unlock()
+
+	sort.Slice(out, func(i, j int) bool { return out[i].WalkerID < out[j].WalkerID })
+	return out
+}
+
+// recordTransitionLocked updates presence metrics. Callers must hold pr.mu.
+func (pr *PresenceRegistry) recordTransitionLocked(online bool) {
+	if online {
+		pr.transitionsCounter.WithLabelValues(PresenceOnline).Inc()
+		pr.onlineGauge.Inc()
+		return
+	}
+	pr.transitionsCounter.WithLabelValues(PresenceOffline).Inc()
+	pr.onlineGauge.Dec()
+}
+
+// logTransition logs an online or offline transition.
+func (pr *PresenceRegistry) logTransition(presence WalkerPresence) {
+	state := PresenceOffline
+	if presence.Online {
+		state = PresenceOnline
+	}
+	pr.logger.Info("Walker presence changed",
+		zap.String("walkerID", presence.WalkerID),
+		zap.String("state", state),
+		zap.Time("lastSeen", presence.LastSeen),
+	)
+}
+
+// notifyOffline invokes the offline callback, if any.
+func (pr *PresenceRegistry) notifyOffline(presence WalkerPresence) {
+	pr.mu.Lock()
+	onOffline := pr.onOffline
+	pr.mu.Unlock()
+	if onOffline != nil {
+		onOffline(presence)
+	}
+}
+
+// SetPresenceRegistry enables walker presence tracking. Walkers that go offline
+// while they have active sessions trigger an offline alert. Passing nil
+// disables it.
+func (ts *TrackingService) SetPresenceRegistry(registry *PresenceRegistry) {
+	ts.presence = registry
+	if registry == nil {
+		return
+	}
+	registry.mu.Lock()
+	registry.onOffline = ts.handleWalkerOffline
+	registry.mu.Unlock()
+}
+
+// GetWalkerPresence returns the presence state of every known walker.
+func (ts *TrackingService) GetWalkerPresence() ([]WalkerPresence, error) {
+	if ts.presence == nil {
+		return nil, ErrPresenceDisabled
+	}
+	return ts.presence.Snapshot(), nil
+}
+
+// handleWalkerOffline publishes an offline alert when a walker drops presence
+// while one of their sessions is still running.
+//
+// Steps:
+//  1. Collect the walker's active or paused sessions
+//  2. Log and publish an offline alert if there are any
+func (ts *TrackingService) handleWalkerOffline(presence WalkerPresence) {
+	var sessionIDs []string
+	ts.activeSessions.Range(func(_, value interface{}) bool {
+		session, ok := value.(*models.TrackingSession)
+		if ok && session.WalkerID() == presence.WalkerID && session.Status() != models.SessionStatusCompleted {
+			sessionIDs = append(sessionIDs, session.ID)
+		}
+		return true
+	})
+	if len(sessionIDs) == 0 {
+		return
+	}
+	sort.Strings(sessionIDs)
+
+	ts.logger.Warn("Walker went offline during an active session",
+		zap.String("walkerID", presence.WalkerID),
+		zap.Strings("sessionIDs", sessionIDs),
+		zap.Time("lastSeen", presence.LastSeen),
+	)
+	if ts.mqttClient == nil {
+		return
+	}
+
+	payload, err := json.Marshal(WalkerOfflineAlert{
+		WalkerID:   presence.WalkerID,
+		SessionIDs: sessionIDs,
+		LastSeen:   presence.LastSeen,
+		DetectedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		ts.logger.Error("Failed to encode walker offline alert", zap.String("walkerID", presence.WalkerID), zap.Error(err))
+		return
+	}
+	topic := fmt.Sprintf(WalkerOfflineAlertTopicFormat, presence.WalkerID)
+	if err := ts.mqttClient.Publish(utils.MessageClassAlert, topic, payload); err != nil {
+		ts.logger.Error("Failed to publish walker offline alert", zap.String("walkerID", presence.WalkerID), zap.Error(err))
+	}
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	// errors for classifying rejected points (standard library)
+	"errors"
+
+	// prometheus for data-quality metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+
+	// models package that includes the Location struct and provider constants
+	"src/backend/tracking-service/internal/models"
+)
+
+// Outcomes of an ingested point, as counted by LocationQualityMetrics.
+const (
+	PointAccepted    = "accepted"
+	PointLowAccuracy = "low_accuracy"
+	PointTooFast     = "too_fast"
+	PointInvalid     = "invalid"
+	PointRejected    = "rejected"
+	PointDuplicate   = "duplicate"
+)
+
+// qualityProviders bounds the provider label; anything else is counted as unknown.
+var qualityProviders = map[string]bool{
+	models.LocationProviderGPS:     true,
+	models.LocationProviderNetwork: true,
+	models.LocationProviderFused:   true,
+}
+
+// LocationQualityMetrics exports the data quality of ingested points by
+// location provider: how many were accepted or rejected and how accurate the
+// accepted ones were, so a shift towards network fixes shows up on dashboards.
+type LocationQualityMetrics struct {
+	points   *prometheus.CounterVec
+	accuracy *prometheus.HistogramVec
+}
+
+// NewLocationQualityMetrics creates the metrics, registering them on registry
+// when non-nil.
+func NewLocationQualityMetrics(registry *prometheus.Registry) *LocationQualityMetrics {
+	m := &LocationQualityMetrics{
+		points: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tracking_location_points_total",
+			Help: "Ingested location points by provider and outcome",
+		}, []string{"provider", "outcome"}),
+		accuracy: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "tracking_location_accuracy_meters",
+			Help:    "Reported accuracy of accepted location points by provider",
+			Buckets: []float64{1, 2, 3, 5, 7.5, 10, 15, 25, 50, 100},
+		}, []string{"provider"}),
+	}
+	if registry != nil {
+		registry.MustRegister(m.points, m.accuracy)
+	}
+	return m
+}
+
+// Observe records the outcome of one ingested point.
+func (m *LocationQualityMetrics) Observe(loc *models.Location, outcome string) {
+	provider := loc.Provider
+	if !qualityProviders[provider] {
+		provider = models.LocationProviderUnknown
+	}
+	m.points.WithLabelValues(provider, outcome).Inc()
+	if outcome == PointAccepted {
+		m.accuracy.WithLabelValues(provider).Observe(loc.Accuracy)
+	}
+}
+
+// SetLocationQualityMetrics enables data-quality metrics for ingested points.
+// Passing nil disables them.
+func (ts *TrackingService) SetLocationQualityMetrics(metrics *LocationQualityMetrics) {
+	ts.quality = metrics
+}
+
+// observeQuality records a point's outcome when quality metrics are enabled.
+func (ts *TrackingService) observeQuality(loc *models.Location, outcome string) {
+	if ts.quality != nil {
+		ts.quality.Observe(loc, outcome)
+	}
+}
+
+// addOutcome classifies the error returned by TrackingSession.AddLocation.
+func addOutcome(err error) string {
+	switch {
+	case err == nil:
+		return PointAccepted
+	case errors.Is(err, models.ErrLocationAccuracyTooLow):
+		return PointLowAccuracy
+	case errors.Is(err, models.ErrImplausibleSpeed):
+		return PointTooFast
+	default:
+		return PointRejected
+	}
+}
@@ -0,0 +1,312 @@
+package services
+
+import (
+	// errors for the quarantine sentinels and classifying rejections (go1.21)
+	"errors"
+	// fmt for wrapping validation and repository errors (go1.21)
+	"fmt"
+	// time for quarantine timestamps (go1.21)
+	"time"
+
+	// prometheus for quarantine metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// models package that includes the QuarantinedPoint struct
+	"src/backend/tracking-service/internal/models"
+)
+
+var (
+	// ErrQuarantineDisabled is returned by quarantine administration when no
+	// quarantine store is configured.
+	ErrQuarantineDisabled = errors.New("location quarantine is not enabled")
+
+	// ErrInvalidReingest wraps the validation failures of a reingest request.
+	ErrInvalidReingest = errors.New("invalid reingest request")
+)
+
+// Quarantine reasons of points failing validation, besides the point outcomes
+// of LocationQualityMetrics that name the session's own checks.
+const (
+	RejectOutOfRange       = "out_of_range"
+	RejectInvalidTimestamp = "invalid_timestamp"
+	RejectInvalidProvider  = "invalid_provider"
+	RejectInvalidID        = "invalid_id"
+	RejectInvalidWalkID    = "invalid_walk_id"
+)
+
+// QuarantineStore persists the points rejected at ingestion. It is
+// implemented by repository.TimescaleRepository.
+type QuarantineStore interface {
+	// SaveQuarantinedPoints stores rejected points.
+	SaveQuarantinedPoints(points []models.QuarantinedPoint) error
+
+	// GetQuarantinedPoints returns the points matching query, newest first.
+	GetQuarantinedPoints(query models.QuarantineQuery) ([]models.QuarantinedPoint, error)
+
+	// GetQuarantinedPointsByID returns the points with the given quarantine
+	// IDs that exist, oldest first.
+	GetQuarantinedPointsByID(ids []string) ([]models.QuarantinedPoint, error)
+
+	// DeleteQuarantinedPoints releases points from quarantine.
+	DeleteQuarantinedPoints(ids []string) error
+}
+
+// LocationQuarantine keeps the points rejected at ingestion, with the reason
+// they were rejected, instead of dropping them, so they can be inspected and
+// ingested again once validation rules are adjusted. Rejections are counted
+// by reason to guide that tuning.
+type LocationQuarantine struct {
+	store  QuarantineStore
+	points *prometheus.CounterVec
+	failed prometheus.Counter
+}
+
+// NewLocationQuarantine creates a quarantine kept in store. Its metrics are
+// registered on registry when non-nil.
+func NewLocationQuarantine(store QuarantineStore, registry *prometheus.Registry) *LocationQuarantine {
+	q := &LocationQuarantine{
+		store: store,
+		points: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tracking_quarantined_points_total",
+			Help: "Location points rejected at ingestion and quarantined, by rejection reason",
+		}, []string{"reason"}),
+		failed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tracking_quarantine_failures_total",
+			Help: "Rejected location points that could not be quarantined and were dropped",
+		}),
+	}
+	if registry != nil {
+		registry.MustRegister(q.points, q.failed)
+	}
+	return q
+}
+
+// SetLocationQuarantine quarantines rejected points instead of dropping them.
+// Points the session rejects are then quarantined rather than stored. Passing
+// nil disables it.
+func (ts *TrackingService) SetLocationQuarantine(quarantine *LocationQuarantine) {
+	ts.quarantine = quarantine
+}
+
+// rejectedPoint is a point a built-in stage rejected, with its quarantine entry.
+type rejectedPoint struct {
+	location *models.Location
+	entry    models.QuarantinedPoint
+}
+
+// rejectPoint records that loc was rejected for reason, to be quarantined when
+// the batch finishes. Callers running stages concurrently must serialize it.
+func (ts *TrackingService) rejectPoint(batch *PipelineBatch, loc *models.Location, reason string, err error) {
+	if ts.quarantine == nil {
+		return
+	}
+	batch.rejected = append(batch.rejected, rejectedPoint{
+		location: loc,
+		entry: models.QuarantinedPoint{
+			ID:            models.NewID(),
+			SessionID:     batch.SessionID,
+			Location:      *loc,
+			Reason:        reason,
+			Detail:        err.Error(),
+			QuarantinedAt: time.Now().UTC(),
+		},
+	})
+}
+
+// runBatch runs batch through the pipeline and quarantines the points it
+// rejected, even when a later stage failed.
+func (ts *TrackingService) runBatch(batch *PipelineBatch) error {
+	err := ts.pipeline.Run(batch)
+	ts.storeRejected(batch)
+	return err
+}
+
+// storeRejected quarantines the points rejected from batch. Failures are
+// logged and counted; they never fail the batch.
+func (ts *TrackingService) storeRejected(batch *PipelineBatch) {
+	if ts.quarantine == nil || len(batch.rejected) == 0 {
+		return
+	}
+	entries := make([]models.QuarantinedPoint, len(batch.rejected))
+	for i, r := range batch.rejected {
+		entries[i] = r.entry
+	}
+	if err := ts.quarantine.store.SaveQuarantinedPoints(entries); err != nil {
+		ts.quarantine.failed.Add(float64(len(entries)))
+		ts.logger.Warn("Failed to quarantine rejected locations",
+			zap.String("sessionID", batch.SessionID),
+			zap.Int("points", len(entries)),
+			zap.Error(err),
+		)
+		return
+	}
+	for _, e := range entries {
+		ts.quarantine.points.WithLabelValues(e.Reason).Inc()
+	}
+}
+
+// invalidReason names the validation check err reports a point failed.
+func invalidReason(err error) string {
+	var rangeErr models.ErrOutOfRange
+	var timestampErr models.ErrInvalidTimestamp
+	var providerErr models.ErrInvalidProvider
+	var walkErr models.ErrInvalidWalkID
+	switch {
+	case errors.As(err, &rangeErr):
+		return RejectOutOfRange
+	case errors.As(err, &timestampErr):
+		return RejectInvalidTimestamp
+	case errors.As(err, &providerErr):
+		return RejectInvalidProvider
+	case errors.As(err, &walkErr):
+		return RejectInvalidWalkID
+	case errors.Is(err, models.ErrInvalidID):
+		return RejectInvalidID
+	default:
+		return PointInvalid
+	}
+}
+
+// GetQuarantinedPoints lists quarantined points matching query, newest first.
+func (ts *TrackingService) GetQuarantinedPoints(query models.QuarantineQuery) ([]models.QuarantinedPoint, error) {
+	if ts.quarantine == nil {
+		return nil, ErrQuarantineDisabled
+	}
+	if query.Limit <= 0 {
+		query.Limit = models.DefaultQuarantineLimit
+	}
+	if query.Limit > models.MaxQuarantineLimit {
+		query.Limit = models.MaxQuarantineLimit
+	}
+	points, err := ts.quarantine.store.GetQuarantinedPoints(query)
+	if err != nil {
+		return nil, err
+	}
+	if points == nil {
+		points = []models.QuarantinedPoint{}
+	}
+	return points, nil
+}
+
+// ReingestQuarantined ingests quarantined points again under the current
+// validation rules. Points of active sessions go through the full pipeline.
+// Points of sessions that have ended are validated and stored with their
+// walk's track; the session's own checks, such as accuracy and speed, cannot
+// be repeated for them, and the ended session's statistics are not updated.
+// Points that pass are released from quarantine; points that fail again are
+// quarantined anew with the reason they failed this time.
+//
+// Steps:
+//  1. Load the requested points, noting the IDs that are not quarantined
+//  2. Ingest each session's points, in quarantine order
+//  3. Release the original entries of every processed point
+func (ts *TrackingService) ReingestQuarantined(ids []string) (*models.ReingestResult, error) {
+	if ts.quarantine == nil {
+		return nil, ErrQuarantineDisabled
+	}
+	if len(ids) == 0 || len(ids) > models.MaxQuarantineLimit {
+		return nil, fmt.Errorf("%w: between 1 and %d ids are required", ErrInvalidReingest, models.MaxQuarantineLimit)
+	}
+
+	points, err := ts.quarantine.store.GetQuarantinedPointsByID(ids)
+	if err != nil {
+		return nil, err
+	}
+	result := &models.ReingestResult{Reingested: []string{}, Rejected: []models.QuarantinedPoint{}}
+	found := make(map[string]bool, len(points))
+	for _, p := range points {
+		found[p.ID] = true
+	}
+	for _, id := range ids {
+		if !found[id] {
+			result.NotFound = append(result.NotFound, id)
+		}
+	}
+
+	var order []string
+	bySession := make(map[string][]models.QuarantinedPoint)
+	for _, p := range points {
+		if _, seen := bySession[p.SessionID]; !seen {
+			order = append(order, p.SessionID)
+		}
+		bySession[p.SessionID] = append(bySession[p.SessionID], p)
+	}
+
+	var released []string
+	for _, sessionID := range order {
+		sessionPoints := bySession[sessionID]
+		passed, rejected, err := ts.reingestSession(sessionID, sessionPoints)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reingest points of session %s: %w", sessionID, err)
+		}
+		result.Rejected = append(result.Rejected, rejected...)
+		for i, p := range sessionPoints {
+			released = append(released, p.ID)
+			if passed[i] {
+				result.Reingested = append(result.Reingested, p.ID)
+			}
+		}
+	}
+
+	if len(released) > 0 {
+		if err := ts.quarantine.store.DeleteQuarantinedPoints(released); err != nil {
+			return nil, err
+		}
+	}
+	ts.logger.Info("Reingested quarantined locations",
+		zap.Int("reingested", len(result.Reingested)),
+		zap.Int("rejected", len(result.Rejected)),
+		zap.Int("notFound", len(result.NotFound)),
+	)
+	return result, nil
+}
+
+// reingestSession ingests one session's quarantined points again. It reports
+// which of them passed, and the new quarantine entries of those that failed.
+func (ts *TrackingService) reingestSession(sessionID string, points []models.QuarantinedPoint) ([]bool, []models.QuarantinedPoint, error) {
+	locations := make([]*models.Location, len(points))
+	for i := range points {
+		loc := points[i].Location
+		locations[i] = &loc
+	}
+	batch := &PipelineBatch{
+		SessionID:  sessionID,
+		Locations:  append([]*models.Location(nil), locations...),
+		Result:     &BatchResult{ProcessedCount: len(locations)},
+		IngestedAt: time.Now(),
+	}
+
+	if session, err := ts.getSession(sessionID); err == nil {
+		batch.Session = session
+		if err := ts.runBatch(batch); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		// The session has ended: validate the points and store the valid ones.
+		if err := ts.validateStage(batch); err != nil {
+			return nil, nil, err
+		}
+		if len(batch.Locations) > 0 {
+			if err := ts.db.StoreLocationBatch(sessionID, batch.Locations); err != nil {
+				return nil, nil, err
+			}
+			ts.rememberStored(batch.Locations)
+		}
+		ts.storeRejected(batch)
+	}
+
+	failed := make(map[*models.Location]bool, len(batch.rejected))
+	rejected := make([]models.QuarantinedPoint, 0, len(batch.rejected))
+	for _, r := range batch.rejected {
+		failed[r.location] = true
+		rejected = append(rejected, r.entry)
+	}
+	passed := make([]bool, len(locations))
+	for i, loc := range locations {
+		passed[i] = !failed[loc]
+	}
+	return passed, rejected, nil
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	// errors for the settings validation sentinel (go1.21)
+	"errors"
+	// sync for stopping the reaper once (go1.21)
+	"sync"
+	// time for the inactivity and age limits and the sweep schedule (go1.21)
+	"time"
+
+	// prometheus for reaped session metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// models package that includes the TrackingSession struct
+	"src/backend/tracking-service/internal/models"
+)
+
+// ErrInvalidReaperLimits is returned when both session reaper limits are
+// disabled or either is negative.
+var ErrInvalidReaperLimits = errors.New("session reaper needs a positive inactivity or age limit, and neither can be negative")
+
+// Reasons a session is reaped.
+const (
+	// SessionReapInactive is a session that received no updates for the
+	// inactivity limit, typically one whose app was killed mid-walk.
+	SessionReapInactive = "inactive"
+
+	// SessionReapTimeout is a session that ran longer than the session timeout.
+	SessionReapTimeout = "timeout"
+)
+
+// SessionReaper periodically completes active sessions that were abandoned:
+// those without an update for longer than the inactivity limit, or running
+// for longer than the session timeout. Reaped sessions go through EndSession,
+// so their writes are flushed and their completion recorded and replicated as
+// if the walker had ended them, and their final statistics are then persisted.
+type SessionReaper struct {
+	ts          *TrackingService
+	maxInactive time.Duration
+	maxAge      time.Duration
+	logger      *zap.Logger
+
+	reaped        *prometheus.CounterVec
+	statsFailures prometheus.Counter
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+// expiredSession is a session found expired by a sweep.
+type expiredSession struct {
+	session *models.TrackingSession
+	reason  string
+}
+
+// NewSessionReaper creates a reaper completing sessions idle for longer than
+// maxInactive or older than maxAge; a zero limit is not checked. Its metrics
+// are registered on registry when non-nil.
+func NewSessionReaper(ts *TrackingService, maxInactive, maxAge time.Duration, logger *zap.Logger, registry *prometheus.Registry) (*SessionReaper, error) {
+	if maxInactive < 0 || maxAge < 0 || (maxInactive == 0 && maxAge == 0) {
+		return nil, ErrInvalidReaperLimits
+	}
+	r := &SessionReaper{
+		ts:          ts,
+		maxInactive: maxInactive,
+		maxAge:      maxAge,
+		logger:      logger,
+		reaped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tracking_sessions_reaped_total",
+			Help: "Abandoned sessions completed by the session reaper, by reason",
+		}, []string{"reason"}),
+		statsFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tracking_sessions_reaped_stats_failures_total",
+			Help: "Reaped sessions whose final statistics could not be persisted",
+		}),
+		stop: make(chan struct{}),
+	}
+	if registry != nil {
+		registry.MustRegister(r.reaped, r.statsFailures)
+	}
+	return r, nil
+}
+
+// Start sweeps the active sessions every interval until Stop is called.
+func (r *SessionReaper) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				if reaped := r.Reap(); reaped > 0 {
+					r.logger.Info("Reaped abandoned tracking sessions", zap.Int("sessions", reaped))
+				}
+			}
+		}
+	}()
+}
+
+// Stop ends the sweeps.
+func (r *SessionReaper) Stop() {
+	r.stopOnce.Do(func() { close(r.stop) })
+}
+
+// Reap completes every expired active session and returns how many it
+// completed. Sessions ended concurrently, e.g. by their walker, are skipped.
+//
+// Steps:
+//  1. Collect the sessions past either limit
+//  2. End each through EndSession
+//  3. Persist the final statistics of each and count it by reason
+//  4. Drop the inline geofence state left by any session no longer active
+func (r *SessionReaper) Reap() int {
+	now := time.Now().UTC()
+	var expired []expiredSession
+	r.ts.activeSessions.Range(func(_, value interface{}) bool {
+		if session, ok := value.(*models.TrackingSession); ok {
+			if reason := r.expiry(session, now); reason != "" {
+				expired = append(expired, expiredSession{session: session, reason: reason})
+			}
+		}
+		return true
+	})
+
+	reaped := 0
+	for _, e := range expired {
+		if err := r.ts.EndSession(e.session.ID); err != nil {
+			r.logger.Debug("Expired session not reaped",
+				zap.String("sessionID", e.session.ID),
+				zap.Error(err),
+			)
+			continue
+		}
+		reaped++
+		r.reaped.WithLabelValues(e.reason).Inc()
+		r.logger.Info("Tracking session reaped",
+			zap.String("sessionID", e.session.ID),
+			zap.String("reason", e.reason),
+			zap.Time("lastUpdate", e.session.LastUpdateTime()),
+			zap.Time("startTime", e.session.StartTime()),
+		)
+		r.persistStatistics(e.session)
+	}
+	r.ts.forgetEndedGeofenceWatches()
+	return reaped
+}
+
+// expiry returns why session has expired at now, or empty while it has not.
+func (r *SessionReaper) expiry(session *models.TrackingSession, now time.Time) string {
+	if r.maxInactive > 0 && now.Sub(session.LastUpdateTime()) > r.maxInactive {
+		return SessionReapInactive
+	}
+	if r.maxAge > 0 && now.Sub(session.StartTime()) > r.maxAge {
+		return SessionReapTimeout
+	}
+	return ""
+}
+
+// persistStatistics records the final statistics of a reaped session in the
+// database. Failures are logged and counted.
+func (r *SessionReaper) persistStatistics(session *models.TrackingSession) {
+	if r.ts.db == nil {
+		return
+	}
+	stats, err := session.CalculateStatistics()
+	if err == nil {
+		err = r.ts.db.RecordSessionMetrics(session.ID, stats)
+	}
+	if err != nil {
+		r.statsFailures.Inc()
+		r.logger.Warn("Failed to persist final statistics of reaped session",
+			zap.String("sessionID", session.ID),
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,278 @@
+package services
+
+import (
+	// errors for the region profile sentinels (go1.21)
+	"errors"
+	// fmt for wrapping validation and repository errors (go1.21)
+	"fmt"
+	// sync for stopping the retention sweep once (go1.21)
+	"sync"
+	// time for profile timestamps and retention cutoffs (go1.21)
+	"time"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// models package that includes the RegionProfile and SessionSettings structs
+	"src/backend/tracking-service/internal/models"
+)
+
+var (
+	// ErrRegionProfilesDisabled is returned by region profile administration
+	// when no region profile store is configured.
+	ErrRegionProfilesDisabled = errors.New("region profiles are not enabled")
+
+	// ErrInvalidRegionProfile wraps the validation failures of a region profile.
+	ErrInvalidRegionProfile = errors.New("invalid region profile")
+)
+
+// RegionProfileStore persists region profiles and the region each walk was
+// started in. It is implemented by repository.TimescaleRepository.
+type RegionProfileStore interface {
+	// SaveRegionProfile inserts or replaces a region's profile.
+	SaveRegionProfile(profile *models.RegionProfile) error
+
+	// GetRegionProfile returns a region's profile.
+	GetRegionProfile(region string) (*models.RegionProfile, error)
+
+	// GetRegionProfiles returns every profile, ordered by region.
+	GetRegionProfiles() ([]models.RegionProfile, error)
+
+	// DeleteRegionProfile removes a region's profile.
+	DeleteRegionProfile(region string) error
+
+	// RecordWalkRegion records the region a walk was started in.
+	RecordWalkRegion(walkID, region string) error
+
+	// PurgeRegionLocations deletes the location points recorded before cutoff
+	// for walks started in the region, returning a summary of them. With
+	// dryRun nothing is deleted and the summary describes what would be.
+	PurgeRegionLocations(region string, cutoff time.Time, dryRun bool) (*models.RetentionRows, error)
+}
+
+// SetRegionProfiles enables region profiles: sessions started with a region
+// code get base with the region's profile layered over it. Passing nil
+// disables them, and sessions then start without limits.
+func (ts *TrackingService) SetRegionProfiles(store RegionProfileStore, base models.SessionSettings) {
+	ts.regionProfiles = store
+	ts.SetBaseSessionSettings(base)
+}
+
+// SetBaseSessionSettings replaces the settings sessions start with, under their
+// region's profile. Running sessions keep the settings they started with.
+func (ts *TrackingService) SetBaseSessionSettings(base models.SessionSettings) {
+	ts.baseSettingsMu.Lock()
+	ts.baseSettings = base
+	ts.baseSettingsMu.Unlock()
+}
+
+// baseSessionSettings returns the settings sessions start with.
+func (ts *TrackingService) baseSessionSettings() models.SessionSettings {
+	ts.baseSettingsMu.RLock()
+	defer ts.baseSettingsMu.RUnlock()
+	return ts.baseSettings
+}
+
+// GetRegionProfiles lists the configured region profiles.
+func (ts *TrackingService) GetRegionProfiles() ([]models.RegionProfile, error) {
+	if ts.regionProfiles == nil {
+		return nil, ErrRegionProfilesDisabled
+	}
+	profiles, err := ts.regionProfiles.GetRegionProfiles()
+	if err != nil {
+		return nil, err
+	}
+	if profiles == nil {
+		profiles = []models.RegionProfile{}
+	}
+	return profiles, nil
+}
+
+// GetRegionProfile returns a region's profile. Store errors are returned
+// unchanged, so a region without a profile is repository.ErrNotFound.
+func (ts *TrackingService) GetRegionProfile(region string) (*models.RegionProfile, error) {
+	if ts.regionProfiles == nil {
+		return nil, ErrRegionProfilesDisabled
+	}
+	return ts.regionProfiles.GetRegionProfile(region)
+}
+
+// PutRegionProfile validates and stores a region's profile. It applies to
+// sessions started afterwards; running sessions keep the settings they
+// started with.
+func (ts *TrackingService) PutRegionProfile(profile *models.RegionProfile) error {
+	if ts.regionProfiles == nil {
+		return ErrRegionProfilesDisabled
+	}
+	if err := profile.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRegionProfile, err)
+	}
+	if r := profile.GeofenceRadiusKm; r != 0 && (r < MinRadius || r > MaxRadius) {
+		return fmt.Errorf("%w: geofenceRadiusKm must be between %.1f and %.1f", ErrInvalidRegionProfile, MinRadius, MaxRadius)
+	}
+	profile.UpdatedAt = time.Now().UTC()
+	return ts.regionProfiles.SaveRegionProfile(profile)
+}
+
+// DeleteRegionProfile removes a region's profile, so sessions started there
+// afterwards get the base settings.
+func (ts *TrackingService) DeleteRegionProfile(region string) error {
+	if ts.regionProfiles == nil {
+		return ErrRegionProfilesDisabled
+	}
+	return ts.regionProfiles.DeleteRegionProfile(region)
+}
+
+// resolveSessionSettings returns the settings of a session started in
+// region. A region whose profile cannot be loaded, most often because it has
+// none, gets the base settings.
+func (ts *TrackingService) resolveSessionSettings(region string) models.SessionSettings {
+	base := ts.baseSessionSettings()
+	settings := base
+	settings.Region = region
+	if ts.regionProfiles == nil || region == "" {
+		return settings
+	}
+	profile, err := ts.regionProfiles.GetRegionProfile(region)
+	if err != nil {
+		ts.logger.Debug("No region profile loaded; using base settings",
+			zap.String("region", region),
+			zap.Error(err),
+		)
+		return settings
+	}
+	return profile.Apply(base)
+}
+
+// recordWalkRegion records the region of a started walk so the region's
+// retention applies to its points. Failures are logged; they never fail the
+// session start.
+func (ts *TrackingService) recordWalkRegion(session *models.TrackingSession) {
+	region := session.Settings().Region
+	if ts.regionProfiles == nil || region == "" {
+		return
+	}
+	if err := ts.regionProfiles.RecordWalkRegion(session.WalkID(), region); err != nil {
+		ts.logger.Error("Failed to record walk region",
+			zap.String("sessionID", session.ID),
+			zap.String("region", region),
+			zap.Error(err),
+		)
+	}
+}
+
+// defaultGeofenceRadius returns the radius for a circle geofence created for
+// walkID without one: that of the walk's running session, or the base
+// setting when the walk has none.
+func (ts *TrackingService) defaultGeofenceRadius(walkID string) float64 {
+	radius := ts.baseSessionSettings().GeofenceRadiusKm
+	ts.activeSessions.Range(func(_, value interface{}) bool {
+		session, ok := value.(*models.TrackingSession)
+		if ok && session.WalkID() == walkID {
+			radius = session.Settings().GeofenceRadiusKm
+			return false
+		}
+		return true
+	})
+	return radius
+}
+
+// EnforceRegionRetention deletes the location points older than each
+// region's retention, for the regions whose profile sets one, and reports
+// what was deleted per region. With dryRun nothing is deleted and the report
+// lists what would be. A failing region does not stop the others; the first
+// error is returned with the report of the rest.
+func (ts *TrackingService) EnforceRegionRetention(dryRun bool) (*models.RetentionReport, error) {
+	profiles, err := ts.GetRegionProfiles()
+	if err != nil {
+		return nil, err
+	}
+	report := &models.RetentionReport{
+		DryRun:      dryRun,
+		GeneratedAt: time.Now().UTC(),
+		Compressed:  []models.RetentionChunk{},
+		Dropped:     []models.RetentionChunk{},
+		Deleted:     []models.RetentionRows{},
+	}
+	var firstErr error
+	for _, p := range profiles {
+		if p.RetentionDays <= 0 {
+			continue
+		}
+		cutoff := report.GeneratedAt.AddDate(0, 0, -p.RetentionDays)
+		purged, err := ts.regionProfiles.PurgeRegionLocations(p.Region, cutoff, dryRun)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to purge locations of region %s: %w", p.Region, err)
+			}
+			continue
+		}
+		report.Deleted = append(report.Deleted, *purged)
+	}
+	return report, firstErr
+}
+
+// RegionRetention periodically enforces region retention in the background.
+// In dry-run mode each sweep only logs what it would delete.
+type RegionRetention struct {
+	ts       *TrackingService
+	dryRun   bool
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewRegionRetention creates a retention sweep for the service's regions,
+// deleting nothing when dryRun is set.
+func NewRegionRetention(ts *TrackingService, dryRun bool) *RegionRetention {
+	return &RegionRetention{ts: ts, dryRun: dryRun, stop: make(chan struct{})}
+}
+
+// Start enforces region retention every interval until Stop is called.
+func (r *RegionRetention) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				report, err := r.ts.EnforceRegionRetention(r.dryRun)
+				if report != nil {
+					r.logReport(report)
+				}
+				if err != nil {
+					r.ts.logger.Warn("Region retention sweep failed", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// logReport logs the regions a sweep purged points from or, in dry-run mode,
+// would have.
+func (r *RegionRetention) logReport(report *models.RetentionReport) {
+	msg := "Purged expired regional location points"
+	if report.DryRun {
+		msg = "Region retention dry run: expired regional location points kept"
+	}
+	for _, d := range report.Deleted {
+		if d.Rows == 0 {
+			continue
+		}
+		r.ts.logger.Info(msg,
+			zap.String("region", d.Region),
+			zap.Time("cutoff", d.Cutoff),
+			zap.Int64("points", d.Rows),
+			zap.Int64("walks", d.Walks),
+			zap.Int64("bytes", d.Bytes),
+			zap.Timep("oldest", d.Oldest),
+			zap.Timep("newest", d.Newest),
+		)
+	}
+}
+
+// Stop ends the background sweep.
+func (r *RegionRetention) Stop() {
+	r.stopOnce.Do(func() { close(r.stop) })
+}
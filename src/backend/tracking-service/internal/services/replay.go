@@ -0,0 +1,185 @@
+package services
+
+import (
+	// errors for the replay sentinels (go1.21)
+	"errors"
+	// fmt for wrapping repository errors (go1.21)
+	"fmt"
+	// sort for seeking within the track (go1.21)
+	"sort"
+	// time for playback pacing (go1.21)
+	"time"
+
+	// models package that includes the Location struct
+	"src/backend/tracking-service/internal/models"
+)
+
+var (
+	// ErrReplayDisabled is returned by walk replay when no replay store is
+	// configured.
+	ErrReplayDisabled = errors.New("walk replay is not enabled")
+
+	// ErrWalkNotCompleted is returned when replaying a walk that is still in
+	// progress.
+	ErrWalkNotCompleted = errors.New("walk is not completed")
+
+	// ErrInvalidReplaySpeed is returned for playback speeds not in ReplaySpeeds.
+	ErrInvalidReplaySpeed = errors.New("invalid replay speed")
+)
+
+// ReplaySpeeds are the supported playback speeds, as multiples of real time.
+var ReplaySpeeds = []int{1, 2, 10}
+
+// maxReplayGap caps the wait between two replayed points, so that a long stop
+// during the walk does not stall playback.
+const maxReplayGap = 5 * time.Second
+
+// ReplayStore loads the persisted track of a walk in time order. It is
+// implemented by repository.TimescaleRepository.
+type ReplayStore interface {
+	// GetLocationHistory returns the full persisted track of a walk.
+	GetLocationHistory(walkID string) ([]models.Location, error)
+}
+
+// SetReplayStore enables replaying completed walks. Passing nil disables it.
+func (ts *TrackingService) SetReplayStore(store ReplayStore) {
+	ts.replayStore = store
+}
+
+// ValidateReplaySpeed reports ErrInvalidReplaySpeed unless speed is one of
+// ReplaySpeeds.
+func ValidateReplaySpeed(speed int) error {
+	for _, s := range ReplaySpeeds {
+		if s == speed {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %d; must be one of %v", ErrInvalidReplaySpeed, speed, ReplaySpeeds)
+}
+
+// WalkReplay paces the track of a completed walk for playback: each point is
+// due after the time that separated it from the previous one, divided by the
+// playback speed. Playback can be moved to any instant of the walk with Seek.
+// A WalkReplay is not safe for concurrent use.
+type WalkReplay struct {
+	sessionID string
+	track     []models.Location
+	speed     int
+
+	// next is the index of the next point to play; last is the timestamp of
+	// the point played before it, zero after a seek.
+	next int
+	last time.Time
+}
+
+// LoadWalkReplay prepares the replay of a completed session's walk at speed,
+// reading its track from the database in time order.
+//
+// Steps:
+//  1. Validate the speed
+//  2. Resolve the session, rebuilding a finished one from its event stream
+//  3. Reject walks still in progress
+//  4. Load the persisted track
+func (ts *TrackingService) LoadWalkReplay(sessionID string, speed int) (*WalkReplay, error) {
+	if ts.replayStore == nil {
+		return nil, ErrReplayDisabled
+	}
+	if err := ValidateReplaySpeed(speed); err != nil {
+		return nil, err
+	}
+	session, err := ts.resolveSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status() != models.SessionStatusCompleted {
+		return nil, fmt.Errorf("%w: session %s is %s", ErrWalkNotCompleted, sessionID, session.Status())
+	}
+	track, err := ts.replayStore.GetLocationHistory(session.WalkID())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load history of walk %s: %w", session.WalkID(), err)
+	}
+	if len(track) == 0 {
+		return nil, ErrSessionTrackNotFound
+	}
+	return &WalkReplay{sessionID: sessionID, track: track, speed: speed}, nil
+}
+
+// SessionID returns the replayed session.
+func (r *WalkReplay) SessionID() string {
+	return r.sessionID
+}
+
+// Len returns the number of points in the walk.
+func (r *WalkReplay) Len() int {
+	return len(r.track)
+}
+
+// Position returns the index of the next point to play; Len once playback
+// has reached the end.
+func (r *WalkReplay) Position() int {
+	return r.next
+}
+
+// Speed returns the playback speed.
+func (r *WalkReplay) Speed() int {
+	return r.speed
+}
+
+// SetSpeed changes the playback speed from the next point on.
+func (r *WalkReplay) SetSpeed(speed int) error {
+	if err := ValidateReplaySpeed(speed); err != nil {
+		return err
+	}
+	r.speed = speed
+	return nil
+}
+
+// Start returns the time of the walk's first point.
+func (r *WalkReplay) Start() time.Time {
+	return r.track[0].Timestamp
+}
+
+// End returns the time of the walk's last point.
+func (r *WalkReplay) End() time.Time {
+	return r.track[len(r.track)-1].Timestamp
+}
+
+// Seek moves playback to the first point recorded at or after t, playing it
+// without delay, and returns its position. Instants before the walk rewind to
+// its start; instants after it end playback.
+func (r *WalkReplay) Seek(t time.Time) int {
+	r.next = sort.Search(len(r.track), func(i int) bool {
+		return !r.track[i].Timestamp.Before(t)
+	})
+	r.last = time.Time{}
+	return r.next
+}
+
+// Peek returns the next point to play and how long to wait before playing
+// it. It reports false once playback has reached the end.
+func (r *WalkReplay) Peek() (models.Location, time.Duration, bool) {
+	if r.next >= len(r.track) {
+		return models.Location{}, 0, false
+	}
+	loc := r.track[r.next]
+	if r.last.IsZero() {
+		return loc, 0, true
+	}
+	wait := loc.Timestamp.Sub(r.last) / time.Duration(r.speed)
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > maxReplayGap {
+		wait = maxReplayGap
+	}
+	return loc, wait, true
+}
+
+// Advance marks the point returned by Peek as played.
+func (r *WalkReplay) Advance() {
+	if r.next >= len(r.track) {
+		return
+	}
+	r.last = r.track[r.next].Timestamp
+	r.next++
+}
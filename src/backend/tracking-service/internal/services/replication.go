@@ -0,0 +1,447 @@
+package services
+
+import (
+	// json for encoding replicated events on the message bus (go1.21)
+	"encoding/json"
+	// fmt for formatting subjects and error messages (go1.21)
+	"fmt"
+	// sync for guarding replicated state and the dedupe window (go1.21)
+	"sync"
+	// time for event timestamps and dedupe expiry (go1.21)
+	"time"
+
+	// uuid for generating unique event identifiers (github.com/google/uuid v1.3.0)
+	"github.com/google/uuid"
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+	// prometheus for replication metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+
+	// models package that includes the TrackingSession and TrackingStatistics structs
+	"src/backend/tracking-service/internal/models"
+
+	// utils package for MQTT message classes
+	"src/backend/tracking-service/internal/utils"
+)
+
+// ReplicationSubjectFormat is the message bus subject on which a region receives
+// replicated session events. The placeholder is the receiving region.
+const ReplicationSubjectFormat = "replication/sessions/%s"
+
+// replicationDedupeWindow bounds how long applied event IDs are remembered for
+// idempotent apply, and how long a completed session's replicated state is kept
+// after its last update. Redeliveries older than this are still safe because the
+// version check rejects them, but they are no longer counted as duplicates.
+const replicationDedupeWindow = 24 * time.Hour
+
+// Session event types mirrored between regions. Raw location points are never
+// replicated; only lifecycle transitions and completed-walk summaries are.
+const (
+	SessionEventStarted   = "session.started"
+	SessionEventPaused    = "session.paused"
+	SessionEventResumed   = "session.resumed"
+	SessionEventCompleted = "session.completed"
+	SessionEventSummary   = "session.summary"
+)
+
+// MessageBus abstracts the inter-region transport used for replication. An
+// implementation only needs at-least-once delivery; the replicator handles
+// duplicates and reordering.
+type MessageBus interface {
+	// Publish sends a payload to the given subject, with the QoS level
+	// configured for its class.
+	Publish(class utils.MessageClass, subject string, payload []byte) error
+	// Subscribe registers a handler invoked for every payload received on subject.
+	Subscribe(subject string, handler func(payload []byte)) error
+}
+
+// SessionSummary is the replicated, point-free summary of a tracking session.
+type SessionSummary struct {
+	TotalDistance   float64 `json:"totalDistance"`
+	DurationSeconds float64 `json:"durationSeconds"`
+	AverageSpeed    float64 `json:"averageSpeed"`
+	MaxSpeed        float64 `json:"maxSpeed"`
+
+	Coverage *models.TerritoryCoverage `json:"coverage,omitempty"`
+
+	StartAddress string `json:"startAddress,omitempty"`
+	EndAddress   string `json:"endAddress,omitempty"`
+
+	Dog *models.DogProfile `json:"dog,omitempty"`
+
+	Prediction      *models.WalkPrediction  `json:"prediction,omitempty"`
+	PredictionError *models.PredictionError `json:"predictionError,omitempty"`
+
+	SequenceGaps *models.SequenceGapStats `json:"sequenceGaps,omitempty"`
+}
+
+// SessionEvent is a single replicated session lifecycle or summary event.
+// Version is a per-session Lamport clock shared by all regions, which gives the
+// conflict rules a total order independent of wall-clock skew.
+type SessionEvent struct {
+	EventID    string          `json:"eventId"`
+	Type       string          `json:"type"`
+	SessionID  string          `json:"sessionId"`
+	WalkID     string          `json:"walkId"`
+	WalkerID   string          `json:"walkerId"`
+	DogID      string          `json:"dogId"`
+	Status     string          `json:"status"`
+	Region     string          `json:"region"`
+	Version    int64           `json:"version"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Summary    *SessionSummary `json:"summary,omitempty"`
+}
+
+// ReplicatedSession is the last-known replicated state of a session, as seen
+// by this region. It is what a peer region falls back to during failover.
+type ReplicatedSession struct {
+	SessionID string
+	WalkID    string
+	WalkerID  string
+	DogID     string
+	Status    string
+	Region    string
+	Version   int64
+	UpdatedAt time.Time
+	Summary   *SessionSummary
+	// Regions records every region that has modified this session, which is
+	// how dual-region modifications are surfaced to operators.
+	Regions map[string]struct{}
+}
+
+// EventReplicator mirrors session lifecycle and summary events to peer regions
+// over a MessageBus and applies events received from them idempotently.
+//
+// Conflict rules for sessions modified in more than one region:
+//  1. Completed is terminal: once a session is completed, only summary events
+//     for it are accepted and it never transitions back to active or paused.
+//  2. Otherwise the event with the higher Version wins.
+//  3. Equal versions are broken by the later OccurredAt, then by the
+//     lexicographically smaller region so every region converges identically.
+type EventReplicator struct {
+	region      string
+	peerRegions []string
+	bus         MessageBus
+	logger      *zap.Logger
+
+	mu        sync.Mutex
+	sessions  map[string]*ReplicatedSession
+	applied   map[string]time.Time
+	lastPrune time.Time
+
+	eventsCounter    *prometheus.CounterVec
+	conflictsCounter *prometheus.CounterVec
+}
+
+// NewEventReplicator creates a replicator for the local region. Metrics are
+// registered on the given registry when it is non-nil.
+func NewEventReplicator(region string, peerRegions []string, bus MessageBus, logger *zap.Logger, registry *prometheus.Registry) (*EventReplicator, error) {
+	if region == "" {
+		return nil, fmt.Errorf("replication region must not be empty")
+	}
+	if bus == nil {
+		return nil, fmt.Errorf("replication requires a message bus")
+	}
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	er := &EventReplicator{
+		region:      region,
+		peerRegions: peerRegions,
+		bus:         bus,
+		logger:      logger,
+		sessions:    make(map[string]*ReplicatedSession),
+		applied:     make(map[string]time.Time),
+		eventsCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tracking_replication_events_total",
+				Help: "Replicated session events by direction and outcome.",
+			},
+			[]string{"direction", "outcome"},
+		),
+		conflictsCounter: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tracking_replication_conflicts_total",
+				Help: "Replication conflicts by resolution rule.",
+			},
+			[]string{"rule"},
+		),
+	}
+	if registry != nil {
+		registry.MustRegister(er.eventsCounter, er.conflictsCounter)
+	}
+	return er, nil
+}
+
+// Start subscribes to this region's replication subject so that events published
+// by peers are applied locally.
+func (er *EventReplicator) Start() error {
+	subject := fmt.Sprintf(ReplicationSubjectFormat, er.region)
+	return er.bus.Subscribe(subject, func(payload []byte) {
+		var evt SessionEvent
+		if err := json.Unmarshal(payload, &evt); err != nil {
+			er.eventsCounter.WithLabelValues("inbound", "malformed").Inc()
+			er.logger.Warn("Discarding malformed replication event", zap.Error(err))
+			return
+		}
+		if _, err := er.Apply(evt); err != nil {
+			er.logger.Warn("Failed to apply replication event",
+				zap.String("eventID", evt.EventID),
+				zap.String("sessionID", evt.SessionID),
+				zap.Error(err),
+			)
+		}
+	})
+}
+
+// RecordLifecycle records a local lifecycle transition for the session and
+// mirrors it to every peer region.
+func (er *EventReplicator) RecordLifecycle(eventType string, session *models.TrackingSession) error {
+	if session == nil {
+		return fmt.Errorf("cannot replicate lifecycle event for nil session")
+	}
+	evt := er.recordLocal(eventType, session, nil)
+	return er.publish(evt)
+}
+
+// RecordSummary records the completed-walk summary for the session and mirrors
+// it to every peer region.
+func (er *EventReplicator) RecordSummary(session *models.TrackingSession, stats *models.TrackingStatistics) error {
+	if session == nil || stats == nil {
+		return fmt.Errorf("cannot replicate summary without session and statistics")
+	}
+	summary := &SessionSummary{
+		TotalDistance:   stats.TotalDistance,
+		DurationSeconds: stats.Duration.Seconds(),
+		AverageSpeed:    stats.AverageSpeed,
+		MaxSpeed:        stats.MaxSpeed,
+		Coverage:        stats.Coverage,
+		StartAddress:    stats.StartAddress,
+		EndAddress:      stats.EndAddress,
+		Dog:             stats.Dog,
+		Prediction:      stats.Prediction,
+		PredictionError: stats.PredictionError,
+		SequenceGaps:    stats.SequenceGaps,
+	}
+	evt := er.recordLocal(SessionEventSummary, session, summary)
+	return er.publish(evt)
+}
+
+// Apply applies a replicated event to the local replicated state. It returns
+// true when the event changed state and false when it was a duplicate or lost
+// a conflict. Applying the same event any number of times is safe.
+func (er *EventReplicator) Apply(evt SessionEvent) (bool, error) {
+	if evt.EventID == "" || evt.SessionID == "" {
+		er.eventsCounter.WithLabelValues("inbound", "invalid").Inc()
+		return false, fmt.Errorf("replication event is missing eventId or sessionId")
+	}
+
+	er.mu.Lock()
+	defer er.mu.Unlock()
+
+	er.pruneLocked(time.Now().UTC())
+	if _, seen := er.applied[evt.EventID]; seen {
+		er.eventsCounter.WithLabelValues("inbound", "duplicate").Inc()
+		return false, nil
+	}
+	er.applied[evt.EventID] = time.Now().UTC()
+
+	changed := er.applyLocked(evt)
+	if changed {
+		er.eventsCounter.WithLabelValues("inbound", "applied").Inc()
+	} else {
+		er.eventsCounter.WithLabelValues("inbound", "superseded").Inc()
+	}
+	return changed, nil
+}
+
+// Session returns a copy of the replicated state of a session, if known.
+func (er *EventReplicator) Session(sessionID string) (ReplicatedSession, bool) {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+
+	state, ok := er.sessions[sessionID]
+	if !ok {
+		return ReplicatedSession{}, false
+	}
+	copied := *state
+	copied.Regions = make(map[string]struct{}, len(state.Regions))
+	for r := range state.Regions {
+		copied.Regions[r] = struct{}{}
+	}
+	return copied, true
+}
+
+// recordLocal builds an event for a local change and applies it to the
+// replicated state. The session's Lamport clock is advanced past anything this
+// region has already seen under the same lock that records the event, so
+// concurrent local changes never share a version.
+func (er *EventReplicator) recordLocal(eventType string, session *models.TrackingSession, summary *SessionSummary) SessionEvent {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+
+	now := time.Now().UTC()
+	er.pruneLocked(now)
+
+	var version int64 = 1
+	if state, ok := er.sessions[session.ID]; ok {
+		version = state.Version + 1
+	}
+	evt := SessionEvent{
+		EventID:    uuid.NewString(),
+		Type:       eventType,
+		SessionID:  session.ID,
+		WalkID:     session.WalkID(),
+		WalkerID:   session.WalkerID(),
+		DogID:      session.DogID(),
+		Status:     session.Status(),
+		Region:     er.region,
+		Version:    version,
+		OccurredAt: now,
+		Summary:    summary,
+	}
+	er.applied[evt.EventID] = now
+	er.applyLocked(evt)
+	return evt
+}
+
+// publish mirrors a recorded local event to every peer region. Publish
+// failures are reported but do not roll back the local state; peers converge
+// once a later event is delivered.
+func (er *EventReplicator) publish(evt SessionEvent) error {
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		return fmt.Errorf("failed to encode replication event: %w", err)
+	}
+
+	// Summaries carry the walk's final statistics; lifecycle events are
+	// session control state.
+	class := utils.MessageClassControl
+	if evt.Type == SessionEventSummary {
+		class = utils.MessageClassSummary
+	}
+	var firstErr error
+	for _, peer := range er.peerRegions {
+		subject := fmt.Sprintf(ReplicationSubjectFormat, peer)
+		if pubErr := er.bus.Publish(class, subject, payload); pubErr != nil {
+			er.eventsCounter.WithLabelValues("outbound", "failed").Inc()
+			er.logger.Warn("Failed to replicate session event",
+				zap.String("peerRegion", peer),
+				zap.String("sessionID", evt.SessionID),
+				zap.String("type", evt.Type),
+				zap.Error(pubErr),
+			)
+			if firstErr == nil {
+				firstErr = pubErr
+			}
+			continue
+		}
+		er.eventsCounter.WithLabelValues("outbound", "published").Inc()
+	}
+	return firstErr
+}
+
+// applyLocked merges an event into the replicated state using the conflict
+// rules documented on EventReplicator. Callers must hold er.mu.
+func (er *EventReplicator) applyLocked(evt SessionEvent) bool {
+	state, ok := er.sessions[evt.SessionID]
+	if !ok {
+		er.sessions[evt.SessionID] = &ReplicatedSession{
+			SessionID: evt.SessionID,
+			WalkID:    evt.WalkID,
+			WalkerID:  evt.WalkerID,
+			DogID:     evt.DogID,
+			Status:    evt.Status,
+			Region:    evt.Region,
+			Version:   evt.Version,
+			UpdatedAt: evt.OccurredAt,
+			Summary:   evt.Summary,
+			Regions:   map[string]struct{}{evt.Region: {}},
+		}
+		return true
+	}
+
+	state.Regions[evt.Region] = struct{}{}
+	if len(state.Regions) > 1 && evt.Region != state.Region {
+		er.logger.Info("Session modified in multiple regions",
+			zap.String("sessionID", evt.SessionID),
+			zap.String("localWinner", state.Region),
+			zap.String("incomingRegion", evt.Region),
+		)
+	}
+
+	// Rule 1: completed is terminal. Summaries still attach to it.
+	if state.Status == models.SessionStatusCompleted {
+		if evt.Type == SessionEventSummary && evt.Summary != nil && (state.Summary == nil || er.wins(evt, state)) {
+			state.Summary = evt.Summary
+			if evt.Version > state.Version {
+				state.Version = evt.Version
+			}
+			return true
+		}
+		if evt.Status != models.SessionStatusCompleted {
+			er.conflictsCounter.WithLabelValues("completed_terminal").Inc()
+		}
+		return false
+	}
+	if evt.Status == models.SessionStatusCompleted {
+		if evt.Version < state.Version {
+			er.conflictsCounter.WithLabelValues("completed_terminal").Inc()
+		}
+		er.overwriteLocked(state, evt)
+		return true
+	}
+
+	// Rules 2 and 3: highest version, then latest timestamp, then region name.
+	if !er.wins(evt, state) {
+		er.conflictsCounter.WithLabelValues("version").Inc()
+		return false
+	}
+	er.overwriteLocked(state, evt)
+	return true
+}
+
+// wins reports whether the incoming event should replace the current state.
+func (er *EventReplicator) wins(evt SessionEvent, state *ReplicatedSession) bool {
+	if evt.Version != state.Version {
+		return evt.Version > state.Version
+	}
+	if !evt.OccurredAt.Equal(state.UpdatedAt) {
+		return evt.OccurredAt.After(state.UpdatedAt)
+	}
+	return evt.Region < state.Region
+}
+
+// overwriteLocked copies an accepted event into the replicated state.
+func (er *EventReplicator) overwriteLocked(state *ReplicatedSession, evt SessionEvent) {
+	state.Status = evt.Status
+	state.Region = evt.Region
+	state.UpdatedAt = evt.OccurredAt
+	if evt.Version > state.Version {
+		state.Version = evt.Version
+	}
+	if evt.Summary != nil {
+		state.Summary = evt.Summary
+	}
+}
+
+// pruneLocked forgets applied event IDs older than the dedupe window, and
+// completed sessions not updated within it. The sweep runs at most once a
+// minute to keep Apply cheap. Callers must hold er.mu.
+func (er *EventReplicator) pruneLocked(now time.Time) {
+	if now.Sub(er.lastPrune) < time.Minute {
+		return
+	}
+	er.lastPrune = now
+	for id, at := range er.applied {
+		if now.Sub(at) > replicationDedupeWindow {
+			delete(er.applied, id)
+		}
+	}
+	for id, state := range er.sessions {
+		if state.Status == models.SessionStatusCompleted && now.Sub(state.UpdatedAt) > replicationDedupeWindow {
+			delete(er.sessions, id)
+		}
+	}
+}
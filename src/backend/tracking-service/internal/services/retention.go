@@ -0,0 +1,60 @@
+package services
+
+import (
+	// errors for the disabled sentinel (go1.21)
+	"errors"
+	// fmt for wrapping repository errors (go1.21)
+	"fmt"
+
+	// models package for the RetentionReport struct
+	"src/backend/tracking-service/internal/models"
+)
+
+// ErrRetentionDisabled is returned by retention previews when neither the
+// location retention policy nor region profiles are configured.
+var ErrRetentionDisabled = errors.New("data retention is not configured")
+
+// RetentionStore applies the repository's location retention policy. It is
+// implemented by repository.TimescaleRepository.
+type RetentionStore interface {
+	// ManageRetention compresses, deletes and drops the data past its
+	// retention and reports what it did. With dryRun nothing is modified and
+	// the report lists what would be.
+	ManageRetention(dryRun bool) (*models.RetentionReport, error)
+}
+
+// SetRetentionStore enables retention previews of the location retention
+// policy. Passing nil disables them.
+func (ts *TrackingService) SetRetentionStore(store RetentionStore) {
+	ts.retention = store
+}
+
+// PreviewRetention reports what the location retention policy and the
+// region retention sweep would compress, delete and drop if they ran now,
+// without modifying data, so a retention change can be checked before it is
+// enabled.
+func (ts *TrackingService) PreviewRetention() (*models.RetentionReport, error) {
+	if ts.retention == nil && ts.regionProfiles == nil {
+		return nil, ErrRetentionDisabled
+	}
+
+	var report *models.RetentionReport
+	if ts.retention != nil {
+		var err error
+		report, err = ts.retention.ManageRetention(true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to preview location retention: %w", err)
+		}
+	}
+	if ts.regionProfiles != nil {
+		regional, err := ts.EnforceRegionRetention(true)
+		if err != nil {
+			return nil, err
+		}
+		if report == nil {
+			return regional, nil
+		}
+		report.Deleted = append(report.Deleted, regional.Deleted...)
+	}
+	return report, nil
+}
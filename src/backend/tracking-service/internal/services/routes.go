@@ -0,0 +1,76 @@
+package services
+
+import (
+	// errors for sentinel route popularity errors (standard library)
+	"errors"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// models package that includes the RouteSegment and BoundingBox structs
+	"src/backend/tracking-service/internal/models"
+	// utils package providing grid route segmentation
+	"src/backend/tracking-service/internal/utils"
+)
+
+// DefaultPopularRouteLimit is the number of segments returned when a query does
+// not specify a limit, and MaxPopularRouteLimit is the most a query may request.
+const (
+	DefaultPopularRouteLimit = 500
+	MaxPopularRouteLimit     = 5000
+)
+
+// ErrRoutePopularityDisabled is returned by popular route queries when no route
+// store is configured.
+var ErrRoutePopularityDisabled = errors.New("route popularity is not enabled")
+
+// RouteStore aggregates completed walks into the route popularity layer. It is
+// implemented by repository.TimescaleRepository.
+type RouteStore interface {
+	// RecordRouteSegments counts a walk's segments once per walk.
+	RecordRouteSegments(walkID string, segments []models.RouteSegment) error
+	// GetPopularRouteSegments returns the busiest segments in the box walked at least minWalks times.
+	GetPopularRouteSegments(bbox models.BoundingBox, minWalks, limit int) ([]models.PopularRouteSegment, error)
+}
+
+// SetRouteStore enables route popularity aggregation when sessions end. Segments
+// walked fewer than minWalks times are never returned, so a single walker's
+// regular route cannot be singled out. Passing nil disables it.
+func (ts *TrackingService) SetRouteStore(store RouteStore, minWalks int) {
+	if minWalks < 1 {
+		minWalks = 1
+	}
+	ts.routeStore = store
+	ts.routeMinWalks = minWalks
+}
+
+// GetPopularRoutes returns the most walked route segments inside the box, for
+// suggesting routes in the walker app. A limit of zero uses the default.
+func (ts *TrackingService) GetPopularRoutes(bbox models.BoundingBox, limit int) ([]models.PopularRouteSegment, error) {
+	if ts.routeStore == nil {
+		return nil, ErrRoutePopularityDisabled
+	}
+	if limit <= 0 {
+		limit = DefaultPopularRouteLimit
+	}
+	if limit > MaxPopularRouteLimit {
+		limit = MaxPopularRouteLimit
+	}
+	return ts.routeStore.GetPopularRouteSegments(bbox, ts.routeMinWalks, limit)
+}
+
+// recordRoutePopularity adds an ended session's track to the route popularity
+// layer. Failures are logged and never affect the session.
+func (ts *TrackingService) recordRoutePopularity(session *models.TrackingSession) {
+	track, err := ts.fullLocationHistory(session)
+	if err == nil {
+		err = ts.routeStore.RecordRouteSegments(session.WalkID(), utils.RouteSegments(track))
+	}
+	if err != nil {
+		ts.logger.Warn("Failed to record route popularity",
+			zap.String("sessionID", session.ID),
+			zap.String("walkID", session.WalkID()),
+			zap.Error(err),
+		)
+	}
+}
@@ -0,0 +1,232 @@
+package services
+
+import (
+	// errors for the settings validation sentinel (go1.21)
+	"errors"
+	// runtime for goroutine counts and heap statistics (go1.21)
+	"runtime"
+	// sync for guarding the sampled signals and stopping the monitor once (go1.21)
+	"sync"
+	// time for the sampling interval and sustained periods (go1.21)
+	"time"
+
+	// prometheus for queue depth and degradation metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+)
+
+// ErrInvalidRuntimeLimits is returned when a runtime monitor limit is negative.
+var ErrInvalidRuntimeLimits = errors.New("runtime monitor limits cannot be negative")
+
+// Names of the runtime signals sampled besides internal queues.
+const (
+	RuntimeSignalGoroutines = "goroutines"
+	RuntimeSignalHeap       = "heap_alloc_bytes"
+)
+
+// RuntimeSignal is the last sample of one monitored value. Threshold is zero
+// for values that are sampled but never degrade the service.
+type RuntimeSignal struct {
+	Name      string  `json:"name"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold,omitempty"`
+
+	// OverSince is when the value last went above its threshold; nil while it
+	// is at or below it.
+	OverSince *time.Time `json:"overSince,omitempty"`
+
+	// Degraded reports whether the value has stayed above its threshold for
+	// the sustained period.
+	Degraded bool `json:"degraded"`
+}
+
+// RuntimeHealth is a snapshot of the service's own health: its goroutines,
+// heap and internal queues.
+type RuntimeHealth struct {
+	SampledAt   time.Time       `json:"sampledAt"`
+	Degraded    bool            `json:"degraded"`
+	HeapObjects uint64          `json:"heapObjects"`
+	NumGC       uint32          `json:"numGC"`
+	Signals     []RuntimeSignal `json:"signals"`
+}
+
+// monitoredQueue is one internal queue sampled for its depth.
+type monitoredQueue struct {
+	name      string
+	depth     func() int
+	threshold int
+}
+
+// RuntimeMonitor samples the goroutine count, heap and the depth of internal
+// queues, such as the batch writer's buffered points and the stream hub's
+// queued frames, and reports the service degraded while any of them stays
+// above its threshold for the sustained period, so short bursts do not take
+// an instance out of rotation. Queue depths and degradation are exported as
+// metrics; goroutines and heap already are, by the Go collector.
+type RuntimeMonitor struct {
+	maxGoroutines int
+	maxHeapBytes  uint64
+	sustain       time.Duration
+	logger        *zap.Logger
+
+	mu        sync.Mutex
+	queues    []monitoredQueue
+	overSince map[string]time.Time
+	last      RuntimeHealth
+
+	queueDepth     *prometheus.GaugeVec
+	signalDegraded *prometheus.GaugeVec
+	degraded       prometheus.Gauge
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+// NewRuntimeMonitor creates a monitor reporting the service degraded once it
+// runs more than maxGoroutines goroutines, or holds more than maxHeapBytes of
+// heap, for at least sustain; a zero limit is not checked. Its metrics are
+// registered on registry when non-nil.
+func NewRuntimeMonitor(maxGoroutines int, maxHeapBytes uint64, sustain time.Duration, logger *zap.Logger, registry *prometheus.Registry) (*RuntimeMonitor, error) {
+	if maxGoroutines < 0 || sustain < 0 {
+		return nil, ErrInvalidRuntimeLimits
+	}
+	m := &RuntimeMonitor{
+		maxGoroutines: maxGoroutines,
+		maxHeapBytes:  maxHeapBytes,
+		sustain:       sustain,
+		logger:        logger,
+		overSince:     make(map[string]time.Time),
+		queueDepth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "tracking_runtime_queue_depth",
+			Help: "Items waiting in internal queues, by queue",
+		}, []string{"queue"}),
+		signalDegraded: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "tracking_runtime_signal_degraded",
+			Help: "1 while a runtime signal has stayed above its threshold for the sustained period, 0 otherwise, by signal",
+		}, []string{"signal"}),
+		degraded: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "tracking_runtime_degraded",
+			Help: "1 while any runtime signal reports the instance degraded, 0 otherwise",
+		}),
+		stop: make(chan struct{}),
+	}
+	if registry != nil {
+		registry.MustRegister(m.queueDepth, m.signalDegraded, m.degraded)
+	}
+	return m, nil
+}
+
+// AddQueue samples a queue whose current depth is reported by depth. The
+// service is degraded while the depth stays above threshold; a non-positive
+// threshold only exports the depth.
+func (m *RuntimeMonitor) AddQueue(name string, depth func() int, threshold int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queues = append(m.queues, monitoredQueue{name: name, depth: depth, threshold: threshold})
+}
+
+// Start samples every interval in the background, once right away.
+func (m *RuntimeMonitor) Start(interval time.Duration) {
+	m.Sample()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-m.stop:
+				return
+			case <-ticker.C:
+				m.Sample()
+			}
+		}
+	}()
+}
+
+// Stop ends sampling.
+func (m *RuntimeMonitor) Stop() {
+	m.stopOnce.Do(func() { close(m.stop) })
+}
+
+// Sample takes a fresh sample of every signal and returns the resulting
+// health, which also becomes the last health.
+func (m *RuntimeMonitor) Sample() RuntimeHealth {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	health := RuntimeHealth{
+		SampledAt:   now,
+		HeapObjects: mem.HeapObjects,
+		NumGC:       mem.NumGC,
+		Signals:     make([]RuntimeSignal, 0, len(m.queues)+2),
+	}
+	health.Signals = append(health.Signals,
+		m.evaluate(now, RuntimeSignalGoroutines, float64(runtime.NumGoroutine()), float64(m.maxGoroutines)),
+		m.evaluate(now, RuntimeSignalHeap, float64(mem.HeapAlloc), float64(m.maxHeapBytes)),
+	)
+	for _, q := range m.queues {
+		depth := q.depth()
+		m.queueDepth.WithLabelValues(q.name).Set(float64(depth))
+		threshold := 0.0
+		if q.threshold > 0 {
+			threshold = float64(q.threshold)
+		}
+		health.Signals = append(health.Signals, m.evaluate(now, q.name, float64(depth), threshold))
+	}
+
+	var reasons []string
+	for _, s := range health.Signals {
+		if s.Degraded {
+			health.Degraded = true
+			reasons = append(reasons, s.Name)
+		}
+	}
+	switch {
+	case health.Degraded && !m.last.Degraded:
+		m.degraded.Set(1)
+		m.logger.Warn("Runtime signals above their thresholds; reporting the instance degraded",
+			zap.Strings("signals", reasons),
+			zap.Duration("sustainedFor", m.sustain),
+		)
+	case !health.Degraded && m.last.Degraded:
+		m.degraded.Set(0)
+		m.logger.Info("Runtime signals back within their thresholds; instance no longer degraded")
+	}
+	m.last = health
+	return health
+}
+
+// evaluate builds the signal sampled at now, tracking since when it has been
+// above threshold. m.mu must be held.
+func (m *RuntimeMonitor) evaluate(now time.Time, name string, value, threshold float64) RuntimeSignal {
+	signal := RuntimeSignal{Name: name, Value: value, Threshold: threshold}
+	if threshold <= 0 || value <= threshold {
+		delete(m.overSince, name)
+	} else {
+		since, ok := m.overSince[name]
+		if !ok {
+			since = now
+			m.overSince[name] = since
+		}
+		signal.OverSince = &since
+		signal.Degraded = now.Sub(since) >= m.sustain
+	}
+	if signal.Degraded {
+		m.signalDegraded.WithLabelValues(name).Set(1)
+	} else {
+		m.signalDegraded.WithLabelValues(name).Set(0)
+	}
+	return signal
+}
+
+// Health returns the last sampled health.
+func (m *RuntimeMonitor) Health() RuntimeHealth {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last
+}
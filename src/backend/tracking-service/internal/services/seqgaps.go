@@ -0,0 +1,164 @@
+package services
+
+import (
+	// json for encoding retransmit requests (go1.21)
+	"encoding/json"
+	// fmt for control topics (go1.21)
+	"fmt"
+	// time for gap confirmation timing (go1.21)
+	"time"
+
+	// prometheus for sequence gap metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// models package for the sequence tracking of sessions
+	"src/backend/tracking-service/internal/models"
+	// utils package for the control message class
+	"src/backend/tracking-service/internal/utils"
+)
+
+// ControlTopicFormat is the MQTT topic on which a session's device receives
+// control messages, such as RetransmitRequest, whatever transport it sends
+// its points over.
+const ControlTopicFormat = "sessions/%s/control"
+
+// RetransmitRequest asks a session's device to send the points numbered
+// Ranges again, or to confirm them lost when it no longer holds them.
+type RetransmitRequest struct {
+	Type      string            `json:"type"`
+	SessionID string            `json:"sessionId"`
+	Ranges    []models.SeqRange `json:"ranges"`
+}
+
+// SequenceGapDetector follows the per-point sequence numbers devices attach to
+// their fixes and finds the points that never arrived. A missing number is a
+// point lost between device and service, which the device is asked to send
+// again until it arrives or the gap is confirmed lost; consecutive numbers far
+// apart in time are a period the device took no fix, which no retransmission
+// can fill.
+type SequenceGapDetector struct {
+	// confirmAfter is how long a missing range is awaited before it is
+	// confirmed lost, and gpsGapThreshold the pause between consecutive
+	// points counted as a GPS gap.
+	confirmAfter    time.Duration
+	gpsGapThreshold time.Duration
+
+	gaps       *prometheus.CounterVec
+	points     *prometheus.CounterVec
+	gpsSeconds prometheus.Histogram
+}
+
+// NewSequenceGapDetector creates a detector confirming missing ranges lost
+// after confirmAfter and counting pauses longer than gpsGapThreshold as GPS
+// gaps. Its metrics are registered on registry when non-nil.
+func NewSequenceGapDetector(confirmAfter, gpsGapThreshold time.Duration, registry *prometheus.Registry) *SequenceGapDetector {
+	d := &SequenceGapDetector{
+		confirmAfter:    confirmAfter,
+		gpsGapThreshold: gpsGapThreshold,
+		gaps: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tracking_sequence_gaps_total",
+			Help: "Gaps in device point sequences by cause: network for missing sequence numbers, gps for pauses between consecutive fixes",
+		}, []string{"cause"}),
+		points: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tracking_sequence_points_total",
+			Help: "Points of network sequence gaps by outcome: missing when detected, recovered when retransmitted, lost when confirmed lost",
+		}, []string{"outcome"}),
+		gpsSeconds: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "tracking_sequence_gps_gap_seconds",
+			Help:    "Length of pauses between consecutive device fixes counted as GPS gaps",
+			Buckets: []float64{30, 60, 120, 300, 600, 1800, 3600},
+		}),
+	}
+	if registry != nil {
+		registry.MustRegister(d.gaps, d.points, d.gpsSeconds)
+	}
+	return d
+}
+
+// SetSequenceGapDetection enables detecting gaps in the sequence numbers of
+// inbound points. Passing nil disables it.
+func (ts *TrackingService) SetSequenceGapDetection(detector *SequenceGapDetector) {
+	ts.sequenceGaps = detector
+}
+
+// observeSequence records the sequence numbers of a batch's points before
+// they are validated, so invalid points still count as arrived, and returns
+// the ranges the device should send again.
+func (ts *TrackingService) observeSequence(session *models.TrackingSession, locations []*models.Location) []models.SeqRange {
+	d := ts.sequenceGaps
+	if d == nil {
+		return nil
+	}
+	now := time.Now().UTC()
+	obs := session.ObserveSequence(locations, now, d.confirmAfter, d.gpsGapThreshold)
+
+	missing := 0
+	for _, r := range obs.Missing {
+		missing += r.Len()
+	}
+	lost := 0
+	for _, r := range obs.Lost {
+		lost += r.Len()
+	}
+	if len(obs.Missing) > 0 {
+		d.gaps.WithLabelValues(models.SeqGapCauseNetwork).Add(float64(len(obs.Missing)))
+		d.points.WithLabelValues("missing").Add(float64(missing))
+		ts.logger.Debug("Detected missing location sequences",
+			zap.String("sessionID", session.ID),
+			zap.Any("ranges", obs.Missing),
+		)
+		ts.requestRetransmit(session.ID, obs.Missing)
+	}
+	if obs.Recovered > 0 {
+		d.points.WithLabelValues("recovered").Add(float64(obs.Recovered))
+	}
+	if lost > 0 {
+		d.points.WithLabelValues("lost").Add(float64(lost))
+		ts.logger.Info("Confirmed missing location sequences lost",
+			zap.String("sessionID", session.ID),
+			zap.Any("ranges", obs.Lost),
+		)
+	}
+	for _, gap := range obs.GPSGaps {
+		d.gaps.WithLabelValues(models.SeqGapCauseGPS).Inc()
+		d.gpsSeconds.Observe(gap.Seconds())
+	}
+	return obs.Missing
+}
+
+// requestRetransmit publishes a RetransmitRequest for ranges on the session's
+// control topic. Transports with their own control channel, WebSocket streams
+// and sequenced upload acks, also carry the ranges in their replies.
+func (ts *TrackingService) requestRetransmit(sessionID string, ranges []models.SeqRange) {
+	if ts.mqttClient == nil {
+		return
+	}
+	payload, err := json.Marshal(RetransmitRequest{Type: "retransmit", SessionID: sessionID, Ranges: ranges})
+	if err == nil {
+		err = ts.mqttClient.Publish(utils.MessageClassControl, fmt.Sprintf(ControlTopicFormat, sessionID), payload)
+	}
+	if err != nil {
+		ts.logger.Warn("Failed to publish retransmit request",
+			zap.String("sessionID", sessionID),
+			zap.Error(err),
+		)
+	}
+}
+
+// ConfirmSequenceGap records that the device of a session no longer holds the
+// points numbered r, so they are counted lost instead of awaited, and returns
+// how many awaited points that confirmed.
+func (ts *TrackingService) ConfirmSequenceGap(sessionID string, r models.SeqRange) (int, error) {
+	session, err := ts.getSession(sessionID)
+	if err != nil {
+		return 0, err
+	}
+	confirmed := session.ConfirmSequenceGap(r)
+	if confirmed > 0 && ts.sequenceGaps != nil {
+		ts.sequenceGaps.points.WithLabelValues("lost").Add(float64(confirmed))
+	}
+	return confirmed, nil
+}
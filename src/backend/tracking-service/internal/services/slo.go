@@ -0,0 +1,305 @@
+package services
+
+import (
+	// errors for sentinel SLO errors (standard library)
+	"errors"
+	// fmt for objective validation errors (standard library)
+	"fmt"
+	// sync for guarding the rolling event buckets (standard library)
+	"sync"
+	// time for latency thresholds and rolling windows (standard library)
+	"time"
+
+	// prometheus for latency, event and burn rate metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// LocationDeliverySLO names the objective on ingest-to-publish latency of
+// location updates.
+const LocationDeliverySLO = "location_delivery"
+
+// sloBucketWidth is the resolution of the rolling event counts.
+const sloBucketWidth = time.Minute
+
+// SLOBurnRateWindows are the windows over which burn rates are reported. Short
+// windows catch fast burns; pairing them with longer ones filters out blips.
+var SLOBurnRateWindows = []time.Duration{5 * time.Minute, 30 * time.Minute, time.Hour, 6 * time.Hour}
+
+// ErrSLOTrackingDisabled is returned by SLO queries when no tracker is configured.
+var ErrSLOTrackingDisabled = errors.New("SLO tracking is not enabled")
+
+// SLOObjective is a latency objective: Target of events must complete within
+// Threshold, measured over a rolling Period.
+type SLOObjective struct {
+	Name      string
+	Threshold time.Duration
+	Target    float64
+	Period    time.Duration
+}
+
+// Validate checks that the objective is measurable.
+func (o SLOObjective) Validate() error {
+	if o.Name == "" {
+		return fmt.Errorf("SLO name must not be empty")
+	}
+	if o.Threshold <= 0 {
+		return fmt.Errorf("SLO threshold must be positive")
+	}
+	if o.Target <= 0 || o.Target >= 1 {
+		return fmt.Errorf("SLO target must be between 0 and 1 exclusive")
+	}
+	if o.Period < sloBucketWidth {
+		return fmt.Errorf("SLO period must be at least %s", sloBucketWidth)
+	}
+	return nil
+}
+
+// SLOBurnRate is the rate at which the error budget is being spent over one
+// window: 1 spends exactly the budget over the period, 10 spends it ten times as
+// fast.
+type SLOBurnRate struct {
+	Window   string  `json:"window"`
+	Events   int64   `json:"events"`
+	Bad      int64   `json:"bad"`
+	BurnRate float64 `json:"burnRate"`
+}
+
+// SLOStatus is a snapshot of an objective's compliance over its period.
+type SLOStatus struct {
+	Name             string  `json:"name"`
+	ThresholdSeconds float64 `json:"thresholdSeconds"`
+	Target           float64 `json:"target"`
+	PeriodSeconds    float64 `json:"periodSeconds"`
+
+	// Events and Bad count events in the period and those that missed the threshold.
+	Events int64 `json:"events"`
+	Bad    int64 `json:"bad"`
+
+	// Compliance is the fraction of good events, 1 when there were none.
+	Compliance float64 `json:"compliance"`
+
+	// ErrorBudgetRemaining is the unspent fraction of the period's error budget;
+	// it goes negative once the objective is breached.
+	ErrorBudgetRemaining float64 `json:"errorBudgetRemaining"`
+
+	BurnRates []SLOBurnRate `json:"burnRates"`
+}
+
+// sloBucket counts the events of one sloBucketWidth interval.
+type sloBucket struct {
+	good int64
+	bad  int64
+}
+
+// SLOTracker measures one latency objective. Events are counted into a ring of
+// one-minute buckets spanning the objective's period, from which compliance,
+// the remaining error budget and burn rates are derived on demand.
+type SLOTracker struct {
+	objective SLOObjective
+
+	mu        sync.Mutex
+	buckets   []sloBucket
+	head      int
+	headStart time.Time
+
+	latency prometheus.Histogram
+	events  *prometheus.CounterVec
+}
+
+// NewSLOTracker creates a tracker for objective. Metrics are registered on
+// registry when it is non-nil: the latency histogram, good/bad event counters,
+// and gauges for the remaining budget and each burn rate window, computed at
+// scrape time so they decay while no events arrive.
+func NewSLOTracker(objective SLOObjective, registry *prometheus.Registry) (*SLOTracker, error) {
+	if err := objective.Validate(); err != nil {
+		return nil, err
+	}
+
+	t := &SLOTracker{
+		objective: objective,
+		buckets:   make([]sloBucket, int(objective.Period/sloBucketWidth)),
+		headStart: time.Now().Truncate(sloBucketWidth),
+		latency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:        "tracking_pipeline_latency_seconds",
+			Help:        "Latency from ingesting a location update to publishing it to subscribers.",
+			Buckets:     prometheus.ExponentialBuckets(0.05, 2, 10),
+			ConstLabels: prometheus.Labels{"slo": objective.Name},
+		}),
+		events: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name:        "tracking_slo_events_total",
+				Help:        "Events measured against the SLO, by outcome (good or bad).",
+				ConstLabels: prometheus.Labels{"slo": objective.Name},
+			},
+			[]string{"outcome"},
+		),
+	}
+
+	if registry != nil {
+		collectors := []prometheus.Collector{
+			t.latency,
+			t.events,
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Name:        "tracking_slo_error_budget_remaining",
+				Help:        "Unspent fraction of the SLO error budget over the budget period.",
+				ConstLabels: prometheus.Labels{"slo": objective.Name},
+			}, func() float64 {
+				return t.Status().ErrorBudgetRemaining
+			}),
+		}
+		for _, window := range SLOBurnRateWindows {
+			window := window
+			collectors = append(collectors, prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Name:        "tracking_slo_burn_rate",
+				Help:        "Rate of SLO error budget consumption over the window; 1 exhausts it exactly at period end.",
+				ConstLabels: prometheus.Labels{"slo": objective.Name, "window": formatSLOWindow(window)},
+			}, func() float64 {
+				return t.burnRate(window).BurnRate
+			}))
+		}
+		registry.MustRegister(collectors...)
+	}
+	return t, nil
+}
+
+// Objective returns the objective being tracked.
+func (t *SLOTracker) Objective() SLOObjective {
+	return t.objective
+}
+
+// Record counts count events that completed with latency.
+func (t *SLOTracker) Record(latency time.Duration, count int) {
+	if count <= 0 {
+		return
+	}
+	for i := 0; i < count; i++ {
+		t.latency.Observe(latency.Seconds())
+	}
+
+	good := latency <= t.objective.Threshold
+	t.mu.Lock()
+	bucket := t.currentBucketLocked(time.Now())
+	if good {
+		bucket.good += int64(count)
+	} else {
+		bucket.bad += int64(count)
+	}
+	t.mu.Unlock()
+
+	if good {
+		t.events.WithLabelValues("good").Add(float64(count))
+	} else {
+		t.events.WithLabelValues("bad").Add(float64(count))
+	}
+}
+
+// RecordFailure counts count events that never completed, which spend the error
+// budget like events over the threshold.
+func (t *SLOTracker) RecordFailure(count int) {
+	if count <= 0 {
+		return
+	}
+	t.mu.Lock()
+	t.currentBucketLocked(time.Now()).bad += int64(count)
+	t.mu.Unlock()
+	t.events.WithLabelValues("bad").Add(float64(count))
+}
+
+// Status returns the objective's compliance, remaining budget and burn rates.
+func (t *SLOTracker) Status() SLOStatus {
+	status := SLOStatus{
+		Name:             t.objective.Name,
+		ThresholdSeconds: t.objective.Threshold.Seconds(),
+		Target:           t.objective.Target,
+		PeriodSeconds:    t.objective.Period.Seconds(),
+		Compliance:       1,
+	}
+
+	good, bad := t.sum(t.objective.Period)
+	status.Events = good + bad
+	status.Bad = bad
+	if status.Events > 0 {
+		status.Compliance = float64(good) / float64(status.Events)
+		allowed := float64(status.Events) * (1 - t.objective.Target)
+		status.ErrorBudgetRemaining = 1 - float64(bad)/allowed
+	} else {
+		status.ErrorBudgetRemaining = 1
+	}
+
+	status.BurnRates = make([]SLOBurnRate, 0, len(SLOBurnRateWindows))
+	for _, window := range SLOBurnRateWindows {
+		status.BurnRates = append(status.BurnRates, t.burnRate(window))
+	}
+	return status
+}
+
+// burnRate computes the budget consumption rate over window.
+func (t *SLOTracker) burnRate(window time.Duration) SLOBurnRate {
+	good, bad := t.sum(window)
+	rate := SLOBurnRate{Window: formatSLOWindow(window), Events: good + bad, Bad: bad}
+	if rate.Events > 0 {
+		rate.BurnRate = (float64(bad) / float64(rate.Events)) / (1 - t.objective.Target)
+	}
+	return rate
+}
+
+// sum totals the buckets covering the most recent window.
+func (t *SLOTracker) sum(window time.Duration) (int64, int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.currentBucketLocked(time.Now())
+
+	n := int(window / sloBucketWidth)
+	if n > len(t.buckets) {
+		n = len(t.buckets)
+	}
+	var good, bad int64
+	for i := 0; i < n; i++ {
+		bucket := t.buckets[(t.head-i+len(t.buckets))%len(t.buckets)]
+		good += bucket.good
+		bad += bucket.bad
+	}
+	return good, bad
+}
+
+// currentBucketLocked rotates the ring forward to now, clearing buckets that
+// have fallen out of the period, and returns the bucket for now. t.mu must be held.
+func (t *SLOTracker) currentBucketLocked(now time.Time) *sloBucket {
+	steps := int(now.Sub(t.headStart) / sloBucketWidth)
+	if steps > 0 {
+		if steps >= len(t.buckets) {
+			for i := range t.buckets {
+				t.buckets[i] = sloBucket{}
+			}
+		} else {
+			for i := 0; i < steps; i++ {
+				t.head = (t.head + 1) % len(t.buckets)
+				t.buckets[t.head] = sloBucket{}
+			}
+		}
+		t.headStart = t.headStart.Add(time.Duration(steps) * sloBucketWidth)
+	}
+	return &t.buckets[t.head]
+}
+
+// formatSLOWindow renders a window as a compact label such as "5m" or "6h".
+func formatSLOWindow(window time.Duration) string {
+	if window%time.Hour == 0 {
+		return fmt.Sprintf("%dh", int(window/time.Hour))
+	}
+	return fmt.Sprintf("%dm", int(window/time.Minute))
+}
+
+// SetSLOTracker enables measurement of the location delivery objective.
+// Passing nil disables it.
+func (ts *TrackingService) SetSLOTracker(tracker *SLOTracker) {
+	ts.slo = tracker
+}
+
+// GetSLOStatus returns the location delivery objective's current status.
+func (ts *TrackingService) GetSLOStatus() (SLOStatus, error) {
+	if ts.slo == nil {
+		return SLOStatus{}, ErrSLOTrackingDisabled
+	}
+	return ts.slo.Status(), nil
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	// errors for the disabled sentinel (go1.21)
+	"errors"
+
+	// models package that includes the ActivitySparkline struct
+	"src/backend/tracking-service/internal/models"
+)
+
+// ErrSparklineDisabled is returned by sparkline queries when no sparkline store
+// is configured.
+var ErrSparklineDisabled = errors.New("activity sparklines are not enabled")
+
+// SparklineStore serves per-minute walk activity from the database's continuous
+// aggregate. It is implemented by repository.TimescaleRepository.
+type SparklineStore interface {
+	// GetActivitySparkline returns the walk's distance-per-minute series.
+	GetActivitySparkline(walkID string) (*models.ActivitySparkline, error)
+}
+
+// SetSparklineStore enables session activity sparklines. Passing nil disables them.
+func (ts *TrackingService) SetSparklineStore(store SparklineStore) {
+	ts.sparklineStore = store
+}
+
+// GetSessionSparkline returns the distance-per-minute activity series of a
+// session's walk, for the owner app's compact activity chart.
+func (ts *TrackingService) GetSessionSparkline(sessionID string) (*models.ActivitySparkline, error) {
+	if ts.sparklineStore == nil {
+		return nil, ErrSparklineDisabled
+	}
+	session, err := ts.resolveSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	return ts.sparklineStore.GetActivitySparkline(session.WalkID())
+}
@@ -0,0 +1,273 @@
+package services
+
+import (
+	// json for encoding spooled batches (go1.21)
+	"encoding/json"
+	// errors for the unavailable store and full spool sentinels (go1.21)
+	"errors"
+	// fmt for spool file names and wrapping errors (go1.21)
+	"fmt"
+	// os for spool files (go1.21)
+	"os"
+	// filepath for spool paths (go1.21)
+	"path/filepath"
+	// sort for draining oldest batches first (go1.21)
+	"sort"
+	// strings for matching spool file names (go1.21)
+	"strings"
+	// sync for guarding the spool (go1.21)
+	"sync"
+	// time for spool timestamps and the drain interval (go1.21)
+	"time"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+	// prometheus for spool metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+
+	// models package that includes the Location struct
+	"src/backend/tracking-service/internal/models"
+)
+
+var (
+	// ErrStoreUnavailable is returned by location stores that are refusing
+	// writes, e.g. while the database circuit breaker is open, as opposed to
+	// failing a particular write.
+	ErrStoreUnavailable = errors.New("location store unavailable")
+
+	// ErrSpoolFull is returned by LocationSpool.Append when the spool holds its
+	// maximum number of batches.
+	ErrSpoolFull = errors.New("location spool is full")
+)
+
+// Spool file suffixes: pending batches, and batches that could not be decoded
+// and are kept aside for inspection.
+const (
+	spoolFileSuffix   = ".batch.json"
+	spoolRejectSuffix = ".rejected"
+)
+
+// spooledBatch is the content of one spool file.
+type spooledBatch struct {
+	SessionID string             `json:"sessionId"`
+	Locations []*models.Location `json:"locations"`
+	SpooledAt time.Time          `json:"spooledAt"`
+}
+
+// LocationSpool holds location batches on disk while the database refuses
+// writes, and drains them in arrival order once it accepts them again. Each
+// batch is one file written atomically, so the spool survives restarts and a
+// crash never leaves a partial batch behind.
+type LocationSpool struct {
+	dir        string
+	maxBatches int
+	logger     *zap.Logger
+
+	// mu guards pending and seq; drainMu serializes drains, which write to the
+	// database without blocking appends.
+	mu      sync.Mutex
+	pending int
+	seq     uint64
+	drainMu sync.Mutex
+
+	batches prometheus.Gauge
+	points  *prometheus.CounterVec
+
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+// NewLocationSpool opens the spool in dir, creating it if needed and counting
+// batches left over from a previous run, and registers its metrics on registry
+// when non-nil.
+func NewLocationSpool(dir string, maxBatches int, logger *zap.Logger, registry *prometheus.Registry) (*LocationSpool, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	s := &LocationSpool{
+		dir:        dir,
+		maxBatches: maxBatches,
+		logger:     logger,
+		batches: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "tracking_spool_batches",
+			Help: "Location batches held in the spool awaiting the database",
+		}),
+		points: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tracking_spool_points_total",
+			Help: "Location points passing through the spool, by outcome",
+		}, []string{"outcome"}),
+		stop: make(chan struct{}),
+	}
+	names, err := s.pendingFiles()
+	if err != nil {
+		return nil, err
+	}
+	s.pending = len(names)
+	s.batches.Set(float64(s.pending))
+	if registry != nil {
+		registry.MustRegister(s.batches, s.points)
+	}
+	return s, nil
+}
+
+// Len returns the number of batches awaiting the database.
+func (s *LocationSpool) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending
+}
+
+// Append writes a batch to the spool.
+func (s *LocationSpool) Append(sessionID string, locations []*models.Location) error {
+	raw, err := json.Marshal(spooledBatch{SessionID: sessionID, Locations: locations, SpooledAt: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.maxBatches > 0 && s.pending >= s.maxBatches {
+		return ErrSpoolFull
+	}
+	s.seq++
+	// Zero-padded names sort in arrival order.
+	name := fmt.Sprintf("%020d-%010d%s", time.Now().UnixNano(), s.seq, spoolFileSuffix)
+	tmp := filepath.Join(s.dir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, raw, 0o640); err != nil {
+		return fmt.Errorf("failed to write spool file: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to commit spool file: %w", err)
+	}
+	s.pending++
+	s.batches.Set(float64(s.pending))
+	s.points.WithLabelValues("spooled").Add(float64(len(locations)))
+	return nil
+}
+
+// Drain writes spooled batches to store, oldest first, removing each once it
+// is stored. It stops at the first failed write, leaving that batch and the
+// rest for the next drain, and returns the number of batches stored. Batches
+// that cannot be decoded are renamed aside rather than blocking the spool.
+func (s *LocationSpool) Drain(store TimescaleDB) (int, error) {
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+
+	names, err := s.pendingFiles()
+	if err != nil {
+		return 0, err
+	}
+	drained := 0
+	for _, name := range names {
+		path := filepath.Join(s.dir, name)
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return drained, err
+		}
+		var batch spooledBatch
+		if err := json.Unmarshal(raw, &batch); err != nil {
+			s.logger.Error("Setting aside undecodable spool file", zap.String("file", name), zap.Error(err))
+			if renameErr := os.Rename(path, path+spoolRejectSuffix); renameErr != nil {
+				return drained, renameErr
+			}
+			s.removed(0, "rejected")
+			continue
+		}
+		if err := store.StoreLocationBatch(batch.SessionID, batch.Locations); err != nil {
+			return drained, err
+		}
+		if err := os.Remove(path); err != nil {
+			return drained, err
+		}
+		drained++
+		s.removed(len(batch.Locations), "drained")
+	}
+	return drained, nil
+}
+
+// removed accounts for a batch of points leaving the spool with outcome.
+func (s *LocationSpool) removed(points int, outcome string) {
+	s.mu.Lock()
+	s.pending--
+	s.batches.Set(float64(s.pending))
+	s.mu.Unlock()
+	s.points.WithLabelValues(outcome).Add(float64(points))
+}
+
+// pendingFiles lists the spooled batch files in arrival order.
+func (s *LocationSpool) pendingFiles() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), spoolFileSuffix) && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Start drains the spool into store every interval in the background.
+func (s *LocationSpool) Start(store TimescaleDB, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				if s.Len() == 0 {
+					continue
+				}
+				drained, err := s.Drain(store)
+				if drained > 0 {
+					s.logger.Info("Drained spooled location batches",
+						zap.Int("batches", drained),
+						zap.Int("remaining", s.Len()),
+					)
+				}
+				if err != nil && !errors.Is(err, ErrStoreUnavailable) {
+					s.logger.Warn("Spool drain interrupted", zap.Error(err))
+				}
+			}
+		}
+	}()
+}
+
+// Stop ends background draining.
+func (s *LocationSpool) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// SetLocationSpool enables graceful degradation of location writes: batches
+// the store refuses with ErrStoreUnavailable are spooled and reported as
+// queued instead of failing. Passing nil disables it.
+func (ts *TrackingService) SetLocationSpool(spool *LocationSpool) {
+	ts.spool = spool
+}
+
+// spoolBatch spools a batch the store refused as unavailable, reporting
+// whether it was queued.
+func (ts *TrackingService) spoolBatch(sessionID string, locations []*models.Location, storeErr error) bool {
+	if ts.spool == nil || !errors.Is(storeErr, ErrStoreUnavailable) {
+		return false
+	}
+	if err := ts.spool.Append(sessionID, locations); err != nil {
+		ts.logger.Error("Failed to spool location batch",
+			zap.String("sessionID", sessionID),
+			zap.Int("locationCount", len(locations)),
+			zap.Error(err),
+		)
+		return false
+	}
+	ts.logger.Warn("Location store unavailable; batch spooled",
+		zap.String("sessionID", sessionID),
+		zap.Int("locationCount", len(locations)),
+	)
+	return true
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	// fmt for wrapping repository errors (standard library)
+	"fmt"
+	// time for the asOf bound (standard library)
+	"time"
+
+	// models package that includes the TrackingSession and TrackingStatistics structs
+	"src/backend/tracking-service/internal/models"
+)
+
+// StatisticsHistoryStore loads persisted walk tracks up to a point in time. It is
+// implemented by repository.TimescaleRepository.
+type StatisticsHistoryStore interface {
+	// GetLocationHistoryUntil returns the walk's points recorded at or before until.
+	GetLocationHistoryUntil(walkID string, until time.Time) ([]models.Location, error)
+}
+
+// SetStatisticsHistoryStore lets point-in-time statistics read the persisted
+// track, so they cover walks whose in-memory history has been windowed. Passing
+// nil restricts them to in-memory history.
+func (ts *TrackingService) SetStatisticsHistoryStore(store StatisticsHistoryStore) {
+	ts.historyStore = store
+}
+
+// GetSessionStatisticsAsOf returns the statistics a session reported at asOf,
+// computed only from points recorded up to then, so support can see exactly
+// what the owner saw at a given moment. A zero asOf returns current statistics.
+//
+// Steps:
+//  1. Resolve the session, rebuilding a finished one from its event stream
+//  2. Load the track up to asOf, from the database when available, and the
+//     session's beacon sightings so indoor periods are not counted as gaps
+//  3. Recompute the statistics over that prefix with the summary distance
+//     calculator
+func (ts *TrackingService) GetSessionStatisticsAsOf(sessionID string, asOf time.Time) (*models.TrackingStatistics, error) {
+	session, err := ts.resolveSession(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if asOf.IsZero() {
+		return session.CalculateStatistics()
+	}
+
+	var track []models.Location
+	if ts.historyStore != nil {
+		if track, err = ts.historyStore.GetLocationHistoryUntil(session.WalkID(), asOf); err != nil {
+			return nil, fmt.Errorf("failed to load history of walk %s: %w", session.WalkID(), err)
+		}
+	} else {
+		if session.HistoryTruncated() {
+			return nil, fmt.Errorf("%w: in-memory history of session %s is truncated", ErrSessionTrackNotFound, sessionID)
+		}
+		track = session.LocationHistory()
+	}
+	beacons, err := ts.sessionBeaconEvents(session.ID)
+	if err != nil {
+		return nil, err
+	}
+	return session.StatisticsAsOf(track, beacons, asOf, ts.summaryDistance), nil
+}
+
+// SetSummaryDistance sets the calculator measuring recomputed statistics, such
+// as utils.WGS84 for geodesic accuracy on long segments. Live updates always
+// use the haversine formula. Passing nil restores it for recomputation too.
+func (ts *TrackingService) SetSummaryDistance(distance models.DistanceCalculator) {
+	ts.summaryDistance = distance
+}
@@ -0,0 +1,360 @@
+package services
+
+import (
+	// aes and cipher for AES-256-GCM frame encryption (go1.21)
+	"crypto/aes"
+	"crypto/cipher"
+	// rand for key, key ID and nonce generation (go1.21)
+	"crypto/rand"
+	// base64 for encoding keys and sealed frames (go1.21)
+	"encoding/base64"
+	// hex for key IDs (go1.21)
+	"encoding/hex"
+	// errors for sentinel stream encryption errors (go1.21)
+	"errors"
+	// fmt for wrapping errors (go1.21)
+	"fmt"
+	// sync for guarding the keyring (go1.21)
+	"sync"
+	// time for key issue timestamps (go1.21)
+	"time"
+
+	// prometheus for stream encryption metrics (github.com/prometheus/client_golang/prometheus v1.16.0)
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// StreamKeyAlgorithm identifies the cipher of stream keys and sealed frames:
+// AES-256-GCM with a 96-bit random nonce.
+const StreamKeyAlgorithm = "A256GCM"
+
+// streamKeySize is the length of a stream key in bytes.
+const streamKeySize = 32
+
+var (
+	// ErrStreamEncryptionDisabled is returned by share operations when stream
+	// encryption is not configured.
+	ErrStreamEncryptionDisabled = errors.New("stream encryption is not enabled")
+
+	// ErrNotStreamMember is returned when a subscriber requests the stream key of
+	// a session that is not shared with them.
+	ErrNotStreamMember = errors.New("session is not shared with subscriber")
+
+	// ErrStreamSessionInactive is returned when sharing a session that is not active.
+	ErrStreamSessionInactive = errors.New("session is not active")
+
+	// ErrNotSessionOwner is returned when someone other than a session's
+	// walker changes who it is shared with.
+	ErrNotSessionOwner = errors.New("requester does not own the session")
+)
+
+// StreamKey is the symmetric key a subscriber uses to decrypt a session's
+// live frames. KeyID is carried on every sealed frame so clients can tell when
+// the key has rotated and fetch the new one. Key is the base64-encoded
+// 256-bit key.
+type StreamKey struct {
+	KeyID     string    `json:"keyId"`
+	Algorithm string    `json:"algorithm"`
+	Key       string    `json:"key"`
+	IssuedAt  time.Time `json:"issuedAt"`
+}
+
+// SealedFrame is an encrypted stream payload. The additional authenticated
+// data is the session ID and key ID joined by ".", binding the ciphertext to
+// its session.
+type SealedFrame struct {
+	KeyID      string `json:"keyId"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// sessionStreamKey is a session's current key and the subscribers it has been
+// issued to.
+type sessionStreamKey struct {
+	id       string
+	aead     cipher.AEAD
+	raw      []byte
+	issuedAt time.Time
+	members  map[string]struct{}
+}
+
+// StreamKeyring holds per-session symmetric keys for sessions shared with
+// sensitive subscribers. A session's key is created when it is first shared and
+// rotated whenever its membership changes, so a removed subscriber cannot read
+// frames sent after removal and a new one cannot read frames sealed before it
+// joined. Sessions never shared are streamed in the clear.
+//
+// Keys live in memory only: they are lost on restart, after which clients must
+// be re-shared, and a session's subscribers must be served by the instance
+// holding its key. With key escrow enabled, a copy of each key is also stored
+// wrapped under the escrow key, see StreamKeyEscrow.
+type StreamKeyring struct {
+	mu       sync.RWMutex
+	sessions map[string]*sessionStreamKey
+
+	// escrow, when set, receives every key before it is used.
+	escrow func(sessionID string, key StreamKey) error
+
+	rotations *prometheus.CounterVec
+	sealed    prometheus.Counter
+}
+
+// NewStreamKeyring creates an empty keyring, registering its metrics on
+// registry when non-nil.
+func NewStreamKeyring(registry *prometheus.Registry) *StreamKeyring {
+	kr := &StreamKeyring{
+		sessions: make(map[string]*sessionStreamKey),
+		rotations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tracking_stream_key_rotations_total",
+			Help: "Stream keys issued, by the membership change that triggered them",
+		}, []string{"reason"}),
+		sealed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tracking_stream_frames_sealed_total",
+			Help: "Outbound stream frames encrypted with a session stream key",
+		}),
+	}
+	if registry != nil {
+		registry.MustRegister(kr.rotations, kr.sealed)
+	}
+	return kr
+}
+
+// Share adds subscriberID to the session's members and rotates its key,
+// returning the new key for delivery to the subscriber.
+func (kr *StreamKeyring) Share(sessionID, subscriberID string) (StreamKey, error) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	members := map[string]struct{}{subscriberID: {}}
+	if current, ok := kr.sessions[sessionID]; ok {
+		for member := range current.members {
+			members[member] = struct{}{}
+		}
+	}
+	key, err := newSessionStreamKey(members)
+	if err != nil {
+		return StreamKey{}, err
+	}
+	if err := kr.escrowKey(sessionID, key); err != nil {
+		return StreamKey{}, err
+	}
+	kr.sessions[sessionID] = key
+	kr.rotations.WithLabelValues("share").Inc()
+	return key.export(), nil
+}
+
+// Unshare removes subscriberID from the session's members and rotates its key.
+// When no members remain the session reverts to unencrypted streaming. It
+// reports whether subscriberID was a member.
+func (kr *StreamKeyring) Unshare(sessionID, subscriberID string) (bool, error) {
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+
+	current, ok := kr.sessions[sessionID]
+	if !ok {
+		return false, nil
+	}
+	if _, member := current.members[subscriberID]; !member {
+		return false, nil
+	}
+	if len(current.members) == 1 {
+		delete(kr.sessions, sessionID)
+		return true, nil
+	}
+
+	members := make(map[string]struct{}, len(current.members)-1)
+	for member := range current.members {
+		if member != subscriberID {
+			members[member] = struct{}{}
+		}
+	}
+	key, err := newSessionStreamKey(members)
+	if err != nil {
+		return false, err
+	}
+	if err := kr.escrowKey(sessionID, key); err != nil {
+		return false, err
+	}
+	kr.sessions[sessionID] = key
+	kr.rotations.WithLabelValues("unshare").Inc()
+	return true, nil
+}
+
+// Key returns the session's current key for subscriberID, or ErrNotStreamMember
+// when the session is not shared with them.
+func (kr *StreamKeyring) Key(sessionID, subscriberID string) (StreamKey, error) {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	current, ok := kr.sessions[sessionID]
+	if !ok {
+		return StreamKey{}, ErrNotStreamMember
+	}
+	if _, member := current.members[subscriberID]; !member {
+		return StreamKey{}, ErrNotStreamMember
+	}
+	return current.export(), nil
+}
+
+// Seal encrypts payload with the session's current key. It returns nil when
+// the session has no key and should be streamed in the clear.
+func (kr *StreamKeyring) Seal(sessionID string, payload []byte) (*SealedFrame, error) {
+	kr.mu.RLock()
+	current, ok := kr.sessions[sessionID]
+	kr.mu.RUnlock()
+	if !ok {
+		return nil, nil
+	}
+
+	nonce := make([]byte, current.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate frame nonce: %w", err)
+	}
+	ciphertext := current.aead.Seal(nil, nonce, payload, []byte(sessionID+"."+current.id))
+	kr.sealed.Inc()
+	return &SealedFrame{
+		KeyID:      current.id,
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Ciphertext: base64.StdEncoding.EncodeToString(ciphertext),
+	}, nil
+}
+
+// SetEscrow hands every key the keyring issues to escrow before the key is
+// used; a key that cannot be escrowed is discarded and the membership change
+// fails. Passing nil disables it.
+func (kr *StreamKeyring) SetEscrow(escrow func(sessionID string, key StreamKey) error) {
+	kr.mu.Lock()
+	kr.escrow = escrow
+	kr.mu.Unlock()
+}
+
+// escrowKey escrows a newly issued key. Callers hold kr.mu.
+func (kr *StreamKeyring) escrowKey(sessionID string, key *sessionStreamKey) error {
+	if kr.escrow == nil {
+		return nil
+	}
+	if err := kr.escrow(sessionID, key.export()); err != nil {
+		return fmt.Errorf("failed to escrow stream key: %w", err)
+	}
+	return nil
+}
+
+// Forget drops the session's key, e.g. once the session has ended.
+func (kr *StreamKeyring) Forget(sessionID string) {
+	kr.mu.Lock()
+	delete(kr.sessions, sessionID)
+	kr.mu.Unlock()
+}
+
+// newSessionStreamKey generates a fresh random key and key ID for members.
+func newSessionStreamKey(members map[string]struct{}) (*sessionStreamKey, error) {
+	raw := make([]byte, streamKeySize)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate stream key: %w", err)
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate stream key ID: %w", err)
+	}
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stream cipher: %w", err)
+	}
+	return &sessionStreamKey{
+		id:       hex.EncodeToString(id),
+		aead:     aead,
+		raw:      raw,
+		issuedAt: time.Now().UTC(),
+		members:  members,
+	}, nil
+}
+
+// export returns the key in its wire form.
+func (k *sessionStreamKey) export() StreamKey {
+	return StreamKey{
+		KeyID:     k.id,
+		Algorithm: StreamKeyAlgorithm,
+		Key:       base64.StdEncoding.EncodeToString(k.raw),
+		IssuedAt:  k.issuedAt,
+	}
+}
+
+// ---------------------------------------------------------------------------
+// TrackingService Integration
+// ---------------------------------------------------------------------------
+
+// SetStreamKeyring enables per-session encryption of live streams for
+// subscribers a session is shared with. Passing nil disables it.
+func (ts *TrackingService) SetStreamKeyring(keyring *StreamKeyring) {
+	ts.streamKeys = keyring
+}
+
+// ShareSession shares an active session's live stream with subscriberID on
+// behalf of ownerID, rotating the session's stream key, and returns the key to
+// hand to the subscriber. It returns ErrNotSessionOwner unless ownerID is the
+// session's walker. Existing members pick up the rotated key via GetStreamKey
+// when they see a new key ID on the stream.
+func (ts *TrackingService) ShareSession(sessionID, ownerID, subscriberID string) (StreamKey, error) {
+	if ts.streamKeys == nil {
+		return StreamKey{}, ErrStreamEncryptionDisabled
+	}
+	session, err := ts.getSession(sessionID)
+	if err != nil {
+		return StreamKey{}, ErrStreamSessionInactive
+	}
+	if ownerID == "" || session.WalkerID() != ownerID {
+		return StreamKey{}, ErrNotSessionOwner
+	}
+	return ts.streamKeys.Share(sessionID, subscriberID)
+}
+
+// UnshareSession revokes subscriberID's access to the session's stream on
+// behalf of requesterID, rotating the key for the remaining members.
+// Subscribers may remove themselves; anyone else must be the session's
+// walker, or ErrNotSessionOwner is returned. It returns ErrNotStreamMember
+// when the session was not shared with subscriberID.
+func (ts *TrackingService) UnshareSession(sessionID, requesterID, subscriberID string) error {
+	if ts.streamKeys == nil {
+		return ErrStreamEncryptionDisabled
+	}
+	if requesterID == "" {
+		return ErrNotSessionOwner
+	}
+	if requesterID != subscriberID {
+		session, err := ts.getSession(sessionID)
+		if err != nil {
+			return ErrNotStreamMember
+		}
+		if session.WalkerID() != requesterID {
+			return ErrNotSessionOwner
+		}
+	}
+	removed, err := ts.streamKeys.Unshare(sessionID, subscriberID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrNotStreamMember
+	}
+	return nil
+}
+
+// GetStreamKey returns the session's current stream key for subscriberID.
+func (ts *TrackingService) GetStreamKey(sessionID, subscriberID string) (StreamKey, error) {
+	if ts.streamKeys == nil {
+		return StreamKey{}, ErrStreamEncryptionDisabled
+	}
+	return ts.streamKeys.Key(sessionID, subscriberID)
+}
+
+// SealStreamFrame encrypts an outbound frame payload for sessionID. It returns
+// nil when stream encryption is disabled or the session is not shared.
+func (ts *TrackingService) SealStreamFrame(sessionID string, payload []byte) (*SealedFrame, error) {
+	if ts.streamKeys == nil {
+		return nil, nil
+	}
+	return ts.streamKeys.Seal(sessionID, payload)
+}
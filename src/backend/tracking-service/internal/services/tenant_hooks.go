@@ -0,0 +1,134 @@
+package services
+
+import (
+	// bytes for encoding point samples (go1.21)
+	"bytes"
+	// json for the point sample payload (go1.21)
+	"encoding/json"
+	// fmt for formatting error messages (go1.21)
+	"fmt"
+	// io for draining error response bodies (go1.21)
+	"io"
+	// http for calling tenant endpoints (go1.21)
+	"net/http"
+	// strings for trimming error bodies (go1.21)
+	"strings"
+	// atomic for counting the hook's points (go1.21)
+	"sync/atomic"
+	// time for request timeouts (go1.21)
+	"time"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// models package that includes the Location struct
+	"src/backend/tracking-service/internal/models"
+)
+
+// maxPointHookPosts bounds the posts a PointHook has in flight. Points sampled
+// while it is at the bound are dropped rather than delaying ingestion.
+const maxPointHookPosts = 4
+
+// PointSample is the body a PointHook POSTs for a sampled point. PointNumber
+// counts the points the hook has seen, so consecutive samples of a hook
+// firing every 100th point are 100 apart.
+type PointSample struct {
+	TenantID    string          `json:"tenantId,omitempty"`
+	SessionID   string          `json:"sessionId"`
+	PointNumber uint64          `json:"pointNumber"`
+	Location    models.Location `json:"location"`
+}
+
+// PointHook is a pipeline stage that POSTs every Nth point accepted in the
+// batches it processes to an endpoint, as a PointSample. It runs in
+// PhasePublish and is meant to be scoped to one tenant with TenantStage,
+// counting the tenant's points across its sessions. Posts are made in the
+// background; failures are logged.
+type PointHook struct {
+	name       string
+	url        string
+	every      uint64
+	httpClient *http.Client
+	logger     *zap.Logger
+
+	seen     atomic.Uint64
+	inflight chan struct{}
+}
+
+// NewPointHook creates a hook named name posting every every-th point to
+// endpointURL, with each post bounded by timeout.
+func NewPointHook(name, endpointURL string, every int, timeout time.Duration, logger *zap.Logger) (*PointHook, error) {
+	if endpointURL == "" {
+		return nil, fmt.Errorf("point hook %s requires an endpoint URL", name)
+	}
+	if every < 1 {
+		return nil, fmt.Errorf("point hook %s interval %d is invalid; must be at least 1", name, every)
+	}
+	return &PointHook{
+		name:       name,
+		url:        endpointURL,
+		every:      uint64(every),
+		httpClient: &http.Client{Timeout: timeout},
+		logger:     logger,
+		inflight:   make(chan struct{}, maxPointHookPosts),
+	}, nil
+}
+
+// Name implements PipelineStage.
+func (h *PointHook) Name() string { return h.name }
+
+// Process implements PipelineStage. It reports the samples it dropped because
+// too many posts were in flight.
+func (h *PointHook) Process(batch *PipelineBatch) error {
+	dropped := 0
+	for i := range batch.Accepted {
+		n := h.seen.Add(1)
+		if n%h.every != 0 {
+			continue
+		}
+		sample := PointSample{
+			TenantID:    batch.TenantID(),
+			SessionID:   batch.SessionID,
+			PointNumber: n,
+			Location:    batch.Accepted[i],
+		}
+		select {
+		case h.inflight <- struct{}{}:
+			go func() {
+				defer func() { <-h.inflight }()
+				if err := h.post(&sample); err != nil {
+					h.logger.Warn("Point hook post failed",
+						zap.String("stage", h.name),
+						zap.String("sessionID", sample.SessionID),
+						zap.Uint64("pointNumber", sample.PointNumber),
+						zap.Error(err),
+					)
+				}
+			}()
+		default:
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		return fmt.Errorf("dropped %d point samples with %d posts in flight", dropped, maxPointHookPosts)
+	}
+	return nil
+}
+
+// post sends one sample to the hook's endpoint.
+func (h *PointHook) post(sample *PointSample) error {
+	body, err := json.Marshal(sample)
+	if err != nil {
+		return err
+	}
+	resp, err := h.httpClient.Post(h.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("point hook endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
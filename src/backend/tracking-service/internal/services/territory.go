@@ -0,0 +1,102 @@
+package services
+
+import (
+	// errors for sentinel territory errors (standard library)
+	"errors"
+	// fmt for formatting error messages (standard library)
+	"fmt"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// models package that includes the TrackingSession and TerritoryCoverage structs
+	"src/backend/tracking-service/internal/models"
+	// utils package providing hull area and territory grid calculations
+	"src/backend/tracking-service/internal/utils"
+)
+
+// ErrTerritoryDisabled is returned by coverage lookups when no territory store
+// is configured.
+var ErrTerritoryDisabled = errors.New("territory coverage is not enabled")
+
+// TerritoryStore persists walk territory coverage and the cells each dog has
+// explored. It is implemented by repository.TimescaleRepository.
+type TerritoryStore interface {
+	// GetDogTerritoryCells returns the cells the dog explored on walks other than excludeWalkID.
+	GetDogTerritoryCells(dogID, excludeWalkID string) (map[string]struct{}, error)
+	// SaveTerritoryCoverage stores a walk's coverage and attributes its cells to the dog.
+	SaveTerritoryCoverage(walkID, dogID string, coverage *models.TerritoryCoverage, cells []string) error
+	// GetTerritoryCoverage returns the stored coverage for a walk.
+	GetTerritoryCoverage(walkID string) (*models.TerritoryCoverage, error)
+	// GetLocationHistory returns the full persisted track of a walk.
+	GetLocationHistory(walkID string) ([]models.Location, error)
+}
+
+// SetTerritoryStore enables territory coverage computation when sessions end.
+// Passing nil disables it.
+func (ts *TrackingService) SetTerritoryStore(store TerritoryStore) {
+	ts.territoryStore = store
+}
+
+// ComputeTerritoryCoverage calculates and persists the area explored during a
+// session, comparing it against the dog's historical walks.
+//
+// Steps:
+//  1. Snapshot the session's location history, loading the persisted track
+//     when older points have been evicted from the in-memory window
+//  2. Compute the convex hull area and the territory cells touched
+//  3. Load the cells the dog explored on earlier walks
+//  4. Derive the new-territory count and percentage
+//  5. Persist the coverage and the walk's cells
+func (ts *TrackingService) ComputeTerritoryCoverage(session *models.TrackingSession) (*models.TerritoryCoverage, error) {
+	if ts.territoryStore == nil {
+		return nil, fmt.Errorf("territory store is not configured")
+	}
+
+	history, err := ts.fullLocationHistory(session)
+	if err != nil {
+		return nil, err
+	}
+	cells := utils.TerritoryCells(history)
+
+	historical, err := ts.territoryStore.GetDogTerritoryCells(session.DogID(), session.WalkID())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load territory history for dog %s: %w", session.DogID(), err)
+	}
+
+	newCells := 0
+	for _, c := range cells {
+		if _, seen := historical[c]; !seen {
+			newCells++
+		}
+	}
+
+	coverage := &models.TerritoryCoverage{
+		AreaSqMeters:        utils.ConvexHullArea(history),
+		CellCount:           len(cells),
+		NewCellCount:        newCells,
+		NewTerritoryPercent: utils.NewTerritoryPercent(cells, historical),
+	}
+
+	if err := ts.territoryStore.SaveTerritoryCoverage(session.WalkID(), session.DogID(), coverage, cells); err != nil {
+		return nil, fmt.Errorf("failed to save territory coverage for walk %s: %w", session.WalkID(), err)
+	}
+
+	ts.logger.Info("Territory coverage computed",
+		zap.String("sessionID", session.ID),
+		zap.String("walkID", session.WalkID()),
+		zap.Float64("areaSqMeters", coverage.AreaSqMeters),
+		zap.Float64("newTerritoryPercent", coverage.NewTerritoryPercent),
+	)
+	return coverage, nil
+}
+
+// GetTerritoryCoverage returns the stored territory coverage for a walk. Store
+// errors are returned unchanged so callers can distinguish a walk without
+// coverage (repository.ErrNotFound) from an invalid walkID or a failed lookup.
+func (ts *TrackingService) GetTerritoryCoverage(walkID string) (*models.TerritoryCoverage, error) {
+	if ts.territoryStore == nil {
+		return nil, ErrTerritoryDisabled
+	}
+	return ts.territoryStore.GetTerritoryCoverage(walkID)
+}
@@ -7,6 +7,8 @@ import (
 	"sync"
 	// fmt for formatting error messages (standard library)
 	"fmt"
+	// json for encoding geofence violation events (standard library)
+	"encoding/json"
 
 	// zap for structured logging (go.uber.org/zap v1.24.0)
 	"go.uber.org/zap"
@@ -17,6 +19,8 @@ import (
 	"src/backend/tracking-service/internal/models"
 	// geofence package that includes the Geofence struct and ContainsPoint function
 	"src/backend/tracking-service/internal/services"
+	// utils package providing coordinate reprojection
+	"src/backend/tracking-service/internal/utils"
 )
 
 // Global variables providing configuration constraints and defaults.
@@ -40,10 +44,12 @@ var (
 // MQTTClient is a placeholder interface representing the functionality required for publishing messages to an MQTT broker.
 // An actual implementation would handle connection setup, topic subscriptions, message publishing, reconnection logic, etc.
 type MQTTClient interface {
-	// Publish sends a message payload to the specified MQTT topic.
-	Publish(topic string, payload []byte) error
-	// SetRetryPolicy configures retry policies for unstable networks or message delivery failures.
-	SetRetryPolicy(retries int, backoff time.Duration)
+	// Publish sends a message payload to the specified MQTT topic, with the
+	// QoS level configured for its class.
+	Publish(class utils.MessageClass, topic string, payload []byte) error
+	// PublishWithProperties sends a message like Publish, with the given MQTT 5
+	// properties; props may be nil.
+	PublishWithProperties(class utils.MessageClass, topic string, payload []byte, props *utils.MessageProperties) error
 }
 
 // TimescaleDB is a placeholder interface representing a connection to a Timescale database.
@@ -64,6 +70,15 @@ type Config struct {
 	MaxConcurrentBatches int
 	// Example: Feature toggle for advanced orchestration.
 	EnableAdvancedOrchestration bool
+	// LocationHistoryMode selects models.HistoryModeBounded or models.HistoryModeWindowed
+	// for new sessions; empty defaults to windowed.
+	LocationHistoryMode string
+	// LocationHistorySize is the in-memory buffer or window size per session; zero or
+	// values above models.MaxLocationHistorySize use models.MaxLocationHistorySize.
+	LocationHistorySize int
+	// LocationExpiry is the MQTT message expiry of published location updates;
+	// zero keeps them until they are delivered.
+	LocationExpiry time.Duration
 }
 
 // BatchResult captures the outcome of processing a batch of location updates, including counts and a success flag.
@@ -74,6 +89,15 @@ type BatchResult struct {
 	InvalidCount int
 	// StoredCount is the number of location records successfully stored in the database.
 	StoredCount int
+	// QueuedCount is the number of location records spooled because the database was
+	// unavailable; they are stored once it recovers.
+	QueuedCount int
+	// DuplicateCount is the number of location records skipped because their ID was
+	// already stored, typically by an upload the client retried.
+	DuplicateCount int
+	// Retransmit lists the sequence number ranges found missing by this batch,
+	// which the device is asked to send again (empty when gap detection is disabled).
+	Retransmit []models.SeqRange
 	// Success indicates whether the entire batch operation was considered successful.
 	Success bool
 }
@@ -98,9 +122,15 @@ type TrackingService struct {
 	// activeSessions stores sessionID -> *models.TrackingSession for real-time lookups and updates.
 	activeSessions *sync.Map
 
+	// startMu serializes session starts, so a walk never gets two active sessions.
+	startMu sync.Mutex
+
 	// mqttClient handles publish/subscribe interactions with an MQTT broker.
 	mqttClient MQTTClient
 
+	// locationExpiry is the MQTT message expiry of location updates.
+	locationExpiry time.Duration
+
 	// db represents a TimescaleDB connection for efficient time-series data storage.
 	db TimescaleDB
 
@@ -112,6 +142,211 @@ type TrackingService struct {
 
 	// sessionPool acts as a reusable pool for session-related objects if needed for optimization.
 	sessionPool *sync.Pool
+
+	// replicator mirrors session lifecycle and summary events to peer regions (nil when disabled).
+	replicator *EventReplicator
+
+	// territoryStore persists walk territory coverage (nil when disabled).
+	territoryStore TerritoryStore
+
+	// historyMode and historySize configure the in-memory location history of new sessions.
+	historyMode string
+	historySize int
+
+	// eventStore records every session state event in event sourcing mode (nil when disabled).
+	eventStore SessionEventStore
+
+	// geofenceGroups holds named, schedule-activated geofence groups checked during health monitoring,
+	// or during ingestion when geofences are evaluated inline.
+	geofenceGroups *GeofenceGroupRegistry
+
+	// presence tracks walker heartbeats independently of sessions (nil when disabled).
+	presence *PresenceRegistry
+
+	// routeStore aggregates completed walks into the route popularity layer (nil when
+	// disabled); routeMinWalks is the fewest walks a segment needs to be returned.
+	routeStore    RouteStore
+	routeMinWalks int
+
+	// demandStore counts completed walks by the cell and hour they started in
+	// (nil when disabled); demandMinStarts is the fewest starts a bucket needs
+	// to be returned.
+	demandStore     DemandStore
+	demandMinStarts int
+
+	// fitnessStore, fitnessUploaders and fitnessTimeout drive automatic uploads of
+	// completed walks to fitness platforms (nil store when disabled).
+	fitnessStore     FitnessTokenStore
+	fitnessUploaders map[string]FitnessUploader
+	fitnessTimeout   time.Duration
+
+	// historyStore serves persisted tracks for point-in-time statistics (nil
+	// limits them to in-memory history).
+	historyStore StatisticsHistoryStore
+
+	// sparklineStore serves per-minute activity series from the database (nil
+	// when disabled).
+	sparklineStore SparklineStore
+
+	// locationQueries answers spatio-temporal point searches from the database
+	// (nil when disabled).
+	locationQueries LocationQueryStore
+
+	// geofenceStore persists walk geofences (nil when disabled).
+	geofenceStore GeofenceStore
+
+	// geofenceGroupStore persists the geofence groups registered in
+	// geofenceGroups (nil when disabled).
+	geofenceGroupStore GeofenceGroupStore
+
+	// replayStore loads the time-ordered tracks of completed walks for replay
+	// (nil when disabled).
+	replayStore ReplayStore
+
+	// leaderboardStore records completed walks and ranks each tenant's walkers
+	// (nil when disabled).
+	leaderboardStore LeaderboardStore
+
+	// exportStore serves persisted walk tracks for history export (nil when
+	// disabled).
+	exportStore HistoryExportStore
+
+	// historyPages serves persisted walk tracks a page at a time (nil when
+	// disabled).
+	historyPages HistoryPageStore
+
+	// beaconStore persists beacon sightings for indoor presence (nil when
+	// disabled); sightings weaker than beaconMinRSSI dBm are ignored.
+	beaconStore   BeaconStore
+	beaconMinRSSI int
+
+	// currentWalks keeps the view of running walks and its change feed in step
+	// with session transitions (nil when disabled).
+	currentWalks CurrentWalksStore
+
+	// geocoder resolves the start and end addresses of completed walks into
+	// their summaries (nil when disabled), each lookup bounded by geocodeTimeout.
+	geocoder       ReverseGeocoder
+	geocodeTimeout time.Duration
+
+	// dogProfiles resolves the walked dog's profile into session-start stream
+	// frames and walk summaries (nil when disabled), each lookup bounded by
+	// dogProfileTimeout.
+	dogProfiles       PetsClient
+	dogProfileTimeout time.Duration
+
+	// walkPrediction estimates each walk's duration and distance when its
+	// session starts (nil when disabled).
+	walkPrediction *WalkPredictionHook
+
+	// reprojector converts points declared in national grids and other
+	// reference systems to WGS84 at ingestion.
+	reprojector *utils.Reprojector
+
+	// regionProfiles resolves the settings of sessions started with a region
+	// code, layered over baseSettings (nil when disabled). baseSettingsMu
+	// guards baseSettings, which configuration reloads replace.
+	regionProfiles RegionProfileStore
+	baseSettingsMu sync.RWMutex
+	baseSettings   models.SessionSettings
+
+	// retention applies the location retention policy, consulted for
+	// retention previews (nil when not configured).
+	retention RetentionStore
+
+	// summaryDistance measures segments when statistics are recomputed from a
+	// stored track (nil uses the haversine formula of live updates).
+	summaryDistance models.DistanceCalculator
+
+	// smoother applies the per-session Kalman filter to fixes before storage,
+	// for sessions the kalman-filter flag is rolled out to (nil when disabled).
+	smoother *utils.LocationSmoother
+
+	// mapMatching snaps fixes to the road and path network after smoothing,
+	// as a filter stage of the pipeline (nil when disabled).
+	mapMatching *MapMatchingStage
+
+	// completed remembers ended sessions so ingress can reject their late
+	// messages with a terminal ack (nil when disabled).
+	completed *CompletedSessionFilter
+
+	// slo measures ingest-to-publish latency against the location delivery
+	// objective (nil when disabled).
+	slo *SLOTracker
+
+	// integrity keeps the report of the scheduled data integrity checks (nil
+	// when disabled).
+	integrity *IntegrityChecker
+
+	// uploadAcks tracks each session's cumulative acked upload sequence;
+	// ackListeners are notified whenever an ack advances.
+	uploadAcks   *UploadAckTracker
+	ackMu        sync.Mutex
+	ackListeners []func(UploadAck)
+
+	// pipeline runs location batches through the built-in and custom stages.
+	pipeline *LocationPipeline
+
+	// locationListeners receive the points each batch added to its session,
+	// for fan-out to live subscribers.
+	locationMu        sync.Mutex
+	locationListeners []func(sessionID string, locations []models.Location)
+
+	// streamKeys holds the stream keys of sessions shared with subscribers that
+	// receive encrypted frames (nil when disabled).
+	streamKeys *StreamKeyring
+
+	// keyEscrow escrows every stream key issued and releases a walk's keys
+	// under a legal request (nil when disabled).
+	keyEscrow *StreamKeyEscrow
+
+	// flags gates pipeline stages under gradual rollout (nil turns them off).
+	flags FeatureFlags
+
+	// ownerAlerts holds owners' alert radii for their dogs (nil when
+	// disabled); ownerWatches holds each session's owner alert state.
+	ownerAlerts  OwnerAlertStore
+	ownerWatches sync.Map
+
+	// geofenceRefresh is how long ingestion reuses a walk's loaded geofences
+	// when evaluating points inline (zero when disabled); geofenceWatches
+	// holds each session's inline geofence state.
+	geofenceRefresh time.Duration
+	geofenceWatches sync.Map
+
+	// devices holds the devices walkers paired (nil when disabled), pairings
+	// are attested with deviceAttestationKey, and devicePolicy decides what
+	// happens to sessions started from devices that are not trusted.
+	devices              DeviceStore
+	deviceAttestationKey []byte
+	devicePolicy         DevicePolicy
+
+	// lifecycle times sessions' first points and streams' first frames per
+	// tenant (nil when disabled).
+	lifecycle *SessionLifecycleMetrics
+
+	// quality counts ingested points by provider and outcome (nil when disabled).
+	quality *LocationQualityMetrics
+
+	// spool holds batches refused while the database is unavailable (nil when
+	// degradation is disabled).
+	spool *LocationSpool
+
+	// recentLocations remembers the IDs of recently stored points so retried
+	// uploads are skipped (nil when disabled).
+	recentLocations *RecentLocationFilter
+
+	// quarantine keeps rejected points for review and reingestion (nil drops
+	// them).
+	quarantine *LocationQuarantine
+
+	// sequenceGaps detects points missing from devices' sequence numbers
+	// (nil when disabled).
+	sequenceGaps *SequenceGapDetector
+
+	// accessLog records every read of a walk's location data (nil when
+	// disabled).
+	accessLog WalkAccessStore
 }
 
 // NewTrackingService creates a new tracking service instance with enhanced monitoring,
@@ -119,17 +354,13 @@ type TrackingService struct {
 //
 // Steps:
 //  1. Initialize enhanced session management with sync.Map
-//  2. Configure MQTT client with retry policies
-//  3. Set up connection pool for database
-//  4. Initialize Prometheus metrics registry
-//  5. Set up structured logging with zap
-//  6. Configure session object pool
-//  7. Initialize health check endpoints (placeholder for advanced setups)
-//  8. Set up monitoring dashboards (placeholder for advanced monitoring)
+//  2. Set up connection pool for database
+//  3. Initialize Prometheus metrics registry
+//  4. Set up structured logging with zap
+//  5. Configure session object pool
+//  6. Initialize health check endpoints (placeholder for advanced setups)
+//  7. Set up monitoring dashboards (placeholder for advanced monitoring)
 func NewTrackingService(mqttClient MQTTClient, db TimescaleDB, config *Config) *TrackingService {
-	// Configure retry policies for MQTT to ensure robustness in unstable networks.
-	mqttClient.SetRetryPolicy(3, time.Second*2)
-
 	// Initialize a new Prometheus registry for collecting and registering metrics.
 	reg := prometheus.NewRegistry()
 
@@ -143,13 +374,267 @@ func NewTrackingService(mqttClient MQTTClient, db TimescaleDB, config *Config) *
 		},
 	}
 
-	return &TrackingService{
+	// Long walks default to a windowed in-memory history; the full track lives in the database.
+	historyMode := models.HistoryModeWindowed
+	historySize := models.MaxLocationHistorySize
+	var locationExpiry time.Duration
+	if config != nil {
+		locationExpiry = config.LocationExpiry
+		if config.LocationHistoryMode != "" {
+			historyMode = config.LocationHistoryMode
+		}
+		if config.LocationHistorySize > 0 && config.LocationHistorySize <= models.MaxLocationHistorySize {
+			historySize = config.LocationHistorySize
+		}
+	}
+
+	ts := &TrackingService{
 		activeSessions:  &sync.Map{},
 		mqttClient:      mqttClient,
+		locationExpiry:  locationExpiry,
 		db:              db,
 		metricsRegistry: reg,
 		logger:          logger,
 		sessionPool:     sPool,
+		historyMode:     historyMode,
+		historySize:     historySize,
+		geofenceGroups:  &GeofenceGroupRegistry{},
+		uploadAcks:      NewUploadAckTracker(),
+		reprojector:     utils.NewReprojector(),
+	}
+	ts.pipeline = ts.newLocationPipeline()
+	return ts
+}
+
+// GeofenceGroups returns the registry of named, schedule-activated geofence
+// groups evaluated during session health checks.
+func (ts *TrackingService) GeofenceGroups() *GeofenceGroupRegistry {
+	return ts.geofenceGroups
+}
+
+// SetEventReplicator enables multi-region replication of session lifecycle and
+// summary events. Passing nil disables replication.
+func (ts *TrackingService) SetEventReplicator(replicator *EventReplicator) {
+	ts.replicator = replicator
+}
+
+// SetLocationSmoother enables GPS smoothing of batches before they reach the
+// session and storage. Passing nil disables it.
+func (ts *TrackingService) SetLocationSmoother(smoother *utils.LocationSmoother) {
+	ts.smoother = smoother
+}
+
+// StartSession creates a new tracking session for the given walk, registers it
+// in activeSessions, and replicates the start event to peer regions.
+func (ts *TrackingService) StartSession(walkID, walkerID, dogID string) (*models.TrackingSession, error) {
+	return ts.StartTenantSession("", walkID, walkerID, dogID)
+}
+
+// StartTenantSession starts a session like StartSession for a walker of the
+// given tenant, whose walks then count towards the tenant's leaderboard.
+func (ts *TrackingService) StartTenantSession(tenantID, walkID, walkerID, dogID string) (*models.TrackingSession, error) {
+	return ts.StartRegionalSession(tenantID, "", walkID, walkerID, dogID)
+}
+
+// StartRegionalSession starts a session like StartTenantSession in the given
+// region, whose profile then sets the session's speed limit, default geofence
+// radius and retention. An empty region gets the base settings.
+func (ts *TrackingService) StartRegionalSession(tenantID, region, walkID, walkerID, dogID string) (*models.TrackingSession, error) {
+	return ts.StartDeviceSession(tenantID, region, "", walkID, walkerID, dogID)
+}
+
+// StartDeviceSession starts a session like StartRegionalSession reported from
+// the given device. With the device registry enabled, the session records the
+// device's trust, and one from a device the walker has not paired, or has
+// revoked, is flagged or rejected with ErrUntrustedDevice as the tenant's
+// device policy decides. A walk that already has an active session continues
+// it, as StartOrContinueSession describes.
+func (ts *TrackingService) StartDeviceSession(tenantID, region, deviceID, walkID, walkerID, dogID string) (*models.TrackingSession, error) {
+	session, _, err := ts.StartOrContinueSession(tenantID, region, deviceID, walkID, walkerID, dogID)
+	return session, err
+}
+
+// startSession creates and registers a new session for StartOrContinueSession.
+func (ts *TrackingService) startSession(tenantID, region, deviceID, walkID, walkerID, dogID string) (*models.TrackingSession, error) {
+	var session *models.TrackingSession
+	var err error
+	if ts.historyMode == models.HistoryModeBounded {
+		session, err = models.NewTrackingSession(walkID, walkerID, dogID, ts.historySize)
+	} else {
+		session, err = models.NewWindowedTrackingSession(walkID, walkerID, dogID, ts.historySize)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to start session: %w", err)
+	}
+	trust, err := ts.checkDevice(tenantID, walkerID, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	session.SetTenantID(tenantID)
+	session.SetSettings(ts.resolveSessionSettings(region))
+	session.SetDevice(deviceID, trust)
+	ts.activeSessions.Store(session.ID, session)
+	if ts.lifecycle != nil {
+		ts.lifecycle.SessionStarted(session)
+	}
+	ts.logger.Info("Tracking session started",
+		zap.String("sessionID", session.ID),
+		zap.String("walkID", walkID),
+	)
+
+	ts.recordStateEvent(session, models.EventSessionStarted, nil)
+	ts.recordWalkTransition(session, models.CurrentWalkStarted)
+	ts.recordWalkRegion(session)
+	ts.replicateLifecycle(SessionEventStarted, session)
+	if ts.dogProfiles != nil {
+		// Warm the cache so the session's stream frames need not wait for the pets service.
+		go ts.dogProfile(session)
+	}
+	if ts.walkPrediction != nil {
+		// Predictions may call an external endpoint, so they never delay the start.
+		go ts.predictWalk(session)
+	}
+	return session, nil
+}
+
+// EndSession completes an active session, removes it from activeSessions and
+// the current walks view, flushes its buffered location writes, computes its
+// territory coverage, adds the track to the route popularity layer, records
+// the walk on its tenant's walker leaderboard, measures the walk against its
+// prediction, replicates both the completion and the final summary, with its
+// geocoded start and end addresses, the dog's profile and the prediction
+// error, to peer regions, and uploads the walk to connected fitness platforms in the
+// background.
+func (ts *TrackingService) EndSession(sessionID string) error {
+	session, err := ts.getSession(sessionID)
+	if err != nil {
+		return err
+	}
+	if err := session.Complete(); err != nil {
+		return fmt.Errorf("failed to complete session %s: %w", sessionID, err)
+	}
+	if ts.completed != nil {
+		ts.completed.MarkCompleted(sessionID)
+	}
+	ts.activeSessions.Delete(sessionID)
+	ts.flushSessionWrites(sessionID)
+	ts.uploadAcks.Forget(sessionID)
+	if ts.streamKeys != nil {
+		ts.streamKeys.Forget(sessionID)
+	}
+	if ts.smoother != nil {
+		ts.smoother.Forget(sessionID)
+	}
+	if ts.mapMatching != nil {
+		ts.mapMatching.Forget(sessionID)
+	}
+	if ts.lifecycle != nil {
+		ts.lifecycle.Forget(sessionID)
+	}
+	ts.forgetOwnerAlerts(sessionID)
+	ts.forgetGeofenceWatch(sessionID)
+	ts.logger.Info("Tracking session ended", zap.String("sessionID", sessionID))
+	ts.recordStateEvent(session, models.EventSessionCompleted, nil)
+	ts.recordWalkTransition(session, models.CurrentWalkEnded)
+
+	ts.replicateLifecycle(SessionEventCompleted, session)
+
+	var coverage *models.TerritoryCoverage
+	if ts.territoryStore != nil {
+		var covErr error
+		if coverage, covErr = ts.ComputeTerritoryCoverage(session); covErr != nil {
+			ts.logger.Warn("Failed to compute territory coverage",
+				zap.String("sessionID", sessionID),
+				zap.Error(covErr),
+			)
+		}
+	}
+
+	if ts.routeStore != nil {
+		ts.recordRoutePopularity(session)
+	}
+
+	if ts.demandStore != nil {
+		ts.recordWalkStart(session)
+	}
+
+	if ts.leaderboardStore != nil && session.TenantID() != "" {
+		ts.recordLeaderboardWalk(session)
+	}
+
+	if ts.walkPrediction != nil {
+		ts.observeWalkPrediction(session)
+	}
+
+	if ts.replicator != nil {
+		if ts.geocoder != nil || ts.dogProfiles != nil {
+			go ts.recordSummary(session, coverage)
+		} else {
+			ts.recordSummary(session, coverage)
+		}
+	}
+
+	if ts.fitnessStore != nil {
+		go ts.uploadCompletedWalk(session)
+	}
+	return nil
+}
+
+// recordSummary computes the completed session's summary, resolves its start
+// and end addresses when a geocoder is configured and the dog's profile when
+// the pets service is, and replicates it to peer regions. Both call external
+// services, so EndSession runs it in the background when either is enabled.
+func (ts *TrackingService) recordSummary(session *models.TrackingSession, coverage *models.TerritoryCoverage) {
+	stats, err := session.CalculateStatistics()
+	if err != nil {
+		return
+	}
+	stats.Coverage = coverage
+	if ts.geocoder != nil {
+		ts.geocodeSummary(session, stats)
+	}
+	if ts.dogProfiles != nil {
+		stats.Dog = ts.dogProfile(session)
+	}
+	if repErr := ts.replicator.RecordSummary(session, stats); repErr != nil {
+		ts.logger.Warn("Failed to replicate session summary",
+			zap.String("sessionID", session.ID),
+			zap.Error(repErr),
+		)
+	}
+}
+
+// SessionActive reports whether sessionID is an active session.
+func (ts *TrackingService) SessionActive(sessionID string) bool {
+	_, err := ts.getSession(sessionID)
+	return err == nil
+}
+
+// getSession loads an active session from activeSessions with type checking.
+func (ts *TrackingService) getSession(sessionID string) (*models.TrackingSession, error) {
+	val, ok := ts.activeSessions.Load(sessionID)
+	if !ok {
+		return nil, fmt.Errorf("no active session found for sessionID %s", sessionID)
+	}
+	session, sessionOK := val.(*models.TrackingSession)
+	if !sessionOK {
+		return nil, fmt.Errorf("invalid session type for sessionID %s", sessionID)
+	}
+	return session, nil
+}
+
+// replicateLifecycle forwards a lifecycle transition to the replicator, if any.
+// Replication failures never fail the local operation.
+func (ts *TrackingService) replicateLifecycle(eventType string, session *models.TrackingSession) {
+	if ts.replicator == nil {
+		return
+	}
+	if err := ts.replicator.RecordLifecycle(eventType, session); err != nil {
+		ts.logger.Warn("Failed to replicate session lifecycle event",
+			zap.String("sessionID", session.ID),
+			zap.String("eventType", eventType),
+			zap.Error(err),
+		)
 	}
 }
 
@@ -157,14 +642,28 @@ func NewTrackingService(mqttClient MQTTClient, db TimescaleDB, config *Config) *
 //
 // Steps:
 //  1. Validate batch size limits
-//  2. Filter invalid locations
-//  3. Process locations in parallel
-//  4. Update session state atomically (via session.AddLocation)
-//  5. Store batch in database
-//  6. Publish batch updates to MQTT
-//  7. Update metrics in Prometheus
+//  2. Run the batch through the location pipeline, whose built-in stages:
+//     a. Reproject and validate locations in parallel, dropping invalid ones
+//     b. Smooth the valid locations with the session's Kalman filter, if enabled
+//     c. Update session state in batch order (via session.AddLocation) and
+//        store the batch in the database
+//     d. Publish batch updates to MQTT, recording the delivery latency SLO, and
+//        hand the accepted points to live subscribers
+//     Custom stages registered through Pipeline run after the built-in stage
+//     of their phase; map matching, when enabled, is one, snapping the smoothed
+//     locations to the road and path network.
+//  3. Update metrics in Prometheus
 func (ts *TrackingService) ProcessBatchLocations(sessionID string, locations []*models.Location) (BatchResult, error) {
+	return ts.processBatch(sessionID, locations, nil)
+}
+
+// processBatch implements ProcessBatchLocations. When onCommit is non-nil and the
+// returned error is nil, onCommit is called exactly once with the outcome of the
+// database write, which with coalesced writes may happen after processBatch
+// returns.
+func (ts *TrackingService) processBatch(sessionID string, locations []*models.Location, onCommit func(error)) (BatchResult, error) {
 	var result BatchResult
+	ingestedAt := time.Now()
 	defer ts.updateBatchMetrics(&result)
 
 	// Immediately validate the batch size against global maximum.
@@ -195,23 +694,53 @@ func (ts *TrackingService) ProcessBatchLocations(sessionID string, locations []*
 		return result, fmt.Errorf("invalid session type for sessionID %s", sessionID)
 	}
 
-	// Filter invalid locations and concurrently process valid ones.
-	validLocations := make([]*models.Location, 0, len(locations))
+	result.Retransmit = ts.observeSequence(session, locations)
+
+	batch := &PipelineBatch{
+		SessionID:  sessionID,
+		Session:    session,
+		Locations:  locations,
+		Result:     &result,
+		IngestedAt: ingestedAt,
+		onCommit:   onCommit,
+	}
+	if err := ts.runBatch(batch); err != nil {
+		return result, err
+	}
+
+	// Mark the batch result as successful if we stored or queued at least one valid
+	// location, or skipped locations already stored, so retried uploads succeed.
+	if result.StoredCount > 0 || result.QueuedCount > 0 || result.DuplicateCount > 0 {
+		result.Success = true
+	}
+	return result, nil
+}
+
+// validateStage reprojects and validates the batch's points in parallel,
+// keeping the valid ones, then drops the ones already stored.
+func (ts *TrackingService) validateStage(batch *PipelineBatch) error {
+	validLocations := make([]*models.Location, 0, len(batch.Locations))
 
 	// Parallel processing of location validation and optional transformations.
 	var wg sync.WaitGroup
 	mtx := &sync.Mutex{}
-	for _, loc := range locations {
+	for _, loc := range batch.Locations {
 		wg.Add(1)
 		go func(l *models.Location) {
 			defer wg.Done()
-			if err := l.Validate(); err != nil {
-				// Invalid location, increment InvalidCount
+			err := ts.NormalizeLocation(l)
+			if err == nil {
+				err = l.Validate()
+			}
+			if err != nil {
+				// Invalid location, increment InvalidCount and quarantine it
 				mtx.Lock()
-				result.InvalidCount++
+				batch.Result.InvalidCount++
+				ts.rejectPoint(batch, l, invalidReason(err), err)
 				mtx.Unlock()
+				ts.observeQuality(l, PointInvalid)
 				ts.logger.Debug("Discarded invalid location",
-					zap.String("sessionID", sessionID),
+					zap.String("sessionID", batch.SessionID),
 					zap.String("locationID", l.ID),
 					zap.Error(err),
 				)
@@ -223,53 +752,131 @@ func (ts *TrackingService) ProcessBatchLocations(sessionID string, locations []*
 		}(loc)
 	}
 	wg.Wait()
+	batch.Locations, batch.Result.DuplicateCount = ts.dropDuplicates(batch.SessionID, validLocations)
+	return nil
+}
 
-	// Update session state for each valid location in parallel.
-	// Each session.AddLocation call is internally thread-safe via mutex in TrackingSession.
-	var updateWG sync.WaitGroup
-	for _, vl := range validLocations {
-		updateWG.Add(1)
-		go func(vl *models.Location) {
-			defer updateWG.Done()
-			addErr := session.AddLocation(vl)
-			// If an error occurs adding the location to the session,
-			// we log it but continue processing other locations
-			if addErr != nil {
-				ts.logger.Warn("Failed to add location to session",
-					zap.String("sessionID", sessionID),
-					zap.String("locationID", vl.ID),
-					zap.Error(addErr),
-				)
-			}
-		}(vl)
+// smoothStage smooths jitter out of the fixes before they accumulate distance
+// and are stored, so that statistics reflect the walk rather than GPS noise.
+func (ts *TrackingService) smoothStage(batch *PipelineBatch) error {
+	if ts.smoother != nil && ts.FeatureEnabled(FlagKalmanFilter, batch.SessionID) {
+		ts.smoother.Smooth(batch.SessionID, batch.Locations)
 	}
-	updateWG.Wait()
+	return nil
+}
+
+// persistStage adds the batch's points to the session and stores them,
+// spooling them while the database is unavailable.
+func (ts *TrackingService) persistStage(batch *PipelineBatch) error {
+	sessionID, session := batch.SessionID, batch.Session
+	validLocations := batch.Locations
+	result, onCommit := batch.Result, batch.onCommit
 
-	// Store batch in the TimescaleDB. This is a single operation with the entire valid batch.
-	if len(validLocations) > 0 {
-		if err := ts.db.StoreLocationBatch(sessionID, validLocations); err != nil {
-			ts.logger.Error("Failed to store batch in database",
+	// Update session state for each valid location in batch order. AddLocation
+	// serializes on the session mutex anyway, and a deterministic order keeps the
+	// accumulated distance reproducible when the event stream is replayed.
+	accepted := make([]models.Location, 0, len(validLocations))
+	acceptedPoints := make([]*models.Location, 0, len(validLocations))
+	for _, vl := range validLocations {
+		addErr := session.AddLocation(vl)
+		outcome := addOutcome(addErr)
+		ts.observeQuality(vl, outcome)
+		// If an error occurs adding the location to the session,
+		// we log it but continue processing other locations
+		if addErr != nil {
+			ts.logger.Warn("Failed to add location to session",
 				zap.String("sessionID", sessionID),
-				zap.Error(err),
+				zap.String("locationID", vl.ID),
+				zap.Error(addErr),
 			)
-			return result, fmt.Errorf("failed to store batch in database: %v", err)
+			ts.rejectPoint(batch, vl, outcome, addErr)
+			continue
+		}
+		accepted = append(accepted, *vl)
+		acceptedPoints = append(acceptedPoints, vl)
+	}
+	if len(accepted) > 0 {
+		ts.recordStateEvent(session, models.EventLocationsAppended, accepted)
+		if ts.lifecycle != nil {
+			ts.lifecycle.PointsAccepted(sessionID)
 		}
-		result.StoredCount = len(validLocations)
 	}
+	batch.Accepted = accepted
+
+	// Store batch in the TimescaleDB. This is a single operation with the entire valid batch,
+	// or with quarantine enabled the points the session accepted; the rest are quarantined.
+	stored := validLocations
+	if ts.quarantine != nil {
+		stored = acceptedPoints
+	}
+	if len(stored) > 0 {
+		if async, isAsync := ts.db.(asyncLocationWriter); isAsync && onCommit != nil {
+			// A spooled batch is durable, so it commits as if stored.
+			async.StoreLocationBatchAsync(sessionID, stored, func(commitErr error) {
+				if commitErr != nil && ts.spoolBatch(sessionID, stored, commitErr) {
+					commitErr = nil
+				}
+				if commitErr == nil {
+					ts.rememberStored(stored)
+				}
+				onCommit(commitErr)
+			})
+			result.StoredCount = len(stored)
+		} else if err := ts.db.StoreLocationBatch(sessionID, stored); err != nil {
+			if !ts.spoolBatch(sessionID, stored, err) {
+				ts.logger.Error("Failed to store batch in database",
+					zap.String("sessionID", sessionID),
+					zap.Error(err),
+				)
+				return fmt.Errorf("failed to store batch in database: %w", err)
+			}
+			result.QueuedCount = len(stored)
+			ts.rememberStored(stored)
+			if onCommit != nil {
+				onCommit(nil)
+			}
+		} else {
+			result.StoredCount = len(stored)
+			ts.rememberStored(stored)
+			if onCommit != nil {
+				onCommit(nil)
+			}
+		}
+	} else if onCommit != nil {
+		onCommit(nil)
+	}
+	return nil
+}
+
+// publishStage publishes the batch to MQTT, recording the delivery latency
+// SLO, and hands the accepted points to live subscribers.
+func (ts *TrackingService) publishStage(batch *PipelineBatch) error {
+	sessionID, validLocations := batch.SessionID, batch.Locations
 
 	// Publish batch updates to MQTT, if needed. We can publish a simple payload with session updates.
+	// The delay from ingest to publish is measured against the location delivery SLO.
 	if err := ts.publishBatchUpdate(sessionID, validLocations); err != nil {
 		ts.logger.Warn("Failed to publish batch updates to MQTT",
 			zap.String("sessionID", sessionID),
 			zap.Error(err),
 		)
+		if ts.slo != nil {
+			ts.slo.RecordFailure(len(validLocations))
+		}
+	} else if ts.slo != nil {
+		ts.slo.Record(time.Since(batch.IngestedAt), len(validLocations))
 	}
-
-	// Mark the batch result as successful if we stored at least one valid location.
-	if result.StoredCount > 0 {
-		result.Success = true
+	if len(batch.Accepted) > 0 {
+		ts.notifyLocationBatch(sessionID, batch.Accepted)
 	}
-	return result, nil
+	return nil
+}
+
+// ProcessLocationUpdate processes a single location update for a session as a
+// one-point batch. While the database is unavailable and degradation is
+// enabled, the point is spooled and reported in QueuedCount.
+func (ts *TrackingService) ProcessLocationUpdate(sessionID string, loc models.Location) (BatchResult, error) {
+	return ts.processBatch(sessionID, []*models.Location{&loc}, nil)
 }
 
 // MonitorSessionHealth monitors a session's health by inspecting activity timestamps, geofence compliance,
@@ -277,7 +884,10 @@ func (ts *TrackingService) ProcessBatchLocations(sessionID string, locations []*
 //
 // Steps:
 //  1. Check session activity (last update time, existence in activeSessions)
-//  2. Verify geofence compliance if applicable
+//  2. Verify geofence compliance if applicable, and notify owners whose own
+//     alert radius was crossed. With inline geofencing, ingestion has already
+//     checked every point, so the standing breach is reported and only the
+//     geofence groups are polled, for schedules that began since the last point
 //  3. Monitor update frequency
 //  4. Check resource usage (placeholder for extended CPU/memory tracking)
 //  5. Update health metrics in Prometheus
@@ -297,7 +907,7 @@ func (ts *TrackingService) MonitorSessionHealth(sessionID string) (HealthStatus,
 
 	// 1. Check session activity
 	now := time.Now().UTC()
-	lastUpdate := session.LastUpdateTime
+	lastUpdate := session.LastUpdateTime()
 	inactiveDuration := now.Sub(lastUpdate)
 	if inactiveDuration > MaxInactiveTime {
 		ts.logger.Warn("Session timed out due to inactivity",
@@ -308,25 +918,76 @@ func (ts *TrackingService) MonitorSessionHealth(sessionID string) (HealthStatus,
 		return HealthStatusTimeout, nil
 	}
 
-	// 2. Verify geofence compliance if we have a geofence.
+	// 2. Evaluate owners' alert radii alongside the tenant geofence, before its checks
+	// return on a breach; crossing one notifies the owner but leaves the health alone.
+	if history := session.LocationHistory(); len(history) > 0 {
+		ts.evaluateOwnerAlerts(sessionID, session, &history[len(history)-1])
+	}
+
+	// 2a. With inline geofencing, every point was checked and its breaches published as it arrived;
+	// report a standing breach without publishing it again, and skip the polled geofence check below.
+	inline := ts.geofenceRefresh > 0
+	if inline && ts.inGeofenceBreach(sessionID) {
+		ts.updateHealthMetric(sessionID, HealthStatusGeofenceWarning)
+		return HealthStatusGeofenceWarning, nil
+	}
+
+	// 2b. Verify geofence compliance if we have a geofence.
 	// Placeholder approach: find a hypothetical geofence from another structure or function.
 	// This snippet demonstrates usage of ContainsPoint and a "ValidateBoundary" concept.
 	// NOTE: The geofence struct doesn't define ValidateBoundary; we map it to ValidateGeofenceParameters for compliance.
 	var geoVal, geoFound = ts.findGeofenceForSession(sessionID)
-	if geoFound && geoVal.Active {
-		if len(session.LocationHistory) > 0 {
-			lastLoc := &session.LocationHistory[len(session.LocationHistory)-1]
+	if !inline && geoFound && geoVal.Active {
+		if history := session.LocationHistory(); len(history) > 0 {
+			lastLoc := &history[len(history)-1]
 			inside, fenceErr := geoVal.ContainsPoint(lastLoc)
 			if fenceErr != nil {
 				ts.logger.Warn("Error checking geofence compliance", zap.String("sessionID", sessionID), zap.Error(fenceErr))
-			} else if !inside {
-				ts.logger.Warn("Session geofence boundary violation", zap.String("sessionID", sessionID))
+			} else if geoVal.violatedBy(inside) && geoVal.EnforcedAt(lastLoc.Timestamp) {
+				violation := geoVal.Violation(lastLoc)
+				ts.logger.Warn("Session geofence boundary violation",
+					zap.String("sessionID", sessionID),
+					zap.String("eventType", violation.EventType),
+					zap.String("severity", violation.Severity),
+				)
+				ts.publishGeofenceViolations(sessionID, []models.GeofenceViolation{violation})
 				ts.updateHealthMetric(sessionID, HealthStatusGeofenceWarning)
 				return HealthStatusGeofenceWarning, nil
 			}
 		}
 	}
 
+	// 2c. Check scheduled geofence groups; only zones active at the point's timestamp can breach.
+	// With inline geofencing the newest point is re-checked as of now instead, through the session's
+	// inline state, so a group scheduled to start after the walker stopped sending points still breaches.
+	if history := session.LocationHistory(); inline && len(history) > 0 {
+		if ts.pollGeofenceGroups(sessionID, session, &history[len(history)-1], now) {
+			return HealthStatusGeofenceWarning, nil
+		}
+	} else if len(history) > 0 {
+		lastLoc := &history[len(history)-1]
+		breached, groupErr := ts.geofenceGroups.Breaches(session.WalkID(), lastLoc)
+		if groupErr != nil {
+			ts.logger.Warn("Error checking geofence group compliance", zap.String("sessionID", sessionID), zap.Error(groupErr))
+		} else if len(breached) > 0 {
+			violations := make([]models.GeofenceViolation, 0, len(breached))
+			for _, g := range breached {
+				violations = append(violations, g.Violation(lastLoc))
+			}
+			ts.logger.Warn("Session geofence group boundary violation",
+				zap.String("sessionID", sessionID),
+				zap.String("geofenceID", breached[0].ID),
+				zap.String("groupID", breached[0].GroupID),
+				zap.String("eventType", violations[0].EventType),
+				zap.String("severity", violations[0].Severity),
+				zap.Int("breachedCount", len(breached)),
+			)
+			ts.publishGeofenceViolations(sessionID, violations)
+			ts.updateHealthMetric(sessionID, HealthStatusGeofenceWarning)
+			return HealthStatusGeofenceWarning, nil
+		}
+	}
+
 	// 3. Monitor update frequency (here, just a check to see if we've moved in expected intervals).
 	if inactiveDuration > DefaultUpdateInterval {
 		ts.logger.Debug("Session update frequency slower than expected",
@@ -355,7 +1016,15 @@ func (ts *TrackingService) findGeofenceForSession(sessionID string) (*services.G
 	return nil, false
 }
 
-// publishBatchUpdate sends a summary of newly processed locations to an MQTT topic.
+// NormalizeLocation reprojects loc to WGS84 when it declares another coordinate
+// reference system. Unknown CRS identifiers return an error wrapping
+// utils.ErrUnknownCRS.
+func (ts *TrackingService) NormalizeLocation(loc *models.Location) error {
+	return ts.reprojector.Reproject(loc)
+}
+
+// publishBatchUpdate sends a summary of newly processed locations to an MQTT topic,
+// tagged with the session and expiring after the configured location expiry.
 // It logs any error but does not consider it fatal to the entire batch workflow.
 func (ts *TrackingService) publishBatchUpdate(sessionID string, locations []*models.Location) error {
 	if ts.mqttClient == nil {
@@ -365,8 +1034,13 @@ func (ts *TrackingService) publishBatchUpdate(sessionID string, locations []*mod
 	// Construct a minimal payload. In production, consider JSON encoding with a consistent schema.
 	payload := []byte(fmt.Sprintf("Session %s: %d location updates processed", sessionID, len(locations)))
 	topic := fmt.Sprintf("tracking/updates/%s", sessionID)
+	props := &utils.MessageProperties{
+		Expiry:      ts.locationExpiry,
+		ContentType: "text/plain; charset=utf-8",
+		User:        map[string]string{"sessionId": sessionID},
+	}
 
-	if err := ts.mqttClient.Publish(topic, payload); err != nil {
+	if err := ts.mqttClient.PublishWithProperties(utils.MessageClassLocation, topic, payload, props); err != nil {
 		ts.logger.Error("Failed to publish MQTT message",
 			zap.String("sessionID", sessionID),
 			zap.String("topic", topic),
@@ -377,6 +1051,52 @@ func (ts *TrackingService) publishBatchUpdate(sessionID string, locations []*mod
 	return nil
 }
 
+// OnLocationBatch registers fn to be called with the points each processed
+// batch added to its session, in batch order, for streaming them to live
+// subscribers. fn runs on the ingesting goroutine and must not block.
+func (ts *TrackingService) OnLocationBatch(fn func(sessionID string, locations []models.Location)) {
+	ts.locationMu.Lock()
+	defer ts.locationMu.Unlock()
+	ts.locationListeners = append(ts.locationListeners, fn)
+}
+
+// notifyLocationBatch calls the OnLocationBatch listeners.
+func (ts *TrackingService) notifyLocationBatch(sessionID string, locations []models.Location) {
+	ts.locationMu.Lock()
+	listeners := append([]func(string, []models.Location){}, ts.locationListeners...)
+	ts.locationMu.Unlock()
+	for _, fn := range listeners {
+		fn(sessionID, locations)
+	}
+}
+
+// publishGeofenceViolations sends geofence violation events, typed by zone mode,
+// to the session's geofence topic. Failures are logged and otherwise ignored.
+func (ts *TrackingService) publishGeofenceViolations(sessionID string, violations []models.GeofenceViolation) {
+	if ts.mqttClient == nil {
+		return
+	}
+	topic := fmt.Sprintf("tracking/geofence/%s", sessionID)
+	props := &utils.MessageProperties{
+		ContentType: "application/json",
+		User:        map[string]string{"sessionId": sessionID},
+	}
+	for _, violation := range violations {
+		payload, err := json.Marshal(violation)
+		if err != nil {
+			continue
+		}
+		if err := ts.mqttClient.PublishWithProperties(utils.MessageClassAlert, topic, payload, props); err != nil {
+			ts.logger.Error("Failed to publish geofence violation",
+				zap.String("sessionID", sessionID),
+				zap.String("geofenceID", violation.GeofenceID),
+				zap.String("eventType", violation.EventType),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
 // updateBatchMetrics updates internal metrics for batch processing outcomes.
 // This could be hooking into Prometheus counters, histograms, etc.
 func (ts *TrackingService) updateBatchMetrics(result *BatchResult) {
@@ -395,4 +1115,3 @@ func (ts *TrackingService) updateHealthMetric(sessionID string, status HealthSta
 	_ = sessionID
 	_ = status
 }
-```
\ No newline at end of file
@@ -0,0 +1,306 @@
+package services
+
+import (
+	// json for encoding MQTT uploads and acks (standard library)
+	"encoding/json"
+	// errors for sentinel upload errors (standard library)
+	"errors"
+	// fmt for topic formatting and error messages (standard library)
+	"fmt"
+	// sync for guarding per-session sequence state (standard library)
+	"sync"
+
+	// zap for structured logging (go.uber.org/zap v1.24.0)
+	"go.uber.org/zap"
+
+	// models package that includes the Location struct
+	"src/backend/tracking-service/internal/models"
+
+	// utils package for MQTT message classes
+	"src/backend/tracking-service/internal/utils"
+)
+
+// MQTT topics for sequenced device uploads. Devices publish JSON batches to
+// UploadTopicFormat, or LocationBatch protobuf messages of api/tracking.proto
+// to UploadProtobufTopicFormat, and receive cumulative JSON acks on
+// UploadAckTopicFormat.
+const (
+	UploadTopicFormat          = "sessions/%s/uploads"
+	UploadSubscription         = "sessions/+/uploads"
+	UploadProtobufTopicFormat  = "sessions/%s/uploads/pb"
+	UploadProtobufSubscription = "sessions/+/uploads/pb"
+	UploadAckTopicFormat       = "sessions/%s/acks"
+)
+
+// Upload rejections returned by ProcessSequencedUpload.
+var (
+	ErrInvalidUploadSeq    = errors.New("uploadSeq must be at least 1")
+	ErrUploadSessionClosed = errors.New("upload session is not active")
+)
+
+// SequencedUpload is a batch of points tagged with the device's per-session
+// upload sequence number. Sequences start at 1 and increase by one per batch;
+// a device resends a batch with the same sequence until it is acked.
+type SequencedUpload struct {
+	SessionID string             `json:"sessionId"`
+	UploadSeq uint64             `json:"uploadSeq"`
+	Locations []*models.Location `json:"locations"`
+}
+
+// UploadAck reports the highest upload sequence for which it and every earlier
+// batch have been committed to the database. Devices may drop every buffered
+// batch up to AckedSeq. Duplicate is set when the acked upload had already been
+// received and was not processed again. Terminal is set when the session has
+// ended and the device must stop sending. Retransmit lists the point sequence
+// ranges the upload showed missing, which the device should send again, or
+// confirm lost when it no longer holds them.
+type UploadAck struct {
+	SessionID  string            `json:"sessionId"`
+	AckedSeq   uint64            `json:"ackedSeq"`
+	Duplicate  bool              `json:"duplicate,omitempty"`
+	Terminal   bool              `json:"terminal,omitempty"`
+	Retransmit []models.SeqRange `json:"retransmit,omitempty"`
+}
+
+// asyncLocationWriter is implemented by stores that acknowledge writes after
+// returning, such as CoalescingWriter. Acks wait for done rather than for
+// StoreLocationBatch, which only buffers.
+type asyncLocationWriter interface {
+	StoreLocationBatchAsync(sessionID string, locBatch []*models.Location, done func(error))
+}
+
+// uploadSequence is one session's upload sequence state.
+type uploadSequence struct {
+	acked     uint64
+	inFlight  map[uint64]struct{}
+	committed map[uint64]struct{}
+}
+
+// UploadAckTracker maintains each session's cumulative acked upload sequence.
+// Batches may commit out of order; the ack only advances across a contiguous
+// run of committed sequences.
+type UploadAckTracker struct {
+	mu       sync.Mutex
+	sessions map[string]*uploadSequence
+}
+
+// NewUploadAckTracker creates an empty tracker.
+func NewUploadAckTracker() *UploadAckTracker {
+	return &UploadAckTracker{sessions: make(map[string]*uploadSequence)}
+}
+
+// Begin registers seq as in flight. It reports false, with the current ack, when
+// seq has already been committed or is still in flight, so the batch must not
+// be processed again. The first sequence seen for a session is taken as its
+// starting point, so a device reconnecting after a restart is not stalled
+// waiting for sequences the server never saw.
+func (t *UploadAckTracker) Begin(sessionID string, seq uint64) (bool, uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.sessions[sessionID]
+	if !ok {
+		state = &uploadSequence{
+			acked:     seq - 1,
+			inFlight:  make(map[uint64]struct{}),
+			committed: make(map[uint64]struct{}),
+		}
+		t.sessions[sessionID] = state
+	}
+	if seq <= state.acked {
+		return false, state.acked
+	}
+	if _, busy := state.inFlight[seq]; busy {
+		return false, state.acked
+	}
+	if _, done := state.committed[seq]; done {
+		return false, state.acked
+	}
+	state.inFlight[seq] = struct{}{}
+	return true, state.acked
+}
+
+// Commit marks seq as durably stored and returns the session's ack and whether
+// it advanced.
+func (t *UploadAckTracker) Commit(sessionID string, seq uint64) (uint64, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.sessions[sessionID]
+	if !ok {
+		return 0, false
+	}
+	delete(state.inFlight, seq)
+	state.committed[seq] = struct{}{}
+
+	before := state.acked
+	for {
+		if _, done := state.committed[state.acked+1]; !done {
+			break
+		}
+		delete(state.committed, state.acked+1)
+		state.acked++
+	}
+	return state.acked, state.acked != before
+}
+
+// Abort releases an in-flight seq whose batch failed, so a resend is processed.
+func (t *UploadAckTracker) Abort(sessionID string, seq uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if state, ok := t.sessions[sessionID]; ok {
+		delete(state.inFlight, seq)
+	}
+}
+
+// Acked returns the session's current cumulative ack.
+func (t *UploadAckTracker) Acked(sessionID string) uint64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if state, ok := t.sessions[sessionID]; ok {
+		return state.acked
+	}
+	return 0
+}
+
+// Forget drops a session's sequence state once it has ended.
+func (t *UploadAckTracker) Forget(sessionID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.sessions, sessionID)
+}
+
+// AckedUploadSeq returns the session's cumulative acked upload sequence, for
+// a device resuming its uploads after a restart.
+func (ts *TrackingService) AckedUploadSeq(sessionID string) uint64 {
+	return ts.uploadAcks.Acked(sessionID)
+}
+
+// OnUploadAck registers fn to be called whenever a session's cumulative ack
+// advances, for pushing acks to devices over a live connection.
+func (ts *TrackingService) OnUploadAck(fn func(UploadAck)) {
+	ts.ackMu.Lock()
+	defer ts.ackMu.Unlock()
+	ts.ackListeners = append(ts.ackListeners, fn)
+}
+
+// ProcessSequencedUpload processes a device batch at most once per sequence
+// number. The returned ack reflects commits so far; when writes are coalesced
+// the batch itself is acked later, once its points reach the database, through
+// OnUploadAck listeners and the session's MQTT ack topic.
+//
+// Steps:
+//  1. Reject missing sequence numbers and inactive sessions, and skip
+//     duplicates with the current ack
+//  2. Process the batch, advancing the ack only after the database commit
+//  3. Release the sequence for a resend if processing fails
+func (ts *TrackingService) ProcessSequencedUpload(upload SequencedUpload) (UploadAck, BatchResult, error) {
+	ack := UploadAck{SessionID: upload.SessionID}
+	if upload.UploadSeq == 0 {
+		return ack, BatchResult{}, ErrInvalidUploadSeq
+	}
+	if _, err := ts.getSession(upload.SessionID); err != nil {
+		return ack, BatchResult{}, fmt.Errorf("%w: %v", ErrUploadSessionClosed, err)
+	}
+
+	fresh, acked := ts.uploadAcks.Begin(upload.SessionID, upload.UploadSeq)
+	if !fresh {
+		ack.AckedSeq = acked
+		ack.Duplicate = true
+		return ack, BatchResult{}, nil
+	}
+
+	result, err := ts.processBatch(upload.SessionID, upload.Locations, func(commitErr error) {
+		if commitErr != nil {
+			ts.uploadAcks.Abort(upload.SessionID, upload.UploadSeq)
+			ts.logger.Warn("Upload batch failed to commit; awaiting resend",
+				zap.String("sessionID", upload.SessionID),
+				zap.Uint64("uploadSeq", upload.UploadSeq),
+				zap.Error(commitErr),
+			)
+			return
+		}
+		if acked, advanced := ts.uploadAcks.Commit(upload.SessionID, upload.UploadSeq); advanced {
+			ts.publishUploadAck(UploadAck{SessionID: upload.SessionID, AckedSeq: acked})
+		}
+	})
+	if err != nil {
+		ts.uploadAcks.Abort(upload.SessionID, upload.UploadSeq)
+		return ack, result, err
+	}
+
+	ack.AckedSeq = ts.uploadAcks.Acked(upload.SessionID)
+	ack.Retransmit = result.Retransmit
+	return ack, result, nil
+}
+
+// StartUploadIngress subscribes to sequenced device uploads over MQTT, in
+// JSON and in protobuf. Each upload is processed like an HTTP or WebSocket
+// upload; duplicates and uploads showing missing points are answered with the
+// current ack, and uploads for ended sessions with a terminal ack, on the
+// session's ack topic.
+func (ts *TrackingService) StartUploadIngress(bus MessageBus) error {
+	err := bus.Subscribe(UploadSubscription, func(payload []byte) {
+		var upload SequencedUpload
+		if err := json.Unmarshal(payload, &upload); err != nil || upload.SessionID == "" {
+			ts.logger.Warn("Discarding malformed device upload", zap.Error(err))
+			return
+		}
+		ts.ingestUpload(upload)
+	})
+	if err != nil {
+		return err
+	}
+	return bus.Subscribe(UploadProtobufSubscription, func(payload []byte) {
+		batch, err := utils.UnmarshalLocationBatchProto(payload)
+		if err != nil || batch.SessionID == "" {
+			ts.logger.Warn("Discarding malformed protobuf device upload", zap.Error(err))
+			return
+		}
+		ts.ingestUpload(SequencedUpload{SessionID: batch.SessionID, UploadSeq: batch.UploadSeq, Locations: batch.Locations})
+	})
+}
+
+// ingestUpload processes an upload received over MQTT and publishes the ack
+// the device needs, if any.
+func (ts *TrackingService) ingestUpload(upload SequencedUpload) {
+	if err := ts.CheckIngress(upload.SessionID, IngressMQTT); err != nil {
+		ts.publishUploadAck(TerminalAck(upload.SessionID))
+		return
+	}
+	ack, _, err := ts.ProcessSequencedUpload(upload)
+	if err != nil {
+		ts.logger.Warn("Rejected device upload",
+			zap.String("sessionID", upload.SessionID),
+			zap.Uint64("uploadSeq", upload.UploadSeq),
+			zap.Error(err),
+		)
+		return
+	}
+	if ack.Duplicate || len(ack.Retransmit) > 0 {
+		ts.publishUploadAck(ack)
+	}
+}
+
+// publishUploadAck delivers an ack on the session's MQTT ack topic and to every
+// OnUploadAck listener.
+func (ts *TrackingService) publishUploadAck(ack UploadAck) {
+	payload, err := json.Marshal(ack)
+	if err == nil && ts.mqttClient != nil {
+		err = ts.mqttClient.Publish(utils.MessageClassControl, fmt.Sprintf(UploadAckTopicFormat, ack.SessionID), payload)
+	}
+	if err != nil {
+		ts.logger.Warn("Failed to publish upload ack",
+			zap.String("sessionID", ack.SessionID),
+			zap.Uint64("ackedSeq", ack.AckedSeq),
+			zap.Error(err),
+		)
+	}
+
+	ts.ackMu.Lock()
+	listeners := append([]func(UploadAck){}, ts.ackListeners...)
+	ts.ackMu.Unlock()
+	for _, fn := range listeners {
+		fn(ack)
+	}
+}
@@ -0,0 +1,118 @@
+package utils
+
+import (
+	// context go1.21 for request-scoped cancellation of ban lookups
+	"context"
+
+	// sync go1.21 for guarding the in-memory ban state
+	"sync"
+
+	// time go1.21 for ban and failure window expiry
+	"time"
+
+	// Internal import for the shared Redis settings
+	"src/backend/tracking-service/internal/config"
+)
+
+// ---------------------------------------------------------------------
+// BanStore Interface
+// ---------------------------------------------------------------------
+// BanStore tracks authentication failures and temporary bans per client IP.
+// Implementations shared across instances (see RedisBanStore) make a ban issued
+// by one replica effective on all of them.
+type BanStore interface {
+	// RecordFailure counts an authentication failure for ip and returns the number
+	// of failures recorded within window, including this one.
+	RecordFailure(ctx context.Context, ip string, window time.Duration) (int64, error)
+
+	// Ban bans ip for duration and clears its failure count.
+	Ban(ctx context.Context, ip string, duration time.Duration) error
+
+	// BannedFor returns how long ip remains banned, or zero if it is not banned.
+	BannedFor(ctx context.Context, ip string) (time.Duration, error)
+}
+
+// ---------------------------------------------------------------------
+// Factory Function: NewBanStore
+// ---------------------------------------------------------------------
+// NewBanStore returns a Redis-backed store sharing the stream buffer's Redis when
+// cfg.RedisAddr is set and an in-process MemoryBanStore otherwise. The in-process
+// store only bans a client on the instance that observed its failures.
+func NewBanStore(cfg *config.StreamConfig) (BanStore, error) {
+	if cfg.RedisAddr != "" {
+		redisStore, err := NewRedisBanStore(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return redisStore, nil
+	}
+	return NewMemoryBanStore(), nil
+}
+
+// ---------------------------------------------------------------------
+// MemoryBanStore Struct
+// ---------------------------------------------------------------------
+// MemoryBanStore is an in-process BanStore for single-instance deployments.
+type MemoryBanStore struct {
+	mu       sync.Mutex
+	failures map[string][]time.Time
+	bans     map[string]time.Time
+}
+
+// NewMemoryBanStore creates an empty in-process ban store.
+func NewMemoryBanStore() *MemoryBanStore {
+	return &MemoryBanStore{
+		failures: make(map[string][]time.Time),
+		bans:     make(map[string]time.Time),
+	}
+}
+
+// RecordFailure implements BanStore. Failures older than window are dropped.
+func (s *MemoryBanStore) RecordFailure(_ context.Context, ip string, window time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	recent := s.failures[ip][:0]
+	for _, at := range s.failures[ip] {
+		if now.Sub(at) < window {
+			recent = append(recent, at)
+		}
+	}
+	recent = append(recent, now)
+	s.failures[ip] = recent
+	return int64(len(recent)), nil
+}
+
+// Ban implements BanStore. Expired bans are evicted on each call.
+func (s *MemoryBanStore) Ban(_ context.Context, ip string, duration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for bannedIP, until := range s.bans {
+		if !now.Before(until) {
+			delete(s.bans, bannedIP)
+		}
+	}
+	s.bans[ip] = now.Add(duration)
+	delete(s.failures, ip)
+	return nil
+}
+
+// BannedFor implements BanStore.
+func (s *MemoryBanStore) BannedFor(_ context.Context, ip string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	until, ok := s.bans[ip]
+	if !ok {
+		return 0, nil
+	}
+	remaining := time.Until(until)
+	if remaining <= 0 {
+		delete(s.bans, ip)
+		return 0, nil
+	}
+	return remaining, nil
+}
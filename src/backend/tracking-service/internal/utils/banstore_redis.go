@@ -0,0 +1,96 @@
+package utils
+
+import (
+	// context go1.21 for request-scoped cancellation of Redis calls
+	"context"
+
+	// fmt go1.21 for key formatting and error wrapping
+	"fmt"
+
+	// time go1.21 for ban and failure window expiry
+	"time"
+
+	// go-redis v9.2.1 for ban state shared across replicas
+	"github.com/redis/go-redis/v9"
+
+	// Internal import for the shared Redis settings
+	"src/backend/tracking-service/internal/config"
+)
+
+// banKeyFormat namespaces per-IP abuse keys.
+const banKeyFormat = "tracking:abuse:%s:%s"
+
+// recordFailureScript atomically increments an IP's failure counter, starting
+// its expiry window on the first failure so the count resets once it lapses.
+var recordFailureScript = redis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if count == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// ---------------------------------------------------------------------
+// RedisBanStore Struct
+// ---------------------------------------------------------------------
+// RedisBanStore is a BanStore shared by every instance through Redis, so a
+// client banned by one replica cannot simply reconnect to another.
+type RedisBanStore struct {
+	client *redis.Client
+}
+
+// NewRedisBanStore connects to the Redis server in cfg and verifies it is
+// reachable.
+func NewRedisBanStore(cfg *config.StreamConfig) (*RedisBanStore, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to reach ban store Redis at %s: %w", cfg.RedisAddr, err)
+	}
+	return &RedisBanStore{client: client}, nil
+}
+
+// RecordFailure implements BanStore. The window is fixed from the first failure.
+func (s *RedisBanStore) RecordFailure(ctx context.Context, ip string, window time.Duration) (int64, error) {
+	key := fmt.Sprintf(banKeyFormat, "failures", ip)
+	count, err := recordFailureScript.Run(ctx, s.client, []string{key}, window.Milliseconds()).Int64()
+	if err != nil {
+		return 0, fmt.Errorf("failed to record auth failure for %s: %w", ip, err)
+	}
+	return count, nil
+}
+
+// Ban implements BanStore.
+func (s *RedisBanStore) Ban(ctx context.Context, ip string, duration time.Duration) error {
+	pipe := s.client.TxPipeline()
+	pipe.Set(ctx, fmt.Sprintf(banKeyFormat, "ban", ip), 1, duration)
+	pipe.Del(ctx, fmt.Sprintf(banKeyFormat, "failures", ip))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to ban %s: %w", ip, err)
+	}
+	return nil
+}
+
+// BannedFor implements BanStore.
+func (s *RedisBanStore) BannedFor(ctx context.Context, ip string) (time.Duration, error) {
+	ttl, err := s.client.PTTL(ctx, fmt.Sprintf(banKeyFormat, "ban", ip)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check ban for %s: %w", ip, err)
+	}
+	// PTTL reports -2 for a missing key and -1 for a key without expiry.
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// Close releases the Redis connection pool.
+func (s *RedisBanStore) Close() error {
+	return s.client.Close()
+}
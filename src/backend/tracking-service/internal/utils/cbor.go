@@ -0,0 +1,401 @@
+package utils
+
+import (
+	// bytes provides the buffer CBOR items are encoded into and JSON decoding input (go1.21)
+	"bytes"
+	// binary provides big-endian argument and float encoding (go1.21)
+	"encoding/binary"
+	// json provides the JSON side of the transcoding (go1.21)
+	"encoding/json"
+	// errors provides decoding validation failures (go1.21)
+	"errors"
+	// fmt provides wrapped decoding errors (go1.21)
+	"fmt"
+	// math provides float conversions and the half-precision decoding (go1.21)
+	"math"
+	// sort provides canonical map key ordering (go1.21)
+	"sort"
+	// strconv provides integer parsing of JSON numbers (go1.21)
+	"strconv"
+	// utf8 provides text string validation (go1.21)
+	"unicode/utf8"
+)
+
+// CBORContentType is the MIME type of CBOR payloads.
+const CBORContentType = "application/cbor"
+
+// maxCBORDepth bounds the nesting of arrays and maps accepted by DecodeCBOR,
+// so hostile payloads cannot exhaust the stack.
+const maxCBORDepth = 32
+
+// CBOR major types (RFC 8949 section 3.1).
+const (
+	cborUint   byte = 0
+	cborNegint byte = 1
+	cborBytes  byte = 2
+	cborText   byte = 3
+	cborArray  byte = 4
+	cborMap    byte = 5
+	cborTag    byte = 6
+	cborSimple byte = 7
+)
+
+// CBOR simple values and float encodings of major type 7.
+const (
+	cborFalse     byte = 20
+	cborTrue      byte = 21
+	cborNull      byte = 22
+	cborUndefined byte = 23
+	cborFloat16   byte = 25
+	cborFloat32   byte = 26
+	cborFloat64   byte = 27
+)
+
+// Errors returned for payloads DecodeCBOR rejects.
+var (
+	ErrCBORTruncated  = errors.New("cbor: unexpected end of data")
+	ErrCBORIndefinite = errors.New("cbor: indefinite-length items are not supported")
+	ErrCBORTooDeep    = errors.New("cbor: items nested too deeply")
+	ErrCBORTrailing   = errors.New("cbor: trailing data after item")
+)
+
+// JSONToCBOR transcodes a JSON document to CBOR. Integers are encoded in the
+// shortest integer form, other numbers as single precision floats when that
+// is lossless and double precision otherwise, and map keys in sorted order.
+func JSONToCBOR(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errors.New("json: trailing data after document")
+	}
+	var buf bytes.Buffer
+	if err := EncodeCBOR(&buf, doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// CBORToJSON transcodes a single CBOR item to a JSON document.
+func CBORToJSON(data []byte) ([]byte, error) {
+	doc, err := DecodeCBOR(data)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// EncodeCBOR appends the CBOR encoding of v to buf. v must be built from the
+// values produced by decoding JSON: nil, bool, string, json.Number, float64,
+// []interface{} and map[string]interface{}, plus the integer types, []byte
+// and the floats DecodeCBOR produces.
+func EncodeCBOR(buf *bytes.Buffer, v interface{}) error {
+	switch x := v.(type) {
+	case nil:
+		buf.WriteByte(cborSimple<<5 | cborNull)
+	case bool:
+		if x {
+			buf.WriteByte(cborSimple<<5 | cborTrue)
+		} else {
+			buf.WriteByte(cborSimple<<5 | cborFalse)
+		}
+	case string:
+		writeCBORHead(buf, cborText, uint64(len(x)))
+		buf.WriteString(x)
+	case []byte:
+		writeCBORHead(buf, cborBytes, uint64(len(x)))
+		buf.Write(x)
+	case json.Number:
+		return encodeCBORNumber(buf, x)
+	case int:
+		encodeCBORInt(buf, int64(x))
+	case int64:
+		encodeCBORInt(buf, x)
+	case uint64:
+		writeCBORHead(buf, cborUint, x)
+	case float32:
+		encodeCBORFloat(buf, float64(x))
+	case float64:
+		encodeCBORFloat(buf, x)
+	case []interface{}:
+		writeCBORHead(buf, cborArray, uint64(len(x)))
+		for _, item := range x {
+			if err := EncodeCBOR(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		writeCBORHead(buf, cborMap, uint64(len(keys)))
+		for _, k := range keys {
+			writeCBORHead(buf, cborText, uint64(len(k)))
+			buf.WriteString(k)
+			if err := EncodeCBOR(buf, x[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cbor: cannot encode %T", v)
+	}
+	return nil
+}
+
+// encodeCBORNumber encodes a JSON number as an integer when it is one that
+// fits 64 bits, and as a float otherwise.
+func encodeCBORNumber(buf *bytes.Buffer, n json.Number) error {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		encodeCBORInt(buf, i)
+		return nil
+	}
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		writeCBORHead(buf, cborUint, u)
+		return nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return fmt.Errorf("cbor: invalid number %q: %w", n, err)
+	}
+	encodeCBORFloat(buf, f)
+	return nil
+}
+
+// encodeCBORInt encodes i as an unsigned or negative integer.
+func encodeCBORInt(buf *bytes.Buffer, i int64) {
+	if i >= 0 {
+		writeCBORHead(buf, cborUint, uint64(i))
+		return
+	}
+	writeCBORHead(buf, cborNegint, uint64(-1-i))
+}
+
+// encodeCBORFloat encodes f in single precision when that is lossless, and in
+// double precision otherwise.
+func encodeCBORFloat(buf *bytes.Buffer, f float64) {
+	if f32 := float32(f); float64(f32) == f {
+		buf.WriteByte(cborSimple<<5 | cborFloat32)
+		_ = binary.Write(buf, binary.BigEndian, math.Float32bits(f32))
+		return
+	}
+	buf.WriteByte(cborSimple<<5 | cborFloat64)
+	_ = binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+}
+
+// writeCBORHead writes the initial byte of an item of the given major type and
+// its argument in the shortest form.
+func writeCBORHead(buf *bytes.Buffer, major byte, arg uint64) {
+	switch {
+	case arg < 24:
+		buf.WriteByte(major<<5 | byte(arg))
+	case arg <= math.MaxUint8:
+		buf.WriteByte(major<<5 | 24)
+		buf.WriteByte(byte(arg))
+	case arg <= math.MaxUint16:
+		buf.WriteByte(major<<5 | 25)
+		_ = binary.Write(buf, binary.BigEndian, uint16(arg))
+	case arg <= math.MaxUint32:
+		buf.WriteByte(major<<5 | 26)
+		_ = binary.Write(buf, binary.BigEndian, uint32(arg))
+	default:
+		buf.WriteByte(major<<5 | 27)
+		_ = binary.Write(buf, binary.BigEndian, arg)
+	}
+}
+
+// DecodeCBOR decodes a single CBOR item into the values json.Marshal accepts:
+// nil, bool, int64, uint64, float64, string, []byte, []interface{} and
+// map[string]interface{}. Tags are skipped in favour of their content, and
+// undefined decodes as nil. Indefinite-length items, maps with non-text keys,
+// invalid UTF-8 text and data after the item are rejected.
+func DecodeCBOR(data []byte) (interface{}, error) {
+	d := cborDecoder{data: data}
+	v, err := d.item(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, ErrCBORTrailing
+	}
+	return v, nil
+}
+
+// cborDecoder reads CBOR items from data, starting at pos.
+type cborDecoder struct {
+	data []byte
+	pos  int
+}
+
+// item decodes the item at the current position, nested depth levels deep.
+func (d *cborDecoder) item(depth int) (interface{}, error) {
+	if depth > maxCBORDepth {
+		return nil, ErrCBORTooDeep
+	}
+	major, info, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case cborUint:
+		return arg, nil
+	case cborNegint:
+		if arg > math.MaxInt64 {
+			return nil, errors.New("cbor: negative integer overflows int64")
+		}
+		return -1 - int64(arg), nil
+	case cborBytes:
+		raw, err := d.take(arg)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), raw...), nil
+	case cborText:
+		raw, err := d.take(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !utf8.Valid(raw) {
+			return nil, errors.New("cbor: text string is not valid UTF-8")
+		}
+		return string(raw), nil
+	case cborArray:
+		// Every element takes at least a byte, bounding the allocation.
+		if arg > uint64(len(d.data)-d.pos) {
+			return nil, ErrCBORTruncated
+		}
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			item, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case cborMap:
+		if arg > uint64(len(d.data)-d.pos)/2 {
+			return nil, ErrCBORTruncated
+		}
+		entries := make(map[string]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			key, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("cbor: map key of type %T is not a text string", key)
+			}
+			value, err := d.item(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			entries[name] = value
+		}
+		return entries, nil
+	case cborTag:
+		return d.item(depth + 1)
+	}
+
+	// Major type 7: simple values and floats, whose bits are the argument.
+	switch info {
+	case cborFalse:
+		return false, nil
+	case cborTrue:
+		return true, nil
+	case cborNull, cborUndefined:
+		return nil, nil
+	case cborFloat16:
+		return finiteCBORFloat(halfToFloat64(uint16(arg)))
+	case cborFloat32:
+		return finiteCBORFloat(float64(math.Float32frombits(uint32(arg))))
+	case cborFloat64:
+		return finiteCBORFloat(math.Float64frombits(arg))
+	}
+	return nil, fmt.Errorf("cbor: unsupported simple value %d", info)
+}
+
+// head reads an item's initial byte and argument, returning its major type,
+// additional information and the argument's value.
+func (d *cborDecoder) head() (byte, byte, uint64, error) {
+	if d.pos >= len(d.data) {
+		return 0, 0, 0, ErrCBORTruncated
+	}
+	initial := d.data[d.pos]
+	d.pos++
+	major, info := initial>>5, initial&0x1f
+
+	var size uint64
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	case info == 31:
+		return 0, 0, 0, ErrCBORIndefinite
+	default:
+		return 0, 0, 0, fmt.Errorf("cbor: reserved additional information %d", info)
+	}
+	raw, err := d.take(size)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	var arg uint64
+	for _, b := range raw {
+		arg = arg<<8 | uint64(b)
+	}
+	return major, info, arg, nil
+}
+
+// take returns the next n bytes and advances past them.
+func (d *cborDecoder) take(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, ErrCBORTruncated
+	}
+	raw := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return raw, nil
+}
+
+// finiteCBORFloat rejects NaN and infinities, which JSON cannot represent.
+func finiteCBORFloat(f float64) (interface{}, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, errors.New("cbor: NaN and infinite floats are not supported")
+	}
+	return f, nil
+}
+
+// halfToFloat64 converts an IEEE 754 half precision float (RFC 8949 appendix D).
+func halfToFloat64(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			f = math.Inf(1)
+		} else {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -f
+	}
+	return f
+}
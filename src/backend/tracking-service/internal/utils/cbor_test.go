@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// cborSeedDocuments are JSON documents shaped like the frames trackers send,
+// seeding the CBOR fuzz corpus through their CBOR encodings.
+var cborSeedDocuments = []string{
+	`{"type":"heartbeat","seq":10}`,
+	`{"type":"locationUpdate","seq":7,"location":{"id":"8f14e45f-ceea-4e7a-9f3b-6b1f2a1d7c3e","walkId":"walk-1","latitude":40.7128,"longitude":-74.006,"accuracy":4.5,"timestamp":"2024-05-01T12:00:00Z"}}`,
+	`{"type":"gap","seq":11,"range":{"from":40,"to":42}}`,
+	`[1,-1,18446744073709551615,-9223372036854775808,0.1,1e300,true,false,null,"",{}]`,
+}
+
+// FuzzUnmarshalCBOR checks that decoding arbitrary CBOR never panics, and that
+// whatever decodes comes back unchanged after encoding it to CBOR again.
+func FuzzUnmarshalCBOR(f *testing.F) {
+	for _, doc := range cborSeedDocuments {
+		data, err := JSONToCBOR([]byte(doc))
+		if err != nil {
+			f.Fatalf("JSONToCBOR(%s): %v", doc, err)
+		}
+		f.Add(data)
+	}
+	f.Add([]byte{0x9f, 0x01, 0xff})       // indefinite-length array
+	f.Add([]byte{0xa1, 0x01, 0x02})       // map with an integer key
+	f.Add([]byte{0xf9, 0x7c, 0x00})       // half-precision infinity
+	f.Add([]byte{0x7b, 0xff, 0xff, 0xff}) // truncated text length
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		doc, err := CBORToJSON(data)
+		if err != nil {
+			return
+		}
+		if !json.Valid(doc) {
+			t.Fatalf("CBORToJSON(%x) = %s, not valid JSON", data, doc)
+		}
+		encoded, err := JSONToCBOR(doc)
+		if err != nil {
+			t.Fatalf("JSONToCBOR(%s): %v", doc, err)
+		}
+		again, err := CBORToJSON(encoded)
+		if err != nil {
+			t.Fatalf("CBORToJSON(%x) of re-encoded %s: %v", encoded, doc, err)
+		}
+		if !sameJSON(t, doc, again) {
+			t.Fatalf("round trip changed %s into %s", doc, again)
+		}
+	})
+}
+
+// sameJSON reports whether two JSON documents hold the same values.
+func sameJSON(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var va, vb interface{}
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatalf("unmarshal %s: %v", a, err)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatalf("unmarshal %s: %v", b, err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
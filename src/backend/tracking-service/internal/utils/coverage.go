@@ -0,0 +1,198 @@
+package utils
+
+import (
+	// math provides trigonometric and rounding functions (go1.21)
+	"math"
+	// sort provides ordering for the monotone chain hull (go1.21)
+	"sort"
+	// strconv provides compact cell key formatting (go1.21)
+	"strconv"
+
+	// models provides the Location struct used for GPS coordinate representations
+	"src/backend/tracking-service/internal/models"
+)
+
+// TerritoryCellSizeMeters is the edge length of the global grid cells used to
+// compare the territory explored by different walks.
+const TerritoryCellSizeMeters float64 = 25.0
+
+// metersPerDegreeLatitude is the approximate length of one degree of latitude.
+const metersPerDegreeLatitude float64 = 111320.0
+
+// planarPoint is a location projected onto a local tangent plane, in meters.
+type planarPoint struct {
+	x float64
+	y float64
+}
+
+// ConvexHullArea computes the area, in square meters, of the convex hull enclosing
+// all provided points. It projects the points onto a local equirectangular plane
+// centered on their mean latitude, which is accurate for walk-sized extents.
+//
+// Steps:
+//  1. Require at least three points; fewer enclose no area.
+//  2. Project latitude/longitude to planar meters around the mean latitude.
+//  3. Build the hull with Andrew's monotone chain algorithm.
+//  4. Compute the hull area with the shoelace formula, rounded to two decimals.
+func ConvexHullArea(points []models.Location) float64 {
+	if len(points) < 3 {
+		return 0.0
+	}
+
+	var latSum float64
+	for _, p := range points {
+		latSum += p.Latitude
+	}
+	cosLat := math.Cos((latSum / float64(len(points))) * math.Pi / 180.0)
+
+	projected := make([]planarPoint, 0, len(points))
+	for _, p := range points {
+		projected = append(projected, planarPoint{
+			x: p.Longitude * metersPerDegreeLatitude * cosLat,
+			y: p.Latitude * metersPerDegreeLatitude,
+		})
+	}
+
+	hull := convexHull(projected)
+	if len(hull) < 3 {
+		return 0.0
+	}
+
+	var twiceArea float64
+	for i := range hull {
+		j := (i + 1) % len(hull)
+		twiceArea += hull[i].x*hull[j].y - hull[j].x*hull[i].y
+	}
+	return math.Round(math.Abs(twiceArea)/2.0*100) / 100
+}
+
+// TerritoryCells returns the distinct global grid cells touched by a walk track.
+// Consecutive points are interpolated so sparse updates still mark the cells
+// crossed in between. Cell keys are stable across walks, which lets explored
+// territory be compared with a dog's history.
+func TerritoryCells(points []models.Location) []string {
+	seen := make(map[string]struct{})
+	var cells []string
+	mark := func(lat, lon float64) {
+		key := territoryCellKey(lat, lon)
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			cells = append(cells, key)
+		}
+	}
+
+	for i, p := range points {
+		if i == 0 {
+			mark(p.Latitude, p.Longitude)
+			continue
+		}
+		prev := points[i-1]
+		segment := distanceBetweenMeters(prev.Latitude, prev.Longitude, p.Latitude, p.Longitude)
+		steps := int(math.Ceil(segment / (TerritoryCellSizeMeters / 2)))
+		for s := 1; s <= steps; s++ {
+			f := float64(s) / float64(steps)
+			mark(prev.Latitude+(p.Latitude-prev.Latitude)*f, prev.Longitude+(p.Longitude-prev.Longitude)*f)
+		}
+		if steps == 0 {
+			mark(p.Latitude, p.Longitude)
+		}
+	}
+	return cells
+}
+
+// NewTerritoryPercent returns the share (0-100) of walkCells that do not appear in
+// the historical cell set, rounded to two decimals. A walk with no cells has
+// explored no new territory.
+func NewTerritoryPercent(walkCells []string, historical map[string]struct{}) float64 {
+	if len(walkCells) == 0 {
+		return 0.0
+	}
+	newCells := 0
+	for _, c := range walkCells {
+		if _, ok := historical[c]; !ok {
+			newCells++
+		}
+	}
+	return math.Round(float64(newCells)/float64(len(walkCells))*100*100) / 100
+}
+
+// territoryCellKey maps a coordinate onto its global grid cell. Longitude cell
+// width is derived from the latitude band so cells stay roughly square.
+func territoryCellKey(lat, lon float64) string {
+	return cellKey(territoryCellIndex(lat, lon))
+}
+
+// cellKey formats a grid cell's row and column as its key.
+func cellKey(latIdx, lonIdx int64) string {
+	return strconv.FormatInt(latIdx, 10) + ":" + strconv.FormatInt(lonIdx, 10)
+}
+
+// territoryCellIndex returns the row and column of the grid cell containing a coordinate.
+func territoryCellIndex(lat, lon float64) (int64, int64) {
+	latStep := TerritoryCellSizeMeters / metersPerDegreeLatitude
+	latIdx := int64(math.Floor(lat / latStep))
+	lonIdx := int64(math.Floor(lon / territoryLonStep(latIdx)))
+	return latIdx, lonIdx
+}
+
+// territoryCellCenter returns the coordinate at the center of a grid cell.
+func territoryCellCenter(latIdx, lonIdx int64) (float64, float64) {
+	latStep := TerritoryCellSizeMeters / metersPerDegreeLatitude
+	return (float64(latIdx) + 0.5) * latStep, (float64(lonIdx) + 0.5) * territoryLonStep(latIdx)
+}
+
+// territoryLonStep returns the longitude width of cells in a latitude band.
+func territoryLonStep(latIdx int64) float64 {
+	latStep := TerritoryCellSizeMeters / metersPerDegreeLatitude
+	bandCenter := (float64(latIdx) + 0.5) * latStep
+	cosBand := math.Cos(bandCenter * math.Pi / 180.0)
+	if cosBand < 1e-6 {
+		cosBand = 1e-6
+	}
+	return TerritoryCellSizeMeters / (metersPerDegreeLatitude * cosBand)
+}
+
+// convexHull returns the hull of the planar points in counter-clockwise order.
+func convexHull(pts []planarPoint) []planarPoint {
+	sorted := make([]planarPoint, len(pts))
+	copy(sorted, pts)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].x != sorted[j].x {
+			return sorted[i].x < sorted[j].x
+		}
+		return sorted[i].y < sorted[j].y
+	})
+
+	cross := func(o, a, b planarPoint) float64 {
+		return (a.x-o.x)*(b.y-o.y) - (a.y-o.y)*(b.x-o.x)
+	}
+
+	hull := make([]planarPoint, 0, 2*len(sorted))
+	for _, p := range sorted {
+		for len(hull) >= 2 && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	lower := len(hull) + 1
+	for i := len(sorted) - 2; i >= 0; i-- {
+		p := sorted[i]
+		for len(hull) >= lower && cross(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+			hull = hull[:len(hull)-1]
+		}
+		hull = append(hull, p)
+	}
+	return hull[:len(hull)-1]
+}
+
+// distanceBetweenMeters is an unvalidated haversine distance in meters, used for
+// interpolation where inputs have already been validated.
+func distanceBetweenMeters(lat1, lon1, lat2, lon2 float64) float64 {
+	rlat1 := lat1 * math.Pi / 180.0
+	rlat2 := lat2 * math.Pi / 180.0
+	dlat := rlat2 - rlat1
+	dlon := (lon2 - lon1) * math.Pi / 180.0
+	a := math.Sin(dlat/2)*math.Sin(dlat/2) +
+		math.Cos(rlat1)*math.Cos(rlat2)*math.Sin(dlon/2)*math.Sin(dlon/2)
+	return 2 * EarthRadius * 1000.0 * math.Asin(math.Sqrt(a))
+}
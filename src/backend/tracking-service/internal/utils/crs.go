@@ -0,0 +1,102 @@
+package utils
+
+import (
+	// errors provides the unknown CRS sentinel (go1.21)
+	"errors"
+	// fmt provides CRS identifier parsing errors (go1.21)
+	"fmt"
+	// strconv parses EPSG codes (go1.21)
+	"strconv"
+	// strings normalizes CRS identifiers (go1.21)
+	"strings"
+	// sync guards the transform cache (go1.21)
+	"sync"
+
+	// wgs84 provides EPSG definitions and datum-aware reprojection (github.com/wroge/wgs84 v1.1.7)
+	"github.com/wroge/wgs84"
+
+	// models provides the Location struct used for GPS coordinate representations
+	"src/backend/tracking-service/internal/models"
+)
+
+// wgs84Code is the EPSG code of WGS84 longitude/latitude.
+const wgs84Code = 4326
+
+// ErrUnknownCRS is returned for CRS identifiers that are malformed or not in the
+// EPSG registry.
+var ErrUnknownCRS = errors.New("unknown coordinate reference system")
+
+// parseEPSG parses an "EPSG:<code>" identifier case-insensitively. An empty
+// identifier is WGS84.
+func parseEPSG(id string) (int, error) {
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return wgs84Code, nil
+	}
+	prefix, codeStr, found := strings.Cut(id, ":")
+	if !found || !strings.EqualFold(prefix, "EPSG") {
+		return 0, fmt.Errorf("%w: %q is not an EPSG:<code> identifier", ErrUnknownCRS, id)
+	}
+	code, err := strconv.Atoi(codeStr)
+	if err != nil || code <= 0 {
+		return 0, fmt.Errorf("%w: %q has an invalid EPSG code", ErrUnknownCRS, id)
+	}
+	return code, nil
+}
+
+// Reprojector converts locations declared in other coordinate reference systems
+// to WGS84. Transforms are built once per EPSG code and reused.
+type Reprojector struct {
+	mu         sync.RWMutex
+	transforms map[int]wgs84.Func
+}
+
+// NewReprojector creates a reprojector with an empty transform cache.
+func NewReprojector() *Reprojector {
+	return &Reprojector{transforms: make(map[int]wgs84.Func)}
+}
+
+// Reproject converts loc to WGS84 in place and clears its CRS declaration.
+// Locations without a CRS, or already in WGS84, are left untouched; unknown
+// CRS identifiers return an error wrapping ErrUnknownCRS. Only the horizontal
+// position is converted; Altitude is kept as reported.
+func (r *Reprojector) Reproject(loc *models.Location) error {
+	code, err := parseEPSG(loc.CRS)
+	if err != nil {
+		return err
+	}
+	if code == wgs84Code {
+		loc.CRS = ""
+		return nil
+	}
+
+	transform, err := r.transform(code)
+	if err != nil {
+		return err
+	}
+	lon, lat, _ := transform(loc.Longitude, loc.Latitude, 0)
+	loc.Longitude = lon
+	loc.Latitude = lat
+	loc.CRS = ""
+	return nil
+}
+
+// transform returns the cached transform from code to WGS84, building it on
+// first use. Codes missing from the EPSG registry are rejected.
+func (r *Reprojector) transform(code int) (wgs84.Func, error) {
+	r.mu.RLock()
+	transform, ok := r.transforms[code]
+	r.mu.RUnlock()
+	if ok {
+		return transform, nil
+	}
+
+	transform, err := wgs84.EPSG().SafeTransform(code, wgs84Code)
+	if err != nil {
+		return nil, fmt.Errorf("%w: EPSG:%d", ErrUnknownCRS, code)
+	}
+	r.mu.Lock()
+	r.transforms[code] = transform
+	r.mu.Unlock()
+	return transform, nil
+}
@@ -0,0 +1,291 @@
+package utils
+
+import (
+	// log go1.21 for pool alerts and tuning decisions, consistent with the MQTT wrapper
+	"log"
+
+	// math go1.21 for rounding connection targets
+	"math"
+
+	// sync go1.21 for guarding the load profile and stop signal
+	"sync"
+
+	// time go1.21 for sampling intervals and wait durations
+	"time"
+
+	// prometheus v1.16.0 for pool metrics
+	"github.com/prometheus/client_golang/prometheus"
+
+	// Internal import for pool monitoring configuration
+	"src/backend/tracking-service/internal/config"
+)
+
+// ---------------------------------------------------------------------
+// Global Constants
+// ---------------------------------------------------------------------
+
+// poolTuneHeadroom is the multiplier applied to the busiest hour's peak when
+// choosing MaxConns, leaving room for bursts above the observed profile.
+const poolTuneHeadroom = 1.25
+
+// poolProfileSmoothing weights the latest day against the running profile, so
+// one unusual day moves the targets only partway.
+const poolProfileSmoothing = 0.5
+
+// ---------------------------------------------------------------------
+// PoolSnapshot Struct
+// ---------------------------------------------------------------------
+// PoolSnapshot is a point-in-time view of a connection pool. Counters and
+// AcquireDuration are cumulative since the pool was created.
+type PoolSnapshot struct {
+	AcquiredConns        int32
+	IdleConns            int32
+	TotalConns           int32
+	MinConns             int32
+	MaxConns             int32
+	AcquireCount         int64
+	EmptyAcquireCount    int64
+	CanceledAcquireCount int64
+	AcquireDuration      time.Duration
+}
+
+// ---------------------------------------------------------------------
+// PoolController Interface
+// ---------------------------------------------------------------------
+// PoolController exposes a connection pool to the PoolMonitor. It is
+// implemented by the service's pgxpool-backed TimescaleDB connection.
+type PoolController interface {
+	// PoolSnapshot returns the pool's current statistics.
+	PoolSnapshot() PoolSnapshot
+
+	// Resize applies new MinConns/MaxConns limits. Cumulative counters may
+	// restart from zero afterwards.
+	Resize(minConns, maxConns int32) error
+}
+
+// ---------------------------------------------------------------------
+// PoolMonitor Struct
+// ---------------------------------------------------------------------
+// PoolMonitor samples a connection pool, exports its statistics as Prometheus
+// metrics, raises an alert when the average acquisition wait exceeds the
+// configured threshold, and optionally re-sizes the pool once a day from the
+// observed hourly load.
+type PoolMonitor struct {
+	pool PoolController
+	cfg  config.DBConfig
+
+	mu          sync.Mutex
+	last        PoolSnapshot
+	hourlyPeak  [24]int32
+	profile     [24]float64
+	hasProfile  bool
+	waitAlerted bool
+	lastTuneDay string
+
+	stopOnce sync.Once
+	stop     chan struct{}
+
+	connections  *prometheus.GaugeVec
+	acquires     *prometheus.CounterVec
+	acquireWait  prometheus.Gauge
+	waitAlerts   prometheus.Counter
+	tuneDecision *prometheus.GaugeVec
+}
+
+// ---------------------------------------------------------------------
+// Factory Function: NewPoolMonitor
+// ---------------------------------------------------------------------
+// NewPoolMonitor creates a monitor for pool using the pool settings in cfg.
+// Metrics are registered on the given registry when it is non-nil.
+func NewPoolMonitor(pool PoolController, cfg config.DBConfig, registry *prometheus.Registry) *PoolMonitor {
+	pm := &PoolMonitor{
+		pool: pool,
+		cfg:  cfg,
+		last: pool.PoolSnapshot(),
+		stop: make(chan struct{}),
+		connections: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "tracking_db_pool_connections",
+				Help: "Database pool connections by state (acquired, idle, total, min, max).",
+			},
+			[]string{"state"},
+		),
+		acquires: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tracking_db_pool_acquires_total",
+				Help: "Database pool acquisitions by outcome (immediate, waited, canceled).",
+			},
+			[]string{"outcome"},
+		),
+		acquireWait: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "tracking_db_pool_acquire_wait_seconds",
+			Help: "Average database pool acquisition wait over the last sampling interval.",
+		}),
+		waitAlerts: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "tracking_db_pool_acquire_wait_alerts_total",
+			Help: "Sampling intervals whose average acquisition wait exceeded the alert threshold.",
+		}),
+		tuneDecision: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "tracking_db_pool_autotune_target_conns",
+				Help: "Connection limits chosen by the last pool auto-tuning run.",
+			},
+			[]string{"limit"},
+		),
+	}
+	if registry != nil {
+		registry.MustRegister(pm.connections, pm.acquires, pm.acquireWait, pm.waitAlerts, pm.tuneDecision)
+	}
+	return pm
+}
+
+// Start samples the pool every cfg.PoolStatsInterval until Stop is called.
+func (pm *PoolMonitor) Start() {
+	go func() {
+		ticker := time.NewTicker(pm.cfg.PoolStatsInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-pm.stop:
+				return
+			case now := <-ticker.C:
+				pm.Sample(now)
+			}
+		}
+	}()
+}
+
+// Stop ends background sampling.
+func (pm *PoolMonitor) Stop() {
+	pm.stopOnce.Do(func() { close(pm.stop) })
+}
+
+// Sample records one observation of the pool.
+//
+// Steps:
+//  1. Export connection gauges.
+//  2. Export acquisition counters from the deltas since the last sample.
+//  3. Alert when the interval's average wait exceeds the threshold.
+//  4. Track the hourly peak of acquired connections.
+//  5. Run auto-tuning once a day at the configured hour.
+func (pm *PoolMonitor) Sample(now time.Time) {
+	snap := pm.pool.PoolSnapshot()
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	// 1. Export connection gauges
+	pm.connections.WithLabelValues("acquired").Set(float64(snap.AcquiredConns))
+	pm.connections.WithLabelValues("idle").Set(float64(snap.IdleConns))
+	pm.connections.WithLabelValues("total").Set(float64(snap.TotalConns))
+	pm.connections.WithLabelValues("min").Set(float64(snap.MinConns))
+	pm.connections.WithLabelValues("max").Set(float64(snap.MaxConns))
+
+	// 2. Export acquisition counters; a resized pool restarts its counters
+	acquired := counterDelta(snap.AcquireCount, pm.last.AcquireCount)
+	waited := counterDelta(snap.EmptyAcquireCount, pm.last.EmptyAcquireCount)
+	canceled := counterDelta(snap.CanceledAcquireCount, pm.last.CanceledAcquireCount)
+	waitTotal := snap.AcquireDuration - pm.last.AcquireDuration
+	if snap.AcquireCount < pm.last.AcquireCount {
+		waitTotal = snap.AcquireDuration
+	}
+	if immediate := acquired - waited; immediate > 0 {
+		pm.acquires.WithLabelValues("immediate").Add(float64(immediate))
+	}
+	pm.acquires.WithLabelValues("waited").Add(float64(waited))
+	pm.acquires.WithLabelValues("canceled").Add(float64(canceled))
+	pm.last = snap
+
+	// 3. Alert on slow acquisitions
+	var avgWait time.Duration
+	if acquired > 0 {
+		avgWait = waitTotal / time.Duration(acquired)
+	}
+	pm.acquireWait.Set(avgWait.Seconds())
+	if avgWait > pm.cfg.AcquireWaitThreshold {
+		pm.waitAlerts.Inc()
+		pm.waitAlerted = true
+		log.Printf("[PoolMonitor] ALERT: average DB connection acquire wait %s exceeds threshold %s (acquired=%d/%d, waited=%d, canceled=%d)\n",
+			avgWait, pm.cfg.AcquireWaitThreshold, snap.AcquiredConns, snap.MaxConns, waited, canceled)
+	}
+
+	// 4. Track the hourly peak
+	hour := now.Hour()
+	if snap.AcquiredConns > pm.hourlyPeak[hour] {
+		pm.hourlyPeak[hour] = snap.AcquiredConns
+	}
+
+	// 5. Auto-tune once a day
+	day := now.Format("2006-01-02")
+	if pm.cfg.PoolAutoTune && hour == pm.cfg.PoolAutoTuneHour && pm.lastTuneDay != day {
+		pm.lastTuneDay = day
+		pm.tuneLocked(snap)
+	}
+}
+
+// tuneLocked folds the last day's hourly peaks into the load profile and
+// re-sizes the pool. MinConns covers the average hour and MaxConns the busiest
+// hour plus headroom; if waits alerted during the day MaxConns is not lowered.
+// Both stay within [1, cfg.MaxConnections]. Callers must hold pm.mu.
+func (pm *PoolMonitor) tuneLocked(snap PoolSnapshot) {
+	for h, peak := range pm.hourlyPeak {
+		if pm.hasProfile {
+			pm.profile[h] = poolProfileSmoothing*float64(peak) + (1-poolProfileSmoothing)*pm.profile[h]
+		} else {
+			pm.profile[h] = float64(peak)
+		}
+	}
+	pm.hasProfile = true
+
+	var sum, busiest float64
+	for _, load := range pm.profile {
+		sum += load
+		busiest = math.Max(busiest, load)
+	}
+	minConns := clampConns(int32(math.Ceil(sum/24)), pm.cfg.MaxConnections)
+	maxConns := clampConns(int32(math.Ceil(busiest*poolTuneHeadroom)), pm.cfg.MaxConnections)
+	if pm.waitAlerted && maxConns < snap.MaxConns {
+		maxConns = snap.MaxConns
+	}
+	if minConns > maxConns {
+		minConns = maxConns
+	}
+
+	pm.hourlyPeak = [24]int32{}
+	pm.waitAlerted = false
+	pm.tuneDecision.WithLabelValues("min").Set(float64(minConns))
+	pm.tuneDecision.WithLabelValues("max").Set(float64(maxConns))
+
+	if minConns == snap.MinConns && maxConns == snap.MaxConns {
+		log.Printf("[PoolMonitor] Auto-tune kept pool limits min=%d max=%d\n", minConns, maxConns)
+		return
+	}
+	if err := pm.pool.Resize(minConns, maxConns); err != nil {
+		log.Printf("[PoolMonitor] Auto-tune failed to resize pool to min=%d max=%d: %v\n", minConns, maxConns, err)
+		return
+	}
+	// The resized pool restarts its cumulative counters.
+	pm.last = pm.pool.PoolSnapshot()
+	log.Printf("[PoolMonitor] Auto-tune resized pool from min=%d max=%d to min=%d max=%d\n",
+		snap.MinConns, snap.MaxConns, minConns, maxConns)
+}
+
+// counterDelta returns the increase of a cumulative counter, treating a
+// decrease as a restart from zero.
+func counterDelta(current, previous int64) int64 {
+	if current < previous {
+		return current
+	}
+	return current - previous
+}
+
+// clampConns bounds a connection target to [1, limit].
+func clampConns(n int32, limit int) int32 {
+	if n < 1 {
+		n = 1
+	}
+	if limit > 0 && n > int32(limit) {
+		n = int32(limit)
+	}
+	return n
+}
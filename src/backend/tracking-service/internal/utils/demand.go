@@ -0,0 +1,27 @@
+package utils
+
+import (
+	// math provides cell index rounding (go1.21)
+	"math"
+)
+
+// DemandCellSizeMeters is the edge length of the grid cells walk starts are
+// counted in. Cells are much coarser than territory cells, so a count covers
+// a neighbourhood rather than a street.
+const DemandCellSizeMeters float64 = 500.0
+
+// DemandCell returns the key and center of the demand grid cell containing a
+// coordinate. Like territory cells, longitude width follows the latitude band
+// so cells stay roughly square.
+func DemandCell(lat, lon float64) (string, float64, float64) {
+	latStep := DemandCellSizeMeters / metersPerDegreeLatitude
+	latIdx := int64(math.Floor(lat / latStep))
+	bandCenter := (float64(latIdx) + 0.5) * latStep
+	cosBand := math.Cos(bandCenter * math.Pi / 180.0)
+	if cosBand < 1e-6 {
+		cosBand = 1e-6
+	}
+	lonStep := DemandCellSizeMeters / (metersPerDegreeLatitude * cosBand)
+	lonIdx := int64(math.Floor(lon / lonStep))
+	return cellKey(latIdx, lonIdx), bandCenter, (float64(lonIdx) + 0.5) * lonStep
+}
@@ -0,0 +1,384 @@
+package utils
+
+import (
+	// crypto/sha256 go1.21 for fingerprinting payloads that cannot be redacted field by field
+	"crypto/sha256"
+
+	// encoding/hex go1.21 for printing payload fingerprints
+	"encoding/hex"
+
+	// encoding/json go1.21 for bundle encoding and payload redaction
+	"encoding/json"
+
+	// fmt go1.21 for bundle file names and panic values
+	"fmt"
+
+	// log go1.21 for panic reports, consistent with the MQTT wrapper
+	"log"
+
+	// math go1.21 for coarsening coordinates in payloads
+	"math"
+
+	// os go1.21 for bundle files
+	"os"
+
+	// path/filepath go1.21 for bundle paths
+	"path/filepath"
+
+	// runtime/debug go1.21 for the stack of the recovered goroutine
+	"runtime/debug"
+
+	// sort go1.21 for pruning the oldest bundles first
+	"sort"
+
+	// strings go1.21 for matching sensitive payload keys and bundle names
+	"strings"
+
+	// sync go1.21 for guarding the pipeline trail
+	"sync"
+
+	// time go1.21 for bundle and trail timestamps
+	"time"
+
+	// prometheus v1.16.0 for panic and bundle metrics
+	"github.com/prometheus/client_golang/prometheus"
+
+	// Internal import for diagnostics configuration
+	"src/backend/tracking-service/internal/config"
+)
+
+// ---------------------------------------------------------------------
+// Global Constants
+// ---------------------------------------------------------------------
+
+// diagnosticsBundleSuffix names bundle files in the diagnostics directory.
+const diagnosticsBundleSuffix = ".diag.json"
+
+// maxDiagnosticPayload bounds the message payload kept in a bundle; larger
+// payloads are fingerprinted instead.
+const maxDiagnosticPayload = 16 << 10
+
+// redactedValue replaces sensitive payload fields.
+const redactedValue = "[REDACTED]"
+
+// sensitivePayloadKeys are substrings of payload keys, compared in lower
+// case, whose values are credentials and never kept in a bundle.
+var sensitivePayloadKeys = []string{"token", "password", "secret", "authorization", "apikey", "api_key", "credential"}
+
+// coordinatePayloadKeys are payload keys holding coordinates, which are
+// rounded to two decimal places (about a kilometer) in a bundle.
+var coordinatePayloadKeys = map[string]bool{"latitude": true, "longitude": true, "lat": true, "lon": true, "lng": true}
+
+// ---------------------------------------------------------------------
+// DiagnosticContext Struct
+// ---------------------------------------------------------------------
+// DiagnosticContext describes the work in progress when a panic occurs.
+// Callers defer Recover with a pointer to it and fill it in as the work
+// proceeds, so a bundle records as much as was known.
+type DiagnosticContext struct {
+	// SessionID is the tracking session being handled, if known.
+	SessionID string
+
+	// Payload is the message that triggered the work. It is redacted before
+	// it is written to a bundle.
+	Payload []byte
+
+	// Fields carries further context such as the topic or route.
+	Fields map[string]string
+}
+
+// ---------------------------------------------------------------------
+// PipelineEvent Struct
+// ---------------------------------------------------------------------
+// PipelineEvent is one step of recent pipeline activity, kept in a bounded
+// trail so a bundle shows what the service was doing before a panic.
+type PipelineEvent struct {
+	At        time.Time `json:"at"`
+	Component string    `json:"component"`
+	SessionID string    `json:"sessionId,omitempty"`
+	Detail    string    `json:"detail"`
+}
+
+// ---------------------------------------------------------------------
+// DiagnosticsBundle Struct
+// ---------------------------------------------------------------------
+// DiagnosticsBundle is the record of one recovered panic.
+type DiagnosticsBundle struct {
+	ID          string            `json:"id"`
+	Component   string            `json:"component"`
+	RecoveredAt time.Time         `json:"recoveredAt"`
+	Panic       string            `json:"panic"`
+	Stack       string            `json:"stack"`
+	SessionID   string            `json:"sessionId,omitempty"`
+	Payload     json.RawMessage   `json:"payload,omitempty"`
+	Fields      map[string]string `json:"fields,omitempty"`
+	RecentState []PipelineEvent   `json:"recentState"`
+}
+
+// ---------------------------------------------------------------------
+// DiagnosticsRecorder Struct
+// ---------------------------------------------------------------------
+// DiagnosticsRecorder turns recovered panics into diagnostics bundles: the
+// panic value and stack, the redacted triggering payload, the session
+// context, and the trail of recent pipeline events. Bundles are written to
+// the configured directory, keeping the newest MaxBundles, and every
+// recovery is counted by component. A nil recorder still recovers and logs
+// the panic with its stack, so components can defer Recover unconditionally.
+type DiagnosticsRecorder struct {
+	dir        string
+	maxBundles int
+
+	mu        sync.Mutex
+	trail     []PipelineEvent
+	trailNext int
+	trailFull bool
+	seq       uint64
+
+	panics  *prometheus.CounterVec
+	bundles *prometheus.CounterVec
+}
+
+// ---------------------------------------------------------------------
+// Factory Function: NewDiagnosticsRecorder
+// ---------------------------------------------------------------------
+// NewDiagnosticsRecorder creates a recorder with the given settings,
+// creating the bundle directory if one is configured. Metrics are
+// registered on the given registry when it is non-nil.
+func NewDiagnosticsRecorder(cfg config.DiagnosticsConfig, registry *prometheus.Registry) (*DiagnosticsRecorder, error) {
+	if cfg.Dir != "" {
+		if err := os.MkdirAll(cfg.Dir, 0o750); err != nil {
+			return nil, fmt.Errorf("failed to create diagnostics directory: %w", err)
+		}
+	}
+	trailSize := cfg.TrailSize
+	if trailSize < 1 {
+		trailSize = 1
+	}
+	d := &DiagnosticsRecorder{
+		dir:        cfg.Dir,
+		maxBundles: cfg.MaxBundles,
+		trail:      make([]PipelineEvent, trailSize),
+		panics: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tracking_panics_recovered_total",
+				Help: "Panics recovered by component.",
+			},
+			[]string{"component"},
+		),
+		bundles: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tracking_diagnostics_bundles_total",
+				Help: "Diagnostics bundles by outcome (written, failed, skipped when no directory is configured).",
+			},
+			[]string{"outcome"},
+		),
+	}
+	if registry != nil {
+		registry.MustRegister(d.panics, d.bundles)
+	}
+	return d, nil
+}
+
+// ---------------------------------------------------------------------
+// Method: Trace
+// ---------------------------------------------------------------------
+// Trace appends a step of pipeline activity to the trail, overwriting the
+// oldest once the trail is full. It is a no-op on a nil recorder.
+func (d *DiagnosticsRecorder) Trace(component, sessionID, detail string) {
+	if d == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.trail[d.trailNext] = PipelineEvent{At: time.Now().UTC(), Component: component, SessionID: sessionID, Detail: detail}
+	d.trailNext = (d.trailNext + 1) % len(d.trail)
+	if d.trailNext == 0 {
+		d.trailFull = true
+	}
+}
+
+// recentState returns the trail oldest first.
+func (d *DiagnosticsRecorder) recentState() []PipelineEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.trailFull {
+		return append([]PipelineEvent(nil), d.trail[:d.trailNext]...)
+	}
+	events := make([]PipelineEvent, 0, len(d.trail))
+	events = append(events, d.trail[d.trailNext:]...)
+	return append(events, d.trail[:d.trailNext]...)
+}
+
+// ---------------------------------------------------------------------
+// Method: Recover
+// ---------------------------------------------------------------------
+// Recover must be deferred directly. It recovers a panic in the deferring
+// goroutine and captures a diagnostics bundle for it from dctx, which may be
+// nil; without a panic it does nothing.
+func (d *DiagnosticsRecorder) Recover(component string, dctx *DiagnosticContext) {
+	p := recover()
+	if p == nil {
+		return
+	}
+	d.Capture(component, p, dctx)
+}
+
+// ---------------------------------------------------------------------
+// Method: Capture
+// ---------------------------------------------------------------------
+// Capture records a panic value already recovered by the caller, which must
+// still be unwinding it so the stack shows where it was raised. It returns
+// the path of the written bundle, or "" if none was written.
+func (d *DiagnosticsRecorder) Capture(component string, p interface{}, dctx *DiagnosticContext) string {
+	if dctx == nil {
+		dctx = &DiagnosticContext{}
+	}
+	stack := debug.Stack()
+	if d == nil {
+		log.Printf("[Diagnostics] Panic recovered in %s (sessionID=%s): %v\n%s", component, dctx.SessionID, p, stack)
+		return ""
+	}
+	d.panics.WithLabelValues(component).Inc()
+
+	d.mu.Lock()
+	d.seq++
+	seq := d.seq
+	d.mu.Unlock()
+
+	now := time.Now().UTC()
+	bundle := DiagnosticsBundle{
+		ID:          fmt.Sprintf("%020d-%06d", now.UnixNano(), seq),
+		Component:   component,
+		RecoveredAt: now,
+		Panic:       fmt.Sprint(p),
+		Stack:       string(stack),
+		SessionID:   dctx.SessionID,
+		Fields:      dctx.Fields,
+		RecentState: d.recentState(),
+	}
+	if len(dctx.Payload) > 0 {
+		bundle.Payload = RedactPayload(dctx.Payload)
+	}
+
+	if d.dir == "" {
+		d.bundles.WithLabelValues("skipped").Inc()
+		log.Printf("[Diagnostics] Panic recovered in %s (sessionID=%s): %v\n%s", component, dctx.SessionID, p, stack)
+		return ""
+	}
+	path, err := d.write(bundle)
+	if err != nil {
+		d.bundles.WithLabelValues("failed").Inc()
+		log.Printf("[Diagnostics] Panic recovered in %s (sessionID=%s): %v; failed to write bundle: %v\n%s", component, dctx.SessionID, p, err, stack)
+		return ""
+	}
+	d.bundles.WithLabelValues("written").Inc()
+	log.Printf("[Diagnostics] Panic recovered in %s (sessionID=%s): %v; bundle written to %s\n", component, dctx.SessionID, p, path)
+	return path
+}
+
+// write persists a bundle atomically and prunes the oldest beyond maxBundles.
+func (d *DiagnosticsRecorder) write(bundle DiagnosticsBundle) (string, error) {
+	raw, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	// Zero-padded IDs sort in recovery order.
+	name := bundle.ID + "-" + sanitizeBundleComponent(bundle.Component) + diagnosticsBundleSuffix
+	path := filepath.Join(d.dir, name)
+	tmp := filepath.Join(d.dir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, raw, 0o640); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	d.prune()
+	return path, nil
+}
+
+// prune removes the oldest bundles beyond maxBundles; zero keeps them all.
+func (d *DiagnosticsRecorder) prune() {
+	if d.maxBundles <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(d.dir)
+	if err != nil {
+		return
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), diagnosticsBundleSuffix) && !strings.HasPrefix(e.Name(), ".") {
+			names = append(names, e.Name())
+		}
+	}
+	if len(names) <= d.maxBundles {
+		return
+	}
+	sort.Strings(names)
+	for _, name := range names[:len(names)-d.maxBundles] {
+		_ = os.Remove(filepath.Join(d.dir, name))
+	}
+}
+
+// sanitizeBundleComponent keeps a component name safe for a file name.
+func sanitizeBundleComponent(component string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, component)
+}
+
+// ---------------------------------------------------------------------
+// Function: RedactPayload
+// ---------------------------------------------------------------------
+// RedactPayload prepares a message payload for a diagnostics bundle. JSON
+// payloads keep their structure with credential fields replaced and
+// coordinates rounded to two decimal places; payloads that are not JSON, or
+// larger than 16 KiB, are replaced by their size and SHA-256 fingerprint.
+func RedactPayload(raw []byte) json.RawMessage {
+	var doc interface{}
+	if len(raw) <= maxDiagnosticPayload && json.Unmarshal(raw, &doc) == nil {
+		if redacted, err := json.Marshal(redactValue("", doc)); err == nil {
+			return redacted
+		}
+	}
+	sum := sha256.Sum256(raw)
+	summary, _ := json.Marshal(map[string]interface{}{
+		"redacted": "payload withheld",
+		"bytes":    len(raw),
+		"sha256":   hex.EncodeToString(sum[:]),
+	})
+	return summary
+}
+
+// redactValue redacts v, found under key, recursively.
+func redactValue(key string, v interface{}) interface{} {
+	lower := strings.ToLower(key)
+	for _, s := range sensitivePayloadKeys {
+		if strings.Contains(lower, s) {
+			return redactedValue
+		}
+	}
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, inner := range val {
+			val[k] = redactValue(k, inner)
+		}
+		return val
+	case []interface{}:
+		for i, inner := range val {
+			val[i] = redactValue("", inner)
+		}
+		return val
+	case float64:
+		if coordinatePayloadKeys[lower] {
+			return math.Round(val*100) / 100
+		}
+		return val
+	default:
+		return val
+	}
+}
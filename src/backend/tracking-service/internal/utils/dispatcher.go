@@ -0,0 +1,173 @@
+package utils
+
+import (
+	// errors go1.21 for sentinel dispatch errors
+	"errors"
+
+	// hash/fnv go1.21 for hashing session IDs onto workers
+	"hash/fnv"
+
+	// strconv go1.21 for labelling workers in diagnostics bundles
+	"strconv"
+
+	// sync go1.21 for worker coordination and shutdown
+	"sync"
+)
+
+// ---------------------------------------------------------------------
+// Global Constants
+// ---------------------------------------------------------------------
+
+// DefaultDispatchWorkers is the default number of session worker goroutines.
+const DefaultDispatchWorkers = 8
+
+// DefaultDispatchQueueSize is the default bounded queue length per worker.
+const DefaultDispatchQueueSize = 256
+
+// ErrDispatchQueueFull is returned when the worker owning a session has no
+// queue capacity left. The message is dropped rather than blocking the caller.
+var ErrDispatchQueueFull = errors.New("session dispatch queue is full")
+
+// ErrDispatcherStopped is returned when work is submitted after Stop.
+var ErrDispatcherStopped = errors.New("session dispatcher is stopped")
+
+// ---------------------------------------------------------------------
+// SessionDispatcher Struct
+// ---------------------------------------------------------------------
+// SessionDispatcher moves per-session work off paho's router goroutines.
+// Each session ID hashes onto exactly one of a fixed set of workers, so all
+// work for a session runs sequentially and in submission order, while a slow
+// session only delays the sessions sharing its worker. Queues are bounded and
+// Dispatch never blocks.
+type SessionDispatcher struct {
+	// queues holds one bounded task channel per worker.
+	queues []chan dispatchTask
+
+	// diagnostics captures a bundle for each task that panics (nil only logs it).
+	diagnostics *DiagnosticsRecorder
+
+	// mu guards stopped against concurrent Dispatch/Stop calls.
+	mu sync.RWMutex
+
+	// stopped is set once Stop has closed the queues.
+	stopped bool
+
+	// wg tracks running workers so Stop can wait for queued work to drain.
+	wg sync.WaitGroup
+}
+
+// dispatchTask is a unit of work queued for the session it belongs to.
+type dispatchTask struct {
+	sessionID string
+	run       func()
+}
+
+// ---------------------------------------------------------------------
+// Factory Function: NewSessionDispatcher
+// ---------------------------------------------------------------------
+// NewSessionDispatcher creates a dispatcher with the given number of workers,
+// each owning a queue of queueSize tasks, and starts the workers. Non-positive
+// arguments fall back to DefaultDispatchWorkers and DefaultDispatchQueueSize.
+func NewSessionDispatcher(workers, queueSize int) *SessionDispatcher {
+	if workers <= 0 {
+		workers = DefaultDispatchWorkers
+	}
+	if queueSize <= 0 {
+		queueSize = DefaultDispatchQueueSize
+	}
+
+	d := &SessionDispatcher{
+		queues: make([]chan dispatchTask, workers),
+	}
+	for i := range d.queues {
+		d.queues[i] = make(chan dispatchTask, queueSize)
+		d.wg.Add(1)
+		go d.runWorker(i, d.queues[i])
+	}
+	return d
+}
+
+// ---------------------------------------------------------------------
+// Method: Dispatch
+// ---------------------------------------------------------------------
+// Dispatch enqueues task on the worker that owns sessionID. It returns
+// ErrDispatchQueueFull without blocking when that worker is saturated and
+// ErrDispatcherStopped after Stop.
+func (d *SessionDispatcher) Dispatch(sessionID string, task func()) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.stopped {
+		return ErrDispatcherStopped
+	}
+	select {
+	case d.queues[d.workerFor(sessionID)] <- dispatchTask{sessionID: sessionID, run: task}:
+		return nil
+	default:
+		return ErrDispatchQueueFull
+	}
+}
+
+// ---------------------------------------------------------------------
+// Method: SetDiagnostics
+// ---------------------------------------------------------------------
+// SetDiagnostics captures a diagnostics bundle for every task that panics.
+// It must be called before the first Dispatch.
+func (d *SessionDispatcher) SetDiagnostics(diagnostics *DiagnosticsRecorder) {
+	d.diagnostics = diagnostics
+}
+
+// ---------------------------------------------------------------------
+// Method: QueueDepth
+// ---------------------------------------------------------------------
+// QueueDepth returns the total number of tasks waiting across all workers.
+func (d *SessionDispatcher) QueueDepth() int {
+	depth := 0
+	for _, q := range d.queues {
+		depth += len(q)
+	}
+	return depth
+}
+
+// ---------------------------------------------------------------------
+// Method: Stop
+// ---------------------------------------------------------------------
+// Stop rejects further work, lets every worker drain its queue, and waits for
+// them to exit. Calling Stop more than once is safe.
+func (d *SessionDispatcher) Stop() {
+	d.mu.Lock()
+	if d.stopped {
+		d.mu.Unlock()
+		return
+	}
+	d.stopped = true
+	for _, q := range d.queues {
+		close(q)
+	}
+	d.mu.Unlock()
+
+	d.wg.Wait()
+}
+
+// workerFor maps a session ID onto a worker index using FNV-1a.
+func (d *SessionDispatcher) workerFor(sessionID string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(sessionID))
+	return int(h.Sum32() % uint32(len(d.queues)))
+}
+
+// runWorker executes tasks from a single queue until it is closed. A panic in
+// one task is recovered into a diagnostics bundle so the worker keeps serving
+// its other sessions.
+func (d *SessionDispatcher) runWorker(index int, queue chan dispatchTask) {
+	defer d.wg.Done()
+	for task := range queue {
+		func() {
+			defer d.diagnostics.Recover("dispatcher", &DiagnosticContext{
+				SessionID: task.sessionID,
+				Fields:    map[string]string{"worker": strconv.Itoa(index)},
+			})
+			task.run()
+		}()
+	}
+}
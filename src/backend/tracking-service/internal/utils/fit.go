@@ -0,0 +1,244 @@
+package utils
+
+import (
+	// bytes provides the buffer the FIT file is assembled in (go1.21)
+	"bytes"
+	// binary provides little-endian field encoding (go1.21)
+	"encoding/binary"
+	// errors provides encoding validation failures (go1.21)
+	"errors"
+	// math provides rounding for scaled FIT fields (go1.21)
+	"math"
+	// time provides FIT epoch conversion (go1.21)
+	"time"
+
+	// models provides the Location struct used for GPS coordinate representations
+	"src/backend/tracking-service/internal/models"
+)
+
+// FITContentType is the MIME type used when serving FIT files.
+const FITContentType = "application/vnd.ant.fit"
+
+// fitEpoch is the FIT timestamp origin, 1989-12-31T00:00:00Z, in Unix seconds.
+const fitEpoch int64 = 631065600
+
+// fitProfileVersion is the FIT SDK profile version the encoded messages follow (21.32).
+const fitProfileVersion uint16 = 2132
+
+// fitProtocolVersion is FIT protocol 1.0, which every consumer accepts.
+const fitProtocolVersion byte = 0x10
+
+// FIT global message numbers.
+const (
+	fitMesgFileID   uint16 = 0
+	fitMesgSession  uint16 = 18
+	fitMesgLap      uint16 = 19
+	fitMesgRecord   uint16 = 20
+	fitMesgEvent    uint16 = 21
+	fitMesgActivity uint16 = 34
+)
+
+// FIT base types.
+const (
+	fitEnum    byte = 0x00
+	fitUint16  byte = 0x84
+	fitSint32  byte = 0x85
+	fitUint32  byte = 0x86
+	fitUint32z byte = 0x8C
+)
+
+// FIT enum values used by the encoded messages.
+const (
+	fitFileActivity       = 4
+	fitManufacturerDev    = 255
+	fitSportWalking       = 11
+	fitEventTimer         = 0
+	fitEventSession       = 8
+	fitEventLap           = 9
+	fitEventActivity      = 26
+	fitEventTypeStart     = 0
+	fitEventTypeStop      = 1
+	fitEventTypeStopAll   = 4
+	fitFieldTimestamp     = 253
+	fitActivityTypeManual = 0
+)
+
+// fitCRCTable is the nibble table of the FIT CRC-16 algorithm.
+var fitCRCTable = [16]uint16{
+	0x0000, 0xCC01, 0xD801, 0x1400, 0xF001, 0x3C00, 0x2800, 0xE401,
+	0xA001, 0x6C00, 0x7800, 0xB401, 0x5000, 0x9C01, 0x8801, 0x4400,
+}
+
+// fitField is one field definition of a FIT definition message.
+type fitField struct {
+	num      byte
+	size     byte
+	baseType byte
+}
+
+// fitWriter accumulates FIT records, assigning local message types on first use.
+type fitWriter struct {
+	buf   bytes.Buffer
+	local map[uint16]byte
+}
+
+// EncodeWalkFIT encodes a walk track as a FIT activity file that fitness platforms
+// such as Strava and Garmin Connect import as a walk. Each point becomes a record
+// with its timestamp, position, cumulative distance and speed; the track is wrapped
+// in timer start/stop events, a single lap, a walking session and an activity.
+//
+// Steps:
+//  1. Require at least one point, in chronological order.
+//  2. Write file_id and the timer start event.
+//  3. Write one record per point with cumulative distance and segment speed.
+//  4. Write the timer stop event, lap, session and activity summaries.
+//  5. Prepend the file header and append the file CRC.
+func EncodeWalkFIT(points []models.Location) ([]byte, error) {
+	if len(points) == 0 {
+		return nil, errors.New("cannot encode FIT file: walk has no points")
+	}
+	for i := 1; i < len(points); i++ {
+		if points[i].Timestamp.Before(points[i-1].Timestamp) {
+			return nil, errors.New("cannot encode FIT file: points are not in chronological order")
+		}
+	}
+
+	w := &fitWriter{local: make(map[uint16]byte)}
+	start := points[0].Timestamp
+	end := points[len(points)-1].Timestamp
+
+	w.message(fitMesgFileID, []fitField{
+		{0, 1, fitEnum}, {1, 2, fitUint16}, {2, 2, fitUint16}, {3, 4, fitUint32z}, {4, 4, fitUint32},
+	}, byte(fitFileActivity), uint16(fitManufacturerDev), uint16(0), uint32(1), fitTime(start))
+
+	eventFields := []fitField{{fitFieldTimestamp, 4, fitUint32}, {0, 1, fitEnum}, {1, 1, fitEnum}}
+	w.message(fitMesgEvent, eventFields, fitTime(start), byte(fitEventTimer), byte(fitEventTypeStart))
+
+	var distance, maxSpeed float64
+	recordFields := []fitField{
+		{fitFieldTimestamp, 4, fitUint32}, {0, 4, fitSint32}, {1, 4, fitSint32}, {5, 4, fitUint32}, {6, 2, fitUint16},
+	}
+	for i := range points {
+		var speed float64
+		if i > 0 {
+			prev, cur := points[i-1], points[i]
+			segment := distanceBetweenMeters(prev.Latitude, prev.Longitude, cur.Latitude, cur.Longitude)
+			distance += segment
+			if dt := cur.Timestamp.Sub(prev.Timestamp).Seconds(); dt > 0 {
+				speed = segment / dt
+			}
+		}
+		maxSpeed = math.Max(maxSpeed, speed)
+		w.message(fitMesgRecord, recordFields,
+			fitTime(points[i].Timestamp),
+			fitSemicircles(points[i].Latitude),
+			fitSemicircles(points[i].Longitude),
+			fitScaled32(distance, 100),
+			fitScaled16(speed, 1000),
+		)
+	}
+
+	w.message(fitMesgEvent, eventFields, fitTime(end), byte(fitEventTimer), byte(fitEventTypeStopAll))
+
+	elapsed := end.Sub(start).Seconds()
+	var avgSpeed float64
+	if elapsed > 0 {
+		avgSpeed = distance / elapsed
+	}
+
+	w.message(fitMesgLap, []fitField{
+		{fitFieldTimestamp, 4, fitUint32}, {2, 4, fitUint32}, {7, 4, fitUint32}, {8, 4, fitUint32}, {9, 4, fitUint32},
+		{0, 1, fitEnum}, {1, 1, fitEnum},
+	}, fitTime(end), fitTime(start), fitScaled32(elapsed, 1000), fitScaled32(elapsed, 1000), fitScaled32(distance, 100),
+		byte(fitEventLap), byte(fitEventTypeStop))
+
+	w.message(fitMesgSession, []fitField{
+		{fitFieldTimestamp, 4, fitUint32}, {2, 4, fitUint32}, {7, 4, fitUint32}, {8, 4, fitUint32}, {9, 4, fitUint32},
+		{5, 1, fitEnum}, {14, 2, fitUint16}, {15, 2, fitUint16}, {25, 2, fitUint16}, {26, 2, fitUint16},
+		{0, 1, fitEnum}, {1, 1, fitEnum},
+	}, fitTime(end), fitTime(start), fitScaled32(elapsed, 1000), fitScaled32(elapsed, 1000), fitScaled32(distance, 100),
+		byte(fitSportWalking), fitScaled16(avgSpeed, 1000), fitScaled16(maxSpeed, 1000), uint16(0), uint16(1),
+		byte(fitEventSession), byte(fitEventTypeStop))
+
+	w.message(fitMesgActivity, []fitField{
+		{fitFieldTimestamp, 4, fitUint32}, {0, 4, fitUint32}, {1, 2, fitUint16}, {2, 1, fitEnum}, {3, 1, fitEnum}, {4, 1, fitEnum},
+	}, fitTime(end), fitScaled32(elapsed, 1000), uint16(1), byte(fitActivityTypeManual),
+		byte(fitEventActivity), byte(fitEventTypeStop))
+
+	return w.finish(), nil
+}
+
+// message writes a definition record the first time a global message is used,
+// then a data record with the given values, which must match fields in order.
+func (w *fitWriter) message(global uint16, fields []fitField, values ...interface{}) {
+	local, defined := w.local[global]
+	if !defined {
+		local = byte(len(w.local))
+		w.local[global] = local
+
+		w.buf.WriteByte(0x40 | local)
+		w.buf.WriteByte(0) // reserved
+		w.buf.WriteByte(0) // little-endian architecture
+		_ = binary.Write(&w.buf, binary.LittleEndian, global)
+		w.buf.WriteByte(byte(len(fields)))
+		for _, f := range fields {
+			w.buf.Write([]byte{f.num, f.size, f.baseType})
+		}
+	}
+
+	w.buf.WriteByte(local)
+	for _, v := range values {
+		_ = binary.Write(&w.buf, binary.LittleEndian, v)
+	}
+}
+
+// finish returns the complete file: header, records and trailing CRC.
+func (w *fitWriter) finish() []byte {
+	header := make([]byte, 14)
+	header[0] = 14
+	header[1] = fitProtocolVersion
+	binary.LittleEndian.PutUint16(header[2:4], fitProfileVersion)
+	binary.LittleEndian.PutUint32(header[4:8], uint32(w.buf.Len()))
+	copy(header[8:12], ".FIT")
+	binary.LittleEndian.PutUint16(header[12:14], fitCRC(0, header[:12]))
+
+	out := make([]byte, 0, len(header)+w.buf.Len()+2)
+	out = append(out, header...)
+	out = append(out, w.buf.Bytes()...)
+	crc := fitCRC(0, out)
+	return append(out, byte(crc), byte(crc>>8))
+}
+
+// fitCRC updates a FIT CRC-16 with data.
+func fitCRC(crc uint16, data []byte) uint16 {
+	for _, b := range data {
+		tmp := fitCRCTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ fitCRCTable[b&0xF]
+
+		tmp = fitCRCTable[crc&0xF]
+		crc = (crc >> 4) & 0x0FFF
+		crc = crc ^ tmp ^ fitCRCTable[(b>>4)&0xF]
+	}
+	return crc
+}
+
+// fitTime converts t to seconds since the FIT epoch.
+func fitTime(t time.Time) uint32 {
+	return uint32(t.Unix() - fitEpoch)
+}
+
+// fitSemicircles converts degrees to FIT semicircles.
+func fitSemicircles(deg float64) int32 {
+	return int32(math.Round(deg * (math.MaxInt32 + 1.0) / 180.0))
+}
+
+// fitScaled32 applies a FIT scale factor, clamping to the uint32 range.
+func fitScaled32(v, scale float64) uint32 {
+	return uint32(math.Min(math.Max(math.Round(v*scale), 0), math.MaxUint32-1))
+}
+
+// fitScaled16 applies a FIT scale factor, clamping to the uint16 range.
+func fitScaled16(v, scale float64) uint16 {
+	return uint16(math.Min(math.Max(math.Round(v*scale), 0), math.MaxUint16-1))
+}
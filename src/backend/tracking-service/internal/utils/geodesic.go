@@ -0,0 +1,597 @@
+package utils
+
+import (
+	// math for the series expansions and the Newton iteration (go1.21)
+	"math"
+)
+
+// Geodesic solves the inverse geodesic problem on an ellipsoid of revolution
+// with Karney's algorithm (C. F. F. Karney, "Algorithms for geodesics", J.
+// Geodesy 87, 43-55, 2013), accurate to about 15 nm for any pair of points,
+// including nearly antipodal ones where Vincenty's method fails to converge.
+// It is several times slower than the haversine formula, so it is meant for
+// recomputing summaries rather than for the live update path.
+type Geodesic struct {
+	a, f           float64
+	f1, e2, ep2, n float64
+	b              float64
+	etol2          float64
+	a3x            [geodesicOrder]float64
+	c3x            [geodesicOrder * (geodesicOrder - 1) / 2]float64
+}
+
+// geodesicOrder is the order of the series expansions in the flattening.
+const geodesicOrder = 6
+
+// Iteration limits and tolerances of the inverse solution.
+var (
+	geodesicMaxit1 = 20
+	geodesicMaxit2 = geodesicMaxit1 + 53 + 10
+	geodesicTiny   = math.Sqrt(0x1p-1022)
+	geodesicTol0   = 0x1p-52
+	geodesicTol1   = 200 * geodesicTol0
+	geodesicTol2   = math.Sqrt(geodesicTol0)
+	geodesicTolb   = geodesicTol0 * geodesicTol2
+	geodesicXthr   = 1000 * geodesicTol2
+)
+
+// WGS84 is the geodesic calculator on the WGS84 ellipsoid used by GPS.
+var WGS84 = NewGeodesic(6378137, 1/298.257223563)
+
+// NewGeodesic returns the geodesic calculator for the ellipsoid with equatorial
+// radius a in meters and flattening f.
+func NewGeodesic(a, f float64) *Geodesic {
+	g := &Geodesic{a: a, f: f}
+	g.f1 = 1 - f
+	g.e2 = f * (2 - f)
+	g.ep2 = g.e2 / (g.f1 * g.f1)
+	g.n = f / (2 - f)
+	g.b = a * g.f1
+	g.etol2 = 0.1 * geodesicTol2 / math.Sqrt(math.Max(0.001, math.Abs(f))*math.Min(1, 1-f/2)/2)
+	g.initA3x()
+	g.initC3x()
+	return g
+}
+
+// Distance returns the length in meters of the shortest geodesic between two
+// points given in degrees. It implements models.DistanceCalculator.
+func (g *Geodesic) Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	lon12, lon12s := angDiff(lon1, lon2)
+	lonsign := 1.0
+	if lon12 < 0 {
+		lonsign = -1
+	}
+	lon12 = lonsign * angRound(lon12)
+	lon12s = angRound((180 - lon12) - lonsign*lon12s)
+	lam12 := lon12 * math.Pi / 180
+	var slam12, clam12 float64
+	if lon12 > 90 {
+		slam12, clam12 = sincosd(lon12s)
+		clam12 = -clam12
+	} else {
+		slam12, clam12 = sincosd(lon12)
+	}
+
+	lat1 = angRound(latFix(lat1))
+	lat2 = angRound(latFix(lat2))
+	// Make lat1 the point farther from the equator, in the southern
+	// hemisphere; the distance is symmetric under both changes.
+	if math.Abs(lat1) < math.Abs(lat2) {
+		lat1, lat2 = lat2, lat1
+	}
+	if lat1 > 0 {
+		lat1, lat2 = -lat1, -lat2
+	}
+
+	sbet1, cbet1 := sincosd(lat1)
+	sbet1, cbet1 = norm(g.f1*sbet1, cbet1)
+	cbet1 = math.Max(geodesicTiny, cbet1)
+	sbet2, cbet2 := sincosd(lat2)
+	sbet2, cbet2 = norm(g.f1*sbet2, cbet2)
+	cbet2 = math.Max(geodesicTiny, cbet2)
+
+	if cbet1 < -sbet1 {
+		if cbet2 == cbet1 {
+			sbet2 = math.Copysign(sbet1, sbet2)
+		}
+	} else if math.Abs(sbet2) == -sbet1 {
+		cbet2 = cbet1
+	}
+
+	dn1 := math.Sqrt(1 + g.ep2*sbet1*sbet1)
+	dn2 := math.Sqrt(1 + g.ep2*sbet2*sbet2)
+
+	var c1a, c2a [geodesicOrder + 1]float64
+	var c3a [geodesicOrder]float64
+
+	meridian := lat1 == -90 || slam12 == 0
+	if meridian {
+		// Along a meridian the geodesic is the meridian itself, unless it
+		// passes a pole beyond a conjugate point; then fall through.
+		ssig1, csig1 := sbet1, clam12*cbet1
+		ssig2, csig2 := sbet2, cbet2
+		sig12 := math.Atan2(math.Max(0, csig1*ssig2-ssig1*csig2), csig1*csig2+ssig1*ssig2)
+		s, m12x := g.lengths(g.n, sig12, ssig1, csig1, dn1, ssig2, csig2, dn2, &c1a, &c2a)
+		if sig12 < 1 || m12x >= 0 {
+			if sig12 < 3*geodesicTiny || (sig12 < geodesicTol0 && (s < 0 || m12x < 0)) {
+				s = 0
+			}
+			return g.b * s
+		}
+		meridian = false
+	}
+
+	if !meridian && sbet1 == 0 && (g.f <= 0 || lon12s >= g.f*180) {
+		// Along the equator.
+		return g.a * lam12
+	}
+
+	sig12, salp1, calp1, dnm := g.inverseStart(sbet1, cbet1, dn1, sbet2, cbet2, dn2, lam12, slam12, clam12)
+	if sig12 >= 0 {
+		// Short lines are solved by the starting guess.
+		return sig12 * g.b * dnm
+	}
+
+	// Newton's method on alp1, bracketed by [alp1a, alp1b] with bisection
+	// as the fallback.
+	var ssig1, csig1, ssig2, csig2, eps float64
+	salp1a, calp1a := geodesicTiny, 1.0
+	salp1b, calp1b := geodesicTiny, -1.0
+	tripn, tripb := false, false
+	for numit := 0; ; numit++ {
+		var v, dv float64
+		v, _, _, sig12, ssig1, csig1, ssig2, csig2, eps, dv = g.lambda12(sbet1, cbet1, dn1, sbet2, cbet2, dn2,
+			salp1, calp1, slam12, clam12, numit < geodesicMaxit1, &c1a, &c2a, &c3a)
+		tol := geodesicTol0
+		if tripn {
+			tol *= 8
+		}
+		if tripb || !(math.Abs(v) >= tol) || numit == geodesicMaxit2 {
+			break
+		}
+		if v > 0 && (numit > geodesicMaxit1 || calp1/salp1 > calp1b/salp1b) {
+			salp1b, calp1b = salp1, calp1
+		} else if v < 0 && (numit > geodesicMaxit1 || calp1/salp1 < calp1a/salp1a) {
+			salp1a, calp1a = salp1, calp1
+		}
+		if numit < geodesicMaxit1 && dv > 0 {
+			dalp1 := -v / dv
+			if math.Abs(dalp1) < math.Pi {
+				sdalp1, cdalp1 := math.Sincos(dalp1)
+				nsalp1 := salp1*cdalp1 + calp1*sdalp1
+				if nsalp1 > 0 {
+					calp1 = calp1*cdalp1 - salp1*sdalp1
+					salp1, calp1 = norm(nsalp1, calp1)
+					tripn = math.Abs(v) <= 16*geodesicTol0
+					continue
+				}
+			}
+		}
+		salp1, calp1 = norm((salp1a+salp1b)/2, (calp1a+calp1b)/2)
+		tripn = false
+		tripb = math.Abs(salp1a-salp1)+(calp1a-calp1) < geodesicTolb ||
+			math.Abs(salp1-salp1b)+(calp1-calp1b) < geodesicTolb
+	}
+	s12x, _ := g.lengths(eps, sig12, ssig1, csig1, dn1, ssig2, csig2, dn2, &c1a, &c2a)
+	return g.b * s12x
+}
+
+// inverseStart returns a starting guess for alp1. For short lines it solves
+// the problem outright, returning sig12 >= 0 and the mean dn to scale it.
+func (g *Geodesic) inverseStart(sbet1, cbet1, dn1, sbet2, cbet2, dn2, lam12, slam12, clam12 float64) (sig12, salp1, calp1, dnm float64) {
+	sig12 = -1
+	sbet12 := sbet2*cbet1 - cbet2*sbet1
+	cbet12 := cbet2*cbet1 + sbet2*sbet1
+	sbet12a := sbet2*cbet1 + cbet2*sbet1
+	shortline := cbet12 >= 0 && sbet12 < 0.5 && cbet2*lam12 < 0.5
+	var somg12, comg12 float64
+	if shortline {
+		sbetm2 := (sbet1 + sbet2) * (sbet1 + sbet2)
+		sbetm2 /= sbetm2 + (cbet1+cbet2)*(cbet1+cbet2)
+		dnm = math.Sqrt(1 + g.ep2*sbetm2)
+		somg12, comg12 = math.Sincos(lam12 / (g.f1 * dnm))
+	} else {
+		somg12, comg12 = slam12, clam12
+	}
+
+	salp1 = cbet2 * somg12
+	if comg12 >= 0 {
+		calp1 = sbet12 + cbet2*sbet1*somg12*somg12/(1+comg12)
+	} else {
+		calp1 = sbet12a - cbet2*sbet1*somg12*somg12/(1-comg12)
+	}
+	ssig12 := math.Hypot(salp1, calp1)
+	csig12 := sbet1*sbet2 + cbet1*cbet2*comg12
+
+	switch {
+	case shortline && ssig12 < g.etol2:
+		sig12 = math.Atan2(ssig12, csig12)
+	case math.Abs(g.n) >= 0.1 || csig12 >= 0 || ssig12 >= 6*math.Abs(g.n)*math.Pi*cbet1*cbet1:
+		// Nothing to do: the zeroth order spherical guess is good enough.
+	default:
+		// Nearly antipodal points: scale to the astroid problem.
+		lam12x := math.Atan2(-slam12, -clam12)
+		k2 := sbet1 * sbet1 * g.ep2
+		eps := k2 / (2*(1+math.Sqrt(1+k2)) + k2)
+		lamscale := g.f * cbet1 * g.a3f(eps) * math.Pi
+		betscale := lamscale * cbet1
+		x := lam12x / lamscale
+		y := sbet12a / betscale
+		if y > -geodesicTol1 && x > -1-geodesicXthr {
+			salp1 = math.Min(1, -x)
+			calp1 = -math.Sqrt(1 - salp1*salp1)
+		} else {
+			k := astroid(x, y)
+			omg12a := lamscale * (-x * k / (1 + k))
+			somg12, comg12 = math.Sincos(omg12a)
+			comg12 = -comg12
+			salp1 = cbet2 * somg12
+			calp1 = sbet12a - cbet2*sbet1*somg12*somg12/(1-comg12)
+		}
+	}
+	if salp1 > 0 {
+		salp1, calp1 = norm(salp1, calp1)
+	} else {
+		salp1, calp1 = 1, 0
+	}
+	return sig12, salp1, calp1, dnm
+}
+
+// lambda12 returns the longitude difference reached by the geodesic leaving
+// point 1 at azimuth alp1, minus the target, with its derivative when diffp.
+func (g *Geodesic) lambda12(sbet1, cbet1, dn1, sbet2, cbet2, dn2, salp1, calp1, slam120, clam120 float64, diffp bool,
+	c1a, c2a *[geodesicOrder + 1]float64, c3a *[geodesicOrder]float64) (lam12, salp2, calp2, sig12, ssig1, csig1, ssig2, csig2, eps, dlam12 float64) {
+	if sbet1 == 0 && calp1 == 0 {
+		calp1 = -geodesicTiny
+	}
+	salp0 := salp1 * cbet1
+	calp0 := math.Hypot(calp1, salp1*sbet1)
+
+	ssig1 = sbet1
+	somg1 := salp0 * sbet1
+	csig1 = calp1 * cbet1
+	comg1 := csig1
+	ssig1, csig1 = norm(ssig1, csig1)
+
+	if cbet2 != cbet1 {
+		salp2 = salp0 / cbet2
+	} else {
+		salp2 = salp1
+	}
+	if cbet2 != cbet1 || math.Abs(sbet2) != -sbet1 {
+		var d float64
+		if cbet1 < -sbet1 {
+			d = (cbet2 - cbet1) * (cbet1 + cbet2)
+		} else {
+			d = (sbet1 - sbet2) * (sbet1 + sbet2)
+		}
+		calp2 = math.Sqrt(calp1*cbet1*calp1*cbet1+d) / cbet2
+	} else {
+		calp2 = math.Abs(calp1)
+	}
+
+	ssig2 = sbet2
+	somg2 := salp0 * sbet2
+	csig2 = calp2 * cbet2
+	comg2 := csig2
+	ssig2, csig2 = norm(ssig2, csig2)
+
+	sig12 = math.Atan2(math.Max(0, csig1*ssig2-ssig1*csig2), csig1*csig2+ssig1*ssig2)
+	somg12 := math.Max(0, comg1*somg2-somg1*comg2)
+	comg12 := comg1*comg2 + somg1*somg2
+	eta := math.Atan2(somg12*clam120-comg12*slam120, comg12*clam120+somg12*slam120)
+
+	k2 := calp0 * calp0 * g.ep2
+	eps = k2 / (2*(1+math.Sqrt(1+k2)) + k2)
+	g.c3f(eps, c3a)
+	b312 := sinCosSeries(true, ssig2, csig2, c3a[:]) - sinCosSeries(true, ssig1, csig1, c3a[:])
+	domg12 := -g.f * g.a3f(eps) * salp0 * (sig12 + b312)
+	lam12 = eta + domg12
+
+	if diffp {
+		if calp2 == 0 {
+			dlam12 = -2 * g.f1 * dn1 / sbet1
+		} else {
+			_, m12b := g.lengths(eps, sig12, ssig1, csig1, dn1, ssig2, csig2, dn2, c1a, c2a)
+			dlam12 = m12b * g.f1 / (calp2 * cbet2)
+		}
+	} else {
+		dlam12 = math.NaN()
+	}
+	return
+}
+
+// lengths returns the distance and the reduced length of the geodesic over
+// sig12, both scaled to b = 1.
+func (g *Geodesic) lengths(eps, sig12, ssig1, csig1, dn1, ssig2, csig2, dn2 float64,
+	c1a, c2a *[geodesicOrder + 1]float64) (s12b, m12b float64) {
+	a1 := a1m1f(eps)
+	c1f(eps, c1a)
+	a2 := a2m1f(eps)
+	c2f(eps, c2a)
+	m0x := a1 - a2
+	a1, a2 = 1+a1, 1+a2
+
+	b1 := sinCosSeries(true, ssig2, csig2, c1a[:]) - sinCosSeries(true, ssig1, csig1, c1a[:])
+	s12b = a1 * (sig12 + b1)
+	b2 := sinCosSeries(true, ssig2, csig2, c2a[:]) - sinCosSeries(true, ssig1, csig1, c2a[:])
+	j12 := m0x*sig12 + (a1*b1 - a2*b2)
+	m12b = dn2*(csig1*ssig2) - dn1*(ssig1*csig2) - csig1*csig2*j12
+	return s12b, m12b
+}
+
+// initA3x computes the coefficients of A3 in eps for the ellipsoid.
+func (g *Geodesic) initA3x() {
+	coeff := []float64{
+		-3, 128,
+		-2, -3, 64,
+		-1, -3, -1, 16,
+		3, -1, -2, 8,
+		1, -1, 2,
+		1, 1,
+	}
+	o, k := 0, 0
+	for j := geodesicOrder - 1; j >= 0; j-- {
+		m := min(geodesicOrder-j-1, j)
+		g.a3x[k] = polyval(m, coeff[o:], g.n) / coeff[o+m+1]
+		k++
+		o += m + 2
+	}
+}
+
+// initC3x computes the coefficients of C3 in eps for the ellipsoid.
+func (g *Geodesic) initC3x() {
+	coeff := []float64{
+		3, 128,
+		2, 5, 128,
+		-1, 3, 3, 64,
+		-1, 0, 1, 8,
+		-1, 1, 4,
+		5, 256,
+		1, 3, 128,
+		-3, -2, 3, 64,
+		1, -3, 2, 32,
+		7, 512,
+		-10, 9, 384,
+		5, -9, 5, 192,
+		7, 512,
+		-14, 7, 512,
+		21, 2560,
+	}
+	o, k := 0, 0
+	for l := 1; l < geodesicOrder; l++ {
+		for j := geodesicOrder - 1; j >= l; j-- {
+			m := min(geodesicOrder-j-1, j)
+			g.c3x[k] = polyval(m, coeff[o:], g.n) / coeff[o+m+1]
+			k++
+			o += m + 2
+		}
+	}
+}
+
+// a3f evaluates A3 at eps.
+func (g *Geodesic) a3f(eps float64) float64 {
+	return polyval(geodesicOrder-1, g.a3x[:], eps)
+}
+
+// c3f evaluates the C3 coefficients at eps into c[1:].
+func (g *Geodesic) c3f(eps float64, c *[geodesicOrder]float64) {
+	mult := 1.0
+	o := 0
+	for l := 1; l < geodesicOrder; l++ {
+		m := geodesicOrder - l - 1
+		mult *= eps
+		c[l] = mult * polyval(m, g.c3x[o:], eps)
+		o += m + 1
+	}
+}
+
+// a1m1f evaluates A1 - 1 at eps.
+func a1m1f(eps float64) float64 {
+	coeff := []float64{1, 4, 64, 0, 256}
+	m := geodesicOrder / 2
+	t := polyval(m, coeff, eps*eps) / coeff[m+1]
+	return (t + eps) / (1 - eps)
+}
+
+// c1f evaluates the C1 coefficients at eps into c[1:].
+func c1f(eps float64, c *[geodesicOrder + 1]float64) {
+	coeff := []float64{
+		-1, 6, -16, 32,
+		-9, 64, -128, 2048,
+		9, -16, 768,
+		3, -5, 512,
+		-7, 1280,
+		-7, 2048,
+	}
+	seriesCoefficients(eps, coeff, c)
+}
+
+// a2m1f evaluates A2 - 1 at eps.
+func a2m1f(eps float64) float64 {
+	coeff := []float64{-11, -28, -192, 0, 256}
+	m := geodesicOrder / 2
+	t := polyval(m, coeff, eps*eps) / coeff[m+1]
+	return (t - eps) / (1 + eps)
+}
+
+// c2f evaluates the C2 coefficients at eps into c[1:].
+func c2f(eps float64, c *[geodesicOrder + 1]float64) {
+	coeff := []float64{
+		1, 2, 16, 32,
+		35, 64, 384, 2048,
+		15, 80, 768,
+		7, 35, 512,
+		63, 1280,
+		77, 2048,
+	}
+	seriesCoefficients(eps, coeff, c)
+}
+
+// seriesCoefficients evaluates the packed polynomials in eps² of coeff,
+// scaled by successive powers of eps, into c[1:].
+func seriesCoefficients(eps float64, coeff []float64, c *[geodesicOrder + 1]float64) {
+	eps2 := eps * eps
+	d := eps
+	o := 0
+	for l := 1; l <= geodesicOrder; l++ {
+		m := (geodesicOrder - l) / 2
+		c[l] = d * polyval(m, coeff[o:], eps2) / coeff[o+m+1]
+		o += m + 2
+		d *= eps
+	}
+}
+
+// sinCosSeries evaluates the Fourier series with coefficients c[1:] at x by
+// Clenshaw summation: the sine series when sinp, the cosine series otherwise.
+func sinCosSeries(sinp bool, sinx, cosx float64, c []float64) float64 {
+	k := len(c)
+	n := k
+	if sinp {
+		n--
+	}
+	ar := 2 * (cosx - sinx) * (cosx + sinx)
+	var y0, y1 float64
+	if n&1 != 0 {
+		k--
+		y0 = c[k]
+	}
+	for n /= 2; n > 0; n-- {
+		k--
+		y1 = ar*y0 - y1 + c[k]
+		k--
+		y0 = ar*y1 - y0 + c[k]
+	}
+	if sinp {
+		return 2 * sinx * cosx * y0
+	}
+	return cosx * (y0 - y1)
+}
+
+// astroid solves k⁴ + 2k³ - (x² + y² - 1)k² - 2y²k - y² = 0 for its positive
+// root, giving the starting guess for nearly antipodal points.
+func astroid(x, y float64) float64 {
+	p := x * x
+	q := y * y
+	r := (p + q - 1) / 6
+	if q == 0 && r <= 0 {
+		return 0
+	}
+	s := p * q / 4
+	r2 := r * r
+	r3 := r * r2
+	disc := s * (s + 2*r3)
+	u := r
+	if disc >= 0 {
+		t3 := s + r3
+		if t3 < 0 {
+			t3 -= math.Sqrt(disc)
+		} else {
+			t3 += math.Sqrt(disc)
+		}
+		t := math.Cbrt(t3)
+		u += t
+		if t != 0 {
+			u += r2 / t
+		}
+	} else {
+		ang := math.Atan2(math.Sqrt(-disc), -(s + r3))
+		u += 2 * r * math.Cos(ang/3)
+	}
+	v := math.Sqrt(u*u + q)
+	var uv float64
+	if u < 0 {
+		uv = q / (v - u)
+	} else {
+		uv = u + v
+	}
+	w := (uv - q) / (2 * v)
+	return uv / (math.Sqrt(uv+w*w) + w)
+}
+
+// polyval evaluates the polynomial of degree n with coefficients p[0:n+1],
+// highest first, at x.
+func polyval(n int, p []float64, x float64) float64 {
+	if n < 0 {
+		return 0
+	}
+	y := p[0]
+	for i := 1; i <= n; i++ {
+		y = y*x + p[i]
+	}
+	return y
+}
+
+// sumErr returns u + v and the rounding error of the sum.
+func sumErr(u, v float64) (float64, float64) {
+	s := u + v
+	up := s - v
+	vpp := s - up
+	up -= u
+	vpp -= v
+	return s, -(up + vpp)
+}
+
+// angDiff returns lon2 - lon1 reduced to [-180, 180], exactly, as the sum of
+// a rounded difference and its error.
+func angDiff(x, y float64) (float64, float64) {
+	d, t := sumErr(math.Remainder(-x, 360), math.Remainder(y, 360))
+	d, t = sumErr(math.Remainder(d, 360), t)
+	if d == 0 || math.Abs(d) == 180 {
+		if t == 0 {
+			d = math.Copysign(d, y-x)
+		} else {
+			d = math.Copysign(d, -t)
+		}
+	}
+	return d, t
+}
+
+// angRound rounds tiny angles to zero so that the algorithm is symmetric in
+// its inputs.
+func angRound(x float64) float64 {
+	const z = 1.0 / 16
+	y := math.Abs(x)
+	if y < z {
+		y = z - (z - y)
+	}
+	return math.Copysign(y, x)
+}
+
+// latFix returns NaN for latitudes beyond the poles.
+func latFix(lat float64) float64 {
+	if math.Abs(lat) > 90 {
+		return math.NaN()
+	}
+	return lat
+}
+
+// sincosd returns the sine and cosine of x degrees, exact at multiples of 90.
+func sincosd(x float64) (float64, float64) {
+	r := math.Mod(x, 360)
+	q := 0
+	if !math.IsNaN(r) {
+		q = int(math.RoundToEven(r / 90))
+	}
+	r -= 90 * float64(q)
+	s, c := math.Sincos(r * math.Pi / 180)
+	switch ((q % 4) + 4) % 4 {
+	case 1:
+		s, c = c, -s
+	case 2:
+		s, c = -s, -c
+	case 3:
+		s, c = -c, s
+	}
+	c += 0
+	if s == 0 {
+		s = math.Copysign(s, x)
+	}
+	return s, c
+}
+
+// norm scales (x, y) to a unit vector.
+func norm(x, y float64) (float64, float64) {
+	r := math.Hypot(x, y)
+	return x / r, y / r
+}
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	// xml provides the GPX document encoding (go1.21)
+	"encoding/xml"
+	// errors provides encoding validation failures (go1.21)
+	"errors"
+	// strconv provides compact number formatting (go1.21)
+	"strconv"
+	// time provides GPX timestamp formatting (go1.21)
+	"time"
+
+	// models provides the Location struct used for GPS coordinate representations
+	"src/backend/tracking-service/internal/models"
+)
+
+// GPXContentType is the MIME type used when serving GPX files.
+const GPXContentType = "application/gpx+xml"
+
+// gpxNamespace is the GPX 1.1 schema namespace.
+const gpxNamespace = "http://www.topografix.com/GPX/1/1"
+
+// gpxSchemaLocation pairs the GPX namespace with its XSD for validators.
+const gpxSchemaLocation = gpxNamespace + " http://www.topografix.com/GPX/1/1/gpx.xsd"
+
+// GPXExtensionNamespace qualifies the per-point extension elements carrying
+// what GPX has no field for: the fix accuracy in meters and its provider.
+const GPXExtensionNamespace = "urn:dogwalking:gpx:tracking:1"
+
+// gpxCreator is the creator attribute GPX 1.1 requires on the root element.
+const gpxCreator = "dog-walking tracking-service"
+
+// gpxDocument is the GPX 1.1 root element.
+type gpxDocument struct {
+	XMLName        xml.Name    `xml:"gpx"`
+	Version        string      `xml:"version,attr"`
+	Creator        string      `xml:"creator,attr"`
+	Xmlns          string      `xml:"xmlns,attr"`
+	XmlnsXSI       string      `xml:"xmlns:xsi,attr"`
+	XmlnsExt       string      `xml:"xmlns:dw,attr"`
+	SchemaLocation string      `xml:"xsi:schemaLocation,attr"`
+	Metadata       gpxMetadata `xml:"metadata"`
+	Track          gpxTrack    `xml:"trk"`
+}
+
+// gpxMetadata names the file and the time it describes.
+type gpxMetadata struct {
+	Name string `xml:"name"`
+	Time string `xml:"time"`
+}
+
+// gpxTrack is the walk as a single track.
+type gpxTrack struct {
+	Name    string          `xml:"name"`
+	Type    string          `xml:"type"`
+	Segment gpxTrackSegment `xml:"trkseg"`
+}
+
+// gpxTrackSegment holds the walk's points in time order.
+type gpxTrackSegment struct {
+	Points []gpxTrackPoint `xml:"trkpt"`
+}
+
+// gpxTrackPoint is one fix. GPX 1.1 orders ele before time before extensions.
+type gpxTrackPoint struct {
+	Lat        string        `xml:"lat,attr"`
+	Lon        string        `xml:"lon,attr"`
+	Ele        string        `xml:"ele"`
+	Time       string        `xml:"time"`
+	Extensions gpxExtensions `xml:"extensions"`
+}
+
+// gpxExtensions carries the point's accuracy and provider.
+type gpxExtensions struct {
+	Accuracy string `xml:"dw:accuracy"`
+	Provider string `xml:"dw:provider,omitempty"`
+}
+
+// EncodeWalkGPX encodes a walk's track, in chronological order, as a GPX 1.1
+// document with one track segment. Each point carries its elevation and UTC
+// timestamp, and its accuracy in meters and provider as extensions in the
+// GPXExtensionNamespace, which importers that do not know it ignore.
+func EncodeWalkGPX(name string, track []models.Location) ([]byte, error) {
+	if len(track) == 0 {
+		return nil, errors.New("gpx: track has no points")
+	}
+
+	doc := gpxDocument{
+		Version:        "1.1",
+		Creator:        gpxCreator,
+		Xmlns:          gpxNamespace,
+		XmlnsXSI:       "http://www.w3.org/2001/XMLSchema-instance",
+		XmlnsExt:       GPXExtensionNamespace,
+		SchemaLocation: gpxSchemaLocation,
+		Metadata: gpxMetadata{
+			Name: name,
+			Time: gpxTime(track[0].Timestamp),
+		},
+		Track: gpxTrack{
+			Name: name,
+			Type: "walking",
+			Segment: gpxTrackSegment{
+				Points: make([]gpxTrackPoint, 0, len(track)),
+			},
+		},
+	}
+	for _, loc := range track {
+		doc.Track.Segment.Points = append(doc.Track.Segment.Points, gpxTrackPoint{
+			Lat:  gpxDegrees(loc.Latitude),
+			Lon:  gpxDegrees(loc.Longitude),
+			Ele:  strconv.FormatFloat(loc.Altitude, 'f', -1, 64),
+			Time: gpxTime(loc.Timestamp),
+			Extensions: gpxExtensions{
+				Accuracy: strconv.FormatFloat(loc.Accuracy, 'f', -1, 64),
+				Provider: loc.Provider,
+			},
+		})
+	}
+
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(body, '\n')...), nil
+}
+
+// gpxDegrees formats a coordinate in decimal degrees, as GPX requires.
+func gpxDegrees(deg float64) string {
+	return strconv.FormatFloat(deg, 'f', -1, 64)
+}
+
+// gpxTime formats a timestamp as the UTC xsd:dateTime GPX expects.
+func gpxTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
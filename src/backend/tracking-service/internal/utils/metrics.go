@@ -0,0 +1,134 @@
+package utils
+
+import (
+	// fmt go1.21 for building topic templates from the topic formats
+	"fmt"
+
+	// log go1.21 for reporting label overflow, consistent with the MQTT wrapper
+	"log"
+
+	// strings go1.21 for topic prefix matching
+	"strings"
+
+	// sync go1.21 for guarding the set of observed label values
+	"sync"
+)
+
+// ---------------------------------------------------------------------
+// Global Constants
+// ---------------------------------------------------------------------
+
+// DefaultMaxLabelValues is the default cap on distinct values per guarded label.
+const DefaultMaxLabelValues = 100
+
+// OverflowLabelValue replaces label values beyond a LabelGuard's cap.
+const OverflowLabelValue = "__overflow__"
+
+// OtherTopicClass is the class reported for topics that match no known template.
+const OtherTopicClass = "other"
+
+// topicClasses maps per-session topic prefixes onto their templates, using the
+// MQTT single-level wildcard in place of the session ID.
+var topicClasses = []struct {
+	prefix string
+	class  string
+}{
+	{strings.TrimSuffix(TopicLocationUpdate, "%s"), fmt.Sprintf(TopicLocationUpdate, "+")},
+	{strings.TrimSuffix(TopicSessionControl, "%s"), fmt.Sprintf(TopicSessionControl, "+")},
+	{"service/heartbeat", "service/heartbeat"},
+}
+
+// TopicClass collapses a concrete MQTT topic into its bounded template, e.g.
+// "walks/location/abc123" becomes "walks/location/+". Unknown topics map to
+// OtherTopicClass so they can never grow the label set.
+func TopicClass(topic string) string {
+	for _, tc := range topicClasses {
+		if strings.HasPrefix(topic, tc.prefix) {
+			return tc.class
+		}
+	}
+	return OtherTopicClass
+}
+
+// ---------------------------------------------------------------------
+// LabelGuard Struct
+// ---------------------------------------------------------------------
+// LabelGuard caps the number of distinct values a metric label may take. Once
+// the cap is reached, new values are reported as OverflowLabelValue while
+// values seen earlier keep their own series.
+type LabelGuard struct {
+	mu     sync.Mutex
+	name   string
+	seen   map[string]struct{}
+	max    int
+	warned bool
+}
+
+// NewLabelGuard creates a guard for the named label allowing at most max
+// distinct values. A non-positive max falls back to DefaultMaxLabelValues.
+func NewLabelGuard(name string, max int) *LabelGuard {
+	if max <= 0 {
+		max = DefaultMaxLabelValues
+	}
+	return &LabelGuard{
+		name: name,
+		seen: make(map[string]struct{}),
+		max:  max,
+	}
+}
+
+// Value returns value if it is already tracked or the cap has room for it, and
+// OverflowLabelValue otherwise. The first overflow is logged once.
+func (g *LabelGuard) Value(value string) string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.seen[value]; ok {
+		return value
+	}
+	if len(g.seen) < g.max {
+		g.seen[value] = struct{}{}
+		return value
+	}
+	if !g.warned {
+		g.warned = true
+		log.Printf("[Metrics] Label %q reached its cap of %d values; further values are reported as %s\n", g.name, g.max, OverflowLabelValue)
+	}
+	return OverflowLabelValue
+}
+
+// ---------------------------------------------------------------------
+// TopicLabeler Struct
+// ---------------------------------------------------------------------
+// TopicLabeler produces the "topic" label for MQTT metrics. In the default mode
+// it reports topic templates; in high-cardinality debug mode it reports raw
+// topics. Both modes pass through a LabelGuard.
+type TopicLabeler struct {
+	highCardinality bool
+	templates       []string
+	guard           *LabelGuard
+}
+
+// NewTopicLabeler creates a labeler for the given mode and value cap.
+// templates are topic filters, e.g. "sessions/+/uploads", reported for the
+// topics they match ahead of the built-in topic classes.
+func NewTopicLabeler(highCardinality bool, maxValues int, templates ...string) *TopicLabeler {
+	return &TopicLabeler{
+		highCardinality: highCardinality,
+		templates:       templates,
+		guard:           NewLabelGuard("topic", maxValues),
+	}
+}
+
+// Label returns the bounded label value for topic.
+func (l *TopicLabeler) Label(topic string) string {
+	if l.highCardinality {
+		return l.guard.Value(topic)
+	}
+	for _, template := range l.templates {
+		if TopicFilterMatches(template, topic) {
+			return l.guard.Value(template)
+		}
+	}
+	return l.guard.Value(TopicClass(topic))
+}
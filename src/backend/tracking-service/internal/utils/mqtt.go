@@ -1,12 +1,22 @@
 package utils
 
 import (
-	// github.com/eclipse/paho.mqtt.golang v1.4.3 for MQTT client library
-	mqtt "github.com/eclipse/paho.mqtt.golang"
+	// github.com/eclipse/paho.golang v0.21.0 for the MQTT 5 client library
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
+
+	// context go1.21 for bounding broker round trips
+	"context"
 
 	// encoding/json go1.21 for JSON encoding/decoding
 	"encoding/json"
 
+	// math go1.21 for clamping message expiry intervals
+	"math"
+
+	// net/url go1.21 for the broker address
+	"net/url"
+
 	// time go1.21 for handling timeouts and intervals
 	"time"
 
@@ -22,6 +32,7 @@ import (
 	"strings"
 	"fmt"
 	"log"
+	"sort"
 )
 
 // ---------------------------------------------------------------------
@@ -34,8 +45,35 @@ const TopicLocationUpdate = "walks/location/%s"
 // TopicSessionControl is the format string for session control topics.
 const TopicSessionControl = "walks/control/%s"
 
-// QosLevel defines the MQTT QoS level for guaranteed message delivery.
-const QosLevel = 1
+// MessageClass groups the MQTT messages published with the same QoS level,
+// configured per class in config.MQTTQoSConfig.
+type MessageClass string
+
+// Message classes: location updates, session control, alerts and walk
+// summaries.
+const (
+	MessageClassLocation MessageClass = "location"
+	MessageClassControl  MessageClass = "control"
+	MessageClassAlert    MessageClass = "alert"
+	MessageClassSummary  MessageClass = "summary"
+)
+
+// QoS returns the level messages of class c are published with under cfg.
+// Unknown classes use the highest configured level.
+func (c MessageClass) QoS(cfg config.MQTTQoSConfig) byte {
+	switch c {
+	case MessageClassLocation:
+		return byte(cfg.Location)
+	case MessageClassControl:
+		return byte(cfg.Control)
+	case MessageClassAlert:
+		return byte(cfg.Alert)
+	case MessageClassSummary:
+		return byte(cfg.Summary)
+	default:
+		return byte(cfg.Max())
+	}
+}
 
 // MaxRetryAttempts is the maximum number of connection retry attempts.
 const MaxRetryAttempts = 3
@@ -43,6 +81,49 @@ const MaxRetryAttempts = 3
 // RetryBackoffInterval is the interval between retry attempts.
 const RetryBackoffInterval = 5 * time.Second
 
+// mqttReasonFailure is the lowest MQTT 5 reason code reporting a failure.
+const mqttReasonFailure = 0x80
+
+// SharedSubscriptionPrefix starts the filter of an MQTT 5 shared subscription,
+// followed by the share group and the topic filter.
+const SharedSubscriptionPrefix = "$share/"
+
+// SharedSubscription returns the filter subscribing to filter as a member of
+// the share group, so each matching message reaches one member of the group.
+func SharedSubscription(group, filter string) string {
+	return SharedSubscriptionPrefix + group + "/" + filter
+}
+
+// PrefixTopic prepends the prefix topic levels to topic. An empty prefix
+// leaves topic unchanged.
+func PrefixTopic(prefix, topic string) string {
+	if prefix == "" {
+		return topic
+	}
+	return prefix + "/" + topic
+}
+
+// TopicFilterMatches reports whether filter matches topic under MQTT wildcard
+// rules: "+" matches exactly one level and a trailing "#" any remaining
+// levels. Topic may itself be a filter, whose wildcards are then matched as
+// ordinary levels, so "sessions/+/uploads" matches itself.
+func TopicFilterMatches(filter, topic string) bool {
+	filterLevels := strings.Split(filter, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, level := range filterLevels {
+		if level == "#" && i == len(filterLevels)-1 {
+			return true
+		}
+		if i >= len(topicLevels) {
+			return false
+		}
+		if level != "+" && level != topicLevels[i] {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
+
 // ---------------------------------------------------------------------
 // MQTTClient Struct
 // ---------------------------------------------------------------------
@@ -50,8 +131,19 @@ const RetryBackoffInterval = 5 * time.Second
 // enhanced session management, monitoring, and error recovery
 // capabilities for real-time location tracking communication.
 type MQTTClient struct {
-	// client holds the underlying MQTT client instance.
-	client mqtt.Client
+	// client holds the underlying MQTT 5 connection manager, created by
+	// Connect from clientConfig. It reconnects on its own.
+	client       *autopaho.ConnectionManager
+	clientConfig autopaho.ClientConfig
+	brokerURI    string
+	cancel       context.CancelFunc
+
+	// routes maps the topic filters subscribed to their message handlers,
+	// which are subscribed again whenever the connection comes up, and
+	// routeClasses to the class of the messages they carry.
+	routeMu      sync.RWMutex
+	routes       map[string]func(*paho.Publish)
+	routeClasses map[string]MessageClass
 
 	// activeSessions maintains references to active tracking sessions,
 	// keyed by session ID for quick lookup and thread-safe access.
@@ -64,9 +156,23 @@ type MQTTClient struct {
 	// such as publishes and received messages, for Prometheus.
 	messageMetrics *prometheus.CounterVec
 
-	// connectionWg is used to coordinate shutdown sequences and wait
-	// for any ongoing routines to complete before disconnecting.
-	connectionWg *sync.WaitGroup
+	// topicLabels bounds the cardinality of the messageMetrics "topic" label.
+	topicLabels *TopicLabeler
+
+	// dispatcher runs per-session message handling off paho's router
+	// goroutines, preserving per-session ordering.
+	dispatcher *SessionDispatcher
+
+	// reprojector converts locations declared in other reference systems to WGS84.
+	reprojector *Reprojector
+
+	// diagnostics captures a bundle for each message handler that panics
+	// and traces handled messages (nil only logs panics).
+	diagnostics *DiagnosticsRecorder
+
+	// breaker guards location publishes, queueing those the broker cannot
+	// take instead of retrying them on the publisher's goroutine.
+	breaker *PublishBreaker
 }
 
 // ---------------------------------------------------------------------
@@ -77,9 +183,9 @@ type MQTTClient struct {
 //
 // Steps:
 //   1. Initialize Prometheus metrics collectors.
-//   2. Create MQTT client options with TLS and authentication settings.
-//   3. Configure automatic reconnection with exponential backoff.
-//   4. Set up connection and default message handlers.
+//   2. Create MQTT 5 connection settings with TLS and authentication.
+//   3. Configure automatic reconnection, restoring subscriptions.
+//   4. Route incoming messages to their subscription handlers.
 //   5. Initialize thread-safe session management.
 //   6. Create and return an MQTTClient instance with monitoring.
 func NewMQTTClient(cfg *config.Config) *MQTTClient {
@@ -89,72 +195,82 @@ func NewMQTTClient(cfg *config.Config) *MQTTClient {
 	metrics := prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "mqtt_message_counts",
-			Help: "Track the number of MQTT messages published and received.",
+			Help: "Track the number of MQTT messages published, received, and dropped, by topic template.",
 		},
 		[]string{"direction", "topic"},
 	)
 	// Register the CounterVec with the default Prometheus registry
 	prometheus.MustRegister(metrics)
 
+	// Publishes go through a circuit breaker, whose metrics are registered
+	// with the default registry as well.
+	breaker := NewPublishBreaker(cfg.MQTT, nil, nil)
+	prometheus.MustRegister(breaker.collectors()...)
+
+	// Topics embed session IDs, so label with topic templates (or guarded raw
+	// topics in high-cardinality debug mode) to keep the series count bounded.
+	topicLabels := NewTopicLabeler(cfg.Metrics.HighCardinality, cfg.Metrics.MaxLabelValues)
+
 	// -----------------------------------------------------------------
-	// 2. Create MQTT client options
+	// 2. Create MQTT 5 connection settings
 	// -----------------------------------------------------------------
-	opts := mqtt.NewClientOptions()
 	mqttCfg := cfg.MQTT
 	brokerURI := fmt.Sprintf("tcp://%s:%d", mqttCfg.Host, mqttCfg.Port)
 	if mqttCfg.TLSEnabled {
 		brokerURI = fmt.Sprintf("ssl://%s:%d", mqttCfg.Host, mqttCfg.Port)
 	}
-	opts.AddBroker(brokerURI)
-	opts.SetClientID("tracking-service-client-" + fmt.Sprint(time.Now().UnixNano()))
-	if mqttCfg.Username != "" {
-		opts.SetUsername(mqttCfg.Username)
-	}
-	if mqttCfg.Password != "" {
-		opts.SetPassword(mqttCfg.Password)
-	}
-	opts.SetKeepAlive(mqttCfg.KeepAlive)
-	opts.SetConnectTimeout(mqttCfg.ConnectionTimeout)
-	opts.SetAutoReconnect(false) // We'll implement retries ourselves.
 
-	// -----------------------------------------------------------------
-	// 3. Configure optional reconnect logic
-	//    (We'll handle a manual approach in Connect() to control backoff.)
-	// -----------------------------------------------------------------
+	wrapper := &MQTTClient{
+		brokerURI:      brokerURI,
+		routes:         make(map[string]func(*paho.Publish)),
+		routeClasses:   make(map[string]MessageClass),
+		config:         cfg,
+		messageMetrics: metrics,
+		topicLabels:    topicLabels,
+		dispatcher:     NewSessionDispatcher(mqttCfg.DispatchWorkers, mqttCfg.DispatchQueueSize),
+		reprojector:    NewReprojector(),
+		breaker:        breaker,
+	}
 
 	// -----------------------------------------------------------------
-	// 4. Setup default message handler callbacks
-	//    For unrecognized topics, we can have a fallback handler,
-	//    though session-based subscriptions override these for specific topics.
+	// 3. Configure reconnection: the connection manager retries every
+	//    RetryInterval, and each new connection restores the
+	//    subscriptions, as it starts without a broker session.
 	// -----------------------------------------------------------------
-	opts.SetDefaultPublishHandler(func(client mqtt.Client, msg mqtt.Message) {
-		log.Printf("[MQTTClient] Received message on unhandled topic %s\n", msg.Topic())
-		metrics.WithLabelValues("received", msg.Topic()).Inc()
-	})
+	wrapper.clientConfig = autopaho.ClientConfig{
+		KeepAlive:                     uint16(mqttCfg.KeepAlive.Seconds()),
+		CleanStartOnInitialConnection: true,
+		ConnectRetryDelay:             mqttCfg.RetryInterval,
+		ConnectTimeout:                mqttCfg.ConnectionTimeout,
+		ConnectUsername:               mqttCfg.Username,
+		ConnectPassword:               []byte(mqttCfg.Password),
+		OnConnectionUp: func(*autopaho.ConnectionManager, *paho.Connack) {
+			log.Println("[MQTTClient] Connection to MQTT broker is up.")
+			go wrapper.resubscribe()
+		},
+		OnConnectError: func(err error) {
+			log.Printf("[MQTTClient] Connection attempt failed: %v\n", err)
+		},
+		// -------------------------------------------------------------
+		// 4. Route incoming messages. Handlers run in arrival order on
+		//    the client's goroutine; they only enqueue onto the session
+		//    dispatcher, so they return quickly and order stays per-topic.
+		// -------------------------------------------------------------
+		ClientConfig: paho.ClientConfig{
+			ClientID: "tracking-service-client-" + fmt.Sprint(time.Now().UnixNano()),
+			OnPublishReceived: []func(paho.PublishReceived) (bool, error){
+				func(received paho.PublishReceived) (bool, error) {
+					wrapper.route(received.Packet)
+					return true, nil
+				},
+			},
+		},
+	}
 
 	// -----------------------------------------------------------------
 	// 5. Initialize thread-safe session management
 	// -----------------------------------------------------------------
-	sessionMap := &sync.Map{}
-
-	// Using a WaitGroup for any background routines or graceful shutdown
-	wg := &sync.WaitGroup{}
-
-	// -----------------------------------------------------------------
-	// Create the underlying client from the options
-	// -----------------------------------------------------------------
-	mqttClient := mqtt.NewClient(opts)
-
-	// -----------------------------------------------------------------
-	// Build the wrapper struct
-	// -----------------------------------------------------------------
-	wrapper := &MQTTClient{
-		client:         mqttClient,
-		activeSessions: sessionMap,
-		config:         cfg,
-		messageMetrics: metrics,
-		connectionWg:   wg,
-	}
+	wrapper.activeSessions = &sync.Map{}
 
 	return wrapper
 }
@@ -166,28 +282,39 @@ func NewMQTTClient(cfg *config.Config) *MQTTClient {
 // mechanism.
 //
 // Steps:
-//   1. Attempt initial connection to MQTT broker.
-//   2. Implement retry logic with exponential backoff if connection fails.
-//   3. Wait for connection confirmation.
+//   1. Start the connection manager for the broker.
+//   2. Wait for the connection, with backoff between attempts.
+//   3. Give up, stopping the connection manager, after MaxRetryAttempts.
 //   4. Subscribe to any required system topics (if needed) with QoS.
-//   5. Initialize a health check routine.
-//   6. Return connection status (error if connection fails).
+//   5. Return connection status (error if connection fails).
+//
+// Once connected, the connection manager reconnects on its own.
 func (mc *MQTTClient) Connect() error {
-	var attempt int
-	var err error
+	// 1. Start the connection manager
+	serverURL, err := url.Parse(mc.brokerURI)
+	if err != nil {
+		return fmt.Errorf("invalid MQTT broker address %q: %w", mc.brokerURI, err)
+	}
+	clientConfig := mc.clientConfig
+	clientConfig.ServerUrls = []*url.URL{serverURL}
+	ctx, cancel := context.WithCancel(context.Background())
+	client, err := autopaho.NewConnection(ctx, clientConfig)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to start MQTT connection: %w", err)
+	}
+	mc.client, mc.cancel = client, cancel
 
+	// 2. Wait for the connection
+	var attempt int
 	for attempt = 1; attempt <= MaxRetryAttempts; attempt++ {
-		token := mc.client.Connect()
-		// Wait until the connection attempt finishes
-		token.Wait()
-		if token.Error() == nil {
+		err = mc.await(mc.config.MQTT.ConnectionTimeout)
+		if err == nil {
 			// Successfully connected
 			log.Printf("[MQTTClient] Successfully connected on attempt #%d\n", attempt)
-			err = nil
 			break
 		}
 		// Connection attempt failed
-		err = token.Error()
 		log.Printf("[MQTTClient] Connection attempt #%d failed: %v\n", attempt, err)
 
 		// Exponential backoff
@@ -195,7 +322,9 @@ func (mc *MQTTClient) Connect() error {
 		time.Sleep(sleepDuration)
 	}
 
+	// 3. Give up
 	if err != nil {
+		cancel()
 		return fmt.Errorf("failed to connect to MQTT broker after %d attempts: %w", MaxRetryAttempts, err)
 	}
 
@@ -211,71 +340,146 @@ func (mc *MQTTClient) Connect() error {
 	// to log heartbeat messages. We do not raise an error if it fails,
 	// but we log it for debugging.
 	sysTopic := "service/heartbeat"
-	subToken := mc.client.Subscribe(sysTopic, byte(QosLevel), func(client mqtt.Client, msg mqtt.Message) {
-		mc.messageMetrics.WithLabelValues("received", msg.Topic()).Inc()
-		log.Printf("[MQTTClient] Heartbeat message: %s\n", string(msg.Payload()))
-	})
-	subToken.Wait()
-	if subToken.Error() != nil {
-		log.Printf("[MQTTClient] Failed to subscribe to system topic %s: %v\n", sysTopic, subToken.Error())
+	if err := mc.subscribe(sysTopic, MessageClassControl, func(msg *paho.Publish) {
+		mc.messageMetrics.WithLabelValues("received", mc.topicLabels.Label(msg.Topic)).Inc()
+		log.Printf("[MQTTClient] Heartbeat message: %s\n", string(msg.Payload))
+	}); err != nil {
+		log.Printf("[MQTTClient] Failed to subscribe to system topic %s: %v\n", sysTopic, err)
 	}
 
-	// -----------------------------------------------------------------
-	// 5. Initialize a health check routine
-	//    (e.g., regularly checking if the connection is alive)
-	// -----------------------------------------------------------------
-	mc.connectionWg.Add(1)
-	go mc.startHealthCheck()
+	return nil
+}
+
+// await waits up to timeout for the connection to be up.
+func (mc *MQTTClient) await(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return mc.client.AwaitConnection(ctx)
+}
+
+// subscribe routes the messages of class matching filter to handler and
+// subscribes to filter, waiting for the broker's ack. The route is removed
+// again when the subscription fails.
+func (mc *MQTTClient) subscribe(filter string, class MessageClass, handler func(*paho.Publish)) error {
+	mc.routeMu.Lock()
+	mc.routes[filter] = handler
+	mc.routeClasses[filter] = class
+	mc.routeMu.Unlock()
+	if err := mc.brokerSubscribe(filter, class); err != nil {
+		mc.routeMu.Lock()
+		delete(mc.routes, filter)
+		delete(mc.routeClasses, filter)
+		mc.routeMu.Unlock()
+		return err
+	}
+	return nil
+}
 
+// brokerSubscribe subscribes to filter with the QoS level of class.
+func (mc *MQTTClient) brokerSubscribe(filter string, class MessageClass) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mc.config.MQTT.ConnectionTimeout)
+	defer cancel()
+	suback, err := mc.client.Subscribe(ctx, &paho.Subscribe{
+		Subscriptions: []paho.SubscribeOptions{{Topic: filter, QoS: class.QoS(mc.config.MQTT.QoS)}},
+	})
+	if err != nil {
+		return err
+	}
+	if len(suback.Reasons) > 0 && suback.Reasons[0] >= mqttReasonFailure {
+		return fmt.Errorf("broker refused subscription to %s with reason code 0x%02x", filter, suback.Reasons[0])
+	}
 	return nil
 }
 
-// startHealthCheck periodically checks that the MQTT client remains
-// connected. If disconnected, it attempts to reconnect. This routine
-// is not strictly required by the specification but demonstrates
-// robust, production-grade handling.
-func (mc *MQTTClient) startHealthCheck() {
-	defer mc.connectionWg.Done()
-	ticker := time.NewTicker(mc.config.MQTT.RetryInterval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			if !mc.client.IsConnected() {
-				log.Println("[MQTTClient] Detected disconnection; attempting manual reconnection.")
-				_ = mc.Connect()
-			}
+// unsubscribe unsubscribes from filter and removes its route.
+func (mc *MQTTClient) unsubscribe(filter string) {
+	ctx, cancel := context.WithTimeout(context.Background(), mc.config.MQTT.ConnectionTimeout)
+	defer cancel()
+	if _, err := mc.client.Unsubscribe(ctx, &paho.Unsubscribe{Topics: []string{filter}}); err != nil {
+		log.Printf("[MQTTClient] Failed to unsubscribe from %s: %v\n", filter, err)
+	}
+	mc.routeMu.Lock()
+	delete(mc.routes, filter)
+	delete(mc.routeClasses, filter)
+	mc.routeMu.Unlock()
+}
+
+// resubscribe subscribes again to every routed filter after a reconnection.
+func (mc *MQTTClient) resubscribe() {
+	mc.routeMu.RLock()
+	classes := make(map[string]MessageClass, len(mc.routeClasses))
+	for filter, class := range mc.routeClasses {
+		classes[filter] = class
+	}
+	mc.routeMu.RUnlock()
+	for filter, class := range classes {
+		if err := mc.brokerSubscribe(filter, class); err != nil {
+			log.Printf("[MQTTClient] Failed to restore subscription to %s: %v\n", filter, err)
 		}
 	}
 }
 
+// route passes msg to the handler of every subscription matching its topic.
+// Messages no subscription matches are logged and counted as received.
+func (mc *MQTTClient) route(msg *paho.Publish) {
+	mc.routeMu.RLock()
+	var matched []func(*paho.Publish)
+	for filter, handler := range mc.routes {
+		if TopicFilterMatches(filter, msg.Topic) {
+			matched = append(matched, handler)
+		}
+	}
+	mc.routeMu.RUnlock()
+
+	if len(matched) == 0 {
+		log.Printf("[MQTTClient] Received message on unhandled topic %s\n", msg.Topic)
+		mc.messageMetrics.WithLabelValues("received", mc.topicLabels.Label(msg.Topic)).Inc()
+		return
+	}
+	for _, handler := range matched {
+		handler(msg)
+	}
+}
+
+// publish publishes payload to topic with the QoS level of class and the
+// given properties, which may be nil.
+func (mc *MQTTClient) publish(class MessageClass, topic string, payload []byte, props *MessageProperties) error {
+	ctx, cancel := context.WithTimeout(context.Background(), mc.config.MQTT.ConnectionTimeout)
+	defer cancel()
+	_, err := mc.client.Publish(ctx, &paho.Publish{
+		Topic:      topic,
+		QoS:        class.QoS(mc.config.MQTT.QoS),
+		Payload:    payload,
+		Properties: props.PahoProperties(),
+	})
+	return err
+}
+
 // ---------------------------------------------------------------------
 // Method: Disconnect
 // ---------------------------------------------------------------------
-// Disconnect cleanly disconnects from the MQTT broker, shutting down
-// health checks, unsubscribing from topics, and cleaning up sessions.
+// Disconnect cleanly disconnects from the MQTT broker, unsubscribing
+// from topics and cleaning up sessions.
 //
 // Steps:
-//   1. Cancel health check routine (using a graceful approach).
-//   2. Unsubscribe from all topics if needed.
-//   3. Clean up active sessions as necessary.
-//   4. Close metric collectors (if any).
-//   5. Disconnect MQTT client with a timeout.
-//   6. Wait for all background routines to finish cleanup.
+//   1. Unsubscribe from all topics if needed.
+//   2. Clean up active sessions as necessary.
+//   3. Close metric collectors (if any).
+//   4. Disconnect from the broker with a timeout and stop reconnecting.
+//   5. Let the session dispatcher drain work already handed to it.
 func (mc *MQTTClient) Disconnect() {
 	log.Println("[MQTTClient] Initiating clean disconnect from MQTT broker.")
+	mc.breaker.Stop()
+	if mc.client == nil {
+		mc.dispatcher.Stop()
+		return
+	}
 
-	// 1. A standard approach to cancel health check might use a context or
-	//    additional logic. For demonstration, we rely on re-check intervals
-	//    finishing. Alternatively, we can forcibly stop the ticker in startHealthCheck.
-	//    We'll let the goroutine exit once the client is fully disconnected.
-
-	// 2. Unsubscribe from possible system topics or from session topics if we wish.
+	// 1. Unsubscribe from possible system topics or from session topics if we wish.
 	//    For demonstration, unsubscribing from "service/heartbeat" or all session topics.
-	mc.client.Unsubscribe("service/heartbeat")
+	mc.unsubscribe("service/heartbeat")
 
-	// 3. Session cleanup. We can iterate over activeSessions and mark them or
+	// 2. Session cleanup. We can iterate over activeSessions and mark them or
 	//    simply log. We'll not forcibly remove them in this example. A real
 	//    implementation might archive or store partial data if necessary.
 	mc.activeSessions.Range(func(key, value interface{}) bool {
@@ -285,15 +489,21 @@ func (mc *MQTTClient) Disconnect() {
 		return true
 	})
 
-	// 4. If we had allocated any special metric collectors beyond messageMetrics,
+	// 3. If we had allocated any special metric collectors beyond messageMetrics,
 	//    we'd close them here. For demonstration, we only have the CounterVec
 	//    registered globally.
 
-	// 5. Disconnect the MQTT client with a defined timeout (e.g., 1000 ms).
-	mc.client.Disconnect(1000)
+	// 4. Disconnect from the broker within a defined timeout (e.g., 1000 ms),
+	//    then stop the connection manager from reconnecting.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	if err := mc.client.Disconnect(ctx); err != nil {
+		log.Printf("[MQTTClient] Disconnect from MQTT broker failed: %v\n", err)
+	}
+	cancel()
+	mc.cancel()
 
-	// 6. Wait for the health check or other background routines to complete.
-	mc.connectionWg.Wait()
+	// 5. Let the session dispatcher drain work already handed to it.
+	mc.dispatcher.Stop()
 
 	log.Println("[MQTTClient] Disconnected successfully.")
 }
@@ -322,24 +532,24 @@ func (mc *MQTTClient) SubscribeToSession(session *models.TrackingSession) error
 
 	// 2. Subscribe to location updates topic
 	locTopic := fmt.Sprintf(TopicLocationUpdate, sessionID)
-	locToken := mc.client.Subscribe(locTopic, QosLevel, func(client mqtt.Client, msg mqtt.Message) {
-		mc.messageMetrics.WithLabelValues("received", msg.Topic()).Inc()
-		handleLocationUpdate(client, msg, mc)
-	})
-	locToken.Wait()
-	if locToken.Error() != nil {
-		return fmt.Errorf("failed to subscribe to location topic for sessionID=%s: %w", sessionID, locToken.Error())
+	if err := mc.subscribe(locTopic, MessageClassLocation, func(msg *paho.Publish) {
+		mc.messageMetrics.WithLabelValues("received", mc.topicLabels.Label(msg.Topic)).Inc()
+		mc.dispatch(sessionID, msg.Topic, func() {
+			handleLocationUpdate(msg, mc)
+		})
+	}); err != nil {
+		return fmt.Errorf("failed to subscribe to location topic for sessionID=%s: %w", sessionID, err)
 	}
 
 	// 3. Subscribe to control messages topic
 	ctrlTopic := fmt.Sprintf(TopicSessionControl, sessionID)
-	ctrlToken := mc.client.Subscribe(ctrlTopic, QosLevel, func(client mqtt.Client, msg mqtt.Message) {
-		mc.messageMetrics.WithLabelValues("received", msg.Topic()).Inc()
-		handleSessionControl(client, msg, mc)
-	})
-	ctrlToken.Wait()
-	if ctrlToken.Error() != nil {
-		return fmt.Errorf("failed to subscribe to control topic for sessionID=%s: %w", sessionID, ctrlToken.Error())
+	if err := mc.subscribe(ctrlTopic, MessageClassControl, func(msg *paho.Publish) {
+		mc.messageMetrics.WithLabelValues("received", mc.topicLabels.Label(msg.Topic)).Inc()
+		mc.dispatch(sessionID, msg.Topic, func() {
+			handleSessionControl(msg, mc)
+		})
+	}); err != nil {
+		return fmt.Errorf("failed to subscribe to control topic for sessionID=%s: %w", sessionID, err)
 	}
 
 	// 4. Store session in activeSessions
@@ -353,20 +563,86 @@ func (mc *MQTTClient) SubscribeToSession(session *models.TrackingSession) error
 	return nil
 }
 
+// SetDiagnostics captures a diagnostics bundle, with the redacted message,
+// whenever handling a message panics. It must be called before Connect.
+func (mc *MQTTClient) SetDiagnostics(diagnostics *DiagnosticsRecorder) {
+	mc.diagnostics = diagnostics
+	mc.dispatcher.SetDiagnostics(diagnostics)
+}
+
+// dispatch hands a session's message handling to the session dispatcher.
+// Location and control messages for the same session share a worker, so
+// a "complete" command is never applied ahead of earlier location updates.
+// Messages are dropped and counted when the owning worker is saturated.
+func (mc *MQTTClient) dispatch(sessionID, topic string, task func()) {
+	if err := mc.dispatcher.Dispatch(sessionID, task); err != nil {
+		mc.messageMetrics.WithLabelValues("dropped", mc.topicLabels.Label(topic)).Inc()
+		log.Printf("[MQTTClient] Dropping message for sessionID=%s on topic=%s: %v\n", sessionID, topic, err)
+	}
+}
+
+// ---------------------------------------------------------------------
+// MessageProperties Struct
+// ---------------------------------------------------------------------
+// MessageProperties are the MQTT 5 properties of a published message,
+// which consumers read without decoding the payload.
+type MessageProperties struct {
+	// Expiry is how long the broker keeps the message for subscribers
+	// that have not received it yet, in whole seconds rounded up. Zero
+	// keeps it until it is delivered.
+	Expiry time.Duration
+
+	// ContentType is the MIME type of the payload, e.g. application/json.
+	ContentType string
+
+	// User holds application-defined properties consumers can route on,
+	// e.g. by session or tenant.
+	User map[string]string
+}
+
+// PahoProperties converts p to paho's publish properties, nil for nil p.
+// User properties are sent sorted by key.
+func (p *MessageProperties) PahoProperties() *paho.PublishProperties {
+	if p == nil {
+		return nil
+	}
+	props := &paho.PublishProperties{ContentType: p.ContentType}
+	if p.Expiry > 0 {
+		seconds := math.Ceil(p.Expiry.Seconds())
+		if seconds > math.MaxUint32 {
+			seconds = math.MaxUint32
+		}
+		expiry := uint32(seconds)
+		props.MessageExpiry = &expiry
+	}
+	keys := make([]string, 0, len(p.User))
+	for key := range p.User {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		props.User = append(props.User, paho.UserProperty{Key: key, Value: p.User[key]})
+	}
+	return props
+}
+
 // ---------------------------------------------------------------------
 // Method: PublishLocation
 // ---------------------------------------------------------------------
 // PublishLocation publishes a location update for a given session
-// with optional batching and retry.
+// through the publish circuit breaker. props may be nil. The payload is
+// JSON, so ContentType defaults to application/json, and a sessionId
+// user property is added unless props sets one, so consumers can route
+// updates without decoding them.
 //
 // Steps:
 //   1. Validate location data.
 //   2. Add to batch if batching is enabled (not implemented in detail here).
 //   3. Encode location data (with optional compression).
-//   4. Publish with retry mechanism if the initial publish fails.
+//   4. Publish through the breaker, queueing the update if it fails.
 //   5. Update metrics.
 //   6. Return publish status.
-func (mc *MQTTClient) PublishLocation(sessionID string, loc *models.Location) error {
+func (mc *MQTTClient) PublishLocation(sessionID string, loc *models.Location, props *MessageProperties) error {
 	// 1. Validate location data
 	if err := loc.Validate(); err != nil {
 		return fmt.Errorf("invalid location data for sessionID=%s: %w", sessionID, err)
@@ -380,27 +656,35 @@ func (mc *MQTTClient) PublishLocation(sessionID string, loc *models.Location) er
 	if err != nil {
 		return fmt.Errorf("failed to encode location data for sessionID=%s: %w", sessionID, err)
 	}
+	locationProps := MessageProperties{ContentType: "application/json", User: map[string]string{"sessionId": sessionID}}
+	if props != nil {
+		locationProps.Expiry = props.Expiry
+		if props.ContentType != "" {
+			locationProps.ContentType = props.ContentType
+		}
+		for key, value := range props.User {
+			locationProps.User[key] = value
+		}
+	}
 
-	// 4. Publish with retry mechanism
+	// 4. Publish through the breaker, which queues the update for delivery
+	//    in the background when the broker cannot take it, so the caller
+	//    never sleeps between attempts.
 	topic := fmt.Sprintf(TopicLocationUpdate, sessionID)
-	var pubErr error
-	for attempt := 1; attempt <= MaxRetryAttempts; attempt++ {
-		pubToken := mc.client.Publish(topic, QosLevel, false, payload)
-		pubToken.Wait()
-		if pubToken.Error() == nil {
-			pubErr = nil
-			break
+	pubErr := mc.breaker.Publish(func() error {
+		err := mc.publish(MessageClassLocation, topic, payload, &locationProps)
+		if err != nil {
+			log.Printf("[MQTTClient] Publish for sessionID=%s failed: %v\n", sessionID, err)
 		}
-		pubErr = pubToken.Error()
-		log.Printf("[MQTTClient] Publish attempt #%d for sessionID=%s failed: %v\n", attempt, sessionID, pubErr)
-		time.Sleep(RetryBackoffInterval * time.Duration(attempt))
-	}
+		return err
+	})
 	if pubErr != nil {
-		return fmt.Errorf("failed to publish location after %d attempts for sessionID=%s: %w", MaxRetryAttempts, sessionID, pubErr)
+		mc.messageMetrics.WithLabelValues("dropped", mc.topicLabels.Label(topic)).Inc()
+		return fmt.Errorf("failed to publish location for sessionID=%s: %w", sessionID, pubErr)
 	}
 
 	// 5. Update metrics
-	mc.messageMetrics.WithLabelValues("published", topic).Inc()
+	mc.messageMetrics.WithLabelValues("published", mc.topicLabels.Label(topic)).Inc()
 	log.Printf("[MQTTClient] Successfully published location for sessionID=%s on topic=%s\n", sessionID, topic)
 
 	// 6. Return publish status
@@ -421,27 +705,41 @@ func (mc *MQTTClient) PublishLocation(sessionID string, loc *models.Location) er
 //   5. Update metrics.
 //   6. Broadcast location update (placeholder).
 //   7. Handle errors with recovery.
-func handleLocationUpdate(client mqtt.Client, message mqtt.Message, mc *MQTTClient) {
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("[MQTTClient] Panic recovered in handleLocationUpdate: %v\n", r)
-		}
-	}()
+func handleLocationUpdate(message *paho.Publish, mc *MQTTClient) {
+	topic := message.Topic
+	diag := &DiagnosticContext{
+		Payload: message.Payload,
+		Fields:  map[string]string{"topic": mc.topicLabels.Label(topic)},
+	}
+	defer mc.diagnostics.Recover("mqtt.location", diag)
 
-	topic := message.Topic()
 	topicParts := strings.Split(topic, "/")
 	if len(topicParts) < 3 {
 		log.Printf("[MQTTClient] Invalid topic format in handleLocationUpdate: %s\n", topic)
 		return
 	}
 	sessionID := topicParts[len(topicParts)-1]
+	diag.SessionID = sessionID
 
-	// 1 & 3. Decode the payload into a location struct
+	// 1 & 3. Decode the payload into a location struct, as a protobuf
+	//        Location when the message's content type says so and as JSON
+	//        otherwise
 	var loc models.Location
-	if err := json.Unmarshal(message.Payload(), &loc); err != nil {
+	if message.Properties != nil && message.Properties.ContentType == ProtobufContentType {
+		decoded, err := UnmarshalLocationProto(message.Payload)
+		if err != nil {
+			log.Printf("[MQTTClient] Failed to unmarshal protobuf location data: %v\n", err)
+			return
+		}
+		loc = *decoded
+	} else if err := json.Unmarshal(message.Payload, &loc); err != nil {
 		log.Printf("[MQTTClient] Failed to unmarshal location data: %v\n", err)
 		return
 	}
+	if err := mc.reprojector.Reproject(&loc); err != nil {
+		log.Printf("[MQTTClient] Rejecting location for sessionID=%s: %v\n", sessionID, err)
+		return
+	}
 
 	// 2. Rate limiting is omitted for brevity.
 
@@ -463,6 +761,7 @@ func handleLocationUpdate(client mqtt.Client, message mqtt.Message, mc *MQTTClie
 		return
 	}
 	log.Printf("[MQTTClient] Added location to sessionID=%s\n", sessionID)
+	mc.diagnostics.Trace("mqtt.location", sessionID, "location added")
 
 	// 5. Update metrics (already incremented in the callback).
 	//    Optionally we could increment other counters for location updates.
@@ -487,26 +786,27 @@ func handleLocationUpdate(client mqtt.Client, message mqtt.Message, mc *MQTTClie
 //   5. Update session state if needed.
 //   6. Send acknowledgment.
 //   7. Update metrics.
-func handleSessionControl(client mqtt.Client, message mqtt.Message, mc *MQTTClient) {
-	defer func() {
-		if r := recover(); r != nil {
-			log.Printf("[MQTTClient] Panic recovered in handleSessionControl: %v\n", r)
-		}
-	}()
+func handleSessionControl(message *paho.Publish, mc *MQTTClient) {
+	topic := message.Topic
+	diag := &DiagnosticContext{
+		Payload: message.Payload,
+		Fields:  map[string]string{"topic": mc.topicLabels.Label(topic)},
+	}
+	defer mc.diagnostics.Recover("mqtt.control", diag)
 
-	topic := message.Topic()
 	topicParts := strings.Split(topic, "/")
 	if len(topicParts) < 3 {
 		log.Printf("[MQTTClient] Invalid topic format in handleSessionControl: %s\n", topic)
 		return
 	}
 	sessionID := topicParts[len(topicParts)-1]
+	diag.SessionID = sessionID
 
 	// 1. Validate message format (we assume JSON with a field "command")
 	var payload struct {
 		Command string `json:"command"`
 	}
-	if err := json.Unmarshal(message.Payload(), &payload); err != nil {
+	if err := json.Unmarshal(message.Payload, &payload); err != nil {
 		log.Printf("[MQTTClient] Failed to unmarshal session control command: %v\n", err)
 		return
 	}
@@ -533,18 +833,17 @@ func handleSessionControl(client mqtt.Client, message mqtt.Message, mc *MQTTClie
 	// 4. Execute control action
 	switch cmd {
 	case "pause":
-		if session.Status() == models.SessionStatusActive {
-			// We don't have an explicit method in the session for pausing,
-			// but we can manually set the status for demonstration.
-			// A real implementation might handle additional logic, e.g., partial archival.
-			setSessionStatus(session, models.SessionStatusPaused)
-			log.Printf("[MQTTClient] Paused sessionID=%s\n", sessionID)
+		if err := session.Pause(); err != nil {
+			log.Printf("[MQTTClient] Failed to pause sessionID=%s: %v\n", sessionID, err)
+			return
 		}
+		log.Printf("[MQTTClient] Paused sessionID=%s\n", sessionID)
 	case "resume":
-		if session.Status() == models.SessionStatusPaused {
-			setSessionStatus(session, models.SessionStatusActive)
-			log.Printf("[MQTTClient] Resumed sessionID=%s\n", sessionID)
+		if err := session.Resume(); err != nil {
+			log.Printf("[MQTTClient] Failed to resume sessionID=%s: %v\n", sessionID, err)
+			return
 		}
+		log.Printf("[MQTTClient] Resumed sessionID=%s\n", sessionID)
 	case "complete":
 		err := session.Complete()
 		if err != nil {
@@ -562,139 +861,11 @@ func handleSessionControl(client mqtt.Client, message mqtt.Message, mc *MQTTClie
 	// 6. Send acknowledgment
 	ackTopic := fmt.Sprintf("%s/ack", topic)
 	ackPayload := fmt.Sprintf(`{"sessionID":"%s","command":"%s","status":"ack"}`, sessionID, cmd)
-	pubToken := client.Publish(ackTopic, QosLevel, false, ackPayload)
-	pubToken.Wait()
-	if pubToken.Error() != nil {
-		log.Printf("[MQTTClient] Failed to publish control ack: %v\n", pubToken.Error())
+	if err := mc.publish(MessageClassControl, ackTopic, []byte(ackPayload), &MessageProperties{ContentType: "application/json"}); err != nil {
+		log.Printf("[MQTTClient] Failed to publish control ack: %v\n", err)
 	}
 
 	// 7. Update metrics if desired (already incremented in the callback for inbound messages).
 	log.Printf("[MQTTClient] Session control command='%s' acked for sessionID=%s\n", cmd, sessionID)
+	mc.diagnostics.Trace("mqtt.control", sessionID, "command "+cmd+" applied")
 }
-
-// setSessionStatus is a helper that safely modifies the session's status.
-// In a real production scenario, we might require more concurrency control
-// or advanced logic here.
-func setSessionStatus(s *models.TrackingSession, newStatus string) {
-	// The session struct has an internal mutex, but the status is updated
-	// through some of the session's methods. For demonstration, we access
-	// the internal field. A more robust approach is recommended.
-	// This function demonstrates how one might manipulate the status in code.
-	s.MutexLock()
-	defer s.MutexUnlock()
-
-	// Reflecting the new status
-	// We'll do it if permitted by the session's current rules.
-	if s.Status() != models.SessionStatusCompleted {
-		// Use reflection on session for demonstration
-		s.SetStatus(newStatus)
-	}
-}
-
-// The following methods extend TrackingSession to safely lock/unlock
-// and set status. These are provided here to ensure we can manipulate
-// session status in a concurrency-safe manner.
-
-func (s *models.TrackingSession) MutexLock() {
-	s.MutexPtr().Lock()
-}
-
-func (s *models.TrackingSession) MutexUnlock() {
-	s.MutexPtr().Unlock()
-}
-
-func (s *models.TrackingSession) SetStatus(newStatus string) {
-	s.StatusRefUpdate(newStatus)
-}
-
-// Below are small bridging methods to safely update internal fields.
-// Not part of the original tracking.go but used here for demonstration.
-
-func (s *models.TrackingSession) MutexPtr() *sync.Mutex {
-	return s.MutexInternal()
-}
-
-func (s *models.TrackingSession) MutexInternal() *sync.Mutex {
-	// We rely on the unexported struct field s.mutex in tracking.go,
-	// so we add a bridging method or update the struct. For strict compliance,
-	// we demonstrate it this way:
-	return sMutex(s)
-}
-
-// Reflecting the internal struct field for demonstration; an alternative
-// is to add these in tracking.go. This is an advanced trick to directly
-// manipulate private fields, but we do it purely to meet the control logic
-// requirement here.
-func sMutex(ts *models.TrackingSession) *sync.Mutex
-func sMutex(ts *models.TrackingSession) *sync.Mutex {
-	return tsMutexField(ts)
-}
-
-// Similarly for status. We add a bridging method. Production code might
-// just have a dedicated method in tracking.go for pausing/resuming.
-func (s *models.TrackingSession) StatusRefUpdate(newStatus string) {
-	updateStatusRef(s, newStatus)
-}
-
-// This function simulates reflection or direct assignment though in
-// an actual codebase, we would implement a dedicated method in
-// tracking.go to properly handle pausing/resuming statuses.
-func updateStatusRef(ts *models.TrackingSession, newStatus string) {
-	tsStatusField(ts, newStatus)
-}
-
-// -------------------------------------------------------------------
-// The below stubs (tsMutexField, tsStatusField) are placeholders for
-// demonstration and would typically be replaced by direct field access
-// or methods within the same package as TrackingSession. Because we
-// want to illustrate how session control might happen, we show this
-// approach. In reality, you'd add a method like `Pause()` or `Resume()`
-// right in tracking.go for clarity. For extreme detail, we keep it here.
-// -------------------------------------------------------------------
-func tsMutexField(ts *models.TrackingSession) *sync.Mutex {
-	return getUnexportedMutex(ts)
-}
-func tsStatusField(ts *models.TrackingSession, newStatus string) {
-	setUnexportedStatus(ts, newStatus)
-}
-
-// -------------------------------------------------------------------
-// You would replace these with direct calls to ts.mutex.
-// Or you expand the tracking.go code with Pause() and Resume() methods.
-//
-// The following are contrived to illustrate direct field manipulation
-// for demonstration. They rely on the unexported fields inside the
-// `TrackingSession` struct. This approach is typically discouraged
-// in production code, but we do it here to remain fully self-contained.
-// -------------------------------------------------------------------
-func getUnexportedMutex(ts *models.TrackingSession) *sync.Mutex {
-	// This is artificially returning the unexported field
-	// from an external package. A reflection-based approach
-	// could be used, but that is not recommended in real code.
-	return tsAccessorMutex(ts)
-}
-
-func setUnexportedStatus(ts *models.TrackingSession, newStatus string) {
-	tsAccessorSetStatus(ts, newStatus)
-}
-
-// -------------------------------------------------------------------
-// Accessor stubs for the unexported fields. In production-level code,
-// these would be actual methods within the same package as tracking.go.
-// -------------------------------------------------------------------
-func tsAccessorMutex(ts *models.TrackingSession) *sync.Mutex {
-	// We rely on the session's internal field 'mutex'. No standard approach
-	// can do this outside the package except reflection. We'll simulate
-	// that it's accessible for demonstration. Real code would be within the
-	// models package or use reflection.
-	return nil
-}
-
-func tsAccessorSetStatus(ts *models.TrackingSession, newStatus string) {
-	// In actual code, we would do something like: ts.status = newStatus
-}
-
-// -------------------------------------------------------------------
-// End of demonstration bridging for session control. Our main focus
-// is on implementing the MQTT functionality as required.
-// -------------------------------------------------------------------
\ No newline at end of file
@@ -0,0 +1,237 @@
+package utils
+
+import (
+	// errors go1.21 for the full fallback queue sentinel
+	"errors"
+
+	// sync go1.21 for stopping the fallback queue once
+	"sync"
+
+	// sync/atomic go1.21 for counting messages waiting for delivery
+	"sync/atomic"
+
+	// time go1.21 for queued message ages and redelivery intervals
+	"time"
+
+	// gobreaker v0.5.0 for the circuit breaker guarding publishes
+	"github.com/sony/gobreaker"
+
+	// prometheus v1.16.0 for breaker state and fallback queue metrics
+	"github.com/prometheus/client_golang/prometheus"
+
+	// zap v1.24.0 for logging breaker transitions
+	"go.uber.org/zap"
+
+	// Internal import for the breaker and fallback queue settings
+	"src/backend/tracking-service/internal/config"
+)
+
+// ---------------------------------------------------------------------
+// Global Constants
+// ---------------------------------------------------------------------
+
+// fallbackRetryInterval is how long the fallback queue waits before trying
+// its oldest message again, or checking whether the breaker has closed.
+const fallbackRetryInterval = time.Second
+
+// Outcomes of messages handed to the fallback queue, as labelled on
+// tracking_mqtt_fallback_messages_total.
+const (
+	FallbackQueued    = "queued"
+	FallbackDelivered = "delivered"
+	FallbackDropped   = "dropped"
+	FallbackExpired   = "expired"
+)
+
+// ErrPublishQueueFull is returned for a message that could not be published
+// and was dropped because the fallback queue was full.
+var ErrPublishQueueFull = errors.New("MQTT publish fallback queue is full")
+
+// queuedPublish is a message waiting in the fallback queue; send publishes it.
+type queuedPublish struct {
+	send     func() error
+	queuedAt time.Time
+}
+
+// ---------------------------------------------------------------------
+// PublishBreaker Struct
+// ---------------------------------------------------------------------
+// PublishBreaker guards MQTT publishes with a circuit breaker, so a broker
+// outage costs publishers one failed attempt each until the breaker opens,
+// and none afterwards. Messages that fail, or that the open breaker refuses,
+// are handed to a bounded fallback queue and delivered in order by a
+// background goroutine once the broker recovers. While messages wait there,
+// new ones queue behind them, so per-topic ordering is kept. Publishers never
+// wait on the queue: a message arriving while it is full is dropped.
+type PublishBreaker struct {
+	breaker *gobreaker.CircuitBreaker
+	queue   chan queuedPublish
+	maxAge  time.Duration
+
+	// pending counts the messages queued or being redelivered.
+	pending atomic.Int64
+
+	stopOnce sync.Once
+	stop     chan struct{}
+
+	state       prometheus.Gauge
+	transitions *prometheus.CounterVec
+	depth       prometheus.Gauge
+	fallback    *prometheus.CounterVec
+}
+
+// ---------------------------------------------------------------------
+// Factory Function: NewPublishBreaker
+// ---------------------------------------------------------------------
+// NewPublishBreaker creates a breaker opened by cfg.BreakerFailures
+// consecutive failed publishes, probing the broker again after
+// cfg.BreakerTimeout, with a fallback queue of cfg.FallbackQueueSize messages
+// kept for up to cfg.FallbackMaxAge. A queue size of zero disables the queue,
+// so failed publishes are only reported. The queue is drained until Stop is
+// called. State changes are logged on logger, when non-nil, and metrics are
+// registered on the given registry when it is non-nil.
+func NewPublishBreaker(cfg config.MQTTConfig, logger *zap.Logger, registry *prometheus.Registry) *PublishBreaker {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	pb := &PublishBreaker{
+		maxAge: cfg.FallbackMaxAge,
+		stop:   make(chan struct{}),
+		state: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "tracking_mqtt_breaker_state",
+			Help: "State of the MQTT publish circuit breaker: 0 closed, 1 half-open, 2 open",
+		}),
+		transitions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tracking_mqtt_breaker_transitions_total",
+			Help: "State changes of the MQTT publish circuit breaker, by state entered",
+		}, []string{"to"}),
+		depth: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "tracking_mqtt_fallback_queue_depth",
+			Help: "MQTT messages waiting in the fallback queue for the broker to recover",
+		}),
+		fallback: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "tracking_mqtt_fallback_messages_total",
+			Help: "MQTT messages handed to the fallback queue, by outcome: queued, delivered, dropped when the queue was full, expired when too old to deliver",
+		}, []string{"outcome"}),
+	}
+
+	failures := uint32(cfg.BreakerFailures)
+	pb.breaker = gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:        "MQTTPublishBreaker",
+		MaxRequests: 1,
+		Timeout:     cfg.BreakerTimeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= failures
+		},
+		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
+			pb.state.Set(float64(to))
+			pb.transitions.WithLabelValues(to.String()).Inc()
+			logger.Warn("MQTT publish breaker changed state",
+				zap.String("breaker", name),
+				zap.String("from", from.String()),
+				zap.String("to", to.String()),
+			)
+		},
+	})
+
+	if registry != nil {
+		registry.MustRegister(pb.collectors()...)
+	}
+	if cfg.FallbackQueueSize > 0 {
+		pb.queue = make(chan queuedPublish, cfg.FallbackQueueSize)
+		go pb.drain()
+	}
+	return pb
+}
+
+// Publish runs send, which publishes one message to the broker, through the
+// breaker. A message that fails, or arrives while the breaker is open or
+// earlier messages are still queued, is queued for delivery in the
+// background and reported as published. Publish returns an error only for
+// messages dropped: the queue is full, or disabled and the publish failed.
+func (pb *PublishBreaker) Publish(send func() error) error {
+	if pb.pending.Load() == 0 {
+		_, err := pb.breaker.Execute(func() (interface{}, error) {
+			return nil, send()
+		})
+		if err == nil || pb.queue == nil {
+			return err
+		}
+	}
+
+	pb.pending.Add(1)
+	select {
+	case pb.queue <- queuedPublish{send: send, queuedAt: time.Now()}:
+		pb.depth.Inc()
+		pb.fallback.WithLabelValues(FallbackQueued).Inc()
+		return nil
+	default:
+		pb.pending.Add(-1)
+		pb.fallback.WithLabelValues(FallbackDropped).Inc()
+		return ErrPublishQueueFull
+	}
+}
+
+// collectors returns the breaker's metrics.
+func (pb *PublishBreaker) collectors() []prometheus.Collector {
+	return []prometheus.Collector{pb.state, pb.transitions, pb.depth, pb.fallback}
+}
+
+// State returns the breaker's current state.
+func (pb *PublishBreaker) State() gobreaker.State {
+	return pb.breaker.State()
+}
+
+// Pending returns the number of messages waiting in the fallback queue.
+func (pb *PublishBreaker) Pending() int {
+	return int(pb.pending.Load())
+}
+
+// Stop ends the delivery of queued messages. Messages still queued are
+// dropped.
+func (pb *PublishBreaker) Stop() {
+	pb.stopOnce.Do(func() { close(pb.stop) })
+}
+
+// drain delivers the queued messages in order until Stop is called.
+func (pb *PublishBreaker) drain() {
+	for {
+		select {
+		case <-pb.stop:
+			return
+		case msg := <-pb.queue:
+			pb.depth.Dec()
+			delivered := pb.redeliver(msg)
+			pb.pending.Add(-1)
+			if !delivered {
+				return
+			}
+		}
+	}
+}
+
+// redeliver publishes msg through the breaker, trying again every
+// fallbackRetryInterval while it fails or the breaker is open, until it is
+// published or expires. It returns false when Stop is called first.
+func (pb *PublishBreaker) redeliver(msg queuedPublish) bool {
+	for {
+		if time.Since(msg.queuedAt) > pb.maxAge {
+			pb.fallback.WithLabelValues(FallbackExpired).Inc()
+			return true
+		}
+		if pb.breaker.State() != gobreaker.StateOpen {
+			_, err := pb.breaker.Execute(func() (interface{}, error) {
+				return nil, msg.send()
+			})
+			if err == nil {
+				pb.fallback.WithLabelValues(FallbackDelivered).Inc()
+				return true
+			}
+		}
+		select {
+		case <-pb.stop:
+			return false
+		case <-time.After(fallbackRetryInterval):
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package utils
+
+import (
+	// binary provides varint and little-endian fixed64 encoding (go1.21)
+	"encoding/binary"
+	// errors provides decoding validation failures (go1.21)
+	"errors"
+	// fmt provides wrapped decoding errors (go1.21)
+	"fmt"
+	// math provides the float64 bit conversions (go1.21)
+	"math"
+	// time provides the millisecond timestamps (go1.21)
+	"time"
+	// utf8 provides string field validation (go1.21)
+	"unicode/utf8"
+
+	// models package that includes the Location struct
+	"src/backend/tracking-service/internal/models"
+)
+
+// ProtobufContentType is the MIME type of protobuf payloads.
+const ProtobufContentType = "application/x-protobuf"
+
+// Protobuf wire types (protobuf encoding guide, "Message Structure"). Groups,
+// types 3 and 4, are deprecated and rejected.
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+// Field numbers of the Location message in api/tracking.proto.
+const (
+	protoLocationID          = 1
+	protoLocationWalkID      = 2
+	protoLocationLatitude    = 3
+	protoLocationLongitude   = 4
+	protoLocationAccuracy    = 5
+	protoLocationAltitude    = 6
+	protoLocationTimestampMs = 7
+	protoLocationIsValid     = 8
+	protoLocationCRS         = 9
+	protoLocationProvider    = 10
+	protoLocationSeq         = 11
+)
+
+// Field numbers of the LocationBatch message in api/tracking.proto.
+const (
+	protoBatchSessionID = 1
+	protoBatchUploadSeq = 2
+	protoBatchLocations = 3
+)
+
+// Errors returned for payloads the protobuf decoders reject.
+var (
+	ErrProtobufTruncated = errors.New("protobuf: unexpected end of data")
+	ErrProtobufOverflow  = errors.New("protobuf: varint overflows 64 bits")
+)
+
+// LocationBatch is the LocationBatch message of api/tracking.proto. Its JSON
+// form matches a sequenced upload, so a decoded batch can be handled like one
+// received as JSON.
+type LocationBatch struct {
+	SessionID string             `json:"sessionId,omitempty"`
+	UploadSeq uint64             `json:"uploadSeq,omitempty"`
+	Locations []*models.Location `json:"locations"`
+}
+
+// MarshalLocationProto encodes loc as a Location message. Timestamps are
+// truncated to the millisecond and zero fields are omitted, as proto3 does.
+func MarshalLocationProto(loc *models.Location) []byte {
+	var buf []byte
+	buf = appendProtoString(buf, protoLocationID, loc.ID)
+	buf = appendProtoString(buf, protoLocationWalkID, loc.WalkID)
+	buf = appendProtoDouble(buf, protoLocationLatitude, loc.Latitude)
+	buf = appendProtoDouble(buf, protoLocationLongitude, loc.Longitude)
+	buf = appendProtoDouble(buf, protoLocationAccuracy, loc.Accuracy)
+	buf = appendProtoDouble(buf, protoLocationAltitude, loc.Altitude)
+	if !loc.Timestamp.IsZero() {
+		buf = appendProtoVarint(buf, protoLocationTimestampMs, uint64(loc.Timestamp.UnixMilli()))
+	}
+	if loc.IsValid {
+		buf = appendProtoVarint(buf, protoLocationIsValid, 1)
+	}
+	buf = appendProtoString(buf, protoLocationCRS, loc.CRS)
+	buf = appendProtoString(buf, protoLocationProvider, loc.Provider)
+	if loc.Seq != 0 {
+		buf = appendProtoVarint(buf, protoLocationSeq, loc.Seq)
+	}
+	return buf
+}
+
+// UnmarshalLocationProto decodes a Location message. Unknown fields are
+// skipped, so devices may send fields added to the schema later.
+func UnmarshalLocationProto(data []byte) (*models.Location, error) {
+	loc := &models.Location{}
+	d := protoDecoder{data: data}
+	for !d.done() {
+		field, wire, err := d.key()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case field == protoLocationID && wire == protoBytes:
+			loc.ID, err = d.string()
+		case field == protoLocationWalkID && wire == protoBytes:
+			loc.WalkID, err = d.string()
+		case field == protoLocationLatitude && wire == protoFixed64:
+			loc.Latitude, err = d.double()
+		case field == protoLocationLongitude && wire == protoFixed64:
+			loc.Longitude, err = d.double()
+		case field == protoLocationAccuracy && wire == protoFixed64:
+			loc.Accuracy, err = d.double()
+		case field == protoLocationAltitude && wire == protoFixed64:
+			loc.Altitude, err = d.double()
+		case field == protoLocationTimestampMs && wire == protoVarint:
+			var ms uint64
+			ms, err = d.varint()
+			loc.Timestamp = time.UnixMilli(int64(ms)).UTC()
+		case field == protoLocationIsValid && wire == protoVarint:
+			var v uint64
+			v, err = d.varint()
+			loc.IsValid = v != 0
+		case field == protoLocationCRS && wire == protoBytes:
+			loc.CRS, err = d.string()
+		case field == protoLocationProvider && wire == protoBytes:
+			loc.Provider, err = d.string()
+		case field == protoLocationSeq && wire == protoVarint:
+			loc.Seq, err = d.varint()
+		default:
+			err = d.skip(wire)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("protobuf: Location field %d: %w", field, err)
+		}
+	}
+	return loc, nil
+}
+
+// MarshalLocationBatchProto encodes batch as a LocationBatch message.
+func MarshalLocationBatchProto(batch *LocationBatch) []byte {
+	var buf []byte
+	buf = appendProtoString(buf, protoBatchSessionID, batch.SessionID)
+	if batch.UploadSeq != 0 {
+		buf = appendProtoVarint(buf, protoBatchUploadSeq, batch.UploadSeq)
+	}
+	for _, loc := range batch.Locations {
+		if loc != nil {
+			buf = appendProtoBytes(buf, protoBatchLocations, MarshalLocationProto(loc))
+		}
+	}
+	return buf
+}
+
+// UnmarshalLocationBatchProto decodes a LocationBatch message. Unknown fields
+// are skipped.
+func UnmarshalLocationBatchProto(data []byte) (*LocationBatch, error) {
+	batch := &LocationBatch{}
+	d := protoDecoder{data: data}
+	for !d.done() {
+		field, wire, err := d.key()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case field == protoBatchSessionID && wire == protoBytes:
+			batch.SessionID, err = d.string()
+		case field == protoBatchUploadSeq && wire == protoVarint:
+			batch.UploadSeq, err = d.varint()
+		case field == protoBatchLocations && wire == protoBytes:
+			var raw []byte
+			if raw, err = d.bytes(); err == nil {
+				var loc *models.Location
+				if loc, err = UnmarshalLocationProto(raw); err == nil {
+					batch.Locations = append(batch.Locations, loc)
+				}
+			}
+		default:
+			err = d.skip(wire)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("protobuf: LocationBatch field %d: %w", field, err)
+		}
+	}
+	return batch, nil
+}
+
+// appendProtoKey appends the key of a field.
+func appendProtoKey(buf []byte, field int, wire byte) []byte {
+	return binary.AppendUvarint(buf, uint64(field)<<3|uint64(wire))
+}
+
+// appendProtoVarint appends a varint field.
+func appendProtoVarint(buf []byte, field int, v uint64) []byte {
+	return binary.AppendUvarint(appendProtoKey(buf, field, protoVarint), v)
+}
+
+// appendProtoDouble appends a double field, omitted when zero.
+func appendProtoDouble(buf []byte, field int, f float64) []byte {
+	if f == 0 {
+		return buf
+	}
+	return binary.LittleEndian.AppendUint64(appendProtoKey(buf, field, protoFixed64), math.Float64bits(f))
+}
+
+// appendProtoString appends a string field, omitted when empty.
+func appendProtoString(buf []byte, field int, s string) []byte {
+	if s == "" {
+		return buf
+	}
+	return appendProtoBytes(buf, field, []byte(s))
+}
+
+// appendProtoBytes appends a length-delimited field.
+func appendProtoBytes(buf []byte, field int, b []byte) []byte {
+	buf = binary.AppendUvarint(appendProtoKey(buf, field, protoBytes), uint64(len(b)))
+	return append(buf, b...)
+}
+
+// protoDecoder reads the fields of one message from data, starting at pos.
+type protoDecoder struct {
+	data []byte
+	pos  int
+}
+
+// done reports whether every field has been read.
+func (d *protoDecoder) done() bool {
+	return d.pos >= len(d.data)
+}
+
+// key reads a field key, returning its field number and wire type.
+func (d *protoDecoder) key() (int, byte, error) {
+	k, err := d.varint()
+	if err != nil {
+		return 0, 0, err
+	}
+	field, wire := k>>3, byte(k&7)
+	if field == 0 || field > math.MaxInt32 {
+		return 0, 0, fmt.Errorf("protobuf: invalid field number %d", field)
+	}
+	return int(field), wire, nil
+}
+
+// varint reads a base 128 varint.
+func (d *protoDecoder) varint() (uint64, error) {
+	v, n := binary.Uvarint(d.data[d.pos:])
+	switch {
+	case n == 0:
+		return 0, ErrProtobufTruncated
+	case n < 0:
+		return 0, ErrProtobufOverflow
+	}
+	d.pos += n
+	return v, nil
+}
+
+// double reads a fixed64 field as a float64, rejecting NaN and infinities,
+// which JSON cannot represent.
+func (d *protoDecoder) double() (float64, error) {
+	raw, err := d.take(8)
+	if err != nil {
+		return 0, err
+	}
+	f := math.Float64frombits(binary.LittleEndian.Uint64(raw))
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, errors.New("protobuf: NaN and infinite doubles are not supported")
+	}
+	return f, nil
+}
+
+// bytes reads a length-delimited field.
+func (d *protoDecoder) bytes() ([]byte, error) {
+	n, err := d.varint()
+	if err != nil {
+		return nil, err
+	}
+	return d.take(n)
+}
+
+// string reads a length-delimited field as UTF-8 text.
+func (d *protoDecoder) string() (string, error) {
+	raw, err := d.bytes()
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(raw) {
+		return "", errors.New("protobuf: invalid UTF-8 in string field")
+	}
+	return string(raw), nil
+}
+
+// skip passes over the value of an unknown field of the given wire type.
+func (d *protoDecoder) skip(wire byte) error {
+	var err error
+	switch wire {
+	case protoVarint:
+		_, err = d.varint()
+	case protoFixed64:
+		_, err = d.take(8)
+	case protoBytes:
+		_, err = d.bytes()
+	case protoFixed32:
+		_, err = d.take(4)
+	default:
+		err = fmt.Errorf("protobuf: unsupported wire type %d", wire)
+	}
+	return err
+}
+
+// take returns the next n bytes and advances past them.
+func (d *protoDecoder) take(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, ErrProtobufTruncated
+	}
+	raw := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return raw, nil
+}
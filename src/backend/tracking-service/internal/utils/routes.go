@@ -0,0 +1,68 @@
+package utils
+
+import (
+	// math provides interpolation step counts (go1.21)
+	"math"
+
+	// models provides the Location and RouteSegment structs
+	"src/backend/tracking-service/internal/models"
+)
+
+// RouteSegments snaps a walk track onto the territory grid and returns the
+// distinct undirected steps it makes between neighbouring cells. The grid acts
+// as a coarse stand-in for a road network: walks along the same path cross the
+// same cells, so counting segments across walks yields route popularity.
+//
+// Steps:
+//  1. Interpolate between consecutive points at half-cell spacing.
+//  2. Collapse runs of points within the same cell.
+//  3. Emit each change of cell once, ordered so both directions share a key.
+func RouteSegments(points []models.Location) []models.RouteSegment {
+	type cell struct{ lat, lon int64 }
+
+	seen := make(map[[2]cell]struct{})
+	var segments []models.RouteSegment
+	var prevCell cell
+	havePrev := false
+
+	visit := func(lat, lon float64) {
+		latIdx, lonIdx := territoryCellIndex(lat, lon)
+		cur := cell{latIdx, lonIdx}
+		if havePrev && cur != prevCell {
+			from, to := prevCell, cur
+			if cellKey(to.lat, to.lon) < cellKey(from.lat, from.lon) {
+				from, to = to, from
+			}
+			if _, ok := seen[[2]cell{from, to}]; !ok {
+				seen[[2]cell{from, to}] = struct{}{}
+				fromLat, fromLon := territoryCellCenter(from.lat, from.lon)
+				toLat, toLon := territoryCellCenter(to.lat, to.lon)
+				segments = append(segments, models.RouteSegment{
+					FromCell:      cellKey(from.lat, from.lon),
+					ToCell:        cellKey(to.lat, to.lon),
+					FromLatitude:  fromLat,
+					FromLongitude: fromLon,
+					ToLatitude:    toLat,
+					ToLongitude:   toLon,
+				})
+			}
+		}
+		prevCell = cur
+		havePrev = true
+	}
+
+	for i, p := range points {
+		if i == 0 {
+			visit(p.Latitude, p.Longitude)
+			continue
+		}
+		prev := points[i-1]
+		segment := distanceBetweenMeters(prev.Latitude, prev.Longitude, p.Latitude, p.Longitude)
+		steps := int(math.Ceil(segment / (TerritoryCellSizeMeters / 2)))
+		for s := 1; s <= steps; s++ {
+			f := float64(s) / float64(steps)
+			visit(prev.Latitude+(p.Latitude-prev.Latitude)*f, prev.Longitude+(p.Longitude-prev.Longitude)*f)
+		}
+	}
+	return segments
+}
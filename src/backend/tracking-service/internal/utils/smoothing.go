@@ -0,0 +1,181 @@
+package utils
+
+import (
+	// math go1.21 for variance and accuracy floors
+	"math"
+
+	// sort go1.21 for smoothing fixes in time order
+	"sort"
+
+	// sync go1.21 for guarding the per-session filters
+	"sync"
+
+	// time go1.21 for the interval between fixes
+	"time"
+
+	// prometheus v1.16.0 for smoothing correction metrics
+	"github.com/prometheus/client_golang/prometheus"
+
+	// Internal imports for smoothing configuration and the Location struct
+	"src/backend/tracking-service/internal/config"
+	"src/backend/tracking-service/internal/models"
+)
+
+// ---------------------------------------------------------------------
+// Global Constants
+// ---------------------------------------------------------------------
+
+// minSmoothingAccuracy floors the accuracy of a fix, in meters, so a device
+// reporting zero cannot pin the filter to a single noisy reading.
+const minSmoothingAccuracy = 1.0
+
+// ---------------------------------------------------------------------
+// KalmanFilter Struct
+// ---------------------------------------------------------------------
+// KalmanFilter smooths a stream of fixes from one device. The position is
+// modelled as a random walk whose uncertainty grows by processNoise meters
+// per second between fixes, and each fix is weighed against the estimate by
+// its reported accuracy, so jittery low-accuracy fixes move the estimate
+// little while precise ones pull it most of the way.
+type KalmanFilter struct {
+	processNoise float64
+	resetAfter   time.Duration
+
+	lat, lon float64
+	// variance is the estimate's uncertainty in square meters; zero until
+	// the first fix.
+	variance float64
+	lastTime time.Time
+}
+
+// ---------------------------------------------------------------------
+// Factory Function: NewKalmanFilter
+// ---------------------------------------------------------------------
+// NewKalmanFilter creates a filter whose position uncertainty grows by
+// processNoise meters per second, about the walking speed the filter allows
+// for. After a silence longer than resetAfter the next fix restarts the
+// filter; zero never restarts it.
+func NewKalmanFilter(processNoise float64, resetAfter time.Duration) *KalmanFilter {
+	return &KalmanFilter{processNoise: processNoise, resetAfter: resetAfter}
+}
+
+// ---------------------------------------------------------------------
+// Method: Process
+// ---------------------------------------------------------------------
+// Process folds a fix with the given accuracy in meters into the estimate
+// and returns the smoothed position. Fixes older than the previous one are
+// weighed as if simultaneous with it.
+func (k *KalmanFilter) Process(lat, lon, accuracy float64, at time.Time) (float64, float64) {
+	accuracy = math.Max(accuracy, minSmoothingAccuracy)
+	restart := k.variance == 0 || (k.resetAfter > 0 && at.Sub(k.lastTime) > k.resetAfter)
+	if restart {
+		k.lat, k.lon = lat, lon
+		k.variance = accuracy * accuracy
+		k.lastTime = at
+		return lat, lon
+	}
+
+	if elapsed := at.Sub(k.lastTime); elapsed > 0 {
+		k.variance += elapsed.Seconds() * k.processNoise * k.processNoise
+		k.lastTime = at
+	}
+	gain := k.variance / (k.variance + accuracy*accuracy)
+	k.lat += gain * (lat - k.lat)
+	k.lon += gain * (lon - k.lon)
+	k.variance *= 1 - gain
+	return k.lat, k.lon
+}
+
+// ---------------------------------------------------------------------
+// LocationSmoother Struct
+// ---------------------------------------------------------------------
+// LocationSmoother applies a KalmanFilter per session to incoming fixes
+// before they are stored, so that GPS jitter does not inflate distance and
+// speed statistics. It is safe for concurrent use across sessions.
+type LocationSmoother struct {
+	mu      sync.Mutex
+	filters map[string]*sessionFilter
+
+	processNoise float64
+	resetAfter   time.Duration
+
+	// corrections observes how far smoothing moved each fix, in meters.
+	corrections prometheus.Histogram
+}
+
+// sessionFilter is a session's filter, locked while a batch is smoothed so
+// concurrent batches of the same session apply in turn.
+type sessionFilter struct {
+	mu     sync.Mutex
+	filter *KalmanFilter
+}
+
+// ---------------------------------------------------------------------
+// Factory Function: NewLocationSmoother
+// ---------------------------------------------------------------------
+// NewLocationSmoother creates a smoother with the configured process noise
+// and reset interval. Metrics are registered on the given registry when it
+// is non-nil.
+func NewLocationSmoother(cfg config.SmoothingConfig, registry *prometheus.Registry) *LocationSmoother {
+	s := &LocationSmoother{
+		filters:      make(map[string]*sessionFilter),
+		processNoise: cfg.ProcessNoise,
+		resetAfter:   cfg.ResetAfter,
+		corrections: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "tracking_smoothing_correction_meters",
+			Help:    "Distance each fix was moved by smoothing, in meters.",
+			Buckets: []float64{0.5, 1, 2, 5, 10, 20, 50},
+		}),
+	}
+	if registry != nil {
+		registry.MustRegister(s.corrections)
+	}
+	return s
+}
+
+// ---------------------------------------------------------------------
+// Method: Smooth
+// ---------------------------------------------------------------------
+// Smooth replaces the coordinates of a session's fixes with their smoothed
+// estimates, in time order, weighing each by its provider-weighted
+// accuracy. Fixes the session would reject as too inaccurate are left
+// untouched and do not affect the filter.
+func (s *LocationSmoother) Smooth(sessionID string, locations []*models.Location) {
+	if len(locations) == 0 {
+		return
+	}
+	ordered := append([]*models.Location(nil), locations...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Timestamp.Before(ordered[j].Timestamp)
+	})
+
+	s.mu.Lock()
+	sf, ok := s.filters[sessionID]
+	if !ok {
+		sf = &sessionFilter{filter: NewKalmanFilter(s.processNoise, s.resetAfter)}
+		s.filters[sessionID] = sf
+	}
+	s.mu.Unlock()
+
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	for _, loc := range ordered {
+		accuracy := loc.EffectiveAccuracy()
+		if accuracy > models.MinLocationAccuracy {
+			continue
+		}
+		lat, lon := sf.filter.Process(loc.Latitude, loc.Longitude, accuracy, loc.Timestamp)
+		s.corrections.Observe(distanceBetweenMeters(loc.Latitude, loc.Longitude, lat, lon))
+		loc.Latitude, loc.Longitude = lat, lon
+	}
+}
+
+// ---------------------------------------------------------------------
+// Method: Forget
+// ---------------------------------------------------------------------
+// Forget drops the filter of an ended session.
+func (s *LocationSmoother) Forget(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.filters, sessionID)
+}
@@ -0,0 +1,327 @@
+package utils
+
+import (
+	// context go1.21 for request-scoped cancellation of buffer operations
+	"context"
+
+	// errors go1.21 for the unknown tier sentinel
+	"errors"
+
+	// fmt go1.21 for wrapping tier assignment errors
+	"fmt"
+
+	// sync go1.21 for guarding the in-memory buffer
+	"sync"
+
+	// time go1.21 for buffer expiry
+	"time"
+
+	// prometheus v1.16.0 for replayed and dropped frame metrics
+	"github.com/prometheus/client_golang/prometheus"
+
+	// Internal import for stream buffer configuration
+	"src/backend/tracking-service/internal/config"
+)
+
+// ---------------------------------------------------------------------
+// Global Constants
+// ---------------------------------------------------------------------
+
+// DefaultStreamBufferSize is the default number of frames retained per session.
+const DefaultStreamBufferSize = 500
+
+// DefaultStreamBufferTTL is how long an idle session's frames are retained.
+const DefaultStreamBufferTTL = 30 * time.Minute
+
+// DefaultStreamFrameMaxAge is the default age beyond which a frame is pruned
+// rather than replayed.
+const DefaultStreamFrameMaxAge = 10 * time.Minute
+
+// memoryStreamSweepInterval bounds how often MemoryStreamBuffer looks for
+// idle streams to expire.
+const memoryStreamSweepInterval = time.Minute
+
+// Reasons a buffered frame is dropped before it could be replayed.
+const (
+	FrameDropCapacity = "capacity"
+	FrameDropAge      = "age"
+)
+
+// ErrUnknownStreamTier is returned when assigning a session to a tier that is
+// not configured.
+var ErrUnknownStreamTier = errors.New("unknown stream tier")
+
+// ---------------------------------------------------------------------
+// BufferedFrame Struct
+// ---------------------------------------------------------------------
+// BufferedFrame is a single outbound stream frame tagged with its per-session
+// sequence number and the time it was buffered.
+type BufferedFrame struct {
+	Sequence   uint64
+	Payload    []byte
+	BufferedAt time.Time
+}
+
+// ---------------------------------------------------------------------
+// StreamBuffer Interface
+// ---------------------------------------------------------------------
+// StreamBuffer retains the last N frames delivered for each session and assigns
+// them monotonically increasing sequence numbers. Implementations shared across
+// instances (see RedisStreamBuffer) let a subscriber resume on any node.
+//
+// Each session belongs to a tier bounding both how many frames are kept and how
+// old they may get; sessions never assigned one use config.DefaultStreamTier.
+type StreamBuffer interface {
+	// Append stores payload as the next frame for sessionID and returns its sequence.
+	Append(ctx context.Context, sessionID string, payload []byte) (uint64, error)
+
+	// Since returns the buffered frames with a sequence greater than afterSeq, in
+	// order. complete is false when frames after afterSeq have already been evicted
+	// or have outlived the tier's maximum age, meaning the subscriber must
+	// resynchronise from the full history instead.
+	Since(ctx context.Context, sessionID string, afterSeq uint64) (frames []BufferedFrame, complete bool, err error)
+
+	// SetTier assigns sessionID to tier. Its limits apply from the next Append or
+	// Since. Unconfigured tiers return an error wrapping ErrUnknownStreamTier.
+	SetTier(ctx context.Context, sessionID string, tier string) error
+}
+
+// ---------------------------------------------------------------------
+// Factory Function: NewStreamBuffer
+// ---------------------------------------------------------------------
+// NewStreamBuffer returns a Redis-backed buffer when cfg.RedisAddr is set and an
+// in-process MemoryStreamBuffer otherwise. The in-process buffer only supports
+// reconnects to the same instance. Metrics are registered on registry when it is
+// non-nil.
+func NewStreamBuffer(cfg *config.StreamConfig, registry *prometheus.Registry) (StreamBuffer, error) {
+	if cfg.RedisAddr != "" {
+		redisBuffer, err := NewRedisStreamBuffer(cfg, registry)
+		if err != nil {
+			return nil, err
+		}
+		return redisBuffer, nil
+	}
+	return NewMemoryStreamBuffer(cfg, registry), nil
+}
+
+// streamBufferLimits returns a copy of cfg with non-positive default tier limits
+// replaced by the package defaults.
+func streamBufferLimits(cfg *config.StreamConfig) config.StreamConfig {
+	limits := *cfg
+	if limits.BufferSize <= 0 {
+		limits.BufferSize = DefaultStreamBufferSize
+	}
+	if limits.BufferTTL <= 0 {
+		limits.BufferTTL = DefaultStreamBufferTTL
+	}
+	if limits.MaxFrameAge <= 0 {
+		limits.MaxFrameAge = DefaultStreamFrameMaxAge
+	}
+	return limits
+}
+
+// ---------------------------------------------------------------------
+// streamBufferMetrics Struct
+// ---------------------------------------------------------------------
+// streamBufferMetrics counts frames replayed to resuming subscribers and frames
+// pruned before they could be, by tier.
+type streamBufferMetrics struct {
+	replayed *prometheus.CounterVec
+	dropped  *prometheus.CounterVec
+}
+
+func newStreamBufferMetrics(registry *prometheus.Registry) *streamBufferMetrics {
+	m := &streamBufferMetrics{
+		replayed: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tracking_stream_frames_replayed_total",
+				Help: "Buffered stream frames replayed to resuming subscribers, by tier.",
+			},
+			[]string{"tier"},
+		),
+		dropped: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "tracking_stream_frames_dropped_total",
+				Help: "Buffered stream frames pruned before replay, by tier and reason (capacity or age).",
+			},
+			[]string{"tier", "reason"},
+		),
+	}
+	if registry != nil {
+		registry.MustRegister(m.replayed, m.dropped)
+	}
+	return m
+}
+
+// recordDropped counts frames pruned from a tier's buffer for reason.
+func (m *streamBufferMetrics) recordDropped(tier, reason string, n int) {
+	if n > 0 {
+		m.dropped.WithLabelValues(tier, reason).Add(float64(n))
+	}
+}
+
+// recordReplayed counts frames returned for replay from a tier's buffer.
+func (m *streamBufferMetrics) recordReplayed(tier string, n int) {
+	if n > 0 {
+		m.replayed.WithLabelValues(tier).Add(float64(n))
+	}
+}
+
+// ---------------------------------------------------------------------
+// MemoryStreamBuffer Struct
+// ---------------------------------------------------------------------
+// MemoryStreamBuffer is an in-process StreamBuffer for single-instance
+// deployments.
+type MemoryStreamBuffer struct {
+	mu        sync.Mutex
+	streams   map[string]*memoryStream
+	lastSweep time.Time
+	cfg       config.StreamConfig
+	metrics   *streamBufferMetrics
+}
+
+// memoryStream holds one session's sequence counter, tier and retained frames.
+type memoryStream struct {
+	lastSeq uint64
+	tier    string
+	frames  []BufferedFrame
+	touched time.Time
+}
+
+// NewMemoryStreamBuffer creates an in-process buffer with the limits in cfg.
+// Non-positive default tier limits fall back to the package defaults. Metrics
+// are registered on registry when it is non-nil.
+func NewMemoryStreamBuffer(cfg *config.StreamConfig, registry *prometheus.Registry) *MemoryStreamBuffer {
+	return &MemoryStreamBuffer{
+		streams: make(map[string]*memoryStream),
+		cfg:     streamBufferLimits(cfg),
+		metrics: newStreamBufferMetrics(registry),
+	}
+}
+
+// Append implements StreamBuffer. Streams idle for longer than the TTL are
+// evicted by a sweep run at most once per memoryStreamSweepInterval, so
+// appending does not scan every session on each frame.
+func (b *MemoryStreamBuffer) Append(_ context.Context, sessionID string, payload []byte) (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(b.lastSweep) >= memoryStreamSweepInterval {
+		b.sweepLocked(now)
+	} else if st, ok := b.streams[sessionID]; ok && b.idleLocked(st, now) {
+		delete(b.streams, sessionID)
+	}
+
+	st := b.streamLocked(sessionID)
+	st.lastSeq++
+	st.touched = now
+
+	frame := BufferedFrame{Sequence: st.lastSeq, Payload: append([]byte(nil), payload...), BufferedAt: now}
+	st.frames = append(st.frames, frame)
+	b.pruneLocked(st, now)
+	return st.lastSeq, nil
+}
+
+// Since implements StreamBuffer.
+func (b *MemoryStreamBuffer) Since(_ context.Context, sessionID string, afterSeq uint64) ([]BufferedFrame, bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	st, ok := b.streams[sessionID]
+	if ok && b.idleLocked(st, now) {
+		delete(b.streams, sessionID)
+		ok = false
+	}
+	if !ok {
+		return nil, afterSeq == 0, nil
+	}
+	tier := b.pruneLocked(st, now)
+
+	var out []BufferedFrame
+	for _, f := range st.frames {
+		if f.Sequence > afterSeq {
+			out = append(out, f)
+		}
+	}
+	b.metrics.recordReplayed(tier, len(out))
+	return out, resumeComplete(out, afterSeq, st.lastSeq), nil
+}
+
+// SetTier implements StreamBuffer.
+func (b *MemoryStreamBuffer) SetTier(_ context.Context, sessionID string, tier string) error {
+	if _, ok := b.cfg.TierLimits(tier); !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownStreamTier, tier)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	st := b.streamLocked(sessionID)
+	st.tier = tier
+	st.touched = time.Now()
+	return nil
+}
+
+// sweepLocked evicts the streams idle for longer than the TTL. b.mu must be
+// held.
+func (b *MemoryStreamBuffer) sweepLocked(now time.Time) {
+	for id, st := range b.streams {
+		if b.idleLocked(st, now) {
+			delete(b.streams, id)
+		}
+	}
+	b.lastSweep = now
+}
+
+// idleLocked reports whether st has been idle for longer than the TTL, so its
+// frames are no longer retained even if the sweep has not evicted it yet.
+// b.mu must be held.
+func (b *MemoryStreamBuffer) idleLocked(st *memoryStream, now time.Time) bool {
+	return now.Sub(st.touched) > b.cfg.BufferTTL
+}
+
+// streamLocked returns sessionID's stream, creating it on first use. b.mu must
+// be held.
+func (b *MemoryStreamBuffer) streamLocked(sessionID string) *memoryStream {
+	st, ok := b.streams[sessionID]
+	if !ok {
+		st = &memoryStream{tier: config.DefaultStreamTier}
+		b.streams[sessionID] = st
+	}
+	return st
+}
+
+// pruneLocked drops st's frames beyond its tier's count limit and those older
+// than its maximum age, and returns the tier the limits were taken from. b.mu
+// must be held.
+func (b *MemoryStreamBuffer) pruneLocked(st *memoryStream, now time.Time) string {
+	limits, ok := b.cfg.TierLimits(st.tier)
+	tier := st.tier
+	if !ok {
+		tier = config.DefaultStreamTier
+	}
+
+	evicted := 0
+	if len(st.frames) > limits.BufferSize {
+		evicted = len(st.frames) - limits.BufferSize
+		st.frames = st.frames[evicted:]
+	}
+	expired := 0
+	for expired < len(st.frames) && now.Sub(st.frames[expired].BufferedAt) > limits.MaxFrameAge {
+		expired++
+	}
+	st.frames = st.frames[expired:]
+
+	b.metrics.recordDropped(tier, FrameDropCapacity, evicted)
+	b.metrics.recordDropped(tier, FrameDropAge, expired)
+	return tier
+}
+
+// resumeComplete reports whether frames fully cover everything after afterSeq,
+// given the last sequence issued for the session.
+func resumeComplete(frames []BufferedFrame, afterSeq, lastSeq uint64) bool {
+	if len(frames) == 0 {
+		return afterSeq >= lastSeq
+	}
+	return frames[0].Sequence == afterSeq+1
+}
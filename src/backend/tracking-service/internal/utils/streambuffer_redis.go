@@ -0,0 +1,263 @@
+package utils
+
+import (
+	// context go1.21 for request-scoped cancellation of Redis calls
+	"context"
+
+	// errors go1.21 for detecting empty script replies
+	"errors"
+
+	// fmt go1.21 for key formatting and error wrapping
+	"fmt"
+
+	// sort go1.21 for a stable tier argument order
+	"sort"
+
+	// strconv go1.21 for parsing sequence-prefixed members
+	"strconv"
+
+	// strings go1.21 for splitting sequence-prefixed members
+	"strings"
+
+	// time go1.21 for buffer expiry
+	"time"
+
+	// prometheus v1.16.0 for replayed and dropped frame metrics
+	"github.com/prometheus/client_golang/prometheus"
+
+	// go-redis v9.2.1 for the shared last-N frame buffer
+	"github.com/redis/go-redis/v9"
+
+	// Internal import for stream buffer configuration
+	"src/backend/tracking-service/internal/config"
+)
+
+// streamKeyFormat namespaces per-session stream keys. The braces form a Redis
+// Cluster hash tag so a session's sequence, frame and tier keys share a slot.
+const streamKeyFormat = "tracking:stream:{%s}:%s"
+
+// resolveTierLua looks up the session's tier (KEYS[3]) in the tier table passed
+// as name/size/maxAge triples from ARGV[tierArgs], setting tier, size and maxAge
+// to its limits or to the default tier's in ARGV[2] and ARGV[3].
+const resolveTierLua = `
+local tier = 'default'
+local size = tonumber(ARGV[2])
+local maxAge = tonumber(ARGV[3])
+local assigned = redis.call('GET', KEYS[3])
+if assigned then
+  for i = tierArgs, #ARGV, 3 do
+    if ARGV[i] == assigned then
+      tier = assigned
+      size = tonumber(ARGV[i + 1])
+      maxAge = tonumber(ARGV[i + 2])
+      break
+    end
+  end
+end
+`
+
+// pruneExpiredLua removes frames older than maxAge from the head of the frame
+// set, counting them in expired. Members are "seq:bufferedAtMillis:payload";
+// frames are appended in time order, so the scan stops at the first fresh one.
+const pruneExpiredLua = `
+local expired = 0
+local cutoff = tonumber(ARGV[1]) - maxAge
+while true do
+  local oldest = redis.call('ZRANGE', KEYS[2], 0, 0)
+  if #oldest == 0 then break end
+  local at = tonumber(string.match(oldest[1], '^%d+:(%d+):'))
+  if at == nil or at >= cutoff then break end
+  redis.call('ZREM', KEYS[2], oldest[1])
+  expired = expired + 1
+end
+`
+
+// appendFrameScript atomically assigns the next sequence, stores the frame in a
+// sorted set scored by sequence, prunes the set to the tier's count and age
+// limits, and renews every key's expiry. It returns the sequence, the resolved
+// tier and the number of frames evicted for capacity and for age.
+//
+// ARGV: nowMillis, defaultSize, defaultMaxAgeMillis, payload, ttlMillis, tiers...
+var appendFrameScript = redis.NewScript(`local tierArgs = 6` + resolveTierLua + `
+local seq = redis.call('INCR', KEYS[1])
+redis.call('ZADD', KEYS[2], seq, seq .. ':' .. ARGV[1] .. ':' .. ARGV[4])
+local evicted = redis.call('ZREMRANGEBYRANK', KEYS[2], 0, -(size + 1))
+` + pruneExpiredLua + `
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+redis.call('PEXPIRE', KEYS[2], ARGV[5])
+redis.call('PEXPIRE', KEYS[3], ARGV[5])
+return {seq, tier, evicted, expired}
+`)
+
+// sinceFramesScript prunes frames that have outlived the tier's maximum age and
+// returns the last issued sequence, the resolved tier, the number of frames
+// pruned and the remaining frames after the given sequence. It returns nil for
+// sessions with no sequence.
+//
+// ARGV: nowMillis, defaultSize, defaultMaxAgeMillis, afterSeq, tiers...
+var sinceFramesScript = redis.NewScript(`local tierArgs = 5
+local lastSeq = redis.call('GET', KEYS[1])
+if not lastSeq then return false end` + resolveTierLua + pruneExpiredLua + `
+local frames = redis.call('ZRANGEBYSCORE', KEYS[2], '(' .. ARGV[4], '+inf')
+return {tonumber(lastSeq), tier, expired, frames}
+`)
+
+// ---------------------------------------------------------------------
+// RedisStreamBuffer Struct
+// ---------------------------------------------------------------------
+// RedisStreamBuffer is a StreamBuffer shared by every instance through Redis,
+// so sequence numbers, tier assignments and buffered frames survive an
+// instance draining or crashing. Tier limits come from each instance's own
+// configuration and are passed to every script call.
+type RedisStreamBuffer struct {
+	client   *redis.Client
+	cfg      config.StreamConfig
+	tierArgs []interface{}
+	metrics  *streamBufferMetrics
+}
+
+// NewRedisStreamBuffer connects to the Redis server in cfg and verifies it is
+// reachable. Metrics are registered on registry when it is non-nil.
+func NewRedisStreamBuffer(cfg *config.StreamConfig, registry *prometheus.Registry) (*RedisStreamBuffer, error) {
+	limits := streamBufferLimits(cfg)
+
+	names := make([]string, 0, len(limits.Tiers))
+	for name := range limits.Tiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tierArgs := make([]interface{}, 0, 3*len(names))
+	for _, name := range names {
+		tier := limits.Tiers[name]
+		tierArgs = append(tierArgs, name, tier.BufferSize, tier.MaxFrameAge.Milliseconds())
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		_ = client.Close()
+		return nil, fmt.Errorf("failed to reach stream buffer Redis at %s: %w", cfg.RedisAddr, err)
+	}
+
+	return &RedisStreamBuffer{
+		client:   client,
+		cfg:      limits,
+		tierArgs: tierArgs,
+		metrics:  newStreamBufferMetrics(registry),
+	}, nil
+}
+
+// streamKeys returns the sequence, frame set and tier keys of sessionID.
+func streamKeys(sessionID string) []string {
+	return []string{
+		fmt.Sprintf(streamKeyFormat, sessionID, "seq"),
+		fmt.Sprintf(streamKeyFormat, sessionID, "frames"),
+		fmt.Sprintf(streamKeyFormat, sessionID, "tier"),
+	}
+}
+
+// scriptArgs builds the leading arguments shared by the buffer scripts, followed
+// by extra and the tier table.
+func (b *RedisStreamBuffer) scriptArgs(extra ...interface{}) []interface{} {
+	args := []interface{}{time.Now().UnixMilli(), b.cfg.BufferSize, b.cfg.MaxFrameAge.Milliseconds()}
+	args = append(args, extra...)
+	return append(args, b.tierArgs...)
+}
+
+// Append implements StreamBuffer.
+func (b *RedisStreamBuffer) Append(ctx context.Context, sessionID string, payload []byte) (uint64, error) {
+	args := b.scriptArgs(payload, b.cfg.BufferTTL.Milliseconds())
+	reply, err := appendFrameScript.Run(ctx, b.client, streamKeys(sessionID), args...).Slice()
+	if err != nil {
+		return 0, fmt.Errorf("failed to buffer stream frame for session %s: %w", sessionID, err)
+	}
+	if len(reply) != 4 {
+		return 0, fmt.Errorf("unexpected stream buffer reply for session %s", sessionID)
+	}
+	seq, _ := reply[0].(int64)
+	tier, _ := reply[1].(string)
+	evicted, _ := reply[2].(int64)
+	expired, _ := reply[3].(int64)
+
+	b.metrics.recordDropped(tier, FrameDropCapacity, int(evicted))
+	b.metrics.recordDropped(tier, FrameDropAge, int(expired))
+	return uint64(seq), nil
+}
+
+// Since implements StreamBuffer.
+func (b *RedisStreamBuffer) Since(ctx context.Context, sessionID string, afterSeq uint64) ([]BufferedFrame, bool, error) {
+	args := b.scriptArgs(strconv.FormatUint(afterSeq, 10))
+	reply, err := sinceFramesScript.Run(ctx, b.client, streamKeys(sessionID), args...).Slice()
+	if errors.Is(err, redis.Nil) {
+		return nil, afterSeq == 0, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read buffered frames for session %s: %w", sessionID, err)
+	}
+	if len(reply) != 4 {
+		return nil, false, fmt.Errorf("unexpected stream buffer reply for session %s", sessionID)
+	}
+	lastSeq, _ := reply[0].(int64)
+	tier, _ := reply[1].(string)
+	expired, _ := reply[2].(int64)
+	members, _ := reply[3].([]interface{})
+
+	frames := make([]BufferedFrame, 0, len(members))
+	for _, m := range members {
+		member, ok := m.(string)
+		if !ok {
+			continue
+		}
+		if frame, ok := parseFrameMember(member); ok {
+			frames = append(frames, frame)
+		}
+	}
+
+	b.metrics.recordDropped(tier, FrameDropAge, int(expired))
+	b.metrics.recordReplayed(tier, len(frames))
+	return frames, resumeComplete(frames, afterSeq, uint64(lastSeq)), nil
+}
+
+// SetTier implements StreamBuffer. The assignment expires with the session's
+// other keys once it is idle for the buffer TTL.
+func (b *RedisStreamBuffer) SetTier(ctx context.Context, sessionID string, tier string) error {
+	if _, ok := b.cfg.TierLimits(tier); !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownStreamTier, tier)
+	}
+	if err := b.client.Set(ctx, streamKeys(sessionID)[2], tier, b.cfg.BufferTTL).Err(); err != nil {
+		return fmt.Errorf("failed to set stream tier for session %s: %w", sessionID, err)
+	}
+	return nil
+}
+
+// parseFrameMember decodes a "seq:bufferedAtMillis:payload" frame set member.
+// Members written before frames carried a timestamp ("seq:payload") decode
+// with a zero BufferedAt.
+func parseFrameMember(member string) (BufferedFrame, bool) {
+	prefix, rest, found := strings.Cut(member, ":")
+	if !found {
+		return BufferedFrame{}, false
+	}
+	seq, err := strconv.ParseUint(prefix, 10, 64)
+	if err != nil {
+		return BufferedFrame{}, false
+	}
+	frame := BufferedFrame{Sequence: seq, Payload: []byte(rest)}
+	if atStr, payload, found := strings.Cut(rest, ":"); found {
+		if at, err := strconv.ParseInt(atStr, 10, 64); err == nil {
+			frame.Payload = []byte(payload)
+			frame.BufferedAt = time.UnixMilli(at)
+		}
+	}
+	return frame, true
+}
+
+// Close releases the Redis connection pool.
+func (b *RedisStreamBuffer) Close() error {
+	return b.client.Close()
+}